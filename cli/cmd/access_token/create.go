@@ -1,6 +1,9 @@
 package access_token
 
 import (
+	"encoding/json"
+	"log"
+	"os"
 	"time"
 
 	"github.com/gravitl/netmaker/cli/functions"
@@ -16,6 +19,8 @@ var accessTokenCreateCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		userName, _ := cmd.Flags().GetString("user")
 		expiresAt, _ := cmd.Flags().GetString("expires")
+		scopesFilePath, _ := cmd.Flags().GetString("scopes")
+		allowedCIDRs, _ := cmd.Flags().GetStringSlice("allowed_cidrs")
 
 		accessToken := &schema.UserAccessToken{}
 		accessToken.Name = args[0]
@@ -30,6 +35,18 @@ var accessTokenCreateCmd = &cobra.Command{
 			}
 		}
 		accessToken.ExpiresAt = expTime
+		accessToken.AllowedCIDRs = allowedCIDRs
+
+		if scopesFilePath != "" {
+			content, err := os.ReadFile(scopesFilePath)
+			if err != nil {
+				log.Fatal("Error when opening file: ", err)
+			}
+			if err := json.Unmarshal(content, accessToken); err != nil {
+				log.Fatal(err)
+			}
+			accessToken.Scoped = true
+		}
 
 		functions.PrettyPrint(functions.CreateAccessToken(accessToken))
 	},
@@ -38,6 +55,8 @@ var accessTokenCreateCmd = &cobra.Command{
 func init() {
 	accessTokenCreateCmd.Flags().String("user", "", "Username to create token for")
 	accessTokenCreateCmd.Flags().String("expires", "", "Expiration time for the token in RFC3339 format (e.g. 2024-01-01T00:00:00Z). Defaults to 1 year from now.")
+	accessTokenCreateCmd.Flags().String("scopes", "", "Path to a json file with the global_level_access and network_level_access the token is restricted to")
+	accessTokenCreateCmd.Flags().StringSlice("allowed_cidrs", []string{}, "Source ranges the token can be used from")
 	accessTokenCreateCmd.MarkFlagRequired("user")
 	rootCmd.AddCommand(accessTokenCreateCmd)
 }
@@ -0,0 +1,13 @@
+package acl
+
+var (
+	networkID     string
+	srcType       string
+	src           string
+	dstType       string
+	dst           string
+	protocol      string
+	port          string
+	atTime        string
+	draftFilePath string
+)
@@ -0,0 +1,72 @@
+package acl
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gravitl/netmaker/cli/functions"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/spf13/cobra"
+)
+
+var aclSimulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Check whether traffic between two peers is allowed by the network policies",
+	Long: `Check whether traffic between two peers is allowed by the network policies.
+Source and destination types: user, user-group, tag, device, egress-id, ip.
+An unsaved policy can be evaluated along with the saved ones using --draft.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		req := &models.AclSimulationRequest{
+			NetworkID: schema.NetworkID(networkID),
+			Src: models.AclSimulationPeer{
+				Type:  models.AclGroupType(srcType),
+				Value: src,
+			},
+			Dst: models.AclSimulationPeer{
+				Type:  models.AclGroupType(dstType),
+				Value: dst,
+			},
+			Proto: models.Protocol(protocol),
+			Port:  port,
+		}
+		if atTime != "" {
+			t, err := time.Parse(time.RFC3339, atTime)
+			if err != nil {
+				log.Fatal("Invalid time: ", err)
+			}
+			req.Time = &t
+		}
+		if draftFilePath != "" {
+			content, err := os.ReadFile(draftFilePath)
+			if err != nil {
+				log.Fatal("Error when opening file: ", err)
+			}
+			draft := &models.Acl{}
+			if err := json.Unmarshal(content, draft); err != nil {
+				log.Fatal(err)
+			}
+			req.DraftAcl = draft
+		}
+		functions.PrettyPrint(functions.SimulateAcl(req))
+	},
+}
+
+func init() {
+	aclSimulateCmd.Flags().StringVar(&networkID, "network", "", "Name of the network")
+	aclSimulateCmd.Flags().StringVar(&srcType, "src-type", string(models.NodeID), "Type of the source")
+	aclSimulateCmd.Flags().StringVar(&src, "src", "", "Source identity (username, group, tag, device id or ip)")
+	aclSimulateCmd.Flags().StringVar(&dstType, "dst-type", string(models.NodeID), "Type of the destination")
+	aclSimulateCmd.Flags().StringVar(&dst, "dst", "", "Destination identity (tag, device id, egress id or ip)")
+	aclSimulateCmd.Flags().StringVar(&protocol, "protocol", string(models.ALL), "Protocol (Enum:- all, tcp, udp, icmp)")
+	aclSimulateCmd.Flags().StringVar(&port, "port", "", "Destination port")
+	aclSimulateCmd.Flags().StringVar(&atTime, "time", "", "Evaluate scheduled policies at this time (RFC3339), defaults to now")
+	aclSimulateCmd.Flags().StringVar(&draftFilePath, "draft", "", "Path to an unsaved acl policy definition (JSON)")
+	aclSimulateCmd.MarkFlagRequired("network")
+	aclSimulateCmd.MarkFlagRequired("src")
+	aclSimulateCmd.MarkFlagRequired("dst")
+	rootCmd.AddCommand(aclSimulateCmd)
+}
@@ -0,0 +1,100 @@
+package apply
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gravitl/netmaker/cli/cmd/commons"
+	"github.com/gravitl/netmaker/cli/functions"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	filePath string
+	dryRun   bool
+)
+
+// rootCmd represents the base command when called without any subcommands
+var rootCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply a declarative network document",
+	Long: `Apply a declarative network document (YAML or JSON) as exported by nmctl export network.
+The network is created if it does not exist. Resources missing from the document are deleted,
+default resources created along with the network are kept. Use --dry-run to review the plan.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			log.Fatal("Error when opening file: ", err)
+		}
+		spec := &models.NetworkSpec{}
+		if err := yaml.Unmarshal(content, spec); err != nil {
+			log.Fatal(err)
+		}
+		if spec.Network.Name == "" {
+			log.Fatal("network name is required")
+		}
+		plan := functions.ApplyNetwork(spec, dryRun)
+		if commons.OutputFormat == commons.JsonOutput {
+			functions.PrettyPrint(plan)
+			return
+		}
+		printPlan(plan)
+	},
+}
+
+// printPlan - prints one line per planned change followed by a summary
+func printPlan(plan *models.NetworkSpecPlan) {
+	counts := make(map[schema.Action]int)
+	for _, change := range plan.Changes {
+		counts[change.Action]++
+		resource := strings.ToLower(strings.ReplaceAll(string(change.Resource), "_", " "))
+		switch change.Action {
+		case schema.Create:
+			fmt.Printf("  + %s %s\n", resource, change.Name)
+		case schema.Delete:
+			fmt.Printf("  - %s %s\n", resource, change.Name)
+		default:
+			action := "~"
+			if change.Replace {
+				action = "-/+"
+			}
+			fmt.Printf("  %s %s %s (%s)\n", action, resource, change.Name, strings.Join(change.Fields, ", "))
+		}
+	}
+	if len(plan.Changes) == 0 {
+		fmt.Printf("network %s is up to date\n", plan.Network)
+		return
+	}
+	verb := "applied"
+	if plan.DryRun {
+		verb = "planned"
+	}
+	fmt.Printf("\n%s: %d to create, %d to update, %d to delete\n", verb,
+		counts[schema.Create], counts[schema.Update], counts[schema.Delete])
+}
+
+// GetRoot returns the root subcommand
+func GetRoot() *cobra.Command {
+	return rootCmd
+}
+
+// Execute adds all child commands to the root command and sets flags appropriately.
+// This is called by main.main(). It only needs to happen once to the rootCmd.
+func Execute() {
+	err := rootCmd.Execute()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.Flags().StringVarP(&filePath, "file", "f", "", "Path to the network document")
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the changes that would be applied")
+	rootCmd.MarkFlagRequired("file")
+}
@@ -0,0 +1,45 @@
+package export
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gravitl/netmaker/cli/cmd/commons"
+	"github.com/gravitl/netmaker/cli/functions"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var outFilePath string
+
+var exportNetworkCmd = &cobra.Command{
+	Use:   "network [NETWORK NAME]",
+	Short: "Export a network and its resources as a YAML document",
+	Long: `Export a network along with its tags, acl policies, egress routes, nameservers,
+enrollment keys and posture checks as a YAML document that can be applied with nmctl apply.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		spec := functions.ExportNetwork(args[0])
+		if commons.OutputFormat == commons.JsonOutput {
+			functions.PrettyPrint(spec)
+			return
+		}
+		data, err := yaml.Marshal(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if outFilePath == "" {
+			fmt.Print(string(data))
+			return
+		}
+		if err := os.WriteFile(outFilePath, data, 0644); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	exportNetworkCmd.Flags().StringVarP(&outFilePath, "file", "f", "", "Write the document to a file instead of stdout")
+	rootCmd.AddCommand(exportNetworkCmd)
+}
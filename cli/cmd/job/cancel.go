@@ -0,0 +1,20 @@
+package job
+
+import (
+	"github.com/gravitl/netmaker/cli/functions"
+	"github.com/spf13/cobra"
+)
+
+var jobCancelCmd = &cobra.Command{
+	Use:   "cancel [JOB ID]",
+	Args:  cobra.ExactArgs(1),
+	Short: "Cancel a background job",
+	Long:  `Cancel a queued or running background job`,
+	Run: func(cmd *cobra.Command, args []string) {
+		functions.PrettyPrint(functions.CancelJob(args[0]))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(jobCancelCmd)
+}
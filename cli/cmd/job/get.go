@@ -0,0 +1,20 @@
+package job
+
+import (
+	"github.com/gravitl/netmaker/cli/functions"
+	"github.com/spf13/cobra"
+)
+
+var jobGetCmd = &cobra.Command{
+	Use:   "get [JOB ID]",
+	Args:  cobra.ExactArgs(1),
+	Short: "Get a background job",
+	Long:  `Get the status and progress of a background job`,
+	Run: func(cmd *cobra.Command, args []string) {
+		functions.PrettyPrint(functions.GetJob(args[0]))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(jobGetCmd)
+}
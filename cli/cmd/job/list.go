@@ -0,0 +1,27 @@
+package job
+
+import (
+	"github.com/gravitl/netmaker/cli/functions"
+	"github.com/spf13/cobra"
+)
+
+var (
+	jobType   string
+	jobStatus string
+)
+
+var jobListCmd = &cobra.Command{
+	Use:   "list",
+	Args:  cobra.NoArgs,
+	Short: "List background jobs",
+	Long:  `List background jobs, latest first`,
+	Run: func(cmd *cobra.Command, args []string) {
+		functions.PrettyPrint(functions.GetJobs(jobType, jobStatus))
+	},
+}
+
+func init() {
+	jobListCmd.Flags().StringVar(&jobType, "type", "", "Job type to filter by")
+	jobListCmd.Flags().StringVar(&jobStatus, "status", "", "Status to filter by (queued, running, succeeded, failed or cancelled)")
+	rootCmd.AddCommand(jobListCmd)
+}
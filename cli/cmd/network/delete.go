@@ -13,7 +13,8 @@ var networkDeleteCmd = &cobra.Command{
 	Long:  `Delete a Network`,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println(*functions.DeleteNetwork(args[0]))
+		job := functions.DeleteNetwork(args[0])
+		fmt.Printf("Network deletion queued, check its progress with: nmctl job get %s\n", job.ID)
 	},
 }
 
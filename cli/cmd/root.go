@@ -4,14 +4,18 @@ import (
 	"os"
 
 	"github.com/gravitl/netmaker/cli/cmd/access_token"
+	"github.com/gravitl/netmaker/cli/cmd/acl"
+	"github.com/gravitl/netmaker/cli/cmd/apply"
 	"github.com/gravitl/netmaker/cli/cmd/commons"
 	"github.com/gravitl/netmaker/cli/cmd/context"
 	"github.com/gravitl/netmaker/cli/cmd/dns"
 	"github.com/gravitl/netmaker/cli/cmd/enrollment_key"
+	"github.com/gravitl/netmaker/cli/cmd/export"
 	"github.com/gravitl/netmaker/cli/cmd/ext_client"
 	"github.com/gravitl/netmaker/cli/cmd/failover"
 	"github.com/gravitl/netmaker/cli/cmd/gateway"
 	"github.com/gravitl/netmaker/cli/cmd/host"
+	"github.com/gravitl/netmaker/cli/cmd/job"
 	"github.com/gravitl/netmaker/cli/cmd/metrics"
 	"github.com/gravitl/netmaker/cli/cmd/network"
 	"github.com/gravitl/netmaker/cli/cmd/node"
@@ -58,4 +62,8 @@ func init() {
 	rootCmd.AddCommand(failover.GetRoot())
 	rootCmd.AddCommand(gateway.GetRoot())
 	rootCmd.AddCommand(access_token.GetRoot())
+	rootCmd.AddCommand(acl.GetRoot())
+	rootCmd.AddCommand(export.GetRoot())
+	rootCmd.AddCommand(apply.GetRoot())
+	rootCmd.AddCommand(job.GetRoot())
 }
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gravitl/netmaker/cli/functions"
+	"github.com/spf13/cobra"
+)
+
+var (
+	backupFile       string
+	backupPassphrase string
+)
+
+var serverBackupCmd = &cobra.Command{
+	Use:   "backup",
+	Args:  cobra.NoArgs,
+	Short: "Download a backup of the server state",
+	Long: `Download a backup of the server state covering all networks, hosts, users and settings.
+The backup is encrypted when a passphrase is provided.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		archive := functions.BackupServer(backupPassphrase)
+		if err := os.WriteFile(backupFile, archive, 0600); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("backup written to %s (%d bytes)\n", backupFile, len(archive))
+	},
+}
+
+func init() {
+	serverBackupCmd.Flags().StringVarP(&backupFile, "file", "f", "", "File to write the backup to")
+	serverBackupCmd.MarkFlagRequired("file")
+	serverBackupCmd.Flags().StringVar(&backupPassphrase, "passphrase", "", "Passphrase to encrypt the backup with")
+	rootCmd.AddCommand(serverBackupCmd)
+}
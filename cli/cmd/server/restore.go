@@ -0,0 +1,39 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gravitl/netmaker/cli/functions"
+	"github.com/spf13/cobra"
+)
+
+var (
+	restoreFile       string
+	restorePassphrase string
+)
+
+var serverRestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Args:  cobra.NoArgs,
+	Short: "Restore the server state from a backup",
+	Long: `Restore the server state from a backup. The server must not have any networks or hosts yet.
+Restart the server once the restore completed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		archive, err := os.ReadFile(restoreFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		summary := functions.RestoreServer(archive, restorePassphrase)
+		functions.PrettyPrint(summary)
+		fmt.Println("restore completed, restart the server to reload all components")
+	},
+}
+
+func init() {
+	serverRestoreCmd.Flags().StringVarP(&restoreFile, "file", "f", "", "Backup file to restore")
+	serverRestoreCmd.MarkFlagRequired("file")
+	serverRestoreCmd.Flags().StringVar(&restorePassphrase, "passphrase", "", "Passphrase the backup was encrypted with")
+	rootCmd.AddCommand(serverRestoreCmd)
+}
@@ -0,0 +1,13 @@
+package functions
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// SimulateAcl - evaluates simulated traffic against the policies of a network
+func SimulateAcl(req *models.AclSimulationRequest) *models.SuccessResponse {
+	return request[models.SuccessResponse](http.MethodPost, "/api/v1/acls/simulate?network="+url.QueryEscape(req.NetworkID.String()), req)
+}
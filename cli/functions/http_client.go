@@ -186,7 +186,8 @@ retry:
 	if err != nil {
 		log.Fatalf("Client could not read response body: %s", err)
 	}
-	if res.StatusCode != http.StatusOK {
+	// 202 Accepted is returned for operations queued as background jobs
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusAccepted {
 		log.Fatalf("Error Status: %d Response: %s", res.StatusCode, string(resBodyBytes))
 	}
 	body := new(T)
@@ -198,6 +199,46 @@ retry:
 	return body
 }
 
+// rawRequest - makes a request with a raw body and returns the raw response body
+func rawRequest(method, route string, payload []byte, headers map[string]string) []byte {
+	_, ctx := config.GetCurrentContext()
+	req, err := http.NewRequest(method, ctx.Endpoint+route, bytes.NewReader(payload))
+	if err != nil {
+		log.Fatalf("Client could not create request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if ctx.MasterKey != "" {
+		req.Header.Set("Authorization", "Bearer "+ctx.MasterKey)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+getAuthToken(ctx, false))
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatalf("Client error making http request: %s", err)
+	}
+	// refresh JWT token
+	if res.StatusCode == http.StatusUnauthorized && ctx.MasterKey == "" {
+		res.Body.Close()
+		req.Header.Set("Authorization", "Bearer "+getAuthToken(ctx, true))
+		req.Body = io.NopCloser(bytes.NewReader(payload))
+		if res, err = http.DefaultClient.Do(req); err != nil {
+			log.Fatalf("Client error making http request: %s", err)
+		}
+	}
+	defer res.Body.Close()
+	resBodyBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		log.Fatalf("Client could not read response body: %s", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		log.Fatalf("Error Status: %d Response: %s", res.StatusCode, string(resBodyBytes))
+	}
+	return resBodyBytes
+}
+
 func get(route string) string {
 	_, ctx := config.GetCurrentContext()
 	req, err := http.NewRequest(http.MethodGet, ctx.Endpoint+route, nil)
@@ -0,0 +1,58 @@
+package functions
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+)
+
+// GetJobs - fetch the background jobs, optionally filtered by type and status
+func GetJobs(jobType, status string) []schema.Job {
+	query := url.Values{}
+	if jobType != "" {
+		query.Set("type", jobType)
+	}
+	if status != "" {
+		query.Set("status", status)
+	}
+	route := "/api/v1/jobs"
+	if len(query) > 0 {
+		route += "?" + query.Encode()
+	}
+	var jobs []schema.Job
+	decodeResponse(request[models.SuccessResponse](http.MethodGet, route, nil), &jobs)
+	return jobs
+}
+
+// GetJob - fetch a background job
+func GetJob(id string) *schema.Job {
+	return decodeJob(request[models.SuccessResponse](http.MethodGet, "/api/v1/jobs/"+id, nil))
+}
+
+// CancelJob - cancel a background job
+func CancelJob(id string) *schema.Job {
+	return decodeJob(request[models.SuccessResponse](http.MethodPost, "/api/v1/jobs/"+id+"/cancel", nil))
+}
+
+// decodeJob - extracts the job from the response of an endpoint queueing or
+// returning a job
+func decodeJob(res *models.SuccessResponse) *schema.Job {
+	job := new(schema.Job)
+	decodeResponse(res, job)
+	return job
+}
+
+// decodeResponse - decodes the Response of a models.SuccessResponse into v
+func decodeResponse(res *models.SuccessResponse, v any) {
+	responseBytes, err := json.Marshal(res.Response)
+	if err != nil {
+		log.Fatalf("Error marshaling response: %v", err)
+	}
+	if err := json.Unmarshal(responseBytes, v); err != nil {
+		log.Fatalf("Error unmarshaling response: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package functions
 import (
 	"net/http"
 
+	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/schema"
 )
 
@@ -21,7 +22,7 @@ func GetNetwork(name string) *schema.Network {
 	return request[schema.Network](http.MethodGet, "/api/networks/"+name, nil)
 }
 
-// DeleteNetwork - delete a network
-func DeleteNetwork(name string) *string {
-	return request[string](http.MethodDelete, "/api/networks/"+name, nil)
+// DeleteNetwork - delete a network, returns the job deleting it
+func DeleteNetwork(name string) *schema.Job {
+	return decodeJob(request[models.SuccessResponse](http.MethodDelete, "/api/networks/"+name, nil))
 }
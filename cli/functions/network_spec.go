@@ -0,0 +1,34 @@
+package functions
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+type networkSpecResponse struct {
+	Code     int
+	Message  string
+	Response models.NetworkSpec
+}
+
+type networkSpecPlanResponse struct {
+	Code     int
+	Message  string
+	Response models.NetworkSpecPlan
+}
+
+// ExportNetwork - fetches the declarative document of a network
+func ExportNetwork(name string) *models.NetworkSpec {
+	return &request[networkSpecResponse](http.MethodGet, "/api/v1/networks/"+url.PathEscape(name)+"/export", nil).Response
+}
+
+// ApplyNetwork - applies a declarative network document, or only plans the changes on a dry run
+func ApplyNetwork(spec *models.NetworkSpec, dryRun bool) *models.NetworkSpecPlan {
+	route := "/api/v1/networks/" + url.PathEscape(spec.Network.Name) + "/apply"
+	if dryRun {
+		route += "?dry_run=true"
+	}
+	return &request[networkSpecPlanResponse](http.MethodPost, route, spec).Response
+}
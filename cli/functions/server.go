@@ -1,6 +1,8 @@
 package functions
 
 import (
+	"encoding/json"
+	"log"
 	"net/http"
 
 	cfg "github.com/gravitl/netmaker/config"
@@ -26,3 +28,31 @@ func GetServerConfig() *cfg.ServerConfig {
 func GetServerHealth() string {
 	return get("/api/server/health")
 }
+
+type backupSummaryResponse struct {
+	Code     int
+	Message  string
+	Response models.BackupSummary
+}
+
+// BackupServer - download a backup archive of the server state, encrypted when a passphrase is set
+func BackupServer(passphrase string) []byte {
+	headers := map[string]string{}
+	if passphrase != "" {
+		headers["X-Backup-Passphrase"] = passphrase
+	}
+	return rawRequest(http.MethodGet, "/api/server/backup", nil, headers)
+}
+
+// RestoreServer - restore a backup archive into an empty server
+func RestoreServer(archive []byte, passphrase string) *models.BackupSummary {
+	headers := map[string]string{}
+	if passphrase != "" {
+		headers["X-Backup-Passphrase"] = passphrase
+	}
+	res := backupSummaryResponse{}
+	if err := json.Unmarshal(rawRequest(http.MethodPost, "/api/server/restore", archive, headers), &res); err != nil {
+		log.Fatalf("Error unmarshalling JSON: %s", err)
+	}
+	return &res.Response
+}
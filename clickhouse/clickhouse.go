@@ -23,6 +23,9 @@ var ErrConnNotFound = errors.New("no connection in context")
 //go:embed initdb.d/02_create_flows_table.sql
 var createFlowsTableScript string
 
+//go:embed initdb.d/03_create_metrics_table.sql
+var createMetricsTableScript string
+
 func Initialize() error {
 	if ch != nil {
 		return nil
@@ -49,6 +52,11 @@ func Initialize() error {
 		return err
 	}
 
+	err = chConn.Exec(ctx, createMetricsTableScript)
+	if err != nil {
+		return err
+	}
+
 	ch = chConn
 	return nil
 }
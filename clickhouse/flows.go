@@ -0,0 +1,109 @@
+package clickhouse
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/grpc/flow"
+)
+
+const insertFlowsQuery = `
+INSERT INTO flows (
+	flow_id, host_id, host_name, network_id,
+	protocol, src_port, dst_port,
+	icmp_type, icmp_code, direction,
+	src_ip, src_type, src_entity_id, src_entity_name,
+	dst_ip, dst_type, dst_entity_id, dst_entity_name,
+	start_ts, end_ts,
+	bytes_sent, bytes_recv,
+	packets_sent, packets_recv,
+	status, version
+)`
+
+var directionNames = map[flow.Direction]string{
+	flow.Direction_DIR_INGRESS: "ingress",
+	flow.Direction_DIR_EGRESS:  "egress",
+}
+
+var participantTypeNames = map[flow.ParticipantType]string{
+	flow.ParticipantType_PARTICIPANT_NODE:         "node",
+	flow.ParticipantType_PARTICIPANT_USER:         "user",
+	flow.ParticipantType_PARTICIPANT_EXTCLIENT:    "extclient",
+	flow.ParticipantType_PARTICIPANT_EGRESS_ROUTE: "egress_route",
+	flow.ParticipantType_PARTICIPANT_EXTERNAL:     "external",
+}
+
+// FlowWriter writes flow events received from netclients
+// to the flows table.
+type FlowWriter struct{}
+
+// WriteFlows inserts the given events into the flows table
+// as a single batch.
+//
+// Events are expected to be validated by the caller; the
+// direction and participant types must be specified.
+func (FlowWriter) WriteFlows(ctx context.Context, events []*flow.FlowEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	conn, err := FromContext(WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	batch, err := conn.PrepareBatch(ctx, insertFlowsQuery)
+	if err != nil {
+		return err
+	}
+	defer batch.Close()
+
+	for _, event := range events {
+		src := event.GetSrc()
+		dst := event.GetDst()
+		err = batch.Append(
+			event.GetFlowId(),
+			event.GetHostId(),
+			event.GetHostName(),
+			event.GetNetworkId(),
+			uint16(event.GetProtocol()),
+			uint16(event.GetSrcPort()),
+			uint16(event.GetDstPort()),
+			uint8(event.GetIcmpType()),
+			uint8(event.GetIcmpCode()),
+			directionNames[event.GetDirection()],
+			src.GetIp(),
+			participantTypeName(src.GetType()),
+			src.GetId(),
+			src.GetName(),
+			dst.GetIp(),
+			participantTypeName(dst.GetType()),
+			dst.GetId(),
+			dst.GetName(),
+			time.UnixMilli(event.GetStartTsMs()),
+			time.UnixMilli(event.GetEndTsMs()),
+			event.GetBytesSent(),
+			event.GetBytesRecv(),
+			event.GetPacketsSent(),
+			event.GetPacketsRecv(),
+			event.GetStatus(),
+			time.UnixMilli(event.GetVersion()),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return batch.Send()
+}
+
+// participantTypeName maps a participant type to its enum value
+// in the flows table. Participants the netclient could not
+// classify are recorded as external.
+func participantTypeName(t flow.ParticipantType) string {
+	name, ok := participantTypeNames[t]
+	if !ok {
+		return participantTypeNames[flow.ParticipantType_PARTICIPANT_EXTERNAL]
+	}
+	return name
+}
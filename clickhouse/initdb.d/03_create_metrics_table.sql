@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS metrics (
+    network_id         String,
+    node_id            String,
+    peer_id            String,
+
+    ts                 DateTime64(3),
+
+    latency            Int64,
+    total_sent         Int64,
+    total_received     Int64,
+    connected          UInt8
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMMDD(ts)
+ORDER BY (node_id, peer_id, ts);
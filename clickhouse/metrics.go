@@ -0,0 +1,130 @@
+package clickhouse
+
+import (
+	"context"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/gravitl/netmaker/schema"
+)
+
+const insertMetricsQuery = `
+INSERT INTO metrics (
+	network_id, node_id, peer_id, ts,
+	latency, total_sent, total_received, connected
+)`
+
+// metrics are downsampled when they are read, samples of a bucket are
+// aggregated the same way the sql store rolls them up.
+const selectMetricsQuery = `
+SELECT
+	any(network_id),
+	node_id,
+	peer_id,
+	toStartOfInterval(ts, INTERVAL {step:UInt32} SECOND) AS bucket,
+	toInt64(avg(latency)),
+	max(latency),
+	max(total_sent),
+	max(total_received),
+	avg(connected),
+	toInt64(count())
+FROM metrics
+WHERE node_id = {nodeID:String}
+	AND ({peerID:String} = '' OR peer_id = {peerID:String})
+	AND ts >= {from:DateTime64(3)} AND ts < {to:DateTime64(3)}
+GROUP BY node_id, peer_id, bucket
+ORDER BY bucket`
+
+// MetricsWriter writes the metric samples reported by
+// netclients to the metrics table.
+type MetricsWriter struct{}
+
+// WriteMetrics inserts the given samples into the metrics
+// table as a single batch.
+func (MetricsWriter) WriteMetrics(ctx context.Context, points []schema.MetricPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	conn, err := FromContext(WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	batch, err := conn.PrepareBatch(ctx, insertMetricsQuery)
+	if err != nil {
+		return err
+	}
+	defer batch.Close()
+
+	for _, point := range points {
+		var connected uint8
+		if point.Connected > 0 {
+			connected = 1
+		}
+		err = batch.Append(
+			point.Network,
+			point.NodeID,
+			point.PeerID,
+			point.TimeStamp,
+			point.Latency,
+			point.TotalSent,
+			point.TotalReceived,
+			connected,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return batch.Send()
+}
+
+// QueryMetrics returns the samples of a node in [from, to),
+// aggregated into buckets of step. Samples towards all peers
+// are returned when peerID is empty.
+func (MetricsWriter) QueryMetrics(ctx context.Context, nodeID, peerID string, from, to time.Time, step time.Duration) ([]schema.MetricPoint, error) {
+	conn, err := FromContext(WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if step < time.Second {
+		step = time.Second
+	}
+
+	rows, err := conn.Query(ctx, selectMetricsQuery,
+		clickhouse.Named("step", uint32(step/time.Second)),
+		clickhouse.Named("nodeID", nodeID),
+		clickhouse.Named("peerID", peerID),
+		clickhouse.Named("from", from),
+		clickhouse.Named("to", to),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []schema.MetricPoint
+	for rows.Next() {
+		point := schema.MetricPoint{Resolution: schema.MetricsRawResolution}
+		err = rows.Scan(
+			&point.Network,
+			&point.NodeID,
+			&point.PeerID,
+			&point.TimeStamp,
+			&point.Latency,
+			&point.MaxLatency,
+			&point.TotalSent,
+			&point.TotalReceived,
+			&point.Connected,
+			&point.Samples,
+		)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
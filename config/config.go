@@ -102,6 +102,9 @@ type ServerConfig struct {
 	SmtpPort                   int           `yaml:"smtp_port"`
 	MetricInterval             string        `yaml:"metric_interval"`
 	MetricsPort                int           `yaml:"metrics_port"`
+	MetricsScrapeToken         string        `yaml:"metrics_scrape_token"`
+	TrustedProxies             string        `yaml:"trusted_proxies"`
+	FlowGRPCPort               int           `yaml:"flow_grpc_port"`
 	ManageDNS                  bool          `yaml:"manage_dns"`
 	Stun                       bool          `yaml:"stun"`
 	StunServers                string        `yaml:"stun_servers"`
@@ -30,6 +30,8 @@ func aclHandlers(r *mux.Router) {
 		Methods(http.MethodPut)
 	r.HandleFunc("/api/v1/acls", logic.SecurityCheck(true, http.HandlerFunc(deleteAcl))).
 		Methods(http.MethodDelete)
+	r.HandleFunc("/api/v1/acls/simulate", logic.SecurityCheck(true, http.HandlerFunc(simulateAcl))).
+		Methods(http.MethodPost)
 	r.HandleFunc("/api/v1/acls/debug", logic.SecurityCheck(true, http.HandlerFunc(aclDebug))).
 		Methods(http.MethodGet)
 }
@@ -259,6 +261,45 @@ func getEgressAcls(w http.ResponseWriter, r *http.Request) {
 	logic.ReturnSuccessResponseWithJson(w, r, acls, "fetched acls for egress"+e.Name)
 }
 
+// @Summary     Simulate Acl policies
+// @Router      /api/v1/acls/simulate [post]
+// @Tags        ACL
+// @Security    oauth
+// @Accept      json
+// @Produce     json
+// @Param       network query string true "Network ID"
+// @Param       body body models.AclSimulationRequest true "Simulated traffic and optional draft policy"
+// @Success     200 {object} models.AclSimulationResult
+// @Failure     400 {object} models.ErrorResponse
+func simulateAcl(w http.ResponseWriter, r *http.Request) {
+	var req models.AclSimulationRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(0, "error decoding request body: ",
+			err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	netID := r.URL.Query().Get("network")
+	if netID == "" {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("network id param is missing"), "badrequest"))
+		return
+	}
+	if req.NetworkID == "" {
+		req.NetworkID = schema.NetworkID(netID)
+	}
+	if req.NetworkID.String() != netID {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("network id mismatch"), "badrequest"))
+		return
+	}
+	result, err := logic.SimulateAcl(req)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, result, "simulated acl policies")
+}
+
 // @Summary     Create Acl
 // @Router      /api/v1/acls [post]
 // @Tags        ACL
@@ -330,7 +371,7 @@ func createAcl(w http.ResponseWriter, r *http.Request) {
 		NetworkID: acl.NetworkID,
 		Origin:    schema.Dashboard,
 	})
-	go mq.PublishPeerUpdate(true)
+	go mq.PublishPeerUpdate(true, models.PeerUpdateChange{Policies: []string{acl.ID}})
 	acls := []models.Acl{acl}
 	logic.PopulateAclPolicyTagNames(acls)
 	logic.ReturnSuccessResponseWithJson(w, r, acls[0], "created acl successfully")
@@ -398,7 +439,7 @@ func updateAcl(w http.ResponseWriter, r *http.Request) {
 		NetworkID: acl.NetworkID,
 		Origin:    schema.Dashboard,
 	})
-	go mq.PublishPeerUpdate(true)
+	go mq.PublishPeerUpdate(true, models.PeerUpdateChange{Networks: []schema.NetworkID{acl.NetworkID}})
 	updatedAcl, err := logic.GetAcl(acl.ID)
 	if err != nil {
 		logic.ReturnSuccessResponse(w, r, "updated acl "+acl.Name)
@@ -459,6 +500,6 @@ func deleteAcl(w http.ResponseWriter, r *http.Request) {
 			New: nil,
 		},
 	})
-	go mq.PublishPeerUpdate(true)
+	go mq.PublishPeerUpdate(true, models.PeerUpdateChange{Networks: []schema.NetworkID{acl.NetworkID}})
 	logic.ReturnSuccessResponse(w, r, "deleted acl "+acl.Name)
 }
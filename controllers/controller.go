@@ -22,6 +22,7 @@ import (
 var HttpMiddlewares = []mux.MiddlewareFunc{
 	db.Middleware,
 	userMiddleWare,
+	requestMetricsMiddleware,
 }
 
 // HttpHandlers - handler functions for REST interactions
@@ -41,6 +42,8 @@ var HttpHandlers = []interface{}{
 	aclHandlers,
 	egressHandlers,
 	legacyHandlers,
+	prometheusHandlers,
+	jobHandlers,
 }
 
 func HandleRESTRequests(wg *sync.WaitGroup, ctx context.Context) {
@@ -57,6 +60,7 @@ func HandleRESTRequests(wg *sync.WaitGroup, ctx context.Context) {
 			"authorization",
 			"From-Ui",
 			"X-Application-Name",
+			"X-Backup-Passphrase",
 		},
 	)
 	originsOk := handlers.AllowedOrigins(strings.Split(servercfg.GetAllowedOrigin(), ","))
@@ -156,7 +156,7 @@ func createNs(w http.ResponseWriter, r *http.Request) {
 		Origin:    schema.Dashboard,
 	})
 
-	go mq.PublishPeerUpdate(false)
+	go mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(ns.NetworkID)}})
 	logic.ReturnSuccessResponseWithJson(w, r, ns, "created nameserver")
 }
 
@@ -314,7 +314,7 @@ func updateNs(w http.ResponseWriter, r *http.Request) {
 		ns.UpdateStatus(db.WithContext(context.TODO()))
 	}
 	logic.LogEvent(event)
-	go mq.PublishPeerUpdate(false)
+	go mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(ns.NetworkID)}})
 	logic.ReturnSuccessResponseWithJson(w, r, ns, "updated nameserver")
 }
 
@@ -372,7 +372,7 @@ func deleteNs(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 
-	go mq.PublishPeerUpdate(false)
+	go mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(ns.NetworkID)}})
 	logic.ReturnSuccessResponseWithJson(w, r, nil, "deleted nameserver resource")
 }
 
@@ -174,7 +174,7 @@ func createEgress(w http.ResponseWriter, r *http.Request) {
 		}
 
 	} else {
-		go mq.PublishPeerUpdate(false)
+		go mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(e.Network)}})
 	}
 
 	logic.ReturnSuccessResponseWithJson(w, r, e, "created egress resource")
@@ -401,7 +401,7 @@ func updateEgress(w http.ResponseWriter, r *http.Request) {
 		}
 
 	}
-	go mq.PublishPeerUpdate(false)
+	go mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(e.Network)}})
 	logic.ReturnSuccessResponseWithJson(w, r, e, "updated egress resource")
 }
 
@@ -468,6 +468,6 @@ func deleteEgress(w http.ResponseWriter, r *http.Request) {
 			logic.UpsertAcl(acl)
 		}
 	}
-	go mq.PublishPeerUpdate(false)
+	go mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(e.Network)}})
 	logic.ReturnSuccessResponseWithJson(w, r, nil, "deleted egress resource")
 }
@@ -640,7 +640,7 @@ Endpoint = %s
 	)
 
 	go func() {
-		if err := mq.PublishPeerUpdate(false); err != nil {
+		if err := mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(client.Network)}}); err != nil {
 			logger.Log(1, "error publishing peer update ", err.Error())
 		}
 		if servercfg.IsDNSMode() {
@@ -913,7 +913,7 @@ func createExtClient(w http.ResponseWriter, r *http.Request) {
 
 	go func() {
 		extUpdateMutex.Lock()
-		mq.PublishPeerUpdate(false)
+		mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(extclient.Network)}})
 		extUpdateMutex.Unlock()
 		if servercfg.IsDNSMode() {
 			logic.SetDNS()
@@ -1081,7 +1081,7 @@ func updateExtClient(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		extUpdateMutex.Lock()
-		mq.PublishPeerUpdate(false)
+		mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(newclient.Network)}})
 		extUpdateMutex.Unlock()
 	}()
 
@@ -1235,7 +1235,7 @@ func bulkDeleteExtClients(w http.ResponseWriter, r *http.Request) {
 				go mq.PublishSingleHostPeerUpdate(gwHost, allNodes, nil, clients, false, nil)
 
 			}
-			go mq.PublishPeerUpdate(false)
+			go mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(network)}})
 			if servercfg.IsDNSMode() {
 				logic.SetDNS()
 			}
@@ -1335,56 +1335,87 @@ func bulkUpdateExtClientStatus(w http.ResponseWriter, r *http.Request) {
 	if !req.Enabled {
 		eventAction = schema.Disconnect
 	}
-	user := r.Header.Get("user")
-	logic.ReturnAcceptedResponse(w, r, fmt.Sprintf("bulk %s of %d ext client(s) accepted", eventAction, len(req.IDs)))
+	job, err := logic.EnqueueJob(logic.BulkExtClientStatusJob, r.Header.Get("user"), bulkExtClientStatusPayload{
+		Network:                   network,
+		BulkExtClientStatusUpdate: req,
+	})
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.Internal))
+		return
+	}
+	logic.ReturnAcceptedResponseWithJson(w, r, job, fmt.Sprintf("bulk %s of %d ext client(s) accepted", eventAction, len(req.IDs)))
+}
 
-	go func() {
-		updated := 0
-		for _, clientID := range req.IDs {
-			client, err := logic.GetExtClient(clientID, network)
-			if err != nil {
-				slog.Error("bulk extclient status: client not found", "client_id", clientID, "error", err)
-				continue
-			}
-			if client.Enabled == req.Enabled {
-				continue
-			}
-			oldClient := client
-			if _, err := logic.ToggleExtClientConnectivity(&client, req.Enabled); err != nil {
-				slog.Error("bulk extclient status: failed to toggle", "client_id", clientID, "error", err)
-				continue
-			}
-			if !req.Enabled {
-				if err := mq.PublishDeletedClientPeerUpdate(&client); err != nil {
-					slog.Error("bulk extclient status: error publishing peer update", "client_id", clientID, "error", err)
-				}
-			}
-			logic.LogEvent(&models.Event{
-				Action: eventAction,
-				Source: models.Subject{
-					ID:   user,
-					Name: user,
-					Type: schema.UserSub,
-				},
-				TriggeredBy: user,
-				Target: models.Subject{
-					ID:   client.ClientID,
-					Name: client.ClientID,
-					Type: schema.NetworkSub,
-					Info: client,
-				},
-				NetworkID: schema.NetworkID(network),
-				Origin:    schema.Dashboard,
-				Diff:      models.Diff{Old: oldClient, New: client},
-			})
-			updated++
-		}
+type bulkExtClientStatusPayload struct {
+	Network string `json:"network"`
+	models.BulkExtClientStatusUpdate
+}
+
+// runBulkExtClientStatusJob - enables or disables the ext clients of a bulkExtClientStatusPayload
+func runBulkExtClientStatusJob(ctx context.Context, run *logic.JobRun) error {
+	var req bulkExtClientStatusPayload
+	if err := run.Payload(&req); err != nil {
+		return err
+	}
+	network := req.Network
+	eventAction := schema.Connect
+	if !req.Enabled {
+		eventAction = schema.Disconnect
+	}
+	user := run.Job.Initiator
+	run.SetTotal(len(req.IDs))
+	updated := 0
+	defer func() {
 		if updated > 0 {
-			mq.PublishPeerUpdate(false)
+			mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(network)}})
 			if servercfg.IsDNSMode() {
 				logic.SetDNS()
 			}
 		}
 		slog.Info("bulk extclient status completed", "action", eventAction, "updated", updated, "total", len(req.IDs))
 	}()
+	for _, clientID := range req.IDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		run.Step()
+		client, err := logic.GetExtClient(clientID, network)
+		if err != nil {
+			slog.Error("bulk extclient status: client not found", "client_id", clientID, "error", err)
+			continue
+		}
+		if client.Enabled == req.Enabled {
+			continue
+		}
+		oldClient := client
+		if _, err := logic.ToggleExtClientConnectivity(&client, req.Enabled); err != nil {
+			slog.Error("bulk extclient status: failed to toggle", "client_id", clientID, "error", err)
+			continue
+		}
+		if !req.Enabled {
+			if err := mq.PublishDeletedClientPeerUpdate(&client); err != nil {
+				slog.Error("bulk extclient status: error publishing peer update", "client_id", clientID, "error", err)
+			}
+		}
+		logic.LogEvent(&models.Event{
+			Action: eventAction,
+			Source: models.Subject{
+				ID:   user,
+				Name: user,
+				Type: schema.UserSub,
+			},
+			TriggeredBy: user,
+			Target: models.Subject{
+				ID:   client.ClientID,
+				Name: client.ClientID,
+				Type: schema.NetworkSub,
+				Info: client,
+			},
+			NetworkID: schema.NetworkID(network),
+			Origin:    schema.Dashboard,
+			Diff:      models.Diff{Old: oldClient, New: client},
+		})
+		updated++
+	}
+	return nil
 }
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/mq"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+// hostStreamKeepalive - interval of the comments keeping idle streams open
+// through proxies
+const hostStreamKeepalive = 30 * time.Second
+
+// @Summary     Streams host and peer updates to a host when running without a message queue
+// @Router      /api/v1/host/{hostid}/stream [get]
+// @Tags        Hosts
+// @Security    oauth
+// @Produce     text/event-stream
+// @Param       hostid path string true "Host ID"
+// @Param       version query integer false "Version of the last peer update applied by the host"
+// @Success     200 {string} string "server-sent events named by the message queue topic, carrying the encrypted message base64 encoded"
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func streamHostUpdates(w http.ResponseWriter, r *http.Request) {
+	if servercfg.IsMessageQueueBackend() {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("updates are published through the message queue"), logic.BadReq))
+		return
+	}
+	hostID, err := uuid.Parse(r.Header.Get(hostIDHeader))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("failed to parse host id: %w", err), logic.BadReq))
+		return
+	}
+	var appliedVersion uint64
+	if v := r.URL.Query().Get("version"); v != "" {
+		appliedVersion, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("invalid version"), logic.BadReq))
+			return
+		}
+	}
+	host := &schema.Host{ID: hostID}
+	if err := host.Get(r.Context()); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.BadReq))
+		return
+	}
+
+	// subscribe before resuming so that no update published in between is lost
+	messages, unsubscribe := mq.SubscribeHostStream(hostID.String())
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		slog.Error("host stream: streaming not supported", "host", hostID, "error", err)
+		return
+	}
+	if err := mq.ResumeHostStream(host, appliedVersion); err != nil {
+		slog.Error("host stream: failed to resume", "host", hostID, "error", err)
+	}
+
+	keepalive := time.NewTicker(hostStreamKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case msg, ok := <-messages:
+			if !ok {
+				// fell behind, the host reconnects and resumes from its version
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Topic, base64.StdEncoding.EncodeToString(msg.Payload)); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -67,6 +67,8 @@ func hostHandlers(r *mux.Router) {
 		Methods(http.MethodPut)
 	r.HandleFunc("/api/v1/host/{hostid}/peer_info", AuthorizeHost(http.HandlerFunc(getHostPeerInfo))).
 		Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/host/{hostid}/stream", AuthorizeHost(http.HandlerFunc(streamHostUpdates))).
+		Methods(http.MethodGet)
 	r.HandleFunc("/api/v1/pending_hosts", logic.SecurityCheck(true, http.HandlerFunc(getPendingHosts))).
 		Methods(http.MethodGet)
 	r.HandleFunc("/api/v1/pending_hosts/approve/{id}", logic.SecurityCheck(true, http.HandlerFunc(approvePendingHost))).
@@ -338,6 +340,7 @@ func pull(w http.ResponseWriter, r *http.Request) {
 			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 			return
 		}
+		logic.VersionHostPeerUpdate(hostID.String(), &hPU)
 		logic.StoreHostPeerUpdate(hostID.String(), hPU)
 	}
 
@@ -358,6 +361,7 @@ func pull(w http.ResponseWriter, r *http.Request) {
 		EndpointDetection:  logic.IsEndpointDetectionEnabled(),
 		DnsNameservers:     hPU.DnsNameservers,
 		ReplacePeers:       hPU.ReplacePeers,
+		Version:            hPU.Version,
 		AutoRelayNodes:     hPU.AutoRelayNodes,
 		GwNodes:            hPU.GwNodes,
 		AddressIdentityMap: hPU.AddressIdentityMap,
@@ -516,6 +520,11 @@ func hostUpdateFallback(w http.ResponseWriter, r *http.Request) {
 	switch hostUpdate.Action {
 	case models.CheckIn:
 		sendPeerUpdate = mq.HandleHostCheckin(&hostUpdate.Host, currentHost)
+		if !sendPeerUpdate {
+			if err := mq.PublishHostPeerUpdateIfBehind(currentHost, hostUpdate.PeerUpdateVersion); err != nil {
+				slog.Error("failed to resend peer update", "id", currentHost.ID, "error", err)
+			}
+		}
 	case models.UpdateHost:
 		if hostUpdate.Host.PublicKey != currentHost.PublicKey {
 			//remove old peer entry
@@ -732,69 +741,24 @@ func bulkDeleteHosts(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("no host IDs provided"), logic.BadReq))
 		return
 	}
-	user := r.Header.Get("user")
-	logic.ReturnAcceptedResponse(w, r, fmt.Sprintf("bulk delete of %d host(s) accepted", len(req.IDs)))
+	job, err := logic.EnqueueJob(logic.BulkHostDeleteJob, r.Header.Get("user"), req)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.Internal))
+		return
+	}
+	logic.ReturnAcceptedResponseWithJson(w, r, job, fmt.Sprintf("bulk delete of %d host(s) accepted", len(req.IDs)))
+}
 
-	go func() {
-		deleted := 0
-		for _, idStr := range req.IDs {
-			hostID, err := uuid.Parse(idStr)
-			if err != nil {
-				slog.Debug("bulk host delete: invalid host id", "id", idStr)
-				continue
-			}
-			currHost := &schema.Host{ID: hostID}
-			if err = currHost.Get(db.WithContext(context.Background())); err != nil {
-				slog.Debug("bulk host delete: host not found", "id", idStr, "error", err)
-				continue
-			}
-			var hostNodes []models.Node
-			for _, nodeID := range currHost.Nodes {
-				node, err := logic.GetNodeByID(nodeID)
-				if err != nil {
-					slog.Debug("bulk host delete: failed to get node", "nodeid", nodeID, "error", err)
-					continue
-				}
-				hostNodes = append(hostNodes, node)
-			}
-			if err = logic.RemoveHost(currHost, true); err != nil {
-				slog.Debug("bulk host delete: failed to remove host", "id", idStr, "error", err)
-				continue
-			}
-			for _, node := range hostNodes {
-				go mq.PublishMqUpdatesForDeletedNode(node, false)
-			}
-			if servercfg.GetBrokerType() == servercfg.EmqxBrokerType {
-				if err := mq.GetEmqxHandler().DeleteEmqxUser(currHost.ID.String()); err != nil {
-					slog.Debug("bulk host delete: failed to remove EMQX credentials", "id", currHost.ID, "error", err)
-				}
-			}
-			if err = mq.HostUpdate(&models.HostUpdate{
-				Action: models.DeleteHost,
-				Host:   *currHost,
-			}); err != nil {
-				slog.Debug("bulk host delete: failed to send host update", "id", currHost.ID, "error", err)
-			}
-			(&schema.PendingHost{HostID: currHost.ID.String()}).DeleteAllPendingHosts(db.WithContext(context.TODO()))
-			logic.LogEvent(&models.Event{
-				Action: schema.Delete,
-				Source: models.Subject{
-					ID:   user,
-					Name: user,
-					Type: schema.UserSub,
-				},
-				TriggeredBy: user,
-				Target: models.Subject{
-					ID:   currHost.ID.String(),
-					Name: currHost.Name,
-					Type: schema.DeviceSub,
-				},
-				Origin: schema.Dashboard,
-				Diff:   models.Diff{Old: currHost, New: nil},
-			})
-			logger.Log(2, user, "removed host", currHost.Name)
-			deleted++
-		}
+// runBulkHostDeleteJob - deletes the hosts of a models.BulkDeleteRequest
+func runBulkHostDeleteJob(ctx context.Context, run *logic.JobRun) error {
+	var req models.BulkDeleteRequest
+	if err := run.Payload(&req); err != nil {
+		return err
+	}
+	user := run.Job.Initiator
+	run.SetTotal(len(req.IDs))
+	deleted := 0
+	defer func() {
 		if deleted > 0 {
 			if err := mq.PublishPeerUpdate(false); err != nil {
 				slog.Error("bulk host delete: failed to publish peer update", "error", err)
@@ -802,6 +766,69 @@ func bulkDeleteHosts(w http.ResponseWriter, r *http.Request) {
 		}
 		slog.Info("bulk host delete completed", "deleted", deleted, "total", len(req.IDs))
 	}()
+	for _, idStr := range req.IDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		run.Step()
+		hostID, err := uuid.Parse(idStr)
+		if err != nil {
+			slog.Debug("bulk host delete: invalid host id", "id", idStr)
+			continue
+		}
+		currHost := &schema.Host{ID: hostID}
+		if err = currHost.Get(db.WithContext(context.Background())); err != nil {
+			slog.Debug("bulk host delete: host not found", "id", idStr, "error", err)
+			continue
+		}
+		var hostNodes []models.Node
+		for _, nodeID := range currHost.Nodes {
+			node, err := logic.GetNodeByID(nodeID)
+			if err != nil {
+				slog.Debug("bulk host delete: failed to get node", "nodeid", nodeID, "error", err)
+				continue
+			}
+			hostNodes = append(hostNodes, node)
+		}
+		if err = logic.RemoveHost(currHost, true); err != nil {
+			slog.Debug("bulk host delete: failed to remove host", "id", idStr, "error", err)
+			continue
+		}
+		for _, node := range hostNodes {
+			go mq.PublishMqUpdatesForDeletedNode(node, false)
+		}
+		if servercfg.GetBrokerType() == servercfg.EmqxBrokerType {
+			if err := mq.GetEmqxHandler().DeleteEmqxUser(currHost.ID.String()); err != nil {
+				slog.Debug("bulk host delete: failed to remove EMQX credentials", "id", currHost.ID, "error", err)
+			}
+		}
+		if err = mq.HostUpdate(&models.HostUpdate{
+			Action: models.DeleteHost,
+			Host:   *currHost,
+		}); err != nil {
+			slog.Debug("bulk host delete: failed to send host update", "id", currHost.ID, "error", err)
+		}
+		(&schema.PendingHost{HostID: currHost.ID.String()}).DeleteAllPendingHosts(db.WithContext(context.TODO()))
+		logic.LogEvent(&models.Event{
+			Action: schema.Delete,
+			Source: models.Subject{
+				ID:   user,
+				Name: user,
+				Type: schema.UserSub,
+			},
+			TriggeredBy: user,
+			Target: models.Subject{
+				ID:   currHost.ID.String(),
+				Name: currHost.Name,
+				Type: schema.DeviceSub,
+			},
+			Origin: schema.Dashboard,
+			Diff:   models.Diff{Old: currHost, New: nil},
+		})
+		logger.Log(2, user, "removed host", currHost.Name)
+		deleted++
+	}
+	return nil
 }
 
 // @Summary     To Add Host To Network
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/schema"
+)
+
+func jobHandlers(r *mux.Router) {
+	r.HandleFunc("/api/v1/jobs", logic.SecurityCheck(true, http.HandlerFunc(listJobs))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/jobs/{job_id}", logic.SecurityCheck(true, http.HandlerFunc(getJob))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/jobs/{job_id}/cancel", logic.SecurityCheck(true, http.HandlerFunc(cancelJob))).Methods(http.MethodPost)
+}
+
+// RegisterJobHandlers - registers the handlers of the background jobs queued
+// by the endpoints of this package
+func RegisterJobHandlers() {
+	logic.RegisterJobHandler(logic.NetworkDeleteJob, runNetworkDeleteJob)
+	logic.RegisterJobHandler(logic.BulkHostDeleteJob, runBulkHostDeleteJob)
+	logic.RegisterJobHandler(logic.BulkExtClientStatusJob, runBulkExtClientStatusJob)
+}
+
+// @Summary     List background jobs
+// @Router      /api/v1/jobs [get]
+// @Tags        Jobs
+// @Security    oauth
+// @Produce     json
+// @Param       type query string false "Job type"
+// @Param       status query string false "queued, running, succeeded, failed or cancelled"
+// @Success     200 {array} schema.Job
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func listJobs(w http.ResponseWriter, r *http.Request) {
+	filter := schema.Job{
+		Type:   r.URL.Query().Get("type"),
+		Status: schema.JobStatus(r.URL.Query().Get("status")),
+	}
+	switch filter.Status {
+	case "", schema.JobQueued, schema.JobRunning, schema.JobSucceeded, schema.JobFailed, schema.JobCancelled:
+	default:
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("invalid status"), logic.BadReq))
+		return
+	}
+	jobs, err := filter.List(db.WithContext(r.Context()))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.Internal))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, jobs, "fetched jobs")
+}
+
+// @Summary     Get a background job
+// @Router      /api/v1/jobs/{job_id} [get]
+// @Tags        Jobs
+// @Security    oauth
+// @Produce     json
+// @Param       job_id path string true "Job ID"
+// @Success     200 {object} schema.Job
+// @Failure     400 {object} models.ErrorResponse
+func getJob(w http.ResponseWriter, r *http.Request) {
+	job := schema.Job{ID: mux.Vars(r)["job_id"]}
+	if err := job.Get(db.WithContext(r.Context())); err != nil || job.Type == "" {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("job not found"), logic.BadReq))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, job, "fetched job")
+}
+
+// @Summary     Cancel a background job
+// @Router      /api/v1/jobs/{job_id}/cancel [post]
+// @Tags        Jobs
+// @Security    oauth
+// @Produce     json
+// @Param       job_id path string true "Job ID"
+// @Success     200 {object} schema.Job
+// @Failure     400 {object} models.ErrorResponse
+func cancelJob(w http.ResponseWriter, r *http.Request) {
+	job := schema.Job{ID: mux.Vars(r)["job_id"]}
+	if err := job.Get(db.WithContext(r.Context())); err != nil || job.Type == "" {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("job not found"), logic.BadReq))
+		return
+	}
+	job, err := logic.CancelJob(job.ID)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.BadReq))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, job, "job cancellation requested")
+}
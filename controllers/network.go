@@ -1,12 +1,12 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
-	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/schema"
@@ -34,6 +34,10 @@ func networkHandlers(r *mux.Router) {
 	r.HandleFunc("/api/networks/{networkname}", logic.SecurityCheck(true, http.HandlerFunc(updateNetwork))).
 		Methods(http.MethodPut)
 	r.HandleFunc("/api/networks/{networkname}/egress_routes", logic.SecurityCheck(true, http.HandlerFunc(getNetworkEgressRoutes)))
+	r.HandleFunc("/api/v1/networks/{networkname}/export", logic.SecurityCheck(true, http.HandlerFunc(exportNetwork))).
+		Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/networks/{networkname}/apply", logic.SecurityCheck(true, http.HandlerFunc(applyNetwork))).
+		Methods(http.MethodPost)
 }
 
 // @Summary     Lists all networks
@@ -185,58 +189,21 @@ func getNetworkEgressRoutes(w http.ResponseWriter, r *http.Request) {
 // @Param       networkname path string true "Network name"
 // @Param       force query bool false "Force Delete"
 // @Produce     json
-// @Success     200 {object} models.SuccessResponse
+// @Success     202 {object} models.SuccessResponse "with the queued schema.Job"
 // @Failure     400 {object} models.ErrorResponse
 // @Failure     403 {object} models.ErrorResponse
 func deleteNetwork(w http.ResponseWriter, r *http.Request) {
 	// Set header
 	w.Header().Set("Content-Type", "application/json")
-	force := r.URL.Query().Get("force") == "true"
 	var params = mux.Vars(r)
 	network := params["networkname"]
-	doneCh := make(chan struct{}, 1)
-	networkNodes, err := logic.GetNetworkNodes(network)
+	job, err := logic.EnqueueJob(logic.NetworkDeleteJob, r.Header.Get("user"), networkDeletePayload{Network: network})
 	if err != nil {
 		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to get network nodes [%s]: %v", network, err))
-		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			fmt.Sprintf("failed to queue deletion of network [%s]: %v", network, err))
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.Internal))
 		return
 	}
-	err = logic.DeleteNetwork(network, force, doneCh)
-	if err != nil {
-		errtype := logic.BadReq
-		if strings.Contains(err.Error(), "Node check failed") {
-			errtype = logic.Forbidden
-		}
-		logger.Log(0, r.Header.Get("user"),
-			fmt.Sprintf("failed to delete network [%s]: %v", network, err))
-		logic.ReturnErrorResponse(w, r, logic.FormatError(err, errtype))
-		return
-	}
-	go logic.UnlinkNetworkAndTagsFromEnrollmentKeys(network, true)
-	go logic.DeleteNetworkRoles(network)
-	go logic.DeleteAllNetworkTags(schema.NetworkID(network))
-	go logic.DeleteNetworkPolicies(schema.NetworkID(network))
-	//delete network from allocated ip map
-	go logic.RemoveNetworkFromAllocatedIpMap(network)
-	go func() {
-		<-doneCh
-		mq.PublishPeerUpdate(true)
-		// send node update to clean up locally
-		for _, node := range networkNodes {
-			node := node
-			node.PendingDelete = true
-			node.Action = models.NODE_DELETE
-			if err := mq.NodeUpdate(&node); err != nil {
-				slog.Error("error publishing node update to node", "node", node.ID, "error", err)
-			}
-		}
-
-		_ = logic.DeleteNetworkNameservers(network)
-		if servercfg.IsDNSMode() {
-			logic.SetDNS()
-		}
-	}()
 	logic.LogEvent(&models.Event{
 		Action: schema.Delete,
 		Source: models.Subject{
@@ -256,9 +223,55 @@ func deleteNetwork(w http.ResponseWriter, r *http.Request) {
 			New: nil,
 		},
 	})
-	logger.Log(1, r.Header.Get("user"), "deleted network", network)
-	w.WriteHeader(http.StatusOK)
-	logic.ReturnSuccessResponse(w, r, "success")
+	logger.Log(1, r.Header.Get("user"), "queued deletion of network", network)
+	logic.ReturnAcceptedResponseWithJson(w, r, job, fmt.Sprintf("deletion of network %s accepted", network))
+}
+
+type networkDeletePayload struct {
+	Network string `json:"network"`
+}
+
+// runNetworkDeleteJob - deletes the network of a networkDeletePayload along
+// with its nodes, tags, policies and roles
+func runNetworkDeleteJob(ctx context.Context, run *logic.JobRun) error {
+	var req networkDeletePayload
+	if err := run.Payload(&req); err != nil {
+		return err
+	}
+	network := req.Network
+	networkNodes, err := logic.GetNetworkNodes(network)
+	if err != nil {
+		return err
+	}
+	run.SetTotal(len(networkNodes))
+	if err := logic.DeleteNetworkNodes(ctx, network, run.Step); err != nil {
+		return err
+	}
+	if err := logic.UnlinkNetworkAndTagsFromEnrollmentKeys(network, true); err != nil {
+		slog.Error("failed to unlink network from enrollment keys", "network", network, "error", err)
+	}
+	logic.DeleteNetworkRoles(network)
+	logic.DeleteAllNetworkTags(schema.NetworkID(network))
+	logic.DeleteNetworkPolicies(schema.NetworkID(network))
+	//delete network from allocated ip map
+	logic.RemoveNetworkFromAllocatedIpMap(network)
+	mq.PublishPeerUpdate(true)
+	// send node update to clean up locally
+	for _, node := range networkNodes {
+		node := node
+		node.PendingDelete = true
+		node.Action = models.NODE_DELETE
+		if err := mq.NodeUpdate(&node); err != nil {
+			slog.Error("error publishing node update to node", "node", node.ID, "error", err)
+		}
+	}
+
+	_ = logic.DeleteNetworkNameservers(network)
+	if servercfg.IsDNSMode() {
+		logic.SetDNS()
+	}
+	logger.Log(1, run.Job.Initiator, "deleted network", network)
+	return nil
 }
 
 // @Summary     Create a network
@@ -368,70 +381,7 @@ func createNetwork(w http.ResponseWriter, r *http.Request) {
 			logger.Log(0, r.Header.Get("user"), "failed to update network with virtual NAT settings:", err.Error())
 		}
 	}
-	go func() {
-		defaultHosts := logic.GetDefaultHosts()
-		for i := range defaultHosts {
-			currHost := &defaultHosts[i]
-			newNode, err := logic.UpdateHostNetwork(currHost, network.Name, true)
-			if err != nil {
-				logger.Log(
-					0,
-					r.Header.Get("user"),
-					"failed to add host to network:",
-					currHost.ID.String(),
-					network.Name,
-					err.Error(),
-				)
-				logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
-				return
-			}
-			logger.Log(1, "added new node", newNode.ID.String(), "to host", currHost.Name)
-			if len(currHost.Nodes) == 1 {
-				if err = mq.HostUpdate(&models.HostUpdate{
-					Action: models.RequestPull,
-					Host:   *currHost,
-					Node:   *newNode,
-				}); err != nil {
-					logger.Log(
-						0,
-						r.Header.Get("user"),
-						"failed to add host to network:",
-						currHost.ID.String(),
-						network.Name,
-						err.Error(),
-					)
-				}
-			} else {
-				if err = mq.HostUpdate(&models.HostUpdate{
-					Action: models.JoinHostToNetwork,
-					Host:   *currHost,
-					Node:   *newNode,
-				}); err != nil {
-					logger.Log(
-						0,
-						r.Header.Get("user"),
-						"failed to add host to network:",
-						currHost.ID.String(),
-						network.Name,
-						err.Error(),
-					)
-				}
-			}
-
-			// make  host failover
-			logic.CreateFailOver(*newNode)
-			// make host remote access gateway
-			logic.CreateIngressGateway(network.Name, newNode.ID.String(), models.IngressRequest{})
-			logic.CreateRelay(models.RelayRequest{
-				NodeID: newNode.ID.String(),
-				NetID:  network.Name,
-			})
-		}
-		// send peer updates
-		if err = mq.PublishPeerUpdate(false); err != nil {
-			logger.Log(1, "failed to publish peer update for default hosts after network is added")
-		}
-	}()
+	go addDefaultHostsToNetwork(network.Name, r.Header.Get("user"))
 	logic.LogEvent(&models.Event{
 		Action: schema.Create,
 		Source: models.Subject{
@@ -453,6 +403,140 @@ func createNetwork(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(network)
 }
 
+// addDefaultHostsToNetwork - joins the default hosts to a new network
+func addDefaultHostsToNetwork(network, user string) {
+	defaultHosts := logic.GetDefaultHosts()
+	for i := range defaultHosts {
+		currHost := &defaultHosts[i]
+		newNode, err := logic.UpdateHostNetwork(currHost, network, true)
+		if err != nil {
+			logger.Log(
+				0,
+				user,
+				"failed to add host to network:",
+				currHost.ID.String(),
+				network,
+				err.Error(),
+			)
+			return
+		}
+		logger.Log(1, "added new node", newNode.ID.String(), "to host", currHost.Name)
+		if len(currHost.Nodes) == 1 {
+			if err = mq.HostUpdate(&models.HostUpdate{
+				Action: models.RequestPull,
+				Host:   *currHost,
+				Node:   *newNode,
+			}); err != nil {
+				logger.Log(
+					0,
+					user,
+					"failed to add host to network:",
+					currHost.ID.String(),
+					network,
+					err.Error(),
+				)
+			}
+		} else {
+			if err = mq.HostUpdate(&models.HostUpdate{
+				Action: models.JoinHostToNetwork,
+				Host:   *currHost,
+				Node:   *newNode,
+			}); err != nil {
+				logger.Log(
+					0,
+					user,
+					"failed to add host to network:",
+					currHost.ID.String(),
+					network,
+					err.Error(),
+				)
+			}
+		}
+
+		// make  host failover
+		logic.CreateFailOver(*newNode)
+		// make host remote access gateway
+		logic.CreateIngressGateway(network, newNode.ID.String(), models.IngressRequest{})
+		logic.CreateRelay(models.RelayRequest{
+			NodeID: newNode.ID.String(),
+			NetID:  network,
+		})
+	}
+	// send peer updates
+	if err := mq.PublishPeerUpdate(false); err != nil {
+		logger.Log(1, "failed to publish peer update for default hosts after network is added")
+	}
+}
+
+// @Summary     Export a network as a declarative document
+// @Router      /api/v1/networks/{networkname}/export [get]
+// @Tags        Networks
+// @Security    oauth
+// @Param       networkname path string true "Network name"
+// @Produce     json
+// @Success     200 {object} models.NetworkSpec
+// @Failure     400 {object} models.ErrorResponse
+func exportNetwork(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	spec, err := logic.ExportNetworkSpec(netID)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, spec, "exported network "+netID)
+}
+
+// @Summary     Apply a declarative network document
+// @Router      /api/v1/networks/{networkname}/apply [post]
+// @Tags        Networks
+// @Security    oauth
+// @Param       networkname path string true "Network name"
+// @Param       dry_run query bool false "only compute the plan"
+// @Param       body body models.NetworkSpec true "Network document"
+// @Produce     json
+// @Success     200 {object} models.NetworkSpecPlan
+// @Failure     400 {object} models.ErrorResponse
+func applyNetwork(w http.ResponseWriter, r *http.Request) {
+	netID := mux.Vars(r)["networkname"]
+	var spec models.NetworkSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if spec.Network.Name == "" {
+		spec.Network.Name = netID
+	}
+	if spec.Network.Name != netID {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("network name in the document does not match the request"), "badrequest"))
+		return
+	}
+	user := r.Header.Get("user")
+	if r.URL.Query().Get("dry_run") == "true" {
+		plan, err := logic.PlanNetworkSpec(spec, user)
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnSuccessResponseWithJson(w, r, plan, "planned network changes")
+		return
+	}
+	plan, err := logic.ApplyNetworkSpec(spec, user)
+	if err != nil {
+		logger.Log(0, user, "failed to apply network document for", netID, err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if len(plan.Changes) > 0 {
+		if plan.Changes[0].Resource == schema.NetworkSub && plan.Changes[0].Action == schema.Create {
+			go addDefaultHostsToNetwork(netID, user)
+		} else {
+			go mq.PublishPeerUpdate(false)
+		}
+	}
+	logger.Log(1, user, "applied", fmt.Sprint(len(plan.Changes)), "changes to network", netID)
+	logic.ReturnSuccessResponseWithJson(w, r, plan, "applied network changes")
+}
+
 // @Summary     Update network settings
 // @Router      /api/networks/{networkname} [put]
 // @Tags        Networks
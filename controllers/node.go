@@ -435,7 +435,7 @@ func createEgressGateway(w http.ResponseWriter, r *http.Request) {
 		if err := mq.NodeUpdate(&node); err != nil {
 			slog.Error("error publishing node update to node", "node", node.ID, "error", err)
 		}
-		mq.PublishPeerUpdate(false)
+		mq.PublishPeerUpdate(false, models.PeerUpdateChange{Nodes: []string{node.ID.String()}})
 	}()
 }
 
@@ -483,7 +483,7 @@ func deleteEgressGateway(w http.ResponseWriter, r *http.Request) {
 		if err := mq.NodeUpdate(&node); err != nil {
 			slog.Error("error publishing node update to node", "node", node.ID, "error", err)
 		}
-		mq.PublishPeerUpdate(false)
+		mq.PublishPeerUpdate(false, models.PeerUpdateChange{Nodes: []string{node.ID.String()}})
 	}()
 }
 
@@ -714,7 +714,7 @@ func updateNode(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 		}
-		mq.PublishPeerUpdate(false)
+		mq.PublishPeerUpdate(false, models.PeerUpdateChange{Nodes: []string{newNode.ID.String()}})
 	}(relayUpdate, newNode)
 }
 
@@ -908,7 +908,7 @@ func bulkUpdateNodeStatus(w http.ResponseWriter, r *http.Request) {
 			updated++
 		}
 		if updated > 0 {
-			mq.PublishPeerUpdate(false)
+			mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(network)}})
 		}
 		slog.Info("bulk node status completed", "action", eventAction, "updated", updated, "total", len(req.IDs))
 	}()
@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+	sm "github.com/gravitl/netmaker/servermetrics"
+	"golang.org/x/exp/slog"
+)
+
+// hostStatusRank - a host is reported with the best status of its nodes
+var hostStatusRank = map[models.NodeStatus]int{
+	models.OnlineSt:     5,
+	models.WarningSt:    4,
+	models.ErrorSt:      3,
+	models.OfflineSt:    2,
+	models.Disconnected: 1,
+	models.UnKnown:      0,
+}
+
+func prometheusHandlers(r *mux.Router) {
+	r.HandleFunc("/metrics", scrapeTokenCheck(http.HandlerFunc(getPrometheusMetrics))).Methods(http.MethodGet)
+}
+
+// scrapeTokenCheck - authenticates scrapes with the configured scrape token,
+// the endpoint is not served when no token is configured
+func scrapeTokenCheck(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := servercfg.GetMetricsScrapeToken()
+		if token == "" {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("metrics scrape token is not configured"), "notfound"))
+			return
+		}
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("invalid scrape token"), "unauthorized"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// @Summary     Prometheus metrics of the server and of the peer connections
+// @Router      /metrics [get]
+// @Tags        Server
+// @Produce     plain
+// @Success     200 {string} string
+// @Failure     401 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func getPrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	nodes, err := logic.GetAllNodes()
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	hosts, err := (&schema.Host{}).ListAll(db.WithContext(context.TODO()))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	families := nodeStatusFamilies(nodes, hosts)
+	families = append(families, peerMetricFamilies(nodes)...)
+	var mqConnected float64
+	if mq.IsConnected() {
+		mqConnected = 1
+	}
+	families = append(families, metricFamily{
+		name:       "netmaker_mq_connected",
+		help:       "Whether the server is connected to the message broker.",
+		metricType: sm.GaugeType,
+		samples:    []sm.Sample{{Value: mqConnected}},
+	})
+	for _, f := range families {
+		if err := sm.WriteFamily(w, f.name, f.help, f.metricType, f.samples); err != nil {
+			slog.Error("failed to write metrics", "error", err)
+			return
+		}
+	}
+	if err := sm.WriteRegistered(w); err != nil {
+		slog.Error("failed to write metrics", "error", err)
+	}
+}
+
+type metricFamily struct {
+	name       string
+	help       string
+	metricType string
+	samples    []sm.Sample
+}
+
+// nodeStatusFamilies - counts the nodes per network and the hosts by status
+func nodeStatusFamilies(nodes []models.Node, hosts []schema.Host) []metricFamily {
+	type nodeKey struct {
+		network string
+		status  models.NodeStatus
+	}
+	defaultPolicies := make(map[string]bool)
+	nodeCounts := make(map[nodeKey]int)
+	hostStatus := make(map[string]models.NodeStatus)
+	for i := range nodes {
+		node := nodes[i]
+		enabled, ok := defaultPolicies[node.Network]
+		if !ok {
+			policy, _ := logic.GetDefaultPolicy(schema.NetworkID(node.Network), models.DevicePolicy)
+			enabled = policy.Enabled
+			defaultPolicies[node.Network] = enabled
+		}
+		logic.GetNodeStatus(&node, enabled)
+		nodeCounts[nodeKey{network: node.Network, status: node.Status}]++
+
+		hostID := node.HostID.String()
+		if status, ok := hostStatus[hostID]; !ok || hostStatusRank[node.Status] > hostStatusRank[status] {
+			hostStatus[hostID] = node.Status
+		}
+	}
+
+	nodeSamples := make([]sm.Sample, 0, len(nodeCounts))
+	for key, count := range nodeCounts {
+		nodeSamples = append(nodeSamples, sm.Sample{
+			Labels: []sm.Label{{Name: "network", Value: key.network}, {Name: "status", Value: string(key.status)}},
+			Value:  float64(count),
+		})
+	}
+	hostCounts := make(map[models.NodeStatus]int)
+	for _, host := range hosts {
+		status, ok := hostStatus[host.ID.String()]
+		if !ok {
+			status = models.UnKnown
+		}
+		hostCounts[status]++
+	}
+	hostSamples := make([]sm.Sample, 0, len(hostCounts))
+	for status, count := range hostCounts {
+		hostSamples = append(hostSamples, sm.Sample{
+			Labels: []sm.Label{{Name: "status", Value: string(status)}},
+			Value:  float64(count),
+		})
+	}
+	sortSamples(nodeSamples)
+	sortSamples(hostSamples)
+	return []metricFamily{
+		{
+			name:       "netmaker_nodes",
+			help:       "Number of nodes by network and status.",
+			metricType: sm.GaugeType,
+			samples:    nodeSamples,
+		},
+		{
+			name:       "netmaker_hosts",
+			help:       "Number of hosts by the best status of their nodes.",
+			metricType: sm.GaugeType,
+			samples:    hostSamples,
+		},
+	}
+}
+
+// peerMetricFamilies - connectivity of every node towards its peers as last
+// reported by the node
+func peerMetricFamilies(nodes []models.Node) []metricFamily {
+	latency := metricFamily{
+		name:       "netmaker_peer_latency_milliseconds",
+		help:       "Latency towards the peer.",
+		metricType: sm.GaugeType,
+	}
+	uptime := metricFamily{
+		name:       "netmaker_peer_uptime_percent",
+		help:       "Share of the time the peer was reachable.",
+		metricType: sm.GaugeType,
+	}
+	connected := metricFamily{
+		name:       "netmaker_peer_connected",
+		help:       "Whether the peer is connected.",
+		metricType: sm.GaugeType,
+	}
+	sent := metricFamily{
+		name:       "netmaker_peer_sent_bytes_total",
+		help:       "Bytes sent to the peer.",
+		metricType: sm.CounterType,
+	}
+	received := metricFamily{
+		name:       "netmaker_peer_received_bytes_total",
+		help:       "Bytes received from the peer.",
+		metricType: sm.CounterType,
+	}
+	for _, node := range nodes {
+		metrics, err := logic.GetMetrics(node.ID.String())
+		if err != nil || metrics == nil {
+			continue
+		}
+		for peerID, metric := range metrics.Connectivity {
+			labels := []sm.Label{
+				{Name: "network", Value: node.Network},
+				{Name: "node_id", Value: node.ID.String()},
+				{Name: "peer_id", Value: peerID},
+				{Name: "peer_name", Value: metric.NodeName},
+			}
+			var isConnected float64
+			if metric.Connected {
+				isConnected = 1
+			}
+			latency.samples = append(latency.samples, sm.Sample{Labels: labels, Value: float64(metric.Latency)})
+			uptime.samples = append(uptime.samples, sm.Sample{Labels: labels, Value: metric.PercentUp})
+			connected.samples = append(connected.samples, sm.Sample{Labels: labels, Value: isConnected})
+			sent.samples = append(sent.samples, sm.Sample{Labels: labels, Value: float64(metric.TotalSent)})
+			received.samples = append(received.samples, sm.Sample{Labels: labels, Value: float64(metric.TotalReceived)})
+		}
+	}
+	return []metricFamily{latency, uptime, connected, sent, received}
+}
+
+// sortSamples - keeps the output stable across scrapes
+func sortSamples(samples []sm.Sample) {
+	key := func(s sm.Sample) string {
+		var b strings.Builder
+		for _, l := range s.Labels {
+			b.WriteString(l.Value)
+			b.WriteByte(0)
+		}
+		return b.String()
+	}
+	slices.SortFunc(samples, func(a, b sm.Sample) int {
+		return strings.Compare(key(a), key(b))
+	})
+}
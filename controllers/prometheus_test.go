@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrapeTokenCheck(t *testing.T) {
+	handler := scrapeTokenCheck(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	scrape := func(authorization string) int {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w.Code
+	}
+
+	t.Setenv("METRICS_SCRAPE_TOKEN", "")
+	assert.Equal(t, http.StatusNotFound, scrape("Bearer secret"))
+
+	t.Setenv("METRICS_SCRAPE_TOKEN", "secret")
+	assert.Equal(t, http.StatusUnauthorized, scrape(""))
+	assert.Equal(t, http.StatusUnauthorized, scrape("Bearer wrong"))
+	assert.Equal(t, http.StatusUnauthorized, scrape("secret"))
+	assert.Equal(t, http.StatusOK, scrape("Bearer secret"))
+}
@@ -1,13 +1,14 @@
 package controller
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
-	"strings"
 	"syscall"
 	"time"
 
@@ -19,6 +20,7 @@ import (
 	"golang.org/x/exp/slog"
 
 	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/leader"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
@@ -28,6 +30,9 @@ import (
 
 var cpuProfileLog *os.File
 
+// maxBackupSize - limit on the size of a backup uploaded for a restore
+var maxBackupSize int64 = 256 << 20
+
 func serverHandlers(r *mux.Router) {
 	// r.HandleFunc("/api/server/addnetwork/{network}", securityCheckServer(true, http.HandlerFunc(addNetwork))).Methods(http.MethodPost)
 	r.HandleFunc(
@@ -57,9 +62,9 @@ func serverHandlers(r *mux.Router) {
 				_ = syscall.Kill(syscall.Getpid(), syscall.SIGINT)
 			})),
 	).Methods(http.MethodPost)
-	r.HandleFunc("/api/server/getconfig", allowUsers(http.HandlerFunc(getConfig))).
+	r.HandleFunc("/api/server/getconfig", logic.UserCheck(http.HandlerFunc(getConfig))).
 		Methods(http.MethodGet)
-	r.HandleFunc("/api/server/settings", allowUsers(http.HandlerFunc(getSettings))).
+	r.HandleFunc("/api/server/settings", logic.UserCheck(http.HandlerFunc(getSettings))).
 		Methods(http.MethodGet)
 	r.HandleFunc("/api/server/settings", logic.SecurityCheck(true, http.HandlerFunc(updateSettings))).
 		Methods(http.MethodPut)
@@ -73,6 +78,116 @@ func serverHandlers(r *mux.Router) {
 	r.HandleFunc("/api/server/mem_profile", logic.SecurityCheck(false, http.HandlerFunc(memProfile))).
 		Methods(http.MethodPost)
 	r.HandleFunc("/api/server/feature_flags", getFeatureFlags).Methods(http.MethodGet)
+	r.HandleFunc("/api/server/backup", logic.SecurityCheck(true, http.HandlerFunc(backupServer))).
+		Methods(http.MethodGet)
+	r.HandleFunc("/api/server/restore", logic.SecurityCheck(true, http.HandlerFunc(restoreServer))).
+		Methods(http.MethodPost)
+}
+
+// isSuperAdminCaller - checks if the request was made with the master key or by a super-admin
+func isSuperAdminCaller(r *http.Request) bool {
+	if r.Header.Get("ismaster") == "yes" {
+		return true
+	}
+	caller := &schema.User{
+		Username: r.Header.Get("user"),
+	}
+	err := caller.Get(r.Context())
+	return err == nil && caller.PlatformRoleID == schema.SuperAdminRole
+}
+
+// @Summary     Download a backup of the server state
+// @Router      /api/server/backup [get]
+// @Tags        Server
+// @Security    oauth
+// @Produce     application/octet-stream
+// @Param       X-Backup-Passphrase header string false "Passphrase to encrypt the backup with"
+// @Success     200 {file} file
+// @Failure     403 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func backupServer(w http.ResponseWriter, r *http.Request) {
+	if !isSuperAdminCaller(r) {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("only a super-admin can back up the server"), "forbidden"))
+		return
+	}
+	var archive bytes.Buffer
+	summary, err := logic.CreateBackup(&archive, r.Header.Get("X-Backup-Passphrase"))
+	if err != nil {
+		logger.Log(0, r.Header.Get("user"), "failed to create backup:", err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.LogEvent(&models.Event{
+		Action: schema.Backup,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: schema.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   servercfg.GetServer(),
+			Name: servercfg.GetServer(),
+			Type: schema.ServerSub,
+		},
+		Origin: schema.Dashboard,
+	})
+	filename := fmt.Sprintf("netmaker-backup-%s.nmbk", summary.CreatedAt.Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(archive.Bytes())
+}
+
+// @Summary     Restore the server state from a backup
+// @Router      /api/server/restore [post]
+// @Tags        Server
+// @Security    oauth
+// @Accept      application/octet-stream
+// @Produce     json
+// @Param       X-Backup-Passphrase header string false "Passphrase the backup was encrypted with"
+// @Success     200 {object} models.BackupSummary
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     403 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func restoreServer(w http.ResponseWriter, r *http.Request) {
+	if !isSuperAdminCaller(r) {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("only a super-admin can restore the server"), "forbidden"))
+		return
+	}
+	archive, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBackupSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			err = fmt.Errorf("backup is larger than %d bytes", maxBytesErr.Limit)
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	summary, err := logic.RestoreBackup(archive, r.Header.Get("X-Backup-Passphrase"))
+	if err != nil {
+		logger.Log(0, r.Header.Get("user"), "failed to restore backup:", err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	logic.LogEvent(&models.Event{
+		Action: schema.Restore,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: schema.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   servercfg.GetServer(),
+			Name: servercfg.GetServer(),
+			Type: schema.ServerSub,
+		},
+		Origin: schema.Dashboard,
+	})
+	go mq.PublishPeerUpdate(false)
+	logic.ReturnSuccessResponseWithJson(w, r, summary,
+		"restored backup successfully, restart the server to reload all components")
 }
 
 func cpuProfile(w http.ResponseWriter, r *http.Request) {
@@ -116,6 +231,9 @@ func getStatus(w http.ResponseWriter, r *http.Request) {
 		TrialEndDate     time.Time `json:"trial_end_date"`
 		IsOnTrialLicense bool      `json:"is_on_trial_license"`
 		Version          string    `json:"version"`
+		IsLeader         bool      `json:"is_leader"`
+		Leader           string    `json:"leader"`
+		LeaseExpiry      time.Time `json:"leader_lease_expiry"`
 	}
 
 	licenseErr := ""
@@ -134,6 +252,7 @@ func getStatus(w http.ResponseWriter, r *http.Request) {
 	// 		isOnTrial = true
 	// 	}
 	// }
+	leaderStatus := leader.GetStatus()
 	currentServerStatus := status{
 		DB:               database.IsConnected(),
 		Broker:           mq.IsConnected(),
@@ -142,6 +261,9 @@ func getStatus(w http.ResponseWriter, r *http.Request) {
 		IsPro:            servercfg.IsPro,
 		DeploymentMode:   logic.GetDeploymentMode(),
 		Version:          servercfg.Version,
+		IsLeader:         leaderStatus.IsLeader,
+		Leader:           leaderStatus.Leader,
+		LeaseExpiry:      leaderStatus.LeaseExpiry,
 		//TrialEndDate:     trialEndDate,
 		//IsOnTrialLicense: isOnTrial,
 	}
@@ -150,30 +272,6 @@ func getStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(&currentServerStatus)
 }
 
-// allowUsers - allow all authenticated (valid) users - only used by getConfig, may be able to remove during refactor
-func allowUsers(next http.Handler) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		errorResponse := models.ErrorResponse{
-			Code: http.StatusUnauthorized, Message: logic.Unauthorized_Msg,
-		}
-		bearerToken := r.Header.Get("Authorization")
-		tokenSplit := strings.Split(bearerToken, " ")
-		authToken := ""
-		if len(tokenSplit) < 2 {
-			logic.ReturnErrorResponse(w, r, errorResponse)
-			return
-		} else {
-			authToken = tokenSplit[1]
-		}
-		user, _, _, err := logic.VerifyUserToken(authToken)
-		if err != nil || user == "" {
-			logic.ReturnErrorResponse(w, r, errorResponse)
-			return
-		}
-		next.ServeHTTP(w, r)
-	}
-}
-
 // @Summary     Get the server information
 // @Router      /api/server/getserverinfo [get]
 // @Tags        Server
@@ -225,6 +323,9 @@ func getSettings(w http.ResponseWriter, r *http.Request) {
 	if scfg.ClientSecret != "" {
 		scfg.ClientSecret = logic.Mask()
 	}
+	if scfg.LDAPBindPassword != "" {
+		scfg.LDAPBindPassword = logic.Mask()
+	}
 
 	logic.ReturnSuccessResponseWithJson(w, r, scfg, "fetched server settings successfully")
 }
@@ -279,8 +380,10 @@ func updateSettings(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			logic.StartFlowCleanupLoop()
+			logic.StartFlowIngestServer()
 		} else {
 			logic.StopFlowCleanupLoop()
+			logic.StopFlowIngestServer()
 			ch.Close()
 		}
 	}
@@ -289,6 +392,7 @@ func updateSettings(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if req.EnableFlowLogs {
 			logic.StopFlowCleanupLoop()
+			logic.StopFlowIngestServer()
 			ch.Close()
 		}
 		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("failed to update server settings "+err.Error()), "internal"))
@@ -417,7 +521,8 @@ func identifySettingsUpdateAction(old, new models.ServerSettings) schema.Action
 	if old.Verbosity != new.Verbosity ||
 		old.MetricsPort != new.MetricsPort ||
 		old.MetricInterval != new.MetricInterval ||
-		old.AuditLogsRetentionPeriodInDays != new.AuditLogsRetentionPeriodInDays {
+		old.AuditLogsRetentionPeriodInDays != new.AuditLogsRetentionPeriodInDays ||
+		old.MetricsRetentionPeriodInDays != new.MetricsRetentionPeriodInDays {
 		return schema.UpdateMonitoringAndDebuggingSettings
 	}
 
@@ -438,6 +543,12 @@ func identifySettingsUpdateAction(old, new models.ServerSettings) schema.Action
 		old.GoogleAdminEmail != new.GoogleAdminEmail ||
 		old.GoogleSACredsJson != new.GoogleSACredsJson ||
 		old.AzureTenant != new.AzureTenant ||
+		old.LDAPURL != new.LDAPURL ||
+		old.LDAPBindDN != new.LDAPBindDN ||
+		old.LDAPBindPassword != new.LDAPBindPassword ||
+		old.LDAPBaseDN != new.LDAPBaseDN ||
+		old.SAMLIDPMetadataURL != new.SAMLIDPMetadataURL ||
+		old.SAMLIDPMetadata != new.SAMLIDPMetadata ||
 		!cmp.Equal(old.GroupFilters, new.GroupFilters) ||
 		cmp.Equal(old.UserFilters, new.UserFilters) {
 		return schema.UpdateIDPSettings
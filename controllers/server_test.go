@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreServerSizeLimit(t *testing.T) {
+	limit := maxBackupSize
+	maxBackupSize = 16
+	defer func() { maxBackupSize = limit }()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/server/restore", bytes.NewReader(make([]byte, 17)))
+	r.Header.Set("ismaster", "yes")
+	w := httptest.NewRecorder()
+	restoreServer(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var response models.ErrorResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, "backup is larger than 16 bytes", response.Message)
+}
@@ -48,6 +48,14 @@ func userHandlers(r *mux.Router) {
 	r.HandleFunc("/api/users/{username}/auth/init-totp", logic.SecurityCheck(false, logic.ContinueIfUserMatch(http.HandlerFunc(initiateTOTPSetup)))).Methods(http.MethodPost)
 	r.HandleFunc("/api/users/{username}/auth/complete-totp", logic.SecurityCheck(false, logic.ContinueIfUserMatch(http.HandlerFunc(completeTOTPSetup)))).Methods(http.MethodPost)
 	r.HandleFunc("/api/users/{username}/auth/verify-totp", logic.PreAuthCheck(logic.ContinueIfUserMatch(http.HandlerFunc(verifyTOTP)))).Methods(http.MethodPost)
+	r.HandleFunc("/api/users/{username}/auth/begin-webauthn-registration", logic.SecurityCheck(false, logic.ContinueIfUserMatch(http.HandlerFunc(beginWebAuthnRegistration)))).Methods(http.MethodPost)
+	r.HandleFunc("/api/users/{username}/auth/complete-webauthn-registration", logic.SecurityCheck(false, logic.ContinueIfUserMatch(http.HandlerFunc(completeWebAuthnRegistration)))).Methods(http.MethodPost)
+	r.HandleFunc("/api/users/{username}/auth/begin-webauthn", logic.PreAuthCheck(logic.ContinueIfUserMatch(http.HandlerFunc(beginWebAuthnVerification)))).Methods(http.MethodPost)
+	r.HandleFunc("/api/users/{username}/auth/verify-webauthn", logic.PreAuthCheck(logic.ContinueIfUserMatch(http.HandlerFunc(verifyWebAuthn)))).Methods(http.MethodPost)
+	r.HandleFunc("/api/users/adm/webauthn/begin-login", beginWebAuthnLogin).Methods(http.MethodPost)
+	r.HandleFunc("/api/users/adm/webauthn/complete-login", completeWebAuthnLogin).Methods(http.MethodPost)
+	r.HandleFunc("/api/users/{username}/webauthn-credentials", logic.SecurityCheck(false, logic.ContinueIfUserMatchOrAdmin(http.HandlerFunc(listWebAuthnCredentials)))).Methods(http.MethodGet)
+	r.HandleFunc("/api/users/{username}/webauthn-credentials/{id}", logic.SecurityCheck(false, logic.ContinueIfUserMatchOrAdmin(http.HandlerFunc(deleteWebAuthnCredential)))).Methods(http.MethodDelete)
 	r.HandleFunc("/api/users/{username}", logic.SecurityCheck(true, http.HandlerFunc(updateUser))).Methods(http.MethodPut)
 	r.HandleFunc("/api/users/{username}", logic.SecurityCheck(true, http.HandlerFunc(createUser))).Methods(http.MethodPost)
 	r.HandleFunc("/api/users/{username}", logic.SecurityCheck(true, http.HandlerFunc(deleteUser))).Methods(http.MethodDelete)
@@ -100,6 +108,15 @@ func createUserAccessToken(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("username is required"), logic.BadReq))
 		return
 	}
+	if logic.IsAccessTokenRequest(r) {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("access tokens cannot be created with an access token"), logic.Forbidden_Msg))
+		return
+	}
+	err = logic.ValidateAccessTokenScopes(&req)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.BadReq))
+		return
+	}
 	caller := &schema.User{Username: r.Header.Get("user")}
 	err = caller.Get(r.Context())
 	if err != nil {
@@ -300,13 +317,20 @@ func authenticateUser(response http.ResponseWriter, request *http.Request) {
 	}
 	user := &schema.User{Username: authRequest.UserName}
 	err := user.Get(request.Context())
+	if err != nil && logic.IsLDAPLoginEnabled() {
+		// directory users are created on their first login
+		user, err = logic.VerifyLDAPCredentials(authRequest.UserName, authRequest.Password)
+	}
 	if err != nil {
 		logger.Log(0, authRequest.UserName, "user validation failed: ",
 			err.Error())
 		logic.ReturnErrorResponse(response, request, logic.FormatError(err, "unauthorized"))
 		return
 	}
-	if logic.IsOauthUser(user) == nil {
+	// the directory may match the username case insensitively
+	authRequest.UserName = user.Username
+	isLDAPUser := logic.IsLDAPLoginEnabled() && user.AuthType == schema.OAuth
+	if !isLDAPUser && logic.IsOauthUser(user) == nil {
 		logic.ReturnErrorResponse(response, request, logic.FormatError(errors.New("user is registered via SSO"), "badrequest"))
 		return
 	}
@@ -317,7 +341,7 @@ func authenticateUser(response http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	if user.PlatformRoleID != schema.SuperAdminRole && !logic.IsBasicAuthEnabled() {
+	if !isLDAPUser && user.PlatformRoleID != schema.SuperAdminRole && !logic.IsBasicAuthEnabled() {
 		logic.ReturnErrorResponse(
 			response,
 			request,
@@ -370,6 +394,7 @@ func authenticateUser(response http.ResponseWriter, request *http.Request) {
 			Response: models.PartialUserLoginResponse{
 				UserName:     username,
 				PreAuthToken: jwt,
+				MFAMethods:   logic.GetUserMFAMethods(user),
 			},
 		}
 	} else {
@@ -675,6 +700,13 @@ func verifyTOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if user.TOTPSecret == "" {
+		err = fmt.Errorf("totp is not set up for user(%s), cannot process totp verification", username)
+		logger.Log(0, err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
 	if totp.Validate(req.TOTP, user.TOTPSecret) {
 		jwt, err := logic.CreateUserJWT(user.Username, user.PlatformRoleID, appName)
 		if err != nil {
@@ -720,6 +752,476 @@ func verifyTOTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// @Summary     Initiate registering a WebAuthn credential for a user
+// @Router      /api/users/{username}/auth/begin-webauthn-registration [post]
+// @Tags        Auth
+// @Security    oauth
+// @Produce     json
+// @Param       username path string true "Username"
+// @Success     200 {object} models.WebAuthnBeginResponse
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func beginWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	username := r.Header.Get("user")
+	if logic.IsAccessTokenRequest(r) {
+		// a passkey would outlive the access token
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("passkeys cannot be registered with an access token"), logic.Forbidden_Msg))
+		return
+	}
+
+	user := &schema.User{Username: username}
+	err := user.Get(r.Context())
+	if err != nil {
+		logger.Log(0, "failed to get user: ", err.Error())
+		err = fmt.Errorf("user not found: %v", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	if user.AuthType == schema.OAuth {
+		err = fmt.Errorf("auth type is %s, cannot process webauthn setup", user.AuthType)
+		logger.Log(0, err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	resp, err := logic.BeginWebAuthnRegistration(user)
+	if err != nil {
+		err = fmt.Errorf("failed to initiate webauthn registration: %v", err)
+		logger.Log(0, err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+
+	logic.ReturnSuccessResponseWithJson(w, r, resp, "webauthn registration initiated")
+}
+
+// @Summary     Verify and complete registering a WebAuthn credential for a user
+// @Router      /api/users/{username}/auth/complete-webauthn-registration [post]
+// @Tags        Auth
+// @Security    oauth
+// @Accept      json
+// @Produce     json
+// @Param       username path string true "Username"
+// @Param       body body models.WebAuthnFinishRequest true "WebAuthn attestation"
+// @Success     200 {object} schema.UserWebAuthnCredential
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func completeWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	username := r.Header.Get("user")
+	if logic.IsAccessTokenRequest(r) {
+		// a passkey would outlive the access token
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("passkeys cannot be registered with an access token"), logic.Forbidden_Msg))
+		return
+	}
+
+	var req models.WebAuthnFinishRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(0, "failed to decode request body: ", err.Error())
+		err = fmt.Errorf("invalid request body: %v", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	user := &schema.User{Username: username}
+	err = user.Get(r.Context())
+	if err != nil {
+		logger.Log(0, "failed to get user: ", err.Error())
+		err = fmt.Errorf("user not found: %v", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	if user.AuthType == schema.OAuth {
+		err = fmt.Errorf("auth type is %s, cannot process webauthn setup", user.AuthType)
+		logger.Log(0, err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	mfaEnabled := user.IsMFAEnabled
+	cred, err := logic.FinishWebAuthnRegistration(user, req)
+	if err != nil {
+		err = fmt.Errorf("cannot register webauthn credential for user %s: %v", username, err)
+		logger.Log(0, err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	logic.LogEvent(&models.Event{
+		Action: schema.Create,
+		Source: models.Subject{
+			ID:   user.Username,
+			Name: user.Username,
+			Type: schema.UserSub,
+		},
+		TriggeredBy: user.Username,
+		Target: models.Subject{
+			ID:   cred.ID,
+			Name: cred.Name,
+			Type: schema.WebAuthnSub,
+			Info: cred,
+		},
+		Origin: schema.Dashboard,
+	})
+	if !mfaEnabled {
+		logic.LogEvent(&models.Event{
+			Action: schema.EnableMFA,
+			Source: models.Subject{
+				ID:   user.Username,
+				Name: user.Username,
+				Type: schema.UserSub,
+			},
+			TriggeredBy: user.Username,
+			Target: models.Subject{
+				ID:   user.Username,
+				Name: user.Username,
+				Type: schema.UserSub,
+			},
+			Origin: schema.Dashboard,
+		})
+	}
+
+	logic.ReturnSuccessResponseWithJson(w, r, cred, fmt.Sprintf("webauthn setup complete for user %s", username))
+}
+
+// @Summary     Initiate verifying a user's WebAuthn credential as second factor
+// @Router      /api/users/{username}/auth/begin-webauthn [post]
+// @Tags        Auth
+// @Produce     json
+// @Param       username path string true "Username"
+// @Success     200 {object} models.WebAuthnBeginResponse
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     401 {object} models.ErrorResponse
+func beginWebAuthnVerification(w http.ResponseWriter, r *http.Request) {
+	username := r.Header.Get("user")
+
+	user := &schema.User{Username: username}
+	err := user.Get(r.Context())
+	if err != nil {
+		logger.Log(0, "failed to get user: ", err.Error())
+		err = fmt.Errorf("user not found: %v", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	if !user.IsMFAEnabled {
+		err = fmt.Errorf("mfa is disabled for user(%s), cannot process webauthn verification", username)
+		logger.Log(0, err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	resp, err := logic.BeginWebAuthnLogin(user)
+	if err != nil {
+		err = fmt.Errorf("failed to initiate webauthn verification: %v", err)
+		logger.Log(0, err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	logic.ReturnSuccessResponseWithJson(w, r, resp, "webauthn verification initiated")
+}
+
+// @Summary     Verify a user's WebAuthn assertion
+// @Router      /api/users/{username}/auth/verify-webauthn [post]
+// @Tags        Auth
+// @Accept      json
+// @Produce     json
+// @Param       username path string true "Username"
+// @Param       body body models.WebAuthnFinishRequest true "WebAuthn assertion"
+// @Success     200 {object} models.SuccessfulUserLoginResponse
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     401 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func verifyWebAuthn(w http.ResponseWriter, r *http.Request) {
+	username := r.Header.Get("user")
+
+	var req models.WebAuthnFinishRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(0, "failed to decode request body: ", err.Error())
+		err = fmt.Errorf("invalid request body: %v", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	user := &schema.User{Username: username}
+	err = user.Get(r.Context())
+	if err != nil {
+		logger.Log(0, "failed to get user: ", err.Error())
+		err = fmt.Errorf("user not found: %v", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	if !user.IsMFAEnabled {
+		err = fmt.Errorf("mfa is disabled for user(%s), cannot process webauthn verification", username)
+		logger.Log(0, err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	err = logic.FinishWebAuthnLogin(user, req)
+	if err != nil {
+		logger.Log(0, username, "webauthn verification failed: ", err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("invalid webauthn assertion"), "unauthorized"))
+		return
+	}
+
+	appName := r.Header.Get("X-Application-Name")
+	if appName == "" {
+		appName = logic.NetmakerDesktopApp
+	}
+
+	completeWebAuthnSignIn(w, r, user, appName)
+}
+
+// @Summary     Initiate a passwordless sign in with a passkey
+// @Router      /api/users/adm/webauthn/begin-login [post]
+// @Tags        Auth
+// @Produce     json
+// @Success     200 {object} models.WebAuthnBeginResponse
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func beginWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	appName := r.Header.Get("X-Application-Name")
+	if appName == "" {
+		appName = logic.NetmakerDesktopApp
+	}
+
+	resp, err := logic.BeginPasswordlessWebAuthnLogin(appName)
+	if err != nil {
+		err = fmt.Errorf("failed to initiate webauthn login: %v", err)
+		logger.Log(0, err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	logic.ReturnSuccessResponseWithJson(w, r, resp, "webauthn login initiated")
+}
+
+// @Summary     Complete a passwordless sign in with a passkey
+// @Router      /api/users/adm/webauthn/complete-login [post]
+// @Tags        Auth
+// @Accept      json
+// @Produce     json
+// @Param       body body models.WebAuthnFinishRequest true "WebAuthn assertion"
+// @Success     200 {object} models.SuccessfulUserLoginResponse
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     401 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func completeWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	var req models.WebAuthnFinishRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		logger.Log(0, "failed to decode request body: ", err.Error())
+		err = fmt.Errorf("invalid request body: %v", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	user, appName, err := logic.FinishPasswordlessWebAuthnLogin(req)
+	if err != nil {
+		logger.Log(0, "webauthn login failed: ", err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("invalid webauthn assertion"), "unauthorized"))
+		return
+	}
+
+	// the passkey stands in for the password, the same checks as for
+	// a basic auth sign in apply.
+	if user.AccountDisabled {
+		err = errors.New("user account disabled")
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "unauthorized"))
+		return
+	}
+
+	if user.PlatformRoleID != schema.SuperAdminRole && !logic.IsBasicAuthEnabled() {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("basic auth is disabled"), "badrequest"))
+		return
+	}
+
+	if val := r.Header.Get("From-Ui"); val == "true" {
+		role := &schema.UserRole{ID: user.PlatformRoleID}
+		err := role.Get(r.Context())
+		if err != nil || role.DenyDashboardAccess {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("access denied to dashboard"), "unauthorized"))
+			return
+		}
+	}
+
+	completeWebAuthnSignIn(w, r, user, appName)
+}
+
+// completeWebAuthnSignIn - issues the auth token of a user that verified a
+// webauthn credential.
+func completeWebAuthnSignIn(w http.ResponseWriter, r *http.Request, user *schema.User, appName string) {
+	jwt, err := logic.CreateUserJWT(user.Username, user.PlatformRoleID, appName)
+	if err != nil {
+		err = fmt.Errorf("error creating token: %v", err)
+		logger.Log(0, err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+
+	// update last login time
+	user.LastLoginAt = time.Now().UTC()
+	err = logic.UpsertUser(*user)
+	if err != nil {
+		err = fmt.Errorf("error upserting user: %v", err)
+		logger.Log(0, err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+
+	logic.LogEvent(&models.Event{
+		Action: schema.Login,
+		Source: models.Subject{
+			ID:   user.Username,
+			Name: user.Username,
+			Type: schema.UserSub,
+		},
+		TriggeredBy: user.Username,
+		Target: models.Subject{
+			ID:   schema.DashboardSub.String(),
+			Name: schema.DashboardSub.String(),
+			Type: schema.DashboardSub,
+		},
+		Origin: schema.Dashboard,
+	})
+
+	logic.ReturnSuccessResponseWithJson(w, r, models.SuccessfulUserLoginResponse{
+		UserName:  user.Username,
+		AuthToken: jwt,
+	}, "W1R3: User "+user.Username+" Authorized")
+}
+
+// @Summary     List a user's WebAuthn credentials
+// @Router      /api/users/{username}/webauthn-credentials [get]
+// @Tags        Users
+// @Security    oauth
+// @Produce     json
+// @Param       username path string true "Username"
+// @Success     200 {array} schema.UserWebAuthnCredential
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     403 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func listWebAuthnCredentials(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+
+	user := &schema.User{Username: username}
+	err := user.Get(r.Context())
+	if err != nil {
+		err = fmt.Errorf("user not found: %v", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	creds, err := logic.ListWebAuthnCredentials(username)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+
+	logic.ReturnSuccessResponseWithJson(w, r, creds, "fetched webauthn credentials")
+}
+
+// @Summary     Revoke a user's WebAuthn credential
+// @Router      /api/users/{username}/webauthn-credentials/{id} [delete]
+// @Tags        Users
+// @Security    oauth
+// @Produce     json
+// @Param       username path string true "Username"
+// @Param       id path string true "Credential ID"
+// @Success     200 {object} models.SuccessResponse
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     403 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func deleteWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	username := params["username"]
+	id := params["id"]
+
+	user := &schema.User{Username: username}
+	err := user.Get(r.Context())
+	if err != nil {
+		err = fmt.Errorf("user not found: %v", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	callerName := r.Header.Get("user")
+	if callerName != logic.MasterUser && callerName != username {
+		caller := &schema.User{Username: callerName}
+		err = caller.Get(r.Context())
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+		if caller.PlatformRoleID == schema.AdminRole &&
+			(user.PlatformRoleID == schema.SuperAdminRole || user.PlatformRoleID == schema.AdminRole) {
+			err = errors.New("an admin user does not have permissions to revoke credentials of another admin user")
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+			return
+		}
+	}
+
+	creds, err := logic.ListWebAuthnCredentials(username)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+
+	var cred *schema.UserWebAuthnCredential
+	for i := range creds {
+		if creds[i].ID == id {
+			cred = &creds[i]
+			break
+		}
+	}
+	if cred == nil {
+		err = fmt.Errorf("webauthn credential %s not found", id)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+
+	if callerName == username && logic.IsMFAEnforced() && user.TOTPSecret == "" && len(creds) == 1 {
+		err = errors.New("mfa is enforced, user cannot remove their last second factor")
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+		return
+	}
+
+	err = logic.DeleteWebAuthnCredential(user, id)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+
+	logic.LogEvent(&models.Event{
+		Action: schema.Delete,
+		Source: models.Subject{
+			ID:   callerName,
+			Name: callerName,
+			Type: schema.UserSub,
+		},
+		TriggeredBy: callerName,
+		Target: models.Subject{
+			ID:   cred.ID,
+			Name: cred.Name,
+			Type: schema.WebAuthnSub,
+			Info: cred,
+		},
+		Origin: schema.Dashboard,
+		Diff: models.Diff{
+			Old: cred,
+			New: nil,
+		},
+	})
+
+	logic.ReturnSuccessResponse(w, r, "revoked webauthn credential")
+}
+
 // @Summary     Check if the server has a super admin
 // @Router      /api/users/adm/hassuperadmin [get]
 // @Tags        Users
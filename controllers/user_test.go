@@ -1,5 +1,17 @@
 package controller
 
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/db"
+	"github.com/stretchr/testify/assert"
+)
+
 // TODO: Need Update Tests for New User Mgmt
 // func deleteAllUsers(t *testing.T) {
 // 	t.Helper()
@@ -359,3 +371,25 @@ package controller
 // 		assert.NotNil(t, jwt)
 // 	})
 // }
+
+func TestWebAuthnRegistrationRejectsAccessTokens(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{
+		"begin":    beginWebAuthnRegistration,
+		"complete": completeWebAuthnRegistration,
+	} {
+		t.Run(name, func(t *testing.T) {
+			register := func(accessTokenID string) int {
+				req := httptest.NewRequest(http.MethodPost, "/api/users/admin/auth/"+name+"-webauthn-registration", strings.NewReader("{}")).
+					WithContext(db.WithContext(context.TODO()))
+				req.Header.Set("user", "admin")
+				// set by logic.SecurityCheck for requests made with an access token
+				req.Header.Set("ACCESS_TOKEN_ID", accessTokenID)
+				w := httptest.NewRecorder()
+				handler(w, req)
+				return w.Code
+			}
+			assert.Equal(t, http.StatusForbidden, register(uuid.NewString()))
+			assert.NotEqual(t, http.StatusForbidden, register(""))
+		})
+	}
+}
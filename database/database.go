@@ -1,11 +1,14 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"time"
 
+	"github.com/gravitl/netmaker/db"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/servercfg"
+	"gorm.io/gorm/clause"
 )
 
 const (
@@ -194,6 +197,25 @@ func DeleteAllRecords(tableName string) error {
 	return CreateTable(tableName)
 }
 
+// InsertTx - inserts object into db using the connection of ctx, so that it
+// is part of the transaction begun with db.BeginTx, if any. The key-value
+// tables share the connection of the sql tables, which rqlite doesn't.
+func InsertTx(ctx context.Context, key string, value string, tableName string) error {
+	if key == "" || value == "" {
+		return errors.New("invalid insert " + key + " : " + value)
+	}
+	return db.FromContext(ctx).Table(tableName).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value"}),
+	}).Create(map[string]interface{}{"key": key, "value": value}).Error
+}
+
+// DeleteAllRecordsTx - removes all records of a table using the connection
+// of ctx, see InsertTx
+func DeleteAllRecordsTx(ctx context.Context, tableName string) error {
+	return db.FromContext(ctx).Exec("DELETE FROM " + tableName).Error
+}
+
 // FetchRecord - fetches a single record by key
 func FetchRecord(tableName string, key string) (string, error) {
 	return getCurrentDB()[FETCH_ONE].(func(string, string) (string, error))(tableName, key)
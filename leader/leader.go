@@ -0,0 +1,183 @@
+// Package leader elects the server replica that runs singleton operations,
+// e.g. migrations, periodic cleanup hooks, IDP sync and processing of
+// incoming client messages.
+//
+// Replicas compete for a lease stored in the database. The leader renews the
+// lease periodically; if it stops doing so, another replica takes over once
+// the lease has expired.
+package leader
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+const (
+	// LeaseName - name of the lease held by the leader
+	LeaseName = "server-leader"
+	// LeaseTTL - time after which a lease that was not renewed can be taken over
+	LeaseTTL = 15 * time.Second
+	// RenewInterval - how often replicas try to acquire or renew the lease
+	RenewInterval = 5 * time.Second
+)
+
+// Status - leadership as seen by this replica
+type Status struct {
+	// Elected - false if leadership is fixed by the deployment
+	// (single replica or IS_MASTER_POD override)
+	Elected     bool      `json:"elected"`
+	IsLeader    bool      `json:"is_leader"`
+	Self        string    `json:"self"`
+	Leader      string    `json:"leader"`
+	Term        int64     `json:"term"`
+	LeaseExpiry time.Time `json:"lease_expiry"`
+}
+
+var (
+	mu        sync.RWMutex
+	status    Status
+	listeners []func(isLeader bool)
+	// notifyMu - serialises listener calls so they observe changes in order
+	notifyMu sync.Mutex
+)
+
+// Init - determines the leadership of this replica. Without HA the replica
+// always leads; IS_MASTER_POD fixes the role of the replica. Otherwise the
+// lease is acquired once, so singleton startup work (migrations) can check
+// IsLeader before Run is started.
+func Init() error {
+	self := servercfg.GetHostName()
+	if override := os.Getenv("IS_MASTER_POD"); override != "" {
+		setStatus(Status{IsLeader: override == "true", Self: self, Leader: leaderName(override == "true", self)})
+		return nil
+	}
+	if !servercfg.IsHA() {
+		setStatus(Status{IsLeader: true, Self: self, Leader: self})
+		return nil
+	}
+	if err := db.FromContext(db.WithContext(context.TODO())).AutoMigrate(&schema.Lease{}); err != nil {
+		return err
+	}
+	setStatus(Status{Elected: true, Self: self})
+	elect()
+	return nil
+}
+
+// Run - keeps acquiring or renewing the lease until ctx is cancelled, then
+// releases it so another replica can take over right away
+func Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if !GetStatus().Elected {
+		return
+	}
+	ticker := time.NewTicker(RenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			resign()
+			return
+		case <-ticker.C:
+			elect()
+		}
+	}
+}
+
+// IsLeader - returns true if this replica should run singleton operations
+func IsLeader() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return status.IsLeader
+}
+
+// GetStatus - returns the current leader and the expiry of its lease
+func GetStatus() Status {
+	mu.RLock()
+	defer mu.RUnlock()
+	return status
+}
+
+// OnChange - registers fn to be called whenever this replica gains or loses
+// leadership. Listeners are called one at a time in registration order and
+// should hand off long running work.
+func OnChange(fn func(isLeader bool)) {
+	mu.Lock()
+	defer mu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+func leaderName(isLeader bool, self string) string {
+	if isLeader {
+		return self
+	}
+	return ""
+}
+
+// elect - tries to acquire or renew the lease and updates the status
+func elect() {
+	prev := GetStatus()
+	now := time.Now()
+	lease := &schema.Lease{Name: LeaseName, Holder: prev.Self}
+	held, err := lease.Acquire(db.WithContext(context.TODO()), now, LeaseTTL)
+	next := prev
+	if err != nil {
+		slog.Error("leader election: failed to acquire lease", "error", err)
+		// keep leading while the lease is certainly still ours, step down
+		// before another replica may take over
+		if prev.IsLeader && now.Add(RenewInterval).After(prev.LeaseExpiry) {
+			next.IsLeader = false
+		}
+	} else {
+		next.IsLeader = held
+		next.Leader = lease.Holder
+		next.Term = lease.Term
+		next.LeaseExpiry = lease.ExpiresAt
+	}
+	setStatus(next)
+}
+
+// resign - gives up the lease on shutdown
+func resign() {
+	s := GetStatus()
+	if !s.IsLeader {
+		return
+	}
+	lease := &schema.Lease{Name: LeaseName, Holder: s.Self}
+	if err := lease.Release(db.WithContext(context.TODO())); err != nil {
+		slog.Error("leader election: failed to release lease", "error", err)
+	}
+	s.IsLeader = false
+	setStatus(s)
+}
+
+// setStatus - stores the status and notifies the listeners if the
+// leadership of this replica changed
+func setStatus(s Status) {
+	notifyMu.Lock()
+	defer notifyMu.Unlock()
+	mu.Lock()
+	changed := status.IsLeader != s.IsLeader
+	status = s
+	fns := append([]func(bool){}, listeners...)
+	mu.Unlock()
+	if !changed {
+		return
+	}
+	if s.Elected {
+		if s.IsLeader {
+			slog.Info("leader election: acquired leadership", "term", s.Term)
+		} else {
+			slog.Info("leader election: lost leadership", "leader", s.Leader)
+		}
+	}
+	for _, fn := range fns {
+		fn(s.IsLeader)
+	}
+}
@@ -0,0 +1,113 @@
+package leader
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	os.Exit(m.Run())
+}
+
+func setupLeaseTest(t *testing.T) context.Context {
+	t.Setenv("IS_HA", "true")
+	t.Setenv("IS_MASTER_POD", "")
+	ctx := db.WithContext(context.TODO())
+	require.NoError(t, db.FromContext(ctx).AutoMigrate(&schema.Lease{}))
+	require.NoError(t, db.FromContext(ctx).Where("name = ?", LeaseName).Delete(&schema.Lease{}).Error)
+	return ctx
+}
+
+func TestLeaseAcquire(t *testing.T) {
+	ctx := setupLeaseTest(t)
+	now := time.Now()
+
+	a := &schema.Lease{Name: LeaseName, Holder: "server-a"}
+	held, err := a.Acquire(ctx, now, LeaseTTL)
+	require.NoError(t, err)
+	assert.True(t, held)
+	assert.Equal(t, int64(1), a.Term)
+
+	// another replica can't take over a live lease
+	b := &schema.Lease{Name: LeaseName, Holder: "server-b"}
+	held, err = b.Acquire(ctx, now.Add(LeaseTTL/2), LeaseTTL)
+	require.NoError(t, err)
+	assert.False(t, held)
+	assert.Equal(t, "server-a", b.Holder)
+
+	// renewing keeps the term
+	a = &schema.Lease{Name: LeaseName, Holder: "server-a"}
+	held, err = a.Acquire(ctx, now.Add(LeaseTTL/2), LeaseTTL)
+	require.NoError(t, err)
+	assert.True(t, held)
+	assert.Equal(t, int64(1), a.Term)
+	assert.WithinDuration(t, now.Add(LeaseTTL/2+LeaseTTL), a.ExpiresAt, time.Millisecond)
+
+	// an expired lease is taken over with a new term
+	b = &schema.Lease{Name: LeaseName, Holder: "server-b"}
+	held, err = b.Acquire(ctx, now.Add(3*LeaseTTL), LeaseTTL)
+	require.NoError(t, err)
+	assert.True(t, held)
+	assert.Equal(t, int64(2), b.Term)
+
+	// a released lease can be taken over right away
+	require.NoError(t, b.Release(ctx))
+	a = &schema.Lease{Name: LeaseName, Holder: "server-a"}
+	held, err = a.Acquire(ctx, now.Add(3*LeaseTTL), LeaseTTL)
+	require.NoError(t, err)
+	assert.True(t, held)
+	assert.Equal(t, int64(3), a.Term)
+}
+
+func TestElection(t *testing.T) {
+	ctx := setupLeaseTest(t)
+	var changes []bool
+	OnChange(func(isLeader bool) { changes = append(changes, isLeader) })
+	t.Cleanup(func() { listeners = nil })
+
+	// another replica holds the lease
+	other := &schema.Lease{Name: LeaseName, Holder: "other-replica"}
+	_, err := other.Acquire(ctx, time.Now(), LeaseTTL)
+	require.NoError(t, err)
+
+	require.NoError(t, Init())
+	s := GetStatus()
+	assert.True(t, s.Elected)
+	assert.False(t, s.IsLeader)
+	assert.Equal(t, "other-replica", s.Leader)
+	assert.False(t, s.LeaseExpiry.IsZero())
+
+	// the leader goes away
+	require.NoError(t, other.Release(ctx))
+	elect()
+	s = GetStatus()
+	assert.True(t, s.IsLeader)
+	assert.Equal(t, s.Self, s.Leader)
+	assert.Equal(t, []bool{true}, changes)
+
+	resign()
+	assert.False(t, IsLeader())
+	assert.Equal(t, []bool{true, false}, changes)
+}
+
+func TestStaticLeadership(t *testing.T) {
+	t.Setenv("IS_HA", "false")
+	t.Setenv("IS_MASTER_POD", "")
+	require.NoError(t, Init())
+	assert.True(t, IsLeader())
+	assert.False(t, GetStatus().Elected)
+
+	t.Setenv("IS_HA", "true")
+	t.Setenv("IS_MASTER_POD", "false")
+	require.NoError(t, Init())
+	assert.False(t, IsLeader())
+}
@@ -0,0 +1,208 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+)
+
+// accessTokenIDHeader - set by SecurityCheck to the id of the api access
+// token a request is authenticated with, empty for session tokens.
+const accessTokenIDHeader = "ACCESS_TOKEN_ID"
+
+// IsAccessTokenRequest - checks if a request passed by SecurityCheck is
+// authenticated with an api access token
+func IsAccessTokenRequest(r *http.Request) bool {
+	return r.Header.Get(accessTokenIDHeader) != ""
+}
+
+// ValidateAccessTokenScopes - validates the access scopes and the source
+// allowlist of an access token
+func ValidateAccessTokenScopes(a *schema.UserAccessToken) error {
+	for _, cidr := range a.AllowedCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid allowed cidr %s", cidr)
+		}
+	}
+
+	if !a.Scoped {
+		if len(a.GlobalLevelAccess.Data()) > 0 || len(a.NetworkLevelAccess.Data()) > 0 {
+			return errors.New("access scopes are only applied to scoped tokens")
+		}
+		return nil
+	}
+
+	for rsrcType := range a.GlobalLevelAccess.Data() {
+		if _, ok := schema.RsrcTypeMap[rsrcType]; !ok {
+			return fmt.Errorf("invalid resource type %s", rsrcType)
+		}
+	}
+
+	for netID, access := range a.NetworkLevelAccess.Data() {
+		if netID != schema.AllNetworks {
+			err := (&schema.Network{Name: netID.String()}).Get(db.WithContext(context.TODO()))
+			if err != nil {
+				return fmt.Errorf("invalid network %s", netID)
+			}
+		}
+		for rsrcType := range access {
+			if _, ok := schema.RsrcTypeMap[rsrcType]; !ok {
+				return fmt.Errorf("invalid resource type %s", rsrcType)
+			}
+		}
+	}
+
+	return nil
+}
+
+// IsAccessTokenSourceAllowed - checks if an access token can be used from
+// the given address
+func IsAccessTokenSourceAllowed(a *schema.UserAccessToken, clientIP string) bool {
+	if len(a.AllowedCIDRs) == 0 {
+		return true
+	}
+
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+
+	for _, cidr := range a.AllowedCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckAccessTokenScopes - checks the request against the access scopes of
+// the token it is authenticated with. The permissions of the user of the
+// token are checked separately, a scoped token can only narrow them down.
+func CheckAccessTokenScopes(r *http.Request, isGlobalAccess bool) error {
+	tokenID := r.Header.Get(accessTokenIDHeader)
+	if tokenID == "" {
+		return nil
+	}
+
+	a := &schema.UserAccessToken{ID: tokenID}
+	err := a.Get(db.WithContext(context.TODO()))
+	if err != nil {
+		return errors.New("token revoked")
+	}
+	if !a.Scoped {
+		return nil
+	}
+
+	targetRsrc := schema.RsrcType(r.Header.Get("TARGET_RSRC"))
+	targetRsrcID := r.Header.Get("TARGET_RSRC_ID")
+	if targetRsrc == "" {
+		return errors.New("access denied, target rsrc is missing")
+	}
+
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	if isGlobalAccess {
+		err = checkAccessTokenRsrcScope(a.GlobalLevelAccess.Data(), targetRsrc,
+			schema.RsrcID(fmt.Sprintf("all_%s", targetRsrc)), targetRsrcID, method)
+		if err != nil {
+			return fmt.Errorf("access denied by token scope: %w", err)
+		}
+		return nil
+	}
+
+	netID := schema.NetworkID(r.Header.Get("NET_ID"))
+	for _, scopedNetID := range []schema.NetworkID{netID, schema.AllNetworks} {
+		access, ok := a.NetworkLevelAccess.Data()[scopedNetID]
+		if !ok {
+			continue
+		}
+		err = checkAccessTokenRsrcScope(access, targetRsrc, GetAllRsrcIDForRsrc(targetRsrc), targetRsrcID, method)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return errors.New("access denied by token scope")
+}
+
+func checkAccessTokenRsrcScope(access schema.ResourceAccess, targetRsrc schema.RsrcType, allRsrcID schema.RsrcID, targetRsrcID, method string) error {
+	rsrcScopes, ok := access[targetRsrc]
+	if !ok {
+		return fmt.Errorf("no access to %s", targetRsrc)
+	}
+
+	if scope, ok := rsrcScopes[allRsrcID]; ok && isMethodAllowedByScope(scope, method) {
+		return nil
+	}
+
+	if targetRsrcID != "" {
+		if scope, ok := rsrcScopes[schema.RsrcID(targetRsrcID)]; ok && isMethodAllowedByScope(scope, method) {
+			return nil
+		}
+	}
+
+	return errors.New("operation not permitted")
+}
+
+func isMethodAllowedByScope(scope schema.RsrcPermissionScope, method string) bool {
+	switch method {
+	case http.MethodGet:
+		return scope.Read
+	case http.MethodPatch, http.MethodPut:
+		return scope.Update
+	case http.MethodDelete:
+		return scope.Delete
+	case http.MethodPost:
+		return scope.Create
+	}
+	return false
+}
+
+// logAccessTokenUse - audits the requests made with an access token. Reads
+// are only logged when they are denied, so that polling clients don't
+// flood the audit log.
+func logAccessTokenUse(a *schema.UserAccessToken, r *http.Request, err error) {
+	if err == nil && r.Method == http.MethodGet {
+		return
+	}
+
+	action := schema.UseAccessToken
+	info := map[string]string{
+		"method":    r.Method,
+		"path":      r.URL.Path,
+		"client_ip": GetClientIP(r),
+	}
+	if err != nil {
+		action = schema.DenyAccessToken
+		info["error"] = err.Error()
+	}
+
+	LogEvent(&models.Event{
+		Action: action,
+		Source: models.Subject{
+			ID:   a.UserName,
+			Name: a.UserName,
+			Type: schema.UserSub,
+		},
+		TriggeredBy: a.UserName,
+		Target: models.Subject{
+			ID:   a.ID,
+			Name: a.Name,
+			Type: schema.UserAccessTokenSub,
+			Info: info,
+		},
+		NetworkID: schema.NetworkID(r.Header.Get("NET_ID")),
+		Origin:    schema.Api,
+	})
+}
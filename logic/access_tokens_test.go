@@ -0,0 +1,212 @@
+package logic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/matryer/is"
+	"gorm.io/datatypes"
+)
+
+func TestIsAccessTokenSourceAllowed(t *testing.T) {
+	is := is.New(t)
+	a := &schema.UserAccessToken{}
+	is.True(IsAccessTokenSourceAllowed(a, "203.0.113.7"))
+
+	a.AllowedCIDRs = []string{"10.0.0.0/8", "2001:db8::/32"}
+	is.True(IsAccessTokenSourceAllowed(a, "10.1.2.3"))
+	is.True(IsAccessTokenSourceAllowed(a, "2001:db8::1"))
+	is.True(!IsAccessTokenSourceAllowed(a, "203.0.113.7"))
+	is.True(!IsAccessTokenSourceAllowed(a, "not-an-ip"))
+}
+
+func TestValidateAccessTokenScopes(t *testing.T) {
+	is := is.New(t)
+	is.True(ValidateAccessTokenScopes(&schema.UserAccessToken{AllowedCIDRs: []string{"10.0.0.1"}}) != nil)
+	is.True(ValidateAccessTokenScopes(&schema.UserAccessToken{
+		GlobalLevelAccess: datatypes.NewJSONType(schema.ResourceAccess{schema.HostRsrc: {}}),
+	}) != nil)
+	is.True(ValidateAccessTokenScopes(&schema.UserAccessToken{
+		Scoped:            true,
+		GlobalLevelAccess: datatypes.NewJSONType(schema.ResourceAccess{"unknown": {}}),
+	}) != nil)
+	is.NoErr(ValidateAccessTokenScopes(&schema.UserAccessToken{
+		Scoped:            true,
+		AllowedCIDRs:      []string{"10.0.0.0/8"},
+		GlobalLevelAccess: datatypes.NewJSONType(schema.ResourceAccess{schema.HostRsrc: {}}),
+	}))
+}
+
+func TestCheckAccessTokenScopes(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+
+	database.InitializeDatabase()
+	defer database.CloseDB()
+
+	is := is.New(t)
+	ctx := db.WithContext(context.TODO())
+
+	unscoped := &schema.UserAccessToken{ID: uuid.NewString(), UserName: "ci"}
+	is.NoErr(unscoped.Create(ctx))
+	scoped := &schema.UserAccessToken{
+		ID:       uuid.NewString(),
+		UserName: "ci",
+		Scoped:   true,
+		NetworkLevelAccess: datatypes.NewJSONType(map[schema.NetworkID]schema.ResourceAccess{
+			"netx": {
+				schema.HostRsrc: {
+					schema.AllHostRsrcID: {Read: true},
+					"host-1":             {Read: true, Update: true},
+				},
+			},
+		}),
+		GlobalLevelAccess: datatypes.NewJSONType(schema.ResourceAccess{
+			schema.NetworkRsrc: {schema.AllNetworkRsrcID: {Read: true}},
+		}),
+	}
+	is.NoErr(scoped.Create(ctx))
+
+	request := func(tokenID, method, rsrc, rsrcID, netID string) *http.Request {
+		r := httptest.NewRequest(method, "/", nil)
+		r.Header.Set(accessTokenIDHeader, tokenID)
+		r.Header.Set("TARGET_RSRC", rsrc)
+		r.Header.Set("TARGET_RSRC_ID", rsrcID)
+		r.Header.Set("NET_ID", netID)
+		return r
+	}
+
+	t.Run("session token", func(t *testing.T) {
+		is.NoErr(CheckAccessTokenScopes(request("", http.MethodDelete, "host", "", "nety"), false))
+	})
+	t.Run("unscoped token", func(t *testing.T) {
+		is.NoErr(CheckAccessTokenScopes(request(unscoped.ID, http.MethodDelete, "host", "", "nety"), false))
+	})
+	t.Run("read hosts of network", func(t *testing.T) {
+		is.NoErr(CheckAccessTokenScopes(request(scoped.ID, http.MethodGet, "host", "", "netx"), false))
+	})
+	t.Run("update granted host", func(t *testing.T) {
+		is.NoErr(CheckAccessTokenScopes(request(scoped.ID, http.MethodPut, "host", "host-1", "netx"), false))
+	})
+	t.Run("update other host", func(t *testing.T) {
+		is.True(CheckAccessTokenScopes(request(scoped.ID, http.MethodPut, "host", "host-2", "netx"), false) != nil)
+	})
+	t.Run("other network", func(t *testing.T) {
+		is.True(CheckAccessTokenScopes(request(scoped.ID, http.MethodGet, "host", "", "nety"), false) != nil)
+	})
+	t.Run("other resource", func(t *testing.T) {
+		is.True(CheckAccessTokenScopes(request(scoped.ID, http.MethodGet, "dns", "", "netx"), false) != nil)
+	})
+	t.Run("global read", func(t *testing.T) {
+		is.NoErr(CheckAccessTokenScopes(request(scoped.ID, http.MethodGet, "network", "", ""), true))
+		is.True(CheckAccessTokenScopes(request(scoped.ID, http.MethodPost, "network", "", ""), true) != nil)
+	})
+	t.Run("revoked token", func(t *testing.T) {
+		is.NoErr(scoped.Delete(ctx))
+		is.True(CheckAccessTokenScopes(request(scoped.ID, http.MethodGet, "host", "", "netx"), false) != nil)
+	})
+}
+
+func TestUserCheck(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+
+	database.InitializeDatabase()
+	defer database.CloseDB()
+
+	secret := jwtSecretKey
+	defer func() { jwtSecretKey = secret }()
+	jwtSecretKey = []byte(RandomString(64))
+
+	is := is.New(t)
+	ctx := db.WithContext(context.TODO())
+
+	user := &schema.User{Username: "token-user", PlatformRoleID: schema.AdminRole}
+	is.NoErr(user.Create(ctx))
+	defer user.Delete(ctx)
+
+	accessJwt := func(a *schema.UserAccessToken) string {
+		a.ID = uuid.NewString()
+		a.UserName = user.Username
+		is.NoErr(a.Create(ctx))
+		jwt, err := CreateUserAccessJwtToken(user.Username, user.PlatformRoleID, time.Now().Add(time.Hour), a.ID)
+		is.NoErr(err)
+		return jwt
+	}
+	// a session token of CreateUserJWT, with a validity that does not
+	// depend on the server settings
+	sessionJwt, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &models.UserClaims{
+		UserName:  user.Username,
+		Role:      user.PlatformRoleID,
+		TokenType: models.UserIDTokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}).SignedString(jwtSecretKey)
+	is.NoErr(err)
+	openJwt := accessJwt(&schema.UserAccessToken{})
+	restrictedJwt := accessJwt(&schema.UserAccessToken{AllowedCIDRs: []string{"10.0.0.0/8"}})
+	scopedJwt := accessJwt(&schema.UserAccessToken{
+		Scoped:            true,
+		GlobalLevelAccess: datatypes.NewJSONType(schema.ResourceAccess{schema.HostRsrc: {schema.AllHostRsrcID: {Read: true}}}),
+	})
+
+	serve := func(check func(http.Handler) http.HandlerFunc, token, remoteAddr string, forwardedFor ...string) int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		for _, hop := range forwardedFor {
+			r.Header.Add("X-Forwarded-For", hop)
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+		// a client cannot pick the resource its token is checked against
+		r.Header.Set("TARGET_RSRC", schema.HostRsrc.String())
+		w := httptest.NewRecorder()
+		check(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("session token", func(t *testing.T) {
+		is.Equal(serve(UserCheck, sessionJwt, "203.0.113.7:1234"), http.StatusOK)
+	})
+	t.Run("invalid token", func(t *testing.T) {
+		is.Equal(serve(UserCheck, "invalid", "203.0.113.7:1234"), http.StatusUnauthorized)
+	})
+	t.Run("access token", func(t *testing.T) {
+		is.Equal(serve(UserCheck, openJwt, "203.0.113.7:1234"), http.StatusOK)
+	})
+	t.Run("access token from an allowed source", func(t *testing.T) {
+		is.Equal(serve(UserCheck, restrictedJwt, "10.1.2.3:1234"), http.StatusOK)
+	})
+	t.Run("access token from another source", func(t *testing.T) {
+		is.Equal(serve(UserCheck, restrictedJwt, "203.0.113.7:1234"), http.StatusUnauthorized)
+	})
+	t.Run("access token with a spoofed forwarded for header", func(t *testing.T) {
+		t.Setenv("TRUSTED_PROXIES", "")
+		is.Equal(serve(UserCheck, restrictedJwt, "203.0.113.7:1234", "10.1.2.3"), http.StatusUnauthorized)
+		securityCheck := func(next http.Handler) http.HandlerFunc { return SecurityCheck(false, next) }
+		is.Equal(serve(securityCheck, restrictedJwt, "203.0.113.7:1234", "10.1.2.3"), http.StatusUnauthorized)
+	})
+	t.Run("access token through a trusted proxy", func(t *testing.T) {
+		t.Setenv("TRUSTED_PROXIES", "192.0.2.10")
+		is.Equal(serve(UserCheck, restrictedJwt, "192.0.2.10:443", "10.1.2.3"), http.StatusOK)
+		is.Equal(serve(UserCheck, restrictedJwt, "192.0.2.10:443", "10.1.2.3", "203.0.113.7"), http.StatusUnauthorized)
+	})
+	t.Run("scoped access token", func(t *testing.T) {
+		is.Equal(serve(UserCheck, scopedJwt, "203.0.113.7:1234"), http.StatusForbidden)
+	})
+	t.Run("pre auth check", func(t *testing.T) {
+		is.Equal(serve(PreAuthCheck, sessionJwt, "203.0.113.7:1234"), http.StatusOK)
+		is.Equal(serve(PreAuthCheck, openJwt, "203.0.113.7:1234"), http.StatusUnauthorized)
+	})
+}
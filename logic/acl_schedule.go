@@ -0,0 +1,173 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// aclScheduleCheckInterval - how often scheduled policies are re-evaluated,
+// schedules have a granularity of one minute
+const aclScheduleCheckInterval = time.Minute
+
+var (
+	aclScheduleStateMutex = &sync.Mutex{}
+	// aclScheduleState - last evaluated state of the scheduled policies
+	aclScheduleState map[string]bool
+)
+
+// IsAclActive - checks if the policy is enabled and within its schedule
+func IsAclActive(acl models.Acl) bool {
+	return isAclActiveAt(acl, time.Now())
+}
+
+func isAclActiveAt(acl models.Acl, t time.Time) bool {
+	return acl.Enabled && IsAclScheduleActive(acl.Schedule, t)
+}
+
+// IsAclScheduleActive - checks if the schedule is active at the given time,
+// a policy without a schedule is always active
+func IsAclScheduleActive(s *models.AclSchedule, t time.Time) bool {
+	if s == nil {
+		return true
+	}
+	if s.From != nil && t.Before(*s.From) {
+		return false
+	}
+	if s.Until != nil && !t.Before(*s.Until) {
+		return false
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return false
+	}
+	t = t.In(loc)
+	if s.StartTime == "" && s.EndTime == "" {
+		return isAclScheduleDay(s, t.Weekday())
+	}
+	start, err := parseAclScheduleTime(s.StartTime)
+	if err != nil {
+		return false
+	}
+	end, err := parseAclScheduleTime(s.EndTime)
+	if err != nil {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	switch {
+	case start == end:
+		return isAclScheduleDay(s, t.Weekday())
+	case start < end:
+		return now >= start && now < end && isAclScheduleDay(s, t.Weekday())
+	default:
+		// overnight window, the part after midnight belongs to the previous day
+		if now >= start {
+			return isAclScheduleDay(s, t.Weekday())
+		}
+		return now < end && isAclScheduleDay(s, (t.Weekday()+6)%7)
+	}
+}
+
+// ValidateAclSchedule - validates the schedule of a policy
+func ValidateAclSchedule(s *models.AclSchedule) error {
+	if s == nil {
+		return nil
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("invalid schedule timezone %s", s.Timezone)
+	}
+	for _, day := range s.Weekdays {
+		if day < time.Sunday || day > time.Saturday {
+			return fmt.Errorf("invalid schedule weekday %d", day)
+		}
+	}
+	if (s.StartTime == "") != (s.EndTime == "") {
+		return errors.New("schedule requires both start and end time")
+	}
+	if s.StartTime != "" {
+		if _, err := parseAclScheduleTime(s.StartTime); err != nil {
+			return fmt.Errorf("invalid schedule start time %s", s.StartTime)
+		}
+		if _, err := parseAclScheduleTime(s.EndTime); err != nil {
+			return fmt.Errorf("invalid schedule end time %s", s.EndTime)
+		}
+	}
+	if s.From != nil && s.Until != nil && !s.Until.After(*s.From) {
+		return errors.New("schedule end must be after its start")
+	}
+	if len(s.Weekdays) == 0 && s.StartTime == "" && s.From == nil && s.Until == nil {
+		return errors.New("schedule is empty")
+	}
+	return nil
+}
+
+// CheckAclScheduleTransitions - re-evaluates the scheduled policies and reports
+// if any of them became active or inactive since the last check
+func CheckAclScheduleTransitions() bool {
+	now := time.Now()
+	state := make(map[string]bool)
+	for _, acl := range ListAcls() {
+		if acl.Schedule == nil {
+			continue
+		}
+		state[acl.ID] = isAclActiveAt(acl, now)
+	}
+	aclScheduleStateMutex.Lock()
+	defer aclScheduleStateMutex.Unlock()
+	prev := aclScheduleState
+	aclScheduleState = state
+	if prev == nil {
+		// first run, peers receive the current state on connect
+		return false
+	}
+	changed := false
+	for id, active := range state {
+		if prevActive, ok := prev[id]; ok && prevActive != active {
+			slog.Info("scheduled acl policy changed state", "acl", id, "active", active)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// InitAclScheduleHook - registers a hook that re-evaluates scheduled policies
+// and calls onChange when one of them crosses a window boundary
+func InitAclScheduleHook(onChange func() error) {
+	HookManagerCh <- models.HookDetails{
+		ID: "acl-schedule-hook",
+		Hook: WrapHook(func() error {
+			if !CheckAclScheduleTransitions() {
+				return nil
+			}
+			return onChange()
+		}),
+		Interval:   aclScheduleCheckInterval,
+		LeaderOnly: true,
+	}
+}
+
+func isAclScheduleDay(s *models.AclSchedule, day time.Weekday) bool {
+	if len(s.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range s.Weekdays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAclScheduleTime - parses HH:MM into minutes since midnight
+func parseAclScheduleTime(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,54 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsAclScheduleActive(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+	weekdays := &models.AclSchedule{
+		Timezone:  "Europe/Berlin",
+		Weekdays:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		StartTime: "08:00",
+		EndTime:   "18:00",
+	}
+	// 2026-10-12 is a Monday
+	assert.True(t, IsAclScheduleActive(weekdays, time.Date(2026, 10, 12, 8, 0, 0, 0, berlin)))
+	assert.True(t, IsAclScheduleActive(weekdays, time.Date(2026, 10, 12, 17, 59, 0, 0, berlin)))
+	assert.False(t, IsAclScheduleActive(weekdays, time.Date(2026, 10, 12, 18, 0, 0, 0, berlin)))
+	assert.False(t, IsAclScheduleActive(weekdays, time.Date(2026, 10, 11, 12, 0, 0, 0, berlin)))
+	// evaluated in the schedule timezone
+	assert.False(t, IsAclScheduleActive(weekdays, time.Date(2026, 10, 12, 5, 30, 0, 0, time.UTC)))
+	assert.True(t, IsAclScheduleActive(weekdays, time.Date(2026, 10, 12, 6, 30, 0, 0, berlin).Add(2*time.Hour)))
+
+	overnight := &models.AclSchedule{
+		Weekdays:  []time.Weekday{time.Friday},
+		StartTime: "22:00",
+		EndTime:   "02:00",
+	}
+	assert.True(t, IsAclScheduleActive(overnight, time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, IsAclScheduleActive(overnight, time.Date(2026, 10, 17, 1, 0, 0, 0, time.UTC)))
+	assert.False(t, IsAclScheduleActive(overnight, time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)))
+
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 10, 8, 0, 0, 0, 0, time.UTC)
+	oneOff := &models.AclSchedule{From: &from, Until: &until}
+	assert.True(t, IsAclScheduleActive(oneOff, from))
+	assert.False(t, IsAclScheduleActive(oneOff, until))
+	assert.True(t, IsAclScheduleActive(nil, until))
+}
+
+func TestValidateAclSchedule(t *testing.T) {
+	assert.NoError(t, ValidateAclSchedule(nil))
+	assert.NoError(t, ValidateAclSchedule(&models.AclSchedule{StartTime: "08:00", EndTime: "18:00"}))
+	assert.Error(t, ValidateAclSchedule(&models.AclSchedule{}))
+	assert.Error(t, ValidateAclSchedule(&models.AclSchedule{StartTime: "08:00"}))
+	assert.Error(t, ValidateAclSchedule(&models.AclSchedule{StartTime: "8am", EndTime: "18:00"}))
+	assert.Error(t, ValidateAclSchedule(&models.AclSchedule{Timezone: "Mars/Olympus", StartTime: "08:00", EndTime: "18:00"}))
+	assert.Error(t, ValidateAclSchedule(&models.AclSchedule{Weekdays: []time.Weekday{7}}))
+}
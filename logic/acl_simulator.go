@@ -0,0 +1,339 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// draftAclID - id assigned to a draft policy that has not been saved yet
+const draftAclID = "draft"
+
+// GetUserAclSrcTags - returns the policy source values that apply to a user
+// in a network. Group expansion is only available in pro.
+var GetUserAclSrcTags = func(user *schema.User, netID schema.NetworkID) map[string]struct{} {
+	return map[string]struct{}{
+		user.Username: {},
+	}
+}
+
+// SimulateAcl - evaluates a hypothetical packet from src to dst against the
+// policies of a network and reports the verdict along with the matching policies.
+// If a draft policy is provided it replaces the saved policy with the same id,
+// or is evaluated as an additional policy.
+func SimulateAcl(req models.AclSimulationRequest) (models.AclSimulationResult, error) {
+	result := models.AclSimulationResult{MatchedPolicies: []string{}}
+	err := (&schema.Network{Name: req.NetworkID.String()}).Get(db.WithContext(context.TODO()))
+	if err != nil {
+		return result, errors.New("failed to get network details for " + req.NetworkID.String())
+	}
+	if req.Proto == "" {
+		req.Proto = models.ALL
+	}
+	switch req.Proto {
+	case models.ALL, models.TCP, models.UDP, models.ICMP:
+	default:
+		return result, fmt.Errorf("invalid protocol %s", req.Proto)
+	}
+	var port int
+	if req.Port != "" && req.Proto != models.ICMP {
+		port, err = strconv.Atoi(req.Port)
+		if err != nil || port < 1 || port > 65535 {
+			return result, fmt.Errorf("invalid port %s", req.Port)
+		}
+	}
+
+	at := time.Now()
+	if req.Time != nil {
+		at = *req.Time
+	}
+	ruleType := models.DevicePolicy
+	if req.Src.Type == models.UserAclID || req.Src.Type == models.UserGroupAclID {
+		ruleType = models.UserPolicy
+	}
+	srcTags, err := resolveAclSimulationPeer(req.NetworkID, req.Src, true)
+	if err != nil {
+		return result, fmt.Errorf("invalid source: %w", err)
+	}
+	dstTags, err := resolveAclSimulationPeer(req.NetworkID, req.Dst, false)
+	if err != nil {
+		return result, fmt.Errorf("invalid destination: %w", err)
+	}
+
+	policies, err := listAclSimulationPolicies(req)
+	if err != nil {
+		return result, err
+	}
+
+	if !servercfg.IsPro && ruleType == models.UserPolicy {
+		result.Allowed = true
+		result.DefaultPolicy = true
+		result.Reason = "user policies are not enforced"
+		return result, nil
+	}
+	defaultID := fmt.Sprintf("%s.%s", req.NetworkID, "all-users")
+	if ruleType == models.DevicePolicy {
+		defaultID = fmt.Sprintf("%s.%s", req.NetworkID, "all-nodes")
+	}
+	// policies are evaluated in order and the first match decides
+	for _, policy := range policies {
+		if !isAclActiveAt(policy, at) || policy.RuleType != ruleType {
+			continue
+		}
+		if !aclPolicyMatchesTraffic(policy, req.Proto, port) {
+			continue
+		}
+		if !aclPolicyMatchesPeers(policy, srcTags, dstTags) {
+			continue
+		}
+		result.MatchedPolicies = append(result.MatchedPolicies, policy.ID)
+		if result.DecidingPolicy != "" {
+			continue
+		}
+		result.DecidingPolicy = policy.ID
+		result.Allowed = !IsDenyPolicy(policy)
+		result.DefaultPolicy = policy.ID == defaultID
+		if result.Allowed {
+			result.Reason = "allowed by policy " + policy.Name
+		} else {
+			result.Reason = "denied by policy " + policy.Name
+		}
+	}
+	if result.DecidingPolicy == "" {
+		result.Reason = "no policy allows this traffic and the default policy is disabled"
+	}
+	return result, nil
+}
+
+// listAclSimulationPolicies - lists the network policies with the draft
+// policy of the request applied
+func listAclSimulationPolicies(req models.AclSimulationRequest) ([]models.Acl, error) {
+	policies, err := ListAclsByNetwork(req.NetworkID)
+	if err != nil {
+		return nil, err
+	}
+	if req.DraftAcl == nil {
+		return policies, nil
+	}
+	draft := *req.DraftAcl
+	if draft.NetworkID == "" {
+		draft.NetworkID = req.NetworkID
+	}
+	if draft.NetworkID != req.NetworkID {
+		return nil, errors.New("invalid draft policy, network id mismatch")
+	}
+	if draft.ID == "" {
+		draft.ID = draftAclID
+	}
+	if draft.ServiceType == models.Any {
+		draft.Port = []string{}
+		draft.Proto = models.ALL
+	}
+	if err := IsAclPolicyValid(draft); err != nil {
+		return nil, fmt.Errorf("invalid draft policy: %w", err)
+	}
+	if err := ValidateAclActionAndPriority(draft); err != nil {
+		return nil, fmt.Errorf("invalid draft policy: %w", err)
+	}
+	if err := ValidateAclSchedule(draft.Schedule); err != nil {
+		return nil, fmt.Errorf("invalid draft policy: %w", err)
+	}
+	for i := range policies {
+		if policies[i].ID == draft.ID {
+			if draft.Priority == 0 {
+				draft.Priority = policies[i].Priority
+			}
+			policies = append(policies[:i], policies[i+1:]...)
+			break
+		}
+	}
+	setAclActionAndPriority(&draft)
+	policies = append(policies, draft)
+	SortAclEntrys(policies)
+	return policies, nil
+}
+
+// resolveAclSimulationPeer - resolves a simulated peer to the set of policy
+// tag values it is matched by
+func resolveAclSimulationPeer(netID schema.NetworkID, peer models.AclSimulationPeer, isSrc bool) (map[string]struct{}, error) {
+	if peer.Value == "" {
+		return nil, errors.New("value is required")
+	}
+	tags := map[string]struct{}{
+		"*": {},
+	}
+	switch peer.Type {
+	case models.UserAclID:
+		if !isSrc {
+			return nil, errors.New("user cannot be a destination")
+		}
+		user := &schema.User{Username: peer.Value}
+		if err := user.Get(db.WithContext(context.TODO())); err != nil {
+			return nil, errors.New("invalid user " + peer.Value)
+		}
+		for tag := range GetUserAclSrcTags(user, netID) {
+			tags[tag] = struct{}{}
+		}
+	case models.UserGroupAclID:
+		if !isSrc {
+			return nil, errors.New("user group cannot be a destination")
+		}
+		tags[peer.Value] = struct{}{}
+	case models.NodeTagID:
+		tags[peer.Value] = struct{}{}
+	case models.NodeID:
+		node, err := GetNodeByID(peer.Value)
+		if err != nil {
+			extclient, extErr := GetExtClient(peer.Value, netID.String())
+			if extErr != nil {
+				return nil, errors.New("invalid device " + peer.Value)
+			}
+			node = extclient.ConvertToStaticNode()
+		}
+		addAclSimulationNodeTags(tags, node)
+	case models.EgressID, models.EgressRange:
+		if isSrc {
+			return nil, errors.New("egress cannot be a source")
+		}
+		tags[peer.Value] = struct{}{}
+	case models.NetmakerIPAclID:
+		ip := net.ParseIP(peer.Value)
+		if ip == nil {
+			return nil, errors.New("invalid ip " + peer.Value)
+		}
+		if node, ok := findAclSimulationNodeByIP(netID, ip); ok {
+			addAclSimulationNodeTags(tags, node)
+			return tags, nil
+		}
+		if isSrc {
+			return nil, fmt.Errorf("ip %s does not belong to a device in network %s", peer.Value, netID)
+		}
+		egresses, _ := (&schema.Egress{Network: netID.String()}).ListByNetwork(db.WithContext(context.TODO()))
+		found := false
+		for _, e := range egresses {
+			if !e.Status || e.Range == "" {
+				continue
+			}
+			_, cidr, err := net.ParseCIDR(e.Range)
+			if err == nil && cidr.Contains(ip) {
+				tags[e.ID] = struct{}{}
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("ip %s does not belong to a device or egress range in network %s", peer.Value, netID)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %s", peer.Type)
+	}
+	return tags, nil
+}
+
+// addAclSimulationNodeTags - adds the id and tags of the node to the set
+func addAclSimulationNodeTags(tags map[string]struct{}, node models.Node) {
+	if node.IsStatic {
+		tags[node.StaticNode.ClientID] = struct{}{}
+		node = node.StaticNode.ConvertToStaticNode()
+	} else {
+		tags[node.ID.String()] = struct{}{}
+	}
+	if node.Mutex != nil {
+		node.Mutex.Lock()
+		defer node.Mutex.Unlock()
+	}
+	for tagID := range node.Tags {
+		tags[tagID.String()] = struct{}{}
+	}
+	if !servercfg.IsPro && node.IsGw {
+		tags[fmt.Sprintf("%s.%s", node.Network, models.GwTagName)] = struct{}{}
+	}
+}
+
+// findAclSimulationNodeByIP - finds the device or static node with the ip in a network
+func findAclSimulationNodeByIP(netID schema.NetworkID, ip net.IP) (models.Node, bool) {
+	nodes, _ := GetNetworkNodes(netID.String())
+	for _, node := range nodes {
+		if (node.Address.IP != nil && node.Address.IP.Equal(ip)) ||
+			(node.Address6.IP != nil && node.Address6.IP.Equal(ip)) {
+			return node, true
+		}
+	}
+	extclients, _ := GetNetworkExtClients(netID.String())
+	for _, extclient := range extclients {
+		if extclient.Address == ip.String() || extclient.Address6 == ip.String() {
+			return extclient.ConvertToStaticNode(), true
+		}
+	}
+	return models.Node{}, false
+}
+
+// aclPolicyMatchesPeers - checks if the policy applies between the src and dst tag sets
+func aclPolicyMatchesPeers(policy models.Acl, src, dst map[string]struct{}) bool {
+	srcMap := ConvAclTagToValueMap(policy.Src)
+	dstMap := ConvAclTagToValueMap(policy.Dst)
+	for _, dstI := range policy.Dst {
+		if dstI.ID == models.EgressID {
+			e := schema.Egress{ID: dstI.Value}
+			err := e.Get(db.WithContext(context.TODO()))
+			if err == nil && e.Status {
+				for nodeID := range e.Nodes {
+					dstMap[nodeID] = struct{}{}
+				}
+			}
+		}
+	}
+	if aclTagsIntersect(srcMap, src) && aclTagsIntersect(dstMap, dst) {
+		return true
+	}
+	if policy.RuleType == models.DevicePolicy && policy.AllowedDirection == models.TrafficDirectionBi {
+		return aclTagsIntersect(srcMap, dst) && aclTagsIntersect(dstMap, src)
+	}
+	return false
+}
+
+// aclPolicyMatchesTraffic - checks if the protocol and port are allowed by the policy.
+// A port of 0 stands for any port and only matches policies without port restrictions.
+func aclPolicyMatchesTraffic(policy models.Acl, proto models.Protocol, port int) bool {
+	if policy.Proto == "" || policy.Proto == models.ALL {
+		return true
+	}
+	if policy.Proto != proto {
+		return false
+	}
+	if proto == models.ICMP || len(policy.Port) == 0 {
+		return true
+	}
+	if port == 0 {
+		return false
+	}
+	for _, portI := range policy.Port {
+		if aclPortMatches(portI, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// aclPortMatches - checks if the port matches a policy port entry, which is
+// either a single port or a range such as 8000-9000
+func aclPortMatches(entry string, port int) bool {
+	r, ok := parseAclPortRange(entry)
+	return ok && port >= r.start && port <= r.end
+}
+
+func aclTagsIntersect(policyTags, peerTags map[string]struct{}) bool {
+	for tag := range peerTags {
+		if _, ok := policyTags[tag]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,374 @@
+package logic
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func TestAclPolicyMatchesTraffic(t *testing.T) {
+	policy := models.Acl{Proto: models.TCP, Port: []string{"443", "8000-8100"}}
+	assert.True(t, aclPolicyMatchesTraffic(policy, models.TCP, 443))
+	assert.True(t, aclPolicyMatchesTraffic(policy, models.TCP, 8050))
+	assert.False(t, aclPolicyMatchesTraffic(policy, models.TCP, 22))
+	assert.False(t, aclPolicyMatchesTraffic(policy, models.UDP, 443))
+	// unspecified port only matches policies without port restrictions
+	assert.False(t, aclPolicyMatchesTraffic(policy, models.TCP, 0))
+	assert.True(t, aclPolicyMatchesTraffic(models.Acl{Proto: models.ALL}, models.UDP, 53))
+	assert.True(t, aclPolicyMatchesTraffic(models.Acl{Proto: models.ICMP}, models.ICMP, 0))
+}
+
+func TestAclPolicyMatchesPeers(t *testing.T) {
+	policy := models.Acl{
+		RuleType: models.DevicePolicy,
+		Src:      []models.AclPolicyTag{{ID: models.NodeTagID, Value: "net.web"}},
+		Dst:      []models.AclPolicyTag{{ID: models.NodeTagID, Value: "net.db"}},
+	}
+	web := map[string]struct{}{"*": {}, "node-1": {}, "net.web": {}}
+	db := map[string]struct{}{"*": {}, "node-2": {}, "net.db": {}}
+	assert.True(t, aclPolicyMatchesPeers(policy, web, db))
+	assert.False(t, aclPolicyMatchesPeers(policy, db, web))
+	policy.AllowedDirection = models.TrafficDirectionBi
+	assert.True(t, aclPolicyMatchesPeers(policy, db, web))
+}
+
+func TestSimulateAcl(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	database.InitializeDatabase()
+	ctx := db.WithContext(context.TODO())
+
+	isPro, getUserAclSrcTags, isAclPolicyValid := servercfg.IsPro, GetUserAclSrcTags, IsAclPolicyValid
+	defer func() {
+		servercfg.IsPro, GetUserAclSrcTags, IsAclPolicyValid = isPro, getUserAclSrcTags, isAclPolicyValid
+	}()
+	servercfg.IsPro = true
+	// group expansion of pro
+	GetUserAclSrcTags = func(user *schema.User, netID schema.NetworkID) map[string]struct{} {
+		tags := map[string]struct{}{user.Username: {}}
+		for group := range user.UserGroups.Data() {
+			tags[group.String()] = struct{}{}
+		}
+		return tags
+	}
+	IsAclPolicyValid = func(models.Acl) error { return nil }
+
+	const netID schema.NetworkID = "acl-sim"
+	network := &schema.Network{Name: netID.String(), AddressRange: "100.64.0.0/16"}
+	require.NoError(t, network.Create(ctx))
+	defer network.Delete(ctx)
+
+	newNode := func(ip string, tags ...models.TagID) models.Node {
+		node := models.Node{
+			CommonNode: models.CommonNode{
+				ID:      uuid.New(),
+				Network: netID.String(),
+				Address: net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)},
+			},
+			Tags: map[models.TagID]struct{}{},
+		}
+		for _, tag := range tags {
+			node.Tags[tag] = struct{}{}
+		}
+		require.NoError(t, UpsertNode(&node))
+		return node
+	}
+	web := newNode("100.64.0.1", "acl-sim.web")
+	defer DeleteNodeByID(&web)
+	dbNode := newNode("100.64.0.2", "acl-sim.db")
+	defer DeleteNodeByID(&dbNode)
+	gw := newNode("100.64.0.3")
+	defer DeleteNodeByID(&gw)
+
+	require.NoError(t, db.FromContext(ctx).Where("1 = 1").Delete(&schema.Egress{}).Error)
+	egress := schema.Egress{
+		ID:      uuid.NewString(),
+		Network: netID.String(),
+		Range:   "10.20.0.0/16",
+		Nodes:   datatypes.JSONMap{gw.ID.String(): 256},
+		Status:  true,
+	}
+	require.NoError(t, egress.Create(ctx))
+	defer egress.Delete(ctx)
+
+	alice := schema.User{
+		Username:       "acl-sim-alice",
+		PlatformRoleID: schema.PlatformUser,
+		UserGroups:     datatypes.NewJSONType(map[schema.UserGroupID]struct{}{"finance": {}}),
+	}
+	require.NoError(t, alice.Create(ctx))
+	defer alice.Delete(ctx)
+
+	tag := func(value string) []models.AclPolicyTag {
+		return []models.AclPolicyTag{{ID: models.NodeTagID, Value: value}}
+	}
+	policies := []models.Acl{
+		{
+			ID:       "deny-ssh",
+			Name:     "deny ssh",
+			RuleType: models.DevicePolicy,
+			Src:      tag("acl-sim.web"),
+			Dst:      tag("acl-sim.db"),
+			Proto:    models.TCP,
+			Port:     []string{"22"},
+			Action:   models.AclActionDeny,
+			Priority: 10,
+		},
+		{
+			ID:               "allow-web-db",
+			Name:             "allow web db",
+			RuleType:         models.DevicePolicy,
+			Src:              tag("acl-sim.web"),
+			Dst:              tag("acl-sim.db"),
+			Proto:            models.ALL,
+			AllowedDirection: models.TrafficDirectionBi,
+			Priority:         20,
+		},
+		{
+			ID:       "allow-egress",
+			Name:     "allow egress",
+			RuleType: models.DevicePolicy,
+			Src:      tag("acl-sim.web"),
+			Dst:      []models.AclPolicyTag{{ID: models.EgressID, Value: egress.ID}},
+			Proto:    models.ALL,
+			Priority: 30,
+		},
+		{
+			ID:       "acl-sim.all-nodes",
+			Name:     "All Nodes",
+			Default:  true,
+			RuleType: models.DevicePolicy,
+			Src:      tag("*"),
+			Dst:      tag("*"),
+			Proto:    models.ALL,
+		},
+		{
+			ID:       "allow-finance",
+			Name:     "allow finance",
+			RuleType: models.UserPolicy,
+			Src:      []models.AclPolicyTag{{ID: models.UserGroupAclID, Value: "finance"}},
+			Dst:      tag("acl-sim.db"),
+			Proto:    models.ALL,
+			Priority: 10,
+		},
+		{
+			ID:       "allow-alice",
+			Name:     "allow alice",
+			RuleType: models.UserPolicy,
+			Src:      []models.AclPolicyTag{{ID: models.UserAclID, Value: alice.Username}},
+			Dst:      tag("acl-sim.web"),
+			Proto:    models.ALL,
+			Priority: 20,
+		},
+	}
+	for _, policy := range policies {
+		policy.NetworkID = netID
+		// the default policy is disabled
+		policy.Enabled = !policy.Default
+		require.NoError(t, InsertAcl(policy))
+		defer DeleteAcl(policy)
+	}
+
+	device := func(node models.Node) models.AclSimulationPeer {
+		return models.AclSimulationPeer{Type: models.NodeID, Value: node.ID.String()}
+	}
+	ip := func(value string) models.AclSimulationPeer {
+		return models.AclSimulationPeer{Type: models.NetmakerIPAclID, Value: value}
+	}
+	tests := []struct {
+		name     string
+		req      models.AclSimulationRequest
+		allowed  bool
+		deciding string
+		matched  []string
+		err      bool
+	}{
+		{
+			name:     "deny ranked above allow",
+			req:      models.AclSimulationRequest{Src: device(web), Dst: device(dbNode), Proto: models.TCP, Port: "22"},
+			deciding: "deny-ssh",
+			matched:  []string{"deny-ssh", "allow-web-db"},
+		},
+		{
+			name:     "allowed by device policy",
+			req:      models.AclSimulationRequest{Src: device(web), Dst: device(dbNode), Proto: models.TCP, Port: "443"},
+			allowed:  true,
+			deciding: "allow-web-db",
+			matched:  []string{"allow-web-db"},
+		},
+		{
+			name:     "bidirectional policy matches the reverse direction",
+			req:      models.AclSimulationRequest{Src: device(dbNode), Dst: device(web), Proto: models.TCP, Port: "22"},
+			allowed:  true,
+			deciding: "allow-web-db",
+			matched:  []string{"allow-web-db"},
+		},
+		{
+			name: "device ip source",
+			req: models.AclSimulationRequest{
+				Src:   ip("100.64.0.1"),
+				Dst:   models.AclSimulationPeer{Type: models.NodeTagID, Value: "acl-sim.db"},
+				Proto: models.UDP,
+				Port:  "53",
+			},
+			allowed:  true,
+			deciding: "allow-web-db",
+			matched:  []string{"allow-web-db"},
+		},
+		{
+			name:     "ip resolved to an egress range",
+			req:      models.AclSimulationRequest{Src: device(web), Dst: ip("10.20.1.5")},
+			allowed:  true,
+			deciding: "allow-egress",
+			matched:  []string{"allow-egress"},
+		},
+		{
+			name:     "egress gateway",
+			req:      models.AclSimulationRequest{Src: device(web), Dst: device(gw)},
+			allowed:  true,
+			deciding: "allow-egress",
+			matched:  []string{"allow-egress"},
+		},
+		{
+			name:    "no matching policy and default disabled",
+			req:     models.AclSimulationRequest{Src: device(gw), Dst: device(dbNode)},
+			matched: []string{},
+		},
+		{
+			name: "ip outside of devices and egress ranges",
+			req:  models.AclSimulationRequest{Src: device(web), Dst: ip("10.30.0.1")},
+			err:  true,
+		},
+		{
+			name: "source ip of an egress range",
+			req:  models.AclSimulationRequest{Src: ip("10.20.1.5"), Dst: device(web)},
+			err:  true,
+		},
+		{
+			name: "egress source",
+			req:  models.AclSimulationRequest{Src: models.AclSimulationPeer{Type: models.EgressID, Value: egress.ID}, Dst: device(web)},
+			err:  true,
+		},
+		{
+			name: "user destination",
+			req:  models.AclSimulationRequest{Src: device(web), Dst: models.AclSimulationPeer{Type: models.UserAclID, Value: alice.Username}},
+			err:  true,
+		},
+		{
+			name:     "user resolved to its groups",
+			req:      models.AclSimulationRequest{Src: models.AclSimulationPeer{Type: models.UserAclID, Value: alice.Username}, Dst: device(dbNode)},
+			allowed:  true,
+			deciding: "allow-finance",
+			matched:  []string{"allow-finance"},
+		},
+		{
+			name:     "user",
+			req:      models.AclSimulationRequest{Src: models.AclSimulationPeer{Type: models.UserAclID, Value: alice.Username}, Dst: device(web)},
+			allowed:  true,
+			deciding: "allow-alice",
+			matched:  []string{"allow-alice"},
+		},
+		{
+			name:    "user group without the user policies",
+			req:     models.AclSimulationRequest{Src: models.AclSimulationPeer{Type: models.UserGroupAclID, Value: "finance"}, Dst: device(web)},
+			matched: []string{},
+		},
+		{
+			name: "unknown user",
+			req:  models.AclSimulationRequest{Src: models.AclSimulationPeer{Type: models.UserAclID, Value: "acl-sim-nobody"}, Dst: device(web)},
+			err:  true,
+		},
+		{
+			name: "draft replaces the saved policy",
+			req: models.AclSimulationRequest{
+				Src:   device(web),
+				Dst:   device(dbNode),
+				Proto: models.TCP,
+				Port:  "443",
+				DraftAcl: &models.Acl{
+					ID:               "allow-web-db",
+					Name:             "deny web db",
+					RuleType:         models.DevicePolicy,
+					Src:              tag("acl-sim.web"),
+					Dst:              tag("acl-sim.db"),
+					Proto:            models.ALL,
+					AllowedDirection: models.TrafficDirectionBi,
+					Action:           models.AclActionDeny,
+					Enabled:          true,
+				},
+			},
+			deciding: "allow-web-db",
+			matched:  []string{"allow-web-db"},
+		},
+		{
+			name: "new draft ranked by its priority",
+			req: models.AclSimulationRequest{
+				Src:   device(web),
+				Dst:   device(dbNode),
+				Proto: models.TCP,
+				Port:  "22",
+				DraftAcl: &models.Acl{
+					Name:     "allow ssh",
+					RuleType: models.DevicePolicy,
+					Src:      tag("acl-sim.web"),
+					Dst:      tag("acl-sim.db"),
+					Proto:    models.TCP,
+					Port:     []string{"22"},
+					Priority: 5,
+					Enabled:  true,
+				},
+			},
+			allowed:  true,
+			deciding: draftAclID,
+			matched:  []string{draftAclID, "deny-ssh", "allow-web-db"},
+		},
+		{
+			name: "draft of another network",
+			req: models.AclSimulationRequest{
+				Src:      device(web),
+				Dst:      device(dbNode),
+				DraftAcl: &models.Acl{NetworkID: "other", RuleType: models.DevicePolicy, Enabled: true},
+			},
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.NetworkID = netID
+			result, err := SimulateAcl(tt.req)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.allowed, result.Allowed)
+			assert.Equal(t, tt.deciding, result.DecidingPolicy)
+			assert.Equal(t, tt.matched, result.MatchedPolicies)
+			assert.False(t, result.DefaultPolicy)
+		})
+	}
+
+	t.Run("user policies on CE", func(t *testing.T) {
+		servercfg.IsPro = false
+		defer func() { servercfg.IsPro = true }()
+		result, err := SimulateAcl(models.AclSimulationRequest{
+			NetworkID: netID,
+			Src:       models.AclSimulationPeer{Type: models.UserAclID, Value: alice.Username},
+			Dst:       device(web),
+		})
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.True(t, result.DefaultPolicy)
+		assert.Empty(t, result.DecidingPolicy)
+	})
+}
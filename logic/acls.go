@@ -7,7 +7,10 @@ import (
 	"fmt"
 	"maps"
 	"net"
+	"net/netip"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -441,11 +444,11 @@ func GetStaticNodeIps(node models.Node) (ips []net.IP) {
 	return
 }
 
-var MigrateToGws = func() {
+var MigrateToGws = func() error {
 
 	nodes, err := GetAllNodes()
 	if err != nil {
-		return
+		return err
 	}
 	for _, node := range nodes {
 		if node.IsIngressGateway || node.IsRelay || node.IsInternetGateway {
@@ -455,21 +458,358 @@ var MigrateToGws = func() {
 			if node.Tags == nil {
 				node.Tags = make(map[models.TagID]struct{})
 			}
-			UpsertNode(&node)
+			if err = UpsertNode(&node); err != nil {
+				return err
+			}
 		}
 	}
-
+	return nil
 }
 
 var CheckIfAnyPolicyisUniDirectional = func(targetNode models.Node, acls []models.Acl) bool {
 	return false
 }
 
+// ApplyAclRulePriorities - enforces first match semantics on the rules of a node. Clients
+// install allow rules only, so the traffic matched by a deny rule is carved out of the allow
+// rules evaluated after it, splitting them where needed, and the deny rules are removed.
+// Carving a single protocol out of an allow rule for all protocols keeps tcp, udp and icmp.
+func ApplyAclRulePriorities(rules map[string]models.AclRule) map[string]models.AclRule {
+	ordered := make([]models.AclRule, 0, len(rules))
+	for _, rule := range rules {
+		ordered = append(ordered, rule)
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Priority != ordered[j].Priority {
+			return ordered[i].Priority < ordered[j].Priority
+		}
+		return ordered[i].ID < ordered[j].ID
+	})
+	result := make(map[string]models.AclRule, len(rules))
+	denies := []models.AclRule{}
+	for _, rule := range ordered {
+		if !rule.Allowed {
+			denies = append(denies, rule)
+			continue
+		}
+		parts := []models.AclRule{rule}
+		for _, deny := range denies {
+			remaining := []models.AclRule{}
+			for _, part := range parts {
+				remaining = append(remaining, subtractAclRule(part, deny)...)
+			}
+			parts = remaining
+		}
+		for i, part := range parts {
+			if i > 0 {
+				part.ID = fmt.Sprintf("%s-%d", rule.ID, i)
+			}
+			result[part.ID] = part
+		}
+	}
+	return result
+}
+
+// subtractAclRule - returns the parts of the allow rule the deny rule doesn't match
+func subtractAclRule(allow, deny models.AclRule) []models.AclRule {
+	traffic, overlaps := subtractAclTraffic(allow, deny)
+	if !overlaps {
+		return []models.AclRule{allow}
+	}
+	src4 := intersectIPNets(allow.IPList, deny.IPList)
+	src6 := intersectIPNets(allow.IP6List, deny.IP6List)
+	dst4 := intersectIPNets(allow.Dst, deny.Dst)
+	dst6 := intersectIPNets(allow.Dst6, deny.Dst6)
+	if (len(src4) == 0 || len(dst4) == 0) && (len(src6) == 0 || len(dst6) == 0) {
+		return []models.AclRule{allow}
+	}
+	parts := []models.AclRule{}
+	// sources not matched by the deny rule
+	part := allow
+	part.IPList = subtractIPNets(allow.IPList, deny.IPList)
+	part.IP6List = subtractIPNets(allow.IP6List, deny.IP6List)
+	parts = appendAclRule(parts, part)
+	// denied sources to destinations not matched by the deny rule
+	part = allow
+	part.IPList, part.IP6List = src4, src6
+	part.Dst = subtractIPNets(allow.Dst, deny.Dst)
+	part.Dst6 = subtractIPNets(allow.Dst6, deny.Dst6)
+	parts = appendAclRule(parts, part)
+	// denied sources and destinations, traffic not matched by the deny rule
+	for _, t := range traffic {
+		part = allow
+		part.IPList, part.IP6List = src4, src6
+		part.Dst, part.Dst6 = dst4, dst6
+		part.AllowedProtocol, part.AllowedPorts = t.proto, t.ports
+		parts = appendAclRule(parts, part)
+	}
+	return parts
+}
+
+// appendAclRule - appends the rule unless it is left without sources or destinations
+func appendAclRule(rules []models.AclRule, rule models.AclRule) []models.AclRule {
+	if (len(rule.IPList) == 0 || len(rule.Dst) == 0) && (len(rule.IP6List) == 0 || len(rule.Dst6) == 0) {
+		return rules
+	}
+	return append(rules, rule)
+}
+
+type aclTraffic struct {
+	proto models.Protocol
+	ports []string
+}
+
+// subtractAclTraffic - returns the protocols and ports of the allow rule not matched by
+// the deny rule, and whether the deny rule matches any of them
+func subtractAclTraffic(allow, deny models.AclRule) ([]aclTraffic, bool) {
+	allowProto, denyProto := allow.AllowedProtocol, deny.AllowedProtocol
+	if allowProto == "" {
+		allowProto = models.ALL
+	}
+	if denyProto == "" || denyProto == models.ALL {
+		return nil, true
+	}
+	denyPorts := parseAclPortRanges(deny.AllowedPorts)
+	if denyProto == models.ICMP {
+		denyPorts = nil
+	}
+	if allowProto == models.ALL {
+		remaining := []aclTraffic{}
+		for _, proto := range []models.Protocol{models.TCP, models.UDP, models.ICMP} {
+			if proto != denyProto {
+				remaining = append(remaining, aclTraffic{proto: proto, ports: []string{}})
+			} else if len(denyPorts) > 0 {
+				ports := subtractPortRanges([]portRange{allPorts}, denyPorts)
+				remaining = append(remaining, aclTraffic{proto: proto, ports: formatPortRanges(ports)})
+			}
+		}
+		return remaining, true
+	}
+	if allowProto != denyProto {
+		return nil, false
+	}
+	if allowProto == models.ICMP || len(denyPorts) == 0 {
+		return nil, true
+	}
+	allowPorts := []portRange{allPorts}
+	if len(allow.AllowedPorts) > 0 {
+		allowPorts = parseAclPortRanges(allow.AllowedPorts)
+	}
+	if !portRangesOverlap(allowPorts, denyPorts) {
+		return nil, false
+	}
+	ports := subtractPortRanges(allowPorts, denyPorts)
+	if len(ports) == 0 {
+		return nil, true
+	}
+	return []aclTraffic{{proto: allowProto, ports: formatPortRanges(ports)}}, true
+}
+
+type portRange struct {
+	start, end int
+}
+
+var allPorts = portRange{start: 1, end: 65535}
+
+// parseAclPortRange - parses a policy port entry, which is either a single
+// port or a range such as 8000-9000
+func parseAclPortRange(entry string) (portRange, bool) {
+	entry = strings.TrimSpace(entry)
+	start, end, isRange := strings.Cut(entry, "-")
+	if !isRange {
+		end = start
+	}
+	startPort, err := strconv.Atoi(strings.TrimSpace(start))
+	if err != nil {
+		return portRange{}, false
+	}
+	endPort, err := strconv.Atoi(strings.TrimSpace(end))
+	if err != nil || endPort < startPort {
+		return portRange{}, false
+	}
+	return portRange{start: startPort, end: endPort}, true
+}
+
+func parseAclPortRanges(entries []string) []portRange {
+	ranges := []portRange{}
+	for _, entry := range entries {
+		if r, ok := parseAclPortRange(entry); ok {
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
+}
+
+func portRangesOverlap(a, b []portRange) bool {
+	for _, rA := range a {
+		for _, rB := range b {
+			if rA.start <= rB.end && rB.start <= rA.end {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func subtractPortRanges(ranges, remove []portRange) []portRange {
+	for _, r := range remove {
+		remaining := []portRange{}
+		for _, rI := range ranges {
+			if r.end < rI.start || r.start > rI.end {
+				remaining = append(remaining, rI)
+				continue
+			}
+			if rI.start < r.start {
+				remaining = append(remaining, portRange{start: rI.start, end: r.start - 1})
+			}
+			if rI.end > r.end {
+				remaining = append(remaining, portRange{start: r.end + 1, end: rI.end})
+			}
+		}
+		ranges = remaining
+	}
+	return ranges
+}
+
+func formatPortRanges(ranges []portRange) []string {
+	ports := []string{}
+	for _, r := range ranges {
+		if r.start == r.end {
+			ports = append(ports, strconv.Itoa(r.start))
+		} else {
+			ports = append(ports, fmt.Sprintf("%d-%d", r.start, r.end))
+		}
+	}
+	return ports
+}
+
+func ipNetToPrefix(ipNet net.IPNet) (netip.Prefix, bool) {
+	addr, ok := netip.AddrFromSlice(ipNet.IP)
+	if !ok || ipNet.Mask == nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+	ones, bits := ipNet.Mask.Size()
+	if bits == 128 && addr.Is4() {
+		ones -= 96
+	}
+	if ones < 0 {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(addr, ones).Masked(), true
+}
+
+func prefixToIPNet(prefix netip.Prefix) net.IPNet {
+	return net.IPNet{
+		IP:   net.IP(prefix.Addr().AsSlice()),
+		Mask: net.CIDRMask(prefix.Bits(), prefix.Addr().BitLen()),
+	}
+}
+
+// intersectIPNets - returns the networks contained in both lists. Entries without
+// an address, such as the missing ipv6 address of a node, only match each other.
+func intersectIPNets(a, b []net.IPNet) []net.IPNet {
+	result := []net.IPNet{}
+	seen := make(map[string]struct{})
+	for _, ipNetA := range a {
+		prefixA, okA := ipNetToPrefix(ipNetA)
+		for _, ipNetB := range b {
+			prefixB, okB := ipNetToPrefix(ipNetB)
+			var match net.IPNet
+			switch {
+			case !okA || !okB:
+				if okA || okB {
+					continue
+				}
+				match = ipNetA
+			case !prefixA.Overlaps(prefixB):
+				continue
+			case prefixA.Bits() >= prefixB.Bits():
+				match = ipNetA
+			default:
+				match = ipNetB
+			}
+			if _, ok := seen[match.String()]; !ok {
+				seen[match.String()] = struct{}{}
+				result = append(result, match)
+			}
+		}
+	}
+	return result
+}
+
+// subtractIPNets - returns the parts of the networks not contained in remove
+func subtractIPNets(ipNets, remove []net.IPNet) []net.IPNet {
+	result := []net.IPNet{}
+	for _, ipNet := range ipNets {
+		prefix, ok := ipNetToPrefix(ipNet)
+		if !ok {
+			removed := false
+			for _, r := range remove {
+				if _, okR := ipNetToPrefix(r); !okR {
+					removed = true
+					break
+				}
+			}
+			if !removed {
+				result = append(result, ipNet)
+			}
+			continue
+		}
+		parts := []netip.Prefix{prefix}
+		changed := false
+		for _, r := range remove {
+			prefixR, okR := ipNetToPrefix(r)
+			if !okR {
+				continue
+			}
+			remaining := []netip.Prefix{}
+			for _, part := range parts {
+				if !part.Overlaps(prefixR) {
+					remaining = append(remaining, part)
+					continue
+				}
+				changed = true
+				remaining = append(remaining, subtractPrefix(part, prefixR)...)
+			}
+			parts = remaining
+		}
+		if !changed {
+			result = append(result, ipNet)
+			continue
+		}
+		for _, part := range parts {
+			result = append(result, prefixToIPNet(part))
+		}
+	}
+	return result
+}
+
+// subtractPrefix - returns the prefixes covering a without b, a and b overlap
+func subtractPrefix(a, b netip.Prefix) []netip.Prefix {
+	result := []netip.Prefix{}
+	for a.Bits() < b.Bits() {
+		lower := netip.PrefixFrom(a.Addr(), a.Bits()+1)
+		upperAddr := a.Addr().AsSlice()
+		upperAddr[a.Bits()/8] |= 0x80 >> (a.Bits() % 8)
+		addr, _ := netip.AddrFromSlice(upperAddr)
+		upper := netip.PrefixFrom(addr, a.Bits()+1)
+		if lower.Overlaps(b) {
+			result = append(result, upper)
+			a = lower
+		} else {
+			result = append(result, lower)
+			a = upper
+		}
+	}
+	return result
+}
+
 func GetAclRulesForNode(targetnodeI *models.Node) (rules map[string]models.AclRule) {
 	targetnode := *targetnodeI
 	defer func() {
 		//if !targetnode.IsIngressGateway {
-		rules = GetUserAclRulesForNode(&targetnode, rules)
+		rules = ApplyAclRulePriorities(GetUserAclRulesForNode(&targetnode, rules))
 		//}
 	}()
 	rules = make(map[string]models.AclRule)
@@ -479,6 +819,7 @@ func GetAclRulesForNode(targetnodeI *models.Node) (rules map[string]models.AclRu
 			AllowedProtocol: models.ALL,
 			Direction:       models.TrafficDirectionBi,
 			Allowed:         true,
+			Priority:        models.DefaultAclPriority,
 			IPList:          []net.IPNet{targetnode.NetworkRange},
 			IP6List:         []net.IPNet{targetnode.NetworkRange6},
 			Dst:             []net.IPNet{targetnode.AddressIPNet4()},
@@ -535,7 +876,7 @@ func GetAclRulesForNode(targetnodeI *models.Node) (rules map[string]models.AclRu
 	targetNodeTags[models.TagID(targetnode.ID.String())] = struct{}{}
 	targetNodeTags["*"] = struct{}{}
 	for _, acl := range acls {
-		if !acl.Enabled {
+		if !IsAclActive(acl) {
 			continue
 		}
 		srcTags := ConvAclTagToValueMap(acl.Src)
@@ -645,7 +986,8 @@ func GetAclRulesForNode(targetnodeI *models.Node) (rules map[string]models.AclRu
 			AllowedProtocol: acl.Proto,
 			AllowedPorts:    acl.Port,
 			Direction:       acl.AllowedDirection,
-			Allowed:         true,
+			Allowed:         !IsDenyPolicy(acl),
+			Priority:        acl.Priority,
 			Dst:             []net.IPNet{targetnode.AddressIPNet4()},
 			Dst6:            []net.IPNet{targetnode.AddressIPNet6()},
 		}
@@ -794,7 +1136,7 @@ func GetAclRulesForNode(targetnodeI *models.Node) (rules map[string]models.AclRu
 func GetEgressRulesForNode(targetnode models.Node) (rules map[string]models.AclRule) {
 	rules = make(map[string]models.AclRule)
 	defer func() {
-		rules = GetEgressUserRulesForNode(&targetnode, rules)
+		rules = ApplyAclRulePriorities(GetEgressUserRulesForNode(&targetnode, rules))
 	}()
 	taggedNodes := GetTagMapWithNodesByNetwork(schema.NetworkID(targetnode.Network), true)
 
@@ -823,7 +1165,7 @@ func GetEgressRulesForNode(targetnode models.Node) (rules map[string]models.AclR
 		return
 	}
 	for _, acl := range acls {
-		if !acl.Enabled {
+		if !IsAclActive(acl) {
 			continue
 		}
 		srcTags := ConvAclTagToValueMap(acl.Src)
@@ -834,7 +1176,8 @@ func GetEgressRulesForNode(targetnode models.Node) (rules map[string]models.AclR
 			AllowedProtocol: acl.Proto,
 			AllowedPorts:    acl.Port,
 			Direction:       acl.AllowedDirection,
-			Allowed:         true,
+			Allowed:         !IsDenyPolicy(acl),
+			Priority:        acl.Priority,
 		}
 		for egressID, egI := range egressIDMap {
 			if _, ok := dstTags[egressID]; ok || dstAll {
@@ -915,6 +1258,7 @@ func GetAclRuleForInetGw(targetnode models.Node) (rules map[string]models.AclRul
 			AllowedPorts:    []string{},
 			Direction:       models.TrafficDirectionBi,
 			Allowed:         true,
+			Priority:        models.DefaultAclPriority,
 		}
 		if targetnode.NetworkRange.IP != nil {
 			aclRule.IPList = append(aclRule.IPList, targetnode.NetworkRange)
@@ -1062,6 +1406,8 @@ var IsPeerAllowed = func(node, peer models.Node, checkDefaultPolicy bool) bool {
 	nodeTags := make(map[models.TagID]struct{})
 	nodeTags[models.TagID(nodeId)] = struct{}{}
 	peerTags[models.TagID(peerId)] = struct{}{}
+	nodeTags["*"] = struct{}{}
+	peerTags["*"] = struct{}{}
 	if peer.IsGw {
 		peerTags[models.TagID(fmt.Sprintf("%s.%s", peer.Network, models.GwTagName))] = struct{}{}
 	}
@@ -1087,7 +1433,7 @@ var IsPeerAllowed = func(node, peer models.Node, checkDefaultPolicy bool) bool {
 		dstMap = nil
 	}()
 	for _, policy := range policies {
-		if !policy.Enabled {
+		if !IsAclActive(policy) {
 			continue
 		}
 
@@ -1105,6 +1451,13 @@ var IsPeerAllowed = func(node, peer models.Node, checkDefaultPolicy bool) bool {
 			}
 		}
 		if CheckTagGroupPolicy(srcMap, dstMap, node, peer, nodeTags, peerTags) {
+			// first matching policy decides, unless it only denies part of the traffic
+			if IsDenyPolicy(policy) {
+				if IsFullDenyBetween(policy, nodeId, peerId, nodeTags, peerTags) {
+					return false
+				}
+				continue
+			}
 			return true
 		}
 
@@ -1201,6 +1554,7 @@ var (
 )
 
 func MigrateAclPolicies() {
+	migrateAclActionAndPriority()
 	acls := ListAcls()
 	for _, acl := range acls {
 		if acl.Proto.String() == "" {
@@ -1219,6 +1573,37 @@ func MigrateAclPolicies() {
 
 }
 
+// migrateAclActionAndPriority - turns policies created before deny rules existed
+// into allow policies, keeping their previous order within each network
+func migrateAclActionAndPriority() {
+	netAcls := make(map[schema.NetworkID][]models.Acl)
+	for _, acl := range ListAcls() {
+		netAcls[acl.NetworkID] = append(netAcls[acl.NetworkID], acl)
+	}
+	for _, acls := range netAcls {
+		SortAclEntrys(acls)
+		priority := 0
+		for _, acl := range acls {
+			if acl.Action != "" && !acl.Default && acl.Priority > priority {
+				priority = acl.Priority
+			}
+		}
+		for _, acl := range acls {
+			if acl.Action != "" {
+				continue
+			}
+			acl.Action = models.AclActionAllow
+			if acl.Default {
+				acl.Priority = models.DefaultAclPriority
+			} else {
+				priority = min(priority+models.AclPriorityStep, models.DefaultAclPriority-1)
+				acl.Priority = priority
+			}
+			UpsertAcl(acl)
+		}
+	}
+}
+
 func IsNodeAllowedToCommunicateWithAllRsrcs(node models.Node) bool {
 	// check default policy if all allowed return true
 	defaultPolicy, err := GetDefaultPolicy(schema.NetworkID(node.Network), models.DevicePolicy)
@@ -1227,6 +1612,9 @@ func IsNodeAllowedToCommunicateWithAllRsrcs(node models.Node) bool {
 			return true
 		}
 	}
+	if HasDenyPolicies(schema.NetworkID(node.Network), models.DevicePolicy) {
+		return false
+	}
 	var nodeId string
 	if node.IsStatic {
 		nodeId = node.StaticNode.ClientID
@@ -1263,7 +1651,7 @@ func IsNodeAllowedToCommunicateWithAllRsrcs(node models.Node) bool {
 		return false
 	}
 	for _, policy := range policies {
-		if !policy.Enabled {
+		if !IsAclActive(policy) {
 			continue
 		}
 		srcMap = ConvAclTagToValueMap(policy.Src)
@@ -1351,122 +1739,105 @@ func IsNodeAllowedToCommunicate(node, peer models.Node, checkDefaultPolicy bool)
 	defer func() {
 		allowedPolicies = UniquePolicies(allowedPolicies)
 	}()
-	// list device policies
+	// list device policies, in evaluation order
 	policies := ListDevicePolicies(schema.NetworkID(peer.Network))
-	srcMap := make(map[string]struct{})
-	dstMap := make(map[string]struct{})
-	defer func() {
-		srcMap = nil
-		dstMap = nil
-	}()
 	for _, policy := range policies {
-		if !policy.Enabled {
+		if !IsAclActive(policy) {
 			continue
 		}
-		allowed := false
-		srcMap = ConvAclTagToValueMap(policy.Src)
-		dstMap = ConvAclTagToValueMap(policy.Dst)
-		for _, dst := range policy.Dst {
-			if dst.ID == models.EgressID {
-				e := schema.Egress{ID: dst.Value}
-				err := e.Get(db.WithContext(context.TODO()))
-				if err == nil && e.Status {
-					for nodeID := range e.Nodes {
-						dstMap[nodeID] = struct{}{}
-					}
-				}
+		if !isDevicePolicyApplicable(policy, nodeId, peerId, nodeTags, peerTags) {
+			continue
+		}
+		if IsDenyPolicy(policy) {
+			// one-way policies only apply from node to peer, a one-way
+			// deny from peer to node was skipped above
+			if IsFullDenyPolicy(policy) {
+				// first match, policies evaluated later do not apply
+				break
 			}
+			continue
 		}
-		_, srcAll := srcMap["*"]
-		_, dstAll := dstMap["*"]
-		if policy.AllowedDirection == models.TrafficDirectionBi {
-			if _, ok := srcMap[nodeId]; ok || srcAll {
-				if _, ok := dstMap[peerId]; ok || dstAll {
-					allowedPolicies = append(allowedPolicies, policy)
-					continue
-				}
+		allowedPolicies = append(allowedPolicies, policy)
+	}
 
-			}
-			if _, ok := dstMap[nodeId]; ok || dstAll {
-				if _, ok := srcMap[peerId]; ok || srcAll {
-					allowedPolicies = append(allowedPolicies, policy)
-					continue
+	if len(allowedPolicies) > 0 {
+		return true, allowedPolicies
+	}
+	return false, allowedPolicies
+}
+
+// isDevicePolicyApplicable - checks if the device policy applies to traffic from node to peer,
+// or between them for bi-directional policies
+func isDevicePolicyApplicable(policy models.Acl, nodeId, peerId string, nodeTags, peerTags map[models.TagID]struct{}) bool {
+	srcMap := ConvAclTagToValueMap(policy.Src)
+	dstMap := ConvAclTagToValueMap(policy.Dst)
+	for _, dst := range policy.Dst {
+		if dst.ID == models.EgressID {
+			e := schema.Egress{ID: dst.Value}
+			err := e.Get(db.WithContext(context.TODO()))
+			if err == nil && e.Status {
+				for nodeID := range e.Nodes {
+					dstMap[nodeID] = struct{}{}
 				}
 			}
 		}
-		if _, ok := dstMap[peerId]; ok || dstAll {
-			if _, ok := srcMap[nodeId]; ok || srcAll {
-				allowedPolicies = append(allowedPolicies, policy)
-				continue
+	}
+	_, srcAll := srcMap["*"]
+	_, dstAll := dstMap["*"]
+	if policy.AllowedDirection == models.TrafficDirectionBi {
+		if _, ok := srcMap[nodeId]; ok || srcAll {
+			if _, ok := dstMap[peerId]; ok || dstAll {
+				return true
 			}
 		}
-		if policy.AllowedDirection == models.TrafficDirectionBi {
-
-			for tagID := range nodeTags {
-
-				if _, ok := dstMap[tagID.String()]; ok || dstAll {
-					if srcAll {
-						allowed = true
-						break
-					}
-					for tagID := range peerTags {
-						if _, ok := srcMap[tagID.String()]; ok {
-							allowed = true
-							break
-						}
-					}
-				}
-				if allowed {
-					allowedPolicies = append(allowedPolicies, policy)
-					break
-				}
-				if _, ok := srcMap[tagID.String()]; ok || srcAll {
-					if dstAll {
-						allowed = true
-						break
-					}
-					for tagID := range peerTags {
-						if _, ok := dstMap[tagID.String()]; ok {
-							allowed = true
-							break
-						}
-					}
-				}
-				if allowed {
-					break
-				}
-			}
-			if allowed {
-				allowedPolicies = append(allowedPolicies, policy)
-				continue
+		if _, ok := dstMap[nodeId]; ok || dstAll {
+			if _, ok := srcMap[peerId]; ok || srcAll {
+				return true
 			}
 		}
-		for tagID := range peerTags {
+	}
+	if _, ok := dstMap[peerId]; ok || dstAll {
+		if _, ok := srcMap[nodeId]; ok || srcAll {
+			return true
+		}
+	}
+	if policy.AllowedDirection == models.TrafficDirectionBi {
+		for tagID := range nodeTags {
 			if _, ok := dstMap[tagID.String()]; ok || dstAll {
 				if srcAll {
-					allowed = true
-					break
+					return true
 				}
-				for tagID := range nodeTags {
+				for tagID := range peerTags {
 					if _, ok := srcMap[tagID.String()]; ok {
-						allowed = true
-						break
+						return true
 					}
 				}
 			}
-			if allowed {
-				break
+			if _, ok := srcMap[tagID.String()]; ok || srcAll {
+				if dstAll {
+					return true
+				}
+				for tagID := range peerTags {
+					if _, ok := dstMap[tagID.String()]; ok {
+						return true
+					}
+				}
 			}
 		}
-		if allowed {
-			allowedPolicies = append(allowedPolicies, policy)
-		}
 	}
-
-	if len(allowedPolicies) > 0 {
-		return true, allowedPolicies
+	for tagID := range peerTags {
+		if _, ok := dstMap[tagID.String()]; ok || dstAll {
+			if srcAll {
+				return true
+			}
+			for tagID := range nodeTags {
+				if _, ok := srcMap[tagID.String()]; ok {
+					return true
+				}
+			}
+		}
 	}
-	return false, allowedPolicies
+	return false
 }
 
 // GetDefaultPolicy - fetches default policy in the network by ruleType
@@ -1482,9 +1853,16 @@ func GetDefaultPolicy(netID schema.NetworkID, ruleType models.AclPolicyType) (mo
 	if err != nil {
 		return models.Acl{}, errors.New("default rule not found")
 	}
-	if acl.Enabled {
+	if IsAclActive(acl) {
+		if !hasDenyPolicyBefore(netID, ruleType, acl) {
+			return acl, nil
+		}
+		// deny policies are evaluated first, so traffic is not open between all peers
+		acl.Enabled = false
 		return acl, nil
 	}
+	// the default policy is disabled or outside of its schedule
+	acl.Enabled = false
 	// check if there are any custom all policies
 	srcMap := make(map[string]struct{})
 	dstMap := make(map[string]struct{})
@@ -1494,10 +1872,13 @@ func GetDefaultPolicy(netID schema.NetworkID, ruleType models.AclPolicyType) (mo
 	}()
 	policies, _ := ListAclsByNetwork(netID)
 	for _, policy := range policies {
-		if !policy.Enabled {
+		if !IsAclActive(policy) {
 			continue
 		}
 		if policy.RuleType == ruleType {
+			if IsDenyPolicy(policy) {
+				break
+			}
 			dstMap = ConvAclTagToValueMap(policy.Dst)
 			srcMap = ConvAclTagToValueMap(policy.Src)
 			if _, ok := srcMap["*"]; ok {
@@ -1525,6 +1906,7 @@ func ListAclsByNetwork(netID schema.NetworkID) ([]models.Acl, error) {
 			netAcls = append(netAcls, acl)
 		}
 	}
+	SortAclEntrys(netAcls)
 	return netAcls, nil
 }
 
@@ -1554,6 +1936,7 @@ func ListDevicePolicies(netID schema.NetworkID) []models.Acl {
 			deviceAcls = append(deviceAcls, acl)
 		}
 	}
+	SortAclEntrys(deviceAcls)
 	return deviceAcls
 }
 
@@ -1566,6 +1949,7 @@ func ListUserPolicies(netID schema.NetworkID) []models.Acl {
 			userAcls = append(userAcls, acl)
 		}
 	}
+	SortAclEntrys(userAcls)
 	return userAcls
 }
 
@@ -1601,12 +1985,25 @@ func UpdateAcl(newAcl, acl models.Acl) error {
 		acl.Port = newAcl.Port
 		acl.Proto = newAcl.Proto
 		acl.ServiceType = newAcl.ServiceType
+		if newAcl.Action != "" {
+			acl.Action = newAcl.Action
+		}
+		if newAcl.Priority != 0 {
+			acl.Priority = newAcl.Priority
+		}
+		acl.Schedule = newAcl.Schedule
 	}
 	if newAcl.ServiceType == models.Any {
 		acl.Port = []string{}
 		acl.Proto = models.ALL
 	}
 	acl.Enabled = newAcl.Enabled
+	if err := ValidateAclActionAndPriority(acl); err != nil {
+		return err
+	}
+	if err := ValidateAclSchedule(acl.Schedule); err != nil {
+		return err
+	}
 	d, err := json.Marshal(acl)
 	if err != nil {
 		return err
@@ -1615,11 +2012,15 @@ func UpdateAcl(newAcl, acl models.Acl) error {
 	if err == nil && servercfg.CacheEnabled() {
 		storeAclInCache(acl)
 	}
+	if err == nil {
+		InvalidateCache(CacheKindAcl, acl.ID)
+	}
 	return err
 }
 
 // UpsertAcl - upserts acl
 func UpsertAcl(acl models.Acl) error {
+	setAclActionAndPriority(&acl)
 	d, err := json.Marshal(acl)
 	if err != nil {
 		return err
@@ -1628,6 +2029,9 @@ func UpsertAcl(acl models.Acl) error {
 	if err == nil && servercfg.CacheEnabled() {
 		storeAclInCache(acl)
 	}
+	if err == nil {
+		InvalidateCache(CacheKindAcl, acl.ID)
+	}
 	return err
 }
 
@@ -1637,6 +2041,9 @@ func DeleteAcl(a models.Acl) error {
 	if err == nil && servercfg.CacheEnabled() {
 		removeAclFromCache(a)
 	}
+	if err == nil {
+		InvalidateCache(CacheKindAcl, a.ID)
+	}
 	return err
 }
 
@@ -1714,13 +2121,104 @@ func DeleteNetworkPolicies(netId schema.NetworkID) {
 	}
 }
 
-// SortTagEntrys - Sorts slice of Tag entries by their id
+// SortAclEntrys - sorts acl policies in evaluation order, by priority and then by name
 func SortAclEntrys(acls []models.Acl) {
-	sort.Slice(acls, func(i, j int) bool {
+	sort.SliceStable(acls, func(i, j int) bool {
+		if acls[i].Priority != acls[j].Priority {
+			return acls[i].Priority < acls[j].Priority
+		}
 		return acls[i].Name < acls[j].Name
 	})
 }
 
+// IsDenyPolicy - checks if the policy drops the traffic it matches
+func IsDenyPolicy(acl models.Acl) bool {
+	return acl.Action == models.AclActionDeny
+}
+
+// IsFullDenyPolicy - checks if the policy drops all traffic between the peers it matches
+func IsFullDenyPolicy(acl models.Acl) bool {
+	return IsDenyPolicy(acl) && (acl.Proto == "" || acl.Proto == models.ALL) && len(acl.Port) == 0
+}
+
+// IsFullDenyBetween - checks if the device policy drops all traffic between node and peer
+// in both directions. A one-way full deny only drops the traffic from its sources to its
+// destinations, the peers still need each other for the traffic the other way round.
+func IsFullDenyBetween(policy models.Acl, nodeId, peerId string, nodeTags, peerTags map[models.TagID]struct{}) bool {
+	return IsFullDenyPolicy(policy) &&
+		isDevicePolicyApplicable(policy, nodeId, peerId, nodeTags, peerTags) &&
+		isDevicePolicyApplicable(policy, peerId, nodeId, peerTags, nodeTags)
+}
+
+// hasDenyPolicyBefore - checks if an enabled deny policy of the rule type
+// is evaluated before the given policy
+func hasDenyPolicyBefore(netID schema.NetworkID, ruleType models.AclPolicyType, acl models.Acl) bool {
+	policies, _ := ListAclsByNetwork(netID)
+	for _, policy := range policies {
+		if policy.ID == acl.ID {
+			return false
+		}
+		if IsAclActive(policy) && policy.RuleType == ruleType && IsDenyPolicy(policy) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasDenyPolicies - checks if the network has enabled deny policies of the rule type
+func HasDenyPolicies(netID schema.NetworkID, ruleType models.AclPolicyType) bool {
+	policies, _ := ListAclsByNetwork(netID)
+	for _, policy := range policies {
+		if IsAclActive(policy) && policy.RuleType == ruleType && IsDenyPolicy(policy) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNextAclPriority - returns the priority for a new custom policy in the network,
+// which places it after the existing custom policies
+func GetNextAclPriority(netID schema.NetworkID) int {
+	maxPriority := 0
+	policies, _ := ListAclsByNetwork(netID)
+	for _, policy := range policies {
+		if policy.Priority < models.DefaultAclPriority && policy.Priority > maxPriority {
+			maxPriority = policy.Priority
+		}
+	}
+	return min(maxPriority+models.AclPriorityStep, models.DefaultAclPriority-1)
+}
+
+// ValidateAclActionAndPriority - validates the action and priority of a policy
+func ValidateAclActionAndPriority(acl models.Acl) error {
+	switch acl.Action {
+	case "", models.AclActionAllow, models.AclActionDeny:
+	default:
+		return fmt.Errorf("invalid policy action %s", acl.Action)
+	}
+	if acl.Default {
+		return nil
+	}
+	if acl.Priority < 0 || acl.Priority >= models.DefaultAclPriority {
+		return fmt.Errorf("policy priority must be between 1 and %d", models.DefaultAclPriority-1)
+	}
+	return nil
+}
+
+// setAclActionAndPriority - fills in the action and priority of a policy that has none set
+func setAclActionAndPriority(a *models.Acl) {
+	if a.Action == "" {
+		a.Action = models.AclActionAllow
+	}
+	if a.Priority == 0 {
+		if a.Default {
+			a.Priority = models.DefaultAclPriority
+		} else {
+			a.Priority = GetNextAclPriority(a.NetworkID)
+		}
+	}
+}
+
 // PopulateAclPolicyTagNames resolves human-readable names for ACL policy tags
 func PopulateAclPolicyTagNames(acls []models.Acl) {
 	for i := range acls {
@@ -1782,6 +2280,14 @@ func ValidateCreateAclReq(req models.Acl) error {
 	if err != nil {
 		return errors.New("failed to get network details for " + req.NetworkID.String())
 	}
+	// only custom policies are created through requests
+	req.Default = false
+	if err := ValidateAclActionAndPriority(req); err != nil {
+		return err
+	}
+	if err := ValidateAclSchedule(req.Schedule); err != nil {
+		return err
+	}
 	// err = CheckIDSyntax(req.Name)
 	// if err != nil {
 	// 	return err
@@ -1836,8 +2342,34 @@ func getAclFromCache(aID string) (a models.Acl, ok bool) {
 	return
 }
 
+// invalidateAclCache - refreshes a policy changed by another replica
+func invalidateAclCache(aID string) {
+	if !servercfg.CacheEnabled() {
+		return
+	}
+	removeAclFromCache(models.Acl{ID: aID})
+	aclCacheMutex.RLock()
+	populated := len(aclCacheMap) > 0
+	aclCacheMutex.RUnlock()
+	// an empty cache is loaded completely on next use
+	if populated {
+		_, _ = GetAcl(aID)
+	}
+}
+
+func reloadAclCache() {
+	if !servercfg.CacheEnabled() {
+		return
+	}
+	aclCacheMutex.Lock()
+	aclCacheMap = make(map[string]models.Acl)
+	aclCacheMutex.Unlock()
+	_ = ListAcls()
+}
+
 // InsertAcl - creates acl policy
 func InsertAcl(a models.Acl) error {
+	setAclActionAndPriority(&a)
 	d, err := json.Marshal(a)
 	if err != nil {
 		return err
@@ -1846,6 +2378,9 @@ func InsertAcl(a models.Acl) error {
 	if err == nil && servercfg.CacheEnabled() {
 		storeAclInCache(a)
 	}
+	if err == nil {
+		InvalidateCache(CacheKindAcl, a.ID)
+	}
 	return err
 }
 
@@ -0,0 +1,380 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortAclEntrys(t *testing.T) {
+	acls := []models.Acl{
+		{ID: "b", Name: "b", Priority: 20},
+		{ID: "default", Name: "All Nodes", Priority: models.DefaultAclPriority},
+		{ID: "c", Name: "c", Priority: 10},
+		{ID: "a", Name: "a", Priority: 20},
+	}
+	SortAclEntrys(acls)
+	ids := []string{}
+	for _, acl := range acls {
+		ids = append(ids, acl.ID)
+	}
+	assert.Equal(t, []string{"c", "a", "b", "default"}, ids)
+}
+
+func TestApplyAclRulePriorities(t *testing.T) {
+	mustCIDR := func(s string) net.IPNet {
+		_, cidr, err := net.ParseCIDR(s)
+		assert.NoError(t, err)
+		return *cidr
+	}
+	target := []net.IPNet{mustCIDR("100.64.0.1/32")}
+	finance := mustCIDR("100.64.0.2/32")
+	other := mustCIDR("100.64.0.3/32")
+	rules := map[string]models.AclRule{
+		"deny-finance": {
+			ID:              "deny-finance",
+			IPList:          []net.IPNet{finance},
+			AllowedProtocol: models.ALL,
+			Dst:             target,
+			Priority:        10,
+		},
+		"deny-ssh": {
+			ID:              "deny-ssh",
+			IPList:          []net.IPNet{other},
+			AllowedProtocol: models.TCP,
+			AllowedPorts:    []string{"22"},
+			Dst:             target,
+			Priority:        15,
+		},
+		"allow-all": {
+			ID:              "allow-all",
+			IPList:          []net.IPNet{finance, other},
+			AllowedProtocol: models.ALL,
+			Dst:             target,
+			Allowed:         true,
+			Priority:        20,
+		},
+		"allow-finance": {
+			ID:              "allow-finance",
+			IPList:          []net.IPNet{finance},
+			AllowedProtocol: models.ALL,
+			Dst:             target,
+			Allowed:         true,
+			Priority:        30,
+		},
+		"allow-finance-first": {
+			ID:              "allow-finance-first",
+			IPList:          []net.IPNet{finance},
+			AllowedProtocol: models.TCP,
+			Dst:             target,
+			Allowed:         true,
+			Priority:        5,
+		},
+	}
+	rules = ApplyAclRulePriorities(rules)
+	// deny rules are never sent to clients
+	for _, rule := range rules {
+		assert.True(t, rule.Allowed, rule.ID)
+	}
+	assert.NotContains(t, rules, "deny-finance")
+	assert.NotContains(t, rules, "deny-ssh")
+	// sources denied for all traffic are removed from later allow rules
+	assert.NotContains(t, rules, "allow-finance")
+	// rules evaluated before the deny are kept as is
+	assert.Equal(t, []net.IPNet{finance}, rules["allow-finance-first"].IPList)
+	// the partial deny is carved out of the allow rule for all traffic
+	assert.Equal(t, models.AclRule{
+		ID:              "allow-all",
+		IPList:          []net.IPNet{other},
+		IP6List:         []net.IPNet{},
+		AllowedProtocol: models.TCP,
+		AllowedPorts:    []string{"1-21", "23-65535"},
+		Dst:             target,
+		Dst6:            []net.IPNet{},
+		Allowed:         true,
+		Priority:        20,
+	}, rules["allow-all"])
+	assert.Equal(t, models.UDP, rules["allow-all-1"].AllowedProtocol)
+	assert.Empty(t, rules["allow-all-1"].AllowedPorts)
+	assert.Equal(t, models.ICMP, rules["allow-all-2"].AllowedProtocol)
+	assert.Len(t, rules, 4)
+}
+
+func TestApplyAclRulePrioritiesPartialDeny(t *testing.T) {
+	mustCIDR := func(s string) net.IPNet {
+		_, cidr, err := net.ParseCIDR(s)
+		assert.NoError(t, err)
+		return *cidr
+	}
+	target := []net.IPNet{mustCIDR("100.64.0.1/32")}
+	host := mustCIDR("100.64.0.2/32")
+	cases := []struct {
+		name  string
+		deny  models.AclRule
+		allow models.AclRule
+		want  []models.AclRule
+	}{
+		{
+			name: "PortDenyAboveAllowAll",
+			deny: models.AclRule{AllowedProtocol: models.TCP, AllowedPorts: []string{"22", "8000-9000"}},
+			allow: models.AclRule{
+				AllowedProtocol: models.ALL,
+			},
+			want: []models.AclRule{
+				{AllowedProtocol: models.TCP, AllowedPorts: []string{"1-21", "23-7999", "9001-65535"}},
+				{AllowedProtocol: models.UDP, AllowedPorts: []string{}},
+				{AllowedProtocol: models.ICMP, AllowedPorts: []string{}},
+			},
+		},
+		{
+			name:  "PortDenyAbovePortAllow",
+			deny:  models.AclRule{AllowedProtocol: models.TCP, AllowedPorts: []string{"443"}},
+			allow: models.AclRule{AllowedProtocol: models.TCP, AllowedPorts: []string{"80", "440-450"}},
+			want: []models.AclRule{
+				{AllowedProtocol: models.TCP, AllowedPorts: []string{"80", "440-442", "444-450"}},
+			},
+		},
+		{
+			name:  "DisjointPorts",
+			deny:  models.AclRule{AllowedProtocol: models.TCP, AllowedPorts: []string{"22"}},
+			allow: models.AclRule{AllowedProtocol: models.TCP, AllowedPorts: []string{"443"}},
+			want: []models.AclRule{
+				{AllowedProtocol: models.TCP, AllowedPorts: []string{"443"}},
+			},
+		},
+		{
+			name:  "OtherProtocol",
+			deny:  models.AclRule{AllowedProtocol: models.UDP},
+			allow: models.AclRule{AllowedProtocol: models.TCP, AllowedPorts: []string{"443"}},
+			want: []models.AclRule{
+				{AllowedProtocol: models.TCP, AllowedPorts: []string{"443"}},
+			},
+		},
+		{
+			name:  "ProtocolDeny",
+			deny:  models.AclRule{AllowedProtocol: models.TCP},
+			allow: models.AclRule{AllowedProtocol: models.TCP, AllowedPorts: []string{"443"}},
+			want:  []models.AclRule{},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.deny.ID, tc.deny.Priority = "deny", 10
+			tc.deny.IPList, tc.deny.Dst = []net.IPNet{host}, target
+			tc.allow.ID, tc.allow.Priority, tc.allow.Allowed = "allow", 20, true
+			tc.allow.IPList, tc.allow.Dst = []net.IPNet{host}, target
+			rules := ApplyAclRulePriorities(map[string]models.AclRule{
+				"deny":  tc.deny,
+				"allow": tc.allow,
+			})
+			assert.Len(t, rules, len(tc.want))
+			for i, want := range tc.want {
+				id := "allow"
+				if i > 0 {
+					id = fmt.Sprintf("allow-%d", i)
+				}
+				rule, ok := rules[id]
+				if !assert.True(t, ok, id) {
+					continue
+				}
+				assert.True(t, rule.Allowed)
+				assert.Equal(t, []net.IPNet{host}, rule.IPList)
+				assert.Equal(t, want.AllowedProtocol, rule.AllowedProtocol, id)
+				assert.Equal(t, want.AllowedPorts, rule.AllowedPorts, id)
+			}
+		})
+	}
+}
+
+func TestApplyAclRulePrioritiesCIDRDeny(t *testing.T) {
+	mustCIDR := func(s string) net.IPNet {
+		_, cidr, err := net.ParseCIDR(s)
+		assert.NoError(t, err)
+		return *cidr
+	}
+	target := []net.IPNet{mustCIDR("100.64.0.1/32")}
+	inside := net.IPNet{IP: net.ParseIP("10.10.0.5"), Mask: net.CIDRMask(32, 32)}
+	outside := mustCIDR("100.64.0.3/32")
+
+	t.Run("HostSources", func(t *testing.T) {
+		rules := ApplyAclRulePriorities(map[string]models.AclRule{
+			"deny": {
+				ID:              "deny",
+				IPList:          []net.IPNet{mustCIDR("10.10.0.0/16")},
+				AllowedProtocol: models.ALL,
+				Dst:             target,
+				Priority:        10,
+			},
+			"allow": {
+				ID:              "allow",
+				IPList:          []net.IPNet{inside, outside},
+				AllowedProtocol: models.ALL,
+				Dst:             target,
+				Allowed:         true,
+				Priority:        20,
+			},
+		})
+		assert.Len(t, rules, 1)
+		assert.Equal(t, []net.IPNet{outside}, rules["allow"].IPList)
+	})
+
+	t.Run("RangeSource", func(t *testing.T) {
+		rules := ApplyAclRulePriorities(map[string]models.AclRule{
+			"deny": {
+				ID:              "deny",
+				IPList:          []net.IPNet{inside},
+				AllowedProtocol: models.ALL,
+				Dst:             target,
+				Priority:        10,
+			},
+			"allow": {
+				ID:              "allow",
+				IPList:          []net.IPNet{mustCIDR("10.10.0.0/29")},
+				AllowedProtocol: models.ALL,
+				Dst:             target,
+				Allowed:         true,
+				Priority:        20,
+			},
+		})
+		assert.Len(t, rules, 1)
+		sources := []string{}
+		for _, ipNet := range rules["allow"].IPList {
+			sources = append(sources, ipNet.String())
+		}
+		assert.ElementsMatch(t, []string{"10.10.0.0/30", "10.10.0.4/32", "10.10.0.6/31"}, sources)
+	})
+
+	t.Run("OtherDestination", func(t *testing.T) {
+		egress := mustCIDR("192.168.1.0/24")
+		rules := ApplyAclRulePriorities(map[string]models.AclRule{
+			"deny": {
+				ID:              "deny",
+				IPList:          []net.IPNet{inside},
+				AllowedProtocol: models.ALL,
+				Dst:             []net.IPNet{egress},
+				Priority:        10,
+			},
+			"allow": {
+				ID:              "allow",
+				IPList:          []net.IPNet{inside, outside},
+				AllowedProtocol: models.ALL,
+				Dst:             append([]net.IPNet{egress}, target...),
+				Allowed:         true,
+				Priority:        20,
+			},
+		})
+		assert.Len(t, rules, 2)
+		assert.Equal(t, []net.IPNet{outside}, rules["allow"].IPList)
+		assert.Equal(t, []net.IPNet{inside}, rules["allow-1"].IPList)
+		assert.Equal(t, target, rules["allow-1"].Dst)
+	})
+}
+
+func TestFullDenyDirection(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	database.InitializeDatabase()
+	ctx := db.WithContext(context.TODO())
+
+	const netID schema.NetworkID = "acl-deny"
+	network := &schema.Network{Name: netID.String(), AddressRange: "100.65.0.0/16"}
+	require.NoError(t, network.Create(ctx))
+	defer network.Delete(ctx)
+
+	newNode := func(ip string) models.Node {
+		node := models.Node{
+			CommonNode: models.CommonNode{
+				ID:      uuid.New(),
+				Network: netID.String(),
+				Address: net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)},
+			},
+			Tags: map[models.TagID]struct{}{},
+		}
+		require.NoError(t, UpsertNode(&node))
+		return node
+	}
+	a := newNode("100.65.0.1")
+	defer DeleteNodeByID(&a)
+	b := newNode("100.65.0.2")
+	defer DeleteNodeByID(&b)
+
+	node := func(n models.Node) []models.AclPolicyTag {
+		return []models.AclPolicyTag{{ID: models.NodeID, Value: n.ID.String()}}
+	}
+	all := []models.AclPolicyTag{{ID: models.NodeTagID, Value: "*"}}
+	allow := models.Acl{
+		ID:               "allow-a-b",
+		Name:             "allow a b",
+		NetworkID:        netID,
+		RuleType:         models.DevicePolicy,
+		Src:              node(a),
+		Dst:              node(b),
+		Proto:            models.ALL,
+		AllowedDirection: models.TrafficDirectionBi,
+		Enabled:          true,
+		Priority:         20,
+	}
+	require.NoError(t, InsertAcl(allow))
+	defer DeleteAcl(allow)
+
+	tests := []struct {
+		name string
+		deny models.Acl
+		// aToB, bToA - IsNodeAllowedToCommunicate in either direction
+		aToB, bToA bool
+		// peers - IsPeerAllowed, which doesn't depend on the order
+		peers bool
+	}{
+		{
+			name:  "one-way full deny",
+			deny:  models.Acl{Src: node(a), Dst: node(b), Proto: models.ALL},
+			bToA:  true,
+			peers: true,
+		},
+		{
+			name: "one-way full deny matching both ways",
+			deny: models.Acl{Src: all, Dst: all, Proto: models.ALL},
+		},
+		{
+			name: "two-way full deny",
+			deny: models.Acl{Src: node(a), Dst: node(b), Proto: models.ALL, AllowedDirection: models.TrafficDirectionBi},
+		},
+		{
+			name:  "one-way partial deny",
+			deny:  models.Acl{Src: node(a), Dst: node(b), Proto: models.TCP, Port: []string{"22"}},
+			aToB:  true,
+			bToA:  true,
+			peers: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deny := tt.deny
+			deny.ID = "deny-a-b"
+			deny.Name = "deny a b"
+			deny.NetworkID = netID
+			deny.RuleType = models.DevicePolicy
+			deny.Action = models.AclActionDeny
+			deny.Enabled = true
+			deny.Priority = 10
+			require.NoError(t, InsertAcl(deny))
+			defer DeleteAcl(deny)
+
+			allowed, _ := IsNodeAllowedToCommunicate(a, b, false)
+			assert.Equal(t, tt.aToB, allowed, "a to b")
+			allowed, _ = IsNodeAllowedToCommunicate(b, a, false)
+			assert.Equal(t, tt.bToA, allowed, "b to a")
+			assert.Equal(t, tt.peers, IsPeerAllowed(a, b, false), "peers a, b")
+			assert.Equal(t, tt.peers, IsPeerAllowed(b, a, false), "peers b, a")
+		})
+	}
+}
@@ -37,6 +37,15 @@ var IsOAuthConfigured = func() bool { return false }
 var ResetAuthProvider = func() {}
 var ResetIDPSyncHook = func() {}
 
+// IsLDAPLoginEnabled - whether users of the IDP sign in with their LDAP password
+var IsLDAPLoginEnabled = func() bool { return false }
+
+// VerifyLDAPCredentials - verifies the password of a user against the LDAP
+// directory, the user is created or linked on the first successful login
+var VerifyLDAPCredentials = func(username, password string) (*schema.User, error) {
+	return nil, errors.New("ldap login is not configured")
+}
+
 // HasSuperAdmin - checks if server has an superadmin/owner
 func HasSuperAdmin() (bool, error) {
 	return (&schema.User{}).SuperAdminExists(db.WithContext(context.TODO()))
@@ -171,18 +180,29 @@ func VerifyAuthRequest(authRequest models.UserAuthParams, appName string) (strin
 	}
 	err := _user.Get(db.WithContext(context.TODO()))
 	if err != nil {
-		return "", errors.New("incorrect credentials")
-	}
-
-	// compare password from request to stored password in database
-	// might be able to have a common hash (certificates?) and compare those so that a password isn't passed in in plain text...
-	// TODO: Consider a way of hashing the password client side before sending, or using certificates
-	if err = bcrypt.CompareHashAndPassword([]byte(_user.Password), []byte(authRequest.Password)); err != nil {
-		return "", errors.New("incorrect credentials")
+		if !IsLDAPLoginEnabled() {
+			return "", errors.New("incorrect credentials")
+		}
+		// directory users are created on their first login
+		_user, err = VerifyLDAPCredentials(authRequest.UserName, authRequest.Password)
+		if err != nil {
+			return "", errors.New("incorrect credentials")
+		}
+	} else if err = bcrypt.CompareHashAndPassword([]byte(_user.Password), []byte(authRequest.Password)); err != nil {
+		// compare password from request to stored password in database
+		// might be able to have a common hash (certificates?) and compare those so that a password isn't passed in in plain text...
+		// TODO: Consider a way of hashing the password client side before sending, or using certificates
+		if _user.AuthType != schema.OAuth || !IsLDAPLoginEnabled() {
+			return "", errors.New("incorrect credentials")
+		}
+		_user, err = VerifyLDAPCredentials(authRequest.UserName, authRequest.Password)
+		if err != nil {
+			return "", errors.New("incorrect credentials")
+		}
 	}
 
 	if _user.IsMFAEnabled {
-		tokenString, err := CreatePreAuthToken(authRequest.UserName)
+		tokenString, err := CreatePreAuthToken(_user.Username)
 		if err != nil {
 			slog.Error("error creating jwt", "error", err)
 			return "", err
@@ -191,7 +211,7 @@ func VerifyAuthRequest(authRequest models.UserAuthParams, appName string) (strin
 		return tokenString, nil
 	} else {
 		// Create a new JWT for the node
-		tokenString, err := CreateUserJWT(authRequest.UserName, schema.UserRoleID(_user.PlatformRoleID), appName)
+		tokenString, err := CreateUserJWT(_user.Username, schema.UserRoleID(_user.PlatformRoleID), appName)
 		if err != nil {
 			slog.Error("error creating jwt", "error", err)
 			return "", err
@@ -378,6 +398,13 @@ func UpdateUser(userchange, _user *schema.User) (*schema.User, error) {
 		if err != nil {
 			return &schema.User{}, err
 		}
+
+		if !_user.IsMFAEnabled {
+			err = (&schema.UserWebAuthnCredential{UserName: queryUser}).DeleteAllUserCredentials(dbctx)
+			if err != nil {
+				return &schema.User{}, err
+			}
+		}
 	}
 
 	commit = true
@@ -452,6 +479,11 @@ func DeleteUser(user string) error {
 	}
 
 	RemoveUserFromAclPolicy(user)
+	err = (&schema.UserWebAuthnCredential{UserName: user}).DeleteAllUserCredentials(db.WithContext(context.TODO()))
+	if err != nil {
+		return err
+	}
+
 	return (&schema.UserAccessToken{UserName: user}).DeleteAllUserTokens(db.WithContext(context.TODO()))
 }
 
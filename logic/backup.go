@@ -0,0 +1,413 @@
+package logic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+	"github.com/hashicorp/go-version"
+	"golang.org/x/crypto/scrypt"
+	"gorm.io/gorm"
+)
+
+// BackupVersion - version of the backup archive format. Version 2 records the
+// migration version of the database.
+const BackupVersion = 2
+
+// backupEncryptedMagic - prefix of encrypted archives, followed by the
+// scrypt salt, the AES-GCM nonce and the sealed gzip stream
+var backupEncryptedMagic = []byte("NMBKENC1")
+
+const backupSaltSize = 16
+
+var (
+	// ErrBackupPassphraseRequired - the archive is encrypted and no passphrase was provided
+	ErrBackupPassphraseRequired = errors.New("backup is encrypted, a passphrase is required")
+	// ErrBackupServerNotEmpty - restores are only allowed into a server without networks and hosts
+	ErrBackupServerNotEmpty = errors.New("restore requires an empty server without networks or hosts")
+	// ErrBackupMigrationMismatch - the archive was created from a database at
+	// another migration version than the one of the server
+	ErrBackupMigrationMismatch = errors.New("backup was created at another migration version")
+	// ErrBackupRestoreUnsupported - the key/value tables of rqlite don't share
+	// the connection of the schema tables, a restore couldn't be rolled back
+	ErrBackupRestoreUnsupported = errors.New("restore is not supported on the rqlite database backend")
+)
+
+// CreateBackup - writes an archive of all key/value tables and schema tables.
+// The archive is encrypted when a passphrase is provided.
+func CreateBackup(w io.Writer, passphrase string) (models.BackupSummary, error) {
+	ctx := db.WithContext(context.TODO())
+	migrationVersion, err := backupMigrationVersion(ctx)
+	if err != nil {
+		return models.BackupSummary{}, err
+	}
+	backup := models.Backup{
+		Version:          BackupVersion,
+		ServerVersion:    servercfg.GetVersion(),
+		Database:         servercfg.GetDB(),
+		MigrationVersion: migrationVersion,
+		CreatedAt:        time.Now().UTC(),
+		KV:               make(map[string]map[string]string),
+		Models:           make(map[string]json.RawMessage),
+	}
+	for _, table := range database.Tables {
+		records, err := database.FetchRecords(table)
+		if err != nil && !database.IsEmptyRecord(err) {
+			return models.BackupSummary{}, fmt.Errorf("failed to read table %s: %w", table, err)
+		}
+		if records == nil {
+			records = make(map[string]string)
+		}
+		backup.KV[table] = records
+	}
+	for _, model := range schema.ListModels() {
+		table, err := backupModelTable(ctx, model)
+		if err != nil {
+			return models.BackupSummary{}, err
+		}
+		rows := reflect.New(reflect.SliceOf(reflect.TypeOf(model).Elem()))
+		if err := db.FromContext(ctx).Model(model).Find(rows.Interface()).Error; err != nil {
+			return models.BackupSummary{}, fmt.Errorf("failed to read table %s: %w", table, err)
+		}
+		data, err := json.Marshal(rows.Interface())
+		if err != nil {
+			return models.BackupSummary{}, err
+		}
+		backup.Models[table] = data
+	}
+	var archive bytes.Buffer
+	zw := gzip.NewWriter(&archive)
+	if err := json.NewEncoder(zw).Encode(backup); err != nil {
+		return models.BackupSummary{}, err
+	}
+	if err := zw.Close(); err != nil {
+		return models.BackupSummary{}, err
+	}
+	data := archive.Bytes()
+	if passphrase != "" {
+		var err error
+		if data, err = encryptBackup(data, passphrase); err != nil {
+			return models.BackupSummary{}, err
+		}
+	}
+	if _, err := w.Write(data); err != nil {
+		return models.BackupSummary{}, err
+	}
+	return summarizeBackup(&backup, passphrase != ""), nil
+}
+
+// RestoreBackup - validates an archive and replays it into an empty server at
+// the same migration version. The archive may come from a server using a
+// different database backend, restoring into rqlite is not supported.
+func RestoreBackup(archive []byte, passphrase string) (models.BackupSummary, error) {
+	if servercfg.GetDB() == "rqlite" {
+		return models.BackupSummary{}, ErrBackupRestoreUnsupported
+	}
+	backup, encrypted, err := ReadBackup(archive, passphrase)
+	if err != nil {
+		return models.BackupSummary{}, err
+	}
+	ctx := db.WithContext(context.TODO())
+	migrationVersion, err := backupMigrationVersion(ctx)
+	if err != nil {
+		return models.BackupSummary{}, err
+	}
+	if backup.MigrationVersion != migrationVersion {
+		return models.BackupSummary{}, fmt.Errorf("%w: backup at version %d, database at version %d",
+			ErrBackupMigrationMismatch, backup.MigrationVersion, migrationVersion)
+	}
+	tables := make(map[string]interface{})
+	for _, model := range schema.ListModels() {
+		table, err := backupModelTable(ctx, model)
+		if err != nil {
+			return models.BackupSummary{}, err
+		}
+		tables[table] = model
+	}
+	for table := range backup.Models {
+		if _, ok := tables[table]; !ok {
+			return models.BackupSummary{}, fmt.Errorf("backup contains unknown table %s, it was created by a newer server", table)
+		}
+	}
+	if err := checkServerIsEmpty(ctx); err != nil {
+		return models.BackupSummary{}, err
+	}
+
+	// the key/value tables share the connection with the schema tables, they
+	// are restored in the same transaction so a failed restore changes nothing
+	dbctx := db.BeginTx(ctx)
+	if err := restoreBackupTables(dbctx, backup, tables); err != nil {
+		db.FromContext(dbctx).Rollback()
+		return models.BackupSummary{}, err
+	}
+	if err := db.FromContext(dbctx).Commit().Error; err != nil {
+		return models.BackupSummary{}, err
+	}
+	resetCachesAfterRestore()
+	logger.Log(0, "restored backup created at", backup.CreatedAt.String(), "by server version", backup.ServerVersion)
+	return summarizeBackup(backup, encrypted), nil
+}
+
+// restoreBackupTables - replaces the key/value and schema tables with the
+// records of the archive using the transaction of ctx
+func restoreBackupTables(ctx context.Context, backup *models.Backup, tables map[string]interface{}) error {
+	for _, table := range database.Tables {
+		if err := database.DeleteAllRecordsTx(ctx, table); err != nil {
+			return fmt.Errorf("failed to clear table %s: %w", table, err)
+		}
+		for key, value := range backup.KV[table] {
+			if err := database.InsertTx(ctx, key, value, table); err != nil {
+				return fmt.Errorf("failed to restore table %s: %w", table, err)
+			}
+		}
+	}
+	tx := db.FromContext(ctx)
+	for table, model := range tables {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(model).Error; err != nil {
+			return fmt.Errorf("failed to clear table %s: %w", table, err)
+		}
+		data, ok := backup.Models[table]
+		if !ok {
+			continue
+		}
+		rows := reflect.New(reflect.SliceOf(reflect.TypeOf(model).Elem()))
+		if err := json.Unmarshal(data, rows.Interface()); err != nil {
+			return fmt.Errorf("invalid rows for table %s: %w", table, err)
+		}
+		if rows.Elem().Len() == 0 {
+			continue
+		}
+		// select all columns so zero values are not replaced by column defaults
+		if err := tx.Model(model).Select("*").CreateInBatches(rows.Interface(), 100).Error; err != nil {
+			return fmt.Errorf("failed to restore table %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// ReadBackup - decrypts and decodes an archive and validates its version
+func ReadBackup(archive []byte, passphrase string) (*models.Backup, bool, error) {
+	encrypted := bytes.HasPrefix(archive, backupEncryptedMagic)
+	if encrypted {
+		if passphrase == "" {
+			return nil, true, ErrBackupPassphraseRequired
+		}
+		var err error
+		if archive, err = decryptBackup(archive, passphrase); err != nil {
+			return nil, true, err
+		}
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, encrypted, fmt.Errorf("invalid backup archive: %w", err)
+	}
+	defer zr.Close()
+	backup := &models.Backup{}
+	if err := json.NewDecoder(zr).Decode(backup); err != nil {
+		return nil, encrypted, fmt.Errorf("invalid backup archive: %w", err)
+	}
+	if backup.Version < 1 || backup.Version > BackupVersion {
+		return nil, encrypted, fmt.Errorf("unsupported backup version %d", backup.Version)
+	}
+	if backup.Version == 1 {
+		// version 1 archives hold the migration records with the schema tables
+		var records []schema.SchemaMigration
+		table := (&schema.SchemaMigration{}).TableName()
+		if data, ok := backup.Models[table]; ok {
+			if err := json.Unmarshal(data, &records); err != nil {
+				return nil, encrypted, fmt.Errorf("invalid rows for table %s: %w", table, err)
+			}
+		}
+		backup.MigrationVersion = maxMigrationVersion(records)
+	}
+	if isNewerServerVersion(backup.ServerVersion, servercfg.GetVersion()) {
+		return nil, encrypted, fmt.Errorf("backup was created by server version %s, which is newer than %s",
+			backup.ServerVersion, servercfg.GetVersion())
+	}
+	return backup, encrypted, nil
+}
+
+// isNewerServerVersion - checks if a is a newer release than b, dev builds are never newer
+func isNewerServerVersion(a, b string) bool {
+	if a == "dev" || b == "dev" {
+		return false
+	}
+	va, err := version.NewVersion(CleanVersion(a))
+	if err != nil {
+		return false
+	}
+	vb, err := version.NewVersion(CleanVersion(b))
+	if err != nil {
+		return false
+	}
+	return va.GreaterThan(vb)
+}
+
+// backupMigrationVersion - the highest migration version applied to the database
+func backupMigrationVersion(ctx context.Context) (int, error) {
+	records, err := (&schema.SchemaMigration{}).ListAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return maxMigrationVersion(records), nil
+}
+
+func maxMigrationVersion(records []schema.SchemaMigration) int {
+	version := 0
+	for _, record := range records {
+		if record.Version > version {
+			version = record.Version
+		}
+	}
+	return version
+}
+
+// checkServerIsEmpty - a server is empty as long as no network was created
+// and no host registered, the users of a fresh server are replaced
+func checkServerIsEmpty(ctx context.Context) error {
+	networks, err := (&schema.Network{}).Count(ctx)
+	if err != nil {
+		return err
+	}
+	hosts, err := (&schema.Host{}).Count(ctx)
+	if err != nil {
+		return err
+	}
+	nodes, err := database.FetchRecords(database.NODES_TABLE_NAME)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	if networks > 0 || hosts > 0 || len(nodes) > 0 {
+		return ErrBackupServerNotEmpty
+	}
+	return nil
+}
+
+// backupModelTable - table name of a schema model
+func backupModelTable(ctx context.Context, model interface{}) (string, error) {
+	stmt := &gorm.Statement{DB: db.FromContext(ctx)}
+	if err := stmt.Parse(model); err != nil {
+		return "", err
+	}
+	return stmt.Schema.Table, nil
+}
+
+func summarizeBackup(backup *models.Backup, encrypted bool) models.BackupSummary {
+	summary := models.BackupSummary{
+		Version:          backup.Version,
+		ServerVersion:    backup.ServerVersion,
+		Database:         backup.Database,
+		MigrationVersion: backup.MigrationVersion,
+		CreatedAt:        backup.CreatedAt,
+		Encrypted:        encrypted,
+		Records:          make(map[string]int),
+	}
+	for table, records := range backup.KV {
+		summary.Records[table] = len(records)
+	}
+	for table, data := range backup.Models {
+		var rows []json.RawMessage
+		_ = json.Unmarshal(data, &rows)
+		summary.Records[table] = len(rows)
+	}
+	return summary
+}
+
+// resetCachesAfterRestore - drops the in-memory state so it is reloaded from
+// the restored tables
+func resetCachesAfterRestore() {
+	ClearNodeCache()
+	aclCacheMutex.Lock()
+	aclCacheMap = make(map[string]models.Acl)
+	aclCacheMutex.Unlock()
+	enrollmentkeyCacheMutex.Lock()
+	enrollmentkeyCacheMap = make(map[string]models.EnrollmentKey)
+	enrollmentkeyCacheMutex.Unlock()
+	extClientCacheMutex.Lock()
+	extClientCacheMap = make(map[string]models.ExtClient)
+	extClientCacheMutex.Unlock()
+	InvalidateServerSettingsCache()
+	InvalidateHostPeerCaches()
+	ClearAllocatedIpMap()
+	if err := SetAllocatedIpMap(); err != nil {
+		logger.Log(0, "failed to reload allocated ips after restore:", err.Error())
+	}
+	InvalidateAllCaches()
+}
+
+func backupKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+// encryptBackup - seals the archive with AES-256-GCM using a key derived from the passphrase
+func encryptBackup(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, backupSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := backupKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte{}, backupEncryptedMagic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, data, backupEncryptedMagic), nil
+}
+
+// decryptBackup - opens an archive sealed by encryptBackup
+func decryptBackup(data []byte, passphrase string) ([]byte, error) {
+	data = data[len(backupEncryptedMagic):]
+	if len(data) < backupSaltSize {
+		return nil, errors.New("invalid encrypted backup")
+	}
+	salt := data[:backupSaltSize]
+	key, err := backupKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	data = data[backupSaltSize:]
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("invalid encrypted backup")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], backupEncryptedMagic)
+	if err != nil {
+		return nil, errors.New("failed to decrypt backup, wrong passphrase")
+	}
+	return plain, nil
+}
@@ -0,0 +1,195 @@
+package logic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/matryer/is"
+)
+
+func TestBackupEncryption(t *testing.T) {
+	is := is.New(t)
+	data := []byte("netmaker backup")
+	sealed, err := encryptBackup(data, "secret")
+	is.NoErr(err)
+	is.True(bytes.HasPrefix(sealed, backupEncryptedMagic))
+	t.Run("right passphrase", func(t *testing.T) {
+		is := is.New(t)
+		plain, err := decryptBackup(sealed, "secret")
+		is.NoErr(err)
+		is.Equal(plain, data)
+	})
+	t.Run("wrong passphrase", func(t *testing.T) {
+		is := is.New(t)
+		_, err := decryptBackup(sealed, "wrong")
+		is.True(err != nil)
+	})
+	t.Run("truncated", func(t *testing.T) {
+		is := is.New(t)
+		_, err := decryptBackup(sealed[:len(backupEncryptedMagic)+4], "secret")
+		is.True(err != nil)
+	})
+}
+
+func TestCreateBackup(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	database.InitializeDatabase()
+
+	t.Run("plain", func(t *testing.T) {
+		is := is.New(t)
+		var archive bytes.Buffer
+		summary, err := CreateBackup(&archive, "")
+		is.NoErr(err)
+		is.True(!summary.Encrypted)
+		backup, encrypted, err := ReadBackup(archive.Bytes(), "")
+		is.NoErr(err)
+		is.True(!encrypted)
+		is.Equal(backup.Version, BackupVersion)
+		for _, table := range database.Tables {
+			_, ok := backup.KV[table]
+			is.True(ok)
+		}
+		is.Equal(len(backup.Models), len(schema.ListModels()))
+	})
+	t.Run("encrypted", func(t *testing.T) {
+		is := is.New(t)
+		var archive bytes.Buffer
+		summary, err := CreateBackup(&archive, "secret")
+		is.NoErr(err)
+		is.True(summary.Encrypted)
+		_, _, err = ReadBackup(archive.Bytes(), "")
+		is.Equal(err, ErrBackupPassphraseRequired)
+		_, encrypted, err := ReadBackup(archive.Bytes(), "secret")
+		is.NoErr(err)
+		is.True(encrypted)
+	})
+	t.Run("restore into server with hosts", func(t *testing.T) {
+		is := is.New(t)
+		var archive bytes.Buffer
+		_, err := CreateBackup(&archive, "")
+		is.NoErr(err)
+		h := schema.Host{
+			ID:         uuid.New(),
+			EndpointIP: net.ParseIP("192.168.1.1"),
+			ListenPort: 51821,
+		}
+		is.NoErr(h.Create(db.WithContext(context.TODO())))
+		defer h.Delete(db.WithContext(context.TODO()))
+		_, err = RestoreBackup(archive.Bytes(), "")
+		is.Equal(err, ErrBackupServerNotEmpty)
+	})
+	t.Run("restore at another migration version", func(t *testing.T) {
+		is := is.New(t)
+		var archive bytes.Buffer
+		_, err := CreateBackup(&archive, "")
+		is.NoErr(err)
+		backup, _, err := ReadBackup(archive.Bytes(), "")
+		is.NoErr(err)
+		backup.MigrationVersion++
+		_, err = RestoreBackup(backupArchive(*backup), "")
+		is.True(errors.Is(err, ErrBackupMigrationMismatch))
+	})
+}
+
+// backupArchive - encodes an unencrypted archive
+func backupArchive(backup models.Backup) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_ = json.NewEncoder(zw).Encode(backup)
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+func TestReadBackupVersion(t *testing.T) {
+	archive := backupArchive
+	t.Run("newer format", func(t *testing.T) {
+		is := is.New(t)
+		_, _, err := ReadBackup(archive(models.Backup{Version: BackupVersion + 1, ServerVersion: "dev"}), "")
+		is.True(err != nil)
+	})
+	t.Run("newer server", func(t *testing.T) {
+		is := is.New(t)
+		is.True(isNewerServerVersion("v1.2.0", "v1.1.0"))
+		is.True(!isNewerServerVersion("v1.1.0", "v1.2.0"))
+		is.True(!isNewerServerVersion("v9.0.0", "dev"))
+	})
+	t.Run("version 1 migration records", func(t *testing.T) {
+		is := is.New(t)
+		backup, _, err := ReadBackup(archive(models.Backup{
+			Version:       1,
+			ServerVersion: "dev",
+			Models: map[string]json.RawMessage{
+				"schema_migrations": json.RawMessage(`[{"version":3},{"version":7},{"version":5}]`),
+			},
+		}), "")
+		is.NoErr(err)
+		is.Equal(backup.MigrationVersion, 7)
+	})
+	t.Run("not an archive", func(t *testing.T) {
+		is := is.New(t)
+		_, _, err := ReadBackup([]byte("not a backup"), "")
+		is.True(err != nil)
+	})
+}
+
+func TestRestoreBackup(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	database.InitializeDatabase()
+	if err := checkServerIsEmpty(db.WithContext(context.TODO())); err != nil {
+		t.Skip("server is not empty")
+	}
+	is := is.New(t)
+	var archive bytes.Buffer
+	created, err := CreateBackup(&archive, "secret")
+	is.NoErr(err)
+	restored, err := RestoreBackup(archive.Bytes(), "secret")
+	is.NoErr(err)
+	is.Equal(restored.Records, created.Records)
+	var again bytes.Buffer
+	after, err := CreateBackup(&again, "")
+	is.NoErr(err)
+	is.Equal(after.Records, created.Records)
+}
+
+func TestRestoreBackupRqlite(t *testing.T) {
+	is := is.New(t)
+	t.Setenv("DATABASE", "rqlite")
+	_, err := RestoreBackup([]byte("archive"), "")
+	is.True(errors.Is(err, ErrBackupRestoreUnsupported))
+}
+
+func TestRestoreBackupRollback(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	database.InitializeDatabase()
+	if err := checkServerIsEmpty(db.WithContext(context.TODO())); err != nil {
+		t.Skip("server is not empty")
+	}
+	is := is.New(t)
+	is.NoErr(database.Insert("restore-probe", "{}", database.ACLS_TABLE_NAME))
+	defer database.DeleteRecord(database.ACLS_TABLE_NAME, "restore-probe")
+	var archive bytes.Buffer
+	_, err := CreateBackup(&archive, "")
+	is.NoErr(err)
+	backup, _, err := ReadBackup(archive.Bytes(), "")
+	is.NoErr(err)
+	// the last table fails to restore after all others were cleared
+	backup.KV[database.ACLS_TABLE_NAME] = map[string]string{}
+	backup.KV[database.Tables[len(database.Tables)-1]] = map[string]string{"invalid": ""}
+	_, err = RestoreBackup(backupArchive(*backup), "")
+	is.True(err != nil)
+	_, err = database.FetchRecord(database.ACLS_TABLE_NAME, "restore-probe")
+	is.NoErr(err) // the key/value tables are rolled back with the schema tables
+}
@@ -0,0 +1,123 @@
+package logic
+
+import (
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// CacheKind - kind of objects held by an in-memory cache
+type CacheKind string
+
+const (
+	CacheKindSettings       CacheKind = "settings"
+	CacheKindNode           CacheKind = "node"
+	CacheKindExtClient      CacheKind = "extclient"
+	CacheKindEnrollmentKey  CacheKind = "enrollment_key"
+	CacheKindAcl            CacheKind = "acl"
+	CacheKindAllocatedIPs   CacheKind = "allocated_ips"
+	CacheKindHostPeerUpdate CacheKind = "host_peer_update"
+	CacheKindMetrics        CacheKind = "metrics"
+	CacheKindFailOver       CacheKind = "failover"
+	CacheKindAutoRelay      CacheKind = "auto_relay"
+)
+
+// CacheInvalidation - tells the other replicas that an object was changed
+// or deleted. An empty ID invalidates every object of the kind.
+type CacheInvalidation struct {
+	Kind CacheKind `json:"kind"`
+	ID   string    `json:"id,omitempty"`
+}
+
+// CacheHandler - keeps a cache in sync with changes made by other replicas.
+// Handlers only update the local cache, they must not invalidate again.
+type CacheHandler struct {
+	// Invalidate - drops or refreshes a single object, Reload is used if nil
+	Invalidate func(id string)
+	// Reload - drops and reloads the whole cache
+	Reload func()
+}
+
+// PublishCacheInvalidation is set by the mq package in HA mode to broadcast
+// cache invalidations to the other replicas. The callback avoids a circular
+// import (logic -> mq).
+var PublishCacheInvalidation func(inv CacheInvalidation)
+
+var (
+	cacheHandlersMutex sync.RWMutex
+	cacheHandlers      = make(map[CacheKind][]CacheHandler)
+)
+
+// RegisterCache - registers the handler of a cache with the invalidation bus
+func RegisterCache(kind CacheKind, handler CacheHandler) {
+	cacheHandlersMutex.Lock()
+	defer cacheHandlersMutex.Unlock()
+	cacheHandlers[kind] = append(cacheHandlers[kind], handler)
+}
+
+// InvalidateCache - notifies the other replicas that the object of the given
+// kind was changed locally
+func InvalidateCache(kind CacheKind, id string) {
+	if PublishCacheInvalidation != nil {
+		PublishCacheInvalidation(CacheInvalidation{Kind: kind, ID: id})
+	}
+}
+
+// InvalidateAllCaches - tells the other replicas to reload every cache
+func InvalidateAllCaches() {
+	cacheHandlersMutex.RLock()
+	kinds := make([]CacheKind, 0, len(cacheHandlers))
+	for kind := range cacheHandlers {
+		kinds = append(kinds, kind)
+	}
+	cacheHandlersMutex.RUnlock()
+	for _, kind := range kinds {
+		InvalidateCache(kind, "")
+	}
+}
+
+// ApplyCacheInvalidation - applies an invalidation received from another replica
+func ApplyCacheInvalidation(inv CacheInvalidation) {
+	cacheHandlersMutex.RLock()
+	handlers := cacheHandlers[inv.Kind]
+	cacheHandlersMutex.RUnlock()
+	if len(handlers) == 0 {
+		slog.Warn("no cache registered for invalidation", "kind", inv.Kind)
+		return
+	}
+	for _, h := range handlers {
+		if inv.ID == "" || h.Invalidate == nil {
+			if h.Reload != nil {
+				h.Reload()
+			}
+			continue
+		}
+		h.Invalidate(inv.ID)
+	}
+}
+
+// ReloadCaches - reloads every registered cache, used when invalidations may
+// have been missed
+func ReloadCaches() {
+	cacheHandlersMutex.RLock()
+	handlers := make([]CacheHandler, 0, len(cacheHandlers))
+	for _, hs := range cacheHandlers {
+		handlers = append(handlers, hs...)
+	}
+	cacheHandlersMutex.RUnlock()
+	for _, h := range handlers {
+		if h.Reload != nil {
+			h.Reload()
+		}
+	}
+}
+
+// RegisterCaches - registers the caches of the logic package
+func RegisterCaches() {
+	RegisterCache(CacheKindSettings, CacheHandler{Reload: reloadServerSettingsCache})
+	RegisterCache(CacheKindNode, CacheHandler{Invalidate: invalidateNodeCache, Reload: reloadNodeCache})
+	RegisterCache(CacheKindExtClient, CacheHandler{Invalidate: invalidateExtClientCache, Reload: reloadExtClientCache})
+	RegisterCache(CacheKindEnrollmentKey, CacheHandler{Invalidate: invalidateEnrollmentKeyCache, Reload: reloadEnrollmentKeyCache})
+	RegisterCache(CacheKindAcl, CacheHandler{Invalidate: invalidateAclCache, Reload: reloadAclCache})
+	RegisterCache(CacheKindAllocatedIPs, CacheHandler{Invalidate: invalidateAllocatedIps, Reload: reloadAllocatedIps})
+}
@@ -0,0 +1,50 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestApplyCacheInvalidation(t *testing.T) {
+	is := is.New(t)
+	const kind CacheKind = "test_apply"
+	var invalidated []string
+	reloads := 0
+	RegisterCache(kind, CacheHandler{
+		Invalidate: func(id string) { invalidated = append(invalidated, id) },
+		Reload:     func() { reloads++ },
+	})
+	const reloadOnly CacheKind = "test_reload_only"
+	reloadOnlyCount := 0
+	RegisterCache(reloadOnly, CacheHandler{Reload: func() { reloadOnlyCount++ }})
+
+	t.Run("object", func(t *testing.T) {
+		ApplyCacheInvalidation(CacheInvalidation{Kind: kind, ID: "a"})
+		is.Equal(invalidated, []string{"a"})
+		is.Equal(reloads, 0)
+	})
+	t.Run("whole kind", func(t *testing.T) {
+		ApplyCacheInvalidation(CacheInvalidation{Kind: kind})
+		is.Equal(invalidated, []string{"a"})
+		is.Equal(reloads, 1)
+	})
+	t.Run("kind without object invalidation", func(t *testing.T) {
+		ApplyCacheInvalidation(CacheInvalidation{Kind: reloadOnly, ID: "b"})
+		is.Equal(reloadOnlyCount, 1)
+	})
+	t.Run("unknown kind", func(t *testing.T) {
+		ApplyCacheInvalidation(CacheInvalidation{Kind: "unknown", ID: "c"})
+		is.Equal(reloads, 1)
+	})
+}
+
+func TestInvalidateCache(t *testing.T) {
+	is := is.New(t)
+	var published []CacheInvalidation
+	PublishCacheInvalidation = func(inv CacheInvalidation) { published = append(published, inv) }
+	t.Cleanup(func() { PublishCacheInvalidation = nil })
+
+	InvalidateCache(CacheKindNode, "node-1")
+	is.Equal(published, []CacheInvalidation{{Kind: CacheKindNode, ID: "node-1"}})
+}
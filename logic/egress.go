@@ -56,7 +56,7 @@ func DoesUserHaveAccessToEgress(user *schema.User, e *schema.Egress, acls []mode
 		return false
 	}
 	for _, acl := range acls {
-		if !acl.Enabled {
+		if !IsAclActive(acl) {
 			continue
 		}
 		dstTags := ConvAclTagToValueMap(acl.Dst)
@@ -84,7 +84,7 @@ func DoesNodeHaveAccessToEgress(node *models.Node, e *schema.Egress, acls []mode
 	nodeTags[models.TagID(node.ID.String())] = struct{}{}
 	nodeTags[models.TagID("*")] = struct{}{}
 	for _, acl := range acls {
-		if !acl.Enabled {
+		if !IsAclActive(acl) {
 			continue
 		}
 		srcVal := ConvAclTagToValueMap(acl.Src)
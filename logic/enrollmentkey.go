@@ -180,6 +180,7 @@ func DeleteEnrollmentKey(value string, force bool) error {
 		if servercfg.CacheEnabled() {
 			deleteEnrollmentkeyFromCache(value)
 		}
+		InvalidateCache(CacheKindEnrollmentKey, value)
 	}
 	return err
 }
@@ -272,6 +273,7 @@ func upsertEnrollmentKey(k *models.EnrollmentKey) error {
 		if servercfg.CacheEnabled() {
 			storeEnrollmentkeyInCache(k.Value, *k)
 		}
+		InvalidateCache(CacheKindEnrollmentKey, k.Value)
 	}
 	return nil
 }
@@ -298,6 +300,39 @@ func storeEnrollmentkeyInCache(key string, enrollmentkey models.EnrollmentKey) {
 	enrollmentkeyCacheMutex.Unlock()
 }
 
+// invalidateEnrollmentKeyCache - refreshes an enrollment key changed by another replica
+func invalidateEnrollmentKeyCache(value string) {
+	if !servercfg.CacheEnabled() {
+		return
+	}
+	deleteEnrollmentkeyFromCache(value)
+	enrollmentkeyCacheMutex.RLock()
+	populated := len(enrollmentkeyCacheMap) > 0
+	enrollmentkeyCacheMutex.RUnlock()
+	// an empty cache is loaded completely on next use
+	if !populated {
+		return
+	}
+	data, err := database.FetchRecord(database.ENROLLMENT_KEYS_TABLE_NAME, value)
+	if err != nil {
+		return
+	}
+	var key models.EnrollmentKey
+	if err = json.Unmarshal([]byte(data), &key); err == nil {
+		storeEnrollmentkeyInCache(value, key)
+	}
+}
+
+func reloadEnrollmentKeyCache() {
+	if !servercfg.CacheEnabled() {
+		return
+	}
+	enrollmentkeyCacheMutex.Lock()
+	enrollmentkeyCacheMap = make(map[string]models.EnrollmentKey)
+	enrollmentkeyCacheMutex.Unlock()
+	_, _ = getEnrollmentKeysMap()
+}
+
 func getEnrollmentKeysMap() (map[string]models.EnrollmentKey, error) {
 	if servercfg.CacheEnabled() {
 		keys := getEnrollmentkeysFromCache()
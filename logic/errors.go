@@ -64,6 +64,18 @@ func ReturnAcceptedResponse(response http.ResponseWriter, request *http.Request,
 	json.NewEncoder(response).Encode(httpResponse)
 }
 
+// ReturnAcceptedResponseWithJson - returns 202 Accepted for async operations
+// along with e.g. the queued job
+func ReturnAcceptedResponseWithJson(response http.ResponseWriter, request *http.Request, res interface{}, message string) {
+	var httpResponse models.SuccessResponse
+	httpResponse.Code = http.StatusAccepted
+	httpResponse.Response = res
+	httpResponse.Message = message
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(response).Encode(httpResponse)
+}
+
 // ReturnSuccessResponseWithJson - processes message and adds header
 func ReturnSuccessResponseWithJson(response http.ResponseWriter, request *http.Request, res interface{}, message string) {
 	var httpResponse models.SuccessResponse
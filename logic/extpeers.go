@@ -65,6 +65,39 @@ func storeExtClientInCache(key string, extclient models.ExtClient) {
 	extClientCacheMutex.Unlock()
 }
 
+// invalidateExtClientCache - refreshes an ext client changed by another replica
+func invalidateExtClientCache(key string) {
+	if !servercfg.CacheEnabled() {
+		return
+	}
+	deleteExtClientFromCache(key)
+	extClientCacheMutex.RLock()
+	populated := len(extClientCacheMap) > 0
+	extClientCacheMutex.RUnlock()
+	// an empty cache is loaded completely on next use
+	if !populated {
+		return
+	}
+	data, err := database.FetchRecord(database.EXT_CLIENT_TABLE_NAME, key)
+	if err != nil {
+		return
+	}
+	var extclient models.ExtClient
+	if err = json.Unmarshal([]byte(data), &extclient); err == nil {
+		storeExtClientInCache(key, extclient)
+	}
+}
+
+func reloadExtClientCache() {
+	if !servercfg.CacheEnabled() {
+		return
+	}
+	extClientCacheMutex.Lock()
+	extClientCacheMap = make(map[string]models.ExtClient)
+	extClientCacheMutex.Unlock()
+	_, _ = GetAllExtClients()
+}
+
 // ExtClient.GetEgressRangesOnNetwork - returns the egress ranges on network of ext client
 func GetEgressRangesOnNetwork(client *models.ExtClient) ([]string, error) {
 
@@ -171,6 +204,7 @@ func DeleteExtClient(network string, clientid string, isUpdate bool) error {
 		}
 		deleteExtClientFromCache(key)
 	}
+	InvalidateCache(CacheKindExtClient, key)
 	if !isUpdate && extClient.RemoteAccessClientID != "" {
 		LogEvent(&models.Event{
 			Action: schema.Disconnect,
@@ -401,6 +435,7 @@ func SaveExtClient(extclient *models.ExtClient) error {
 	if err = database.Insert(key, string(data), database.EXT_CLIENT_TABLE_NAME); err != nil {
 		return err
 	}
+	InvalidateCache(CacheKindExtClient, key)
 	if servercfg.CacheEnabled() {
 		storeExtClientInCache(key, *extclient)
 		if extclient.Address != "" {
@@ -2,11 +2,11 @@ package logic
 
 type ServerSyncType string
 
+// ServerSyncType - command sent to the leader by other replicas, cache
+// changes are sent with InvalidateCache
 const (
-	SyncTypeSettings   ServerSyncType = "settings"
-	SyncTypePeerUpdate ServerSyncType = "peer_update"
-	SyncTypeIDPSync    ServerSyncType = "idp_sync"
-	SyncTypeIDPReset   ServerSyncType = "idp_reset"
+	SyncTypeIDPSync  ServerSyncType = "idp_sync"
+	SyncTypeIDPReset ServerSyncType = "idp_reset"
 )
 
 // PublishServerSync is set by the mq package at startup to broadcast
@@ -0,0 +1,294 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/leader"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"golang.org/x/exp/slog"
+)
+
+// background job types
+const (
+	NetworkDeleteJob       = "network_delete"
+	BulkHostDeleteJob      = "bulk_host_delete"
+	BulkExtClientStatusJob = "bulk_extclient_status"
+	IDPSyncJob             = "idp_sync"
+)
+
+const (
+	jobRunnerHookID       = "job-runner-hook"
+	jobRunnerInterval     = 5 * time.Second
+	jobCancelPollInterval = 2 * time.Second
+	jobRetryBackoff       = 30 * time.Second
+	jobRetention          = 7 * 24 * time.Hour
+	defaultJobMaxAttempts = 3
+)
+
+// ErrJobNotCancellable - returned when cancelling a job that already finished
+var ErrJobNotCancellable = errors.New("job already finished")
+
+// JobHandler - runs a job. ctx is cancelled when the job is cancelled or this
+// replica loses leadership. Jobs are retried on error, so handlers have to
+// be safe to run again.
+type JobHandler func(ctx context.Context, run *JobRun) error
+
+var (
+	jobHandlers   = make(map[string]JobHandler)
+	jobHandlersMu sync.RWMutex
+
+	// jobRunnerRecovered - false until the jobs left running by a previous
+	// leader were requeued
+	jobRunnerRecovered bool
+	jobRunnerMu        sync.Mutex
+	jobRunnerCancel    context.CancelFunc
+)
+
+// RegisterJobHandler - registers the handler running jobs of the type
+func RegisterJobHandler(jobType string, handler JobHandler) {
+	jobHandlersMu.Lock()
+	defer jobHandlersMu.Unlock()
+	jobHandlers[jobType] = handler
+}
+
+func getJobHandler(jobType string) (JobHandler, bool) {
+	jobHandlersMu.RLock()
+	defer jobHandlersMu.RUnlock()
+	handler, ok := jobHandlers[jobType]
+	return handler, ok
+}
+
+// JobRun - a single run of a job, handlers read their payload and report
+// progress through it
+type JobRun struct {
+	Job *schema.Job
+}
+
+// Payload - decodes the payload the job was enqueued with
+func (run *JobRun) Payload(v any) error {
+	return json.Unmarshal(run.Job.Payload, v)
+}
+
+// SetTotal - sets the number of steps of the job and restarts its progress
+func (run *JobRun) SetTotal(total int) {
+	run.Job.Total = total
+	run.Job.Progress = 0
+	run.saveProgress()
+}
+
+// Step - marks one more step of the job as done
+func (run *JobRun) Step() {
+	run.Job.Progress++
+	run.saveProgress()
+}
+
+func (run *JobRun) saveProgress() {
+	if err := run.Job.UpdateProgress(db.WithContext(context.TODO())); err != nil {
+		slog.Error("failed to update job progress", "job", run.Job.ID, "error", err)
+	}
+}
+
+// EnqueueJob - queues a job of the type, the payload is passed to its handler
+func EnqueueJob(jobType, initiator string, payload any) (schema.Job, error) {
+	if _, ok := getJobHandler(jobType); !ok {
+		return schema.Job{}, fmt.Errorf("unknown job type %s", jobType)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return schema.Job{}, err
+	}
+	now := time.Now().UTC()
+	job := schema.Job{
+		ID:          uuid.New().String(),
+		Type:        jobType,
+		Status:      schema.JobQueued,
+		Initiator:   initiator,
+		Payload:     data,
+		MaxAttempts: defaultJobMaxAttempts,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		NextRunAt:   now,
+	}
+	if err := job.Create(db.WithContext(context.TODO())); err != nil {
+		return schema.Job{}, err
+	}
+	RunHook(jobRunnerHookID)
+	return job, nil
+}
+
+// CancelJob - cancels a queued job right away, a running job is stopped by
+// the runner shortly after
+func CancelJob(jobID string) (schema.Job, error) {
+	ctx := db.WithContext(context.TODO())
+	job := schema.Job{ID: jobID}
+	if err := job.Get(ctx); err != nil {
+		return job, err
+	}
+	switch job.Status {
+	case schema.JobQueued:
+		cancelled, err := job.CancelQueued(ctx)
+		if err != nil {
+			return job, err
+		}
+		if !cancelled {
+			// started running in the meantime
+			if err := job.RequestCancel(ctx); err != nil {
+				return job, err
+			}
+		}
+	case schema.JobRunning:
+		if err := job.RequestCancel(ctx); err != nil {
+			return job, err
+		}
+	default:
+		return job, ErrJobNotCancellable
+	}
+	err := job.Get(ctx)
+	return job, err
+}
+
+// AddJobRunnerHook - runs the queued jobs on the leader
+func AddJobRunnerHook() {
+	leader.OnChange(func(isLeader bool) {
+		if isLeader {
+			return
+		}
+		jobRunnerMu.Lock()
+		defer jobRunnerMu.Unlock()
+		jobRunnerRecovered = false
+		if jobRunnerCancel != nil {
+			jobRunnerCancel()
+		}
+	})
+	HookManagerCh <- models.HookDetails{
+		ID:         jobRunnerHookID,
+		Hook:       WrapHook(JobRunnerHook),
+		Interval:   jobRunnerInterval,
+		LeaderOnly: true,
+	}
+}
+
+// JobRunnerHook - runs the queued jobs that are due one at a time and deletes
+// the jobs that finished more than a week ago
+func JobRunnerHook() error {
+	ctx := db.WithContext(context.TODO())
+	jobRunnerMu.Lock()
+	if !jobRunnerRecovered {
+		if err := (&schema.Job{}).RequeueRunning(ctx); err != nil {
+			jobRunnerMu.Unlock()
+			return err
+		}
+		jobRunnerRecovered = true
+	}
+	jobRunnerMu.Unlock()
+	if err := (&schema.Job{}).DeleteFinishedBefore(ctx, time.Now().UTC().Add(-jobRetention)); err != nil {
+		slog.Error("failed to delete finished jobs", "error", err)
+	}
+	jobs, err := (&schema.Job{}).ListDue(ctx, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	for i := range jobs {
+		if !leader.IsLeader() {
+			return nil
+		}
+		if err := runJob(&jobs[i]); err != nil {
+			slog.Error("failed to run job", "job", jobs[i].ID, "type", jobs[i].Type, "error", err)
+		}
+	}
+	return nil
+}
+
+// runJob - claims and runs the job, then records the outcome
+func runJob(job *schema.Job) error {
+	ctx := db.WithContext(context.TODO())
+	claimed, err := job.Claim(ctx)
+	if err != nil || !claimed {
+		return err
+	}
+	jobCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jobRunnerMu.Lock()
+	jobRunnerCancel = cancel
+	jobRunnerMu.Unlock()
+	defer func() {
+		jobRunnerMu.Lock()
+		jobRunnerCancel = nil
+		jobRunnerMu.Unlock()
+	}()
+	go watchJobCancel(jobCtx, job.ID, cancel)
+
+	var runErr error
+	handler, ok := getJobHandler(job.Type)
+	if !ok {
+		runErr = fmt.Errorf("unknown job type %s", job.Type)
+	} else {
+		runErr = runJobHandler(jobCtx, handler, &JobRun{Job: job})
+	}
+
+	if !leader.IsLeader() {
+		// the new leader requeues the job
+		return runErr
+	}
+	current := schema.Job{ID: job.ID}
+	if err := current.Get(ctx); err != nil {
+		return err
+	}
+	job.Error = ""
+	now := time.Now().UTC()
+	switch {
+	case runErr == nil:
+		job.Status = schema.JobSucceeded
+	case current.CancelRequested:
+		job.Status = schema.JobCancelled
+		job.Error = runErr.Error()
+	case ok && job.Attempts < job.MaxAttempts:
+		job.Status = schema.JobQueued
+		job.Error = runErr.Error()
+		job.NextRunAt = now.Add(time.Duration(job.Attempts) * jobRetryBackoff)
+	default:
+		job.Status = schema.JobFailed
+		job.Error = runErr.Error()
+	}
+	if job.Status != schema.JobQueued {
+		job.FinishedAt = &now
+	}
+	slog.Info("job run finished", "job", job.ID, "type", job.Type, "status", job.Status, "attempt", job.Attempts)
+	return job.Finish(ctx)
+}
+
+// runJobHandler - runs the handler, turning a panic into an error
+func runJobHandler(ctx context.Context, handler JobHandler, run *JobRun) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, run)
+}
+
+// watchJobCancel - cancels the job context once a cancellation is requested
+func watchJobCancel(ctx context.Context, jobID string, cancel context.CancelFunc) {
+	ticker := time.NewTicker(jobCancelPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job := schema.Job{ID: jobID}
+			if err := job.Get(db.WithContext(context.TODO())); err == nil && job.CancelRequested {
+				cancel()
+				return
+			}
+		}
+	}
+}
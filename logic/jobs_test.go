@@ -0,0 +1,158 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/leader"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobRunner(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	t.Setenv("IS_MASTER_POD", "true")
+	assert.NoError(t, leader.Init())
+	ctx := db.WithContext(context.TODO())
+
+	type payload struct {
+		Items []string `json:"items"`
+	}
+	var failures int
+	RegisterJobHandler("test_job", func(ctx context.Context, run *JobRun) error {
+		var p payload
+		if err := run.Payload(&p); err != nil {
+			return err
+		}
+		run.SetTotal(len(p.Items))
+		for range p.Items {
+			run.Step()
+		}
+		if failures > 0 {
+			failures--
+			return errors.New("transient failure")
+		}
+		return nil
+	})
+	RegisterJobHandler("test_panic_job", func(ctx context.Context, run *JobRun) error {
+		panic("boom")
+	})
+
+	t.Run("UnknownType", func(t *testing.T) {
+		_, err := EnqueueJob("no_such_job", "admin", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("Succeeds", func(t *testing.T) {
+		job, err := EnqueueJob("test_job", "admin", payload{Items: []string{"a", "b", "c"}})
+		assert.NoError(t, err)
+		assert.Equal(t, schema.JobQueued, job.Status)
+		assert.NoError(t, runJob(&job))
+
+		got := schema.Job{ID: job.ID}
+		assert.NoError(t, got.Get(ctx))
+		assert.Equal(t, schema.JobSucceeded, got.Status)
+		assert.Equal(t, "admin", got.Initiator)
+		assert.Equal(t, 3, got.Progress)
+		assert.Equal(t, 3, got.Total)
+		assert.Equal(t, 1, got.Attempts)
+		assert.NotNil(t, got.FinishedAt)
+	})
+
+	t.Run("RetriesThenFails", func(t *testing.T) {
+		failures = defaultJobMaxAttempts
+		job, err := EnqueueJob("test_job", "admin", payload{Items: []string{"a"}})
+		assert.NoError(t, err)
+		for attempt := 1; attempt <= defaultJobMaxAttempts; attempt++ {
+			assert.NoError(t, runJob(&job))
+			assert.NoError(t, job.Get(ctx))
+			assert.Equal(t, attempt, job.Attempts)
+			assert.Equal(t, "transient failure", job.Error)
+			if attempt < defaultJobMaxAttempts {
+				assert.Equal(t, schema.JobQueued, job.Status)
+				assert.True(t, job.NextRunAt.After(time.Now()))
+				assert.Nil(t, job.FinishedAt)
+			}
+		}
+		assert.Equal(t, schema.JobFailed, job.Status)
+		assert.NotNil(t, job.FinishedAt)
+	})
+
+	t.Run("RetrySucceeds", func(t *testing.T) {
+		failures = 1
+		job, err := EnqueueJob("test_job", "admin", payload{Items: []string{"a", "b"}})
+		assert.NoError(t, err)
+		assert.NoError(t, runJob(&job))
+		assert.NoError(t, job.Get(ctx))
+		assert.Equal(t, schema.JobQueued, job.Status)
+		assert.NoError(t, runJob(&job))
+		assert.NoError(t, job.Get(ctx))
+		assert.Equal(t, schema.JobSucceeded, job.Status)
+		assert.Equal(t, 2, job.Attempts)
+		assert.Equal(t, 2, job.Progress)
+		assert.Empty(t, job.Error)
+	})
+
+	t.Run("Panics", func(t *testing.T) {
+		job, err := EnqueueJob("test_panic_job", "admin", nil)
+		assert.NoError(t, err)
+		assert.NoError(t, db.FromContext(ctx).Model(&schema.Job{}).Where("id = ?", job.ID).Update("max_attempts", 1).Error)
+		assert.NoError(t, runJob(&job))
+		assert.NoError(t, job.Get(ctx))
+		assert.Equal(t, schema.JobFailed, job.Status)
+		assert.Contains(t, job.Error, "boom")
+	})
+
+	t.Run("CancelQueued", func(t *testing.T) {
+		job, err := EnqueueJob("test_job", "admin", payload{})
+		assert.NoError(t, err)
+		job, err = CancelJob(job.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, schema.JobCancelled, job.Status)
+		assert.True(t, job.CancelRequested)
+
+		// a cancelled job is not claimed by the runner
+		assert.NoError(t, runJob(&job))
+		assert.NoError(t, job.Get(ctx))
+		assert.Equal(t, schema.JobCancelled, job.Status)
+		assert.Equal(t, 0, job.Attempts)
+
+		_, err = CancelJob(job.ID)
+		assert.ErrorIs(t, err, ErrJobNotCancellable)
+	})
+
+	t.Run("RequeueRunning", func(t *testing.T) {
+		job, err := EnqueueJob("test_job", "admin", payload{})
+		assert.NoError(t, err)
+		claimed, err := job.Claim(ctx)
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+		jobRunnerRecovered = false
+		assert.NoError(t, JobRunnerHook())
+		assert.NoError(t, job.Get(ctx))
+		assert.Equal(t, schema.JobSucceeded, job.Status)
+		assert.Equal(t, 2, job.Attempts)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		job, err := EnqueueJob("test_job", "admin", payload{})
+		assert.NoError(t, err)
+		_, err = CancelJob(job.ID)
+		assert.NoError(t, err)
+		jobs, err := (&schema.Job{Type: "test_job", Status: schema.JobCancelled}).List(ctx)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, jobs)
+		assert.Equal(t, job.ID, jobs[0].ID)
+		for _, j := range jobs {
+			assert.Equal(t, schema.JobCancelled, j.Status)
+		}
+	})
+}
@@ -143,31 +143,39 @@ func VerifyOTPAuthURL(url, signature string) bool {
 }
 
 func GetUserNameFromToken(authtoken string) (username string, err error) {
+	username, _, err = getUserNameAndAccessToken(authtoken)
+	return username, err
+}
+
+// getUserNameAndAccessToken - verifies the token of a request, the record of
+// the api access token is returned along with the user when the request is
+// made with one.
+func getUserNameAndAccessToken(authtoken string) (username string, accessToken *schema.UserAccessToken, err error) {
 	claims := &models.UserClaims{}
 	var tokenSplit = strings.Split(authtoken, " ")
 	var tokenString = ""
 
 	if len(tokenSplit) < 2 {
-		return "", Unauthorized_Err
+		return "", nil, Unauthorized_Err
 	} else {
 		tokenString = tokenSplit[1]
 	}
 	if tokenString == servercfg.GetMasterKey() && servercfg.GetMasterKey() != "" {
-		return MasterUser, nil
+		return MasterUser, nil, nil
 	}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return jwtSecretKey, nil
 	})
 	if err != nil {
-		return "", Unauthorized_Err
+		return "", nil, Unauthorized_Err
 	}
 
 	for _, aud := range claims.Audience {
 		// token created for mfa cannot be used for
 		// anything else.
 		if aud == "auth:mfa" {
-			return "", Unauthorized_Err
+			return "", nil, Unauthorized_Err
 		}
 	}
 
@@ -179,10 +187,11 @@ func GetUserNameFromToken(authtoken string) (username string, err error) {
 			err := a.Get(db.WithContext(context.TODO()))
 			if err != nil {
 				err = errors.New("token revoked")
-				return "", err
+				return "", nil, err
 			}
 			a.LastUsed = time.Now().UTC()
 			a.Update(db.WithContext(context.TODO()))
+			accessToken = &a
 		}
 	}
 
@@ -191,19 +200,19 @@ func GetUserNameFromToken(authtoken string) (username string, err error) {
 		user := &schema.User{Username: claims.UserName}
 		err = user.Get(db.WithContext(context.TODO()))
 		if err != nil {
-			return "", err
+			return "", nil, err
 		}
 		if user.Username != "" {
-			return user.Username, nil
+			return user.Username, accessToken, nil
 		}
 		if user.PlatformRoleID != claims.Role {
-			return "", Unauthorized_Err
+			return "", nil, Unauthorized_Err
 		}
 		err = errors.New("user does not exist")
 	} else {
 		err = Unauthorized_Err
 	}
-	return "", err
+	return "", nil, err
 }
 
 // VerifyUserToken func will used to Verify the JWT Token while using APIS
@@ -0,0 +1,1401 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var (
+	// ListNetworkTags - lists the device tags of a network, tags are only available in pro
+	ListNetworkTags = func(netID schema.NetworkID) ([]models.Tag, error) {
+		return []models.Tag{}, nil
+	}
+	// UpsertTag - creates or updates a device tag
+	UpsertTag = func(tag models.Tag) error {
+		return errors.New("tags are only available in pro")
+	}
+	// DeleteTag - deletes a device tag and untags the devices
+	DeleteTag = func(tagID models.TagID, removeFromPolicy bool) error {
+		return errors.New("tags are only available in pro")
+	}
+	// CheckIDSyntax - validates the name of a tag
+	CheckIDSyntax = func(id string) error {
+		return nil
+	}
+	// ValidatePostureCheck - validates and normalizes a posture check
+	ValidatePostureCheck = func(pc *schema.PostureCheck) error {
+		return errors.New("posture checks are only available in pro")
+	}
+)
+
+// networkSpecMutex - serializes applies so plans are computed against a stable state
+var networkSpecMutex = &sync.Mutex{}
+
+// networkSpecOp - planned change of a resource with the functions to validate,
+// apply and revert it. validate is nil once the change was validated at plan time.
+type networkSpecOp struct {
+	change   models.NetworkSpecChange
+	validate func() error
+	apply    func() error
+	undo     func() error
+}
+
+// ExportNetworkSpec - builds the declarative document describing a network
+// and its resources
+func ExportNetworkSpec(netID string) (models.NetworkSpec, error) {
+	network := &schema.Network{Name: netID}
+	if err := network.Get(db.WithContext(context.TODO())); err != nil {
+		return models.NetworkSpec{}, err
+	}
+	state, err := getNetworkSpecState(network)
+	if err != nil {
+		return models.NetworkSpec{}, err
+	}
+	spec := models.NetworkSpec{
+		APIVersion:     models.NetworkSpecAPIVersion,
+		Kind:           models.NetworkSpecKind,
+		Network:        convNetworkToSpec(network),
+		Tags:           []models.NetworkSpecTag{},
+		Acls:           []models.NetworkSpecAcl{},
+		Egresses:       []models.NetworkSpecEgress{},
+		Nameservers:    []models.NetworkSpecNameserver{},
+		EnrollmentKeys: []models.NetworkSpecEnrollmentKey{},
+		PostureChecks:  []models.NetworkSpecPostureCheck{},
+	}
+	for _, tag := range state.tags {
+		spec.Tags = append(spec.Tags, convTagToSpec(tag))
+	}
+	for _, acl := range state.acls {
+		spec.Acls = append(spec.Acls, convAclToSpec(acl, state.egressNames))
+	}
+	for _, e := range state.egresses {
+		spec.Egresses = append(spec.Egresses, convEgressToSpec(e))
+	}
+	for _, ns := range state.nameservers {
+		spec.Nameservers = append(spec.Nameservers, convNameserverToSpec(ns))
+	}
+	for _, key := range state.keys {
+		spec.EnrollmentKeys = append(spec.EnrollmentKeys, convEnrollmentKeyToSpec(key))
+	}
+	for _, pc := range state.postureChecks {
+		spec.PostureChecks = append(spec.PostureChecks, convPostureCheckToSpec(pc))
+	}
+	return spec, nil
+}
+
+// PlanNetworkSpec - computes the changes needed to bring the network to the
+// state declared in the document without applying them
+func PlanNetworkSpec(spec models.NetworkSpec, user string) (models.NetworkSpecPlan, error) {
+	networkSpecMutex.Lock()
+	defer networkSpecMutex.Unlock()
+	plan := models.NetworkSpecPlan{
+		Network: spec.Network.Name,
+		DryRun:  true,
+		Changes: []models.NetworkSpecChange{},
+	}
+	ops, err := planNetworkSpec(spec, user)
+	if err != nil {
+		return plan, err
+	}
+	for _, op := range ops {
+		plan.Changes = append(plan.Changes, op.change)
+	}
+	return plan, nil
+}
+
+// ApplyNetworkSpec - brings the network to the state declared in the document.
+// Either all changes are applied or, if one of them fails, the changes applied
+// so far are reverted.
+func ApplyNetworkSpec(spec models.NetworkSpec, user string) (models.NetworkSpecPlan, error) {
+	networkSpecMutex.Lock()
+	defer networkSpecMutex.Unlock()
+	plan := models.NetworkSpecPlan{
+		Network: spec.Network.Name,
+		Changes: []models.NetworkSpecChange{},
+	}
+	ops, err := planNetworkSpec(spec, user)
+	if err != nil {
+		return plan, err
+	}
+	applied := []networkSpecOp{}
+	rollback := func(cause error) error {
+		return revertNetworkSpecOps(applied, cause)
+	}
+	if len(ops) > 0 && ops[0].change.Resource == schema.NetworkSub && ops[0].change.Action == schema.Create {
+		// resources of a new network are planned once the network and its
+		// default resources exist
+		if err := ops[0].apply(); err != nil {
+			return plan, fmt.Errorf("failed to create network %s: %w", spec.Network.Name, err)
+		}
+		applied = append(applied, ops[0])
+		plan.Changes = append(plan.Changes, ops[0].change)
+		network := &schema.Network{Name: spec.Network.Name}
+		if err := network.Get(db.WithContext(context.TODO())); err != nil {
+			return plan, rollback(err)
+		}
+		ops, err = planNetworkSpecResources(spec, network, user)
+		if err != nil {
+			return plan, rollback(err)
+		}
+	}
+	for _, op := range ops {
+		if op.validate != nil {
+			if err := op.validate(); err != nil {
+				return plan, rollback(fmt.Errorf("invalid %s %s: %w", strings.ToLower(string(op.change.Resource)), op.change.Name, err))
+			}
+		}
+		if err := op.apply(); err != nil {
+			return plan, rollback(fmt.Errorf("failed to %s %s %s: %w", strings.ToLower(string(op.change.Action)),
+				strings.ToLower(string(op.change.Resource)), op.change.Name, err))
+		}
+		applied = append(applied, op)
+		plan.Changes = append(plan.Changes, op.change)
+	}
+	plan.Applied = true
+	for _, change := range plan.Changes {
+		LogEvent(&models.Event{
+			Action: change.Action,
+			Source: models.Subject{
+				ID:   user,
+				Name: user,
+				Type: schema.UserSub,
+			},
+			TriggeredBy: user,
+			Target: models.Subject{
+				ID:   change.Name,
+				Name: change.Name,
+				Type: change.Resource,
+			},
+			Diff: models.Diff{
+				Old: change.Old,
+				New: change.New,
+			},
+			NetworkID: schema.NetworkID(spec.Network.Name),
+			Origin:    schema.Api,
+		})
+	}
+	return plan, nil
+}
+
+// revertNetworkSpecOps - undoes the applied changes, latest first, after the
+// apply failed with cause. The changes that couldn't be reverted are returned
+// along with cause.
+func revertNetworkSpecOps(applied []networkSpecOp, cause error) error {
+	errs := []error{cause}
+	for i := len(applied) - 1; i >= 0; i-- {
+		if err := applied[i].undo(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to revert %s %s: %w",
+				strings.ToLower(string(applied[i].change.Resource)), applied[i].change.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// planNetworkSpec - validates the document and plans the changes against the current state
+func planNetworkSpec(spec models.NetworkSpec, user string) ([]networkSpecOp, error) {
+	if err := validateNetworkSpec(spec); err != nil {
+		return nil, err
+	}
+	network := &schema.Network{Name: spec.Network.Name}
+	err := network.Get(db.WithContext(context.TODO()))
+	if err == nil {
+		return planNetworkSpecResources(spec, network, user)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	ops := []networkSpecOp{newNetworkSpecCreateOp(spec.Network, user)}
+	// default resources created along with the network are matched on apply
+	for i := range spec.Tags {
+		ops = append(ops, networkSpecOp{change: newNetworkSpecChange(schema.TagSub, spec.Tags[i].Name, nil, &spec.Tags[i])})
+	}
+	for i := range spec.Egresses {
+		ops = append(ops, networkSpecOp{change: newNetworkSpecChange(schema.EgressSub, spec.Egresses[i].Name, nil, &spec.Egresses[i])})
+	}
+	for i := range spec.Nameservers {
+		ops = append(ops, networkSpecOp{change: newNetworkSpecChange(schema.NameserverSub, spec.Nameservers[i].Name, nil, &spec.Nameservers[i])})
+	}
+	for i := range spec.PostureChecks {
+		ops = append(ops, networkSpecOp{change: newNetworkSpecChange(schema.PostureCheckSub, spec.PostureChecks[i].Name, nil, &spec.PostureChecks[i])})
+	}
+	for i := range spec.Acls {
+		ops = append(ops, networkSpecOp{change: newNetworkSpecChange(schema.AclSub, spec.Acls[i].Name, nil, &spec.Acls[i])})
+	}
+	for i := range spec.EnrollmentKeys {
+		ops = append(ops, networkSpecOp{change: newNetworkSpecChange(schema.EnrollmentKeySub, spec.EnrollmentKeys[i].Name, nil, &spec.EnrollmentKeys[i])})
+	}
+	return ops, nil
+}
+
+// validateNetworkSpec - checks the document is well formed and resource names are unique
+func validateNetworkSpec(spec models.NetworkSpec) error {
+	if spec.APIVersion != "" && spec.APIVersion != models.NetworkSpecAPIVersion {
+		return fmt.Errorf("unsupported apiVersion %s", spec.APIVersion)
+	}
+	if spec.Kind != "" && spec.Kind != models.NetworkSpecKind {
+		return fmt.Errorf("unsupported kind %s", spec.Kind)
+	}
+	if err := validateNetName(&schema.Network{Name: spec.Network.Name}); err != nil {
+		return err
+	}
+	names := func(resource string, n int, name func(i int) string) error {
+		seen := make(map[string]struct{})
+		for i := 0; i < n; i++ {
+			if name(i) == "" {
+				return fmt.Errorf("%s name is required", resource)
+			}
+			if _, ok := seen[name(i)]; ok {
+				return fmt.Errorf("duplicate %s %s", resource, name(i))
+			}
+			seen[name(i)] = struct{}{}
+		}
+		return nil
+	}
+	return errors.Join(
+		names("tag", len(spec.Tags), func(i int) string { return spec.Tags[i].Name }),
+		names("acl", len(spec.Acls), func(i int) string { return spec.Acls[i].Name }),
+		names("egress", len(spec.Egresses), func(i int) string { return spec.Egresses[i].Name }),
+		names("nameserver", len(spec.Nameservers), func(i int) string { return spec.Nameservers[i].Name }),
+		names("enrollment key", len(spec.EnrollmentKeys), func(i int) string { return spec.EnrollmentKeys[i].Name }),
+		names("posture check", len(spec.PostureChecks), func(i int) string { return spec.PostureChecks[i].Name }),
+	)
+}
+
+// networkSpecState - current resources of a network
+type networkSpecState struct {
+	tags          []models.Tag
+	acls          []models.Acl
+	egresses      []schema.Egress
+	nameservers   []schema.Nameserver
+	keys          []models.EnrollmentKey
+	postureChecks []schema.PostureCheck
+	egressNames   map[string]string
+}
+
+func getNetworkSpecState(network *schema.Network) (*networkSpecState, error) {
+	var err error
+	state := &networkSpecState{egressNames: make(map[string]string)}
+	netID := schema.NetworkID(network.Name)
+	ctx := db.WithContext(context.TODO())
+	if state.tags, err = ListNetworkTags(netID); err != nil {
+		return nil, err
+	}
+	sort.Slice(state.tags, func(i, j int) bool { return state.tags[i].TagName < state.tags[j].TagName })
+	if state.acls, err = ListAclsByNetwork(netID); err != nil {
+		return nil, err
+	}
+	if state.egresses, err = (&schema.Egress{Network: network.Name}).ListByNetwork(ctx); err != nil {
+		return nil, err
+	}
+	sort.Slice(state.egresses, func(i, j int) bool { return state.egresses[i].Name < state.egresses[j].Name })
+	for _, e := range state.egresses {
+		state.egressNames[e.ID] = e.Name
+	}
+	if state.nameservers, err = (&schema.Nameserver{NetworkID: network.Name}).ListByNetwork(ctx); err != nil {
+		return nil, err
+	}
+	sort.Slice(state.nameservers, func(i, j int) bool { return state.nameservers[i].Name < state.nameservers[j].Name })
+	keys, err := GetAllEnrollmentKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if isNetworkSpecEnrollmentKey(key, network.Name) {
+			state.keys = append(state.keys, key)
+		}
+	}
+	sort.Slice(state.keys, func(i, j int) bool { return state.keys[i].Tags[0] < state.keys[j].Tags[0] })
+	if state.postureChecks, err = (&schema.PostureCheck{NetworkID: netID}).ListByNetwork(ctx); err != nil {
+		return nil, err
+	}
+	sort.Slice(state.postureChecks, func(i, j int) bool { return state.postureChecks[i].Name < state.postureChecks[j].Name })
+	return state, nil
+}
+
+// isNetworkSpecEnrollmentKey - only non default keys scoped to just this
+// network are managed by the network document
+func isNetworkSpecEnrollmentKey(key models.EnrollmentKey, network string) bool {
+	return !key.Default && len(key.Networks) == 1 && key.Networks[0] == network && len(key.Tags) > 0
+}
+
+// planNetworkSpecResources - plans the changes of an existing network. Changes
+// are ordered so that resources exist before they are referenced and are
+// deleted after the references to them are gone.
+func planNetworkSpecResources(spec models.NetworkSpec, network *schema.Network, user string) ([]networkSpecOp, error) {
+	state, err := getNetworkSpecState(network)
+	if err != nil {
+		return nil, err
+	}
+	ops := []networkSpecOp{}
+	if op, ok, err := planNetworkSpecSettings(spec.Network, network); err != nil {
+		return nil, err
+	} else if ok {
+		ops = append(ops, op)
+	}
+	tagOps, tagDeletes, err := planNetworkSpecTags(spec, state, user)
+	if err != nil {
+		return nil, err
+	}
+	// resources referencing tags that do not exist yet are validated on apply
+	deferValidation := false
+	for _, op := range tagOps {
+		if op.change.Action == schema.Create {
+			deferValidation = true
+		}
+	}
+	egressIDs := make(map[string]string)
+	for id, name := range state.egressNames {
+		egressIDs[name] = id
+	}
+	egressOps, egressDeletes, err := planNetworkSpecEgresses(spec, state, network, egressIDs, user)
+	if err != nil {
+		return nil, err
+	}
+	nsOps, nsDeletes, err := planNetworkSpecNameservers(spec, state, user)
+	if err != nil {
+		return nil, err
+	}
+	pcOps, pcDeletes, err := planNetworkSpecPostureChecks(spec, state, user)
+	if err != nil {
+		return nil, err
+	}
+	aclOps, aclDeletes, err := planNetworkSpecAcls(spec, state, egressIDs, user)
+	if err != nil {
+		return nil, err
+	}
+	keyOps, keyDeletes, err := planNetworkSpecEnrollmentKeys(spec, state)
+	if err != nil {
+		return nil, err
+	}
+	ops = append(ops, tagOps...)
+	ops = append(ops, egressOps...)
+	ops = append(ops, nsOps...)
+	ops = append(ops, pcOps...)
+	ops = append(ops, aclOps...)
+	ops = append(ops, keyOps...)
+	ops = append(ops, keyDeletes...)
+	ops = append(ops, aclDeletes...)
+	ops = append(ops, pcDeletes...)
+	ops = append(ops, nsDeletes...)
+	ops = append(ops, egressDeletes...)
+	ops = append(ops, tagDeletes...)
+	if !deferValidation {
+		for i := range ops {
+			if ops[i].validate == nil {
+				continue
+			}
+			if err := ops[i].validate(); err != nil {
+				return nil, fmt.Errorf("invalid %s %s: %w", strings.ToLower(string(ops[i].change.Resource)), ops[i].change.Name, err)
+			}
+			ops[i].validate = nil
+		}
+	}
+	return ops, nil
+}
+
+// newNetworkSpecCreateOp - creates the network along with its default
+// resources, the same way the networks api does
+func newNetworkSpecCreateOp(settings models.NetworkSpecSettings, user string) networkSpecOp {
+	desired := settings
+	return networkSpecOp{
+		change: newNetworkSpecChange(schema.NetworkSub, settings.Name, nil, &desired),
+		apply: func() error {
+			network := &schema.Network{
+				Name:                        settings.Name,
+				AddressRange:                settings.AddressRange,
+				AddressRange6:               settings.AddressRange6,
+				AutoJoin:                    settings.AutoJoin,
+				AutoRemove:                  settings.AutoRemove,
+				AutoRemoveThreshold:         settings.AutoRemoveThreshold,
+				AutoRemoveTags:              settings.AutoRemoveTags,
+				VirtualNATPoolIPv4:          settings.VirtualNATPoolIPv4,
+				VirtualNATSitePrefixLenIPv4: settings.VirtualNATSitePrefixLenIPv4,
+				CreatedBy:                   user,
+				CreatedAt:                   time.Now().UTC(),
+			}
+			if network.AddressRange == "" && network.AddressRange6 == "" {
+				return errors.New("IPv4 or IPv6 CIDR required")
+			}
+			if !GetFeatureFlags().EnableDeviceApproval {
+				network.AutoJoin = true
+			}
+			if network.AutoRemove && network.AutoRemoveThreshold == 0 {
+				network.AutoRemoveThreshold = 60
+			}
+			if network.AutoRemoveTags == nil {
+				network.AutoRemoveTags = []string{}
+			}
+			if err := CreateNetwork(network); err != nil {
+				return err
+			}
+			CreateDefaultNetworkRolesAndGroups(schema.NetworkID(network.Name))
+			CreateDefaultAclNetworkPolicies(schema.NetworkID(network.Name))
+			CreateDefaultTags(schema.NetworkID(network.Name))
+			AddNetworkToAllocatedIpMap(network.Name)
+			CreateFallbackNameserver(network.Name)
+			if GetFeatureFlags().EnableOverlappingEgressRanges && network.VirtualNATPoolIPv4 == "" {
+				if err := AllocateUniqueVNATPool(network); err != nil {
+					logger.Log(0, "failed to allocate unique virtual NAT pool:", err.Error())
+				} else if err := UpsertNetwork(network); err != nil {
+					logger.Log(0, "failed to update network with virtual NAT settings:", err.Error())
+				}
+			}
+			return nil
+		},
+		undo: func() error {
+			netID := schema.NetworkID(settings.Name)
+			UnlinkNetworkAndTagsFromEnrollmentKeys(settings.Name, true)
+			DeleteNetworkRoles(settings.Name)
+			DeleteAllNetworkTags(netID)
+			DeleteNetworkPolicies(netID)
+			RemoveNetworkFromAllocatedIpMap(settings.Name)
+			DeleteNetworkNameservers(settings.Name)
+			return (&schema.Network{Name: settings.Name}).Delete(db.WithContext(context.TODO()))
+		},
+	}
+}
+
+// planNetworkSpecSettings - plans the update of the network settings. Virtual
+// NAT settings that are omitted keep their current value.
+func planNetworkSpecSettings(settings models.NetworkSpecSettings, network *schema.Network) (networkSpecOp, bool, error) {
+	current := convNetworkToSpec(network)
+	desired := settings
+	if desired.AddressRange != "" {
+		normalized, err := NormalizeCIDR(desired.AddressRange)
+		if err != nil {
+			return networkSpecOp{}, false, err
+		}
+		desired.AddressRange = normalized
+	}
+	if desired.AddressRange6 != "" {
+		normalized, err := NormalizeCIDR(desired.AddressRange6)
+		if err != nil {
+			return networkSpecOp{}, false, err
+		}
+		desired.AddressRange6 = normalized
+	}
+	if desired.AddressRange != current.AddressRange || desired.AddressRange6 != current.AddressRange6 {
+		return networkSpecOp{}, false, errors.New("address ranges of an existing network cannot be changed")
+	}
+	if !GetFeatureFlags().EnableDeviceApproval {
+		desired.AutoJoin = true
+	}
+	if desired.AutoRemove && desired.AutoRemoveThreshold == 0 {
+		desired.AutoRemoveThreshold = 60
+	}
+	if desired.VirtualNATPoolIPv4 == "" {
+		desired.VirtualNATPoolIPv4 = current.VirtualNATPoolIPv4
+		if desired.VirtualNATSitePrefixLenIPv4 == 0 {
+			desired.VirtualNATSitePrefixLenIPv4 = current.VirtualNATSitePrefixLenIPv4
+		}
+	}
+	change := newNetworkSpecChange(schema.NetworkSub, network.Name, &current, &desired)
+	if change.Action == "" {
+		return networkSpecOp{}, false, nil
+	}
+	old := *network
+	return networkSpecOp{
+		change: change,
+		apply: func() error {
+			currNet := old
+			newNet := old
+			newNet.AutoJoin = desired.AutoJoin
+			newNet.AutoRemove = desired.AutoRemove
+			newNet.AutoRemoveThreshold = desired.AutoRemoveThreshold
+			newNet.AutoRemoveTags = desired.AutoRemoveTags
+			if newNet.AutoRemoveTags == nil {
+				newNet.AutoRemoveTags = []string{}
+			}
+			newNet.VirtualNATPoolIPv4 = desired.VirtualNATPoolIPv4
+			newNet.VirtualNATSitePrefixLenIPv4 = desired.VirtualNATSitePrefixLenIPv4
+			return UpdateNetwork(&currNet, &newNet)
+		},
+		undo: func() error {
+			network := old
+			return UpsertNetwork(&network)
+		},
+	}, true, nil
+}
+
+// planNetworkSpecTags - plans the changes of device tags, the default gateway
+// tag is never deleted
+func planNetworkSpecTags(spec models.NetworkSpec, state *networkSpecState, user string) (ops, deletes []networkSpecOp, err error) {
+	netID := schema.NetworkID(spec.Network.Name)
+	current := make(map[string]models.Tag)
+	for _, tag := range state.tags {
+		current[tag.TagName] = tag
+	}
+	for i := range spec.Tags {
+		desired := spec.Tags[i]
+		tag, exists := current[desired.Name]
+		delete(current, desired.Name)
+		if !exists {
+			tag = models.Tag{
+				ID:        models.TagID(fmt.Sprintf("%s.%s", netID, desired.Name)),
+				TagName:   desired.Name,
+				Network:   netID,
+				CreatedBy: user,
+				CreatedAt: time.Now().UTC(),
+			}
+			if err := CheckIDSyntax(desired.Name); err != nil {
+				return nil, nil, fmt.Errorf("invalid tag %s: %w", desired.Name, err)
+			}
+			tag.ColorCode = desired.ColorCode
+			ops = append(ops, networkSpecOp{
+				change: newNetworkSpecChange(schema.TagSub, desired.Name, nil, &desired),
+				apply:  func() error { return UpsertTag(tag) },
+				undo:   func() error { return DeleteTag(tag.ID, false) },
+			})
+			continue
+		}
+		old := convTagToSpec(tag)
+		change := newNetworkSpecChange(schema.TagSub, desired.Name, &old, &desired)
+		if change.Action == "" {
+			continue
+		}
+		newTag := tag
+		newTag.ColorCode = desired.ColorCode
+		ops = append(ops, networkSpecOp{
+			change: change,
+			apply:  func() error { return UpsertTag(newTag) },
+			undo:   func() error { return UpsertTag(tag) },
+		})
+	}
+	for _, tag := range state.tags {
+		if _, ok := current[tag.TagName]; !ok || tag.TagName == models.GwTagName {
+			continue
+		}
+		tag := tag
+		old := convTagToSpec(tag)
+		var nodeIDs, clientIDs []string
+		deletes = append(deletes, networkSpecOp{
+			change: newNetworkSpecChange[models.NetworkSpecTag](schema.TagSub, tag.TagName, &old, nil),
+			apply: func() (err error) {
+				if nodeIDs, clientIDs, err = getNetworkSpecTaggedDevices(tag); err != nil {
+					return err
+				}
+				return DeleteTag(tag.ID, false)
+			},
+			// DeleteTag also removes the tag from egress routes in the
+			// background, those are not tagged again
+			undo: func() error {
+				if err := UpsertTag(tag); err != nil {
+					return err
+				}
+				return retagNetworkSpecDevices(tag, nodeIDs, clientIDs)
+			},
+		})
+	}
+	return ops, deletes, nil
+}
+
+// getNetworkSpecTaggedDevices - ids of the nodes and ext clients of the
+// network with the tag
+func getNetworkSpecTaggedDevices(tag models.Tag) (nodeIDs, clientIDs []string, err error) {
+	nodes, err := GetNetworkNodes(tag.Network.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, node := range nodes {
+		if _, ok := node.Tags[tag.ID]; ok {
+			nodeIDs = append(nodeIDs, node.ID.String())
+		}
+	}
+	clients, err := GetNetworkExtClients(tag.Network.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, client := range clients {
+		if _, ok := client.Tags[tag.ID]; ok {
+			clientIDs = append(clientIDs, client.ClientID)
+		}
+	}
+	return nodeIDs, clientIDs, nil
+}
+
+// retagNetworkSpecDevices - tags the nodes and ext clients untagged by
+// DeleteTag again, devices deleted since are skipped
+func retagNetworkSpecDevices(tag models.Tag, nodeIDs, clientIDs []string) error {
+	for _, id := range nodeIDs {
+		node, err := GetNodeByID(id)
+		if err != nil {
+			continue
+		}
+		if node.Tags == nil {
+			node.Tags = make(map[models.TagID]struct{})
+		}
+		node.Tags[tag.ID] = struct{}{}
+		if err = UpsertNode(&node); err != nil {
+			return err
+		}
+	}
+	for _, id := range clientIDs {
+		client, err := GetExtClient(id, tag.Network.String())
+		if err != nil {
+			continue
+		}
+		if client.Tags == nil {
+			client.Tags = make(map[models.TagID]struct{})
+		}
+		client.Tags[tag.ID] = struct{}{}
+		if err = SaveExtClient(&client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planNetworkSpecEgresses - plans the changes of egress routes. ids of new
+// egresses are added to egressIDs so policies can reference them by name.
+func planNetworkSpecEgresses(spec models.NetworkSpec, state *networkSpecState, network *schema.Network,
+	egressIDs map[string]string, user string) (ops, deletes []networkSpecOp, err error) {
+	current := make(map[string]schema.Egress)
+	for _, e := range state.egresses {
+		current[e.Name] = e
+	}
+	for i := range spec.Egresses {
+		desired := spec.Egresses[i]
+		e, exists := current[desired.Name]
+		delete(current, desired.Name)
+		old := e
+		if !exists {
+			e = schema.Egress{
+				ID:        uuid.New().String(),
+				Network:   network.Name,
+				DomainAns: []string{},
+				CreatedBy: user,
+				CreatedAt: time.Now().UTC(),
+			}
+			egressIDs[desired.Name] = e.ID
+		}
+		if desired.Range != "" && desired.Range != "*" {
+			if desired.Range, err = NormalizeCIDR(desired.Range); err != nil {
+				return nil, nil, fmt.Errorf("invalid egress %s: %w", desired.Name, err)
+			}
+		}
+		if desired.Domain != "" {
+			if !IsFQDN(desired.Domain) {
+				return nil, nil, fmt.Errorf("invalid egress %s: bad domain name", desired.Name)
+			}
+			desired.Range = ""
+		}
+		if e.Domain != desired.Domain {
+			e.DomainAns = []string{}
+		}
+		if desired.Mode != schema.VirtualNAT || e.Mode != schema.VirtualNAT {
+			e.VirtualRange = ""
+		}
+		e.Name = desired.Name
+		e.Description = desired.Description
+		e.Range = desired.Range
+		e.Domain = desired.Domain
+		e.Nat = desired.Nat
+		e.Mode = desired.Mode
+		if !e.Nat {
+			e.Mode = ""
+			e.VirtualRange = ""
+		} else if e.Mode == "" {
+			e.Mode = schema.DirectNAT
+		}
+		e.Status = desired.Enabled
+		e.Nodes = make(datatypes.JSONMap)
+		e.Tags = make(datatypes.JSONMap)
+		if len(desired.Tags) > 0 {
+			for tagID, metric := range desired.Tags {
+				e.Tags[tagID] = metric
+			}
+		} else {
+			for nodeID, metric := range desired.Nodes {
+				e.Nodes[nodeID] = metric
+			}
+		}
+		e.UpdatedAt = time.Now().UTC()
+		if err := AssignVirtualRangeToEgress(network, &e); err != nil {
+			return nil, nil, fmt.Errorf("invalid egress %s: %w", desired.Name, err)
+		}
+		egress := e
+		op := networkSpecOp{
+			validate: func() error { return ValidateEgressReq(&egress) },
+		}
+		if !exists {
+			normalized := convEgressToSpec(egress)
+			op.change = newNetworkSpecChange(schema.EgressSub, desired.Name, nil, &normalized)
+			op.apply = func() error { return egress.Create(db.WithContext(context.TODO())) }
+			op.undo = func() error { return egress.Delete(db.WithContext(context.TODO())) }
+			ops = append(ops, op)
+			continue
+		}
+		oldSpec := convEgressToSpec(old)
+		newSpec := convEgressToSpec(egress)
+		op.change = newNetworkSpecChange(schema.EgressSub, desired.Name, &oldSpec, &newSpec)
+		if op.change.Action == "" {
+			continue
+		}
+		op.apply = func() error { return saveNetworkSpecResource(&egress) }
+		op.undo = func() error { return saveNetworkSpecResource(&old) }
+		ops = append(ops, op)
+	}
+	for _, e := range state.egresses {
+		if _, ok := current[e.Name]; !ok {
+			continue
+		}
+		e := e
+		old := convEgressToSpec(e)
+		deletes = append(deletes, networkSpecOp{
+			change: newNetworkSpecChange[models.NetworkSpecEgress](schema.EgressSub, e.Name, &old, nil),
+			apply:  func() error { return e.Delete(db.WithContext(context.TODO())) },
+			undo:   func() error { return e.Create(db.WithContext(context.TODO())) },
+		})
+	}
+	return ops, deletes, nil
+}
+
+// planNetworkSpecNameservers - plans the changes of nameservers, default
+// nameservers are never deleted
+func planNetworkSpecNameservers(spec models.NetworkSpec, state *networkSpecState, user string) (ops, deletes []networkSpecOp, err error) {
+	current := make(map[string]schema.Nameserver)
+	for _, ns := range state.nameservers {
+		current[ns.Name] = ns
+	}
+	for i := range spec.Nameservers {
+		desired := spec.Nameservers[i]
+		ns, exists := current[desired.Name]
+		delete(current, desired.Name)
+		old := ns
+		if !exists {
+			ns = schema.Nameserver{
+				ID:        uuid.New().String(),
+				NetworkID: spec.Network.Name,
+				CreatedBy: user,
+				CreatedAt: time.Now().UTC(),
+			}
+		}
+		ns.Name = desired.Name
+		ns.Description = desired.Description
+		ns.Servers = desired.Servers
+		if gNs, ok := GlobalNsList[desired.Name]; ok {
+			ns.Servers = gNs.IPs
+		}
+		ns.MatchAll = desired.MatchAll
+		ns.Fallback = desired.Fallback
+		ns.Status = desired.Enabled
+		ns.Domains = []schema.NameserverDomain{}
+		for _, domain := range desired.Domains {
+			ns.Domains = append(ns.Domains, schema.NameserverDomain(domain))
+		}
+		if ns.MatchAll {
+			ns.Domains = []schema.NameserverDomain{{Domain: "."}}
+		}
+		if ns.Fallback {
+			ns.Domains = []schema.NameserverDomain{}
+			ns.MatchAll = false
+		}
+		ns.Tags = convListToJSONMap(desired.Tags)
+		ns.Nodes = convListToJSONMap(desired.Nodes)
+		if !servercfg.IsPro {
+			ns.Tags = datatypes.JSONMap{"*": struct{}{}}
+		}
+		ns.UpdatedAt = time.Now().UTC()
+		nameserver := ns
+		op := networkSpecOp{
+			validate: func() error { return ValidateNameserverReq(&nameserver) },
+		}
+		if !exists {
+			normalized := convNameserverToSpec(nameserver)
+			op.change = newNetworkSpecChange(schema.NameserverSub, desired.Name, nil, &normalized)
+			op.apply = func() error { return nameserver.Create(db.WithContext(context.TODO())) }
+			op.undo = func() error { return nameserver.Delete(db.WithContext(context.TODO())) }
+			ops = append(ops, op)
+			continue
+		}
+		oldSpec := convNameserverToSpec(old)
+		newSpec := convNameserverToSpec(nameserver)
+		op.change = newNetworkSpecChange(schema.NameserverSub, desired.Name, &oldSpec, &newSpec)
+		if op.change.Action == "" {
+			continue
+		}
+		op.apply = func() error { return saveNetworkSpecResource(&nameserver) }
+		op.undo = func() error { return saveNetworkSpecResource(&old) }
+		ops = append(ops, op)
+	}
+	for _, ns := range state.nameservers {
+		if _, ok := current[ns.Name]; !ok || ns.Default {
+			continue
+		}
+		ns := ns
+		old := convNameserverToSpec(ns)
+		deletes = append(deletes, networkSpecOp{
+			change: newNetworkSpecChange[models.NetworkSpecNameserver](schema.NameserverSub, ns.Name, &old, nil),
+			apply:  func() error { return ns.Delete(db.WithContext(context.TODO())) },
+			undo:   func() error { return ns.Create(db.WithContext(context.TODO())) },
+		})
+	}
+	return ops, deletes, nil
+}
+
+// planNetworkSpecPostureChecks - plans the changes of posture checks
+func planNetworkSpecPostureChecks(spec models.NetworkSpec, state *networkSpecState, user string) (ops, deletes []networkSpecOp, err error) {
+	current := make(map[string]schema.PostureCheck)
+	for _, pc := range state.postureChecks {
+		current[pc.Name] = pc
+	}
+	for i := range spec.PostureChecks {
+		desired := spec.PostureChecks[i]
+		pc, exists := current[desired.Name]
+		delete(current, desired.Name)
+		old := pc
+		if !exists {
+			pc = schema.PostureCheck{
+				ID:        uuid.New().String(),
+				NetworkID: schema.NetworkID(spec.Network.Name),
+				CreatedBy: user,
+				CreatedAt: time.Now().UTC(),
+			}
+		}
+		pc.Name = desired.Name
+		pc.Description = desired.Description
+		pc.Attribute = desired.Attribute
+		pc.Values = append([]string{}, desired.Values...)
+		pc.Severity = desired.Severity
+		pc.Tags = convListToJSONMap(desired.Tags)
+		pc.UserGroups = convListToJSONMap(desired.UserGroups)
+		pc.Status = desired.Enabled
+		pc.UpdatedAt = time.Now().UTC()
+		postureCheck := pc
+		// values are normalized by the validation, compare against the normalized check
+		normalized := postureCheck
+		normalized.Values = append([]string{}, postureCheck.Values...)
+		if err := ValidatePostureCheck(&normalized); err == nil {
+			postureCheck.Values = normalized.Values
+		}
+		desired = convPostureCheckToSpec(postureCheck)
+		op := networkSpecOp{
+			validate: func() error { return ValidatePostureCheck(&postureCheck) },
+		}
+		if !exists {
+			op.change = newNetworkSpecChange(schema.PostureCheckSub, desired.Name, nil, &desired)
+			op.apply = func() error { return postureCheck.Create(db.WithContext(context.TODO())) }
+			op.undo = func() error { return postureCheck.Delete(db.WithContext(context.TODO())) }
+			ops = append(ops, op)
+			continue
+		}
+		oldSpec := convPostureCheckToSpec(old)
+		op.change = newNetworkSpecChange(schema.PostureCheckSub, desired.Name, &oldSpec, &desired)
+		if op.change.Action == "" {
+			continue
+		}
+		op.apply = func() error { return saveNetworkSpecResource(&postureCheck) }
+		op.undo = func() error { return saveNetworkSpecResource(&old) }
+		ops = append(ops, op)
+	}
+	for _, pc := range state.postureChecks {
+		if _, ok := current[pc.Name]; !ok {
+			continue
+		}
+		pc := pc
+		old := convPostureCheckToSpec(pc)
+		deletes = append(deletes, networkSpecOp{
+			change: newNetworkSpecChange[models.NetworkSpecPostureCheck](schema.PostureCheckSub, pc.Name, &old, nil),
+			apply:  func() error { return pc.Delete(db.WithContext(context.TODO())) },
+			undo:   func() error { return pc.Create(db.WithContext(context.TODO())) },
+		})
+	}
+	return ops, deletes, nil
+}
+
+// planNetworkSpecAcls - plans the changes of policies. Egress destinations
+// are referenced by egress name in the document. Default policies can only
+// be enabled or disabled and are never deleted.
+func planNetworkSpecAcls(spec models.NetworkSpec, state *networkSpecState, egressIDs map[string]string, user string) (ops, deletes []networkSpecOp, err error) {
+	netID := schema.NetworkID(spec.Network.Name)
+	current := make(map[string]models.Acl)
+	for _, acl := range state.acls {
+		current[acl.Name] = acl
+	}
+	for i := range spec.Acls {
+		desired := spec.Acls[i]
+		acl, exists := current[desired.Name]
+		delete(current, desired.Name)
+		old := acl
+		oldSpec := convAclToSpec(old, state.egressNames)
+		if exists && acl.Default {
+			desiredDefault := oldSpec
+			desiredDefault.Enabled = desired.Enabled
+			if fields := networkSpecDiff(desiredDefault, normalizeNetworkSpecAcl(desired, oldSpec)); len(fields) > 0 {
+				return nil, nil, fmt.Errorf("invalid acl %s: only enabled can be changed on default policies, got changes to %s",
+					desired.Name, strings.Join(fields, ", "))
+			}
+			change := newNetworkSpecChange(schema.AclSub, desired.Name, &oldSpec, &desiredDefault)
+			if change.Action == "" {
+				continue
+			}
+			acl.Enabled = desired.Enabled
+			ops = append(ops, networkSpecOp{
+				change: change,
+				apply:  func() error { return UpsertAcl(acl) },
+				undo:   func() error { return UpsertAcl(old) },
+			})
+			continue
+		}
+		if !exists {
+			acl = models.Acl{
+				ID:        uuid.New().String(),
+				NetworkID: netID,
+				CreatedBy: user,
+				CreatedAt: time.Now().UTC(),
+			}
+		}
+		desired = normalizeNetworkSpecAcl(desired, oldSpec)
+		acl.Name = desired.Name
+		acl.RuleType = desired.RuleType
+		acl.Src = desired.Src
+		acl.Dst = []models.AclPolicyTag{}
+		for _, dst := range desired.Dst {
+			if dst.ID == models.EgressID {
+				id, ok := egressIDs[dst.Value]
+				if !ok {
+					return nil, nil, fmt.Errorf("invalid acl %s: unknown egress %s", desired.Name, dst.Value)
+				}
+				dst.Value = id
+			}
+			acl.Dst = append(acl.Dst, dst)
+		}
+		acl.Proto = desired.Proto
+		acl.ServiceType = desired.ServiceType
+		acl.Port = desired.Port
+		acl.AllowedDirection = desired.AllowedDirection
+		acl.Action = desired.Action
+		acl.Priority = desired.Priority
+		acl.Schedule = desired.Schedule
+		acl.Enabled = desired.Enabled
+		policy := acl
+		op := networkSpecOp{
+			validate: func() error {
+				if err := ValidateCreateAclReq(policy); err != nil {
+					return err
+				}
+				return IsAclPolicyValid(policy)
+			},
+		}
+		if !exists {
+			op.change = newNetworkSpecChange(schema.AclSub, desired.Name, nil, &desired)
+			op.apply = func() error { return InsertAcl(policy) }
+			op.undo = func() error { return DeleteAcl(policy) }
+			ops = append(ops, op)
+			continue
+		}
+		op.change = newNetworkSpecChange(schema.AclSub, desired.Name, &oldSpec, &desired)
+		if op.change.Action == "" {
+			continue
+		}
+		op.apply = func() error { return UpsertAcl(policy) }
+		op.undo = func() error { return UpsertAcl(old) }
+		ops = append(ops, op)
+	}
+	for _, acl := range state.acls {
+		if _, ok := current[acl.Name]; !ok || acl.Default {
+			continue
+		}
+		acl := acl
+		old := convAclToSpec(acl, state.egressNames)
+		deletes = append(deletes, networkSpecOp{
+			change: newNetworkSpecChange[models.NetworkSpecAcl](schema.AclSub, acl.Name, &old, nil),
+			apply:  func() error { return DeleteAcl(acl) },
+			undo:   func() error { return UpsertAcl(acl) },
+		})
+	}
+	return ops, deletes, nil
+}
+
+// normalizeNetworkSpecAcl - fills in the defaults applied when a policy is
+// saved, an omitted priority keeps the current priority of the policy
+func normalizeNetworkSpecAcl(desired, current models.NetworkSpecAcl) models.NetworkSpecAcl {
+	if desired.ServiceType == models.Any {
+		desired.Port = []string{}
+		desired.Proto = models.ALL
+	}
+	if desired.Action == "" {
+		desired.Action = models.AclActionAllow
+	}
+	if desired.Priority == 0 {
+		desired.Priority = current.Priority
+	}
+	desired.Src = stripAclPolicyTagNames(desired.Src)
+	desired.Dst = stripAclPolicyTagNames(desired.Dst)
+	desired.Schedule = normalizeNetworkSpecSchedule(desired.Schedule)
+	return desired
+}
+
+// planNetworkSpecEnrollmentKeys - plans the changes of enrollment keys. The
+// relay, groups and gateway assignment of a key are updated in place, other
+// changes recreate the key. The remaining uses count down as hosts join, they
+// are only set on keys that are created.
+func planNetworkSpecEnrollmentKeys(spec models.NetworkSpec, state *networkSpecState) (ops, deletes []networkSpecOp, err error) {
+	current := make(map[string]models.EnrollmentKey)
+	for _, key := range state.keys {
+		current[key.Tags[0]] = key
+	}
+	for i := range spec.EnrollmentKeys {
+		desired := spec.EnrollmentKeys[i]
+		if desired.Expiration != nil {
+			expiration := desired.Expiration.UTC()
+			desired.Expiration = &expiration
+		}
+		relay := uuid.Nil
+		if desired.Relay != "" {
+			if relay, err = uuid.Parse(desired.Relay); err != nil {
+				return nil, nil, fmt.Errorf("invalid enrollment key %s: invalid relay %s", desired.Name, desired.Relay)
+			}
+		}
+		if relay != uuid.Nil {
+			desired.AutoAssignGateway = false
+		}
+		key, exists := current[desired.Name]
+		delete(current, desired.Name)
+		create := func() (*models.EnrollmentKey, error) {
+			expiration := time.Time{}
+			if desired.Expiration != nil {
+				expiration = *desired.Expiration
+			}
+			tags := []string{desired.Name}
+			if exists {
+				tags = key.Tags
+			}
+			return CreateEnrollmentKey(desired.UsesRemaining, expiration, []string{spec.Network.Name},
+				tags, desired.Groups, desired.Unlimited, relay, false, desired.AutoEgress, desired.AutoAssignGateway)
+		}
+		var created *models.EnrollmentKey
+		if !exists {
+			if err := validateNetworkSpecEnrollmentKey(desired); err != nil {
+				return nil, nil, err
+			}
+			ops = append(ops, networkSpecOp{
+				change: newNetworkSpecChange(schema.EnrollmentKeySub, desired.Name, nil, &desired),
+				apply: func() (err error) {
+					created, err = create()
+					return err
+				},
+				undo: func() error { return DeleteEnrollmentKey(created.Value, true) },
+			})
+			continue
+		}
+		old := key
+		oldSpec := convEnrollmentKeyToSpec(old)
+		compared := oldSpec
+		compared.UsesRemaining = desired.UsesRemaining
+		change := newNetworkSpecChange(schema.EnrollmentKeySub, desired.Name, &compared, &desired)
+		if change.Action == "" {
+			continue
+		}
+		change.Old = oldSpec
+		op := networkSpecOp{
+			change: change,
+			apply: func() error {
+				_, err := UpdateEnrollmentKey(old.Value, &models.APIEnrollmentKey{
+					Relay:             desired.Relay,
+					Groups:            desired.Groups,
+					AutoAssignGateway: desired.AutoAssignGateway,
+				})
+				return err
+			},
+			undo: func() error { return upsertEnrollmentKey(&old) },
+		}
+		for _, field := range change.Fields {
+			if field != "relay" && field != "groups" && field != "auto_assign_gw" {
+				op.change.Replace = true
+				break
+			}
+		}
+		if op.change.Replace {
+			if err := validateNetworkSpecEnrollmentKey(desired); err != nil {
+				return nil, nil, err
+			}
+			op.apply = func() (err error) {
+				if err = DeleteEnrollmentKey(old.Value, true); err != nil {
+					return err
+				}
+				created, err = create()
+				return err
+			}
+			op.undo = func() error {
+				if created != nil {
+					DeleteEnrollmentKey(created.Value, true)
+				}
+				return upsertEnrollmentKey(&old)
+			}
+		}
+		ops = append(ops, op)
+	}
+	for _, key := range state.keys {
+		if _, ok := current[key.Tags[0]]; !ok {
+			continue
+		}
+		key := key
+		old := convEnrollmentKeyToSpec(key)
+		deletes = append(deletes, networkSpecOp{
+			change: newNetworkSpecChange[models.NetworkSpecEnrollmentKey](schema.EnrollmentKeySub, key.Tags[0], &old, nil),
+			apply:  func() error { return DeleteEnrollmentKey(key.Value, true) },
+			undo:   func() error { return upsertEnrollmentKey(&key) },
+		})
+	}
+	return ops, deletes, nil
+}
+
+// validateNetworkSpecEnrollmentKey - keys are created with uses, an
+// expiration or unlimited
+func validateNetworkSpecEnrollmentKey(key models.NetworkSpecEnrollmentKey) error {
+	if key.UsesRemaining <= 0 && key.Expiration == nil && !key.Unlimited {
+		return fmt.Errorf("invalid enrollment key %s: uses, an expiration or unlimited is required", key.Name)
+	}
+	return nil
+}
+
+// saveNetworkSpecResource - saves all fields of a resource, including zero values
+func saveNetworkSpecResource(resource any) error {
+	return db.FromContext(db.WithContext(context.TODO())).Save(resource).Error
+}
+
+// newNetworkSpecChange - describes the change from old to new, a nil old is a
+// create and a nil new is a delete. The action is empty if nothing changed.
+func newNetworkSpecChange[T any](resource schema.SubjectType, name string, old, new *T) models.NetworkSpecChange {
+	change := models.NetworkSpecChange{
+		Resource: resource,
+		Name:     name,
+	}
+	switch {
+	case old == nil:
+		change.Action = schema.Create
+		change.New = *new
+	case new == nil:
+		change.Action = schema.Delete
+		change.Old = *old
+	default:
+		change.Fields = networkSpecDiff(*old, *new)
+		if len(change.Fields) > 0 {
+			change.Action = schema.Update
+			change.Old = *old
+			change.New = *new
+		}
+	}
+	return change
+}
+
+// networkSpecDiff - lists the json names of the fields that differ between two
+// documents of the same type, empty and omitted values are considered equal
+func networkSpecDiff(old, new any) []string {
+	fields := []string{}
+	oldV := reflect.ValueOf(old)
+	newV := reflect.ValueOf(new)
+	for i := 0; i < oldV.NumField(); i++ {
+		oldData, _ := json.Marshal(oldV.Field(i).Interface())
+		newData, _ := json.Marshal(newV.Field(i).Interface())
+		if normalizeNetworkSpecJSON(oldData) != normalizeNetworkSpecJSON(newData) {
+			name, _, _ := strings.Cut(oldV.Type().Field(i).Tag.Get("json"), ",")
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+func normalizeNetworkSpecJSON(data []byte) string {
+	switch s := string(data); s {
+	case "null", "[]", "{}", `""`, "0", "false":
+		return ""
+	default:
+		return s
+	}
+}
+
+func convNetworkToSpec(network *schema.Network) models.NetworkSpecSettings {
+	return models.NetworkSpecSettings{
+		Name:                        network.Name,
+		AddressRange:                network.AddressRange,
+		AddressRange6:               network.AddressRange6,
+		AutoJoin:                    network.AutoJoin,
+		AutoRemove:                  network.AutoRemove,
+		AutoRemoveThreshold:         network.AutoRemoveThreshold,
+		AutoRemoveTags:              network.AutoRemoveTags,
+		VirtualNATPoolIPv4:          network.VirtualNATPoolIPv4,
+		VirtualNATSitePrefixLenIPv4: network.VirtualNATSitePrefixLenIPv4,
+	}
+}
+
+func convTagToSpec(tag models.Tag) models.NetworkSpecTag {
+	return models.NetworkSpecTag{
+		Name:      tag.TagName,
+		ColorCode: tag.ColorCode,
+	}
+}
+
+func convAclToSpec(acl models.Acl, egressNames map[string]string) models.NetworkSpecAcl {
+	dst := []models.AclPolicyTag{}
+	for _, dstI := range stripAclPolicyTagNames(acl.Dst) {
+		if name, ok := egressNames[dstI.Value]; ok && dstI.ID == models.EgressID {
+			dstI.Value = name
+		}
+		dst = append(dst, dstI)
+	}
+	return models.NetworkSpecAcl{
+		Name:             acl.Name,
+		RuleType:         acl.RuleType,
+		Src:              stripAclPolicyTagNames(acl.Src),
+		Dst:              dst,
+		Proto:            acl.Proto,
+		ServiceType:      acl.ServiceType,
+		Port:             acl.Port,
+		AllowedDirection: acl.AllowedDirection,
+		Action:           acl.Action,
+		Priority:         acl.Priority,
+		Schedule:         normalizeNetworkSpecSchedule(acl.Schedule),
+		Enabled:          acl.Enabled,
+	}
+}
+
+// stripAclPolicyTagNames - drops the display names of policy tags
+func stripAclPolicyTagNames(tags []models.AclPolicyTag) []models.AclPolicyTag {
+	stripped := []models.AclPolicyTag{}
+	for _, tag := range tags {
+		stripped = append(stripped, models.AclPolicyTag{ID: tag.ID, Value: tag.Value})
+	}
+	return stripped
+}
+
+func normalizeNetworkSpecSchedule(schedule *models.AclSchedule) *models.AclSchedule {
+	if schedule == nil {
+		return nil
+	}
+	normalized := *schedule
+	if normalized.From != nil {
+		from := normalized.From.UTC()
+		normalized.From = &from
+	}
+	if normalized.Until != nil {
+		until := normalized.Until.UTC()
+		normalized.Until = &until
+	}
+	return &normalized
+}
+
+func convEgressToSpec(e schema.Egress) models.NetworkSpecEgress {
+	spec := models.NetworkSpecEgress{
+		Name:         e.Name,
+		Description:  e.Description,
+		Range:        e.Range,
+		Domain:       e.Domain,
+		Nat:          e.Nat,
+		Mode:         e.Mode,
+		VirtualRange: e.VirtualRange,
+		Nodes:        make(map[string]int),
+		Tags:         make(map[string]int),
+		Enabled:      e.Status,
+	}
+	for nodeID, metric := range e.Nodes {
+		spec.Nodes[nodeID] = convNetworkSpecMetric(metric)
+	}
+	for tagID, metric := range e.Tags {
+		spec.Tags[tagID] = convNetworkSpecMetric(metric)
+	}
+	return spec
+}
+
+// convNetworkSpecMetric - route metrics are numbers of any type once read back from the db
+func convNetworkSpecMetric(metric any) int {
+	switch m := metric.(type) {
+	case int:
+		return m
+	case int64:
+		return int(m)
+	case float64:
+		return int(m)
+	case json.Number:
+		n, _ := m.Int64()
+		return int(n)
+	default:
+		return 0
+	}
+}
+
+func convNameserverToSpec(ns schema.Nameserver) models.NetworkSpecNameserver {
+	spec := models.NetworkSpecNameserver{
+		Name:        ns.Name,
+		Description: ns.Description,
+		Servers:     ns.Servers,
+		MatchAll:    ns.MatchAll,
+		Domains:     []models.NetworkSpecNameserverDomain{},
+		Fallback:    ns.Fallback,
+		Tags:        convJSONMapToList(ns.Tags),
+		Nodes:       convJSONMapToList(ns.Nodes),
+		Enabled:     ns.Status,
+	}
+	if !ns.MatchAll {
+		for _, domain := range ns.Domains {
+			spec.Domains = append(spec.Domains, models.NetworkSpecNameserverDomain(domain))
+		}
+	}
+	return spec
+}
+
+func convEnrollmentKeyToSpec(key models.EnrollmentKey) models.NetworkSpecEnrollmentKey {
+	spec := models.NetworkSpecEnrollmentKey{
+		Name:              key.Tags[0],
+		UsesRemaining:     key.UsesRemaining,
+		Unlimited:         key.Unlimited,
+		Groups:            key.Groups,
+		AutoEgress:        key.AutoEgress,
+		AutoAssignGateway: key.AutoAssignGateway,
+	}
+	if !key.Expiration.IsZero() {
+		expiration := key.Expiration.UTC()
+		spec.Expiration = &expiration
+	}
+	if key.Relay != uuid.Nil {
+		spec.Relay = key.Relay.String()
+	}
+	return spec
+}
+
+func convPostureCheckToSpec(pc schema.PostureCheck) models.NetworkSpecPostureCheck {
+	return models.NetworkSpecPostureCheck{
+		Name:        pc.Name,
+		Description: pc.Description,
+		Attribute:   pc.Attribute,
+		Values:      pc.Values,
+		Severity:    pc.Severity,
+		Tags:        convJSONMapToList(pc.Tags),
+		UserGroups:  convJSONMapToList(pc.UserGroups),
+		Enabled:     pc.Status,
+	}
+}
+
+func convListToJSONMap(list []string) datatypes.JSONMap {
+	m := make(datatypes.JSONMap)
+	for _, item := range list {
+		m[item] = struct{}{}
+	}
+	return m
+}
+
+func convJSONMapToList(m datatypes.JSONMap) []string {
+	list := []string{}
+	for key := range m {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,211 @@
+package logic
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func TestNetworkSpecDiff(t *testing.T) {
+	old := models.NetworkSpecEgress{
+		Name:    "office",
+		Range:   "10.10.0.0/16",
+		Nodes:   map[string]int{"node-1": 256},
+		Tags:    map[string]int{},
+		Enabled: true,
+	}
+	t.Run("empty and omitted values are equal", func(t *testing.T) {
+		desired := old
+		desired.Tags = nil
+		assert.Empty(t, networkSpecDiff(old, desired))
+	})
+	t.Run("changed fields are listed", func(t *testing.T) {
+		desired := old
+		desired.Range = "10.11.0.0/16"
+		desired.Nodes = map[string]int{"node-2": 256}
+		assert.Equal(t, []string{"range", "nodes"}, networkSpecDiff(old, desired))
+	})
+}
+
+func TestNewNetworkSpecChange(t *testing.T) {
+	tag := models.NetworkSpecTag{Name: "servers"}
+	change := newNetworkSpecChange(schema.TagSub, tag.Name, nil, &tag)
+	assert.Equal(t, schema.Create, change.Action)
+	change = newNetworkSpecChange[models.NetworkSpecTag](schema.TagSub, tag.Name, &tag, nil)
+	assert.Equal(t, schema.Delete, change.Action)
+	change = newNetworkSpecChange(schema.TagSub, tag.Name, &tag, &tag)
+	assert.Equal(t, schema.Action(""), change.Action)
+	updated := tag
+	updated.ColorCode = "#2f80ed"
+	change = newNetworkSpecChange(schema.TagSub, tag.Name, &tag, &updated)
+	assert.Equal(t, schema.Update, change.Action)
+	assert.Equal(t, []string{"color_code"}, change.Fields)
+}
+
+func TestConvEgressToSpec(t *testing.T) {
+	// metrics read back from the db are decoded as float64
+	spec := convEgressToSpec(schema.Egress{
+		Name:  "office",
+		Nodes: datatypes.JSONMap{"node-1": float64(256)},
+		Tags:  datatypes.JSONMap{},
+	})
+	assert.Equal(t, map[string]int{"node-1": 256}, spec.Nodes)
+}
+
+func TestValidateNetworkSpec(t *testing.T) {
+	spec := models.NetworkSpec{
+		APIVersion: models.NetworkSpecAPIVersion,
+		Kind:       models.NetworkSpecKind,
+		Network:    models.NetworkSpecSettings{Name: "office"},
+		Tags:       []models.NetworkSpecTag{{Name: "servers"}},
+	}
+	assert.NoError(t, validateNetworkSpec(spec))
+	spec.Tags = append(spec.Tags, models.NetworkSpecTag{Name: "servers"})
+	assert.Error(t, validateNetworkSpec(spec))
+	spec.Tags = nil
+	spec.Kind = "Host"
+	assert.Error(t, validateNetworkSpec(spec))
+}
+
+func TestPlanNetworkSpecEnrollmentKeys(t *testing.T) {
+	spec := models.NetworkSpec{Network: models.NetworkSpecSettings{Name: "office"}}
+	exported := models.EnrollmentKey{
+		Value:         "exported",
+		Networks:      []string{"office"},
+		Tags:          []string{"laptops"},
+		UsesRemaining: 5,
+		Type:          models.Uses,
+	}
+	t.Run("used keys are not replaced", func(t *testing.T) {
+		key := exported
+		key.UsesRemaining = 2
+		spec.EnrollmentKeys = []models.NetworkSpecEnrollmentKey{convEnrollmentKeyToSpec(exported)}
+		ops, deletes, err := planNetworkSpecEnrollmentKeys(spec, &networkSpecState{keys: []models.EnrollmentKey{key}})
+		assert.NoError(t, err)
+		assert.Empty(t, ops)
+		assert.Empty(t, deletes)
+	})
+	t.Run("exhausted keys are kept", func(t *testing.T) {
+		key := exported
+		key.UsesRemaining = 0
+		spec.EnrollmentKeys = []models.NetworkSpecEnrollmentKey{convEnrollmentKeyToSpec(key)}
+		ops, _, err := planNetworkSpecEnrollmentKeys(spec, &networkSpecState{keys: []models.EnrollmentKey{key}})
+		assert.NoError(t, err)
+		assert.Empty(t, ops)
+	})
+	t.Run("other changes replace the key", func(t *testing.T) {
+		key := exported
+		key.UsesRemaining = 2
+		desired := convEnrollmentKeyToSpec(exported)
+		desired.AutoEgress = true
+		spec.EnrollmentKeys = []models.NetworkSpecEnrollmentKey{desired}
+		ops, _, err := planNetworkSpecEnrollmentKeys(spec, &networkSpecState{keys: []models.EnrollmentKey{key}})
+		assert.NoError(t, err)
+		if assert.Len(t, ops, 1) {
+			assert.True(t, ops[0].change.Replace)
+			assert.Equal(t, []string{"auto_egress"}, ops[0].change.Fields)
+			assert.Equal(t, 2, ops[0].change.Old.(models.NetworkSpecEnrollmentKey).UsesRemaining)
+		}
+
+		// the key is created again, it needs uses
+		key.UsesRemaining = 0
+		desired.UsesRemaining = 0
+		spec.EnrollmentKeys = []models.NetworkSpecEnrollmentKey{desired}
+		_, _, err = planNetworkSpecEnrollmentKeys(spec, &networkSpecState{keys: []models.EnrollmentKey{key}})
+		assert.Error(t, err)
+	})
+	t.Run("new keys need uses", func(t *testing.T) {
+		spec.EnrollmentKeys = []models.NetworkSpecEnrollmentKey{{Name: "servers"}}
+		_, _, err := planNetworkSpecEnrollmentKeys(spec, &networkSpecState{})
+		assert.Error(t, err)
+	})
+}
+
+func TestPlanNetworkSpecTagDeleteUndo(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	database.InitializeDatabase()
+
+	tag := models.Tag{
+		ID:      models.TagID("spec-tags.servers"),
+		TagName: "servers",
+		Network: "spec-tags",
+	}
+	tags := map[models.TagID]struct{}{tag.ID: {}}
+	node := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), Network: "spec-tags"}, Tags: tags}
+	untagged := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), Network: "spec-tags"}}
+	client := models.ExtClient{ClientID: "spec-tags-client", Network: "spec-tags", Tags: tags}
+	require.NoError(t, UpsertNode(&node))
+	defer DeleteNodeByID(&node)
+	require.NoError(t, UpsertNode(&untagged))
+	defer DeleteNodeByID(&untagged)
+	require.NoError(t, SaveExtClient(&client))
+	defer DeleteExtClient(client.Network, client.ClientID, true)
+
+	// DeleteTag of pro untags the devices
+	upsertTag, deleteTag := UpsertTag, DeleteTag
+	defer func() { UpsertTag, DeleteTag = upsertTag, deleteTag }()
+	UpsertTag = func(models.Tag) error { return nil }
+	DeleteTag = func(tagID models.TagID, removeFromPolicy bool) error {
+		n, err := GetNodeByID(node.ID.String())
+		require.NoError(t, err)
+		delete(n.Tags, tagID)
+		require.NoError(t, UpsertNode(&n))
+		c, err := GetExtClient(client.ClientID, client.Network)
+		require.NoError(t, err)
+		delete(c.Tags, tagID)
+		return SaveExtClient(&c)
+	}
+
+	spec := models.NetworkSpec{Network: models.NetworkSpecSettings{Name: "spec-tags"}}
+	_, deletes, err := planNetworkSpecTags(spec, &networkSpecState{tags: []models.Tag{tag}}, "admin")
+	require.NoError(t, err)
+	require.Len(t, deletes, 1)
+	require.NoError(t, deletes[0].apply())
+	n, err := GetNodeByID(node.ID.String())
+	require.NoError(t, err)
+	assert.NotContains(t, n.Tags, tag.ID)
+
+	require.NoError(t, deletes[0].undo())
+	n, err = GetNodeByID(node.ID.String())
+	require.NoError(t, err)
+	assert.Contains(t, n.Tags, tag.ID)
+	n, err = GetNodeByID(untagged.ID.String())
+	require.NoError(t, err)
+	assert.NotContains(t, n.Tags, tag.ID)
+	c, err := GetExtClient(client.ClientID, client.Network)
+	require.NoError(t, err)
+	assert.Contains(t, c.Tags, tag.ID)
+}
+
+func TestRevertNetworkSpecOps(t *testing.T) {
+	var undone []string
+	op := func(name string, err error) networkSpecOp {
+		return networkSpecOp{
+			change: newNetworkSpecChange(schema.TagSub, name, nil, &models.NetworkSpecTag{Name: name}),
+			undo: func() error {
+				undone = append(undone, name)
+				return err
+			},
+		}
+	}
+	cause := errors.New("failed to create tag web")
+	undoErr := errors.New("tag not found")
+	err := revertNetworkSpecOps([]networkSpecOp{op("servers", nil), op("db", undoErr), op("clients", nil)}, cause)
+	assert.Equal(t, []string{"clients", "db", "servers"}, undone)
+	assert.ErrorIs(t, err, cause)
+	assert.ErrorIs(t, err, undoErr)
+	assert.ErrorContains(t, err, "failed to revert tag db")
+
+	err = revertNetworkSpecOps([]networkSpecOp{op("servers", nil)}, cause)
+	assert.ErrorIs(t, err, cause)
+	assert.Equal(t, cause.Error(), err.Error())
+}
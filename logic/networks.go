@@ -46,45 +46,80 @@ func SetAllocatedIpMap() error {
 	}
 
 	for _, v := range currentNetworks {
-		pMap := map[string]net.IP{}
-		netName := v.Name
+		allocatedIpMap[v.Name] = loadAllocatedIps(v.Name)
+	}
+	logger.Log(0, "setting up allocated ip map done")
+	return nil
+}
 
-		//nodes
-		nodes, err := GetNetworkNodes(netName)
-		if err != nil {
-			slog.Error("could not load node for network", netName, "error", err.Error())
-		} else {
-			for _, n := range nodes {
+// loadAllocatedIps - collects the addresses of the nodes and ext clients of a network
+func loadAllocatedIps(netName string) map[string]net.IP {
+	pMap := map[string]net.IP{}
 
-				if n.Address.IP != nil {
-					pMap[n.Address.IP.String()] = n.Address.IP
-				}
-				if n.Address6.IP != nil {
-					pMap[n.Address6.IP.String()] = n.Address6.IP
-				}
-			}
+	//nodes
+	nodes, err := GetNetworkNodes(netName)
+	if err != nil {
+		slog.Error("could not load node for network", netName, "error", err.Error())
+	} else {
+		for _, n := range nodes {
 
+			if n.Address.IP != nil {
+				pMap[n.Address.IP.String()] = n.Address.IP
+			}
+			if n.Address6.IP != nil {
+				pMap[n.Address6.IP.String()] = n.Address6.IP
+			}
 		}
 
-		//extClients
-		extClients, err := GetNetworkExtClients(netName)
-		if err != nil {
-			slog.Error("could not load extClient for network", netName, "error", err.Error())
-		} else {
-			for _, extClient := range extClients {
-				if extClient.Address != "" {
-					pMap[extClient.Address] = net.ParseIP(extClient.Address)
-				}
-				if extClient.Address6 != "" {
-					pMap[extClient.Address6] = net.ParseIP(extClient.Address6)
-				}
+	}
+
+	//extClients
+	extClients, err := GetNetworkExtClients(netName)
+	if err != nil {
+		slog.Error("could not load extClient for network", netName, "error", err.Error())
+	} else {
+		for _, extClient := range extClients {
+			if extClient.Address != "" {
+				pMap[extClient.Address] = net.ParseIP(extClient.Address)
+			}
+			if extClient.Address6 != "" {
+				pMap[extClient.Address6] = net.ParseIP(extClient.Address6)
 			}
 		}
+	}
+	return pMap
+}
 
+// invalidateAllocatedIps - rebuilds the allocated ips of a network changed by another replica
+func invalidateAllocatedIps(netName string) {
+	if !servercfg.CacheEnabled() {
+		return
+	}
+	network := &schema.Network{Name: netName}
+	if err := network.Get(db.WithContext(context.TODO())); err != nil {
+		networkCacheMutex.Lock()
+		delete(allocatedIpMap, netName)
+		networkCacheMutex.Unlock()
+		return
+	}
+	pMap := loadAllocatedIps(netName)
+	networkCacheMutex.Lock()
+	if allocatedIpMap != nil {
 		allocatedIpMap[netName] = pMap
 	}
-	logger.Log(0, "setting up allocated ip map done")
-	return nil
+	networkCacheMutex.Unlock()
+}
+
+func reloadAllocatedIps() {
+	if !servercfg.CacheEnabled() {
+		return
+	}
+	networkCacheMutex.Lock()
+	allocatedIpMap = map[string]map[string]net.IP{}
+	networkCacheMutex.Unlock()
+	if err := SetAllocatedIpMap(); err != nil {
+		slog.Error("failed to reload allocated ip map", "error", err)
+	}
 }
 
 // ClearAllocatedIpMap - set allocatedIpMap to nil
@@ -104,6 +139,7 @@ func AddIpToAllocatedIpMap(networkName string, ip net.IP) {
 		m[ip.String()] = ip
 	}
 	networkCacheMutex.Unlock()
+	InvalidateCache(CacheKindAllocatedIPs, networkName)
 }
 
 func RemoveIpFromAllocatedIpMap(networkName string, ip string) {
@@ -115,6 +151,7 @@ func RemoveIpFromAllocatedIpMap(networkName string, ip string) {
 		delete(m, ip)
 	}
 	networkCacheMutex.Unlock()
+	InvalidateCache(CacheKindAllocatedIPs, networkName)
 }
 
 // AddNetworkToAllocatedIpMap - add network to allocated ip map when network is added
@@ -126,6 +163,7 @@ func AddNetworkToAllocatedIpMap(networkName string) {
 	networkCacheMutex.Lock()
 	allocatedIpMap[networkName] = make(map[string]net.IP)
 	networkCacheMutex.Unlock()
+	InvalidateCache(CacheKindAllocatedIPs, networkName)
 }
 
 // RemoveNetworkFromAllocatedIpMap - remove network from allocated ip map when network is deleted
@@ -136,6 +174,7 @@ func RemoveNetworkFromAllocatedIpMap(networkName string) {
 	networkCacheMutex.Lock()
 	delete(allocatedIpMap, networkName)
 	networkCacheMutex.Unlock()
+	InvalidateCache(CacheKindAllocatedIPs, networkName)
 }
 
 // DeleteNetwork - deletes a network
@@ -152,37 +191,56 @@ func DeleteNetwork(network string, force bool, done chan struct{}) error {
 
 	// Remove All Nodes
 	go func() {
-		nodes, err := GetNetworkNodes(network)
-		if err == nil {
-			for _, node := range nodes {
-				node := node
-				host := &schema.Host{ID: node.HostID}
-				if err := host.Get(db.WithContext(context.TODO())); err != nil {
-					continue
-				}
+		if err := DeleteNetworkNodes(context.Background(), network, nil); err != nil {
+			return
+		}
+		done <- struct{}{}
+		close(done)
+	}()
+
+	return nil
+}
+
+// DeleteNetworkNodes - removes the nodes of the network from their hosts and
+// then deletes the network record, step is called after each node. It stops
+// early once ctx is done.
+func DeleteNetworkNodes(ctx context.Context, network string, step func()) error {
+	nodes, err := GetNetworkNodes(network)
+	if err == nil {
+		for _, node := range nodes {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			node := node
+			host := &schema.Host{ID: node.HostID}
+			if err := host.Get(db.WithContext(context.TODO())); err == nil {
 				if node.IsGw {
 					// delete ext clients belonging to gateway
 					DeleteGatewayExtClients(node.ID.String(), node.Network)
 				}
 				DissasociateNodeFromHost(&node, host)
 			}
+			if step != nil {
+				step()
+			}
 		}
-		// delete server nodes first then db records
-		_network := &schema.Network{
-			Name: network,
-		}
-		err = _network.Delete(db.WithContext(context.TODO()))
-		if err != nil {
-			return
-		}
-		done <- struct{}{}
-		close(done)
-	}()
+	}
+	// delete server nodes first then db records
+	_network := &schema.Network{
+		Name: network,
+	}
+	if err := _network.Delete(db.WithContext(context.TODO())); err != nil {
+		return err
+	}
+	deleteDefaultEnrollmentKey(network)
+	return nil
+}
 
-	// Delete default network enrollment key
+// deleteDefaultEnrollmentKey - deletes the default enrollment key of the network
+func deleteDefaultEnrollmentKey(network string) {
 	keys, _ := GetAllEnrollmentKeys()
 	for _, key := range keys {
-		if key.Tags[0] == network {
+		if len(key.Tags) > 0 && key.Tags[0] == network {
 			if key.Default {
 				DeleteEnrollmentKey(key.Value, true)
 				break
@@ -190,8 +248,6 @@ func DeleteNetwork(network string, force bool, done chan struct{}) error {
 
 		}
 	}
-
-	return nil
 }
 
 // AssignVirtualNATDefaults determines safe defaults based on VPN CIDR
@@ -939,9 +995,10 @@ var NetworkHook models.HookFunc = func(params ...interface{}) error {
 
 func InitNetworkHooks() {
 	HookManagerCh <- models.HookDetails{
-		ID:       "network-hook",
-		Hook:     NetworkHook,
-		Interval: time.Duration(GetServerSettings().CleanUpInterval) * time.Minute,
+		ID:         "network-hook",
+		Hook:       NetworkHook,
+		Interval:   time.Duration(GetServerSettings().CleanUpInterval) * time.Minute,
+		LeaderOnly: true,
 	}
 }
 
@@ -16,6 +16,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/leader"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/schema"
@@ -132,6 +133,32 @@ func ClearNodeCache() {
 	nodeCacheMutex.Unlock()
 }
 
+// invalidateNodeCache - refreshes a node changed by another replica
+func invalidateNodeCache(nodeID string) {
+	if !servercfg.CacheEnabled() {
+		return
+	}
+	if node, ok := getNodeFromCache(nodeID); ok {
+		deleteNodeFromCache(nodeID)
+		deleteNodeFromNetworkCache(nodeID, node.Network)
+	}
+	nodeCacheMutex.RLock()
+	populated := len(nodesCacheMap) > 0
+	nodeCacheMutex.RUnlock()
+	// an empty cache is loaded completely on next use
+	if populated {
+		_, _ = GetNodeByID(nodeID)
+	}
+}
+
+func reloadNodeCache() {
+	if !servercfg.CacheEnabled() {
+		return
+	}
+	ClearNodeCache()
+	_, _ = GetAllNodes()
+}
+
 const (
 	// RELAY_NODE_ERR - error to return if relay node is unfound
 	RELAY_NODE_ERR = "could not find relay for node"
@@ -265,6 +292,7 @@ func UpsertNode(newNode *models.Node) error {
 		storeNodeInCache(*newNode)
 		storeNodeInNetworkCache(*newNode, newNode.Network)
 	}
+	InvalidateCache(CacheKindNode, newNode.ID.String())
 	return nil
 }
 
@@ -298,6 +326,7 @@ func UpdateNode(currentNode *models.Node, newNode *models.Node) error {
 			if err != nil {
 				return err
 			}
+			InvalidateCache(CacheKindNode, newNode.ID.String())
 			if servercfg.CacheEnabled() {
 				storeNodeInCache(*newNode)
 				storeNodeInNetworkCache(*newNode, newNode.Network)
@@ -454,6 +483,7 @@ func DeleteNodeByID(node *models.Node) error {
 		deleteNodeFromCache(node.ID.String())
 		deleteNodeFromNetworkCache(node.ID.String(), node.Network)
 	}
+	InvalidateCache(CacheKindNode, node.ID.String())
 	if servercfg.IsDNSMode() {
 		SetDNS()
 	}
@@ -735,6 +765,9 @@ func DeleteExpiredNodes(ctx context.Context) {
 			ticker.Stop()
 			return
 		case <-ticker.C:
+			if !leader.IsLeader() {
+				continue
+			}
 			allnodes, err := GetAllNodes()
 			if err != nil {
 				slog.Error("failed to retrieve all nodes", "error", err.Error())
@@ -820,6 +853,7 @@ func createNode(node *models.Node) error {
 	if err != nil {
 		return err
 	}
+	InvalidateCache(CacheKindNode, node.ID.String())
 	if servercfg.CacheEnabled() {
 		storeNodeInCache(*node)
 		storeNodeInNetworkCache(*node, node.Network)
@@ -0,0 +1,89 @@
+package logic
+
+import (
+	"strings"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+)
+
+// PeerUpdateIndex - maps the objects a peer update change can reference to
+// the hosts whose peer update depends on them. Peers, tags, policies, posture
+// checks, nameservers and gateways are all scoped to a network, so a change
+// to any of them affects the hosts with a node in that network.
+type PeerUpdateIndex struct {
+	networkHosts map[schema.NetworkID]map[string]struct{}
+	nodeNetwork  map[string]schema.NetworkID
+	hostNetworks map[string][]schema.NetworkID
+}
+
+// NewPeerUpdateIndex - builds the dependency index from the current nodes
+func NewPeerUpdateIndex(allNodes []models.Node) *PeerUpdateIndex {
+	idx := &PeerUpdateIndex{
+		networkHosts: make(map[schema.NetworkID]map[string]struct{}),
+		nodeNetwork:  make(map[string]schema.NetworkID, len(allNodes)),
+		hostNetworks: make(map[string][]schema.NetworkID),
+	}
+	for _, node := range allNodes {
+		network := schema.NetworkID(node.Network)
+		hostID := node.HostID.String()
+		if idx.networkHosts[network] == nil {
+			idx.networkHosts[network] = make(map[string]struct{})
+		}
+		idx.networkHosts[network][hostID] = struct{}{}
+		idx.nodeNetwork[node.ID.String()] = network
+		idx.hostNetworks[hostID] = append(idx.hostNetworks[hostID], network)
+	}
+	return idx
+}
+
+// AffectedHosts - resolves the hosts affected by the change, all is true if
+// the change is global or references an object that could not be resolved,
+// in which case every host has to be updated
+func (idx *PeerUpdateIndex) AffectedHosts(change models.PeerUpdateChange) (hosts map[string]struct{}, all bool) {
+	if change.IsGlobal() {
+		return nil, true
+	}
+	networks := make(map[schema.NetworkID]struct{})
+	hosts = make(map[string]struct{})
+	for _, network := range change.Networks {
+		networks[network] = struct{}{}
+	}
+	for _, nodeID := range change.Nodes {
+		network, ok := idx.nodeNetwork[nodeID]
+		if !ok {
+			// deleted or unknown node, its peers can't be resolved anymore
+			return nil, true
+		}
+		networks[network] = struct{}{}
+	}
+	for _, hostID := range change.Hosts {
+		hosts[hostID] = struct{}{}
+		for _, network := range idx.hostNetworks[hostID] {
+			networks[network] = struct{}{}
+		}
+	}
+	for _, tagID := range change.Tags {
+		network, _, ok := strings.Cut(tagID.String(), ".")
+		if !ok {
+			return nil, true
+		}
+		networks[schema.NetworkID(network)] = struct{}{}
+	}
+	for _, policyID := range change.Policies {
+		acl, err := GetAcl(policyID)
+		if err != nil {
+			return nil, true
+		}
+		networks[acl.NetworkID] = struct{}{}
+	}
+	if _, ok := networks[schema.AllNetworks]; ok {
+		return nil, true
+	}
+	for network := range networks {
+		for hostID := range idx.networkHosts[network] {
+			hosts[hostID] = struct{}{}
+		}
+	}
+	return hosts, false
+}
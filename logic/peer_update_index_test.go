@@ -0,0 +1,130 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func testPeerUpdateNode(network string, hostID uuid.UUID) models.Node {
+	node := models.Node{}
+	node.ID = uuid.New()
+	node.HostID = hostID
+	node.Network = network
+	return node
+}
+
+func TestPeerUpdateIndexAffectedHosts(t *testing.T) {
+	h1, h2, h3 := uuid.New(), uuid.New(), uuid.New()
+	n1 := testPeerUpdateNode("net1", h1)
+	n2 := testPeerUpdateNode("net1", h2)
+	n3 := testPeerUpdateNode("net2", h2)
+	n4 := testPeerUpdateNode("net3", h3)
+	idx := NewPeerUpdateIndex([]models.Node{n1, n2, n3, n4})
+
+	hostSet := func(ids ...uuid.UUID) map[string]struct{} {
+		set := make(map[string]struct{})
+		for _, id := range ids {
+			set[id.String()] = struct{}{}
+		}
+		return set
+	}
+
+	t.Run("global", func(t *testing.T) {
+		_, all := idx.AffectedHosts(models.PeerUpdateChange{})
+		assert.True(t, all)
+		_, all = idx.AffectedHosts(models.PeerUpdateChange{Global: true, Networks: []schema.NetworkID{"net1"}})
+		assert.True(t, all)
+		_, all = idx.AffectedHosts(models.PeerUpdateChange{Networks: []schema.NetworkID{schema.AllNetworks}})
+		assert.True(t, all)
+	})
+	t.Run("network", func(t *testing.T) {
+		hosts, all := idx.AffectedHosts(models.PeerUpdateChange{Networks: []schema.NetworkID{"net2"}})
+		assert.False(t, all)
+		assert.Equal(t, hostSet(h2), hosts)
+	})
+	t.Run("node", func(t *testing.T) {
+		hosts, all := idx.AffectedHosts(models.PeerUpdateChange{Nodes: []string{n1.ID.String()}})
+		assert.False(t, all)
+		assert.Equal(t, hostSet(h1, h2), hosts)
+		_, all = idx.AffectedHosts(models.PeerUpdateChange{Nodes: []string{uuid.NewString()}})
+		assert.True(t, all)
+	})
+	t.Run("host", func(t *testing.T) {
+		hosts, all := idx.AffectedHosts(models.PeerUpdateChange{Hosts: []string{h2.String()}})
+		assert.False(t, all)
+		assert.Equal(t, hostSet(h1, h2), hosts)
+	})
+	t.Run("tag", func(t *testing.T) {
+		hosts, all := idx.AffectedHosts(models.PeerUpdateChange{Tags: []models.TagID{"net3.web"}})
+		assert.False(t, all)
+		assert.Equal(t, hostSet(h3), hosts)
+		_, all = idx.AffectedHosts(models.PeerUpdateChange{Tags: []models.TagID{"web"}})
+		assert.True(t, all)
+	})
+}
+
+func TestPeerUpdateChangeMerge(t *testing.T) {
+	change := models.PeerUpdateChange{Networks: []schema.NetworkID{"net1"}}
+	change.Merge(models.PeerUpdateChange{Tags: []models.TagID{"net2.web"}})
+	assert.False(t, change.IsGlobal())
+	assert.Equal(t, []schema.NetworkID{"net1"}, change.Networks)
+	assert.Equal(t, []models.TagID{"net2.web"}, change.Tags)
+	change.Merge(models.PeerUpdateChange{})
+	assert.True(t, change.IsGlobal())
+}
+
+// BenchmarkPeerUpdateFanOut compares a full broadcast with a publish targeted
+// at a single network, the per host work stands in for computing and encoding
+// the host's peer update.
+func BenchmarkPeerUpdateFanOut(b *testing.B) {
+	const networks, hostsPerNetwork = 50, 40
+	var allNodes []models.Node
+	var hostIDs []string
+	networkPeers := make(map[string][]wgtypes.PeerConfig)
+	for n := 0; n < networks; n++ {
+		network := fmt.Sprintf("net%d", n)
+		for h := 0; h < hostsPerNetwork; h++ {
+			hostID := uuid.New()
+			hostIDs = append(hostIDs, hostID.String())
+			allNodes = append(allNodes, testPeerUpdateNode(network, hostID))
+			key, _ := wgtypes.GenerateKey()
+			networkPeers[network] = append(networkPeers[network], wgtypes.PeerConfig{PublicKey: key.PublicKey()})
+		}
+	}
+	hostNetwork := make(map[string]string, len(allNodes))
+	for _, node := range allNodes {
+		hostNetwork[node.HostID.String()] = node.Network
+	}
+	publish := func(b *testing.B, change models.PeerUpdateChange) {
+		var fanOut int
+		for i := 0; i < b.N; i++ {
+			targets := hostIDs
+			if hosts, all := NewPeerUpdateIndex(allNodes).AffectedHosts(change); !all {
+				targets = targets[:0:0]
+				for hostID := range hosts {
+					targets = append(targets, hostID)
+				}
+			}
+			for _, hostID := range targets {
+				if _, err := json.Marshal(models.HostPeerUpdate{Peers: networkPeers[hostNetwork[hostID]]}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			fanOut = len(targets)
+		}
+		b.ReportMetric(float64(fanOut), "hosts/op")
+	}
+	b.Run("full", func(b *testing.B) {
+		publish(b, models.PeerUpdateChange{})
+	})
+	b.Run("targeted", func(b *testing.B) {
+		publish(b, models.PeerUpdateChange{Tags: []models.TagID{"net7.web"}})
+	})
+}
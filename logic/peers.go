@@ -1,11 +1,15 @@
 package logic
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/netip"
+	"sort"
 	"sync"
 	"time"
 
@@ -73,8 +77,20 @@ var (
 	hostPeerUpdateCacheMu sync.RWMutex
 )
 
-// InvalidateHostPeerCaches clears both hostPeerInfoCache and
-// hostPeerUpdateCache so they are rebuilt on next access or refresh.
+// hostPeerUpdateVersion - canonical hash and version of the last peer update
+// published to a host
+type hostPeerUpdateVersion struct {
+	hash    [sha256.Size]byte
+	version uint64
+}
+
+var (
+	hostPeerUpdateVersions   map[string]hostPeerUpdateVersion
+	hostPeerUpdateVersionsMu sync.Mutex
+)
+
+// InvalidateHostPeerCaches clears hostPeerInfoCache, hostPeerUpdateCache and
+// the peer update versions so they are rebuilt on next access or refresh.
 func InvalidateHostPeerCaches() {
 	hostPeerInfoCacheMu.Lock()
 	hostPeerInfoCache = nil
@@ -83,6 +99,84 @@ func InvalidateHostPeerCaches() {
 	hostPeerUpdateCacheMu.Lock()
 	hostPeerUpdateCache = nil
 	hostPeerUpdateCacheMu.Unlock()
+
+	hostPeerUpdateVersionsMu.Lock()
+	hostPeerUpdateVersions = nil
+	hostPeerUpdateVersionsMu.Unlock()
+}
+
+// VersionHostPeerUpdate - sets the version of a peer update about to be
+// published to the host. The version is only bumped if the canonical hash
+// differs from the last published update, changed is false if the host
+// already received an identical update.
+func VersionHostPeerUpdate(hostID string, peerUpdate *models.HostPeerUpdate) (changed bool) {
+	hash, err := hashHostPeerUpdate(*peerUpdate)
+	if err != nil {
+		slog.Error("failed to hash peer update", "host", hostID, "error", err)
+		return true
+	}
+	hostPeerUpdateVersionsMu.Lock()
+	defer hostPeerUpdateVersionsMu.Unlock()
+	if hostPeerUpdateVersions == nil {
+		hostPeerUpdateVersions = make(map[string]hostPeerUpdateVersion)
+	}
+	last, ok := hostPeerUpdateVersions[hostID]
+	if ok && last.hash == hash {
+		peerUpdate.Version = last.version
+		return false
+	}
+	// versions start from the current time so that they keep increasing
+	// across server restarts and cache invalidations
+	version := uint64(time.Now().UnixMilli())
+	if version <= last.version {
+		version = last.version + 1
+	}
+	hostPeerUpdateVersions[hostID] = hostPeerUpdateVersion{hash: hash, version: version}
+	peerUpdate.Version = version
+	return true
+}
+
+// ResetHostPeerUpdateVersion - forgets the last peer update published to the
+// host, e.g. when publishing failed, so that the next one is not skipped
+func ResetHostPeerUpdateVersion(hostID string) {
+	hostPeerUpdateVersionsMu.Lock()
+	defer hostPeerUpdateVersionsMu.Unlock()
+	if last, ok := hostPeerUpdateVersions[hostID]; ok {
+		// keep the version so that the next one is still newer
+		hostPeerUpdateVersions[hostID] = hostPeerUpdateVersion{version: last.version}
+	}
+}
+
+// hashHostPeerUpdate - hashes the peer update leaving out the version, check-in
+// times and the order of peers, none of which the host has to be updated for
+func hashHostPeerUpdate(peerUpdate models.HostPeerUpdate) ([sha256.Size]byte, error) {
+	peerUpdate.Version = 0
+	peerUpdate.Host.UpdatedAt = time.Time{}
+	nodes := make([]models.Node, len(peerUpdate.Nodes))
+	for i, node := range peerUpdate.Nodes {
+		node.LastModified = time.Time{}
+		node.LastCheckIn = time.Time{}
+		node.LastPeerUpdate = time.Time{}
+		nodes[i] = node
+	}
+	peerUpdate.Nodes = nodes
+	peerUpdate.Peers = sortedPeerConfigs(peerUpdate.Peers)
+	peerUpdate.NodePeers = sortedPeerConfigs(peerUpdate.NodePeers)
+	peerUpdate.OldPeerUpdateFields.NodePeers = sortedPeerConfigs(peerUpdate.OldPeerUpdateFields.NodePeers)
+	peerUpdate.OldPeers = sortedPeerConfigs(peerUpdate.OldPeers)
+	data, err := json.Marshal(&peerUpdate)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+func sortedPeerConfigs(peers []wgtypes.PeerConfig) []wgtypes.PeerConfig {
+	sorted := slices.Clone(peers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].PublicKey[:], sorted[j].PublicKey[:]) < 0
+	})
+	return sorted
 }
 
 // StoreHostPeerUpdate - caches a computed HostPeerUpdate for a host.
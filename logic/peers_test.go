@@ -3,9 +3,11 @@ package logic
 import (
 	"net"
 	"testing"
+	"time"
 
 	"github.com/gravitl/netmaker/models"
 	"github.com/stretchr/testify/assert"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
 
 func TestDeduplicateEgressRoutesMergesRangesForSamePeerAndNetwork(t *testing.T) {
@@ -80,3 +82,43 @@ func TestDeduplicateEgressRoutesMergesRangesForSamePeerAndNetwork(t *testing.T)
 		},
 	}, merged.EgressRangesWithMetric)
 }
+
+func TestVersionHostPeerUpdate(t *testing.T) {
+	t.Cleanup(InvalidateHostPeerCaches)
+	key1, _ := wgtypes.GenerateKey()
+	key2, _ := wgtypes.GenerateKey()
+	node := models.Node{}
+	node.Network = "testnet"
+	peerUpdate := models.HostPeerUpdate{
+		Nodes: []models.Node{node},
+		Peers: []wgtypes.PeerConfig{{PublicKey: key1.PublicKey()}, {PublicKey: key2.PublicKey()}},
+	}
+
+	assert.True(t, VersionHostPeerUpdate("host-1", &peerUpdate))
+	version := peerUpdate.Version
+	assert.NotZero(t, version)
+
+	// check-ins and peer order don't change the update
+	same := peerUpdate
+	same.Version = 0
+	same.Nodes = []models.Node{node}
+	same.Nodes[0].LastCheckIn = time.Now()
+	same.Peers = []wgtypes.PeerConfig{peerUpdate.Peers[1], peerUpdate.Peers[0]}
+	assert.False(t, VersionHostPeerUpdate("host-1", &same))
+	assert.Equal(t, version, same.Version)
+
+	changed := peerUpdate
+	changed.Peers = peerUpdate.Peers[:1]
+	assert.True(t, VersionHostPeerUpdate("host-1", &changed))
+	assert.Greater(t, changed.Version, version)
+
+	// a failed publish is not skipped next time
+	ResetHostPeerUpdateVersion("host-1")
+	retry := changed
+	assert.True(t, VersionHostPeerUpdate("host-1", &retry))
+	assert.Greater(t, retry.Version, changed.Version)
+
+	// versions are kept per host
+	other := peerUpdate
+	assert.True(t, VersionHostPeerUpdate("host-2", &other))
+}
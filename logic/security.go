@@ -23,20 +23,30 @@ const (
 	Unauthorized_Err = models.Error(Unauthorized_Msg)
 )
 
-var NetworkPermissionsCheck = func(username string, r *http.Request) error { return nil }
-var GlobalPermissionsCheck = func(username string, r *http.Request) error { return nil }
+var NetworkPermissionsCheck = func(username string, r *http.Request) error { return CheckAccessTokenScopes(r, false) }
+var GlobalPermissionsCheck = func(username string, r *http.Request) error { return CheckAccessTokenScopes(r, true) }
 
 // SecurityCheck - Check if user has appropriate permissions
 func SecurityCheck(reqAdmin bool, next http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		r.Header.Set("ismaster", "no")
+		r.Header.Set(accessTokenIDHeader, "")
 		isGlobalAccesss := r.Header.Get("IS_GLOBAL_ACCESS") == "yes"
 		bearerToken := r.Header.Get("Authorization")
-		username, err := GetUserNameFromToken(bearerToken)
+		username, accessToken, err := getUserNameAndAccessToken(bearerToken)
 		if err != nil {
 			ReturnErrorResponse(w, r, FormatError(err, "unauthorized"))
 			return
 		}
+		if accessToken != nil {
+			if !IsAccessTokenSourceAllowed(accessToken, GetClientIP(r)) {
+				err = errors.New("access token is not allowed from this source")
+				logAccessTokenUse(accessToken, r, err)
+				ReturnErrorResponse(w, r, FormatError(err, "unauthorized"))
+				return
+			}
+			r.Header.Set(accessTokenIDHeader, accessToken.ID)
+		}
 		if username != MasterUser {
 			user := &schema.User{Username: username}
 			err = user.Get(r.Context())
@@ -67,6 +77,9 @@ func SecurityCheck(reqAdmin bool, next http.Handler) http.HandlerFunc {
 		w.Header().Set("RSRC_TYPE", r.Header.Get("RSRC_TYPE"))
 		w.Header().Set("IS_GLOBAL_ACCESS", r.Header.Get("IS_GLOBAL_ACCESS"))
 		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if accessToken != nil {
+			logAccessTokenUse(accessToken, r, err)
+		}
 		if err != nil {
 			w.Header().Set("ACCESS_PERM", err.Error())
 			ReturnErrorResponse(w, r, FormatError(err, "forbidden"))
@@ -77,6 +90,39 @@ func SecurityCheck(reqAdmin bool, next http.Handler) http.HandlerFunc {
 	}
 }
 
+// UserCheck - allows any authenticated user. A request made with an api
+// access token is held to the source allowlist of the token, and to its
+// scopes, which deny scoped tokens as no resource is targeted.
+func UserCheck(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set(accessTokenIDHeader, "")
+		username, accessToken, err := getUserNameAndAccessToken(r.Header.Get("Authorization"))
+		if err != nil {
+			ReturnErrorResponse(w, r, FormatError(err, "unauthorized"))
+			return
+		}
+		if accessToken != nil {
+			if !IsAccessTokenSourceAllowed(accessToken, GetClientIP(r)) {
+				err = errors.New("access token is not allowed from this source")
+				logAccessTokenUse(accessToken, r, err)
+				ReturnErrorResponse(w, r, FormatError(err, "unauthorized"))
+				return
+			}
+			r.Header.Set(accessTokenIDHeader, accessToken.ID)
+			r.Header.Del("TARGET_RSRC")
+			r.Header.Del("TARGET_RSRC_ID")
+			err = CheckAccessTokenScopes(r, true)
+			logAccessTokenUse(accessToken, r, err)
+			if err != nil {
+				ReturnErrorResponse(w, r, FormatError(err, "forbidden"))
+				return
+			}
+		}
+		r.Header.Set("user", username)
+		next.ServeHTTP(w, r)
+	}
+}
+
 func PreAuthCheck(next http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -90,7 +136,12 @@ func PreAuthCheck(next http.Handler) http.HandlerFunc {
 
 		// first check is user is authenticated.
 		// if yes, allow the user to go through.
-		username, err := GetUserNameFromToken(authHeader)
+		username, accessToken, err := getUserNameAndAccessToken(authHeader)
+		if err == nil && accessToken != nil {
+			// api access tokens cannot complete a login.
+			ReturnErrorResponse(w, r, FormatError(Unauthorized_Err, "unauthorized"))
+			return
+		}
 		if err != nil {
 			// if no, then check the user has a pre-auth token.
 			var claims jwt.RegisteredClaims
@@ -18,6 +18,8 @@ var GetDeploymentMode = func() string {
 }
 var StartFlowCleanupLoop = func() {}
 var StopFlowCleanupLoop = func() {}
+var StartFlowIngestServer = func() {}
+var StopFlowIngestServer = func() {}
 
 // == Join, Checkin, and Leave for Server ==
 
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"regexp"
 	"strconv"
@@ -22,6 +23,10 @@ var (
 	ErrInvalidJwtValidityDuration = errors.New("invalid jwt validity duration")
 	ErrFlowLogsNotSupported       = errors.New("flow logs not supported")
 	ErrInvalidIPDetectionInterval = errors.New("invalid ip detection interval (must be greater than or equal to 15s)")
+	ErrInvalidAuditExport         = errors.New("invalid audit export settings")
+	ErrInvalidLDAPSettings        = errors.New("ldap url and base dn are required")
+	ErrInvalidSAMLSettings        = errors.New("saml idp metadata url or metadata is required")
+	ErrInvalidMetricsRetention    = errors.New("invalid metrics retention period")
 )
 
 var ServerSettingsDBKey = "server_cfg"
@@ -70,6 +75,9 @@ func UpsertServerSettings(s models.ServerSettings) error {
 	if s.ClientSecret == Mask() {
 		s.ClientSecret = currSettings.ClientSecret
 	}
+	if s.LDAPBindPassword == Mask() {
+		s.LDAPBindPassword = currSettings.LDAPBindPassword
+	}
 
 	if servercfg.DeployedByOperator() {
 		s.BasicAuth = true
@@ -101,12 +109,19 @@ func UpsertServerSettings(s models.ServerSettings) error {
 		return err
 	}
 	serverSettingsCache.Store(&s)
-	if PublishServerSync != nil {
-		PublishServerSync(SyncTypeSettings)
-	}
+	InvalidateCache(CacheKindSettings, "")
 	return nil
 }
 
+// reloadServerSettingsCache - drops the settings changed by another replica
+func reloadServerSettingsCache() {
+	oldInterval := GetMetricInterval()
+	InvalidateServerSettingsCache()
+	if GetMetricInterval() != oldInterval {
+		NotifyMetricExportIntervalChanged()
+	}
+}
+
 func GetUserSettings(userID string) models.UserSettings {
 	data, err := database.FetchRecord(database.SERVER_SETTINGS, userID)
 	if err != nil {
@@ -155,6 +170,45 @@ func ValidateNewSettings(req models.ServerSettings) error {
 		return ErrInvalidIPDetectionInterval
 	}
 
+	if req.AuthProvider == "ldap" && (req.LDAPURL == "" || req.LDAPBaseDN == "") {
+		return ErrInvalidLDAPSettings
+	}
+
+	if req.AuthProvider == "saml" && req.SAMLIDPMetadataURL == "" && req.SAMLIDPMetadata == "" {
+		return ErrInvalidSAMLSettings
+	}
+
+	if req.MetricsRetentionPeriodInDays < 0 {
+		return ErrInvalidMetricsRetention
+	}
+
+	if req.AuditExportEnabled {
+		if err := validateAuditExport(req); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAuditExport, err)
+		}
+	}
+
+	return nil
+}
+
+func validateAuditExport(req models.ServerSettings) error {
+	switch req.AuditExportFormat {
+	case models.AuditExportSyslog, models.AuditExportCEF, models.AuditExportJSON:
+	default:
+		return fmt.Errorf("unknown format %q", req.AuditExportFormat)
+	}
+	switch req.AuditExportTransport {
+	case models.AuditExportFile:
+		if req.AuditExportTarget == "" {
+			return errors.New("file path is required")
+		}
+	case models.AuditExportUDP, models.AuditExportTCP, models.AuditExportTLS:
+		if _, _, err := net.SplitHostPort(req.AuditExportTarget); err != nil {
+			return fmt.Errorf("target must be host:port: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown transport %q", req.AuditExportTransport)
+	}
 	return nil
 }
 
@@ -371,6 +425,14 @@ func GetAuthProviderInfo(settings models.ServerSettings) (pi []string) {
 		}
 	}()
 
+	// saml has no client credentials, the idp is described by its metadata.
+	if strings.ToLower(settings.AuthProvider) == "saml" {
+		if settings.SAMLIDPMetadataURL != "" || settings.SAMLIDPMetadata != "" {
+			return []string{"saml", "", ""}
+		}
+		return []string{"", "", ""}
+	}
+
 	if settings.AuthProvider != "" && settings.ClientID != "" && settings.ClientSecret != "" {
 		authProvider = strings.ToLower(settings.AuthProvider)
 		if authProvider == "google" || authProvider == "azure-ad" || authProvider == "github" || authProvider == "okta" || authProvider == "oidc" {
@@ -6,7 +6,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gravitl/netmaker/leader"
 	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/servermetrics"
 	"golang.org/x/exp/slog"
 
 	"github.com/google/uuid"
@@ -28,9 +30,11 @@ var HookCommandCh = make(chan models.HookCommand, 10)
 type hookInfo struct {
 	cancelFunc context.CancelFunc
 	resetCh    chan struct{}
+	runCh      chan struct{}
 	interval   time.Duration
 	hook       models.HookFunc
 	params     []interface{}
+	leaderOnly bool
 }
 
 // runningHooks - map of hook ID to hook info
@@ -91,6 +95,21 @@ func RestartHook(hookID string, newInterval time.Duration) {
 	}
 }
 
+// RunHook - runs the hook with the given ID right away, unless a run is
+// already pending
+func RunHook(hookID string) {
+	hooksMutex.RLock()
+	defer hooksMutex.RUnlock()
+	info, ok := runningHooks[hookID]
+	if !ok {
+		return
+	}
+	select {
+	case info.runCh <- struct{}{}:
+	default:
+	}
+}
+
 // GetRunningHooks - returns a list of currently running hook IDs
 func GetRunningHooks() []string {
 	hooksMutex.RLock()
@@ -106,6 +125,7 @@ func GetRunningHooks() []string {
 // StartHookManager - listens on `HookManagerCh` to run any hook and `HookCommandCh` for commands
 func StartHookManager(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
+	leader.OnChange(runLeaderOnlyHooks)
 	for {
 		select {
 		case <-ctx.Done():
@@ -134,20 +154,21 @@ func StartHookManager(ctx context.Context, wg *sync.WaitGroup) {
 
 			// Create context for this hook
 			hookCtx, cancelFunc := context.WithCancel(ctx)
-			resetCh := make(chan struct{}, 1)
 
 			info := &hookInfo{
 				cancelFunc: cancelFunc,
-				resetCh:    resetCh,
+				resetCh:    make(chan struct{}, 1),
+				runCh:      make(chan struct{}, 1),
 				interval:   newhook.Interval,
 				hook:       newhook.Hook,
 				params:     newhook.Params,
+				leaderOnly: newhook.LeaderOnly,
 			}
 			runningHooks[hookID] = info
 			hooksMutex.Unlock()
 
 			wg.Add(1)
-			go addHookWithInterval(hookCtx, wg, hookID, newhook.Hook, newhook.Params, newhook.Interval, resetCh)
+			go addHookWithInterval(hookCtx, wg, hookID, info)
 		case cmd := <-HookCommandCh:
 			hooksMutex.Lock()
 			info, exists := runningHooks[cmd.ID]
@@ -193,50 +214,83 @@ func StartHookManager(ctx context.Context, wg *sync.WaitGroup) {
 
 				// Create new context and restart
 				hookCtx, cancelFunc := context.WithCancel(ctx)
-				resetCh := make(chan struct{}, 1)
 
 				newInfo := &hookInfo{
 					cancelFunc: cancelFunc,
-					resetCh:    resetCh,
+					resetCh:    make(chan struct{}, 1),
+					runCh:      make(chan struct{}, 1),
 					interval:   interval,
 					hook:       hook,
 					params:     params,
+					leaderOnly: info.leaderOnly,
 				}
 				runningHooks[hookID] = newInfo
 				hooksMutex.Unlock()
 
 				wg.Add(1)
-				go addHookWithInterval(hookCtx, wg, hookID, hook, params, interval, resetCh)
+				go addHookWithInterval(hookCtx, wg, hookID, newInfo)
 				slog.Info("hook restarted", "hook_id", hookID, "interval", interval)
 			}
 		}
 	}
 }
 
-func addHookWithInterval(ctx context.Context, wg *sync.WaitGroup, hookID string, hook models.HookFunc, params []interface{}, interval time.Duration, resetCh chan struct{}) {
+func addHookWithInterval(ctx context.Context, wg *sync.WaitGroup, hookID string, info *hookInfo) {
 	defer wg.Done()
 	defer func() {
 		hooksMutex.Lock()
-		delete(runningHooks, hookID)
+		if runningHooks[hookID] == info {
+			delete(runningHooks, hookID)
+		}
 		hooksMutex.Unlock()
 	}()
 
-	ticker := time.NewTicker(interval)
+	ticker := time.NewTicker(info.interval)
 	defer ticker.Stop()
 
+	run := func() {
+		if info.leaderOnly && !leader.IsLeader() {
+			return
+		}
+		start := time.Now()
+		err := info.hook(info.params...)
+		servermetrics.HookDuration.ObserveSince(start, hookID, servermetrics.Result(err))
+		if err != nil {
+			slog.Error("error running hook", "hook_id", hookID, "error", err.Error())
+		}
+	}
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-resetCh:
+		case <-info.resetCh:
 			// Reset the timer by stopping the old ticker and creating a new one
 			ticker.Stop()
-			ticker = time.NewTicker(interval)
+			ticker = time.NewTicker(info.interval)
 			slog.Info("hook timer reset", "hook_id", hookID)
+		case <-info.runCh:
+			run()
 		case <-ticker.C:
-			if err := hook(params...); err != nil {
-				slog.Error("error running hook", "hook_id", hookID, "error", err.Error())
-			}
+			run()
+		}
+	}
+}
+
+// runLeaderOnlyHooks - runs the leader only hooks right away once this
+// replica becomes leader, instead of waiting for their next interval
+func runLeaderOnlyHooks(isLeader bool) {
+	if !isLeader {
+		return
+	}
+	hooksMutex.RLock()
+	defer hooksMutex.RUnlock()
+	for _, info := range runningHooks {
+		if !info.leaderOnly {
+			continue
+		}
+		select {
+		case info.runCh <- struct{}{}:
+		default:
 		}
 	}
 }
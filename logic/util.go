@@ -22,6 +22,7 @@ import (
 	"github.com/gravitl/netmaker/db"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
 )
 
 // IsBase64 - checks if a string is in base64 format
@@ -240,23 +241,59 @@ func UniqueStrings(input []string) []string {
 
 	return result
 }
+
+// GetClientIP - gets the ip of the client of a request. Forwarding headers
+// are only honored for requests from a trusted proxy, and then the client is
+// the rightmost X-Forwarded-For hop that is not a trusted proxy.
 func GetClientIP(r *http.Request) string {
-	// Trust X-Forwarded-For first
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		parts := strings.Split(xff, ",")
-		return strings.TrimSpace(parts[0])
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
 	}
-	if xrip := r.Header.Get("X-Real-IP"); xrip != "" {
-		return xrip
+	trusted := servercfg.GetTrustedProxies()
+	if !isTrustedProxy(ip, trusted) {
+		return ip
 	}
 
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			ip = hop
+			if !isTrustedProxy(hop, trusted) {
+				break
+			}
+		}
+		return ip
+	}
+	if xrip := strings.TrimSpace(r.Header.Get("X-Real-IP")); xrip != "" {
+		return xrip
 	}
 	return ip
 }
 
+// isTrustedProxy - checks if the ip matches one of the trusted proxy
+// addresses or cidrs
+func isTrustedProxy(ip string, trusted []string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, proxy := range trusted {
+		if _, cidr, err := net.ParseCIDR(proxy); err == nil {
+			if cidr.Contains(addr) {
+				return true
+			}
+		} else if proxyIP := net.ParseIP(proxy); proxyIP != nil && proxyIP.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 // CompareIfaceSlices compares two slices of Iface for deep equality (order-sensitive)
 func CompareIfaceSlices(a, b []schema.Iface) bool {
 	if len(a) != len(b) {
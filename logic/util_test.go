@@ -1,6 +1,8 @@
 package logic
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -30,3 +32,34 @@ func TestMakeRandomStringValid(t *testing.T) {
 	assert.False(t, strings.Contains(validMqID, "{"))
 	assert.False(t, strings.Contains(validMqID, "}"))
 }
+
+func TestGetClientIP(t *testing.T) {
+	request := func(remoteAddr string, headers map[string]string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+	spoofed := map[string]string{"X-Forwarded-For": "10.1.2.3", "X-Real-IP": "10.1.2.3"}
+
+	t.Setenv("TRUSTED_PROXIES", "")
+	assert.Equal(t, "203.0.113.7", GetClientIP(request("203.0.113.7:1234", nil)))
+	// forwarding headers of untrusted clients are ignored
+	assert.Equal(t, "203.0.113.7", GetClientIP(request("203.0.113.7:1234", spoofed)))
+
+	t.Setenv("TRUSTED_PROXIES", "192.0.2.10, 198.51.100.0/24")
+	assert.Equal(t, "203.0.113.7", GetClientIP(request("203.0.113.7:1234", spoofed)))
+	assert.Equal(t, "10.1.2.3", GetClientIP(request("192.0.2.10:443", spoofed)))
+	// the rightmost hop that is not a trusted proxy is the client, hops
+	// to its left are set by the client
+	assert.Equal(t, "203.0.113.7", GetClientIP(request("192.0.2.10:443", map[string]string{
+		"X-Forwarded-For": "10.1.2.3, 203.0.113.7, 198.51.100.5",
+	})))
+	assert.Equal(t, "10.1.2.3", GetClientIP(request("192.0.2.10:443", map[string]string{
+		"X-Forwarded-For": "10.1.2.3, 198.51.100.5",
+	})))
+	assert.Equal(t, "10.1.2.3", GetClientIP(request("198.51.100.5:443", map[string]string{"X-Real-IP": "10.1.2.3"})))
+	assert.Equal(t, "192.0.2.10", GetClientIP(request("192.0.2.10:443", nil)))
+}
@@ -0,0 +1,450 @@
+package logic
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// webAuthnSessionLength - length of the id of a webauthn ceremony, the
+// ceremony state is kept in the sso state cache until it is finished.
+const webAuthnSessionLength = 48
+
+var (
+	ErrWebAuthnNotConfigured  = errors.New("webauthn requires the frontend url to be configured")
+	ErrWebAuthnInvalidSession = errors.New("invalid or expired webauthn session")
+	ErrWebAuthnCloned         = errors.New("webauthn authenticator may have been cloned")
+)
+
+// webAuthnUser - adapts a user and its credentials to the webauthn library
+type webAuthnUser struct {
+	user        *schema.User
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(u.user.ID)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if u.user.DisplayName != "" {
+		return u.user.DisplayName
+	}
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// getWebAuthn - relying party of the dashboard, credentials are bound to the
+// host of the frontend url.
+func getWebAuthn() (*webauthn.WebAuthn, error) {
+	frontendURL := strings.TrimSuffix(servercfg.GetFrontendURL(), "/")
+	if frontendURL == "" {
+		return nil, ErrWebAuthnNotConfigured
+	}
+
+	u, err := url.Parse(frontendURL)
+	if err != nil || u.Hostname() == "" {
+		return nil, ErrWebAuthnNotConfigured
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          u.Hostname(),
+		RPDisplayName: "Netmaker",
+		RPOrigins:     []string{u.Scheme + "://" + u.Host},
+	})
+}
+
+func getWebAuthnUser(user *schema.User) (*webAuthnUser, error) {
+	creds, err := (&schema.UserWebAuthnCredential{UserName: user.Username}).ListByUser(db.WithContext(context.TODO()))
+	if err != nil {
+		return nil, err
+	}
+
+	waUser := &webAuthnUser{user: user}
+	for _, cred := range creds {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(cred.Credential, &credential); err != nil {
+			return nil, err
+		}
+		waUser.credentials = append(waUser.credentials, credential)
+	}
+
+	return waUser, nil
+}
+
+// saveWebAuthnSession - stores the state of a ceremony, the returned id is
+// handed to the client and sent back to finish the ceremony.
+func saveWebAuthnSession(appName string, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	id := RandomString(webAuthnSessionLength)
+	s := models.SsoState{
+		AppName:    appName,
+		Value:      string(data),
+		Expiration: session.Expires,
+	}
+	if s.Expiration.IsZero() {
+		s.Expiration = time.Now().Add(models.DefaultExpDuration)
+	}
+
+	record, err := json.Marshal(&s)
+	if err != nil {
+		return "", err
+	}
+
+	return id, database.Insert(id, string(record), database.SSO_STATE_CACHE)
+}
+
+// popWebAuthnSession - returns the state of a ceremony, a session can only
+// be used once.
+func popWebAuthnSession(id string) (*webauthn.SessionData, string, error) {
+	if len(id) != webAuthnSessionLength {
+		return nil, "", ErrWebAuthnInvalidSession
+	}
+
+	s, err := GetState(id)
+	if err != nil {
+		return nil, "", ErrWebAuthnInvalidSession
+	}
+
+	if err = delState(id); err != nil {
+		return nil, "", err
+	}
+
+	var session webauthn.SessionData
+	if err = json.Unmarshal([]byte(s.Value), &session); err != nil || session.Challenge == "" {
+		return nil, "", ErrWebAuthnInvalidSession
+	}
+
+	return &session, s.AppName, nil
+}
+
+// BeginWebAuthnRegistration - starts the registration of a credential, the
+// user is asked for a discoverable credential so that it can be used for
+// passwordless sign in as well.
+func BeginWebAuthnRegistration(user *schema.User) (*models.WebAuthnBeginResponse, error) {
+	wa, err := getWebAuthn()
+	if err != nil {
+		return nil, err
+	}
+
+	waUser, err := getWebAuthnUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	creation, session, err := wa.BeginRegistration(
+		waUser,
+		webauthn.WithExclusions(webauthn.Credentials(waUser.credentials).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := saveWebAuthnSession("", session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WebAuthnBeginResponse{
+		SessionID: sessionID,
+		Options:   creation,
+	}, nil
+}
+
+// FinishWebAuthnRegistration - verifies the attestation of a new credential
+// and stores it. Registering a credential enables mfa for the user.
+func FinishWebAuthnRegistration(user *schema.User, req models.WebAuthnFinishRequest) (*schema.UserWebAuthnCredential, error) {
+	wa, err := getWebAuthn()
+	if err != nil {
+		return nil, err
+	}
+
+	session, _, err := popWebAuthnSession(req.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	waUser, err := getWebAuthnUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		return nil, formatWebAuthnError(err)
+	}
+
+	credential, err := wa.CreateCredential(waUser, *session, parsed)
+	if err != nil {
+		return nil, formatWebAuthnError(err)
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "security key"
+	}
+
+	cred := &schema.UserWebAuthnCredential{
+		ID:         base64.RawURLEncoding.EncodeToString(credential.ID),
+		UserName:   user.Username,
+		Name:       name,
+		Credential: data,
+		CreatedAt:  time.Now().UTC(),
+	}
+	err = cred.Create(db.WithContext(context.TODO()))
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsMFAEnabled {
+		user.IsMFAEnabled = true
+		err = user.UpdateMFA(db.WithContext(context.TODO()))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cred, nil
+}
+
+// BeginWebAuthnLogin - starts the verification of the second factor of a
+// user that signed in with a password.
+func BeginWebAuthnLogin(user *schema.User) (*models.WebAuthnBeginResponse, error) {
+	wa, err := getWebAuthn()
+	if err != nil {
+		return nil, err
+	}
+
+	waUser, err := getWebAuthnUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(waUser.credentials) == 0 {
+		return nil, errors.New("user has no webauthn credentials")
+	}
+
+	assertion, session, err := wa.BeginLogin(waUser)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := saveWebAuthnSession("", session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WebAuthnBeginResponse{
+		SessionID: sessionID,
+		Options:   assertion,
+	}, nil
+}
+
+// FinishWebAuthnLogin - verifies the assertion of one of the credentials of
+// the user.
+func FinishWebAuthnLogin(user *schema.User, req models.WebAuthnFinishRequest) error {
+	wa, err := getWebAuthn()
+	if err != nil {
+		return err
+	}
+
+	session, _, err := popWebAuthnSession(req.SessionID)
+	if err != nil {
+		return err
+	}
+
+	waUser, err := getWebAuthnUser(user)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		return formatWebAuthnError(err)
+	}
+
+	credential, err := wa.ValidateLogin(waUser, *session, parsed)
+	if err != nil {
+		return formatWebAuthnError(err)
+	}
+
+	return recordWebAuthnCredentialUse(credential)
+}
+
+// BeginPasswordlessWebAuthnLogin - starts a sign in with a passkey, the
+// user is identified by the credential it picks.
+func BeginPasswordlessWebAuthnLogin(appName string) (*models.WebAuthnBeginResponse, error) {
+	wa, err := getWebAuthn()
+	if err != nil {
+		return nil, err
+	}
+
+	// the credential replaces both the password and the second factor,
+	// the authenticator has to verify the user.
+	assertion, session, err := wa.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := saveWebAuthnSession(appName, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WebAuthnBeginResponse{
+		SessionID: sessionID,
+		Options:   assertion,
+	}, nil
+}
+
+// FinishPasswordlessWebAuthnLogin - verifies the assertion of a passkey and
+// returns its user along with the app the sign in was started from.
+func FinishPasswordlessWebAuthnLogin(req models.WebAuthnFinishRequest) (*schema.User, string, error) {
+	wa, err := getWebAuthn()
+	if err != nil {
+		return nil, "", err
+	}
+
+	session, appName, err := popWebAuthnSession(req.SessionID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		return nil, "", formatWebAuthnError(err)
+	}
+
+	var user *schema.User
+	credential, err := wa.ValidateDiscoverableLogin(func(_, userHandle []byte) (webauthn.User, error) {
+		user = &schema.User{ID: string(userHandle)}
+		if err := user.Get(db.WithContext(context.TODO())); err != nil {
+			return nil, err
+		}
+		return getWebAuthnUser(user)
+	}, *session, parsed)
+	if err != nil {
+		return nil, "", formatWebAuthnError(err)
+	}
+
+	err = recordWebAuthnCredentialUse(credential)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, appName, nil
+}
+
+// recordWebAuthnCredentialUse - stores the sign count of a credential after
+// a sign in, a count that went backwards hints at a cloned authenticator.
+func recordWebAuthnCredentialUse(credential *webauthn.Credential) error {
+	if credential.Authenticator.CloneWarning {
+		return ErrWebAuthnCloned
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	cred := &schema.UserWebAuthnCredential{
+		ID:         base64.RawURLEncoding.EncodeToString(credential.ID),
+		Credential: data,
+		LastUsedAt: time.Now().UTC(),
+	}
+	return cred.UpdateUsage(db.WithContext(context.TODO()))
+}
+
+// ListWebAuthnCredentials - lists the credentials registered by a user
+func ListWebAuthnCredentials(username string) ([]schema.UserWebAuthnCredential, error) {
+	creds, err := (&schema.UserWebAuthnCredential{UserName: username}).ListByUser(db.WithContext(context.TODO()))
+	if err != nil {
+		return nil, err
+	}
+	if creds == nil {
+		creds = []schema.UserWebAuthnCredential{}
+	}
+	return creds, nil
+}
+
+// DeleteWebAuthnCredential - revokes a credential of a user, mfa is turned
+// off when the user is left without a second factor.
+func DeleteWebAuthnCredential(user *schema.User, id string) error {
+	cred := &schema.UserWebAuthnCredential{ID: id}
+	err := cred.Get(db.WithContext(context.TODO()))
+	if err != nil || cred.UserName != user.Username {
+		return fmt.Errorf("webauthn credential %s not found", id)
+	}
+
+	err = cred.Delete(db.WithContext(context.TODO()))
+	if err != nil {
+		return err
+	}
+
+	if !HasMFAFactor(user) && user.IsMFAEnabled {
+		user.IsMFAEnabled = false
+		return user.UpdateMFA(db.WithContext(context.TODO()))
+	}
+
+	return nil
+}
+
+// GetUserMFAMethods - second factors set up by a user
+func GetUserMFAMethods(user *schema.User) []models.MFAMethod {
+	var methods []models.MFAMethod
+	if user.TOTPSecret != "" {
+		methods = append(methods, models.MFAMethodTOTP)
+	}
+
+	creds, err := ListWebAuthnCredentials(user.Username)
+	if err == nil && len(creds) > 0 {
+		methods = append(methods, models.MFAMethodWebAuthn)
+	}
+
+	return methods
+}
+
+// HasMFAFactor - checks if a user has set up any second factor
+func HasMFAFactor(user *schema.User) bool {
+	return len(GetUserMFAMethods(user)) > 0
+}
+
+// formatWebAuthnError - the details of protocol errors tell why a ceremony
+// failed, the message alone is generic.
+func formatWebAuthnError(err error) error {
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) && protocolErr.DevInfo != "" {
+		return fmt.Errorf("%s: %s", protocolErr.Details, protocolErr.DevInfo)
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package logic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/matryer/is"
+)
+
+func createWebAuthnTestUser(t *testing.T, username string) *schema.User {
+	_ = DeleteUser(username)
+	user := &schema.User{
+		ID:             uuid.NewString(),
+		Username:       username,
+		PlatformRoleID: schema.ServiceUser,
+	}
+	err := user.Create(db.WithContext(context.TODO()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestWebAuthnSession(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	is := is.New(t)
+	t.Setenv("FRONTEND_URL", "https://dashboard.example.com")
+	user := createWebAuthnTestUser(t, "webauthn-session")
+
+	resp, err := BeginWebAuthnRegistration(user)
+	is.NoErr(err)
+	is.Equal(len(resp.SessionID), webAuthnSessionLength)
+
+	session, _, err := popWebAuthnSession(resp.SessionID)
+	is.NoErr(err)
+	is.Equal(session.UserID, []byte(user.ID))
+	is.Equal(session.RelyingPartyID, "dashboard.example.com")
+
+	t.Run("used once", func(t *testing.T) {
+		_, _, err := popWebAuthnSession(resp.SessionID)
+		is.Equal(err, ErrWebAuthnInvalidSession)
+	})
+	t.Run("unknown", func(t *testing.T) {
+		_, _, err := popWebAuthnSession(RandomString(webAuthnSessionLength))
+		is.Equal(err, ErrWebAuthnInvalidSession)
+	})
+	t.Run("passwordless keeps app name", func(t *testing.T) {
+		resp, err := BeginPasswordlessWebAuthnLogin(NetmakerDesktopApp)
+		is.NoErr(err)
+		_, appName, err := popWebAuthnSession(resp.SessionID)
+		is.NoErr(err)
+		is.Equal(appName, NetmakerDesktopApp)
+	})
+}
+
+func TestDeleteWebAuthnCredential(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	is := is.New(t)
+	user := createWebAuthnTestUser(t, "webauthn-owner")
+	other := createWebAuthnTestUser(t, "webauthn-other")
+
+	ctx := db.WithContext(context.TODO())
+	for _, id := range []string{"cred-a", "cred-b"} {
+		is.NoErr((&schema.UserWebAuthnCredential{ID: id, UserName: user.Username, Credential: []byte("{}")}).Create(ctx))
+	}
+	user.IsMFAEnabled = true
+	is.NoErr(user.UpdateMFA(ctx))
+	is.True(HasMFAFactor(user))
+
+	t.Run("not owned", func(t *testing.T) {
+		is.True(DeleteWebAuthnCredential(other, "cred-a") != nil)
+	})
+	t.Run("mfa kept while a factor is left", func(t *testing.T) {
+		is.NoErr(DeleteWebAuthnCredential(user, "cred-a"))
+		is.NoErr(user.Get(ctx))
+		is.True(user.IsMFAEnabled)
+	})
+	t.Run("mfa disabled with the last factor", func(t *testing.T) {
+		is.NoErr(DeleteWebAuthnCredential(user, "cred-b"))
+		is.NoErr(user.Get(ctx))
+		is.True(!user.IsMFAEnabled)
+		is.Equal(len(GetUserMFAMethods(user)), 0)
+	})
+}
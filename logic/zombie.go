@@ -2,10 +2,12 @@ package logic
 
 import (
 	"context"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/leader"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/schema"
@@ -23,6 +25,8 @@ var (
 	hostZombies   []uuid.UUID
 	newZombie     chan uuid.UUID = make(chan (uuid.UUID), 10)
 	newHostZombie chan uuid.UUID = make(chan (uuid.UUID), 10)
+	// resetZombies - empties the zombie node list before it is rebuilt
+	resetZombies chan struct{} = make(chan struct{})
 )
 
 // CheckZombies - checks if new node has same hostid as existing node
@@ -76,10 +80,20 @@ func checkForZombieHosts(h *schema.Host) {
 }
 
 // ManageZombies - goroutine which adds/removes/deletes nodes from the zombie node quarantine list
+// Zombies are only deleted by the leader, the quarantine list is rebuilt
+// from the database when a replica becomes leader.
 func ManageZombies(ctx context.Context) {
 	logger.Log(2, "Zombie management started")
-	go InitializeZombies()
-	go checkPendingRemovalNodes()
+	if leader.IsLeader() {
+		go rebuildZombies()
+		go checkPendingRemovalNodes()
+	}
+	leader.OnChange(func(isLeader bool) {
+		if isLeader {
+			go rebuildZombies()
+			go checkPendingRemovalNodes()
+		}
+	})
 	// Zombie Nodes Cleanup Four Times a Day
 	ticker := time.NewTicker(time.Hour * ZOMBIE_TIMEOUT)
 
@@ -89,11 +103,20 @@ func ManageZombies(ctx context.Context) {
 			ticker.Stop()
 			close(DeleteNodesCh)
 			return
+		case <-resetZombies:
+			zombies = nil
 		case id := <-newZombie:
-			zombies = append(zombies, id)
+			if !slices.Contains(zombies, id) {
+				zombies = append(zombies, id)
+			}
 		case id := <-newHostZombie:
-			hostZombies = append(hostZombies, id)
+			if !slices.Contains(hostZombies, id) {
+				hostZombies = append(hostZombies, id)
+			}
 		case <-ticker.C: // run this check 4 times a day
+			if !leader.IsLeader() {
+				continue
+			}
 			logger.Log(3, "checking for zombie nodes")
 			if len(zombies) > 0 {
 				for i := len(zombies) - 1; i >= 0; i-- {
@@ -153,6 +176,13 @@ func checkPendingRemovalNodes() {
 	}
 }
 
+// rebuildZombies - replaces the zombie node list with the zombies found in
+// the database, the list may be stale after a leadership change
+func rebuildZombies() {
+	resetZombies <- struct{}{}
+	InitializeZombies()
+}
+
 // InitializeZombies - populates the zombie quarantine list (should be called from initialization)
 func InitializeZombies() {
 	nodes, err := GetAllNodes()
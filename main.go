@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -22,6 +23,7 @@ import (
 	"github.com/gravitl/netmaker/config"
 	controller "github.com/gravitl/netmaker/controllers"
 	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/leader"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/migrate"
@@ -50,10 +52,16 @@ var version = "v1.5.1"
 // Start DB Connection and start API Request Handler
 func main() {
 	absoluteConfigPath := flag.String("c", "", "absolute path to configuration file")
+	migrateOnly := flag.Bool("migrate-only", false, "apply the pending database migrations and exit")
+	migrateDryRun := flag.Bool("migrate-dry-run", false, "report the pending database migrations and the changes they would make, then exit")
+	migrateDown := flag.Int("migrate-down", -1, "revert the database migrations above the version and exit, reports them with -migrate-dry-run")
 	flag.Parse()
 	setVerbosity()
 	setupConfig(*absoluteConfigPath)
 	servercfg.SetVersion(version)
+	if *migrateOnly || *migrateDryRun || *migrateDown >= 0 {
+		os.Exit(runMigrationCommand(*migrateDryRun, *migrateDown))
+	}
 	fmt.Println(models.RetrieveLogo()) // print the logo
 	initialize()                       // initial db and acls
 	logic.SetAllocatedIpMap()
@@ -68,6 +76,8 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
 	defer stop()
 	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	go leader.Run(ctx, &waitGroup)
 	startControllers(&waitGroup, ctx) // start the api endpoint and mq and stun
 	startHooks(ctx, &waitGroup)
 	<-ctx.Done()
@@ -86,8 +96,8 @@ func setupConfig(absoluteConfigPath string) {
 }
 
 func startHooks(ctx context.Context, wg *sync.WaitGroup) {
-	// Only run timer checkpoint (telemetry) on master pod
-	if servercfg.IsMasterPod() {
+	// Only run timer checkpoint (telemetry) on the leader
+	if leader.IsLeader() {
 		err := logic.TimerCheckpoint()
 		if err != nil {
 			logger.Log(1, "Timer error occurred: ", err.Error())
@@ -103,15 +113,6 @@ func initialize() { // Client Mode Prereq Check
 		logger.Log(0, "warning: MASTER_KEY not set, this could make account recovery difficult")
 	}
 
-	// Log master/worker mode for K8s HA setup
-	if servercfg.IsHA() {
-		if servercfg.IsMasterPod() {
-			logger.Log(0, "HA mode: running as MASTER pod - will run migrations and singleton operations")
-		} else {
-			logger.Log(0, "HA mode: running as WORKER pod - skipping migrations and singleton operations")
-		}
-	}
-
 	// initialize sql schema db.
 	err = db.InitializeDB(schema.ListModels()...)
 	if err != nil {
@@ -120,24 +121,56 @@ func initialize() { // Client Mode Prereq Check
 
 	logger.Log(0, "database successfully connected")
 
+	if err = leader.Init(); err != nil {
+		logger.FatalLog("error initializing leader election: ", err.Error())
+	}
+	// Log leader/follower mode for HA setup
+	if servercfg.IsHA() {
+		if leader.IsLeader() {
+			logger.Log(0, "HA mode: running as LEADER - will run migrations and singleton operations")
+		} else {
+			logger.Log(0, "HA mode: running as FOLLOWER - skipping migrations and singleton operations, leader is", leader.GetStatus().Leader)
+		}
+	}
+
 	// initialize kv schema db.
 	if err = database.InitializeDatabase(); err != nil {
 		logger.FatalLog("error initializing database: ", err.Error())
 	}
 
-	// Only run migrations on master pod to avoid conflicts in HA setup
-	if servercfg.IsMasterPod() {
-		err = migrate.ToSQLSchema()
-		if err != nil {
+	// refuse to run against a database migrated by a newer version, e.g.
+	// after rolling back the server without reverting its migrations.
+	if err = migrate.Check(db.WithContext(context.TODO())); err != nil {
+		logger.FatalLog("error checking database migrations: ", err.Error())
+	}
+
+	// Only run migrations on the leader to avoid conflicts in HA setup.
+	// A replica elected later runs them too, so an upgraded replica migrates
+	// the database once it takes over from a replica of the older version.
+	// A failed migration stops the server at startup, while a replica taking
+	// over keeps serving and logs it, the migration is retried on the next start.
+	if leader.IsLeader() {
+		if err = runMigrations(); errors.Is(err, migrate.ErrDatabaseNewer) {
+			logger.FatalLog("error running migrations: ", err.Error())
+		} else if err != nil {
 			// we shouldn't allow user to use the product until the migration is successfully done.
 			panic(err)
 		}
-		migrate.Run()
 	}
+	leader.OnChange(func(isLeader bool) {
+		if isLeader {
+			go func() {
+				if err := runMigrations(); err != nil {
+					logger.Log(0, "error running migrations after taking over as leader:", err.Error())
+				}
+			}()
+		}
+	})
 
 	initializeUUID()
 
 	//initialize cache
+	logic.RegisterCaches()
 	_, _ = logic.GetAllNodes()
 	_, _ = logic.GetAllExtClients()
 	_ = logic.ListAcls()
@@ -147,6 +180,53 @@ func initialize() { // Client Mode Prereq Check
 	logic.SetJWTSecret()
 }
 
+var migrationMutex sync.Mutex
+
+func runMigrations() error {
+	migrationMutex.Lock()
+	defer migrationMutex.Unlock()
+	report, err := migrate.Up(db.WithContext(context.TODO()), false)
+	if err != nil {
+		return err
+	}
+	if len(report.Steps) > 0 {
+		logger.Log(0, report.String())
+	}
+	return nil
+}
+
+// runMigrationCommand - applies the pending migrations, or reverts the ones
+// above downTo if it is not negative, and returns the exit code
+func runMigrationCommand(dryRun bool, downTo int) int {
+	if err := db.InitializeDB(schema.ListModels()...); err != nil {
+		logger.FatalLog("error connecting to database: ", err.Error())
+	}
+	defer db.CloseDB()
+	if err := database.InitializeDatabase(); err != nil {
+		logger.FatalLog("error initializing database: ", err.Error())
+	}
+	defer database.CloseDB()
+
+	var (
+		report migrate.Report
+		err    error
+	)
+	ctx := db.WithContext(context.TODO())
+	if downTo >= 0 {
+		report, err = migrate.Down(ctx, downTo, dryRun)
+	} else {
+		report, err = migrate.Up(ctx, dryRun)
+	}
+	if err == nil || len(report.Steps) > 0 {
+		fmt.Print(report)
+	}
+	if err != nil {
+		logger.Log(0, "migration failed:", err.Error())
+		return 1
+	}
+	return 0
+}
+
 func startControllers(wg *sync.WaitGroup, ctx context.Context) {
 	//Run Rest Server
 	if servercfg.IsRestBackend() {
@@ -172,6 +252,9 @@ func startControllers(wg *sync.WaitGroup, ctx context.Context) {
 
 		wg.Add(1)
 		go runMessageQueue(wg, ctx)
+	} else if servercfg.IsRestBackend() {
+		logger.Log(0, "no message queue, hosts receive updates over HTTP streams")
+		mq.StartHostStreaming(ctx)
 	}
 
 	if !servercfg.IsRestBackend() && !servercfg.IsMessageQueueBackend() {
@@ -183,11 +266,15 @@ func startControllers(wg *sync.WaitGroup, ctx context.Context) {
 
 	wg.Add(1)
 	go logic.StartHookManager(ctx, wg)
-	// Only run network cleanup hooks on master pod
-	if servercfg.IsMasterPod() {
-		logic.InitNetworkHooks()
-	}
+	// network cleanup hooks only run on the leader
+	logic.InitNetworkHooks()
+	logic.InitAclScheduleHook(func() error {
+		return mq.PublishPeerUpdate(false)
+	})
 	logic.AddSSOStateCleanupHook()
+	// background jobs only run on the leader
+	controller.RegisterJobHandlers()
+	logic.AddJobRunnerHook()
 }
 
 // Should we be using a context vice a waitgroup????????????
@@ -197,12 +284,10 @@ func runMessageQueue(wg *sync.WaitGroup, ctx context.Context) {
 
 	go mq.Keepalive(ctx)
 	go func() {
-		// Only run zombie management and expired node deletion on master pod
+		// zombie and expired nodes are only deleted by the leader
 		// to avoid duplicate operations in HA setup
-		if servercfg.IsMasterPod() {
-			go logic.ManageZombies(ctx)
-			go logic.DeleteExpiredNodes(ctx)
-		}
+		go logic.ManageZombies(ctx)
+		go logic.DeleteExpiredNodes(ctx)
 		for nodeUpdate := range logic.DeleteNodesCh {
 			if nodeUpdate == nil {
 				continue
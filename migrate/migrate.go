@@ -10,7 +10,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/gravitl/netmaker/serverctl"
 	"golang.org/x/exp/slog"
 	"gorm.io/datatypes"
 
@@ -25,43 +24,20 @@ import (
 	"github.com/gravitl/netmaker/servercfg"
 )
 
-// Run - runs all migrations
-func Run() {
-	migrateSettings()
-	updateEnrollmentKeys()
-	assignSuperAdmin()
-	createDefaultTagsAndPolicies()
-	syncUsers()
-	updateNodes()
-	updateNewAcls()
-	logic.MigrateToGws()
-	migrateToEgressV1()
-	updateNetworks()
-	resync()
-	deleteOldExtclients()
-	cleanupDeletedUserGroupRefs()
-	migrateNameservers()
-
-	logic.InitialiseRoles()
-	logic.IntialiseGroups()
-	_ = serverctl.SetDefaults()
-}
-
-func updateNetworks() {
-	initializeVirtualNATSettings()
-}
-
-func initializeVirtualNATSettings() {
+func updateNetworks(ctx context.Context) error {
+	return initializeVirtualNATSettings(ctx)
+}
+
+func initializeVirtualNATSettings(ctx context.Context) error {
 	if !servercfg.IsPro {
-		return
+		return nil
 	}
 	logger.Log(1, "Initializing Virtual NAT settings for existing networks")
 	defer logger.Log(1, "Completed initializing Virtual NAT settings for existing networks")
 
-	networks, err := (&schema.Network{}).ListAll(db.WithContext(context.TODO()))
+	networks, err := (&schema.Network{}).ListAll(ctx)
 	if err != nil {
-		logger.Log(0, "failed to get networks for Virtual NAT migration:", err.Error())
-		return
+		return fmt.Errorf("failed to get networks for Virtual NAT migration: %w", err)
 	}
 
 	allocatedPools := make(map[string]struct{})
@@ -74,13 +50,11 @@ func initializeVirtualNATSettings() {
 
 	_, fallbackNet, err := net.ParseCIDR(logic.FallbackVNATPool)
 	if err != nil || fallbackNet == nil {
-		logger.Log(0, "failed to parse fallback pool for Virtual NAT migration:", err.Error())
-		return
+		return fmt.Errorf("failed to parse fallback pool for Virtual NAT migration: %w", err)
 	}
 	_, cgnatNet, err := net.ParseCIDR(logic.CgnatCIDR)
 	if err != nil || cgnatNet == nil {
-		logger.Log(0, "failed to parse CGNAT CIDR for Virtual NAT migration:", err.Error())
-		return
+		return fmt.Errorf("failed to parse CGNAT CIDR for Virtual NAT migration: %w", err)
 	}
 
 	for _, network := range networks {
@@ -121,11 +95,29 @@ func initializeVirtualNATSettings() {
 		}
 
 		if err := logic.UpsertNetwork(&network); err != nil {
-			logger.Log(0, "failed to update network", network.Name, "with Virtual NAT settings:", err.Error())
-			continue
+			return fmt.Errorf("failed to update network %s with Virtual NAT settings: %w", network.Name, err)
 		}
 		logger.Log(1, "initialized Virtual NAT settings for network", network.Name, "pool:", network.VirtualNATPoolIPv4)
 	}
+	return nil
+}
+
+func planVirtualNAT(ctx context.Context) ([]string, error) {
+	if !servercfg.IsPro {
+		return nil, nil
+	}
+	networks, err := (&schema.Network{}).ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	for _, network := range networks {
+		if isValidVNATPool(network.VirtualNATPoolIPv4) && network.VirtualNATSitePrefixLenIPv4 > 0 {
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("assign a virtual NAT pool to network %s", network.Name))
+	}
+	return changes, nil
 }
 
 func isValidVNATPool(pool string) bool {
@@ -248,10 +240,10 @@ func assignSuperAdmin() {
 	}
 }
 
-func updateEnrollmentKeys() {
+func updateEnrollmentKeys(ctx context.Context) error {
 	rows, err := database.FetchRecords(database.ENROLLMENT_KEYS_TABLE_NAME)
-	if err != nil {
-		return
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
 	}
 	for _, row := range rows {
 		var key models.EnrollmentKey
@@ -263,29 +255,21 @@ func updateEnrollmentKeys() {
 			continue
 		} else {
 			logger.Log(2, "migration: updating enrollment key type")
-			if key.Unlimited {
-				key.Type = models.Unlimited
-			} else if key.UsesRemaining > 0 {
-				key.Type = models.Uses
-			} else if !key.Expiration.IsZero() {
-				key.Type = models.TimeExpiration
-			}
+			key.Type = enrollmentKeyType(key)
 		}
 		data, err := json.Marshal(key)
 		if err != nil {
-			logger.Log(0, "migration: marshalling enrollment key: "+err.Error())
-			continue
+			return fmt.Errorf("marshalling enrollment key: %w", err)
 		}
 		if err = database.Insert(key.Value, string(data), database.ENROLLMENT_KEYS_TABLE_NAME); err != nil {
-			logger.Log(0, "migration: inserting enrollment key: "+err.Error())
-			continue
+			return fmt.Errorf("inserting enrollment key: %w", err)
 		}
 
 	}
 
 	existingKeys, err := logic.GetAllEnrollmentKeys()
 	if err != nil {
-		return
+		return err
 	}
 	// check if any tags are duplicate
 	existingTags := make(map[string]struct{})
@@ -294,12 +278,15 @@ func updateEnrollmentKeys() {
 			existingTags[t] = struct{}{}
 		}
 	}
-	networks, _ := (&schema.Network{}).ListAll(db.WithContext(context.TODO()))
+	networks, err := (&schema.Network{}).ListAll(ctx)
+	if err != nil {
+		return err
+	}
 	for _, network := range networks {
 		if _, ok := existingTags[network.Name]; ok {
 			continue
 		}
-		_, _ = logic.CreateEnrollmentKey(
+		_, err = logic.CreateEnrollmentKey(
 			0,
 			time.Time{},
 			[]string{network.Name},
@@ -311,26 +298,112 @@ func updateEnrollmentKeys() {
 			false,
 			false,
 		)
+		if err != nil {
+			return fmt.Errorf("creating the default enrollment key of network %s: %w", network.Name, err)
+		}
+	}
+	return nil
+}
+
+// enrollmentKeyType - the type of a key created before keys had one
+func enrollmentKeyType(key models.EnrollmentKey) models.KeyType {
+	switch {
+	case key.Unlimited:
+		return models.Unlimited
+	case key.UsesRemaining > 0:
+		return models.Uses
+	case !key.Expiration.IsZero():
+		return models.TimeExpiration
+	}
+	return models.Undefined
+}
+
+// revertEnrollmentKeyTypes - clears the types updateEnrollmentKeys sets,
+// keys whose type was changed since are left as is. The default keys of
+// the networks are kept, earlier versions use them like any other key.
+func revertEnrollmentKeyTypes(ctx context.Context) error {
+	rows, err := database.FetchRecords(database.ENROLLMENT_KEYS_TABLE_NAME)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	for _, row := range rows {
+		var key models.EnrollmentKey
+		if err = json.Unmarshal([]byte(row), &key); err != nil {
+			continue
+		}
+		if key.Type == models.Undefined || key.Type != enrollmentKeyType(key) {
+			continue
+		}
+		key.Type = models.Undefined
+		data, err := json.Marshal(key)
+		if err != nil {
+			return fmt.Errorf("marshalling enrollment key: %w", err)
+		}
+		if err = database.Insert(key.Value, string(data), database.ENROLLMENT_KEYS_TABLE_NAME); err != nil {
+			return fmt.Errorf("inserting enrollment key: %w", err)
+		}
+	}
+	return nil
+}
+
+// planEnrollmentKeyTypes - reports the enrollment keys updateEnrollmentKeys
+// sets the type of and the networks it creates a default key for
+func planEnrollmentKeyTypes(ctx context.Context) ([]string, error) {
+	var changes []string
+	rows, err := database.FetchRecords(database.ENROLLMENT_KEYS_TABLE_NAME)
+	if err == nil {
+		untyped := 0
+		for _, row := range rows {
+			var key models.EnrollmentKey
+			if err = json.Unmarshal([]byte(row), &key); err == nil && key.Type == models.Undefined {
+				untyped++
+			}
+		}
+		if untyped > 0 {
+			changes = append(changes, fmt.Sprintf("set the type of %d enrollment key(s)", untyped))
+		}
 	}
+
+	existingKeys, err := logic.GetAllEnrollmentKeys()
+	if err != nil {
+		return changes, nil
+	}
+	existingTags := make(map[string]struct{})
+	for _, existingKey := range existingKeys {
+		for _, t := range existingKey.Tags {
+			existingTags[t] = struct{}{}
+		}
+	}
+	networks, err := (&schema.Network{}).ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, network := range networks {
+		if _, ok := existingTags[network.Name]; !ok {
+			changes = append(changes, fmt.Sprintf("create a default enrollment key for network %s", network.Name))
+		}
+	}
+	return changes, nil
 }
 
-func updateNodes() {
+func updateNodes(ctx context.Context) error {
 	nodes, err := logic.GetAllNodes()
 	if err != nil {
-		slog.Error("migration failed for nodes", "error", err)
-		return
+		return err
 	}
 	for _, node := range nodes {
 		node := node
 		if node.Tags == nil {
 			node.Tags = make(map[models.TagID]struct{})
-			logic.UpsertNode(&node)
+			if err = logic.UpsertNode(&node); err != nil {
+				return err
+			}
 		}
 		if node.IsIngressGateway {
 			host := &schema.Host{
 				ID: node.HostID,
 			}
-			err = host.Get(db.WithContext(context.TODO()))
+			err = host.Get(ctx)
 			if err == nil {
 				go logic.DeleteRole(models.GetRAGRoleID(node.Network, host.ID.String()), true)
 			}
@@ -340,7 +413,9 @@ func updateNodes() {
 			if update {
 				node.EgressGatewayRequest.Ranges = egressRanges
 				node.EgressGatewayRanges = egressRanges
-				logic.UpsertNode(&node)
+				if err = logic.UpsertNode(&node); err != nil {
+					return err
+				}
 			}
 			if len(node.EgressGatewayRequest.Ranges) > 0 && len(node.EgressGatewayRequest.RangesWithMetric) == 0 {
 				for _, egressRangeI := range node.EgressGatewayRequest.Ranges {
@@ -349,18 +424,71 @@ func updateNodes() {
 						RouteMetric: 256,
 					})
 				}
-				logic.UpsertNode(&node)
+				if err = logic.UpsertNode(&node); err != nil {
+					return err
+				}
 			}
 
 		}
 	}
-	extclients, _ := logic.GetAllExtClients()
+	extclients, err := logic.GetAllExtClients()
+	if err != nil {
+		return err
+	}
 	for _, extclient := range extclients {
 		if extclient.Tags == nil {
 			extclient.Tags = make(map[models.TagID]struct{})
-			logic.SaveExtClient(&extclient)
+			if err = logic.SaveExtClient(&extclient); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
+
+// planNodeTags - reports the nodes and ext clients updateNodes updates
+func planNodeTags(ctx context.Context) ([]string, error) {
+	nodes, err := logic.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	var untagged, egressGws, ingressGws int
+	for _, node := range nodes {
+		if node.Tags == nil {
+			untagged++
+		}
+		if node.IsIngressGateway {
+			ingressGws++
+		}
+		if node.IsEgressGateway {
+			_, update := removeInterGw(slices.Clone(node.EgressGatewayRanges))
+			if update || (len(node.EgressGatewayRequest.Ranges) > 0 && len(node.EgressGatewayRequest.RangesWithMetric) == 0) {
+				egressGws++
+			}
+		}
+	}
+	untaggedClients := 0
+	extclients, _ := logic.GetAllExtClients()
+	for _, extclient := range extclients {
+		if extclient.Tags == nil {
+			untaggedClients++
+		}
+	}
+
+	var changes []string
+	if untagged > 0 {
+		changes = append(changes, fmt.Sprintf("initialise the tags of %d node(s)", untagged))
+	}
+	if ingressGws > 0 {
+		changes = append(changes, fmt.Sprintf("delete the remote access gateway roles of %d gateway(s)", ingressGws))
+	}
+	if egressGws > 0 {
+		changes = append(changes, fmt.Sprintf("update the egress ranges of %d egress gateway(s)", egressGws))
+	}
+	if untaggedClients > 0 {
+		changes = append(changes, fmt.Sprintf("initialise the tags of %d ext client(s)", untaggedClients))
+	}
+	return changes, nil
 }
 
 func removeInterGw(egressRanges []string) ([]string, bool) {
@@ -374,9 +502,12 @@ func removeInterGw(egressRanges []string) ([]string, bool) {
 	return egressRanges, update
 }
 
-func updateNewAcls() {
+func updateNewAcls(ctx context.Context) error {
 	if servercfg.IsPro {
-		userGroups, _ := (&schema.UserGroup{}).ListAll(db.WithContext(context.TODO()))
+		userGroups, err := (&schema.UserGroup{}).ListAll(ctx)
+		if err != nil {
+			return err
+		}
 		for _, userGroup := range userGroups {
 			group := userGroup
 			if group.Default {
@@ -404,7 +535,9 @@ func updateNewAcls() {
 					}
 
 					adminAcl.Src = newAclSrc
-					_ = logic.UpsertAcl(adminAcl)
+					if err = logic.UpsertAcl(adminAcl); err != nil {
+						return err
+					}
 				}
 
 				userAcl, err := logic.GetAcl(fmt.Sprintf("%s.%s-grp", networkID, schema.NetworkUser))
@@ -429,7 +562,9 @@ func updateNewAcls() {
 					}
 
 					userAcl.Src = newAclSrc
-					_ = logic.UpsertAcl(userAcl)
+					if err = logic.UpsertAcl(userAcl); err != nil {
+						return err
+					}
 				}
 
 				expectedAcl := models.Acl{
@@ -458,7 +593,10 @@ func updateNewAcls() {
 					CreatedAt:        time.Now().UTC(),
 				}
 
-				acls, _ := logic.ListAclsByNetwork(networkID)
+				acls, err := logic.ListAclsByNetwork(networkID)
+				if err != nil {
+					return err
+				}
 				for _, acl := range acls {
 					if acl.Name == expectedAcl.Name &&
 						acl.MetaData == expectedAcl.MetaData &&
@@ -471,7 +609,9 @@ func updateNewAcls() {
 						acl.AllowedDirection == expectedAcl.AllowedDirection {
 
 						acl.Default = true
-						_ = logic.UpsertAcl(acl)
+						if err = logic.UpsertAcl(acl); err != nil {
+							return err
+						}
 						createSeparateACL = false
 						break
 					}
@@ -479,13 +619,159 @@ func updateNewAcls() {
 
 				if createSeparateACL {
 					expectedAcl.Enabled = enableSeparateACL
-					_ = logic.InsertAcl(expectedAcl)
+					if err = logic.InsertAcl(expectedAcl); err != nil {
+						return err
+					}
 				}
 			}
 
-			_ = logic.EnsureDefaultUserGroupNetworkPolicies(nil, &group)
+			if err = logic.EnsureDefaultUserGroupNetworkPolicies(nil, &group); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func planUserGroupPolicies(ctx context.Context) ([]string, error) {
+	if !servercfg.IsPro {
+		return nil, nil
+	}
+	userGroups, err := (&schema.UserGroup{}).ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	for _, userGroup := range userGroups {
+		group := userGroup
+		if group.Default {
+			continue
+		}
+		networks, err := logic.GetGroupNetworksMap(&group)
+		if err != nil {
+			continue
 		}
+		src := models.AclPolicyTag{ID: models.UserGroupAclID, Value: group.ID.String()}
+		for networkID := range networks {
+			for _, role := range []schema.UserRoleID{schema.NetworkAdmin, schema.NetworkUser} {
+				acl, err := logic.GetAcl(fmt.Sprintf("%s.%s-grp", networkID, role))
+				if err == nil && slices.Contains(acl.Src, src) {
+					changes = append(changes, fmt.Sprintf("move user group %s out of policy %s into a policy of its own", group.Name, acl.ID))
+				}
+			}
+		}
+		changes = append(changes, fmt.Sprintf("ensure the default network policies of user group %s", group.Name))
 	}
+	return changes, nil
+}
+
+func planGateways(ctx context.Context) ([]string, error) {
+	nodes, err := logic.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	var gws, failovers int
+	for _, node := range nodes {
+		if node.IsIngressGateway || node.IsRelay || node.IsInternetGateway || node.IsFailOver {
+			gws++
+		}
+		if node.IsFailOver || node.FailedOverBy != uuid.Nil || len(node.FailOverPeers) > 0 {
+			failovers++
+		}
+	}
+
+	// the user policies are read from the db, logic.ListAcls leaves them
+	// out on CE.
+	records, err := database.FetchRecords(database.ACLS_TABLE_NAME)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	policies := 0
+	for _, record := range records {
+		var acl models.Acl
+		if err = json.Unmarshal([]byte(record), &acl); err != nil {
+			continue
+		}
+		oldTag := models.AclPolicyTag{
+			ID:    models.NodeTagID,
+			Value: fmt.Sprintf("%s.%s", acl.NetworkID, models.OldRemoteAccessTagName),
+		}
+		if slices.Contains(acl.Src, oldTag) || slices.Contains(acl.Dst, oldTag) {
+			policies++
+		}
+	}
+
+	var changes []string
+	if gws > 0 {
+		changes = append(changes, fmt.Sprintf("turn %d relay, remote access and internet gateway(s) into gateways", gws))
+	}
+	if failovers > 0 {
+		changes = append(changes, fmt.Sprintf("turn off failover on %d node(s)", failovers))
+	}
+	if policies > 0 {
+		changes = append(changes, fmt.Sprintf("point %d policy(ies) at the gateways tag", policies))
+	}
+	networks, err := (&schema.Network{}).ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, network := range networks {
+		tagID := fmt.Sprintf("%s.%s", network.Name, models.OldRemoteAccessTagName)
+		if _, err := database.FetchRecord(database.TAG_TABLE_NAME, tagID); err == nil {
+			changes = append(changes, fmt.Sprintf("delete tag %s", tagID))
+		}
+	}
+	return changes, nil
+}
+
+// revertGateways - down step of the gateways migration. The gateways keep
+// their relay and remote access roles and the gateways tag, which the
+// policies point at, earlier versions serve them as is. The remote access
+// gateways tag is created again and set on the gateways. Failover stays
+// off, it has to be turned on again on the nodes that used it.
+func revertGateways(ctx context.Context) error {
+	nodes, err := logic.GetAllNodes()
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if _, ok := node.Tags[models.TagID(fmt.Sprintf("%s.%s", node.Network, models.GwTagName))]; !ok {
+			continue
+		}
+		oldTag := models.TagID(fmt.Sprintf("%s.%s", node.Network, models.OldRemoteAccessTagName))
+		if _, ok := node.Tags[oldTag]; ok {
+			continue
+		}
+		node.Tags[oldTag] = struct{}{}
+		if err = logic.UpsertNode(&node); err != nil {
+			return err
+		}
+	}
+
+	networks, err := (&schema.Network{}).ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, network := range networks {
+		tag := models.Tag{
+			ID:        models.TagID(fmt.Sprintf("%s.%s", network.Name, models.OldRemoteAccessTagName)),
+			TagName:   models.OldRemoteAccessTagName,
+			Network:   schema.NetworkID(network.Name),
+			CreatedBy: "auto",
+			CreatedAt: time.Now().UTC(),
+		}
+		if _, err := database.FetchRecord(database.TAG_TABLE_NAME, tag.ID.String()); err == nil {
+			continue
+		}
+		data, err := json.Marshal(tag)
+		if err != nil {
+			return err
+		}
+		if err = database.Insert(tag.ID.String(), string(data), database.TAG_TABLE_NAME); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func MigrateEmqx() {
@@ -558,26 +844,34 @@ func createDefaultTagsAndPolicies() {
 	}
 }
 
-func migrateToEgressV1() {
-	nodes, _ := logic.GetAllNodes()
+func migrateToEgressV1(ctx context.Context) error {
+	nodes, err := logic.GetAllNodes()
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(nodes, func(node models.Node) bool { return node.IsEgressGateway }) {
+		return nil
+	}
 	user, err := logic.GetSuperAdmin()
 	if err != nil {
-		return
+		return fmt.Errorf("%w: no super admin to create the egress resources with", errPending)
 	}
 	for _, node := range nodes {
 		if node.IsEgressGateway {
 			host := &schema.Host{
 				ID: node.HostID,
 			}
-			err := host.Get(db.WithContext(context.TODO()))
+			err := host.Get(ctx)
 			if err != nil {
 				continue
 			}
 			for _, rangeMetric := range node.EgressGatewayRequest.RangesWithMetric {
 				e := &schema.Egress{Range: rangeMetric.Network}
-				if err := e.DoesEgressRouteExists(db.WithContext(context.TODO())); err == nil {
+				if err := e.DoesEgressRouteExists(ctx); err == nil {
 					e.Nodes[node.ID.String()] = rangeMetric.RouteMetric
-					e.Update(db.WithContext(context.TODO()))
+					if err = e.Update(ctx); err != nil {
+						return err
+					}
 					continue
 				}
 				e = &schema.Egress{
@@ -598,77 +892,165 @@ func migrateToEgressV1() {
 				if !e.Nat {
 					e.Mode = schema.DisabledNAT
 				}
-				err = e.Create(db.WithContext(context.TODO()))
-				if err == nil {
+				if err = e.Create(ctx); err != nil {
+					return err
+				}
+				for _, ruleType := range []models.AclPolicyType{models.DevicePolicy, models.UserPolicy} {
+					src := models.AclPolicyTag{ID: models.NodeTagID, Value: "*"}
+					if ruleType == models.UserPolicy {
+						src = models.AclPolicyTag{ID: models.UserAclID, Value: "*"}
+					}
 					acl := models.Acl{
-						ID:          uuid.New().String(),
-						Name:        "egress node policy",
-						MetaData:    "",
-						Default:     false,
-						ServiceType: models.Any,
-						NetworkID:   schema.NetworkID(node.Network),
-						Proto:       models.ALL,
-						RuleType:    models.DevicePolicy,
-						Src: []models.AclPolicyTag{
-
-							{
-								ID:    models.NodeTagID,
-								Value: "*",
-							},
-						},
-						Dst: []models.AclPolicyTag{
-							{
-								ID:    models.EgressID,
-								Value: e.ID,
-							},
-						},
-
+						ID:               uuid.New().String(),
+						Name:             "egress node policy",
+						MetaData:         "",
+						Default:          false,
+						ServiceType:      models.Any,
+						NetworkID:        schema.NetworkID(node.Network),
+						Proto:            models.ALL,
+						RuleType:         ruleType,
+						Src:              []models.AclPolicyTag{src},
+						Dst:              []models.AclPolicyTag{{ID: models.EgressID, Value: e.ID}},
 						AllowedDirection: models.TrafficDirectionBi,
 						Enabled:          true,
 						CreatedBy:        "auto",
 						CreatedAt:        time.Now().UTC(),
 					}
-					logic.InsertAcl(acl)
-					acl = models.Acl{
-						ID:          uuid.New().String(),
-						Name:        "egress node policy",
-						MetaData:    "",
-						Default:     false,
-						ServiceType: models.Any,
-						NetworkID:   schema.NetworkID(node.Network),
-						Proto:       models.ALL,
-						RuleType:    models.UserPolicy,
-						Src: []models.AclPolicyTag{
-
-							{
-								ID:    models.UserAclID,
-								Value: "*",
-							},
-						},
-						Dst: []models.AclPolicyTag{
-							{
-								ID:    models.EgressID,
-								Value: e.ID,
-							},
-						},
-
-						AllowedDirection: models.TrafficDirectionBi,
-						Enabled:          true,
-						CreatedBy:        "auto",
-						CreatedAt:        time.Now().UTC(),
+					if err = logic.InsertAcl(acl); err != nil {
+						return err
 					}
-					logic.InsertAcl(acl)
 				}
-
 			}
 			node.IsEgressGateway = false
 			node.EgressGatewayRequest = models.EgressGatewayRequest{}
 			node.EgressGatewayNatEnabled = false
 			node.EgressGatewayRanges = []string{}
-			logic.UpsertNode(&node)
+			if err = logic.UpsertNode(&node); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// revertEgressV1 - turns the egress resources back into egress gateways of
+// their nodes and deletes the policies and the resources. It fails before
+// changing anything if a resource can't be set on a node, e.g. it routes a
+// domain or is applied to tags.
+func revertEgressV1(ctx context.Context) error {
+	egresses, err := (&schema.Egress{}).List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, e := range egresses {
+		if e.Domain != "" || e.Range == "" || e.Mode == schema.VirtualNAT || !e.Status ||
+			len(e.Tags) > 0 || len(e.Nodes) == 0 {
+			return fmt.Errorf("egress %s can't be set on its nodes", e.Name)
+		}
+	}
+
+	egressNodes := make(map[string]*models.Node)
+	egressIDs := make(map[string]struct{})
+	for _, e := range egresses {
+		egressIDs[e.ID] = struct{}{}
+		for nodeID, metric := range e.Nodes {
+			node, ok := egressNodes[nodeID]
+			if !ok {
+				n, err := logic.GetNodeByID(nodeID)
+				if err != nil {
+					continue
+				}
+				node = &n
+				node.IsEgressGateway = true
+				node.EgressGatewayRanges = []string{}
+				node.EgressGatewayRequest = models.EgressGatewayRequest{
+					NodeID:     nodeID,
+					NetID:      node.Network,
+					NatEnabled: "no",
+				}
+				egressNodes[nodeID] = node
+			}
+			m64, err := metric.(json.Number).Int64()
+			if err != nil {
+				m64 = 256
+			}
+			routeMetric := uint32(m64)
+			node.EgressGatewayRanges = append(node.EgressGatewayRanges, e.Range)
+			node.EgressGatewayRequest.Ranges = append(node.EgressGatewayRequest.Ranges, e.Range)
+			node.EgressGatewayRequest.RangesWithMetric = append(node.EgressGatewayRequest.RangesWithMetric,
+				models.EgressRangeMetric{
+					Network:     e.Range,
+					RouteMetric: routeMetric,
+				})
+			if e.Nat {
+				node.EgressGatewayNatEnabled = true
+				node.EgressGatewayRequest.NatEnabled = "yes"
+			}
+		}
+	}
+	for _, node := range egressNodes {
+		if err = logic.UpsertNode(node); err != nil {
+			return err
+		}
+	}
+
+	// the user policies are read from the db, logic.ListAcls leaves them
+	// out on CE.
+	records, err := database.FetchRecords(database.ACLS_TABLE_NAME)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	for _, record := range records {
+		var acl models.Acl
+		if err = json.Unmarshal([]byte(record), &acl); err != nil {
+			continue
+		}
+		dst := slices.DeleteFunc(slices.Clone(acl.Dst), func(tag models.AclPolicyTag) bool {
+			_, ok := egressIDs[tag.Value]
+			return tag.ID == models.EgressID && ok
+		})
+		if len(dst) == len(acl.Dst) {
+			continue
+		}
+		if len(dst) == 0 {
+			err = logic.DeleteAcl(acl)
+		} else {
+			acl.Dst = dst
+			err = logic.UpsertAcl(acl)
+		}
+		if err != nil {
+			return err
+		}
+	}
 
+	for _, e := range egresses {
+		if err = e.Delete(ctx); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// planEgressV1 - reports the egress gateways migrateToEgressV1 converts to
+// egress resources
+func planEgressV1(ctx context.Context) ([]string, error) {
+	nodes, err := logic.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	for _, node := range nodes {
+		if node.IsEgressGateway {
+			changes = append(changes, fmt.Sprintf("convert %d egress range(s) of node %s to egress resources",
+				len(node.EgressGatewayRequest.RangesWithMetric), node.ID))
+		}
+	}
+	if len(changes) > 0 {
+		if _, err := logic.GetSuperAdmin(); err != nil {
+			return []string{"nothing, no super admin to create the egress resources with"}, nil
+		}
+	}
+	return changes, nil
 }
 
 func migrateSettings() {
@@ -695,6 +1077,9 @@ func migrateSettings() {
 	if settings.AuditLogsRetentionPeriodInDays == 0 {
 		settings.AuditLogsRetentionPeriodInDays = 7
 	}
+	if settings.MetricsRetentionPeriodInDays == 0 {
+		settings.MetricsRetentionPeriodInDays = 90
+	}
 	if settings.DefaultDomain == "" {
 		settings.DefaultDomain = servercfg.GetDefaultDomain()
 	}
@@ -707,8 +1092,37 @@ func migrateSettings() {
 	logic.UpsertServerSettings(settings)
 }
 
-func deleteOldExtclients() {
-	extclients, _ := logic.GetAllExtClients()
+func deleteOldExtclients(ctx context.Context) error {
+	stale, err := staleRemoteAccessClients()
+	if err != nil {
+		return err
+	}
+	for _, extclient := range stale {
+		if err = logic.DeleteExtClient(extclient.Network, extclient.ClientID, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func planRemoteAccessClients(ctx context.Context) ([]string, error) {
+	stale, err := staleRemoteAccessClients()
+	if err != nil {
+		return nil, err
+	}
+	if len(stale) == 0 {
+		return nil, nil
+	}
+	return []string{fmt.Sprintf("delete %d duplicate disabled remote access client(s)", len(stale))}, nil
+}
+
+// staleRemoteAccessClients - the disabled remote access clients of a user
+// beyond the first one
+func staleRemoteAccessClients() ([]models.ExtClient, error) {
+	extclients, err := logic.GetAllExtClients()
+	if err != nil {
+		return nil, err
+	}
 	userExtclientMap := make(map[string][]models.ExtClient)
 	for _, extclient := range extclients {
 		if extclient.RemoteAccessClientID == "" {
@@ -726,20 +1140,19 @@ func deleteOldExtclients() {
 		userExtclientMap[extclient.OwnerID] = append(userExtclientMap[extclient.OwnerID], extclient)
 	}
 
+	var stale []models.ExtClient
 	for _, userExtclients := range userExtclientMap {
 		if len(userExtclients) > 1 {
-			for _, extclient := range userExtclients[1:] {
-				_ = logic.DeleteExtClient(extclient.Network, extclient.Network, false)
-			}
+			stale = append(stale, userExtclients[1:]...)
 		}
 	}
+	return stale, nil
 }
 
-func cleanupDeletedUserGroupRefs() {
-	groups, err := (&schema.UserGroup{}).ListAll(db.WithContext(context.TODO()))
+func cleanupDeletedUserGroupRefs(ctx context.Context) error {
+	groups, err := (&schema.UserGroup{}).ListAll(ctx)
 	if err != nil {
-		// skip if we can't list all groups.
-		return
+		return err
 	}
 
 	existingGroups := make(map[schema.UserGroupID]schema.UserGroup)
@@ -748,7 +1161,10 @@ func cleanupDeletedUserGroupRefs() {
 	}
 
 	existingUsers := make(map[string]schema.User)
-	users, _ := (&schema.User{}).ListAll(db.WithContext(context.TODO()))
+	users, err := (&schema.User{}).ListAll(ctx)
+	if err != nil {
+		return err
+	}
 	for _, user := range users {
 		existingUsers[user.Username] = user
 		var update bool
@@ -760,68 +1176,168 @@ func cleanupDeletedUserGroupRefs() {
 		}
 
 		if update {
-			_ = user.Update(db.WithContext(context.TODO()))
+			if err = user.Update(ctx); err != nil {
+				return err
+			}
 		}
 	}
 
 	for _, acl := range logic.ListAcls() {
-		var newSrc []models.AclPolicyTag
-		for _, src := range acl.Src {
-			if src.ID == models.UserGroupAclID {
-				if group, ok := existingGroups[schema.UserGroupID(src.Value)]; ok {
-					var hasAccess bool
-					if _, ok := group.NetworkRoles.Data()[schema.AllNetworks]; ok {
-						hasAccess = true
-					}
+		newSrc := existingPolicySrc(acl, existingGroups, existingUsers)
+		if len(newSrc) == 0 {
+			err = logic.DeleteAcl(acl)
+		} else if len(acl.Src) != len(newSrc) {
+			acl.Src = newSrc
+			err = logic.UpsertAcl(acl)
+		}
+		if err != nil {
+			return err
+		}
+	}
 
-					if _, ok := group.NetworkRoles.Data()[acl.NetworkID]; ok {
-						hasAccess = true
-					}
+	postureChecks, err := (&schema.PostureCheck{}).ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, postureCheck := range postureChecks {
+		var update bool
+		for groupID := range postureCheck.UserGroups {
+			if _, ok := existingGroups[schema.UserGroupID(groupID)]; !ok {
+				delete(postureCheck.UserGroups, groupID)
+				update = true
+			}
+		}
 
-					if hasAccess {
-						newSrc = append(newSrc, src)
-					}
+		if update {
+			if err = postureCheck.Update(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// existingPolicySrc - the sources of the policy left once the deleted users
+// and the user groups without access to the network of the policy are
+// removed
+func existingPolicySrc(acl models.Acl, existingGroups map[schema.UserGroupID]schema.UserGroup, existingUsers map[string]schema.User) []models.AclPolicyTag {
+	var newSrc []models.AclPolicyTag
+	for _, src := range acl.Src {
+		if src.ID == models.UserGroupAclID {
+			if group, ok := existingGroups[schema.UserGroupID(src.Value)]; ok {
+				var hasAccess bool
+				if _, ok := group.NetworkRoles.Data()[schema.AllNetworks]; ok {
+					hasAccess = true
+				}
+
+				if _, ok := group.NetworkRoles.Data()[acl.NetworkID]; ok {
+					hasAccess = true
 				}
-			} else if src.ID == models.UserAclID && src.Value != "*" {
-				if _, ok := existingUsers[src.Value]; ok {
+
+				if hasAccess {
 					newSrc = append(newSrc, src)
 				}
-			} else {
+			}
+		} else if src.ID == models.UserAclID && src.Value != "*" {
+			if _, ok := existingUsers[src.Value]; ok {
 				newSrc = append(newSrc, src)
 			}
+		} else {
+			newSrc = append(newSrc, src)
+		}
+	}
+	return newSrc
+}
+
+func planDeletedUserGroupRefs(ctx context.Context) ([]string, error) {
+	groups, err := (&schema.UserGroup{}).ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existingGroups := make(map[schema.UserGroupID]schema.UserGroup)
+	for _, group := range groups {
+		existingGroups[group.ID] = group
+	}
+	isDeleted := func(groupID schema.UserGroupID) bool {
+		_, ok := existingGroups[groupID]
+		return !ok
+	}
+
+	existingUsers := make(map[string]schema.User)
+	users, err := (&schema.User{}).ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var changedUsers int
+	for _, user := range users {
+		existingUsers[user.Username] = user
+		for groupID := range user.UserGroups.Data() {
+			if isDeleted(groupID) {
+				changedUsers++
+				break
+			}
 		}
+	}
 
+	var changedPolicies, deletedPolicies int
+	for _, acl := range logic.ListAcls() {
+		newSrc := existingPolicySrc(acl, existingGroups, existingUsers)
 		if len(newSrc) == 0 {
-			_ = logic.DeleteAcl(acl)
+			deletedPolicies++
 		} else if len(acl.Src) != len(newSrc) {
-			acl.Src = newSrc
-			_ = logic.UpsertAcl(acl)
+			changedPolicies++
 		}
 	}
 
-	postureChecks, _ := (&schema.PostureCheck{}).ListAll(db.WithContext(context.TODO()))
+	postureChecks, err := (&schema.PostureCheck{}).ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var changedPostureChecks int
 	for _, postureCheck := range postureChecks {
-		var update bool
 		for groupID := range postureCheck.UserGroups {
-			if _, ok := existingGroups[schema.UserGroupID(groupID)]; !ok {
-				delete(postureCheck.UserGroups, groupID)
-				update = true
+			if isDeleted(schema.UserGroupID(groupID)) {
+				changedPostureChecks++
+				break
 			}
 		}
+	}
 
-		if update {
-			_ = postureCheck.Update(db.WithContext(context.TODO()))
-		}
+	var changes []string
+	if changedUsers > 0 {
+		changes = append(changes, fmt.Sprintf("remove deleted user groups from %d user(s)", changedUsers))
+	}
+	if changedPolicies > 0 {
+		changes = append(changes, fmt.Sprintf("remove deleted users and user groups from %d policy(ies)", changedPolicies))
+	}
+	if deletedPolicies > 0 {
+		changes = append(changes, fmt.Sprintf("delete %d policy(ies) left without sources", deletedPolicies))
+	}
+	if changedPostureChecks > 0 {
+		changes = append(changes, fmt.Sprintf("remove deleted user groups from %d posture check(s)", changedPostureChecks))
 	}
+	return changes, nil
 }
 
-func migrateNameservers() {
-	networks, _ := (&schema.Network{}).ListAll(db.WithContext(context.TODO()))
+// gwNameserversSuffix - suffix of the names of the nameservers created from
+// the dns servers set on gateways
+const gwNameserversSuffix = " gw nameservers"
+
+func migrateNameservers(ctx context.Context) error {
+	networks, err := (&schema.Network{}).ListAll(ctx)
+	if err != nil {
+		return err
+	}
 	for _, network := range networks {
-		_ = logic.CreateFallbackNameserver(network.Name)
+		if err = logic.CreateFallbackNameserver(network.Name); err != nil {
+			return err
+		}
 	}
 
-	nameservers, _ := (&schema.Nameserver{}).ListAll(db.WithContext(context.TODO()))
+	nameservers, err := (&schema.Nameserver{}).ListAll(ctx)
+	if err != nil {
+		return err
+	}
 	for _, nameserver := range nameservers {
 		if len(nameserver.Domains) != 0 {
 			for _, matchDomain := range nameserver.MatchDomains {
@@ -832,49 +1348,41 @@ func migrateNameservers() {
 
 			nameserver.MatchDomains = []string{}
 
-			_ = nameserver.Update(db.WithContext(context.TODO()))
+			if err = nameserver.Update(ctx); err != nil {
+				return err
+			}
 		}
 	}
 
-	superAdmin := &schema.User{}
-	err := superAdmin.GetSuperAdmin(db.WithContext(context.TODO()))
+	nodes, err := logic.GetAllNodes()
 	if err != nil {
-		return
+		return err
 	}
-
-	nodes, _ := logic.GetAllNodes()
+	var superAdmin *schema.User
 	for _, node := range nodes {
 		if !node.IsGw {
 			continue
 		}
 
 		if node.IngressDNS != "" {
-			var nsIPs []string
-			for _, nsIP := range strings.Split(node.IngressDNS, ",") {
-				nsIP = strings.TrimSpace(nsIP)
-
-				if (node.Address.IP != nil && node.Address.IP.String() == nsIP) ||
-					(node.Address6.IP != nil && node.Address6.IP.String() == nsIP) {
-					continue
-				}
-				if nsIP == "8.8.8.8" || nsIP == "1.1.1.1" || nsIP == "9.9.9.9" {
-					continue
-				}
-
-				nsIPs = append(nsIPs, nsIP)
-			}
-
+			nsIPs := customIngressDNS(node)
 			if len(nsIPs) > 0 {
+				if superAdmin == nil {
+					superAdmin = &schema.User{}
+					if err = superAdmin.GetSuperAdmin(ctx); err != nil {
+						return fmt.Errorf("%w: no super admin to create the gateway nameservers with", errPending)
+					}
+				}
 				host := &schema.Host{
 					ID: node.HostID,
 				}
-				err := host.Get(db.WithContext(context.TODO()))
+				err := host.Get(ctx)
 				if err != nil {
 					continue
 				}
 				ns := schema.Nameserver{
 					ID:        uuid.NewString(),
-					Name:      fmt.Sprintf("%s gw nameservers", host.Name),
+					Name:      fmt.Sprintf("%s%s", host.Name, gwNameserversSuffix),
 					NetworkID: node.Network,
 					Servers:   nsIPs,
 					MatchAll:  true,
@@ -890,10 +1398,114 @@ func migrateNameservers() {
 					Status:    true,
 					CreatedBy: superAdmin.Username,
 				}
-				_ = ns.Create(db.WithContext(context.TODO()))
+				if err = ns.Create(ctx); err != nil {
+					return err
+				}
 				node.IngressDNS = ""
-				_ = logic.UpsertNode(&node)
+				if err = logic.UpsertNode(&node); err != nil {
+					return err
+				}
 			}
 		}
 	}
+	return nil
+}
+
+// revertNameservers - sets the nameservers created from the dns servers of
+// gateways back on the gateways and deletes them along with the fallback
+// nameservers. The gateway addresses and public resolvers migrateNameservers
+// leaves out, and the match domains it moves, are not restored.
+func revertNameservers(ctx context.Context) error {
+	nameservers, err := (&schema.Nameserver{}).ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ns := range nameservers {
+		switch {
+		case ns.Default && ns.Name == logic.GooglePublicNameserverName:
+		case strings.HasSuffix(ns.Name, gwNameserversSuffix) && len(ns.Nodes) == 1:
+			for nodeID := range ns.Nodes {
+				node, err := logic.GetNodeByID(nodeID)
+				if err != nil || !node.IsGw {
+					continue
+				}
+				node.IngressDNS = strings.Join(ns.Servers, ", ")
+				if err = logic.UpsertNode(&node); err != nil {
+					return err
+				}
+			}
+		default:
+			continue
+		}
+		if err = ns.Delete(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planNameservers - reports the nameservers migrateNameservers creates and
+// updates
+func planNameservers(ctx context.Context) ([]string, error) {
+	var changes []string
+	networks, _ := (&schema.Network{}).ListAll(ctx)
+	for _, network := range networks {
+		nameservers, err := (&schema.Nameserver{NetworkID: network.Name}).ListByNetwork(ctx)
+		if err != nil {
+			return nil, err
+		}
+		hasFallback := false
+		for _, ns := range nameservers {
+			if ns.Default && ns.Name == logic.GooglePublicNameserverName {
+				hasFallback = true
+				break
+			}
+		}
+		if !hasFallback {
+			changes = append(changes, fmt.Sprintf("create the fallback nameserver of network %s", network.Name))
+		}
+	}
+
+	nameservers, _ := (&schema.Nameserver{}).ListAll(ctx)
+	for _, nameserver := range nameservers {
+		if len(nameserver.Domains) != 0 && len(nameserver.MatchDomains) != 0 {
+			changes = append(changes, fmt.Sprintf("move the match domains of nameserver %s to its domains", nameserver.Name))
+		}
+	}
+
+	superAdmin := &schema.User{}
+	if err := superAdmin.GetSuperAdmin(ctx); err != nil {
+		return changes, nil
+	}
+	nodes, _ := logic.GetAllNodes()
+	for _, node := range nodes {
+		if !node.IsGw || node.IngressDNS == "" {
+			continue
+		}
+		if nsIPs := customIngressDNS(node); len(nsIPs) > 0 {
+			changes = append(changes, fmt.Sprintf("convert the dns servers %s of gateway %s to a nameserver",
+				strings.Join(nsIPs, ", "), node.ID))
+		}
+	}
+	return changes, nil
+}
+
+// customIngressDNS - the dns servers set on the gateway, leaving out the
+// gateway itself and the public resolvers used as defaults
+func customIngressDNS(node models.Node) []string {
+	var nsIPs []string
+	for _, nsIP := range strings.Split(node.IngressDNS, ",") {
+		nsIP = strings.TrimSpace(nsIP)
+
+		if (node.Address.IP != nil && node.Address.IP.String() == nsIP) ||
+			(node.Address6.IP != nil && node.Address6.IP.String() == nsIP) {
+			continue
+		}
+		if nsIP == "8.8.8.8" || nsIP == "1.1.1.1" || nsIP == "9.9.9.9" {
+			continue
+		}
+
+		nsIPs = append(nsIPs, nsIP)
+	}
+	return nsIPs
 }
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,11 +20,15 @@ import (
 	"gorm.io/gorm"
 )
 
-// ToSQLSchema migrates the data from key-value
+// upstreamNameserversName is the name of the nameservers
+// created from the nameservers of a network.
+const upstreamNameserversName = "upstream nameservers"
+
+// migrateToSQLSchema migrates the data from key-value
 // db to sql db.
-func ToSQLSchema() error {
+func migrateToSQLSchema(ctx context.Context) error {
 	// begin a new transaction.
-	dbctx := db.BeginTx(context.TODO())
+	dbctx := db.BeginTx(ctx)
 	commit := false
 	defer func() {
 		if commit {
@@ -34,9 +39,8 @@ func ToSQLSchema() error {
 	}()
 
 	// v1.5.1 migration includes migrating the users, groups, roles, networks and hosts tables.
-	// future table migrations should be made below this block,
-	// with a different version number and a similar check for whether the
-	// migration was already done.
+	// it is recorded as a job too, so that servers that ran it before
+	// versioned migrations don't run it again.
 	migrationJob := &schema.Job{
 		ID: "migration-v1.5.1",
 	}
@@ -68,6 +72,63 @@ func ToSQLSchema() error {
 	return nil
 }
 
+// revertSQLSchema writes the users, groups, roles, networks
+// and hosts of the sql db back to the key-value db and removes
+// them from the sql db, so that migrateToSQLSchema runs again.
+func revertSQLSchema(ctx context.Context) error {
+	dbctx := db.BeginTx(ctx)
+	commit := false
+	defer func() {
+		if commit {
+			db.FromContext(dbctx).Commit()
+		} else {
+			db.FromContext(dbctx).Rollback()
+		}
+	}()
+
+	err := revertV1_5_1(dbctx)
+	if err != nil {
+		return err
+	}
+
+	err = db.FromContext(dbctx).Delete(&schema.Job{ID: "migration-v1.5.1"}).Error
+	if err != nil {
+		return err
+	}
+
+	commit = true
+	return nil
+}
+
+// planSQLSchema reports the records of the key-value
+// tables that would be migrated to the sql db.
+func planSQLSchema(ctx context.Context) ([]string, error) {
+	err := (&schema.Job{ID: "migration-v1.5.1"}).Get(ctx)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var changes []string
+	for _, table := range []string{
+		database.USERS_TABLE_NAME,
+		database.NETWORKS_TABLE_NAME,
+		database.USER_PERMISSIONS_TABLE_NAME,
+		database.USER_GROUPS_TABLE_NAME,
+		database.HOSTS_TABLE_NAME,
+	} {
+		records, err := FetchAll(ctx, table)
+		if err != nil {
+			// the table is missing or empty.
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("migrate %d record(s) of %s", len(records), table))
+	}
+	return changes, nil
+}
+
 func migrateV1_5_1(ctx context.Context) error {
 	err := migrateUsers(ctx)
 	if err != nil {
@@ -92,6 +153,30 @@ func migrateV1_5_1(ctx context.Context) error {
 	return migrateHosts(ctx)
 }
 
+func revertV1_5_1(ctx context.Context) error {
+	err := revertUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = revertNetworks(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = revertUserRoles(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = revertUserGroups(ctx)
+	if err != nil {
+		return err
+	}
+
+	return revertHosts(ctx)
+}
+
 func migrateUsers(ctx context.Context) error {
 	records, err := FetchAll(ctx, database.USERS_TABLE_NAME)
 	if err != nil && !database.IsEmptyRecord(err) {
@@ -237,7 +322,7 @@ func migrateNetworks(ctx context.Context) error {
 		if len(network.NameServers) > 0 {
 			ns := schema.Nameserver{
 				ID:        uuid.NewString(),
-				Name:      "upstream nameservers",
+				Name:      upstreamNameserversName,
 				NetworkID: _network.Name,
 				Servers:   []string{},
 				MatchAll:  true,
@@ -424,6 +509,239 @@ func migrateHosts(ctx context.Context) error {
 	return nil
 }
 
+// replaceAll replaces the records of a key-value table.
+func replaceAll(ctx context.Context, tableName string, records map[string]interface{}) error {
+	err := database.DeleteAllRecordsTx(ctx, tableName)
+	if err != nil {
+		return err
+	}
+
+	for key, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		err = database.InsertTx(ctx, key, string(data), tableName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func revertUsers(ctx context.Context) error {
+	var users []schema.User
+	err := db.FromContext(ctx).Find(&users).Error
+	if err != nil {
+		return err
+	}
+
+	records := make(map[string]interface{})
+	for _, user := range users {
+		logger.Log(4, fmt.Sprintf("reverting user %s", user.Username))
+
+		records[user.Username] = models.User{
+			UserName:                   user.Username,
+			ExternalIdentityProviderID: user.ExternalIdentityProviderID,
+			IsMFAEnabled:               user.IsMFAEnabled,
+			TOTPSecret:                 user.TOTPSecret,
+			DisplayName:                user.DisplayName,
+			AccountDisabled:            user.AccountDisabled,
+			Password:                   user.Password,
+			IsAdmin:                    user.PlatformRoleID == schema.SuperAdminRole || user.PlatformRoleID == schema.AdminRole,
+			IsSuperAdmin:               user.PlatformRoleID == schema.SuperAdminRole,
+			AuthType:                   user.AuthType,
+			UserGroups:                 user.UserGroups.Data(),
+			PlatformRoleID:             user.PlatformRoleID,
+			LastLoginTime:              user.LastLoginAt,
+			CreatedBy:                  user.CreatedBy,
+			CreatedAt:                  user.CreatedAt,
+			UpdatedAt:                  user.UpdatedAt,
+		}
+	}
+
+	err = replaceAll(ctx, database.USERS_TABLE_NAME, records)
+	if err != nil {
+		return err
+	}
+
+	return db.FromContext(ctx).Where("1 = 1").Delete(&schema.User{}).Error
+}
+
+func revertNetworks(ctx context.Context) error {
+	var networks []schema.Network
+	err := db.FromContext(ctx).Find(&networks).Error
+	if err != nil {
+		return err
+	}
+
+	records := make(map[string]interface{})
+	for _, network := range networks {
+		logger.Log(4, fmt.Sprintf("reverting network %s", network.Name))
+
+		_network := models.Network{
+			AddressRange:                network.AddressRange,
+			AddressRange6:               network.AddressRange6,
+			NetID:                       network.Name,
+			NodesLastModified:           network.NodesUpdatedAt.Unix(),
+			NetworkLastModified:         network.UpdatedAt.Unix(),
+			DefaultKeepalive:            int32(network.DefaultKeepAlive),
+			IsIPv4:                      models.FormatBool(network.AddressRange != ""),
+			IsIPv6:                      models.FormatBool(network.AddressRange6 != ""),
+			DefaultMTU:                  network.DefaultMTU,
+			DefaultACL:                  "yes",
+			AutoJoin:                    strconv.FormatBool(network.AutoJoin),
+			AutoRemove:                  strconv.FormatBool(network.AutoRemove),
+			AutoRemoveTags:              network.AutoRemoveTags,
+			AutoRemoveThreshold:         network.AutoRemoveThreshold,
+			JITEnabled:                  models.FormatBool(network.JITEnabled),
+			VirtualNATPoolIPv4:          network.VirtualNATPoolIPv4,
+			VirtualNATSitePrefixLenIPv4: network.VirtualNATSitePrefixLenIPv4,
+			CreatedBy:                   network.CreatedBy,
+			CreatedAt:                   network.CreatedAt,
+		}
+
+		// the nameservers of the network were moved to the upstream
+		// nameservers by migrateNetworks.
+		nameservers, err := (&schema.Nameserver{NetworkID: network.Name}).ListByNetwork(ctx)
+		if err != nil {
+			return err
+		}
+		for _, ns := range nameservers {
+			if ns.Name != upstreamNameserversName {
+				continue
+			}
+
+			_network.NameServers = append(_network.NameServers, ns.Servers...)
+			err = ns.Delete(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		records[network.Name] = _network
+	}
+
+	err = replaceAll(ctx, database.NETWORKS_TABLE_NAME, records)
+	if err != nil {
+		return err
+	}
+
+	return db.FromContext(ctx).Where("1 = 1").Delete(&schema.Network{}).Error
+}
+
+func revertUserRoles(ctx context.Context) error {
+	var userRoles []schema.UserRole
+	err := db.FromContext(ctx).Find(&userRoles).Error
+	if err != nil {
+		return err
+	}
+
+	records := make(map[string]interface{})
+	for _, userRole := range userRoles {
+		logger.Log(4, fmt.Sprintf("reverting user role %s", userRole.ID))
+
+		records[userRole.ID.String()] = userRole
+	}
+
+	err = replaceAll(ctx, database.USER_PERMISSIONS_TABLE_NAME, records)
+	if err != nil {
+		return err
+	}
+
+	return db.FromContext(ctx).Where("1 = 1").Delete(&schema.UserRole{}).Error
+}
+
+func revertUserGroups(ctx context.Context) error {
+	var userGroups []schema.UserGroup
+	err := db.FromContext(ctx).Find(&userGroups).Error
+	if err != nil {
+		return err
+	}
+
+	records := make(map[string]interface{})
+	for _, userGroup := range userGroups {
+		logger.Log(4, fmt.Sprintf("reverting user group %s", userGroup.ID))
+
+		records[userGroup.ID.String()] = userGroup
+	}
+
+	err = replaceAll(ctx, database.USER_GROUPS_TABLE_NAME, records)
+	if err != nil {
+		return err
+	}
+
+	return db.FromContext(ctx).Where("1 = 1").Delete(&schema.UserGroup{}).Error
+}
+
+func revertHosts(ctx context.Context) error {
+	var hosts []schema.Host
+	err := db.FromContext(ctx).Find(&hosts).Error
+	if err != nil {
+		return err
+	}
+
+	records := make(map[string]interface{})
+	for _, host := range hosts {
+		logger.Log(4, fmt.Sprintf("reverting host %s", host.ID))
+
+		_host := models.Host{
+			ID:                  host.ID,
+			Verbosity:           host.Verbosity,
+			FirewallInUse:       host.FirewallInUse,
+			Version:             host.Version,
+			IPForwarding:        host.IPForwarding,
+			DaemonInstalled:     host.DaemonInstalled,
+			AutoUpdate:          host.AutoUpdate,
+			HostPass:            host.HostPass,
+			Name:                host.Name,
+			OS:                  host.OS,
+			OSFamily:            host.OSFamily,
+			OSVersion:           host.OSVersion,
+			KernelVersion:       host.KernelVersion,
+			Interface:           host.Interface,
+			Debug:               host.Debug,
+			ListenPort:          host.ListenPort,
+			WgPublicListenPort:  host.WgPublicListenPort,
+			MTU:                 host.MTU,
+			PublicKey:           host.PublicKey.Key,
+			MacAddress:          host.MacAddress,
+			TrafficKeyPublic:    host.TrafficKeyPublic,
+			Nodes:               host.Nodes,
+			Interfaces:          host.Interfaces,
+			DefaultInterface:    host.DefaultInterface,
+			EndpointIP:          host.EndpointIP,
+			EndpointIPv6:        host.EndpointIPv6,
+			IsDocker:            host.IsDocker,
+			IsK8S:               host.IsK8S,
+			IsStaticPort:        host.IsStaticPort,
+			IsStatic:            host.IsStatic,
+			IsDefault:           host.IsDefault,
+			DNS:                 host.DNS,
+			NatType:             host.NatType,
+			PersistentKeepalive: host.PersistentKeepalive,
+			Location:            host.Location,
+			CountryCode:         host.CountryCode,
+			EnableFlowLogs:      host.EnableFlowLogs,
+		}
+
+		if host.TurnEndpoint != nil {
+			_host.TurnEndpoint = &host.TurnEndpoint.AddrPort
+		}
+
+		records[host.ID.String()] = _host
+	}
+
+	err = replaceAll(ctx, database.HOSTS_TABLE_NAME, records)
+	if err != nil {
+		return err
+	}
+
+	return db.FromContext(ctx).Where("1 = 1").Delete(&schema.Host{}).Error
+}
+
 func FetchAll(ctx context.Context, tableName string) (map[string]string, error) {
 	row, err := db.FromContext(ctx).Raw("SELECT * FROM " + tableName + " ORDER BY key").Rows()
 	if err != nil {
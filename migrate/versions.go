@@ -0,0 +1,278 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+	"github.com/gravitl/netmaker/serverctl"
+)
+
+// ErrDatabaseNewer - the database has migrations applied that this version of
+// the server doesn't know, i.e. it was migrated by a newer version
+var ErrDatabaseNewer = errors.New("database was migrated by a newer version of the server")
+
+// ErrIrreversible - a migration to revert has no down step
+var ErrIrreversible = errors.New("migration can't be reverted")
+
+// errPending - returned by a migration that can't run yet, e.g. before the
+// super admin is created. It is not recorded and runs again on the next start.
+var errPending = errors.New("migration pending")
+
+// Migration - a numbered change to the database, applied once and recorded
+// in schema.SchemaMigration. Up has to be safe to run again, the server may
+// stop after a migration was applied but before it was recorded.
+//
+// A Migration without a Version is not recorded, it runs on every start to
+// bring the data in line with the defaults of this version.
+type Migration struct {
+	Version int
+	Name    string
+	// ProOnly - left pending on CE servers and applied once the server runs
+	// as pro, even if later versions were applied in the meantime
+	ProOnly bool
+	Up      func(ctx context.Context) error
+	// Down - reverts Up, so that the version of the server before the
+	// migration runs with the database. nil if the migration can't be
+	// reverted.
+	Down func(ctx context.Context) error
+	// Plan - describes the changes Up would make without making them, used
+	// by dry runs
+	Plan func(ctx context.Context) ([]string, error)
+}
+
+// migrations - the migrations in the order they run. Numbered migrations are
+// added at the end with the next version, versions are never reused.
+var migrations = []Migration{
+	{Version: 1, Name: "sql_schema", Up: migrateToSQLSchema, Down: revertSQLSchema, Plan: planSQLSchema},
+	{Name: "server_settings", Up: legacy(migrateSettings)},
+	{Version: 2, Name: "enrollment_key_types", Up: updateEnrollmentKeys, Down: revertEnrollmentKeyTypes, Plan: planEnrollmentKeyTypes},
+	{Name: "super_admin", Up: legacy(assignSuperAdmin)},
+	{Name: "default_tags_and_policies", Up: legacy(createDefaultTagsAndPolicies)},
+	{Name: "users", Up: legacy(syncUsers)},
+	{Version: 3, Name: "node_tags_and_egress_metrics", Up: updateNodes, Down: keepData, Plan: planNodeTags},
+	{Version: 4, Name: "user_group_policies", ProOnly: true, Up: updateNewAcls, Down: keepData, Plan: planUserGroupPolicies},
+	{Version: 5, Name: "gateways", ProOnly: true, Up: func(context.Context) error { return logic.MigrateToGws() }, Down: revertGateways, Plan: planGateways},
+	{Version: 6, Name: "egress_v1", Up: migrateToEgressV1, Down: revertEgressV1, Plan: planEgressV1},
+	{Version: 7, Name: "virtual_nat", ProOnly: true, Up: updateNetworks, Down: keepData, Plan: planVirtualNAT},
+	{Name: "relays", Up: legacy(resync)},
+	{Version: 8, Name: "remote_access_clients", Up: deleteOldExtclients, Down: keepData, Plan: planRemoteAccessClients},
+	{Version: 9, Name: "deleted_user_group_refs", Up: cleanupDeletedUserGroupRefs, Down: keepData, Plan: planDeletedUserGroupRefs},
+	{Version: 10, Name: "nameservers", Up: migrateNameservers, Down: revertNameservers, Plan: planNameservers},
+	{Name: "roles", Up: legacy(func() { logic.InitialiseRoles() })},
+	{Name: "groups", Up: legacy(func() { logic.IntialiseGroups() })},
+	{Name: "server_defaults", Up: legacy(func() { _ = serverctl.SetDefaults() })},
+}
+
+// keepData - down step of the migrations that fill in fields earlier
+// versions ignore, split policies into equivalent ones or delete stale data.
+// Earlier versions run with the migrated data as is.
+func keepData(context.Context) error {
+	return nil
+}
+
+// legacy - adapts a step run on every start, which logs its errors instead
+// of returning them and is retried on the next start. Numbered migrations
+// return their errors, so that a failed run is not recorded as applied.
+func legacy(fn func()) func(context.Context) error {
+	return func(context.Context) error {
+		fn()
+		return nil
+	}
+}
+
+// Step - a migration applied or reverted by a run
+type Step struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	// Changes - the changes the migration would make, set by dry runs of
+	// migrations with a Plan
+	Changes   []string `json:"changes,omitempty"`
+	Previewed bool     `json:"previewed"`
+}
+
+// Report - outcome of a run of Up or Down
+type Report struct {
+	DryRun bool `json:"dry_run"`
+	Down   bool `json:"down"`
+	// Version - the highest version applied before the run
+	Version int    `json:"version"`
+	Steps   []Step `json:"steps"`
+}
+
+func (r Report) String() string {
+	action := "applied"
+	switch {
+	case r.DryRun && r.Down:
+		action = "to revert"
+	case r.DryRun:
+		action = "to apply"
+	case r.Down:
+		action = "reverted"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "database at version %d, %d migration(s) %s\n", r.Version, len(r.Steps), action)
+	for _, step := range r.Steps {
+		fmt.Fprintf(&b, "  %d %s\n", step.Version, step.Name)
+		if !r.DryRun || r.Down {
+			continue
+		}
+		switch {
+		case !step.Previewed:
+			b.WriteString("      plan unavailable\n")
+		case len(step.Changes) == 0:
+			b.WriteString("      no changes\n")
+		}
+		for _, change := range step.Changes {
+			fmt.Fprintf(&b, "      %s\n", change)
+		}
+	}
+	return b.String()
+}
+
+// Check - returns ErrDatabaseNewer if the database was migrated by a newer
+// version of the server
+func Check(ctx context.Context) error {
+	_, err := appliedMigrations(ctx, migrations)
+	return err
+}
+
+// Up - applies the pending migrations in order along with the ones running
+// on every start. With dryRun nothing is changed, the pending migrations are
+// reported along with the changes they would make.
+func Up(ctx context.Context, dryRun bool) (Report, error) {
+	return up(ctx, migrations, dryRun)
+}
+
+// Down - reverts the applied migrations above version, latest first. Nothing
+// is reverted if any of them can't be. With dryRun the migrations are only
+// reported.
+func Down(ctx context.Context, version int, dryRun bool) (Report, error) {
+	return down(ctx, migrations, version, dryRun)
+}
+
+func up(ctx context.Context, known []Migration, dryRun bool) (Report, error) {
+	applied, err := appliedMigrations(ctx, known)
+	if err != nil {
+		return Report{}, err
+	}
+	report := Report{DryRun: dryRun, Version: currentVersion(applied)}
+	for _, m := range known {
+		if m.Version == 0 {
+			if !dryRun {
+				if err := m.Up(ctx); err != nil {
+					return report, fmt.Errorf("migration %s: %w", m.Name, err)
+				}
+			}
+			continue
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if m.ProOnly && !servercfg.IsPro {
+			continue
+		}
+		step := Step{Version: m.Version, Name: m.Name}
+		if dryRun {
+			if m.Plan != nil {
+				step.Changes, err = m.Plan(ctx)
+				if err != nil {
+					return report, fmt.Errorf("planning migration %d %s: %w", m.Version, m.Name, err)
+				}
+				step.Previewed = true
+			}
+			report.Steps = append(report.Steps, step)
+			continue
+		}
+		logger.Log(1, fmt.Sprintf("running migration %d %s", m.Version, m.Name))
+		if err := m.Up(ctx); errors.Is(err, errPending) {
+			logger.Log(0, fmt.Sprintf("skipping migration %d %s: %v", m.Version, m.Name, err))
+			continue
+		} else if err != nil {
+			return report, fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+		}
+		record := schema.SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}
+		if err := record.Create(ctx); err != nil {
+			return report, err
+		}
+		report.Steps = append(report.Steps, step)
+	}
+	return report, nil
+}
+
+func down(ctx context.Context, known []Migration, version int, dryRun bool) (Report, error) {
+	applied, err := appliedMigrations(ctx, known)
+	if err != nil {
+		return Report{}, err
+	}
+	report := Report{DryRun: dryRun, Down: true, Version: currentVersion(applied)}
+	var revert []Migration
+	for i := len(known) - 1; i >= 0; i-- {
+		m := known[i]
+		if m.Version <= version {
+			continue
+		}
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == nil {
+			return report, fmt.Errorf("%w: %d %s", ErrIrreversible, m.Version, m.Name)
+		}
+		revert = append(revert, m)
+	}
+	for _, m := range revert {
+		if !dryRun {
+			logger.Log(1, fmt.Sprintf("reverting migration %d %s", m.Version, m.Name))
+			if err := m.Down(ctx); err != nil {
+				return report, fmt.Errorf("reverting migration %d %s: %w", m.Version, m.Name, err)
+			}
+			if err := (&schema.SchemaMigration{Version: m.Version}).Delete(ctx); err != nil {
+				return report, err
+			}
+		}
+		report.Steps = append(report.Steps, Step{Version: m.Version, Name: m.Name})
+	}
+	return report, nil
+}
+
+// appliedMigrations - returns the applied migrations by version, fails with
+// ErrDatabaseNewer if any of them is not known
+func appliedMigrations(ctx context.Context, known []Migration) (map[int]schema.SchemaMigration, error) {
+	records, err := (&schema.SchemaMigration{}).ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[int]struct{}, len(known))
+	for _, m := range known {
+		versions[m.Version] = struct{}{}
+	}
+	applied := make(map[int]schema.SchemaMigration, len(records))
+	var unknown []string
+	for _, record := range records {
+		if _, ok := versions[record.Version]; !ok {
+			unknown = append(unknown, fmt.Sprintf("%d %s", record.Version, record.Name))
+		}
+		applied[record.Version] = record
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w, unknown migrations: %s", ErrDatabaseNewer, strings.Join(unknown, ", "))
+	}
+	return applied, nil
+}
+
+// currentVersion - the highest applied version
+func currentVersion(applied map[int]schema.SchemaMigration) int {
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version
+}
@@ -0,0 +1,682 @@
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupMigrationTest - connects to an empty test database. Caching is
+// disabled so that the data of one fixture doesn't leak into the next.
+func setupMigrationTest(t *testing.T) context.Context {
+	t.Setenv("CACHING_ENABLED", "false")
+	require.NoError(t, db.InitializeDB(schema.ListModels()...))
+	t.Cleanup(db.CloseDB)
+	require.NoError(t, database.InitializeDatabase())
+	t.Cleanup(database.CloseDB)
+	return resetTestDB(t)
+}
+
+// resetTestDB - empties the sql and key-value tables
+func resetTestDB(t *testing.T) context.Context {
+	ctx := db.WithContext(context.TODO())
+	require.NoError(t, db.FromContext(ctx).Migrator().DropTable(schema.ListModels()...))
+	require.NoError(t, db.FromContext(ctx).AutoMigrate(schema.ListModels()...))
+	for _, table := range database.Tables {
+		require.NoError(t, database.DeleteAllRecords(table))
+	}
+	return ctx
+}
+
+// loadFixture - inserts the key-value records of testdata/<name>.json, a map
+// of table names to the records of the table by key
+func loadFixture(t *testing.T, name string) {
+	data, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+	require.NoError(t, err)
+	var tables map[string]map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &tables))
+	for table, records := range tables {
+		for key, record := range records {
+			require.NoError(t, database.Insert(key, string(record), table))
+		}
+	}
+}
+
+// egressPolicies - counts the policies to egress resources, read from the
+// db as logic.ListAcls leaves out the user policies on CE
+func egressPolicies(t *testing.T) int {
+	records, err := database.FetchRecords(database.ACLS_TABLE_NAME)
+	require.NoError(t, err)
+	policies := 0
+	for _, record := range records {
+		var acl models.Acl
+		require.NoError(t, json.Unmarshal([]byte(record), &acl))
+		for _, dst := range acl.Dst {
+			if dst.ID == models.EgressID {
+				policies++
+			}
+		}
+	}
+	return policies
+}
+
+func TestMigrationVersions(t *testing.T) {
+	names := make(map[string]struct{})
+	last := 0
+	for _, m := range migrations {
+		assert.NotNil(t, m.Up, m.Name)
+		assert.NotContains(t, names, m.Name)
+		names[m.Name] = struct{}{}
+		if m.Version == 0 {
+			assert.Nil(t, m.Down, m.Name)
+			continue
+		}
+		assert.Greater(t, m.Version, last, "versions must increase, %s", m.Name)
+		last = m.Version
+		assert.NotNil(t, m.Down, "%s can't be reverted", m.Name)
+		assert.NotNil(t, m.Plan, "%s can't be previewed", m.Name)
+	}
+}
+
+func TestUpAndDown(t *testing.T) {
+	ctx := setupMigrationTest(t)
+
+	var calls []string
+	call := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	known := []Migration{
+		{Version: 1, Name: "one", Up: call("up 1"), Down: call("down 1")},
+		{Name: "every_start", Up: call("every start")},
+		{Version: 2, Name: "two", Up: call("up 2"), Down: call("down 2"), Plan: func(context.Context) ([]string, error) {
+			return []string{"change"}, nil
+		}},
+		{Version: 3, Name: "pro", ProOnly: true, Up: call("up 3")},
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		calls = nil
+		report, err := up(ctx, known, true)
+		require.NoError(t, err)
+		assert.Empty(t, calls)
+		assert.Equal(t, 0, report.Version)
+		assert.Equal(t, []Step{
+			{Version: 1, Name: "one"},
+			{Version: 2, Name: "two", Changes: []string{"change"}, Previewed: true},
+		}, report.Steps)
+		assert.Contains(t, report.String(), "  1 one\n      plan unavailable\n")
+	})
+
+	t.Run("Up", func(t *testing.T) {
+		calls = nil
+		report, err := up(ctx, known, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"up 1", "every start", "up 2"}, calls)
+		assert.Len(t, report.Steps, 2)
+
+		calls = nil
+		report, err = up(ctx, known, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"every start"}, calls)
+		assert.Equal(t, 2, report.Version)
+		assert.Empty(t, report.Steps)
+	})
+
+	t.Run("ProOnly", func(t *testing.T) {
+		servercfg.IsPro = true
+		defer func() { servercfg.IsPro = false }()
+		calls = nil
+		report, err := up(ctx, known, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"every start", "up 3"}, calls)
+		assert.Equal(t, []Step{{Version: 3, Name: "pro"}}, report.Steps)
+	})
+
+	t.Run("DatabaseNewer", func(t *testing.T) {
+		calls = nil
+		_, err := up(ctx, known[:3], false)
+		assert.ErrorIs(t, err, ErrDatabaseNewer)
+		_, err = down(ctx, known[:3], 0, false)
+		assert.ErrorIs(t, err, ErrDatabaseNewer)
+		assert.Empty(t, calls)
+	})
+
+	t.Run("Irreversible", func(t *testing.T) {
+		calls = nil
+		_, err := down(ctx, known, 0, false)
+		assert.ErrorIs(t, err, ErrIrreversible)
+		assert.Empty(t, calls)
+		applied, err := appliedMigrations(ctx, known)
+		require.NoError(t, err)
+		assert.Len(t, applied, 3)
+	})
+
+	t.Run("Down", func(t *testing.T) {
+		known[3].Down = call("down 3")
+		calls = nil
+		report, err := down(ctx, known, 1, true)
+		require.NoError(t, err)
+		assert.Empty(t, calls)
+		assert.Equal(t, []Step{{Version: 3, Name: "pro"}, {Version: 2, Name: "two"}}, report.Steps)
+
+		report, err = down(ctx, known, 1, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"down 3", "down 2"}, calls)
+		assert.Equal(t, 3, report.Version)
+		applied, err := appliedMigrations(ctx, known)
+		require.NoError(t, err)
+		assert.Len(t, applied, 1)
+		assert.Contains(t, applied, 1)
+	})
+}
+
+func TestUpFailures(t *testing.T) {
+	ctx := setupMigrationTest(t)
+
+	failures := 1
+	pending := true
+	known := []Migration{
+		{Version: 1, Name: "flaky", Up: func(context.Context) error {
+			if failures > 0 {
+				failures--
+				return errors.New("transient failure")
+			}
+			return nil
+		}},
+		{Version: 2, Name: "waiting", Up: func(context.Context) error {
+			if pending {
+				return fmt.Errorf("%w: no super admin", errPending)
+			}
+			return nil
+		}},
+	}
+
+	// a failed migration is not recorded and runs again on the next start
+	_, err := up(ctx, known, false)
+	assert.ErrorContains(t, err, "transient failure")
+	applied, err := appliedMigrations(ctx, known)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	// a pending migration is skipped without failing the run
+	report, err := up(ctx, known, false)
+	require.NoError(t, err)
+	assert.Equal(t, []Step{{Version: 1, Name: "flaky"}}, report.Steps)
+
+	pending = false
+	report, err = up(ctx, known, false)
+	require.NoError(t, err)
+	assert.Equal(t, []Step{{Version: 2, Name: "waiting"}}, report.Steps)
+}
+
+func TestMigrationsAgainstFixtures(t *testing.T) {
+	ctx := setupMigrationTest(t)
+
+	var versions []int
+	for _, m := range migrations {
+		if m.Version != 0 && !m.ProOnly {
+			versions = append(versions, m.Version)
+		}
+	}
+	appliedVersions := func(report Report) []int {
+		var applied []int
+		for _, step := range report.Steps {
+			applied = append(applied, step.Version)
+		}
+		return applied
+	}
+	// every migration has to be safe to run again, the server may stop
+	// before it was recorded
+	rerun := func(t *testing.T) {
+		for _, m := range migrations {
+			if m.Version != 0 && !m.ProOnly {
+				assert.NoError(t, m.Up(ctx), m.Name)
+			}
+		}
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		ctx := resetTestDB(t)
+		report, err := Up(ctx, true)
+		require.NoError(t, err)
+		assert.Equal(t, versions, appliedVersions(report))
+
+		report, err = Up(ctx, false)
+		require.NoError(t, err)
+		assert.Equal(t, versions, appliedVersions(report))
+		rerun(t)
+
+		report, err = Up(ctx, true)
+		require.NoError(t, err)
+		assert.Empty(t, report.Steps)
+		assert.Equal(t, versions[len(versions)-1], report.Version)
+		assert.NoError(t, Check(ctx))
+	})
+
+	t.Run("v1.4", func(t *testing.T) {
+		ctx := resetTestDB(t)
+		loadFixture(t, "v1.4")
+
+		report, err := Up(ctx, true)
+		require.NoError(t, err)
+		require.Equal(t, versions, appliedVersions(report))
+		assert.Contains(t, report.Steps[0].Changes, "migrate 1 record(s) of users")
+		assert.Contains(t, report.Steps[0].Changes, "migrate 2 record(s) of hosts")
+		assert.Contains(t, report.Steps[1].Changes, "set the type of 1 enrollment key(s)")
+
+		report, err = Up(ctx, false)
+		require.NoError(t, err)
+		assert.Equal(t, versions, appliedVersions(report))
+
+		assertFixtureMigrated := func(t *testing.T) {
+			user := &schema.User{Username: "fixture-admin"}
+			require.NoError(t, user.Get(ctx))
+			assert.Equal(t, schema.SuperAdminRole, user.PlatformRoleID)
+
+			network := &schema.Network{Name: "fixture-net"}
+			require.NoError(t, network.Get(ctx))
+
+			key, err := logic.GetEnrollmentKey("fixturekey")
+			require.NoError(t, err)
+			assert.Equal(t, models.Unlimited, key.Type)
+			keys, err := logic.GetAllEnrollmentKeys()
+			require.NoError(t, err)
+			assert.Len(t, keys, 2, "a default key is created for the network")
+
+			egressNode, err := logic.GetNodeByID("5f1b7c2a-0d3e-4b5a-8c6d-7e8f9a0b1c01")
+			require.NoError(t, err)
+			assert.False(t, egressNode.IsEgressGateway)
+			egresses, err := (&schema.Egress{}).List(ctx)
+			require.NoError(t, err)
+			require.Len(t, egresses, 1)
+			assert.Equal(t, "192.168.50.0/24", egresses[0].Range)
+			assert.True(t, egresses[0].Nat)
+
+			gwNode, err := logic.GetNodeByID("5f1b7c2a-0d3e-4b5a-8c6d-7e8f9a0b1c02")
+			require.NoError(t, err)
+			assert.Empty(t, gwNode.IngressDNS)
+			nameservers, err := (&schema.Nameserver{}).ListAll(ctx)
+			require.NoError(t, err)
+			var servers [][]string
+			for _, ns := range nameservers {
+				servers = append(servers, ns.Servers)
+			}
+			assert.Len(t, nameservers, 2, "fallback and gateway nameservers")
+			assert.Contains(t, servers, []string{"10.10.10.10"})
+		}
+		assertFixtureMigrated(t)
+
+		rerun(t)
+		assertFixtureMigrated(t)
+
+		report, err = Up(ctx, false)
+		require.NoError(t, err)
+		assert.Empty(t, report.Steps)
+
+		report, err = Down(ctx, 0, false)
+		require.NoError(t, err)
+		assert.Len(t, report.Steps, len(versions))
+		applied, err := appliedMigrations(ctx, migrations)
+		require.NoError(t, err)
+		assert.Empty(t, applied)
+
+		users, err := database.FetchRecords(database.USERS_TABLE_NAME)
+		require.NoError(t, err)
+		var admin models.User
+		require.NoError(t, json.Unmarshal([]byte(users["fixture-admin"]), &admin))
+		assert.True(t, admin.IsSuperAdmin)
+		count, err := (&schema.User{}).Count(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+		networks, err := database.FetchRecords(database.NETWORKS_TABLE_NAME)
+		require.NoError(t, err)
+		assert.Contains(t, networks, "fixture-net")
+		hosts, err := database.FetchRecords(database.HOSTS_TABLE_NAME)
+		require.NoError(t, err)
+		assert.Len(t, hosts, 2)
+
+		key, err := logic.GetEnrollmentKey("fixturekey")
+		require.NoError(t, err)
+		assert.Equal(t, models.Undefined, key.Type)
+
+		egressNode, err := logic.GetNodeByID("5f1b7c2a-0d3e-4b5a-8c6d-7e8f9a0b1c01")
+		require.NoError(t, err)
+		assert.True(t, egressNode.IsEgressGateway)
+		assert.Equal(t, []string{"192.168.50.0/24"}, egressNode.EgressGatewayRanges)
+		assert.Equal(t, "yes", egressNode.EgressGatewayRequest.NatEnabled)
+		assert.Zero(t, egressPolicies(t))
+
+		gwNode, err := logic.GetNodeByID("5f1b7c2a-0d3e-4b5a-8c6d-7e8f9a0b1c02")
+		require.NoError(t, err)
+		assert.Equal(t, "10.10.10.10", gwNode.IngressDNS)
+
+		// the reverted database migrates like the original one
+		report, err = Up(ctx, false)
+		require.NoError(t, err)
+		assert.Equal(t, versions, appliedVersions(report))
+		assertFixtureMigrated(t)
+	})
+}
+
+// TestMigrationRoundTrips - runs every migration that changes data against
+// testdata/<migration name>.json on top of testdata/base.json, reverts it and
+// runs it again. The pro migrations are left pending on CE and not covered.
+func TestMigrationRoundTrips(t *testing.T) {
+	setupMigrationTest(t)
+
+	getNode := func(t *testing.T, id string) models.Node {
+		node, err := logic.GetNodeByID(id)
+		require.NoError(t, err)
+		return node
+	}
+	listNameservers := func(t *testing.T, ctx context.Context) []schema.Nameserver {
+		nameservers, err := (&schema.Nameserver{}).ListAll(ctx)
+		require.NoError(t, err)
+		return nameservers
+	}
+	kvRecord := func(t *testing.T, table, key string, record interface{}) {
+		data, err := database.FetchRecord(table, key)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal([]byte(data), record))
+	}
+	keyTypes := func(t *testing.T) map[string]models.KeyType {
+		types := make(map[string]models.KeyType)
+		for _, value := range []string{"unlimitedkey", "useskey", "expiringkey", "typedkey"} {
+			key, err := logic.GetEnrollmentKey(value)
+			require.NoError(t, err)
+			types[value] = key.Type
+		}
+		return types
+	}
+
+	tests := []struct {
+		migration string
+		// plan - the changes planned against the fixture, checked if set
+		plan     []string
+		migrated func(t *testing.T, ctx context.Context)
+		reverted func(t *testing.T, ctx context.Context)
+	}{
+		{
+			migration: "sql_schema",
+			migrated: func(t *testing.T, ctx context.Context) {
+				network := &schema.Network{Name: "fixture-dns"}
+				require.NoError(t, network.Get(ctx))
+				assert.False(t, network.AutoJoin)
+				assert.True(t, network.JITEnabled)
+				nameservers, err := (&schema.Nameserver{NetworkID: "fixture-dns"}).ListByNetwork(ctx)
+				require.NoError(t, err)
+				var upstream []string
+				for _, ns := range nameservers {
+					if ns.Name == upstreamNameserversName {
+						upstream = append(upstream, ns.Servers...)
+					}
+				}
+				assert.Equal(t, []string{"9.9.9.9"}, upstream)
+
+				user := &schema.User{Username: "fixture-user"}
+				require.NoError(t, user.Get(ctx))
+				assert.Equal(t, schema.ServiceUser, user.PlatformRoleID)
+				assert.Equal(t, "Fixture User", user.DisplayName)
+
+				host := &schema.Host{ID: uuid.MustParse("9c3e1a0e-6f4b-4b8e-9f43-8b1c8d1e0b01")}
+				require.NoError(t, host.Get(ctx))
+				assert.Equal(t, "fixture-host", host.Name)
+			},
+			reverted: func(t *testing.T, ctx context.Context) {
+				// the nameservers in the range of the network are
+				// left out by the migration
+				var network models.Network
+				kvRecord(t, database.NETWORKS_TABLE_NAME, "fixture-dns", &network)
+				assert.Equal(t, []string{"9.9.9.9"}, network.NameServers)
+				assert.Equal(t, "false", network.AutoJoin)
+				assert.Equal(t, "yes", network.JITEnabled)
+				assert.Equal(t, "10.101.0.0/24", network.AddressRange)
+				assert.Empty(t, listNameservers(t, ctx))
+
+				var user models.User
+				kvRecord(t, database.USERS_TABLE_NAME, "fixture-user", &user)
+				assert.Equal(t, schema.ServiceUser, user.PlatformRoleID)
+				assert.False(t, user.IsAdmin)
+				assert.Equal(t, "Fixture User", user.DisplayName)
+				count, err := (&schema.User{}).Count(ctx)
+				require.NoError(t, err)
+				assert.Zero(t, count)
+
+				var host models.Host
+				kvRecord(t, database.HOSTS_TABLE_NAME, "9c3e1a0e-6f4b-4b8e-9f43-8b1c8d1e0b01", &host)
+				assert.Equal(t, "fixture-host", host.Name)
+				count, err = (&schema.Host{}).Count(ctx)
+				require.NoError(t, err)
+				assert.Zero(t, count)
+			},
+		},
+		{
+			migration: "enrollment_key_types",
+			migrated: func(t *testing.T, ctx context.Context) {
+				assert.Equal(t, map[string]models.KeyType{
+					"unlimitedkey": models.Unlimited,
+					"useskey":      models.Uses,
+					"expiringkey":  models.TimeExpiration,
+					"typedkey":     models.Uses,
+				}, keyTypes(t))
+				keys, err := logic.GetAllEnrollmentKeys()
+				require.NoError(t, err)
+				assert.Len(t, keys, 5, "a default key is created for the network")
+			},
+			reverted: func(t *testing.T, ctx context.Context) {
+				assert.Equal(t, map[string]models.KeyType{
+					"unlimitedkey": models.Undefined,
+					"useskey":      models.Undefined,
+					"expiringkey":  models.Undefined,
+					"typedkey":     models.Uses,
+				}, keyTypes(t))
+				keys, err := logic.GetAllEnrollmentKeys()
+				require.NoError(t, err)
+				assert.Len(t, keys, 5, "the default key is kept")
+			},
+		},
+		{
+			migration: "node_tags_and_egress_metrics",
+			migrated: func(t *testing.T, ctx context.Context) {
+				assert.NotNil(t, getNode(t, "5f1b7c2a-0d3e-4b5a-8c6d-7e8f9a0b2b01").Tags)
+				extclient, err := logic.GetExtClient("fixture-client", "fixture-net")
+				require.NoError(t, err)
+				assert.NotNil(t, extclient.Tags)
+			},
+			reverted: func(t *testing.T, ctx context.Context) {
+				assert.NotNil(t, getNode(t, "5f1b7c2a-0d3e-4b5a-8c6d-7e8f9a0b2b01").Tags)
+			},
+		},
+		{
+			migration: "egress_v1",
+			migrated: func(t *testing.T, ctx context.Context) {
+				node := getNode(t, "5f1b7c2a-0d3e-4b5a-8c6d-7e8f9a0b2b03")
+				assert.False(t, node.IsEgressGateway)
+				assert.Empty(t, node.EgressGatewayRanges)
+
+				egresses, err := (&schema.Egress{}).List(ctx)
+				require.NoError(t, err)
+				metrics := make(map[string]interface{})
+				for _, e := range egresses {
+					assert.False(t, e.Nat)
+					assert.Equal(t, schema.DisabledNAT, e.Mode)
+					metrics[e.Range] = e.Nodes[node.ID.String()]
+				}
+				assert.Equal(t, map[string]interface{}{
+					"192.168.60.0/24": json.Number("100"),
+					"192.168.61.0/24": json.Number("200"),
+				}, metrics)
+
+				assert.Equal(t, 4, egressPolicies(t), "a device and a user policy per egress")
+			},
+			reverted: func(t *testing.T, ctx context.Context) {
+				node := getNode(t, "5f1b7c2a-0d3e-4b5a-8c6d-7e8f9a0b2b03")
+				assert.True(t, node.IsEgressGateway)
+				assert.False(t, node.EgressGatewayNatEnabled)
+				assert.Equal(t, "no", node.EgressGatewayRequest.NatEnabled)
+				assert.ElementsMatch(t, []string{"192.168.60.0/24", "192.168.61.0/24"}, node.EgressGatewayRanges)
+				assert.ElementsMatch(t, []models.EgressRangeMetric{
+					{Network: "192.168.60.0/24", RouteMetric: 100},
+					{Network: "192.168.61.0/24", RouteMetric: 200},
+				}, node.EgressGatewayRequest.RangesWithMetric)
+
+				egresses, err := (&schema.Egress{}).List(ctx)
+				require.NoError(t, err)
+				assert.Empty(t, egresses)
+				assert.Zero(t, egressPolicies(t))
+			},
+		},
+		{
+			migration: "remote_access_clients",
+			plan:      []string{"delete 1 duplicate disabled remote access client(s)"},
+			migrated: func(t *testing.T, ctx context.Context) {
+				extclients, err := logic.GetAllExtClients()
+				require.NoError(t, err)
+				var disabled, enabled int
+				for _, extclient := range extclients {
+					if extclient.Enabled {
+						enabled++
+					} else {
+						disabled++
+					}
+				}
+				assert.Equal(t, 1, disabled, "duplicate disabled clients are deleted")
+				assert.Equal(t, 1, enabled)
+			},
+		},
+		{
+			migration: "deleted_user_group_refs",
+			migrated: func(t *testing.T, ctx context.Context) {
+				user := &schema.User{Username: "fixture-member"}
+				require.NoError(t, user.Get(ctx))
+				assert.Empty(t, user.UserGroups.Data())
+			},
+		},
+		{
+			migration: "nameservers",
+			migrated: func(t *testing.T, ctx context.Context) {
+				assert.Empty(t, getNode(t, "5f1b7c2a-0d3e-4b5a-8c6d-7e8f9a0b2b04").IngressDNS)
+				assert.Equal(t, "1.1.1.1", getNode(t, "5f1b7c2a-0d3e-4b5a-8c6d-7e8f9a0b2b05").IngressDNS)
+
+				servers := make(map[string][]string)
+				for _, ns := range listNameservers(t, ctx) {
+					servers[ns.Name] = ns.Servers
+				}
+				assert.Contains(t, servers, logic.GooglePublicNameserverName)
+				assert.Equal(t, []string{"10.20.30.40"}, servers["fixture-dns-gw"+gwNameserversSuffix])
+			},
+			reverted: func(t *testing.T, ctx context.Context) {
+				// the public resolvers are left out by the migration
+				assert.Equal(t, "10.20.30.40", getNode(t, "5f1b7c2a-0d3e-4b5a-8c6d-7e8f9a0b2b04").IngressDNS)
+				assert.Equal(t, "1.1.1.1", getNode(t, "5f1b7c2a-0d3e-4b5a-8c6d-7e8f9a0b2b05").IngressDNS)
+				assert.Empty(t, listNameservers(t, ctx))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.migration, func(t *testing.T) {
+			var migration Migration
+			for _, m := range migrations {
+				if m.Name == tt.migration {
+					migration = m
+				}
+			}
+			require.NotZero(t, migration.Version)
+			if tt.reverted == nil {
+				// the data of the migrations that only delete stale
+				// data stays migrated
+				require.NotNil(t, migration.Down)
+				tt.reverted = tt.migrated
+			}
+
+			ctx := resetTestDB(t)
+			loadFixture(t, "base")
+			loadFixture(t, tt.migration)
+
+			if tt.plan != nil {
+				// the plan runs against the data migrated by the
+				// earlier versions
+				var before []Migration
+				for _, m := range migrations {
+					if m.Version == migration.Version {
+						break
+					}
+					before = append(before, m)
+				}
+				_, err := up(ctx, before, false)
+				require.NoError(t, err)
+				changes, err := migration.Plan(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, tt.plan, changes)
+			}
+
+			_, err := Up(ctx, false)
+			require.NoError(t, err)
+			tt.migrated(t, ctx)
+
+			_, err = Down(ctx, migration.Version-1, false)
+			require.NoError(t, err)
+			tt.reverted(t, ctx)
+
+			_, err = Up(ctx, false)
+			require.NoError(t, err)
+			tt.migrated(t, ctx)
+		})
+	}
+}
+
+// TestGateways - the gateways migration is pro only, its Up is set by pro and
+// not run here
+func TestGateways(t *testing.T) {
+	ctx := setupMigrationTest(t)
+	loadFixture(t, "base")
+	loadFixture(t, "gateways")
+	require.NoError(t, migrateToSQLSchema(ctx))
+
+	changes, err := planGateways(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"turn 1 relay, remote access and internet gateway(s) into gateways",
+		"turn off failover on 1 node(s)",
+		"point 1 policy(ies) at the gateways tag",
+		"delete tag fixture-net.remote-access-gws",
+	}, changes)
+
+	// the migration deletes the remote access gateways tag
+	require.NoError(t, database.DeleteRecord(database.TAG_TABLE_NAME, "fixture-net.remote-access-gws"))
+	for range 2 {
+		require.NoError(t, revertGateways(ctx))
+	}
+	gw, err := logic.GetNodeByID("5f1b7c2a-0d3e-4b5a-8c6d-7e8f9a0b2b07")
+	require.NoError(t, err)
+	assert.Equal(t, map[models.TagID]struct{}{
+		"fixture-net.gateways":          {},
+		"fixture-net.remote-access-gws": {},
+	}, gw.Tags)
+	relay, err := logic.GetNodeByID("5f1b7c2a-0d3e-4b5a-8c6d-7e8f9a0b2b06")
+	require.NoError(t, err)
+	assert.Equal(t, map[models.TagID]struct{}{"fixture-net.remote-access-gws": {}}, relay.Tags)
+	var tag models.Tag
+	data, err := database.FetchRecord(database.TAG_TABLE_NAME, "fixture-net.remote-access-gws")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(data), &tag))
+	assert.Equal(t, models.OldRemoteAccessTagName, tag.TagName)
+	assert.Equal(t, schema.NetworkID("fixture-net"), tag.Network)
+}
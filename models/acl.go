@@ -42,6 +42,34 @@ func (p Protocol) String() string {
 	return string(p)
 }
 
+// AclAction - action taken on the traffic matched by a policy
+type AclAction string
+
+const (
+	AclActionAllow AclAction = "allow"
+	AclActionDeny  AclAction = "deny"
+)
+
+const (
+	// DefaultAclPriority - priority of the default network policies,
+	// they are evaluated after all custom policies
+	DefaultAclPriority = 10000
+	// AclPriorityStep - gap between the priorities assigned to new policies
+	AclPriorityStep = 10
+)
+
+// AclSchedule - time window during which a policy is active. Weekdays and the
+// daily start and end times are evaluated in Timezone, From and Until bound the
+// schedule to a one-off window.
+type AclSchedule struct {
+	Timezone  string         `json:"timezone" yaml:"timezone,omitempty"`     // IANA name, defaults to UTC
+	Weekdays  []time.Weekday `json:"weekdays" yaml:"weekdays,omitempty"`     // 0 is Sunday, empty means every day
+	StartTime string         `json:"start_time" yaml:"start_time,omitempty"` // HH:MM
+	EndTime   string         `json:"end_time" yaml:"end_time,omitempty"`     // HH:MM, before StartTime for overnight windows
+	From      *time.Time     `json:"from,omitempty" yaml:"from,omitempty"`
+	Until     *time.Time     `json:"until,omitempty" yaml:"until,omitempty"`
+}
+
 type AclPolicyType string
 
 const (
@@ -50,9 +78,9 @@ const (
 )
 
 type AclPolicyTag struct {
-	ID    AclGroupType `json:"id"`
-	Name  string       `json:"name"`
-	Value string       `json:"value"`
+	ID    AclGroupType `json:"id" yaml:"id"`
+	Name  string       `json:"name" yaml:"name,omitempty"`
+	Value string       `json:"value" yaml:"value"`
 }
 
 type AclGroupType string
@@ -95,6 +123,9 @@ type Acl struct {
 	ServiceType      string                  `json:"type"`
 	Port             []string                `json:"ports"`
 	AllowedDirection AllowedTrafficDirection `json:"allowed_traffic_direction"`
+	Action           AclAction               `json:"action"`
+	Priority         int                     `json:"priority"` // lower value is evaluated first
+	Schedule         *AclSchedule            `json:"schedule,omitempty"`
 	Enabled          bool                    `json:"enabled"`
 	CreatedBy        string                  `json:"created_by"`
 	CreatedAt        time.Time               `json:"created_at"`
@@ -124,4 +155,34 @@ type AclRule struct {
 	Dst             []net.IPNet             `json:"dst"`
 	Dst6            []net.IPNet             `json:"dst6"`
 	Allowed         bool
+	Priority        int `json:"priority"`
+}
+
+// AclSimulationPeer - one side of a simulated packet. Type is one of
+// user, user-group, tag, device, egress-id or ip.
+type AclSimulationPeer struct {
+	Type  AclGroupType `json:"type"`
+	Value string       `json:"value"`
+}
+
+// AclSimulationRequest - hypothetical packet evaluated against the
+// policies of a network, optionally with an unsaved draft policy and at
+// a given time for scheduled policies.
+type AclSimulationRequest struct {
+	NetworkID schema.NetworkID  `json:"network_id"`
+	Src       AclSimulationPeer `json:"src"`
+	Dst       AclSimulationPeer `json:"dst"`
+	Proto     Protocol          `json:"protocol"`
+	Port      string            `json:"port"`
+	DraftAcl  *Acl              `json:"draft_acl,omitempty"`
+	Time      *time.Time        `json:"time,omitempty"`
+}
+
+// AclSimulationResult - verdict of an acl simulation
+type AclSimulationResult struct {
+	Allowed         bool     `json:"allowed"`
+	DefaultPolicy   bool     `json:"default_policy"`
+	DecidingPolicy  string   `json:"deciding_policy"`
+	MatchedPolicies []string `json:"matched_policies"`
+	Reason          string   `json:"reason"`
 }
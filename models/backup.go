@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Backup - full server state. KV holds the records of the key/value tables
+// keyed by table and record key, Models holds the rows of the schema tables
+// keyed by table name. MigrationVersion is the highest migration applied to
+// the database, archives are only restored at the same version.
+type Backup struct {
+	Version          int                          `json:"version"`
+	ServerVersion    string                       `json:"server_version"`
+	Database         string                       `json:"database"`
+	MigrationVersion int                          `json:"migration_version"`
+	CreatedAt        time.Time                    `json:"created_at"`
+	KV               map[string]map[string]string `json:"kv"`
+	Models           map[string]json.RawMessage   `json:"models"`
+}
+
+// BackupSummary - contents of a backup archive
+type BackupSummary struct {
+	Version          int            `json:"version"`
+	ServerVersion    string         `json:"server_version"`
+	Database         string         `json:"database"`
+	MigrationVersion int            `json:"migration_version"`
+	CreatedAt        time.Time      `json:"created_at"`
+	Encrypted        bool           `json:"encrypted"`
+	Records          map[string]int `json:"records"`
+}
@@ -23,3 +23,22 @@ type Event struct {
 	NetworkID   schema.NetworkID
 	Diff        Diff
 }
+
+// EventChainVerification - result of verifying the audit event hash chain.
+// Broken is set to the first link that does not verify.
+type EventChainVerification struct {
+	Valid       bool             `json:"valid"`
+	Checked     int              `json:"checked"`
+	FirstSeq    int64            `json:"first_seq"`
+	LastSeq     int64            `json:"last_seq"`
+	Checkpoints int              `json:"checkpoints"`
+	PublicKey   string           `json:"public_key"`
+	Broken      *EventChainBreak `json:"broken,omitempty"`
+}
+
+// EventChainBreak - first link of the chain that failed verification
+type EventChainBreak struct {
+	Seq     int64  `json:"seq"`
+	EventID string `json:"event_id,omitempty"`
+	Reason  string `json:"reason"`
+}
@@ -156,6 +156,8 @@ type HostUpdate struct {
 	Signal       Signal
 	EgressDomain EgressDomain
 	NewMetrics   Metrics
+	// PeerUpdateVersion - version of the last peer update applied by the host, reported on check-in
+	PeerUpdateVersion uint64
 }
 
 // HostTurnRegister - struct for host turn registration
@@ -30,6 +30,27 @@ type Metric struct {
 	Connected         bool          `json:"connected" bson:"connected" yaml:"connected"`
 }
 
+// MetricsHistory - metric points of a node over a time range, points are
+// downsampled into buckets of Step seconds unless Step is 0
+type MetricsHistory struct {
+	NodeID string               `json:"node_id"`
+	PeerID string               `json:"peer_id,omitempty"`
+	From   time.Time            `json:"from"`
+	To     time.Time            `json:"to"`
+	Step   int64                `json:"step"`
+	Points []schema.MetricPoint `json:"points"`
+}
+
+// MetricsHistoryQuery - selects the metric points of a node, towards a
+// single peer if PeerID is set
+type MetricsHistoryQuery struct {
+	NodeID string
+	PeerID string
+	From   time.Time
+	To     time.Time
+	Step   time.Duration
+}
+
 // IDandAddr - struct to hold ID and primary Address
 type IDandAddr struct {
 	ID          string `json:"id" bson:"id" yaml:"id"`
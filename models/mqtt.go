@@ -26,6 +26,37 @@ type PeerIdentity struct {
 	Name string   `json:"name"`
 }
 
+// PeerUpdateChange - describes what a peer update was triggered by, so that it
+// is only published to the hosts depending on the changed objects. A change
+// that references nothing is global and published to every host.
+type PeerUpdateChange struct {
+	Global   bool
+	Networks []schema.NetworkID
+	Nodes    []string
+	Hosts    []string
+	Tags     []TagID
+	Policies []string
+}
+
+// IsGlobal - checks if the change has to be published to every host
+func (c PeerUpdateChange) IsGlobal() bool {
+	return c.Global || (len(c.Networks) == 0 && len(c.Nodes) == 0 && len(c.Hosts) == 0 &&
+		len(c.Tags) == 0 && len(c.Policies) == 0)
+}
+
+// Merge - adds the objects referenced by other to the change
+func (c *PeerUpdateChange) Merge(other PeerUpdateChange) {
+	if other.IsGlobal() {
+		c.Global = true
+		return
+	}
+	c.Networks = append(c.Networks, other.Networks...)
+	c.Nodes = append(c.Nodes, other.Nodes...)
+	c.Hosts = append(c.Hosts, other.Hosts...)
+	c.Tags = append(c.Tags, other.Tags...)
+	c.Policies = append(c.Policies, other.Policies...)
+}
+
 // HostPeerUpdate - struct for host peer updates
 type HostPeerUpdate struct {
 	Host               schema.Host                 `json:"host"`
@@ -44,6 +75,7 @@ type HostPeerUpdate struct {
 	EgressRoutes       []EgressNetworkRoutes       `json:"egress_network_routes"`
 	FwUpdate           FwUpdate                    `json:"fw_update"`
 	ReplacePeers       bool                        `json:"replace_peers"`
+	Version            uint64                      `json:"version"`
 	NameServers        []string                    `json:"name_servers"`
 	DnsNameservers     []Nameserver                `json:"dns_nameservers"`
 	EgressWithDomains  []EgressDomain              `json:"egress_with_domains"`
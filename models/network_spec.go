@@ -0,0 +1,147 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gravitl/netmaker/schema"
+)
+
+const (
+	// NetworkSpecAPIVersion - version of the declarative network document
+	NetworkSpecAPIVersion = "netmaker/v1"
+	// NetworkSpecKind - kind of the declarative network document
+	NetworkSpecKind = "Network"
+)
+
+// NetworkSpec - declarative description of a network and the resources
+// that belong to it. Resources are identified by name within the network.
+type NetworkSpec struct {
+	APIVersion     string                     `json:"apiVersion" yaml:"apiVersion"`
+	Kind           string                     `json:"kind" yaml:"kind"`
+	Network        NetworkSpecSettings        `json:"network" yaml:"network"`
+	Tags           []NetworkSpecTag           `json:"tags" yaml:"tags,omitempty"`
+	Acls           []NetworkSpecAcl           `json:"acls" yaml:"acls,omitempty"`
+	Egresses       []NetworkSpecEgress        `json:"egresses" yaml:"egresses,omitempty"`
+	Nameservers    []NetworkSpecNameserver    `json:"nameservers" yaml:"nameservers,omitempty"`
+	EnrollmentKeys []NetworkSpecEnrollmentKey `json:"enrollment_keys" yaml:"enrollment_keys,omitempty"`
+	PostureChecks  []NetworkSpecPostureCheck  `json:"posture_checks" yaml:"posture_checks,omitempty"`
+}
+
+// NetworkSpecSettings - network level settings, address ranges can only be
+// set when the network is created
+type NetworkSpecSettings struct {
+	Name                        string   `json:"name" yaml:"name"`
+	AddressRange                string   `json:"address_range" yaml:"address_range,omitempty"`
+	AddressRange6               string   `json:"address_range6" yaml:"address_range6,omitempty"`
+	AutoJoin                    bool     `json:"auto_join" yaml:"auto_join"`
+	AutoRemove                  bool     `json:"auto_remove" yaml:"auto_remove"`
+	AutoRemoveThreshold         int      `json:"auto_remove_threshold" yaml:"auto_remove_threshold,omitempty"` // in minutes
+	AutoRemoveTags              []string `json:"auto_remove_tags" yaml:"auto_remove_tags,omitempty"`
+	VirtualNATPoolIPv4          string   `json:"virtual_nat_pool_ipv4" yaml:"virtual_nat_pool_ipv4,omitempty"`
+	VirtualNATSitePrefixLenIPv4 int      `json:"virtual_nat_site_prefixlen_ipv4" yaml:"virtual_nat_site_prefixlen_ipv4,omitempty"`
+}
+
+// NetworkSpecTag - device tag of the network
+type NetworkSpecTag struct {
+	Name      string `json:"name" yaml:"name"`
+	ColorCode string `json:"color_code" yaml:"color_code,omitempty"`
+}
+
+// NetworkSpecAcl - acl policy of the network. Default policies are matched
+// by name and can be updated but not deleted.
+type NetworkSpecAcl struct {
+	Name             string                  `json:"name" yaml:"name"`
+	RuleType         AclPolicyType           `json:"policy_type" yaml:"policy_type"`
+	Src              []AclPolicyTag          `json:"src_type" yaml:"src"`
+	Dst              []AclPolicyTag          `json:"dst_type" yaml:"dst"`
+	Proto            Protocol                `json:"protocol" yaml:"protocol,omitempty"`
+	ServiceType      string                  `json:"type" yaml:"type,omitempty"`
+	Port             []string                `json:"ports" yaml:"ports,omitempty"`
+	AllowedDirection AllowedTrafficDirection `json:"allowed_traffic_direction" yaml:"allowed_traffic_direction"`
+	Action           AclAction               `json:"action" yaml:"action,omitempty"`
+	Priority         int                     `json:"priority" yaml:"priority,omitempty"`
+	Schedule         *AclSchedule            `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Enabled          bool                    `json:"enabled" yaml:"enabled"`
+}
+
+// NetworkSpecEgress - egress route of the network, nodes and tags map to the route metric
+type NetworkSpecEgress struct {
+	Name         string               `json:"name" yaml:"name"`
+	Description  string               `json:"description" yaml:"description,omitempty"`
+	Range        string               `json:"range" yaml:"range,omitempty"`
+	Domain       string               `json:"domain" yaml:"domain,omitempty"`
+	Nat          bool                 `json:"nat" yaml:"nat"`
+	Mode         schema.EgressNATMode `json:"mode" yaml:"mode,omitempty"`
+	VirtualRange string               `json:"virtual_range" yaml:"virtual_range,omitempty"`
+	Nodes        map[string]int       `json:"nodes" yaml:"nodes,omitempty"`
+	Tags         map[string]int       `json:"tags" yaml:"tags,omitempty"`
+	Enabled      bool                 `json:"enabled" yaml:"enabled"`
+}
+
+// NetworkSpecNameserver - nameserver of the network
+type NetworkSpecNameserver struct {
+	Name        string                        `json:"name" yaml:"name"`
+	Description string                        `json:"description" yaml:"description,omitempty"`
+	Servers     []string                      `json:"servers" yaml:"servers"`
+	MatchAll    bool                          `json:"match_all" yaml:"match_all,omitempty"`
+	Domains     []NetworkSpecNameserverDomain `json:"domains" yaml:"domains,omitempty"`
+	Fallback    bool                          `json:"fallback" yaml:"fallback,omitempty"`
+	Tags        []string                      `json:"tags" yaml:"tags,omitempty"`
+	Nodes       []string                      `json:"nodes" yaml:"nodes,omitempty"`
+	Enabled     bool                          `json:"enabled" yaml:"enabled"`
+}
+
+// NetworkSpecNameserverDomain - domain resolved by a nameserver
+type NetworkSpecNameserverDomain struct {
+	Domain         string `json:"domain" yaml:"domain"`
+	IsSearchDomain bool   `json:"is_search_domain" yaml:"is_search_domain,omitempty"`
+	IsADDomain     bool   `json:"is_ad_domain" yaml:"is_ad_domain,omitempty"`
+}
+
+// NetworkSpecEnrollmentKey - enrollment key scoped to the network, identified
+// by its first tag. Key values are never part of the document. UsesRemaining
+// counts down as hosts join, it only sets the uses of keys the document
+// creates.
+type NetworkSpecEnrollmentKey struct {
+	Name              string     `json:"name" yaml:"name"`
+	Tags              []string   `json:"tags" yaml:"tags,omitempty"`
+	UsesRemaining     int        `json:"uses_remaining" yaml:"uses_remaining,omitempty"`
+	Expiration        *time.Time `json:"expiration,omitempty" yaml:"expiration,omitempty"`
+	Unlimited         bool       `json:"unlimited" yaml:"unlimited,omitempty"`
+	Groups            []TagID    `json:"groups" yaml:"groups,omitempty"`
+	Relay             string     `json:"relay" yaml:"relay,omitempty"`
+	AutoEgress        bool       `json:"auto_egress" yaml:"auto_egress,omitempty"`
+	AutoAssignGateway bool       `json:"auto_assign_gw" yaml:"auto_assign_gw,omitempty"`
+}
+
+// NetworkSpecPostureCheck - posture check of the network
+type NetworkSpecPostureCheck struct {
+	Name        string           `json:"name" yaml:"name"`
+	Description string           `json:"description" yaml:"description,omitempty"`
+	Attribute   schema.Attribute `json:"attribute" yaml:"attribute"`
+	Values      []string         `json:"values" yaml:"values"`
+	Severity    schema.Severity  `json:"severity" yaml:"severity"`
+	Tags        []string         `json:"tags" yaml:"tags,omitempty"`
+	UserGroups  []string         `json:"user_groups" yaml:"user_groups,omitempty"`
+	Enabled     bool             `json:"enabled" yaml:"enabled"`
+}
+
+// NetworkSpecChange - planned change of a single resource. Replace is set
+// when an enrollment key has to be recreated to apply the change.
+type NetworkSpecChange struct {
+	Resource schema.SubjectType `json:"resource"`
+	Name     string             `json:"name"`
+	Action   schema.Action      `json:"action"`
+	Fields   []string           `json:"fields,omitempty"`
+	Replace  bool               `json:"replace,omitempty"`
+	Old      any                `json:"old,omitempty"`
+	New      any                `json:"new,omitempty"`
+}
+
+// NetworkSpecPlan - changes needed to bring a network to the declared state
+type NetworkSpecPlan struct {
+	Network string              `json:"network"`
+	DryRun  bool                `json:"dry_run"`
+	Applied bool                `json:"applied"`
+	Changes []NetworkSpecChange `json:"changes"`
+}
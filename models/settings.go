@@ -54,8 +54,60 @@ type ServerSettings struct {
 	PostureCheckInterval           string `json:"posture_check_interval"` // in minutes
 	CleanUpInterval                int    `json:"clean_up_interval_in_mins"`
 	EnableFlowLogs                 bool   `json:"enable_flow_logs"`
+	// AuditExportEnabled streams audit events to AuditExportTarget using
+	// AuditExportFormat over AuditExportTransport.
+	AuditExportEnabled   bool                 `json:"audit_export_enabled"`
+	AuditExportFormat    AuditExportFormat    `json:"audit_export_format"`
+	AuditExportTransport AuditExportTransport `json:"audit_export_transport"`
+	// AuditExportTarget is a host:port for socket transports and a path for the file transport.
+	AuditExportTarget string `json:"audit_export_target"`
+	// LDAP* configure the directory of the ldap auth provider, it is used
+	// to sync users and groups and to verify passwords on login.
+	LDAPURL string `json:"ldap_url"`
+	// LDAPStartTLS upgrades ldap:// connections to TLS.
+	LDAPStartTLS             bool   `json:"ldap_start_tls"`
+	LDAPSkipTLSVerify        bool   `json:"ldap_skip_tls_verify"`
+	LDAPBindDN               string `json:"ldap_bind_dn"`
+	LDAPBindPassword         string `json:"ldap_bind_password"`
+	LDAPBaseDN               string `json:"ldap_base_dn"`
+	LDAPUserFilter           string `json:"ldap_user_filter"`
+	LDAPGroupFilter          string `json:"ldap_group_filter"`
+	LDAPUsernameAttribute    string `json:"ldap_username_attribute"`
+	LDAPGroupMemberAttribute string `json:"ldap_group_member_attribute"`
+	// SAMLIDPMetadataURL or SAMLIDPMetadata (xml) describe the IDP of the
+	// saml auth provider, the metadata url takes precedence.
+	SAMLIDPMetadataURL string `json:"saml_idp_metadata_url"`
+	SAMLIDPMetadata    string `json:"saml_idp_metadata"`
+	// SAMLUsernameAttribute is the assertion attribute holding the
+	// username, the NameID is used when it is empty.
+	SAMLUsernameAttribute string `json:"saml_username_attribute"`
+	// SAMLGroupsAttribute is the assertion attribute holding the groups of
+	// the user, memberships are updated on every login when it is set.
+	SAMLGroupsAttribute string `json:"saml_groups_attribute"`
+	// MetricsRetentionPeriodInDays is how long the history of node metrics
+	// is kept, older samples are downsampled before they are dropped.
+	MetricsRetentionPeriodInDays int `json:"metrics_retention_period"`
 }
 
+// AuditExportFormat - encoding of exported audit events
+type AuditExportFormat string
+
+const (
+	AuditExportSyslog AuditExportFormat = "syslog"
+	AuditExportCEF    AuditExportFormat = "cef"
+	AuditExportJSON   AuditExportFormat = "json"
+)
+
+// AuditExportTransport - destination type of exported audit events
+type AuditExportTransport string
+
+const (
+	AuditExportUDP  AuditExportTransport = "udp"
+	AuditExportTCP  AuditExportTransport = "tcp"
+	AuditExportTLS  AuditExportTransport = "tls"
+	AuditExportFile AuditExportTransport = "file"
+)
+
 type UserSettings struct {
 	Theme         Theme  `json:"theme"`
 	TextSize      string `json:"text_size"`
@@ -89,13 +89,15 @@ type SuccessfulUserLoginResponse struct {
 
 // PartialUserLoginResponse represents the response returned to the client
 // after successful username and password authentication, but before the
-// completion of TOTP authentication.
+// completion of TOTP or WebAuthn authentication.
 //
 // This response includes a temporary token required to complete
 // the authentication process.
 type PartialUserLoginResponse struct {
 	UserName     string `json:"user_name"`
 	PreAuthToken string `json:"pre_auth_token"`
+	// MFAMethods - second factors the sign in can be completed with
+	MFAMethods []MFAMethod `json:"mfa_methods"`
 }
 
 type TOTPInitiateResponse struct {
@@ -294,6 +296,7 @@ type HostPull struct {
 	AutoRelayNodes     map[schema.NetworkID][]Node `json:"auto_relay_nodes"`
 	GwNodes            map[schema.NetworkID][]Node `json:"gw_nodes"`
 	ReplacePeers       bool                        `json:"replace_peers"`
+	Version            uint64                      `json:"version"`
 	AddressIdentityMap map[string]PeerIdentity     `json:"address_identity_map"`
 }
 
@@ -361,10 +364,11 @@ type HookFunc func(...interface{}) error
 
 // HookDetails - struct to hold hook info
 type HookDetails struct {
-	ID       string        // Unique identifier for the hook (optional, auto-generated if empty)
-	Hook     HookFunc      // Hook function that accepts optional variadic parameters
-	Params   []interface{} // Optional parameters to pass to the hook function
-	Interval time.Duration
+	ID         string        // Unique identifier for the hook (optional, auto-generated if empty)
+	Hook       HookFunc      // Hook function that accepts optional variadic parameters
+	Params     []interface{} // Optional parameters to pass to the hook function
+	Interval   time.Duration
+	LeaderOnly bool // Run the hook only on the replica elected as leader
 }
 
 // HookCommandType - type of command for hook management
@@ -469,6 +473,17 @@ type IDPSyncTestRequest struct {
 	GoogleSACredsJson string `json:"google_sa_creds_json"`
 	OktaOrgURL        string `json:"okta_org_url"`
 	OktaAPIToken      string `json:"okta_api_token"`
+	// LDAP* - see the matching fields of ServerSettings
+	LDAPURL                  string `json:"ldap_url"`
+	LDAPStartTLS             bool   `json:"ldap_start_tls"`
+	LDAPSkipTLSVerify        bool   `json:"ldap_skip_tls_verify"`
+	LDAPBindDN               string `json:"ldap_bind_dn"`
+	LDAPBindPassword         string `json:"ldap_bind_password"`
+	LDAPBaseDN               string `json:"ldap_base_dn"`
+	LDAPUserFilter           string `json:"ldap_user_filter"`
+	LDAPGroupFilter          string `json:"ldap_group_filter"`
+	LDAPUsernameAttribute    string `json:"ldap_username_attribute"`
+	LDAPGroupMemberAttribute string `json:"ldap_group_member_attribute"`
 }
 
 type PostureCheckDeviceInfo struct {
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -100,6 +101,30 @@ type UserTOTPVerificationParams struct {
 	TOTP                string `json:"totp"`
 }
 
+// MFAMethod - second factor a user can sign in with
+type MFAMethod string
+
+const (
+	MFAMethodTOTP     MFAMethod = "totp"
+	MFAMethodWebAuthn MFAMethod = "webauthn"
+)
+
+// WebAuthnBeginResponse - options passed to navigator.credentials.create or
+// navigator.credentials.get, the session id is sent back to finish the
+// ceremony
+type WebAuthnBeginResponse struct {
+	SessionID string `json:"session_id"`
+	Options   any    `json:"options"`
+}
+
+// WebAuthnFinishRequest - response of the authenticator to a ceremony
+type WebAuthnFinishRequest struct {
+	SessionID string `json:"session_id"`
+	// Name - label of a credential being registered
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential" swaggertype:"object"`
+}
+
 // UserClaims - user claims struct
 type UserClaims struct {
 	Role           schema.UserRoleID
@@ -111,6 +111,11 @@ func UpdateHost(client mqtt.Client, msg mqtt.Message) {
 	switch hostUpdate.Action {
 	case models.CheckIn:
 		sendPeerUpdate = HandleHostCheckin(&hostUpdate.Host, currentHost)
+		if !sendPeerUpdate {
+			if err := PublishHostPeerUpdateIfBehind(currentHost, hostUpdate.PeerUpdateVersion); err != nil {
+				slog.Error("failed to resend peer update", "id", currentHost.ID, "error", err)
+			}
+		}
 	case models.Acknowledgement:
 		nodes, err := logic.GetAllNodes()
 		if err != nil {
@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gravitl/netmaker/leader"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/servercfg"
@@ -25,6 +28,12 @@ var peer_force_send = 0
 
 var mqclient mqtt.Client
 
+var (
+	leaderChangeOnce    sync.Once
+	clientTopicsMutex   sync.Mutex
+	serverSyncConnected atomic.Bool
+)
+
 func setMqOptions(user, password string, opts *mqtt.ClientOptions) {
 	broker, _ := servercfg.GetMessageQueueEndpoint()
 	opts.AddBroker(broker)
@@ -42,6 +51,9 @@ func setMqOptions(user, password string, opts *mqtt.ClientOptions) {
 
 // SetupMQTT creates a connection to broker and return client
 func SetupMQTT(fatal bool) {
+	leaderChangeOnce.Do(func() {
+		leader.OnChange(onLeaderChange)
+	})
 	if servercfg.GetBrokerType() == servercfg.EmqxBrokerType {
 		if emqx.GetType() == servercfg.EmqxOnPremDeploy {
 			time.Sleep(10 * time.Second) // wait for the REST endpoint to be ready
@@ -73,31 +85,23 @@ func SetupMQTT(fatal bool) {
 	setMqOptions(servercfg.GetMqUserName(), servercfg.GetMqPassword(), opts)
 	logger.Log(0, "Mq Client Connecting with Random ID: ", opts.ClientID)
 	opts.SetOnConnectHandler(func(client mqtt.Client) {
-		// Only master pod subscribes to incoming client messages in HA setup
-		// This prevents duplicate message processing across multiple pods
-		// Worker pods can still publish messages but won't process incoming ones
+		// Only the leader subscribes to incoming client messages in HA setup
+		// This prevents duplicate message processing across multiple replicas
+		// Followers can still publish messages but won't process incoming ones
 		serverName := servercfg.GetServer()
-		if servercfg.IsMasterPod() {
-			if token := client.Subscribe(fmt.Sprintf("update/%s/#", serverName), 0, mqtt.MessageHandler(UpdateNode)); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
-				logger.Log(0, "node update subscription failed")
-			}
-			if token := client.Subscribe(fmt.Sprintf("host/serverupdate/%s/#", serverName), 0, mqtt.MessageHandler(UpdateHost)); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
-				logger.Log(0, "host update subscription failed")
-			}
-			if token := client.Subscribe(fmt.Sprintf("signal/%s/#", serverName), 0, mqtt.MessageHandler(ClientPeerUpdate)); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
-				logger.Log(0, "node client subscription failed")
-			}
-			if token := client.Subscribe(fmt.Sprintf("metrics/%s/#", serverName), 0, mqtt.MessageHandler(UpdateMetrics)); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
-				logger.Log(0, "node metrics subscription failed")
-			}
-			logger.Log(0, "MQ subscriptions established (master pod)")
+		if leader.IsLeader() {
+			subscribeClientTopics(client)
 		} else {
-			logger.Log(0, "MQ publish-only mode (worker pod)")
+			logger.Log(0, "MQ publish-only mode (follower)")
 		}
 		if servercfg.IsHA() {
-			if token := client.Subscribe(fmt.Sprintf("serversync/%s", serverName), 0, mqtt.MessageHandler(handleServerSync)); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
+			if token := client.Subscribe(fmt.Sprintf("serversync/%s", serverName), 1, mqtt.MessageHandler(handleServerSync)); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
 				logger.Log(0, "server sync subscription failed")
 			}
+			// invalidations sent while disconnected are lost
+			if !serverSyncConnected.CompareAndSwap(false, true) {
+				reloadCaches("reconnected to broker")
+			}
 		}
 
 		opts.SetOrderMatters(false)
@@ -137,6 +141,55 @@ const CHECKIN_FLUSH_INTERVAL = 30
 
 // normalizedMetricsExportInterval applies the same minimum as before (invalid/too-small
 // intervals use a 10-minute default).
+// clientTopics - topics of messages sent by clients, processed by the leader only
+func clientTopics() map[string]mqtt.MessageHandler {
+	serverName := servercfg.GetServer()
+	return map[string]mqtt.MessageHandler{
+		fmt.Sprintf("update/%s/#", serverName):            UpdateNode,
+		fmt.Sprintf("host/serverupdate/%s/#", serverName): UpdateHost,
+		fmt.Sprintf("signal/%s/#", serverName):            ClientPeerUpdate,
+		fmt.Sprintf("metrics/%s/#", serverName):           UpdateMetrics,
+	}
+}
+
+func subscribeClientTopics(client mqtt.Client) {
+	for topic, handler := range clientTopics() {
+		if token := client.Subscribe(topic, 0, handler); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
+			logger.Log(0, "subscription failed", topic, token.Error().Error())
+		}
+	}
+	logger.Log(0, "MQ subscriptions established (leader)")
+}
+
+func unsubscribeClientTopics(client mqtt.Client) {
+	topics := make([]string, 0, 4)
+	for topic := range clientTopics() {
+		topics = append(topics, topic)
+	}
+	if token := client.Unsubscribe(topics...); token.WaitTimeout(MQ_TIMEOUT*time.Second) && token.Error() != nil {
+		logger.Log(0, "failed to unsubscribe client topics", token.Error().Error())
+	}
+	logger.Log(0, "MQ publish-only mode (follower)")
+}
+
+// onLeaderChange - moves processing of client messages along with leadership
+func onLeaderChange(bool) {
+	if mqclient == nil || !mqclient.IsConnectionOpen() {
+		// subscriptions are set up by the connect handler
+		return
+	}
+	go func() {
+		// apply the latest leadership, changes may overtake each other
+		clientTopicsMutex.Lock()
+		defer clientTopicsMutex.Unlock()
+		if leader.IsLeader() {
+			subscribeClientTopics(mqclient)
+		} else {
+			unsubscribeClientTopics(mqclient)
+		}
+	}()
+}
+
 func normalizedMetricsExportInterval() time.Duration {
 	d := logic.GetMetricIntervalInMinutes()
 	if d < time.Minute {
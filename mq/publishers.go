@@ -15,17 +15,22 @@ import (
 	"github.com/google/uuid"
 	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/leader"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/schema"
 	"github.com/gravitl/netmaker/servercfg"
+	"github.com/gravitl/netmaker/servermetrics"
 	"golang.org/x/exp/slog"
 )
 
 var (
 	peerUpdateSignal  = make(chan struct{}, 1)
 	peerUpdateReplace atomic.Bool
+
+	peerUpdateChangeMu sync.Mutex
+	peerUpdateChange   *models.PeerUpdateChange
 )
 
 const (
@@ -38,13 +43,16 @@ var metricsHTTPClient = &http.Client{Timeout: 30 * time.Second}
 
 // PublishPeerUpdate --- queues a peer update that will be coalesced with other
 // rapid-fire updates via a debounce window (500ms) capped by a max-wait (3s).
-func PublishPeerUpdate(replacePeers bool) error {
-	if !servercfg.IsMessageQueueBackend() {
+// The changes describe what triggered the update so that it is only published
+// to the affected hosts, without any the update is published to every host.
+func PublishPeerUpdate(replacePeers bool, changes ...models.PeerUpdateChange) error {
+	if !pushEnabled() {
 		return nil
 	}
 	if replacePeers {
 		peerUpdateReplace.Store(true)
 	}
+	queuePeerUpdateChange(changes)
 	select {
 	case peerUpdateSignal <- struct{}{}:
 	default:
@@ -52,6 +60,34 @@ func PublishPeerUpdate(replacePeers bool) error {
 	return nil
 }
 
+func queuePeerUpdateChange(changes []models.PeerUpdateChange) {
+	peerUpdateChangeMu.Lock()
+	defer peerUpdateChangeMu.Unlock()
+	if peerUpdateChange == nil {
+		peerUpdateChange = &models.PeerUpdateChange{}
+	}
+	if len(changes) == 0 {
+		peerUpdateChange.Global = true
+		return
+	}
+	for _, change := range changes {
+		peerUpdateChange.Merge(change)
+	}
+}
+
+// takePeerUpdateChange --- returns the changes queued since the last publish,
+// ok is false if they were already published
+func takePeerUpdateChange() (change models.PeerUpdateChange, ok bool) {
+	peerUpdateChangeMu.Lock()
+	defer peerUpdateChangeMu.Unlock()
+	if peerUpdateChange == nil {
+		return change, false
+	}
+	change = *peerUpdateChange
+	peerUpdateChange = nil
+	return change, true
+}
+
 // StartPeerUpdateWorker --- runs a background goroutine that coalesces peer
 // update signals using a resettable debounce timer capped by an absolute
 // max-wait deadline. This ensures rapid-fire PublishPeerUpdate calls result
@@ -79,7 +115,6 @@ func StartPeerUpdateWorker(ctx context.Context) {
 						debounce = time.After(peerUpdateDebounce)
 					}
 				}
-				replacePeers := peerUpdateReplace.Swap(false)
 			drain:
 				for {
 					select {
@@ -88,11 +123,19 @@ func StartPeerUpdateWorker(ctx context.Context) {
 						break drain
 					}
 				}
+				change, ok := takePeerUpdateChange()
+				if !ok {
+					continue
+				}
+				replacePeers := peerUpdateReplace.Swap(false)
 				logic.RefreshHostPeerInfoCache()
-				if err := publishPeerUpdateImmediate(replacePeers); err != nil {
+				start := time.Now()
+				err := publishPeerUpdateImmediate(replacePeers, change)
+				servermetrics.PeerUpdatePublishDuration.ObserveSince(start, servermetrics.Result(err))
+				if err != nil {
 					slog.Error("error publishing peer update", "error", err)
 				} else {
-					publishServerSync(logic.SyncTypePeerUpdate)
+					logic.InvalidateCache(logic.CacheKindHostPeerUpdate, "")
 				}
 			}
 		}
@@ -113,18 +156,23 @@ func warmPeerCaches() {
 			slog.Error("warmPeerCaches: failed to compute peer update", "host", hosts[i].ID, "error", err)
 			continue
 		}
+		// version the update so that hosts checking in with an older one get
+		// it resent, but keep it unpublished for the first broadcast
+		logic.VersionHostPeerUpdate(hosts[i].ID.String(), &peerUpdate)
+		logic.ResetHostPeerUpdateVersion(hosts[i].ID.String())
 		logic.StoreHostPeerUpdate(hosts[i].ID.String(), peerUpdate)
 	}
 	slog.Info("peer update caches warmed", "hosts", len(hosts))
 }
 
-// publishPeerUpdateImmediate --- determines and publishes a peer update to all the hosts
-func publishPeerUpdateImmediate(replacePeers bool) error {
-	if !servercfg.IsMessageQueueBackend() {
+// publishPeerUpdateImmediate --- determines and publishes a peer update to the
+// hosts affected by the change
+func publishPeerUpdateImmediate(replacePeers bool, change models.PeerUpdateChange) error {
+	if !pushEnabled() {
 		return nil
 	}
 
-	if logic.GetManageDNS() {
+	if logic.GetManageDNS() && servercfg.IsMessageQueueBackend() {
 		sendDNSSync()
 	}
 
@@ -137,6 +185,10 @@ func publishPeerUpdateImmediate(replacePeers bool) error {
 	if err != nil {
 		return err
 	}
+	hosts = peerUpdateTargets(hosts, allNodes, change)
+	if !servercfg.IsMessageQueueBackend() {
+		hosts = streamingHosts(hosts)
+	}
 
 	sem := make(chan struct{}, maxConcurrentPublishes)
 	var wg sync.WaitGroup
@@ -160,10 +212,25 @@ func publishPeerUpdateImmediate(replacePeers bool) error {
 	return nil
 }
 
+// peerUpdateTargets --- filters the hosts down to the ones affected by the change
+func peerUpdateTargets(hosts []schema.Host, allNodes []models.Node, change models.PeerUpdateChange) []schema.Host {
+	affected, all := logic.NewPeerUpdateIndex(allNodes).AffectedHosts(change)
+	if all {
+		return hosts
+	}
+	targets := make([]schema.Host, 0, len(affected))
+	for _, host := range hosts {
+		if _, ok := affected[host.ID.String()]; ok {
+			targets = append(targets, host)
+		}
+	}
+	return targets
+}
+
 // PublishDeletedNodePeerUpdate --- determines and publishes a peer update
 // to all the hosts with a deleted node to account for
 func PublishDeletedNodePeerUpdate(delNode *models.Node) error {
-	if !servercfg.IsMessageQueueBackend() {
+	if !pushEnabled() {
 		return nil
 	}
 
@@ -188,7 +255,7 @@ func PublishDeletedNodePeerUpdate(delNode *models.Node) error {
 // PublishDeletedClientPeerUpdate --- determines and publishes a peer update
 // to all the hosts with a deleted ext client to account for
 func PublishDeletedClientPeerUpdate(delClient *models.ExtClient) error {
-	if !servercfg.IsMessageQueueBackend() {
+	if !pushEnabled() {
 		return nil
 	}
 
@@ -217,10 +284,30 @@ func PublishSingleHostPeerUpdate(host *schema.Host, allNodes []models.Node, dele
 	if wg != nil {
 		defer wg.Done()
 	}
-	peerUpdate, err := logic.GetPeerUpdateForHost("", host, allNodes, deletedNode, deletedClients)
+	peerUpdate, err := buildHostPeerUpdate(host, allNodes, deletedNode, deletedClients, replacePeers)
 	if err != nil {
 		return err
 	}
+	changed := logic.VersionHostPeerUpdate(host.ID.String(), &peerUpdate)
+	if deletedNode == nil && len(deletedClients) == 0 {
+		logic.StoreHostPeerUpdate(host.ID.String(), peerUpdate)
+		if !changed && !peerUpdate.ReplacePeers {
+			// the host already has the retained identical update
+			return nil
+		}
+	}
+	if err := publishHostPeerUpdate(host, &peerUpdate); err != nil {
+		logic.ResetHostPeerUpdateVersion(host.ID.String())
+		return err
+	}
+	return nil
+}
+
+func buildHostPeerUpdate(host *schema.Host, allNodes []models.Node, deletedNode *models.Node, deletedClients []models.ExtClient, replacePeers bool) (models.HostPeerUpdate, error) {
+	peerUpdate, err := logic.GetPeerUpdateForHost("", host, allNodes, deletedNode, deletedClients)
+	if err != nil {
+		return peerUpdate, err
+	}
 
 	for _, nodeID := range host.Nodes {
 
@@ -235,10 +322,26 @@ func PublishSingleHostPeerUpdate(host *schema.Host, allNodes []models.Node, dele
 		EndpointDetection: peerUpdate.ServerConfig.EndpointDetection,
 	}
 	peerUpdate.ReplacePeers = replacePeers
-	if deletedNode == nil && len(deletedClients) == 0 {
-		logic.StoreHostPeerUpdate(host.ID.String(), peerUpdate)
+	return peerUpdate, nil
+}
+
+// PublishHostPeerUpdateIfBehind --- resends the last peer update published to
+// the host if the version it reported to have applied is older
+func PublishHostPeerUpdateIfBehind(host *schema.Host, appliedVersion uint64) error {
+	if appliedVersion == 0 {
+		// host does not report versions
+		return nil
 	}
-	data, err := json.Marshal(&peerUpdate)
+	peerUpdate, ok := logic.GetCachedHostPeerUpdate(host.ID.String())
+	if !ok || peerUpdate.Version <= appliedVersion {
+		return nil
+	}
+	slog.Debug("host is behind on peer updates, resending", "host", host.ID, "applied", appliedVersion, "version", peerUpdate.Version)
+	return publishHostPeerUpdate(host, &peerUpdate)
+}
+
+func publishHostPeerUpdate(host *schema.Host, peerUpdate *models.HostPeerUpdate) error {
+	data, err := json.Marshal(peerUpdate)
 	if err != nil {
 		return err
 	}
@@ -251,7 +354,7 @@ func NodeUpdate(node *models.Node) error {
 	if err := host.Get(db.WithContext(context.TODO())); err != nil {
 		return nil
 	}
-	if !servercfg.IsMessageQueueBackend() {
+	if !pushEnabled() {
 		return nil
 	}
 	logger.Log(3, "publishing node update to "+node.ID.String())
@@ -275,7 +378,7 @@ func NodeUpdate(node *models.Node) error {
 
 // HostUpdate -- publishes a host update to clients
 func HostUpdate(hostUpdate *models.HostUpdate) error {
-	if !servercfg.IsMessageQueueBackend() {
+	if !pushEnabled() {
 		return nil
 	}
 	logger.Log(3, "publishing host update to "+hostUpdate.Host.ID.String())
@@ -395,8 +498,8 @@ func sendPeers() {
 	if peer_force_send == 5 {
 		servercfg.SetHost()
 		peer_force_send = 0
-		// Only run timer checkpoint on master pod in HA setup
-		if servercfg.IsMasterPod() {
+		// Only run timer checkpoint on the leader in HA setup
+		if leader.IsLeader() {
 			err := logic.TimerCheckpoint() // run telemetry & log dumps if 24 hours has passed..
 			if err != nil {
 				logger.Log(3, "error occurred on timer,", err.Error())
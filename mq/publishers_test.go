@@ -0,0 +1,79 @@
+package mq
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueuePeerUpdateChange(t *testing.T) {
+	_, ok := takePeerUpdateChange()
+	assert.False(t, ok)
+
+	queuePeerUpdateChange([]models.PeerUpdateChange{{Networks: []schema.NetworkID{"net1"}}})
+	queuePeerUpdateChange([]models.PeerUpdateChange{{Nodes: []string{"node-1"}}})
+	change, ok := takePeerUpdateChange()
+	assert.True(t, ok)
+	assert.False(t, change.IsGlobal())
+	assert.Equal(t, []schema.NetworkID{"net1"}, change.Networks)
+	assert.Equal(t, []string{"node-1"}, change.Nodes)
+
+	// a signal without changes is published to every host
+	queuePeerUpdateChange([]models.PeerUpdateChange{{Networks: []schema.NetworkID{"net1"}}})
+	queuePeerUpdateChange(nil)
+	change, ok = takePeerUpdateChange()
+	assert.True(t, ok)
+	assert.True(t, change.IsGlobal())
+
+	_, ok = takePeerUpdateChange()
+	assert.False(t, ok)
+}
+
+func TestPublishHostPeerUpdateIfBehindAfterWarming(t *testing.T) {
+	t.Setenv("MESSAGEQUEUE_BACKEND", "off")
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	require.NoError(t, database.InitializeDatabase())
+	logic.InvalidateHostPeerCaches()
+	defer logic.InvalidateHostPeerCaches()
+
+	trafficKey := make([]byte, 32)
+	_, err := rand.Read(trafficKey)
+	require.NoError(t, err)
+	host := schema.Host{
+		ID:               uuid.New(),
+		Version:          "v1.0.0",
+		TrafficKeyPublic: trafficKey,
+	}
+	require.NoError(t, host.Create(db.WithContext(context.TODO())))
+	defer host.Delete(db.WithContext(context.TODO()))
+
+	warmPeerCaches()
+	peerUpdate, ok := logic.GetCachedHostPeerUpdate(host.ID.String())
+	require.True(t, ok)
+	require.NotZero(t, peerUpdate.Version)
+
+	messages, unsubscribe := SubscribeHostStream(host.ID.String())
+	defer unsubscribe()
+	// a host on the warmed version is up to date
+	require.NoError(t, PublishHostPeerUpdateIfBehind(&host, peerUpdate.Version))
+	assert.Empty(t, messages)
+	// a host on an older version gets the warmed update resent
+	require.NoError(t, PublishHostPeerUpdateIfBehind(&host, peerUpdate.Version-1))
+	assert.Len(t, messages, 1)
+
+	// the warmed update is not taken as published, the first broadcast
+	// still goes out under a newer version
+	broadcast := peerUpdate
+	assert.True(t, logic.VersionHostPeerUpdate(host.ID.String(), &broadcast))
+	assert.Greater(t, broadcast.Version, peerUpdate.Version)
+}
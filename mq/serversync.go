@@ -3,41 +3,156 @@ package mq
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/leader"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/servercfg"
 	"golang.org/x/exp/slog"
 )
 
+const (
+	serverSyncQueueSize = 4096
+	serverSyncBatchSize = 256
+	// serverSyncGapTimeout - how long a message overtaken by a later one is
+	// waited for before it is considered lost
+	serverSyncGapTimeout = 3 * time.Second
+)
+
+// serverSyncMessage - message exchanged between replicas in HA mode. Every
+// sender numbers its messages, receivers that detect a gap in the sequence
+// reload all caches.
 type serverSyncMessage struct {
-	Sender   string               `json:"sender"`
-	SyncType logic.ServerSyncType `json:"sync_type"`
+	Sender string `json:"sender"`
+	// Epoch - identifies a run of the sender, sequence numbers restart at 1
+	Epoch         string                    `json:"epoch"`
+	Seq           uint64                    `json:"seq"`
+	SyncType      logic.ServerSyncType      `json:"sync_type,omitempty"`
+	Invalidations []logic.CacheInvalidation `json:"invalidations,omitempty"`
+}
+
+// serverSyncStream - state of the messages received from one sender
+type serverSyncStream struct {
+	epoch   string
+	next    uint64
+	pending map[uint64]serverSyncMessage
+	timer   *time.Timer
 }
 
-// InitServerSync wires up the logic.PublishServerSync hook so that
-// mutations in the logic package can broadcast sync signals
-// without importing mq (avoiding circular imports).
+var (
+	serverSyncOnce    sync.Once
+	serverSyncEpoch   = uuid.New().String()
+	serverSyncSeq     atomic.Uint64
+	serverSyncQueue   = make(chan serverSyncMessage, serverSyncQueueSize)
+	serverSyncMutex   sync.Mutex
+	serverSyncStreams = make(map[string]*serverSyncStream)
+	// cacheReloadMutex - guards cacheReloadRunning and cacheReloadPending
+	cacheReloadMutex   sync.Mutex
+	cacheReloadRunning bool
+	cacheReloadPending bool
+)
+
+// InitServerSync wires up the logic.PublishServerSync and
+// logic.PublishCacheInvalidation hooks so that mutations in the logic
+// package can broadcast to the other replicas without importing mq
+// (avoiding circular imports).
 func InitServerSync() {
-	logic.PublishServerSync = publishServerSync
+	serverSyncOnce.Do(func() {
+		logic.RegisterCache(logic.CacheKindHostPeerUpdate, logic.CacheHandler{
+			Reload: func() {
+				logic.InvalidateHostPeerCaches()
+				go warmPeerCaches()
+			},
+		})
+		if !servercfg.IsHA() {
+			return
+		}
+		logic.PublishServerSync = publishServerSync
+		logic.PublishCacheInvalidation = publishCacheInvalidation
+		go runServerSyncPublisher()
+	})
 }
 
 func publishServerSync(syncType logic.ServerSyncType) {
+	queueServerSync(serverSyncMessage{SyncType: syncType})
+}
+
+func publishCacheInvalidation(inv logic.CacheInvalidation) {
+	queueServerSync(serverSyncMessage{Invalidations: []logic.CacheInvalidation{inv}})
+}
+
+func queueServerSync(msg serverSyncMessage) {
+	select {
+	case serverSyncQueue <- msg:
+	default:
+		// skip a sequence number, receivers notice the gap and reload
+		serverSyncSeq.Add(1)
+		slog.Warn("serversync: queue full, dropping message")
+	}
+}
+
+// runServerSyncPublisher - publishes queued messages in order, invalidations
+// waiting in the queue are sent in one message
+func runServerSyncPublisher() {
+	for msg := range serverSyncQueue {
+		for msg.SyncType == "" && len(msg.Invalidations) < serverSyncBatchSize {
+			var next serverSyncMessage
+			select {
+			case next = <-serverSyncQueue:
+			default:
+			}
+			if next.SyncType == "" && len(next.Invalidations) == 0 {
+				break
+			}
+			if next.SyncType != "" {
+				publishServerSyncMessage(msg)
+				msg = next
+				break
+			}
+			msg.Invalidations = append(msg.Invalidations, next.Invalidations...)
+		}
+		publishServerSyncMessage(msg)
+	}
+}
+
+func uniqueInvalidations(invs []logic.CacheInvalidation) []logic.CacheInvalidation {
+	if len(invs) < 2 {
+		return invs
+	}
+	seen := make(map[logic.CacheInvalidation]struct{}, len(invs))
+	unique := invs[:0]
+	for _, inv := range invs {
+		if _, ok := seen[inv]; ok {
+			continue
+		}
+		seen[inv] = struct{}{}
+		unique = append(unique, inv)
+	}
+	return unique
+}
+
+func publishServerSyncMessage(msg serverSyncMessage) {
+	// the sequence number is used up even if publishing fails, so receivers
+	// notice the lost message
+	msg.Seq = serverSyncSeq.Add(1)
+	msg.Sender = servercfg.GetHostName()
+	msg.Epoch = serverSyncEpoch
+	msg.Invalidations = uniqueInvalidations(msg.Invalidations)
 	if mqclient == nil || !mqclient.IsConnectionOpen() {
 		return
 	}
-	msg := serverSyncMessage{
-		Sender:   servercfg.GetHostName(),
-		SyncType: syncType,
-	}
 	data, err := json.Marshal(msg)
 	if err != nil {
 		slog.Error("serversync: failed to marshal message", "error", err)
 		return
 	}
 	topic := fmt.Sprintf("serversync/%s", servercfg.GetServer())
-	token := mqclient.Publish(topic, 0, false, data)
+	token := mqclient.Publish(topic, 1, false, data)
 	if !token.WaitTimeout(MQ_TIMEOUT * time.Second) {
 		slog.Warn("serversync: publish timed out", "topic", topic)
 	} else if token.Error() != nil {
@@ -54,25 +169,127 @@ func handleServerSync(_ mqtt.Client, msg mqtt.Message) {
 	if syncMsg.Sender == servercfg.GetHostName() {
 		return
 	}
-	slog.Info("serversync: received sync", "from", syncMsg.Sender, "type", syncMsg.SyncType)
+	receiveServerSync(syncMsg)
+}
 
-	switch syncMsg.SyncType {
-	case logic.SyncTypeSettings:
-		oldInterval := logic.GetMetricInterval()
-		logic.InvalidateServerSettingsCache()
-		if logic.GetMetricInterval() != oldInterval {
-			logic.NotifyMetricExportIntervalChanged()
+// receiveServerSync - applies the messages of a sender in sequence order.
+// Messages may be handled concurrently, so a message that arrives early is
+// held back until the messages before it arrived or are considered lost.
+func receiveServerSync(msg serverSyncMessage) {
+	serverSyncMutex.Lock()
+	defer serverSyncMutex.Unlock()
+	stream, ok := serverSyncStreams[msg.Sender]
+	if !ok || stream.epoch != msg.Epoch {
+		if ok && stream.timer != nil {
+			stream.timer.Stop()
+		}
+		// a restarted sender starts over at 1, messages of a sender not seen
+		// before were sent before this replica loaded its caches
+		if ok && msg.Seq != 1 {
+			reloadCaches(fmt.Sprintf("missed messages from restarted replica %s", msg.Sender))
 		}
-	case logic.SyncTypePeerUpdate:
-		logic.InvalidateHostPeerCaches()
-		go warmPeerCaches()
+		stream = &serverSyncStream{epoch: msg.Epoch, next: msg.Seq, pending: make(map[uint64]serverSyncMessage)}
+		serverSyncStreams[msg.Sender] = stream
+	}
+	if msg.Seq < stream.next {
+		// redelivered
+		return
+	}
+	stream.pending[msg.Seq] = msg
+	applyPendingServerSync(stream)
+	if len(stream.pending) > 0 && stream.timer == nil {
+		sender, epoch := msg.Sender, msg.Epoch
+		stream.timer = time.AfterFunc(serverSyncGapTimeout, func() {
+			skipServerSyncGap(sender, epoch)
+		})
+	}
+}
+
+// applyPendingServerSync - applies the held back messages that are next in sequence
+func applyPendingServerSync(stream *serverSyncStream) {
+	for {
+		msg, ok := stream.pending[stream.next]
+		if !ok {
+			break
+		}
+		delete(stream.pending, stream.next)
+		stream.next++
+		applyServerSync(msg)
+	}
+	if len(stream.pending) == 0 && stream.timer != nil {
+		stream.timer.Stop()
+		stream.timer = nil
+	}
+}
+
+// skipServerSyncGap - gives up on the missing messages of a sender, the held
+// back messages are applied and all caches are reloaded
+func skipServerSyncGap(sender, epoch string) {
+	serverSyncMutex.Lock()
+	defer serverSyncMutex.Unlock()
+	stream, ok := serverSyncStreams[sender]
+	if !ok || stream.epoch != epoch {
+		return
+	}
+	stream.timer = nil
+	if len(stream.pending) == 0 {
+		return
+	}
+	seqs := make([]uint64, 0, len(stream.pending))
+	for seq := range stream.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	reloadCaches(fmt.Sprintf("missed messages %d-%d from replica %s", stream.next, seqs[0]-1, sender))
+	stream.next = seqs[0]
+	applyPendingServerSync(stream)
+	if len(stream.pending) > 0 {
+		stream.timer = time.AfterFunc(serverSyncGapTimeout, func() {
+			skipServerSyncGap(sender, epoch)
+		})
+	}
+}
+
+func applyServerSync(msg serverSyncMessage) {
+	slog.Debug("serversync: received sync", "from", msg.Sender, "seq", msg.Seq, "type", msg.SyncType, "invalidations", len(msg.Invalidations))
+	for _, inv := range msg.Invalidations {
+		logic.ApplyCacheInvalidation(inv)
+	}
+	switch msg.SyncType {
 	case logic.SyncTypeIDPReset:
-		if servercfg.IsMasterPod() {
+		if leader.IsLeader() {
 			logic.ResetIDPSyncHook()
 		}
 	case logic.SyncTypeIDPSync:
-		if servercfg.IsMasterPod() {
+		if leader.IsLeader() {
 			logic.SyncFromIDP()
 		}
 	}
 }
+
+// reloadCaches - reloads all caches in the background. A reload requested
+// while one is running is done once the running one finished, as it may
+// have read the database before the missed changes were made.
+func reloadCaches(reason string) {
+	slog.Warn("serversync: reloading all caches", "reason", reason)
+	cacheReloadMutex.Lock()
+	defer cacheReloadMutex.Unlock()
+	if cacheReloadRunning {
+		cacheReloadPending = true
+		return
+	}
+	cacheReloadRunning = true
+	go func() {
+		for {
+			logic.ReloadCaches()
+			cacheReloadMutex.Lock()
+			if !cacheReloadPending {
+				cacheReloadRunning = false
+				cacheReloadMutex.Unlock()
+				return
+			}
+			cacheReloadPending = false
+			cacheReloadMutex.Unlock()
+		}
+	}()
+}
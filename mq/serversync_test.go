@@ -0,0 +1,141 @@
+package mq
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/logic"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCacheKind logic.CacheKind = "test"
+
+type testCache struct {
+	mu          sync.Mutex
+	invalidated []string
+	reloaded    chan struct{}
+}
+
+func (c *testCache) ids() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.invalidated...)
+}
+
+var cache = func() *testCache {
+	c := &testCache{reloaded: make(chan struct{}, 16)}
+	logic.RegisterCache(testCacheKind, logic.CacheHandler{
+		Invalidate: func(id string) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.invalidated = append(c.invalidated, id)
+		},
+		Reload: func() { c.reloaded <- struct{}{} },
+	})
+	return c
+}()
+
+func testSyncMessage(sender, epoch string, seq uint64, id string) serverSyncMessage {
+	return serverSyncMessage{
+		Sender:        sender,
+		Epoch:         epoch,
+		Seq:           seq,
+		Invalidations: []logic.CacheInvalidation{{Kind: testCacheKind, ID: id}},
+	}
+}
+
+func resetTestCache(t *testing.T) {
+	cache.mu.Lock()
+	cache.invalidated = nil
+	cache.mu.Unlock()
+	t.Cleanup(func() {
+		serverSyncMutex.Lock()
+		defer serverSyncMutex.Unlock()
+		for sender, stream := range serverSyncStreams {
+			if stream.timer != nil {
+				stream.timer.Stop()
+			}
+			delete(serverSyncStreams, sender)
+		}
+	})
+}
+
+func assertReloaded(t *testing.T) {
+	t.Helper()
+	select {
+	case <-cache.reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("caches were not reloaded")
+	}
+}
+
+func assertNotReloaded(t *testing.T) {
+	t.Helper()
+	select {
+	case <-cache.reloaded:
+		t.Fatal("caches were reloaded")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestReceiveServerSyncOrder(t *testing.T) {
+	resetTestCache(t)
+	receiveServerSync(testSyncMessage("a", "e1", 1, "1"))
+	receiveServerSync(testSyncMessage("a", "e1", 3, "3"))
+	assert.Equal(t, []string{"1"}, cache.ids())
+
+	receiveServerSync(testSyncMessage("a", "e1", 2, "2"))
+	assert.Equal(t, []string{"1", "2", "3"}, cache.ids())
+
+	// redelivered messages are applied once
+	receiveServerSync(testSyncMessage("a", "e1", 2, "2"))
+	assert.Equal(t, []string{"1", "2", "3"}, cache.ids())
+	assertNotReloaded(t)
+}
+
+func TestReceiveServerSyncGap(t *testing.T) {
+	resetTestCache(t)
+	receiveServerSync(testSyncMessage("b", "e1", 1, "1"))
+	receiveServerSync(testSyncMessage("b", "e1", 3, "3"))
+	receiveServerSync(testSyncMessage("b", "e1", 4, "4"))
+	assert.Equal(t, []string{"1"}, cache.ids())
+
+	// message 2 never arrives
+	skipServerSyncGap("b", "e1")
+	assertReloaded(t)
+	assert.Equal(t, []string{"1", "3", "4"}, cache.ids())
+
+	receiveServerSync(testSyncMessage("b", "e1", 2, "2"))
+	assert.Equal(t, []string{"1", "3", "4"}, cache.ids())
+}
+
+func TestReceiveServerSyncRestart(t *testing.T) {
+	resetTestCache(t)
+	receiveServerSync(testSyncMessage("c", "e1", 5, "5"))
+	assertNotReloaded(t)
+
+	// the restarted sender's first message was lost
+	receiveServerSync(testSyncMessage("c", "e2", 2, "2"))
+	assertReloaded(t)
+	assert.Equal(t, []string{"5", "2"}, cache.ids())
+
+	// a restart without lost messages needs no reload
+	receiveServerSync(testSyncMessage("c", "e3", 1, "1"))
+	assertNotReloaded(t)
+	assert.Equal(t, []string{"5", "2", "1"}, cache.ids())
+}
+
+func TestUniqueInvalidations(t *testing.T) {
+	invs := []logic.CacheInvalidation{
+		{Kind: logic.CacheKindNode, ID: "a"},
+		{Kind: logic.CacheKindNode, ID: "b"},
+		{Kind: logic.CacheKindNode, ID: "a"},
+		{Kind: logic.CacheKindAcl, ID: "a"},
+	}
+	assert.Equal(t, []logic.CacheInvalidation{
+		{Kind: logic.CacheKindNode, ID: "a"},
+		{Kind: logic.CacheKindNode, ID: "b"},
+		{Kind: logic.CacheKindAcl, ID: "a"},
+	}, uniqueInvalidations(invs))
+}
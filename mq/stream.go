@@ -0,0 +1,135 @@
+package mq
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+// hostStreamBuffer - messages queued per stream before it is considered too
+// slow and closed, the host then reconnects and resumes from its version
+const hostStreamBuffer = 32
+
+// HostStreamMessage - encrypted message published to a host over its HTTP
+// stream when running without a broker, Topic is the MQTT topic it would
+// have been published to
+type HostStreamMessage struct {
+	Topic   string
+	Payload []byte
+}
+
+var (
+	hostStreams   map[string]map[chan HostStreamMessage]struct{}
+	hostStreamsMu sync.RWMutex
+)
+
+// SubscribeHostStream - registers an HTTP stream of the host, messages
+// published to the host are delivered until the returned func is called or
+// the channel is closed because the stream fell behind
+func SubscribeHostStream(hostID string) (<-chan HostStreamMessage, func()) {
+	messages := make(chan HostStreamMessage, hostStreamBuffer)
+	hostStreamsMu.Lock()
+	if hostStreams == nil {
+		hostStreams = make(map[string]map[chan HostStreamMessage]struct{})
+	}
+	if hostStreams[hostID] == nil {
+		hostStreams[hostID] = make(map[chan HostStreamMessage]struct{})
+	}
+	hostStreams[hostID][messages] = struct{}{}
+	hostStreamsMu.Unlock()
+	return messages, func() {
+		hostStreamsMu.Lock()
+		defer hostStreamsMu.Unlock()
+		removeHostStream(hostID, messages)
+	}
+}
+
+// removeHostStream - unregisters and closes the stream, the caller must hold hostStreamsMu
+func removeHostStream(hostID string, messages chan HostStreamMessage) {
+	if _, ok := hostStreams[hostID][messages]; !ok {
+		return
+	}
+	delete(hostStreams[hostID], messages)
+	if len(hostStreams[hostID]) == 0 {
+		delete(hostStreams, hostID)
+	}
+	close(messages)
+}
+
+// hostStreamsOpen - checks if any host is streaming updates
+func hostStreamsOpen() bool {
+	hostStreamsMu.RLock()
+	defer hostStreamsMu.RUnlock()
+	return len(hostStreams) > 0
+}
+
+// streamingHosts - filters the hosts down to the ones with an open stream
+func streamingHosts(hosts []schema.Host) []schema.Host {
+	hostStreamsMu.RLock()
+	defer hostStreamsMu.RUnlock()
+	streaming := make([]schema.Host, 0, len(hostStreams))
+	for _, host := range hosts {
+		if len(hostStreams[host.ID.String()]) > 0 {
+			streaming = append(streaming, host)
+		}
+	}
+	return streaming
+}
+
+// pushEnabled - checks if updates can be pushed to hosts, either through the
+// broker or over the HTTP streams of hosts
+func pushEnabled() bool {
+	return servercfg.IsMessageQueueBackend() || hostStreamsOpen()
+}
+
+// publishToHostStreams - delivers the message to the open streams of the
+// host, hosts without a stream catch up when they connect
+func publishToHostStreams(hostID, topic string, payload []byte) {
+	hostStreamsMu.Lock()
+	defer hostStreamsMu.Unlock()
+	for messages := range hostStreams[hostID] {
+		select {
+		case messages <- HostStreamMessage{Topic: topic, Payload: payload}:
+		default:
+			slog.Warn("host stream fell behind, closing it", "host", hostID)
+			removeHostStream(hostID, messages)
+		}
+	}
+}
+
+// ResumeHostStream - publishes the current peer update to a host that just
+// opened its stream, unless the host already applied that version. It is
+// computed again as peer updates of hosts without a stream are not kept up
+// to date.
+func ResumeHostStream(host *schema.Host, appliedVersion uint64) error {
+	allNodes, err := logic.GetAllNodes()
+	if err != nil {
+		return err
+	}
+	peerUpdate, err := buildHostPeerUpdate(host, allNodes, nil, nil, false)
+	if err != nil {
+		return err
+	}
+	logic.VersionHostPeerUpdate(host.ID.String(), &peerUpdate)
+	logic.StoreHostPeerUpdate(host.ID.String(), peerUpdate)
+	if peerUpdate.Version <= appliedVersion {
+		return nil
+	}
+	if err := publishHostPeerUpdate(host, &peerUpdate); err != nil {
+		logic.ResetHostPeerUpdateVersion(host.ID.String())
+		return err
+	}
+	return nil
+}
+
+// StartHostStreaming - starts publishing peer updates to the HTTP streams of
+// hosts when running without a broker. Streams are served by the replica the
+// host connected to, so HA setups still need the broker.
+func StartHostStreaming(ctx context.Context) {
+	warmPeerCaches()
+	StartPeerUpdateWorker(ctx)
+}
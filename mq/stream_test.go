@@ -0,0 +1,46 @@
+package mq
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostStreams(t *testing.T) {
+	hostID := uuid.New()
+	other := uuid.New()
+	assert.False(t, hostStreamsOpen())
+
+	messages, unsubscribe := SubscribeHostStream(hostID.String())
+	assert.True(t, hostStreamsOpen())
+	assert.Equal(t, []schema.Host{{ID: hostID}}, streamingHosts([]schema.Host{{ID: hostID}, {ID: other}}))
+
+	publishToHostStreams(hostID.String(), "peers/host/1/server", []byte("update"))
+	publishToHostStreams(other.String(), "peers/host/2/server", []byte("not for this host"))
+	assert.Equal(t, HostStreamMessage{Topic: "peers/host/1/server", Payload: []byte("update")}, <-messages)
+	assert.Empty(t, messages)
+
+	unsubscribe()
+	_, ok := <-messages
+	assert.False(t, ok)
+	assert.False(t, hostStreamsOpen())
+	// unsubscribing again is a no-op
+	unsubscribe()
+}
+
+func TestHostStreamFallsBehind(t *testing.T) {
+	hostID := uuid.NewString()
+	messages, unsubscribe := SubscribeHostStream(hostID)
+	defer unsubscribe()
+	for i := 0; i <= hostStreamBuffer; i++ {
+		publishToHostStreams(hostID, "host/update", []byte("update"))
+	}
+	received := 0
+	for range messages {
+		received++
+	}
+	assert.Equal(t, hostStreamBuffer, received)
+	assert.False(t, hostStreamsOpen())
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/netclient/ncutils"
 	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
 	"github.com/klauspost/compress/gzip"
 	"golang.org/x/exp/slog"
 )
@@ -188,6 +189,10 @@ func publish(host *schema.Host, dest string, msg []byte) error {
 			return encryptErr
 		}
 	}
+	if !servercfg.IsMessageQueueBackend() {
+		publishToHostStreams(host.ID.String(), dest, encrypted)
+		return nil
+	}
 
 	for attempt := 0; attempt < 2; attempt++ {
 		if mqclient == nil || !mqclient.IsConnectionOpen() {
@@ -29,6 +29,8 @@ const (
 	github_provider_name   = "github"
 	okta_provider_name     = "okta"
 	oidc_provider_name     = "oidc"
+	ldap_provider_name     = "ldap"
+	saml_provider_name     = "saml"
 	verify_user            = "verifyuser"
 	user_signin_length     = 16
 	node_signin_length     = 64
@@ -90,6 +92,8 @@ func getCurrentAuthFunctions() map[string]interface{} {
 		return okta_functions
 	case oidc_provider_name:
 		return oidc_functions
+	case saml_provider_name:
+		return saml_functions
 	default:
 		return nil
 	}
@@ -102,6 +106,9 @@ func ResetAuthProvider() {
 	if settings.AuthProvider == "" {
 		auth_provider = nil
 	}
+	if settings.AuthProvider != saml_provider_name {
+		saml_service_provider = nil
+	}
 
 	InitializeAuthProvider()
 }
@@ -282,11 +289,25 @@ func getStateAndCode(r *http.Request) (string, string) {
 	} else if r.URL.Query().Get("state") != "" && r.URL.Query().Get("code") != "" {
 		state = r.URL.Query().Get("state")
 		code = r.URL.Query().Get("code")
+	} else if r.FormValue("RelayState") != "" && r.FormValue("SAMLResponse") != "" {
+		// saml idps post the response along with the state as RelayState
+		state = r.FormValue("RelayState")
+		code = r.FormValue("SAMLResponse")
 	}
 
 	return state, code
 }
 
+// getAuthCodeURL - returns the url of the IDP to sign in at, the state is
+// passed back to the callback.
+func getAuthCodeURL(state string) (string, error) {
+	if saml_service_provider != nil {
+		return getSAMLAuthURL(state)
+	}
+
+	return auth_provider.AuthCodeURL(state), nil
+}
+
 func getUserEmailFromClaims(token string) string {
 	accessToken, _ := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
 		return []byte(""), nil
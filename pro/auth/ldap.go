@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/pro/idp/ldap"
+	"github.com/gravitl/netmaker/schema"
+	"gorm.io/datatypes"
+)
+
+// IsLDAPLoginEnabled - users sign in with their directory password when the
+// ldap auth provider is configured
+func IsLDAPLoginEnabled() bool {
+	settings := logic.GetServerSettings()
+	return settings.AuthProvider == ldap_provider_name && settings.LDAPURL != ""
+}
+
+// VerifyLDAPCredentials - binds as the user to verify the password. Users
+// that have not been synced yet are created, with the memberships of the
+// groups already synced from the directory, unless the user or group
+// filters exclude them.
+func VerifyLDAPCredentials(username, password string) (*schema.User, error) {
+	client := ldap.NewLDAPClientFromSettings()
+	idpUser, err := client.Authenticate(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if idpUser.AccountDisabled {
+		return nil, errors.New("user account disabled")
+	}
+
+	user := &schema.User{Username: idpUser.Username}
+	err = user.Get(db.WithContext(context.TODO()))
+	if err == nil {
+		if user.AuthType != schema.OAuth {
+			// basic auth users are not taken over by the directory.
+			return nil, errors.New("user is not managed by the directory")
+		}
+
+		if user.ExternalIdentityProviderID != idpUser.ID {
+			user.ExternalIdentityProviderID = idpUser.ID
+			err = logic.UpsertUser(*user)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return user, nil
+	}
+
+	settings := logic.GetServerSettings()
+	if len(settings.UserFilters) > 0 && !hasAnyPrefix(idpUser.Username, settings.UserFilters) {
+		return nil, errors.New("user is filtered out")
+	}
+
+	idpGroups, err := client.GetGroups(settings.GroupFilters)
+	if err != nil {
+		return nil, err
+	}
+
+	memberOf := make(map[string]struct{})
+	for _, group := range idpGroups {
+		for _, member := range group.Members {
+			if member == idpUser.ID {
+				memberOf[group.ID] = struct{}{}
+			}
+		}
+	}
+
+	if len(settings.GroupFilters) > 0 && len(memberOf) == 0 {
+		return nil, errors.New("user is filtered out")
+	}
+
+	dbGroups, err := (&schema.UserGroup{}).ListAll(db.WithContext(context.TODO()))
+	if err != nil {
+		return nil, err
+	}
+
+	userGroups := make(map[schema.UserGroupID]struct{})
+	for _, group := range dbGroups {
+		if _, ok := memberOf[group.ExternalIdentityProviderID]; ok && group.ExternalIdentityProviderID != "" {
+			userGroups[group.ID] = struct{}{}
+		}
+	}
+
+	oauthPassword, err := logic.FetchPassValue("")
+	if err != nil {
+		return nil, err
+	}
+
+	err = logic.CreateUser(&schema.User{
+		Username:                   idpUser.Username,
+		ExternalIdentityProviderID: idpUser.ID,
+		DisplayName:                idpUser.DisplayName,
+		Password:                   oauthPassword,
+		AuthType:                   schema.OAuth,
+		PlatformRoleID:             schema.ServiceUser,
+		UserGroups:                 datatypes.NewJSONType(userGroups),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_ = logic.DeletePendingUser(idpUser.Username)
+
+	user = &schema.User{Username: idpUser.Username}
+	err = user.Get(db.WithContext(context.TODO()))
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func hasAnyPrefix(value string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
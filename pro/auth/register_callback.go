@@ -154,5 +154,13 @@ func RegisterHostSSO(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.Redirect(w, r, auth_provider.AuthCodeURL(machineKeyStr), http.StatusSeeOther)
+	url, err := getAuthCodeURL(machineKeyStr)
+	if err != nil {
+		logger.Log(0, "error when creating the sign-in url:", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("invalid login attempt"))
+		return
+	}
+
+	http.Redirect(w, r, url, http.StatusSeeOther)
 }
@@ -0,0 +1,548 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	proLogic "github.com/gravitl/netmaker/pro/logic"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+	dsig "github.com/russellhaering/goxmldsig"
+	"golang.org/x/oauth2"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	SAML_TIMEOUT = 10 * time.Second
+	// samlMetadataMaxSize - upper bound of the idp metadata document
+	samlMetadataMaxSize = 1 << 20
+)
+
+var saml_functions = map[string]interface{}{
+	init_provider:   initSAML,
+	get_user_info:   getSAMLUserInfo,
+	handle_callback: handleSAMLCallback,
+	handle_login:    handleSAMLLogin,
+}
+
+var saml_service_provider *saml.ServiceProvider
+
+// == handle SAML authentication here ==
+
+// initSAML - sets up the service provider, the assertion consumer service is
+// the oauth callback so that host and headless sign-ins work unchanged.
+func initSAML(redirectURL string, _ string, _ string) {
+	sp, err := newSAMLServiceProvider(redirectURL, logic.GetServerSettings())
+	if err != nil {
+		logger.Log(0, "error when initializing SAML provider:", err.Error())
+		saml_service_provider = nil
+		auth_provider = nil
+		return
+	}
+
+	saml_service_provider = sp
+	// the other providers are represented by their oauth2 config, it is
+	// only used to tell whether sso is configured.
+	auth_provider = &oauth2.Config{
+		RedirectURL: sp.AcsURL.String(),
+		Endpoint: oauth2.Endpoint{
+			AuthURL: sp.GetSSOBindingLocation(saml.HTTPRedirectBinding),
+		},
+	}
+}
+
+func newSAMLServiceProvider(redirectURL string, settings models.ServerSettings) (*saml.ServiceProvider, error) {
+	idpMetadata, err := loadSAMLIDPMetadata(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	key, cert, err := proLogic.GetSAMLServiceProviderKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load service provider key pair: %w", err)
+	}
+
+	acsURL, err := url.Parse(redirectURL)
+	if err != nil {
+		return nil, err
+	}
+	metadataURL, err := url.Parse(strings.TrimSuffix(redirectURL, "/callback") + "/saml/metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	sp := &saml.ServiceProvider{
+		EntityID:    metadataURL.String(),
+		Key:         key,
+		Certificate: cert,
+		MetadataURL: *metadataURL,
+		AcsURL:      *acsURL,
+		IDPMetadata: idpMetadata,
+		// let the idp pick the name id format, transient ids change on
+		// every login.
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+		SignatureMethod:   dsig.RSASHA256SignatureMethod,
+	}
+	if sp.GetSSOBindingLocation(saml.HTTPRedirectBinding) == "" {
+		return nil, errors.New("idp does not support the HTTP-Redirect binding")
+	}
+
+	return sp, nil
+}
+
+func loadSAMLIDPMetadata(settings models.ServerSettings) (*saml.EntityDescriptor, error) {
+	data := []byte(settings.SAMLIDPMetadata)
+	if settings.SAMLIDPMetadataURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), SAML_TIMEOUT)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, settings.SAMLIDPMetadataURL, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch idp metadata: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch idp metadata: %s", resp.Status)
+		}
+
+		data, err = io.ReadAll(io.LimitReader(resp.Body, samlMetadataMaxSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch idp metadata: %w", err)
+		}
+	}
+
+	return parseSAMLMetadata(data)
+}
+
+// parseSAMLMetadata - parses the metadata of an idp, which some idps
+// publish within an EntitiesDescriptor.
+func parseSAMLMetadata(data []byte) (*saml.EntityDescriptor, error) {
+	entity := &saml.EntityDescriptor{}
+	if err := xml.Unmarshal(data, entity); err == nil {
+		if len(entity.IDPSSODescriptors) == 0 {
+			return nil, errors.New("saml metadata does not describe an idp")
+		}
+		return entity, nil
+	}
+
+	entities := &saml.EntitiesDescriptor{}
+	if err := xml.Unmarshal(data, entities); err != nil {
+		return nil, fmt.Errorf("invalid saml metadata: %w", err)
+	}
+	for i := range entities.EntityDescriptors {
+		if len(entities.EntityDescriptors[i].IDPSSODescriptors) > 0 {
+			return &entities.EntityDescriptors[i], nil
+		}
+	}
+
+	return nil, errors.New("saml metadata does not describe an idp")
+}
+
+// samlRequestID - id of the AuthnRequest sent for a state. It is derived
+// from the state instead of being stored, the idp echoes it in the
+// InResponseTo of its response and the state comes back as RelayState.
+func samlRequestID(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return "id-" + hex.EncodeToString(sum[:])
+}
+
+// getSAMLAuthURL - returns the url of the signed AuthnRequest for a state
+func getSAMLAuthURL(state string) (string, error) {
+	sp := saml_service_provider
+	if sp == nil {
+		return "", errors.New("saml is not configured")
+	}
+
+	req, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", err
+	}
+	req.ID = samlRequestID(state)
+
+	// the states are alphanumeric, they need no escaping as RelayState.
+	redirect, err := req.Redirect(state, sp)
+	if err != nil {
+		return "", err
+	}
+
+	return redirect.String(), nil
+}
+
+func handleSAMLLogin(w http.ResponseWriter, r *http.Request) {
+	appName := r.Header.Get("X-Application-Name")
+	if appName == "" {
+		appName = logic.NetmakerDesktopApp
+	}
+
+	var oauth_state_string = logic.RandomString(user_signin_length)
+	if auth_provider == nil {
+		handleOauthNotConfigured(w)
+		return
+	}
+
+	if err := logic.SetState(appName, oauth_state_string); err != nil {
+		handleOauthNotConfigured(w)
+		return
+	}
+
+	authURL, err := getSAMLAuthURL(oauth_state_string)
+	if err != nil {
+		logger.Log(0, "error when creating SAML authentication request:", err.Error())
+		handleOauthNotConfigured(w)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
+}
+
+func handleSAMLCallback(w http.ResponseWriter, r *http.Request) {
+	var rState, rCode = getStateAndCode(r)
+
+	state, err := logic.GetState(rState)
+	if err != nil {
+		handleOauthNotValid(w)
+		return
+	}
+
+	content, groups, err := parseSAMLResponse(rState, rCode)
+	if err != nil {
+		logger.Log(1, "error when getting user info from callback:", err.Error())
+		handleOauthNotValid(w)
+		return
+	}
+	var inviteExists bool
+	// check if invite exists for User
+	in, err := logic.GetUserInvite(content.Email)
+	if err == nil {
+		inviteExists = true
+	}
+	// check if user approval is already pending
+	if !inviteExists && logic.IsPendingUser(content.Email) {
+		handleOauthUserSignUpApprovalPending(w)
+		return
+	}
+
+	user := &schema.User{Username: content.Email}
+	err = user.Get(r.Context())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) { // user must not exist, so try to make one
+			if inviteExists {
+				// create user
+				user, err := proLogic.PrepareOauthUserFromInvite(in)
+				if err != nil {
+					logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+					return
+				}
+				user.ExternalIdentityProviderID = string(content.ID)
+				if err = logic.CreateUser(&user); err != nil {
+					handleSomethingWentWrong(w)
+					return
+				}
+				logic.DeleteUserInvite(user.Username)
+				logic.DeletePendingUser(content.Email)
+			} else {
+				if !isEmailAllowed(content.Email) {
+					handleOauthUserNotAllowedToSignUp(w)
+					return
+				}
+				err = logic.InsertPendingUser(&models.User{
+					UserName:                   content.Email,
+					ExternalIdentityProviderID: string(content.ID),
+					AuthType:                   schema.OAuth,
+				})
+				if err != nil {
+					handleSomethingWentWrong(w)
+					return
+				}
+				handleFirstTimeOauthUserSignUp(w)
+				return
+			}
+		} else {
+			handleSomethingWentWrong(w)
+			return
+		}
+	} else {
+		// if user exists, then ensure user's auth type is
+		// oauth before proceeding.
+		if user.AuthType == schema.BasicAuth {
+			logger.Log(0, "invalid auth type: basic_auth")
+			handleAuthTypeMismatch(w)
+			return
+		}
+	}
+
+	user = &schema.User{Username: content.Email}
+	err = user.Get(r.Context())
+	if err != nil {
+		handleOauthUserNotFound(w)
+		return
+	}
+
+	if user.AccountDisabled {
+		handleUserAccountDisabled(w)
+		return
+	}
+
+	if logic.GetServerSettings().SAMLGroupsAttribute != "" {
+		err = syncSAMLUserGroups(user, groups)
+		if err != nil {
+			logger.Log(0, "failed to update groups of user", user.Username, err.Error())
+			handleSomethingWentWrong(w)
+			return
+		}
+	}
+
+	userRole := &schema.UserRole{ID: user.PlatformRoleID}
+	err = userRole.Get(r.Context())
+	if err != nil {
+		handleSomethingWentWrong(w)
+		return
+	}
+	if userRole.DenyDashboardAccess {
+		handleOauthUserNotAllowed(w)
+		return
+	}
+	var newPass, fetchErr = logic.FetchPassValue("")
+	if fetchErr != nil {
+		return
+	}
+	// send a netmaker jwt token
+	var authRequest = models.UserAuthParams{
+		UserName: content.Email,
+		Password: newPass,
+	}
+
+	var jwt, jwtErr = logic.VerifyAuthRequest(authRequest, state.AppName)
+	if jwtErr != nil {
+		logger.Log(1, "could not parse jwt for user", authRequest.UserName, jwtErr.Error())
+		return
+	}
+	logic.LogEvent(&models.Event{
+		Action: schema.Login,
+		Source: models.Subject{
+			ID:   user.Username,
+			Name: user.Username,
+			Type: schema.UserSub,
+		},
+		TriggeredBy: user.Username,
+		Target: models.Subject{
+			ID:   schema.DashboardSub.String(),
+			Name: schema.DashboardSub.String(),
+			Type: schema.DashboardSub,
+			Info: logic.ToReturnUser(user),
+		},
+		Origin: schema.Dashboard,
+	})
+	logger.Log(1, "completed SAML signin in for", content.Email)
+	// the idp posts the response, the browser has to follow with a GET.
+	http.Redirect(w, r, servercfg.GetFrontendURL()+"/login?login="+jwt+"&user="+content.Email, http.StatusSeeOther)
+}
+
+func getSAMLUserInfo(state string, code string) (*OAuthUser, error) {
+	user, _, err := parseSAMLResponse(state, code)
+	return user, err
+}
+
+// parseSAMLResponse - validates the response posted by the idp for a state
+// and returns the user and the groups it asserts.
+func parseSAMLResponse(state string, samlResponse string) (*OAuthUser, []string, error) {
+	oauth_state_string, isValid := logic.IsStateValid(state)
+	if (!isValid || state != oauth_state_string) && !isStateCached(state) {
+		return nil, nil, fmt.Errorf("invalid oauth state")
+	}
+
+	sp := saml_service_provider
+	if sp == nil {
+		return nil, nil, errors.New("saml is not configured")
+	}
+
+	rawResponse, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode saml response: %w", err)
+	}
+
+	assertion, err := sp.ParseXMLResponse(rawResponse, []string{samlRequestID(state)})
+	if err != nil {
+		// the error returned to the caller is kept generic by the library.
+		var invalidErr *saml.InvalidResponseError
+		if errors.As(err, &invalidErr) {
+			return nil, nil, fmt.Errorf("invalid saml response: %v", invalidErr.PrivateErr)
+		}
+		return nil, nil, err
+	}
+
+	var nameID string
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		nameID = assertion.Subject.NameID.Value
+	}
+
+	settings := logic.GetServerSettings()
+	username := nameID
+	if settings.SAMLUsernameAttribute != "" {
+		values := samlAttributeValues(assertion, settings.SAMLUsernameAttribute)
+		if len(values) == 0 {
+			return nil, nil, fmt.Errorf("saml assertion has no %s attribute", settings.SAMLUsernameAttribute)
+		}
+		username = values[0]
+	}
+	if username == "" {
+		return nil, nil, errors.New("saml assertion has no username")
+	}
+
+	var groups []string
+	if settings.SAMLGroupsAttribute != "" {
+		groups = samlAttributeValues(assertion, settings.SAMLGroupsAttribute)
+	}
+
+	id := nameID
+	if id == "" {
+		id = username
+	}
+
+	return &OAuthUser{
+		ID:    StringOrInt(id),
+		Email: username,
+	}, groups, nil
+}
+
+// samlAttributeValues - values of an assertion attribute, matched by name or
+// friendly name.
+func samlAttributeValues(assertion *saml.Assertion, name string) []string {
+	var values []string
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			if !strings.EqualFold(attribute.Name, name) && !strings.EqualFold(attribute.FriendlyName, name) {
+				continue
+			}
+
+			for _, value := range attribute.Values {
+				if value.Value != "" {
+					values = append(values, value.Value)
+				}
+			}
+		}
+	}
+
+	return values
+}
+
+// syncSAMLUserGroups - updates the memberships of a user in the groups
+// managed by the idp to the groups asserted on login, groups that do not
+// exist yet are created. Memberships of local groups are left untouched.
+func syncSAMLUserGroups(user *schema.User, groups []string) error {
+	filters := logic.GetServerSettings().GroupFilters
+	asserted := make(map[string]struct{})
+	for _, group := range groups {
+		if len(filters) > 0 && !hasAnyPrefix(group, filters) {
+			continue
+		}
+
+		asserted[group] = struct{}{}
+	}
+
+	dbGroups, err := (&schema.UserGroup{}).ListAll(db.WithContext(context.TODO()))
+	if err != nil {
+		return err
+	}
+
+	userGroups := make(map[schema.UserGroupID]struct{})
+	for groupID := range user.UserGroups.Data() {
+		userGroups[groupID] = struct{}{}
+	}
+
+	var changed, removed bool
+	for _, group := range dbGroups {
+		if group.ExternalIdentityProviderID == "" {
+			continue
+		}
+
+		_, isMember := userGroups[group.ID]
+		if _, ok := asserted[group.ExternalIdentityProviderID]; ok {
+			delete(asserted, group.ExternalIdentityProviderID)
+			if !isMember {
+				userGroups[group.ID] = struct{}{}
+				changed = true
+			}
+		} else if isMember {
+			delete(userGroups, group.ID)
+			changed = true
+			removed = true
+		}
+	}
+
+	for name := range asserted {
+		group := schema.UserGroup{
+			ExternalIdentityProviderID: name,
+			Name:                       name,
+			NetworkRoles:               datatypes.NewJSONType(schema.NetworkRoles{}),
+		}
+		err = proLogic.CreateUserGroup(&group)
+		if err != nil {
+			// a local group with the same name is not taken over.
+			logger.Log(1, "failed to create group", name, "asserted by the saml idp:", err.Error())
+			continue
+		}
+
+		userGroups[group.ID] = struct{}{}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	old := *user
+	user.UserGroups = datatypes.NewJSONType(userGroups)
+	err = logic.UpsertUser(*user)
+	if err != nil {
+		return err
+	}
+
+	if removed {
+		proLogic.UpdateUserGwAccess(&old, user)
+	}
+
+	return nil
+}
+
+// HandleSAMLMetadata - serves the metadata of the saml service provider, it
+// is registered with the idp.
+// Note: not included in API reference as part of the OAuth process itself.
+func HandleSAMLMetadata(w http.ResponseWriter, r *http.Request) {
+	sp := saml_service_provider
+	if sp == nil {
+		handleOauthNotConfigured(w)
+		return
+	}
+
+	data, err := xml.MarshalIndent(sp.Metadata(), "", "  ")
+	if err != nil {
+		handleSomethingWentWrong(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
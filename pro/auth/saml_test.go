@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/xml"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	proLogic "github.com/gravitl/netmaker/pro/logic"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+const testSAMLCallbackURL = "https://api.netmaker.example.com/api/oauth/callback"
+
+type testSAMLServiceProviders struct {
+	sp *saml.ServiceProvider
+}
+
+func (p testSAMLServiceProviders) GetServiceProvider(_ *http.Request, id string) (*saml.EntityDescriptor, error) {
+	if id != p.sp.EntityID {
+		return nil, os.ErrNotExist
+	}
+	return p.sp.Metadata(), nil
+}
+
+func newTestSAMLIDP(t *testing.T) *saml.IdentityProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	metadataURL, _ := url.Parse("https://idp.example.com/metadata")
+	ssoURL, _ := url.Parse("https://idp.example.com/sso")
+	return &saml.IdentityProvider{
+		Key:         key,
+		Certificate: cert,
+		MetadataURL: *metadataURL,
+		SSOURL:      *ssoURL,
+	}
+}
+
+func setupSAMLTest(t *testing.T) *saml.IdentityProvider {
+	db.InitializeDB(schema.ListModels()...)
+	database.InitializeDatabase()
+	t.Cleanup(func() {
+		saml_service_provider = nil
+		auth_provider = nil
+		database.CloseDB()
+		db.CloseDB()
+	})
+	ctx := db.WithContext(context.TODO())
+	require.NoError(t, db.FromContext(ctx).Where("1 = 1").Delete(&schema.User{}).Error)
+	require.NoError(t, db.FromContext(ctx).Where("1 = 1").Delete(&schema.UserGroup{}).Error)
+
+	idp := newTestSAMLIDP(t)
+	idpMetadata, err := xml.Marshal(idp.Metadata())
+	require.NoError(t, err)
+
+	settings := logic.GetServerSettings()
+	settings.AuthProvider = saml_provider_name
+	settings.SAMLIDPMetadata = string(idpMetadata)
+	settings.SAMLUsernameAttribute = "eduPersonPrincipalName"
+	settings.SAMLGroupsAttribute = "urn:oid:1.3.6.1.4.1.5923.1.1.1.1"
+	require.NoError(t, logic.UpsertServerSettings(settings))
+
+	initSAML(testSAMLCallbackURL, "", "")
+	require.NotNil(t, saml_service_provider)
+	idp.ServiceProviderProvider = testSAMLServiceProviders{sp: saml_service_provider}
+
+	return idp
+}
+
+// samlLogin - plays the idp: accepts the AuthnRequest the user is sent to
+// and returns the base64 encoded response posted back to netmaker.
+func samlLogin(t *testing.T, idp *saml.IdentityProvider, authURL string, session *saml.Session) string {
+	req, err := saml.NewIdpAuthnRequest(idp, httptest.NewRequest(http.MethodGet, authURL, nil))
+	require.NoError(t, err)
+	require.NoError(t, req.Validate())
+	require.NoError(t, saml.DefaultAssertionMaker{}.MakeAssertion(req, session))
+	require.NoError(t, req.MakeResponse())
+
+	doc := etree.NewDocument()
+	doc.SetRoot(req.ResponseEl)
+	data, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestSAMLServiceProvider(t *testing.T) {
+	setupSAMLTest(t)
+	sp := saml_service_provider
+
+	assert.Equal(t, testSAMLCallbackURL, sp.AcsURL.String())
+	assert.Equal(t, "https://api.netmaker.example.com/api/oauth/saml/metadata", sp.EntityID)
+	assert.Equal(t, "https://idp.example.com/sso", auth_provider.Endpoint.AuthURL)
+
+	// the key pair is kept, the metadata registered with the idp stays valid.
+	initSAML(testSAMLCallbackURL, "", "")
+	assert.Equal(t, sp.Certificate.Raw, saml_service_provider.Certificate.Raw)
+
+	w := httptest.NewRecorder()
+	HandleSAMLMetadata(w, httptest.NewRequest(http.MethodGet, "/api/oauth/saml/metadata", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	metadata := &saml.EntityDescriptor{}
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), metadata))
+	assert.Equal(t, sp.EntityID, metadata.EntityID)
+	require.Len(t, metadata.SPSSODescriptors, 1)
+	assert.True(t, *metadata.SPSSODescriptors[0].AuthnRequestsSigned)
+}
+
+func TestParseSAMLMetadata(t *testing.T) {
+	idp := newTestSAMLIDP(t)
+	entity, err := xml.Marshal(idp.Metadata())
+	require.NoError(t, err)
+
+	parsed, err := parseSAMLMetadata(entity)
+	require.NoError(t, err)
+	assert.Equal(t, idp.MetadataURL.String(), parsed.EntityID)
+
+	entities, err := xml.Marshal(saml.EntitiesDescriptor{EntityDescriptors: []saml.EntityDescriptor{*idp.Metadata()}})
+	require.NoError(t, err)
+	parsed, err = parseSAMLMetadata(entities)
+	require.NoError(t, err)
+	assert.Equal(t, idp.MetadataURL.String(), parsed.EntityID)
+
+	_, err = parseSAMLMetadata([]byte("<html></html>"))
+	assert.Error(t, err)
+}
+
+func TestSAMLLogin(t *testing.T) {
+	idp := setupSAMLTest(t)
+	state := logic.RandomString(user_signin_length)
+	require.NoError(t, logic.SetState(logic.NetmakerDesktopApp, state))
+
+	authURL, err := getSAMLAuthURL(state)
+	require.NoError(t, err)
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, state, parsed.Query().Get("RelayState"))
+	assert.NotEmpty(t, parsed.Query().Get("SigAlg"))
+	assert.NotEmpty(t, parsed.Query().Get("Signature"))
+
+	response := samlLogin(t, idp, authURL, &saml.Session{
+		NameID:    "00u1abcd",
+		UserEmail: "jane@example.com",
+		Groups:    []string{"engineering", "ops"},
+	})
+
+	user, groups, err := parseSAMLResponse(state, response)
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", user.getUserName())
+	assert.Equal(t, StringOrInt("00u1abcd"), user.ID)
+	assert.Equal(t, []string{"engineering", "ops"}, groups)
+
+	// the state is consumed by the first login
+	_, _, err = parseSAMLResponse(state, response)
+	assert.Error(t, err)
+}
+
+func TestSAMLLoginRejected(t *testing.T) {
+	idp := setupSAMLTest(t)
+	session := &saml.Session{NameID: "jane@example.com"}
+
+	state := logic.RandomString(user_signin_length)
+	authURL, err := getSAMLAuthURL(state)
+	require.NoError(t, err)
+	response := samlLogin(t, idp, authURL, session)
+
+	// response to the request of another state
+	other := logic.RandomString(user_signin_length)
+	require.NoError(t, logic.SetState(logic.NetmakerDesktopApp, other))
+	_, _, err = parseSAMLResponse(other, response)
+	assert.ErrorContains(t, err, "InResponseTo")
+
+	// response of an idp that is not trusted
+	forger := newTestSAMLIDP(t)
+	forger.MetadataURL = idp.MetadataURL
+	forger.ServiceProviderProvider = idp.ServiceProviderProvider
+	require.NoError(t, logic.SetState(logic.NetmakerDesktopApp, state))
+	_, _, err = parseSAMLResponse(state, samlLogin(t, forger, authURL, session))
+	assert.Error(t, err)
+
+	// unknown state
+	_, _, err = parseSAMLResponse(logic.RandomString(user_signin_length), response)
+	assert.ErrorContains(t, err, "invalid oauth state")
+}
+
+func TestSyncSAMLUserGroups(t *testing.T) {
+	setupSAMLTest(t)
+	proLogic.UserRolesInit()
+
+	local := schema.UserGroup{Name: "local", NetworkRoles: datatypes.NewJSONType(schema.NetworkRoles{})}
+	require.NoError(t, proLogic.CreateUserGroup(&local))
+	require.NoError(t, logic.CreateUser(&schema.User{
+		Username:       "jane@example.com",
+		Password:       "a-secret-password",
+		PlatformRoleID: schema.ServiceUser,
+		UserGroups:     datatypes.NewJSONType(map[schema.UserGroupID]struct{}{local.ID: {}}),
+	}))
+	user := &schema.User{Username: "jane@example.com"}
+	require.NoError(t, user.Get(db.WithContext(context.TODO())))
+
+	require.NoError(t, syncSAMLUserGroups(user, []string{"engineering", "ops"}))
+	require.NoError(t, user.Get(db.WithContext(context.TODO())))
+	assert.Len(t, user.UserGroups.Data(), 3)
+
+	engineering := &schema.UserGroup{Name: "engineering"}
+	require.NoError(t, engineering.GetByName(db.WithContext(context.TODO())))
+	assert.Equal(t, "engineering", engineering.ExternalIdentityProviderID)
+
+	// memberships of local groups are kept
+	require.NoError(t, syncSAMLUserGroups(user, []string{"ops"}))
+	require.NoError(t, user.Get(db.WithContext(context.TODO())))
+	assert.Len(t, user.UserGroups.Data(), 2)
+	assert.Contains(t, user.UserGroups.Data(), local.ID)
+	assert.NotContains(t, user.UserGroups.Data(), engineering.ID)
+}
@@ -2,12 +2,14 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/leader"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
@@ -15,6 +17,7 @@ import (
 	"github.com/gravitl/netmaker/pro/idp"
 	"github.com/gravitl/netmaker/pro/idp/azure"
 	"github.com/gravitl/netmaker/pro/idp/google"
+	"github.com/gravitl/netmaker/pro/idp/ldap"
 	"github.com/gravitl/netmaker/pro/idp/okta"
 	proLogic "github.com/gravitl/netmaker/pro/logic"
 	"github.com/gravitl/netmaker/schema"
@@ -30,18 +33,14 @@ var (
 )
 
 func ResetIDPSyncHook() {
-	if !servercfg.IsMasterPod() {
+	if !leader.IsLeader() {
 		if servercfg.IsHA() && logic.PublishServerSync != nil {
 			logic.PublishServerSync(logic.SyncTypeIDPSync)
 		}
 		return
 	}
 
-	if cancelSyncHook != nil {
-		cancelSyncHook()
-		hookStopWg.Wait()
-		cancelSyncHook = nil
-	}
+	StopIDPSyncHook()
 
 	if logic.IsSyncEnabled() {
 		ctx, cancel := context.WithCancel(context.Background())
@@ -51,6 +50,24 @@ func ResetIDPSyncHook() {
 	}
 }
 
+// StopIDPSyncHook - stops the periodic sync from the IDP
+func StopIDPSyncHook() {
+	if cancelSyncHook != nil {
+		cancelSyncHook()
+		hookStopWg.Wait()
+		cancelSyncHook = nil
+	}
+}
+
+// OnLeaderChange - runs the periodic sync from the IDP on the leader only
+func OnLeaderChange(isLeader bool) {
+	if isLeader {
+		ResetIDPSyncHook()
+	} else {
+		StopIDPSyncHook()
+	}
+}
+
 func runIDPSyncHook(ctx context.Context) {
 	defer hookStopWg.Done()
 	ticker := time.NewTicker(logic.GetIDPSyncInterval())
@@ -85,6 +102,13 @@ func SyncFromIDP() error {
 		idpSyncErr = err
 	}()
 
+	if proLogic.IsScimEnabled() {
+		// the IDP pushes users and groups, syncing as well would delete
+		// the users it provisioned that are missing in the pulled list
+		err = errors.New("idp sync is disabled while scim provisioning is enabled")
+		return err
+	}
+
 	switch settings.AuthProvider {
 	case "google":
 		idpClient, err = google.NewGoogleWorkspaceClientFromSettings()
@@ -98,6 +122,8 @@ func SyncFromIDP() error {
 		if err != nil {
 			return err
 		}
+	case "ldap":
+		idpClient = ldap.NewLDAPClientFromSettings()
 	default:
 		if settings.AuthProvider != "" {
 			err = fmt.Errorf("invalid auth provider: %s", settings.AuthProvider)
@@ -162,7 +188,7 @@ func syncUsers(idpUsers []idp.User, removeIntegration bool) error {
 			// delete the user if it has been archived.
 			user, ok := dbUsersMap[user.Username]
 			if ok {
-				_ = deleteAndCleanUpUser(user)
+				_ = DeleteAndCleanUpUser(user)
 			}
 			continue
 		}
@@ -232,7 +258,7 @@ func syncUsers(idpUsers []idp.User, removeIntegration bool) error {
 
 				// delete the user if it has been deleted on idp
 				// or is filtered out.
-				err = deleteAndCleanUpUser(user)
+				err = DeleteAndCleanUpUser(user)
 				if err != nil {
 					return err
 				}
@@ -440,10 +466,13 @@ func filterGroupsByMembers(idpGroups []idp.Group, idpUsers []idp.User) []idp.Gro
 	return filteredGroups
 }
 
+// DeleteAndCleanUpUser - deletes a user removed on the IDP along with its
+// ext clients and invites.
+//
 // TODO: deduplicate
 // The cyclic import between the package logic and mq requires this
 // function to be duplicated in multiple places.
-func deleteAndCleanUpUser(user *schema.User) error {
+func DeleteAndCleanUpUser(user *schema.User) error {
 	err := logic.DeleteUser(user.Username)
 	if err != nil {
 		return err
@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	proLogic "github.com/gravitl/netmaker/pro/logic"
+	"github.com/gravitl/netmaker/schema"
+)
+
+func AlertHandlers(r *mux.Router) {
+	r.HandleFunc("/api/v1/alert-rules", logic.SecurityCheck(true, http.HandlerFunc(listAlertRules))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/alert-rules", logic.SecurityCheck(true, http.HandlerFunc(createAlertRule))).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/alert-rules/{rule_id}", logic.SecurityCheck(true, http.HandlerFunc(getAlertRule))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/alert-rules/{rule_id}", logic.SecurityCheck(true, http.HandlerFunc(updateAlertRule))).Methods(http.MethodPut)
+	r.HandleFunc("/api/v1/alert-rules/{rule_id}", logic.SecurityCheck(true, http.HandlerFunc(deleteAlertRule))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/v1/alerts", logic.SecurityCheck(true, http.HandlerFunc(listAlerts))).Methods(http.MethodGet)
+}
+
+// @Summary     List alert rules
+// @Router      /api/v1/alert-rules [get]
+// @Tags        Alerts
+// @Security    oauth
+// @Produce     json
+// @Success     200 {array} schema.AlertRule
+// @Failure     500 {object} models.ErrorResponse
+func listAlertRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := (&schema.AlertRule{}).ListAll(db.WithContext(r.Context()))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.Internal))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, rules, "fetched alert rules")
+}
+
+// @Summary     Get an alert rule
+// @Router      /api/v1/alert-rules/{rule_id} [get]
+// @Tags        Alerts
+// @Security    oauth
+// @Produce     json
+// @Param       rule_id path string true "Alert Rule ID"
+// @Success     200 {object} schema.AlertRule
+// @Failure     400 {object} models.ErrorResponse
+func getAlertRule(w http.ResponseWriter, r *http.Request) {
+	rule := schema.AlertRule{ID: mux.Vars(r)["rule_id"]}
+	if err := rule.Get(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("alert rule not found"), logic.BadReq))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, rule, "fetched alert rule")
+}
+
+// @Summary     Create an alert rule
+// @Router      /api/v1/alert-rules [post]
+// @Tags        Alerts
+// @Security    oauth
+// @Accept      json
+// @Produce     json
+// @Param       body body schema.AlertRule true "Alert rule payload"
+// @Success     200 {object} schema.AlertRule
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func createAlertRule(w http.ResponseWriter, r *http.Request) {
+	var req schema.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Log(0, "error decoding request body: ", err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.BadReq))
+		return
+	}
+	if err := proLogic.ValidateAlertRule(&req); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.BadReq))
+		return
+	}
+	rule := schema.AlertRule{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Type:      req.Type,
+		Network:   req.Network,
+		Threshold: req.Threshold,
+		Severity:  req.Severity,
+		Emails:    req.Emails,
+		Enabled:   req.Enabled,
+		CreatedBy: r.Header.Get("user"),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	if err := rule.Create(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("error creating alert rule "+err.Error()), logic.Internal))
+		return
+	}
+	logic.LogEvent(&models.Event{
+		Action: schema.Create,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: schema.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   rule.ID,
+			Name: rule.Name,
+			Type: schema.AlertRuleSub,
+		},
+		NetworkID: rule.Network,
+		Origin:    schema.Dashboard,
+	})
+	logic.ReturnSuccessResponseWithJson(w, r, rule, "created alert rule")
+}
+
+// @Summary     Update an alert rule
+// @Router      /api/v1/alert-rules/{rule_id} [put]
+// @Tags        Alerts
+// @Security    oauth
+// @Accept      json
+// @Produce     json
+// @Param       rule_id path string true "Alert Rule ID"
+// @Param       body body schema.AlertRule true "Alert rule payload, firing alerts of a disabled rule are resolved"
+// @Success     200 {object} schema.AlertRule
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func updateAlertRule(w http.ResponseWriter, r *http.Request) {
+	var req schema.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Log(0, "error decoding request body: ", err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.BadReq))
+		return
+	}
+	rule := schema.AlertRule{ID: mux.Vars(r)["rule_id"]}
+	if err := rule.Get(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("alert rule not found"), logic.BadReq))
+		return
+	}
+	if err := proLogic.ValidateAlertRule(&req); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.BadReq))
+		return
+	}
+	old := rule
+	rule.Name = req.Name
+	rule.Type = req.Type
+	rule.Network = req.Network
+	rule.Threshold = req.Threshold
+	rule.Severity = req.Severity
+	rule.Emails = req.Emails
+	rule.Enabled = req.Enabled
+	rule.UpdatedAt = time.Now().UTC()
+	if err := rule.Update(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("error updating alert rule "+err.Error()), logic.Internal))
+		return
+	}
+	logic.LogEvent(&models.Event{
+		Action: schema.Update,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: schema.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   rule.ID,
+			Name: rule.Name,
+			Type: schema.AlertRuleSub,
+		},
+		Diff: models.Diff{
+			Old: old,
+			New: rule,
+		},
+		NetworkID: rule.Network,
+		Origin:    schema.Dashboard,
+	})
+	logic.ReturnSuccessResponseWithJson(w, r, rule, "updated alert rule")
+}
+
+// @Summary     Delete an alert rule and its alerts
+// @Router      /api/v1/alert-rules/{rule_id} [delete]
+// @Tags        Alerts
+// @Security    oauth
+// @Produce     json
+// @Param       rule_id path string true "Alert Rule ID"
+// @Success     200 {object} models.SuccessResponse
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func deleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	rule := schema.AlertRule{ID: mux.Vars(r)["rule_id"]}
+	if err := rule.Get(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("alert rule not found"), logic.BadReq))
+		return
+	}
+	if err := rule.Delete(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.Internal))
+		return
+	}
+	if err := (&schema.Alert{RuleID: rule.ID}).DeleteByRule(db.WithContext(r.Context())); err != nil {
+		logger.Log(0, "failed to delete alerts of rule", rule.ID, err.Error())
+	}
+	logic.LogEvent(&models.Event{
+		Action: schema.Delete,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: schema.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   rule.ID,
+			Name: rule.Name,
+			Type: schema.AlertRuleSub,
+		},
+		Diff: models.Diff{
+			Old: rule,
+			New: nil,
+		},
+		NetworkID: rule.Network,
+		Origin:    schema.Dashboard,
+	})
+	logic.ReturnSuccessResponse(w, r, "deleted alert rule "+rule.Name)
+}
+
+// @Summary     List alerts
+// @Router      /api/v1/alerts [get]
+// @Tags        Alerts
+// @Security    oauth
+// @Produce     json
+// @Param       state query string false "firing or resolved"
+// @Success     200 {array} schema.Alert
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func listAlerts(w http.ResponseWriter, r *http.Request) {
+	state := schema.AlertState(r.URL.Query().Get("state"))
+	if state != "" && state != schema.AlertFiring && state != schema.AlertResolved {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("invalid state"), logic.BadReq))
+		return
+	}
+	alerts, err := (&schema.Alert{State: state}).List(db.WithContext(r.Context()))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.Internal))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, alerts, "fetched alerts")
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/gravitl/netmaker/db"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
+	proLogic "github.com/gravitl/netmaker/pro/logic"
 	"github.com/gravitl/netmaker/schema"
 )
 
@@ -16,6 +17,30 @@ func EventHandlers(r *mux.Router) {
 	r.HandleFunc("/api/v1/network/activity", logic.SecurityCheck(true, http.HandlerFunc(listNetworkActivity))).Methods(http.MethodGet)
 	r.HandleFunc("/api/v1/user/activity", logic.SecurityCheck(false, http.HandlerFunc(listUserActivity))).Methods(http.MethodGet)
 	r.HandleFunc("/api/v1/activity", logic.SecurityCheck(true, http.HandlerFunc(listActivity))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/activity/verify", logic.SecurityCheck(true, http.HandlerFunc(verifyActivity))).Methods(http.MethodGet)
+}
+
+// @Summary     Verify the integrity of the activity log
+// @Router      /api/v1/activity/verify [get]
+// @Tags        Activity
+// @Security    oauth
+// @Produce     json
+// @Success     200 {object} models.EventChainVerification
+// @Failure     500 {object} models.ErrorResponse
+func verifyActivity(w http.ResponseWriter, r *http.Request) {
+	result, err := proLogic.VerifyEventChain(db.WithContext(r.Context()))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, models.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+	msg := "activity log is intact"
+	if !result.Valid {
+		msg = "activity log was tampered with"
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, result, msg)
 }
 
 // @Summary     List network activity
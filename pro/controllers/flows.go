@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -13,10 +14,14 @@ import (
 	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/logic"
 	proLogic "github.com/gravitl/netmaker/pro/logic"
+	"github.com/gravitl/netmaker/schema"
 )
 
 func FlowHandlers(r *mux.Router) {
 	r.HandleFunc("/api/v1/flows", logic.SecurityCheck(true, http.HandlerFunc(handleListFlows))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/flows/top_talkers", logic.SecurityCheck(true, http.HandlerFunc(handleFlowTopTalkers))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/flows/ports", logic.SecurityCheck(true, http.HandlerFunc(handleFlowPortTotals))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/flows/series", logic.SecurityCheck(true, http.HandlerFunc(handleFlowSeries))).Methods(http.MethodGet)
 }
 
 const (
@@ -103,41 +108,22 @@ func handleListFlows(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 
 	// TODO: handle query filters better
-	var (
-		whereParts []string
-		args       []any
-	)
-
 	// 0. Network filter.
 	networkID := q.Get("network_id")
-	if networkID != "" {
-		whereParts = append(whereParts, "network_id = ?")
-		args = append(args, networkID)
+	whereParts, args, err := flowNetworkFilter(r, networkID)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, flowFilterErrType(err)))
+		return
 	}
 
 	// 1. Time filtering (start_ts: UInt64 timestamp in ms)
-	fromStr := q.Get("from")
-	toStr := q.Get("to")
-
-	if fromStr != "" {
-		fromVal, err := time.Parse(time.RFC3339, fromStr)
-		if err != nil {
-			logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("invalid 'from' timestamp: %v", err), logic.BadReq))
-			return
-		}
-		whereParts = append(whereParts, "start_ts >= ?")
-		args = append(args, fromVal)
-	}
-
-	if toStr != "" {
-		toVal, err := time.Parse(time.RFC3339, toStr)
-		if err != nil {
-			logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("invalid 'to' timestamp: %v", err), logic.BadReq))
-			return
-		}
-		whereParts = append(whereParts, "start_ts <= ?")
-		args = append(args, toVal)
+	timeParts, timeArgs, err := flowTimeFilter(q)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.BadReq))
+		return
 	}
+	whereParts = append(whereParts, timeParts...)
+	args = append(args, timeArgs...)
 
 	// 2. Source filters
 	if q.Get("src_type") != "" {
@@ -267,3 +253,414 @@ func parseIntOrDefault(s string, def int) int {
 	}
 	return v
 }
+
+const (
+	defaultFlowAggregateLimit = 10
+	maxFlowAggregateLimit     = 1000
+	defaultFlowSeriesBucket   = 5 * time.Minute
+	// maxFlowSeriesPoints bounds the number of buckets a single
+	// series query may produce per network.
+	maxFlowSeriesPoints = 2000
+)
+
+var errFlowNetworkForbidden = errors.New("access denied to flows of this network")
+
+// FlowTalkerRow represents the traffic totals of a single source or
+// destination entity.
+type FlowTalkerRow struct {
+	EntityType  string `ch:"entity_type" json:"entity_type"`
+	EntityID    string `ch:"entity_id" json:"entity_id"`
+	EntityName  string `ch:"entity_name" json:"entity_name"`
+	BytesSent   uint64 `ch:"bytes_sent" json:"bytes_sent"`
+	BytesRecv   uint64 `ch:"bytes_recv" json:"bytes_recv"`
+	TotalBytes  uint64 `ch:"total_bytes" json:"total_bytes"`
+	PacketsSent uint64 `ch:"packets_sent" json:"packets_sent"`
+	PacketsRecv uint64 `ch:"packets_recv" json:"packets_recv"`
+	Flows       uint64 `ch:"flows" json:"flows"`
+}
+
+// FlowPortRow represents the traffic totals of a destination port and
+// protocol pair.
+type FlowPortRow struct {
+	Protocol    uint16 `ch:"protocol" json:"protocol"`
+	DstPort     uint16 `ch:"dst_port" json:"dst_port"`
+	BytesSent   uint64 `ch:"bytes_sent" json:"bytes_sent"`
+	BytesRecv   uint64 `ch:"bytes_recv" json:"bytes_recv"`
+	TotalBytes  uint64 `ch:"total_bytes" json:"total_bytes"`
+	PacketsSent uint64 `ch:"packets_sent" json:"packets_sent"`
+	PacketsRecv uint64 `ch:"packets_recv" json:"packets_recv"`
+	Flows       uint64 `ch:"flows" json:"flows"`
+}
+
+// FlowSeriesPoint represents the traffic totals of a network within a
+// single time bucket.
+type FlowSeriesPoint struct {
+	NetworkID   string    `ch:"network_id" json:"network_id"`
+	Bucket      time.Time `ch:"bucket" json:"bucket"`
+	BytesSent   uint64    `ch:"bytes_sent" json:"bytes_sent"`
+	BytesRecv   uint64    `ch:"bytes_recv" json:"bytes_recv"`
+	TotalBytes  uint64    `ch:"total_bytes" json:"total_bytes"`
+	PacketsSent uint64    `ch:"packets_sent" json:"packets_sent"`
+	PacketsRecv uint64    `ch:"packets_recv" json:"packets_recv"`
+	Flows       uint64    `ch:"flows" json:"flows"`
+}
+
+const flowTotalsSelect = `
+	sum(bytes_sent) AS bytes_sent,
+	sum(bytes_recv) AS bytes_recv,
+	sum(bytes_sent + bytes_recv) AS total_bytes,
+	sum(packets_sent) AS packets_sent,
+	sum(packets_recv) AS packets_recv,
+	count() AS flows`
+
+// @Summary     List top talkers by bytes
+// @Router      /api/v1/flows/top_talkers [get]
+// @Tags        Traffic Logs
+// @Security    oauth
+// @Produce     json
+// @Param       network_id query string false "Filter by network ID"
+// @Param       from query string false "Start time in RFC3339 format"
+// @Param       to query string false "End time in RFC3339 format"
+// @Param       by query string false "Group by src or dst entity (default src)"
+// @Param       limit query int false "Number of entities to return (max 1000)"
+// @Success     200 {array} FlowTalkerRow
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     403 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func handleFlowTopTalkers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	side := q.Get("by")
+	if side == "" {
+		side = "src"
+	}
+	if side != "src" && side != "dst" {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("'by' must be either src or dst"), logic.BadReq))
+		return
+	}
+
+	whereSQL, args, ok := flowAggregateFilter(w, r)
+	if !ok {
+		return
+	}
+
+	query, args := flowTopTalkersQuery(side, whereSQL, args, flowAggregateLimit(q))
+
+	result := make([]FlowTalkerRow, 0)
+	if err := queryFlowAggregate(r, query, args, func(scan func(any) error) error {
+		var row FlowTalkerRow
+		if err := scan(&row); err != nil {
+			return err
+		}
+		result = append(result, row)
+		return nil
+	}); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("error fetching top talkers: %v", err), logic.Internal))
+		return
+	}
+
+	logic.ReturnSuccessResponseWithJson(w, r, result, "top talkers retrieved successfully")
+}
+
+// @Summary     List traffic totals per destination port and protocol
+// @Router      /api/v1/flows/ports [get]
+// @Tags        Traffic Logs
+// @Security    oauth
+// @Produce     json
+// @Param       network_id query string false "Filter by network ID"
+// @Param       from query string false "Start time in RFC3339 format"
+// @Param       to query string false "End time in RFC3339 format"
+// @Param       limit query int false "Number of port/protocol pairs to return (max 1000)"
+// @Success     200 {array} FlowPortRow
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     403 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func handleFlowPortTotals(w http.ResponseWriter, r *http.Request) {
+	whereSQL, args, ok := flowAggregateFilter(w, r)
+	if !ok {
+		return
+	}
+
+	query, args := flowPortTotalsQuery(whereSQL, args, flowAggregateLimit(r.URL.Query()))
+
+	result := make([]FlowPortRow, 0)
+	if err := queryFlowAggregate(r, query, args, func(scan func(any) error) error {
+		var row FlowPortRow
+		if err := scan(&row); err != nil {
+			return err
+		}
+		result = append(result, row)
+		return nil
+	}); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("error fetching port totals: %v", err), logic.Internal))
+		return
+	}
+
+	logic.ReturnSuccessResponseWithJson(w, r, result, "port totals retrieved successfully")
+}
+
+// @Summary     List time-bucketed traffic totals per network
+// @Router      /api/v1/flows/series [get]
+// @Tags        Traffic Logs
+// @Security    oauth
+// @Produce     json
+// @Param       network_id query string false "Filter by network ID"
+// @Param       from query string false "Start time in RFC3339 format"
+// @Param       to query string false "End time in RFC3339 format"
+// @Param       bucket query string false "Bucket size as a duration of whole seconds, e.g. 1m, 5m, 1h (default 5m)"
+// @Success     200 {array} FlowSeriesPoint
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     403 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func handleFlowSeries(w http.ResponseWriter, r *http.Request) {
+	bucket, err := parseFlowSeriesBucket(r.URL.Query())
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.BadReq))
+		return
+	}
+
+	whereSQL, args, ok := flowAggregateFilter(w, r)
+	if !ok {
+		return
+	}
+
+	query, args := flowSeriesQuery(bucket, whereSQL, args)
+
+	result := make([]FlowSeriesPoint, 0)
+	if err := queryFlowAggregate(r, query, args, func(scan func(any) error) error {
+		var point FlowSeriesPoint
+		if err := scan(&point); err != nil {
+			return err
+		}
+		result = append(result, point)
+		return nil
+	}); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("error fetching flow series: %v", err), logic.Internal))
+		return
+	}
+
+	logic.ReturnSuccessResponseWithJson(w, r, result, "flow series retrieved successfully")
+}
+
+// flowAggregateFilter builds the where clause shared by the aggregate
+// endpoints: network scope, time range and completed flows only. It
+// writes the error response and returns false if the request cannot
+// be served.
+func flowAggregateFilter(w http.ResponseWriter, r *http.Request) (string, []any, bool) {
+	if !proLogic.GetFeatureFlags().EnableFlowLogs || !logic.GetServerSettings().EnableFlowLogs {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("flow logs not enabled"), logic.Forbidden))
+		return "", nil, false
+	}
+
+	q := r.URL.Query()
+	whereParts, args, err := flowNetworkFilter(r, q.Get("network_id"))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, flowFilterErrType(err)))
+		return "", nil, false
+	}
+
+	timeParts, timeArgs, err := flowTimeFilter(q)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.BadReq))
+		return "", nil, false
+	}
+	whereParts = append(whereParts, timeParts...)
+	args = append(args, timeArgs...)
+
+	// only destroyed flows carry traffic counters.
+	whereParts = append(whereParts, "end_ts <> ?")
+	args = append(args, time.Unix(0, 0))
+
+	return "WHERE " + strings.Join(whereParts, " AND "), args, true
+}
+
+// flowTopTalkersQuery builds the top talkers query, grouping by the
+// source or destination entity depending on side.
+func flowTopTalkersQuery(side, whereSQL string, args []any, limit int) (string, []any) {
+	query := fmt.Sprintf(`
+SELECT
+	%[1]s_type AS entity_type,
+	if(%[1]s_entity_id = '', %[1]s_ip, %[1]s_entity_id) AS entity_id,
+	any(%[1]s_entity_name) AS entity_name,%[2]s
+FROM flows FINAL
+%[3]s
+GROUP BY entity_type, entity_id
+ORDER BY total_bytes DESC
+LIMIT ?`, side, flowTotalsSelect, whereSQL)
+	return query, append(args, limit)
+}
+
+// flowPortTotalsQuery builds the query of the traffic totals per
+// destination port and protocol.
+func flowPortTotalsQuery(whereSQL string, args []any, limit int) (string, []any) {
+	query := fmt.Sprintf(`
+SELECT
+	protocol,
+	dst_port,%s
+FROM flows FINAL
+%s
+GROUP BY protocol, dst_port
+ORDER BY total_bytes DESC
+LIMIT ?`, flowTotalsSelect, whereSQL)
+	return query, append(args, limit)
+}
+
+// flowSeriesQuery builds the query of the traffic totals per network and
+// time bucket. The bucket size is the first argument.
+func flowSeriesQuery(bucket time.Duration, whereSQL string, args []any) (string, []any) {
+	query := fmt.Sprintf(`
+SELECT
+	network_id,
+	toStartOfInterval(start_ts, toIntervalSecond(?)) AS bucket,%s
+FROM flows FINAL
+%s
+GROUP BY network_id, bucket
+ORDER BY network_id, bucket`, flowTotalsSelect, whereSQL)
+	return query, append([]any{int64(bucket / time.Second)}, args...)
+}
+
+// parseFlowSeriesBucket parses the bucket query parameter. Buckets are
+// queried in whole seconds, so anything else is rejected rather than
+// truncated, as are time ranges that produce too many buckets.
+func parseFlowSeriesBucket(q url.Values) (time.Duration, error) {
+	bucket := defaultFlowSeriesBucket
+	if q.Get("bucket") != "" {
+		var err error
+		bucket, err = time.ParseDuration(q.Get("bucket"))
+		if err != nil || bucket < time.Second || bucket%time.Second != 0 {
+			return 0, errors.New("'bucket' must be a whole number of seconds of at least 1s")
+		}
+	}
+	from, to, err := parseFlowTimeRange(q)
+	if err != nil {
+		return 0, err
+	}
+	if !from.IsZero() {
+		if to.IsZero() {
+			to = time.Now()
+		}
+		if to.Sub(from)/bucket > maxFlowSeriesPoints {
+			return 0, fmt.Errorf("time range produces more than %d buckets, increase 'bucket'", maxFlowSeriesPoints)
+		}
+	}
+	return bucket, nil
+}
+
+// queryFlowAggregate runs the query and calls next for every row.
+func queryFlowAggregate(r *http.Request, query string, args []any, next func(scan func(any) error) error) error {
+	conn, err := ch.FromContext(r.Context())
+	if err != nil {
+		return fmt.Errorf("clickhouse connection not available: %v", err)
+	}
+
+	rows, err := conn.Query(r.Context(), query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := next(rows.ScanStruct); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// flowNetworkFilter restricts a flows query to the networks the caller
+// has access to. Callers without full access are limited to the
+// networks of their user groups, and requesting any other network is
+// forbidden.
+func flowNetworkFilter(r *http.Request, networkID string) ([]string, []any, error) {
+	if r.Header.Get("ismaster") == "yes" {
+		if networkID == "" {
+			return nil, nil, nil
+		}
+		return []string{"network_id = ?"}, []any{networkID}, nil
+	}
+
+	user := &schema.User{Username: r.Header.Get("user")}
+	err := user.Get(r.Context())
+	if err != nil {
+		return nil, nil, err
+	}
+	userRole := &schema.UserRole{ID: user.PlatformRoleID}
+	err = userRole.Get(r.Context())
+	if err != nil {
+		return nil, nil, err
+	}
+	if userRole.FullAccess {
+		if networkID == "" {
+			return nil, nil, nil
+		}
+		return []string{"network_id = ?"}, []any{networkID}, nil
+	}
+
+	allNetworks, err := (&schema.Network{}).ListAll(r.Context())
+	if err != nil {
+		return nil, nil, err
+	}
+	var allowed []string
+	for _, network := range logic.FilterNetworksByRole(allNetworks, user) {
+		if networkID == "" || network.Name == networkID {
+			allowed = append(allowed, network.Name)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, nil, errFlowNetworkForbidden
+	}
+	return []string{"network_id IN ?"}, []any{allowed}, nil
+}
+
+func flowFilterErrType(err error) logic.ApiErrorType {
+	if errors.Is(err, errFlowNetworkForbidden) {
+		return logic.Forbidden
+	}
+	return logic.Internal
+}
+
+// flowTimeFilter builds the start_ts conditions for the from/to query
+// parameters.
+func flowTimeFilter(q url.Values) ([]string, []any, error) {
+	from, to, err := parseFlowTimeRange(q)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		whereParts []string
+		args       []any
+	)
+	if !from.IsZero() {
+		whereParts = append(whereParts, "start_ts >= ?")
+		args = append(args, from)
+	}
+	if !to.IsZero() {
+		whereParts = append(whereParts, "start_ts <= ?")
+		args = append(args, to)
+	}
+	return whereParts, args, nil
+}
+
+func parseFlowTimeRange(q url.Values) (from, to time.Time, err error) {
+	if fromStr := q.Get("from"); fromStr != "" {
+		from, err = time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid 'from' timestamp: %v", err)
+		}
+	}
+	if toStr := q.Get("to"); toStr != "" {
+		to, err = time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid 'to' timestamp: %v", err)
+		}
+	}
+	return from, to, nil
+}
+
+func flowAggregateLimit(q url.Values) int {
+	limit := parseIntOrDefault(q.Get("limit"), defaultFlowAggregateLimit)
+	if limit > maxFlowAggregateLimit {
+		limit = maxFlowAggregateLimit
+	}
+	return limit
+}
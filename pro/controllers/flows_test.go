@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	proLogic "github.com/gravitl/netmaker/pro/logic"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func setupFlowsTestDB(t *testing.T) context.Context {
+	db.InitializeDB(schema.ListModels()...)
+	database.InitializeDatabase()
+	filterNetworksByRole := logic.FilterNetworksByRole
+	logic.FilterNetworksByRole = proLogic.FilterNetworksByRole
+	t.Cleanup(func() {
+		logic.FilterNetworksByRole = filterNetworksByRole
+		database.CloseDB()
+		db.CloseDB()
+	})
+	ctx := db.WithContext(context.TODO())
+	require.NoError(t, db.FromContext(ctx).Where("1 = 1").Delete(&schema.User{}).Error)
+	require.NoError(t, db.FromContext(ctx).Where("1 = 1").Delete(&schema.UserGroup{}).Error)
+	require.NoError(t, db.FromContext(ctx).Where("1 = 1").Delete(&schema.Network{}).Error)
+	proLogic.UserRolesInit()
+	for _, name := range []string{"net1", "net2", "net3"} {
+		require.NoError(t, (&schema.Network{Name: name}).Create(ctx))
+	}
+	return ctx
+}
+
+func flowsRequest(ctx context.Context, username string, master bool) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/flows/top_talkers", nil).WithContext(ctx)
+	r.Header.Set("user", username)
+	if master {
+		r.Header.Set("ismaster", "yes")
+	}
+	return r
+}
+
+func TestFlowNetworkFilter(t *testing.T) {
+	ctx := setupFlowsTestDB(t)
+
+	group := schema.UserGroup{
+		ID: schema.UserGroupID(uuid.NewString()),
+		NetworkRoles: datatypes.NewJSONType(schema.NetworkRoles{
+			"net1": {"net1-network-user": {}},
+			"net2": {"net2-network-user": {}},
+		}),
+	}
+	require.NoError(t, group.Create(ctx))
+	users := []schema.User{
+		{Username: "admin", PlatformRoleID: schema.AdminRole},
+		{
+			Username:       "scoped",
+			PlatformRoleID: schema.PlatformUser,
+			UserGroups:     datatypes.NewJSONType(map[schema.UserGroupID]struct{}{group.ID: {}}),
+		},
+		{Username: "nogroups", PlatformRoleID: schema.PlatformUser},
+	}
+	for i := range users {
+		require.NoError(t, users[i].Create(ctx))
+	}
+
+	tests := []struct {
+		name      string
+		username  string
+		master    bool
+		networkID string
+		where     []string
+		args      []any
+		err       error
+	}{
+		{name: "master token, all networks", master: true},
+		{
+			name:      "master token, one network",
+			master:    true,
+			networkID: "net3",
+			where:     []string{"network_id = ?"},
+			args:      []any{"net3"},
+		},
+		{name: "full access, all networks", username: "admin"},
+		{
+			name:      "full access, one network",
+			username:  "admin",
+			networkID: "net3",
+			where:     []string{"network_id = ?"},
+			args:      []any{"net3"},
+		},
+		{
+			name:     "scoped user, all networks",
+			username: "scoped",
+			where:    []string{"network_id IN ?"},
+			args:     []any{[]string{"net1", "net2"}},
+		},
+		{
+			name:      "scoped user, own network",
+			username:  "scoped",
+			networkID: "net2",
+			where:     []string{"network_id IN ?"},
+			args:      []any{[]string{"net2"}},
+		},
+		{
+			name:      "scoped user, other network",
+			username:  "scoped",
+			networkID: "net3",
+			err:       errFlowNetworkForbidden,
+		},
+		{
+			name:     "user without networks",
+			username: "nogroups",
+			err:      errFlowNetworkForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args, err := flowNetworkFilter(flowsRequest(ctx, tt.username, tt.master), tt.networkID)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				assert.Equal(t, logic.Forbidden, flowFilterErrType(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.where, where)
+			assert.ElementsMatch(t, tt.args, args)
+		})
+	}
+
+	t.Run("unknown user", func(t *testing.T) {
+		_, _, err := flowNetworkFilter(flowsRequest(ctx, "unknown", false), "")
+		require.Error(t, err)
+		assert.Equal(t, logic.Internal, flowFilterErrType(err))
+	})
+}
+
+func TestFlowAggregateQueries(t *testing.T) {
+	where := "WHERE network_id IN ? AND end_ts <> ?"
+	args := []any{[]string{"net1"}, time.Unix(0, 0)}
+
+	query, queryArgs := flowTopTalkersQuery("dst", where, args, 25)
+	assert.Contains(t, query, "dst_type AS entity_type")
+	assert.Contains(t, query, "if(dst_entity_id = '', dst_ip, dst_entity_id) AS entity_id")
+	assert.Contains(t, query, where)
+	assert.Equal(t, []any{[]string{"net1"}, time.Unix(0, 0), 25}, queryArgs)
+
+	query, queryArgs = flowPortTotalsQuery(where, args, 10)
+	assert.Contains(t, query, "GROUP BY protocol, dst_port")
+	assert.Contains(t, query, where)
+	assert.Equal(t, []any{[]string{"net1"}, time.Unix(0, 0), 10}, queryArgs)
+
+	// the bucket size binds the placeholder in the select list, ahead
+	// of the where clause
+	query, queryArgs = flowSeriesQuery(90*time.Second, where, args)
+	assert.Contains(t, query, "toIntervalSecond(?)")
+	assert.Contains(t, query, where)
+	assert.Equal(t, []any{int64(90), []string{"net1"}, time.Unix(0, 0)}, queryArgs)
+}
+
+func TestFlowTimeFilter(t *testing.T) {
+	where, args, err := flowTimeFilter(url.Values{})
+	require.NoError(t, err)
+	assert.Empty(t, where)
+	assert.Empty(t, args)
+
+	where, args, err = flowTimeFilter(url.Values{
+		"from": {"2026-01-01T00:00:00Z"},
+		"to":   {"2026-01-02T00:00:00Z"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"start_ts >= ?", "start_ts <= ?"}, where)
+	assert.Equal(t, []any{
+		time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+	}, args)
+
+	_, _, err = flowTimeFilter(url.Values{"from": {"yesterday"}})
+	assert.Error(t, err)
+}
+
+func TestParseFlowSeriesBucket(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  url.Values
+		bucket time.Duration
+		err    bool
+	}{
+		{name: "default", query: url.Values{}, bucket: defaultFlowSeriesBucket},
+		{name: "minutes", query: url.Values{"bucket": {"1m"}}, bucket: time.Minute},
+		{name: "one second", query: url.Values{"bucket": {"1s"}}, bucket: time.Second},
+		{name: "under a second", query: url.Values{"bucket": {"500ms"}}, err: true},
+		{name: "fractional seconds", query: url.Values{"bucket": {"1.5s"}}, err: true},
+		{name: "negative", query: url.Values{"bucket": {"-1m"}}, err: true},
+		{name: "not a duration", query: url.Values{"bucket": {"often"}}, err: true},
+		{
+			name: "within the point limit",
+			query: url.Values{
+				"bucket": {"1m"},
+				"from":   {"2026-01-01T00:00:00Z"},
+				"to":     {"2026-01-01T12:00:00Z"},
+			},
+			bucket: time.Minute,
+		},
+		{
+			name: "too many points",
+			query: url.Values{
+				"bucket": {"1s"},
+				"from":   {"2026-01-01T00:00:00Z"},
+				"to":     {"2026-01-01T12:00:00Z"},
+			},
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket, err := parseFlowSeriesBucket(tt.query)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.bucket, bucket)
+		})
+	}
+}
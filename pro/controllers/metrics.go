@@ -2,7 +2,10 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	proLogic "github.com/gravitl/netmaker/pro/logic"
 	"golang.org/x/exp/slog"
@@ -17,6 +20,7 @@ import (
 // MetricHandlers - How we handle Pro Metrics
 func MetricHandlers(r *mux.Router) {
 	r.HandleFunc("/api/metrics/{network}/{nodeid}", logic.SecurityCheck(true, http.HandlerFunc(getNodeMetrics))).Methods(http.MethodGet)
+	r.HandleFunc("/api/metrics/{network}/{nodeid}/history", logic.SecurityCheck(true, http.HandlerFunc(getNodeMetricsHistory))).Methods(http.MethodGet)
 	r.HandleFunc("/api/metrics/{network}", logic.SecurityCheck(true, http.HandlerFunc(getNetworkNodesMetrics))).Methods(http.MethodGet)
 	r.HandleFunc("/api/metrics", logic.SecurityCheck(true, http.HandlerFunc(getAllMetrics))).Methods(http.MethodGet)
 	r.HandleFunc("/api/metrics-ext/{network}", logic.SecurityCheck(true, http.HandlerFunc(getNetworkExtMetrics))).Methods(http.MethodGet)
@@ -51,6 +55,73 @@ func getNodeMetrics(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(metrics)
 }
 
+// @Summary     Get historical metrics for a specific node
+// @Router      /api/metrics/{network}/{nodeid}/history [get]
+// @Tags        Metrics
+// @Security    oauth
+// @Produce     json
+// @Param       network path string true "Network ID"
+// @Param       nodeid path string true "Node ID"
+// @Param       peer query string false "Peer ID, all peers when empty"
+// @Param       from query string false "Start of the range in RFC3339 format, defaults to a day before to"
+// @Param       to query string false "End of the range in RFC3339 format, defaults to now"
+// @Param       step query string false "Bucket size the points are aggregated into (e.g. 5m, 1h)"
+// @Success     200 {object} models.MetricsHistory
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func getNodeMetricsHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var params = mux.Vars(r)
+	query := models.MetricsHistoryQuery{
+		NodeID: params["nodeid"],
+		PeerID: r.URL.Query().Get("peer"),
+	}
+	var err error
+	if from := r.URL.Query().Get("from"); from != "" {
+		query.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("invalid from: %w", err), "badrequest"))
+			return
+		}
+	}
+	if to := r.URL.Query().Get("to"); to != "" {
+		query.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("invalid to: %w", err), "badrequest"))
+			return
+		}
+	}
+	if step := r.URL.Query().Get("step"); step != "" {
+		query.Step, err = time.ParseDuration(step)
+		if err == nil && query.Step < 0 {
+			err = errors.New("must not be negative")
+		}
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("invalid step: %w", err), "badrequest"))
+			return
+		}
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("from must be before to"), "badrequest"))
+		return
+	}
+	node, err := logic.GetNodeByID(query.NodeID)
+	if err != nil || node.Network != params["network"] {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("node not found"), "badrequest"))
+		return
+	}
+
+	history, err := proLogic.GetMetricsHistory(query)
+	if err != nil {
+		logger.Log(1, r.Header.Get("user"), "failed to fetch metrics history of node", query.NodeID, err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(history)
+}
+
 // @Summary     Get metrics for all nodes in a network
 // @Router      /api/metrics/{network} [get]
 // @Tags        Metrics
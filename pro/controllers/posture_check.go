@@ -108,7 +108,7 @@ func createPostureCheck(w http.ResponseWriter, r *http.Request) {
 		Origin:    schema.Dashboard,
 	})
 
-	go mq.PublishPeerUpdate(false)
+	go mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(pc.NetworkID)}})
 	go proLogic.RunPostureChecks()
 	proLogic.PopulatePostureCheckGroupNames([]schema.PostureCheck{pc})
 	logic.ReturnSuccessResponseWithJson(w, r, pc, "created posture check")
@@ -247,7 +247,7 @@ func updatePostureCheck(w http.ResponseWriter, r *http.Request) {
 		pc.UpdateStatus(db.WithContext(context.TODO()))
 	}
 	logic.LogEvent(event)
-	go mq.PublishPeerUpdate(false)
+	go mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(pc.NetworkID)}})
 	go proLogic.RunPostureChecks()
 	proLogic.PopulatePostureCheckGroupNames([]schema.PostureCheck{pc})
 	logic.ReturnSuccessResponseWithJson(w, r, pc, "updated posture check")
@@ -302,7 +302,7 @@ func deletePostureCheck(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 
-	go mq.PublishPeerUpdate(false)
+	go mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{schema.NetworkID(pc.NetworkID)}})
 	go proLogic.RunPostureChecks()
 	logic.ReturnSuccessResponseWithJson(w, r, pc, "deleted posture check")
 }
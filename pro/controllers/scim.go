@@ -0,0 +1,525 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	proAuth "github.com/gravitl/netmaker/pro/auth"
+	proLogic "github.com/gravitl/netmaker/pro/logic"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+const (
+	scimBasePath        = "/scim/v2"
+	scimContentType     = "application/scim+json"
+	scimDefaultPageSize = 100
+	scimMaxPageSize     = 1000
+)
+
+func ScimHandlers(r *mux.Router) {
+	r.HandleFunc("/api/v1/scim/token", logic.SecurityCheck(true, http.HandlerFunc(getScimToken))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/scim/token", logic.SecurityCheck(true, http.HandlerFunc(createScimToken))).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/scim/token", logic.SecurityCheck(true, http.HandlerFunc(deleteScimToken))).Methods(http.MethodDelete)
+
+	r.HandleFunc(scimBasePath+"/ServiceProviderConfig", scimAuth(getScimServiceProviderConfig)).Methods(http.MethodGet)
+	r.HandleFunc(scimBasePath+"/ResourceTypes", scimAuth(getScimResourceTypes)).Methods(http.MethodGet)
+	r.HandleFunc(scimBasePath+"/Users", scimAuth(listScimUsers)).Methods(http.MethodGet)
+	r.HandleFunc(scimBasePath+"/Users", scimAuth(createScimUser)).Methods(http.MethodPost)
+	r.HandleFunc(scimBasePath+"/Users/{id}", scimAuth(getScimUser)).Methods(http.MethodGet)
+	r.HandleFunc(scimBasePath+"/Users/{id}", scimAuth(replaceScimUser)).Methods(http.MethodPut)
+	r.HandleFunc(scimBasePath+"/Users/{id}", scimAuth(patchScimUser)).Methods(http.MethodPatch)
+	r.HandleFunc(scimBasePath+"/Users/{id}", scimAuth(deleteScimUser)).Methods(http.MethodDelete)
+	r.HandleFunc(scimBasePath+"/Groups", scimAuth(listScimGroups)).Methods(http.MethodGet)
+	r.HandleFunc(scimBasePath+"/Groups", scimAuth(createScimGroup)).Methods(http.MethodPost)
+	r.HandleFunc(scimBasePath+"/Groups/{id}", scimAuth(getScimGroup)).Methods(http.MethodGet)
+	r.HandleFunc(scimBasePath+"/Groups/{id}", scimAuth(replaceScimGroup)).Methods(http.MethodPut)
+	r.HandleFunc(scimBasePath+"/Groups/{id}", scimAuth(patchScimGroup)).Methods(http.MethodPatch)
+	r.HandleFunc(scimBasePath+"/Groups/{id}", scimAuth(deleteScimGroup)).Methods(http.MethodDelete)
+}
+
+// scimBaseURL - url of the SCIM api as seen by the IDP
+func scimBaseURL() string {
+	serverConn := servercfg.GetAPIHost()
+	if strings.Contains(serverConn, "localhost") || strings.Contains(serverConn, "127.0.0.1") {
+		return "http://" + serverConn + scimBasePath
+	}
+	return "https://" + serverConn + scimBasePath
+}
+
+// scimAuth - authenticates requests of the IDP with the SCIM bearer token
+func scimAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !proLogic.ValidateScimToken(strings.TrimSpace(token)) {
+			writeScimError(w, &proLogic.ScimRequestError{Status: http.StatusUnauthorized, Detail: "invalid scim token"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeScim(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+	if v != nil {
+		_ = json.NewEncoder(w).Encode(v)
+	}
+}
+
+func writeScimError(w http.ResponseWriter, err error) {
+	var reqErr *proLogic.ScimRequestError
+	if !errors.As(err, &reqErr) {
+		logger.Log(0, "scim request failed:", err.Error())
+		reqErr = &proLogic.ScimRequestError{Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+	writeScim(w, reqErr.Status, models.ScimError{
+		Schemas:  []string{models.ScimErrorSchema},
+		Status:   strconv.Itoa(reqErr.Status),
+		ScimType: reqErr.ScimType,
+		Detail:   reqErr.Detail,
+	})
+}
+
+func decodeScim(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return &proLogic.ScimRequestError{Status: http.StatusBadRequest, ScimType: "invalidSyntax", Detail: err.Error()}
+	}
+	return nil
+}
+
+// scimPage - reads the startIndex and count query parameters
+func scimPage(r *http.Request) (int, int, error) {
+	startIndex, count := 1, scimDefaultPageSize
+	for name, value := range map[string]*int{"startIndex": &startIndex, "count": &count} {
+		param := r.URL.Query().Get(name)
+		if param == "" {
+			continue
+		}
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return 0, 0, &proLogic.ScimRequestError{Status: http.StatusBadRequest, ScimType: "invalidValue", Detail: "invalid " + name}
+		}
+		*value = n
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > scimMaxPageSize {
+		count = scimMaxPageSize
+	}
+	return startIndex, count, nil
+}
+
+func scimUserLocation(su *models.ScimUser) {
+	if su.Meta != nil {
+		su.Meta.Location = scimBaseURL() + "/Users/" + su.ID
+	}
+}
+
+func scimGroupLocation(sg *models.ScimGroup) {
+	if sg.Meta != nil {
+		sg.Meta.Location = scimBaseURL() + "/Groups/" + sg.ID
+	}
+}
+
+func logScimEvent(action schema.Action, target models.Subject) {
+	logic.LogEvent(&models.Event{
+		Action: action,
+		Source: models.Subject{
+			ID:   "scim",
+			Name: "SCIM",
+			Type: schema.ScimTokenSub,
+		},
+		TriggeredBy: "scim",
+		Target:      target,
+		Origin:      schema.Scim,
+	})
+}
+
+// @Summary     Get the SCIM provisioning status
+// @Router      /api/v1/scim/token [get]
+// @Tags        SCIM
+// @Security    oauth
+// @Produce     json
+// @Success     200 {object} models.ScimTokenInfo
+func getScimToken(w http.ResponseWriter, r *http.Request) {
+	info := proLogic.GetScimTokenInfo()
+	info.URL = scimBaseURL()
+	logic.ReturnSuccessResponseWithJson(w, r, info, "fetched scim provisioning status")
+}
+
+// @Summary     Create the SCIM token
+// @Router      /api/v1/scim/token [post]
+// @Tags        SCIM
+// @Security    oauth
+// @Produce     json
+// @Success     200 {object} models.ScimTokenInfo
+// @Failure     500 {object} models.ErrorResponse
+func createScimToken(w http.ResponseWriter, r *http.Request) {
+	info, err := proLogic.CreateScimToken(r.Header.Get("user"))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.Internal))
+		return
+	}
+	info.URL = scimBaseURL()
+	logic.LogEvent(&models.Event{
+		Action: schema.Create,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: schema.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   "scim",
+			Name: "SCIM token",
+			Type: schema.ScimTokenSub,
+		},
+		Origin: schema.Dashboard,
+	})
+	logic.ReturnSuccessResponseWithJson(w, r, info, "created scim token, it will not be shown again")
+}
+
+// @Summary     Delete the SCIM token, disabling SCIM provisioning
+// @Router      /api/v1/scim/token [delete]
+// @Tags        SCIM
+// @Security    oauth
+// @Produce     json
+// @Success     200 {object} models.SuccessResponse
+// @Failure     500 {object} models.ErrorResponse
+func deleteScimToken(w http.ResponseWriter, r *http.Request) {
+	if err := proLogic.DeleteScimToken(); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.Internal))
+		return
+	}
+	logic.LogEvent(&models.Event{
+		Action: schema.Delete,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: schema.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   "scim",
+			Name: "SCIM token",
+			Type: schema.ScimTokenSub,
+		},
+		Origin: schema.Dashboard,
+	})
+	logic.ReturnSuccessResponse(w, r, "deleted scim token")
+}
+
+// @Summary     SCIM service provider configuration
+// @Router      /scim/v2/ServiceProviderConfig [get]
+// @Tags        SCIM
+// @Produce     json
+func getScimServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	writeScim(w, http.StatusOK, map[string]any{
+		"schemas":        []string{models.ScimSPConfigSchema},
+		"patch":          map[string]bool{"supported": true},
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": scimMaxPageSize},
+		"changePassword": map[string]bool{"supported": false},
+		"sort":           map[string]bool{"supported": false},
+		"etag":           map[string]bool{"supported": false},
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "Token created in the netmaker dashboard",
+			"primary":     true,
+		}},
+	})
+}
+
+// @Summary     SCIM resource types
+// @Router      /scim/v2/ResourceTypes [get]
+// @Tags        SCIM
+// @Produce     json
+func getScimResourceTypes(w http.ResponseWriter, r *http.Request) {
+	resourceTypes := []map[string]any{
+		{
+			"schemas":  []string{models.ScimResourceTypeSchema},
+			"id":       "User",
+			"name":     "User",
+			"endpoint": "/Users",
+			"schema":   models.ScimUserSchema,
+		},
+		{
+			"schemas":  []string{models.ScimResourceTypeSchema},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   models.ScimGroupSchema,
+		},
+	}
+	writeScim(w, http.StatusOK, proLogic.PaginateScim(resourceTypes, 1, len(resourceTypes)))
+}
+
+// @Summary     List users
+// @Router      /scim/v2/Users [get]
+// @Tags        SCIM
+// @Produce     json
+// @Param       filter query string false "SCIM filter, e.g. userName eq \"jane@example.com\""
+// @Param       startIndex query int false "1-based index of the first result"
+// @Param       count query int false "Maximum number of results"
+// @Success     200 {object} models.ScimListResponse
+func listScimUsers(w http.ResponseWriter, r *http.Request) {
+	startIndex, count, err := scimPage(r)
+	if err != nil {
+		writeScimError(w, err)
+		return
+	}
+	users, err := proLogic.ListScimUsers(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeScimError(w, err)
+		return
+	}
+	for i := range users {
+		scimUserLocation(&users[i])
+	}
+	writeScim(w, http.StatusOK, proLogic.PaginateScim(users, startIndex, count))
+}
+
+// @Summary     Get a user
+// @Router      /scim/v2/Users/{id} [get]
+// @Tags        SCIM
+// @Produce     json
+// @Param       id path string true "User ID"
+// @Success     200 {object} models.ScimUser
+func getScimUser(w http.ResponseWriter, r *http.Request) {
+	user, err := proLogic.GetScimUser(mux.Vars(r)["id"])
+	if err != nil {
+		writeScimError(w, err)
+		return
+	}
+	scimUserLocation(&user)
+	writeScim(w, http.StatusOK, user)
+}
+
+// @Summary     Provision a user
+// @Router      /scim/v2/Users [post]
+// @Tags        SCIM
+// @Accept      json
+// @Produce     json
+// @Param       body body models.ScimUser true "User"
+// @Success     201 {object} models.ScimUser
+func createScimUser(w http.ResponseWriter, r *http.Request) {
+	var req models.ScimUser
+	if err := decodeScim(r, &req); err != nil {
+		writeScimError(w, err)
+		return
+	}
+	user, err := proLogic.CreateScimUser(req)
+	if err != nil {
+		writeScimError(w, err)
+		return
+	}
+	scimUserLocation(&user)
+	logScimEvent(schema.Create, models.Subject{ID: user.UserName, Name: user.UserName, Type: schema.UserSub})
+	w.Header().Set("Location", user.Meta.Location)
+	writeScim(w, http.StatusCreated, user)
+}
+
+// @Summary     Replace a user
+// @Router      /scim/v2/Users/{id} [put]
+// @Tags        SCIM
+// @Accept      json
+// @Produce     json
+// @Param       id path string true "User ID"
+// @Param       body body models.ScimUser true "User"
+// @Success     200 {object} models.ScimUser
+func replaceScimUser(w http.ResponseWriter, r *http.Request) {
+	var req models.ScimUser
+	if err := decodeScim(r, &req); err != nil {
+		writeScimError(w, err)
+		return
+	}
+	user, err := proLogic.ReplaceScimUser(mux.Vars(r)["id"], req)
+	if err != nil {
+		writeScimError(w, err)
+		return
+	}
+	scimUserLocation(&user)
+	logScimEvent(schema.Update, models.Subject{ID: user.UserName, Name: user.UserName, Type: schema.UserSub})
+	writeScim(w, http.StatusOK, user)
+}
+
+// @Summary     Update a user
+// @Router      /scim/v2/Users/{id} [patch]
+// @Tags        SCIM
+// @Accept      json
+// @Produce     json
+// @Param       id path string true "User ID"
+// @Param       body body models.ScimPatchRequest true "Patch operations"
+// @Success     200 {object} models.ScimUser
+func patchScimUser(w http.ResponseWriter, r *http.Request) {
+	var req models.ScimPatchRequest
+	if err := decodeScim(r, &req); err != nil {
+		writeScimError(w, err)
+		return
+	}
+	user, err := proLogic.PatchScimUser(mux.Vars(r)["id"], req)
+	if err != nil {
+		writeScimError(w, err)
+		return
+	}
+	scimUserLocation(&user)
+	logScimEvent(schema.Update, models.Subject{ID: user.UserName, Name: user.UserName, Type: schema.UserSub})
+	writeScim(w, http.StatusOK, user)
+}
+
+// @Summary     Deprovision a user
+// @Router      /scim/v2/Users/{id} [delete]
+// @Tags        SCIM
+// @Param       id path string true "User ID"
+// @Success     204
+func deleteScimUser(w http.ResponseWriter, r *http.Request) {
+	user, err := proLogic.GetScimUserForDeletion(mux.Vars(r)["id"])
+	if err != nil {
+		writeScimError(w, err)
+		return
+	}
+	if err := proAuth.DeleteAndCleanUpUser(user); err != nil {
+		writeScimError(w, err)
+		return
+	}
+	logScimEvent(schema.Delete, models.Subject{ID: user.Username, Name: user.Username, Type: schema.UserSub})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary     List groups
+// @Router      /scim/v2/Groups [get]
+// @Tags        SCIM
+// @Produce     json
+// @Param       filter query string false "SCIM filter, e.g. displayName eq \"engineering\""
+// @Param       startIndex query int false "1-based index of the first result"
+// @Param       count query int false "Maximum number of results"
+// @Success     200 {object} models.ScimListResponse
+func listScimGroups(w http.ResponseWriter, r *http.Request) {
+	startIndex, count, err := scimPage(r)
+	if err != nil {
+		writeScimError(w, err)
+		return
+	}
+	groups, err := proLogic.ListScimGroups(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeScimError(w, err)
+		return
+	}
+	for i := range groups {
+		scimGroupLocation(&groups[i])
+	}
+	writeScim(w, http.StatusOK, proLogic.PaginateScim(groups, startIndex, count))
+}
+
+// @Summary     Get a group
+// @Router      /scim/v2/Groups/{id} [get]
+// @Tags        SCIM
+// @Produce     json
+// @Param       id path string true "Group ID"
+// @Success     200 {object} models.ScimGroup
+func getScimGroup(w http.ResponseWriter, r *http.Request) {
+	group, err := proLogic.GetScimGroup(mux.Vars(r)["id"])
+	if err != nil {
+		writeScimError(w, err)
+		return
+	}
+	scimGroupLocation(&group)
+	writeScim(w, http.StatusOK, group)
+}
+
+// @Summary     Provision a group
+// @Router      /scim/v2/Groups [post]
+// @Tags        SCIM
+// @Accept      json
+// @Produce     json
+// @Param       body body models.ScimGroup true "Group"
+// @Success     201 {object} models.ScimGroup
+func createScimGroup(w http.ResponseWriter, r *http.Request) {
+	var req models.ScimGroup
+	if err := decodeScim(r, &req); err != nil {
+		writeScimError(w, err)
+		return
+	}
+	group, err := proLogic.CreateScimGroup(req)
+	if err != nil {
+		writeScimError(w, err)
+		return
+	}
+	scimGroupLocation(&group)
+	logScimEvent(schema.Create, models.Subject{ID: group.ID, Name: group.DisplayName, Type: schema.UserGroupSub})
+	w.Header().Set("Location", group.Meta.Location)
+	writeScim(w, http.StatusCreated, group)
+}
+
+// @Summary     Replace a group
+// @Router      /scim/v2/Groups/{id} [put]
+// @Tags        SCIM
+// @Accept      json
+// @Produce     json
+// @Param       id path string true "Group ID"
+// @Param       body body models.ScimGroup true "Group"
+// @Success     200 {object} models.ScimGroup
+func replaceScimGroup(w http.ResponseWriter, r *http.Request) {
+	var req models.ScimGroup
+	if err := decodeScim(r, &req); err != nil {
+		writeScimError(w, err)
+		return
+	}
+	group, err := proLogic.ReplaceScimGroup(mux.Vars(r)["id"], req)
+	if err != nil {
+		writeScimError(w, err)
+		return
+	}
+	scimGroupLocation(&group)
+	logScimEvent(schema.Update, models.Subject{ID: group.ID, Name: group.DisplayName, Type: schema.UserGroupSub})
+	writeScim(w, http.StatusOK, group)
+}
+
+// @Summary     Update a group
+// @Router      /scim/v2/Groups/{id} [patch]
+// @Tags        SCIM
+// @Accept      json
+// @Produce     json
+// @Param       id path string true "Group ID"
+// @Param       body body models.ScimPatchRequest true "Patch operations"
+// @Success     200 {object} models.ScimGroup
+func patchScimGroup(w http.ResponseWriter, r *http.Request) {
+	var req models.ScimPatchRequest
+	if err := decodeScim(r, &req); err != nil {
+		writeScimError(w, err)
+		return
+	}
+	group, err := proLogic.PatchScimGroup(mux.Vars(r)["id"], req)
+	if err != nil {
+		writeScimError(w, err)
+		return
+	}
+	scimGroupLocation(&group)
+	logScimEvent(schema.Update, models.Subject{ID: group.ID, Name: group.DisplayName, Type: schema.UserGroupSub})
+	writeScim(w, http.StatusOK, group)
+}
+
+// @Summary     Delete a group
+// @Router      /scim/v2/Groups/{id} [delete]
+// @Tags        SCIM
+// @Param       id path string true "Group ID"
+// @Success     204
+func deleteScimGroup(w http.ResponseWriter, r *http.Request) {
+	group, err := proLogic.DeleteScimGroup(mux.Vars(r)["id"])
+	if err != nil {
+		writeScimError(w, err)
+		return
+	}
+	logScimEvent(schema.Delete, models.Subject{ID: group.ID.String(), Name: group.Name, Type: schema.UserGroupSub})
+	w.WriteHeader(http.StatusNoContent)
+}
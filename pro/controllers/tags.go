@@ -155,7 +155,7 @@ func createTag(w http.ResponseWriter, r *http.Request) {
 		NetworkID: tag.Network,
 		Origin:    schema.Dashboard,
 	})
-	go mq.PublishPeerUpdate(false)
+	go mq.PublishPeerUpdate(false, models.PeerUpdateChange{Tags: []models.TagID{tag.ID}})
 
 	var res models.TagListRespNodes = models.TagListRespNodes{
 		Tag:         tag,
@@ -242,7 +242,7 @@ func updateTag(w http.ResponseWriter, r *http.Request) {
 		if updateTag.NewName != "" {
 			proLogic.UpdateDeviceTag(updateTag.ID, newID, tag.Network)
 		}
-		mq.PublishPeerUpdate(false)
+		mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{tag.Network}})
 	}()
 	e.Diff.New = updateTag
 	logic.LogEvent(e)
@@ -290,7 +290,7 @@ func deleteTag(w http.ResponseWriter, r *http.Request) {
 		proLogic.RemoveTagFromPostureChecks(tag.ID, tag.Network)
 		proLogic.RemoveTagFromNameservers(tag.ID, tag.Network)
 		logic.RemoveTagFromEnrollmentKeys(tag.ID)
-		mq.PublishPeerUpdate(false)
+		mq.PublishPeerUpdate(false, models.PeerUpdateChange{Networks: []schema.NetworkID{tag.Network}})
 	}()
 	logic.LogEvent(&models.Event{
 		Action: schema.Delete,
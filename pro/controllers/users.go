@@ -13,6 +13,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/database"
 	dbtypes "github.com/gravitl/netmaker/db/types"
+	"github.com/gravitl/netmaker/leader"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
@@ -22,6 +23,7 @@ import (
 	"github.com/gravitl/netmaker/pro/idp"
 	"github.com/gravitl/netmaker/pro/idp/azure"
 	"github.com/gravitl/netmaker/pro/idp/google"
+	"github.com/gravitl/netmaker/pro/idp/ldap"
 	"github.com/gravitl/netmaker/pro/idp/okta"
 	proLogic "github.com/gravitl/netmaker/pro/logic"
 	"github.com/gravitl/netmaker/schema"
@@ -34,7 +36,8 @@ import (
 func UserHandlers(r *mux.Router) {
 
 	r.HandleFunc("/api/oauth/login", proAuth.HandleAuthLogin).Methods(http.MethodGet)
-	r.HandleFunc("/api/oauth/callback", proAuth.HandleAuthCallback).Methods(http.MethodGet)
+	r.HandleFunc("/api/oauth/callback", proAuth.HandleAuthCallback).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/api/oauth/saml/metadata", proAuth.HandleSAMLMetadata).Methods(http.MethodGet)
 	r.HandleFunc("/api/oauth/headless", proAuth.HandleHeadlessSSO)
 	r.HandleFunc("/api/oauth/register/{regKey}", proAuth.RegisterHostSSO).Methods(http.MethodGet)
 
@@ -2089,22 +2092,15 @@ func deleteAllPendingUsers(w http.ResponseWriter, r *http.Request) {
 // @Tags        IDP
 // @Security    oauth
 // @Produce     json
-// @Success     200 {object} models.SuccessResponse
+// @Success     202 {object} models.SuccessResponse "with the queued schema.Job"
+// @Failure     500 {object} models.ErrorResponse
 func syncIDP(w http.ResponseWriter, r *http.Request) {
-	if servercfg.IsMasterPod() {
-		go func() {
-			err := proAuth.SyncFromIDP()
-			if err != nil {
-				logger.Log(0, "failed to sync from idp: ", err.Error())
-			} else {
-				logger.Log(0, "sync from idp complete")
-			}
-		}()
-	} else if servercfg.IsHA() && logic.PublishServerSync != nil {
-		logic.PublishServerSync(logic.SyncTypeIDPSync)
+	job, err := logic.EnqueueJob(logic.IDPSyncJob, r.Header.Get("user"), nil)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.Internal))
+		return
 	}
-
-	logic.ReturnSuccessResponse(w, r, "starting sync from idp")
+	logic.ReturnAcceptedResponseWithJson(w, r, job, "starting sync from idp")
 }
 
 // @Summary     Test IDP Sync Credentials
@@ -2146,6 +2142,23 @@ func testIDPSync(w http.ResponseWriter, r *http.Request) {
 			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 			return
 		}
+	case "ldap":
+		bindPassword := req.LDAPBindPassword
+		if bindPassword == logic.Mask() {
+			bindPassword = logic.GetServerSettings().LDAPBindPassword
+		}
+		idpClient = ldap.NewLDAPClient(ldap.Config{
+			URL:                  req.LDAPURL,
+			StartTLS:             req.LDAPStartTLS,
+			InsecureSkipVerify:   req.LDAPSkipTLSVerify,
+			BindDN:               req.LDAPBindDN,
+			BindPassword:         bindPassword,
+			BaseDN:               req.LDAPBaseDN,
+			UserFilter:           req.LDAPUserFilter,
+			GroupFilter:          req.LDAPGroupFilter,
+			UsernameAttribute:    req.LDAPUsernameAttribute,
+			GroupMemberAttribute: req.LDAPGroupMemberAttribute,
+		})
 	default:
 		err = fmt.Errorf("invalid auth provider: %s", req.AuthProvider)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
@@ -2209,6 +2222,20 @@ func removeIDPIntegration(w http.ResponseWriter, r *http.Request) {
 	settings.AzureTenant = ""
 	settings.OktaOrgURL = ""
 	settings.OktaAPIToken = ""
+	settings.LDAPURL = ""
+	settings.LDAPStartTLS = false
+	settings.LDAPSkipTLSVerify = false
+	settings.LDAPBindDN = ""
+	settings.LDAPBindPassword = ""
+	settings.LDAPBaseDN = ""
+	settings.LDAPUserFilter = ""
+	settings.LDAPGroupFilter = ""
+	settings.LDAPUsernameAttribute = ""
+	settings.LDAPGroupMemberAttribute = ""
+	settings.SAMLIDPMetadataURL = ""
+	settings.SAMLIDPMetadata = ""
+	settings.SAMLUsernameAttribute = ""
+	settings.SAMLGroupsAttribute = ""
 	settings.UserFilters = nil
 	settings.GroupFilters = nil
 	settings.IDPSyncInterval = ""
@@ -2226,7 +2253,7 @@ func removeIDPIntegration(w http.ResponseWriter, r *http.Request) {
 	proAuth.ResetAuthProvider()
 	proAuth.ResetIDPSyncHook()
 
-	if servercfg.IsMasterPod() {
+	if leader.IsLeader() {
 		go func() {
 			err := proAuth.SyncFromIDP()
 			if err != nil {
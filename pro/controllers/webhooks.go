@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	proLogic "github.com/gravitl/netmaker/pro/logic"
+	"github.com/gravitl/netmaker/schema"
+)
+
+func WebhookHandlers(r *mux.Router) {
+	r.HandleFunc("/api/v1/webhooks", logic.SecurityCheck(true, http.HandlerFunc(listWebhooks))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/webhooks", logic.SecurityCheck(true, http.HandlerFunc(createWebhook))).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/webhooks/{webhook_id}", logic.SecurityCheck(true, http.HandlerFunc(getWebhook))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/webhooks/{webhook_id}", logic.SecurityCheck(true, http.HandlerFunc(updateWebhook))).Methods(http.MethodPut)
+	r.HandleFunc("/api/v1/webhooks/{webhook_id}", logic.SecurityCheck(true, http.HandlerFunc(deleteWebhook))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/v1/webhooks/{webhook_id}/test", logic.SecurityCheck(true, http.HandlerFunc(testWebhook))).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/webhooks/{webhook_id}/deliveries", logic.SecurityCheck(true, http.HandlerFunc(listWebhookDeliveries))).Methods(http.MethodGet)
+}
+
+// maskWebhook - hides the signing secret, it is only returned when the webhook is created
+func maskWebhook(w schema.Webhook) schema.Webhook {
+	if w.Secret != "" {
+		w.Secret = logic.Mask()
+	}
+	return w
+}
+
+// @Summary     List webhooks
+// @Router      /api/v1/webhooks [get]
+// @Tags        Webhooks
+// @Security    oauth
+// @Produce     json
+// @Success     200 {array} schema.Webhook
+// @Failure     500 {object} models.ErrorResponse
+func listWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := (&schema.Webhook{}).ListAll(db.WithContext(r.Context()))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.Internal))
+		return
+	}
+	for i := range webhooks {
+		webhooks[i] = maskWebhook(webhooks[i])
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, webhooks, "fetched webhooks")
+}
+
+// @Summary     Get a webhook
+// @Router      /api/v1/webhooks/{webhook_id} [get]
+// @Tags        Webhooks
+// @Security    oauth
+// @Produce     json
+// @Param       webhook_id path string true "Webhook ID"
+// @Success     200 {object} schema.Webhook
+// @Failure     400 {object} models.ErrorResponse
+func getWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook := schema.Webhook{ID: mux.Vars(r)["webhook_id"]}
+	if err := webhook.Get(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("webhook not found"), logic.BadReq))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, maskWebhook(webhook), "fetched webhook")
+}
+
+// @Summary     Create a webhook
+// @Router      /api/v1/webhooks [post]
+// @Tags        Webhooks
+// @Security    oauth
+// @Accept      json
+// @Produce     json
+// @Param       body body schema.Webhook true "Webhook payload, a secret is generated when empty"
+// @Success     200 {object} schema.Webhook
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func createWebhook(w http.ResponseWriter, r *http.Request) {
+	var req schema.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Log(0, "error decoding request body: ", err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.BadReq))
+		return
+	}
+	if err := proLogic.ValidateWebhook(&req); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.BadReq))
+		return
+	}
+	webhook := schema.Webhook{
+		ID:           uuid.New().String(),
+		Name:         req.Name,
+		URL:          req.URL,
+		Secret:       req.Secret,
+		Actions:      req.Actions,
+		SubjectTypes: req.SubjectTypes,
+		Networks:     req.Networks,
+		Enabled:      req.Enabled,
+		CreatedBy:    r.Header.Get("user"),
+		CreatedAt:    time.Now().UTC(),
+		UpdatedAt:    time.Now().UTC(),
+	}
+	if webhook.Secret == "" {
+		webhook.Secret = proLogic.GenerateWebhookSecret()
+	}
+	if err := webhook.Create(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("error creating webhook "+err.Error()), logic.Internal))
+		return
+	}
+	logic.LogEvent(&models.Event{
+		Action: schema.Create,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: schema.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   webhook.ID,
+			Name: webhook.Name,
+			Type: schema.WebhookSub,
+		},
+		Origin: schema.Dashboard,
+	})
+	logic.ReturnSuccessResponseWithJson(w, r, webhook, "created webhook")
+}
+
+// @Summary     Update a webhook
+// @Router      /api/v1/webhooks/{webhook_id} [put]
+// @Tags        Webhooks
+// @Security    oauth
+// @Accept      json
+// @Produce     json
+// @Param       webhook_id path string true "Webhook ID"
+// @Param       body body schema.Webhook true "Webhook payload, the secret is kept when empty or masked"
+// @Success     200 {object} schema.Webhook
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func updateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req schema.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Log(0, "error decoding request body: ", err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.BadReq))
+		return
+	}
+	webhook := schema.Webhook{ID: mux.Vars(r)["webhook_id"]}
+	if err := webhook.Get(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("webhook not found"), logic.BadReq))
+		return
+	}
+	if err := proLogic.ValidateWebhook(&req); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.BadReq))
+		return
+	}
+	old := maskWebhook(webhook)
+	webhook.Name = req.Name
+	webhook.URL = req.URL
+	if req.Secret != "" && req.Secret != logic.Mask() {
+		webhook.Secret = req.Secret
+	}
+	webhook.Actions = req.Actions
+	webhook.SubjectTypes = req.SubjectTypes
+	webhook.Networks = req.Networks
+	webhook.Enabled = req.Enabled
+	webhook.UpdatedAt = time.Now().UTC()
+	if err := webhook.Update(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("error updating webhook "+err.Error()), logic.Internal))
+		return
+	}
+	logic.LogEvent(&models.Event{
+		Action: schema.Update,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: schema.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   webhook.ID,
+			Name: webhook.Name,
+			Type: schema.WebhookSub,
+		},
+		Diff: models.Diff{
+			Old: old,
+			New: maskWebhook(webhook),
+		},
+		Origin: schema.Dashboard,
+	})
+	logic.ReturnSuccessResponseWithJson(w, r, maskWebhook(webhook), "updated webhook")
+}
+
+// @Summary     Delete a webhook
+// @Router      /api/v1/webhooks/{webhook_id} [delete]
+// @Tags        Webhooks
+// @Security    oauth
+// @Produce     json
+// @Param       webhook_id path string true "Webhook ID"
+// @Success     200 {object} models.SuccessResponse
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook := schema.Webhook{ID: mux.Vars(r)["webhook_id"]}
+	if err := webhook.Get(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("webhook not found"), logic.BadReq))
+		return
+	}
+	if err := webhook.Delete(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.Internal))
+		return
+	}
+	if err := (&schema.WebhookDelivery{WebhookID: webhook.ID}).DeleteByWebhook(db.WithContext(r.Context())); err != nil {
+		logger.Log(0, "failed to delete deliveries of webhook", webhook.ID, err.Error())
+	}
+	logic.LogEvent(&models.Event{
+		Action: schema.Delete,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: schema.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   webhook.ID,
+			Name: webhook.Name,
+			Type: schema.WebhookSub,
+		},
+		Diff: models.Diff{
+			Old: maskWebhook(webhook),
+			New: nil,
+		},
+		Origin: schema.Dashboard,
+	})
+	logic.ReturnSuccessResponse(w, r, "deleted webhook "+webhook.Name)
+}
+
+// @Summary     Send a test event to a webhook
+// @Router      /api/v1/webhooks/{webhook_id}/test [post]
+// @Tags        Webhooks
+// @Security    oauth
+// @Produce     json
+// @Param       webhook_id path string true "Webhook ID"
+// @Success     200 {object} schema.WebhookDelivery
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func testWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook := schema.Webhook{ID: mux.Vars(r)["webhook_id"]}
+	if err := webhook.Get(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("webhook not found"), logic.BadReq))
+		return
+	}
+	delivery, err := proLogic.TestWebhook(&webhook, r.Header.Get("user"))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.Internal))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, delivery, "sent test event to webhook")
+}
+
+// @Summary     List the deliveries of a webhook
+// @Router      /api/v1/webhooks/{webhook_id}/deliveries [get]
+// @Tags        Webhooks
+// @Security    oauth
+// @Produce     json
+// @Param       webhook_id path string true "Webhook ID"
+// @Param       page query int false "Page number"
+// @Param       per_page query int false "Items per page"
+// @Success     200 {array} schema.WebhookDelivery
+// @Failure     500 {object} models.ErrorResponse
+func listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	ctx := db.WithContext(r.Context())
+	deliveries, err := (&schema.WebhookDelivery{WebhookID: mux.Vars(r)["webhook_id"]}).
+		ListByWebhook(db.SetPagination(ctx, page, pageSize))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, logic.Internal))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, deliveries, "fetched webhook deliveries")
+}
@@ -0,0 +1,72 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+
+	"github.com/gravitl/netmaker/schema"
+)
+
+// AlertMail - mail for notifying the recipients of an alert rule
+type AlertMail struct {
+	BodyBuilder EmailBodyBuilder
+	Rule        *schema.AlertRule
+	Alert       *schema.Alert
+}
+
+// SendAlertEmail - sends the fired or resolved alert to the recipients of the rule
+func SendAlertEmail(rule *schema.AlertRule, alert *schema.Alert) error {
+	var sendErr error
+	for _, recipient := range rule.Emails {
+		mail := AlertMail{
+			BodyBuilder: &EmailBodyBuilderWithH1HeadlineAndImage{},
+			Rule:        rule,
+			Alert:       alert,
+		}
+		notification := Notification{
+			RecipientMail: recipient,
+			RecipientName: recipient,
+		}
+		if err := GetClient().SendEmail(context.Background(), notification, mail); err != nil {
+			sendErr = errors.Join(sendErr, fmt.Errorf("%s: %w", recipient, err))
+		}
+	}
+	return sendErr
+}
+
+// GetSubject - gets the subject of the email
+func (mail AlertMail) GetSubject(info Notification) string {
+	if mail.Alert.State == schema.AlertResolved {
+		return fmt.Sprintf("[Resolved] %s: %s", mail.Rule.Name, mail.Alert.SubjectName)
+	}
+	return fmt.Sprintf("[Firing] %s: %s", mail.Rule.Name, mail.Alert.SubjectName)
+}
+
+// GetBody - gets the body of the email
+func (mail AlertMail) GetBody(info Notification) string {
+	headline := "Alert Firing"
+	if mail.Alert.State == schema.AlertResolved {
+		headline = "Alert Resolved"
+	}
+	builder := mail.BodyBuilder.
+		WithHeadline(headline).
+		WithParagraph(html.EscapeString(mail.Alert.Message)).
+		WithParagraph("Alert Details:").
+		WithHtml("<ul>").
+		WithHtml(fmt.Sprintf("<li><strong>Rule:</strong> %s</li>", html.EscapeString(mail.Rule.Name))).
+		WithHtml(fmt.Sprintf("<li><strong>Subject:</strong> %s</li>", html.EscapeString(mail.Alert.SubjectName)))
+	if mail.Alert.Network != "" {
+		builder = builder.WithHtml(fmt.Sprintf("<li><strong>Network:</strong> %s</li>", html.EscapeString(mail.Alert.Network.String())))
+	}
+	builder = builder.WithHtml(fmt.Sprintf("<li><strong>Fired At:</strong> %s</li>", formatUTCTime(mail.Alert.FiredAt)))
+	if mail.Alert.ResolvedAt != nil {
+		builder = builder.WithHtml(fmt.Sprintf("<li><strong>Resolved At:</strong> %s</li>", formatUTCTime(*mail.Alert.ResolvedAt)))
+	}
+	return builder.
+		WithHtml("</ul>").
+		WithParagraph("Best Regards,").
+		WithParagraph("The Netmaker Team").
+		Build()
+}
@@ -0,0 +1,329 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/pro/idp"
+)
+
+const (
+	DefaultUserFilter           = "(objectClass=person)"
+	DefaultGroupFilter          = "(|(objectClass=group)(objectClass=groupOfNames)(objectClass=groupOfUniqueNames))"
+	DefaultUsernameAttribute    = "uid"
+	DefaultGroupMemberAttribute = "member"
+
+	dialTimeout    = 10 * time.Second
+	requestTimeout = 30 * time.Second
+	searchPageSize = 500
+	// adAccountDisabled - ACCOUNTDISABLE flag of the Active Directory
+	// userAccountControl attribute
+	adAccountDisabled = 0x2
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Config - connection and schema of an LDAP directory, empty filters and
+// attributes fall back to the defaults. Active Directory uses
+// sAMAccountName or userPrincipalName as the username attribute.
+type Config struct {
+	URL                  string
+	StartTLS             bool
+	InsecureSkipVerify   bool
+	BindDN               string
+	BindPassword         string
+	BaseDN               string
+	UserFilter           string
+	GroupFilter          string
+	UsernameAttribute    string
+	GroupMemberAttribute string
+}
+
+type Client struct {
+	config Config
+}
+
+func NewLDAPClient(config Config) *Client {
+	if config.UserFilter == "" {
+		config.UserFilter = DefaultUserFilter
+	}
+	if config.GroupFilter == "" {
+		config.GroupFilter = DefaultGroupFilter
+	}
+	if config.UsernameAttribute == "" {
+		config.UsernameAttribute = DefaultUsernameAttribute
+	}
+	if config.GroupMemberAttribute == "" {
+		config.GroupMemberAttribute = DefaultGroupMemberAttribute
+	}
+	config.UserFilter = wrapFilter(config.UserFilter)
+	config.GroupFilter = wrapFilter(config.GroupFilter)
+
+	return &Client{
+		config: config,
+	}
+}
+
+func NewLDAPClientFromSettings() *Client {
+	settings := logic.GetServerSettings()
+
+	return NewLDAPClient(Config{
+		URL:                  settings.LDAPURL,
+		StartTLS:             settings.LDAPStartTLS,
+		InsecureSkipVerify:   settings.LDAPSkipTLSVerify,
+		BindDN:               settings.LDAPBindDN,
+		BindPassword:         settings.LDAPBindPassword,
+		BaseDN:               settings.LDAPBaseDN,
+		UserFilter:           settings.LDAPUserFilter,
+		GroupFilter:          settings.LDAPGroupFilter,
+		UsernameAttribute:    settings.LDAPUsernameAttribute,
+		GroupMemberAttribute: settings.LDAPGroupMemberAttribute,
+	})
+}
+
+func (c *Client) Verify() error {
+	conn, err := c.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Search(goldap.NewSearchRequest(
+		c.config.BaseDN, goldap.ScopeBaseObject, goldap.NeverDerefAliases,
+		0, 0, false, "(objectClass=*)", []string{"dn"}, nil,
+	))
+	if err != nil {
+		return fmt.Errorf("failed to read base dn %s: %w", c.config.BaseDN, err)
+	}
+
+	return nil
+}
+
+func (c *Client) GetUsers(filters []string) ([]idp.User, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entries, err := c.search(conn, andFilter(c.config.UserFilter, buildPrefixFilter(c.config.UsernameAttribute, filters)), c.userAttributes())
+	if err != nil {
+		return nil, err
+	}
+
+	retval := make([]idp.User, 0, len(entries))
+	for _, entry := range entries {
+		user := c.userFromEntry(entry)
+		if user.Username == "" {
+			continue
+		}
+
+		retval = append(retval, user)
+	}
+
+	return retval, nil
+}
+
+func (c *Client) GetGroups(filters []string) ([]idp.Group, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entries, err := c.search(conn, andFilter(c.config.GroupFilter, buildPrefixFilter("cn", filters)), []string{"cn", c.config.GroupMemberAttribute})
+	if err != nil {
+		return nil, err
+	}
+
+	retval := make([]idp.Group, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.GetEqualFoldAttributeValue("cn")
+		if name == "" {
+			continue
+		}
+
+		// members are referenced by dn, which is also the id of the users.
+		var members []string
+		for _, member := range entry.GetEqualFoldAttributeValues(c.config.GroupMemberAttribute) {
+			members = append(members, normalizeDN(member))
+		}
+
+		retval = append(retval, idp.Group{
+			ID:      normalizeDN(entry.DN),
+			Name:    name,
+			Members: members,
+		})
+	}
+
+	return retval, nil
+}
+
+// Authenticate - verifies the password of a user by binding as the user,
+// the user is looked up by the username attribute within the user filter.
+func (c *Client) Authenticate(username, password string) (*idp.User, error) {
+	// a simple bind without a password is an unauthenticated bind, which
+	// most servers accept.
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	filter := andFilter(c.config.UserFilter, fmt.Sprintf("(%s=%s)", c.config.UsernameAttribute, goldap.EscapeFilter(username)))
+	entries, err := c.search(conn, filter, c.userAttributes())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+
+	err = conn.Bind(entries[0].DN, password)
+	if err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	user := c.userFromEntry(entries[0])
+	return &user, nil
+}
+
+// connect - dials the directory and binds as the service account.
+func (c *Client) connect() (*goldap.Conn, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.config.InsecureSkipVerify,
+	}
+	if u, err := url.Parse(c.config.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	conn, err := goldap.DialURL(
+		c.config.URL,
+		goldap.DialWithDialer(&net.Dialer{Timeout: dialTimeout}),
+		goldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(requestTimeout)
+
+	if c.config.StartTLS {
+		err = conn.StartTLS(tlsConfig)
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to start tls: %w", err)
+		}
+	}
+
+	if c.config.BindDN != "" {
+		err = conn.Bind(c.config.BindDN, c.config.BindPassword)
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to bind as %s: %w", c.config.BindDN, err)
+		}
+	}
+
+	return conn, nil
+}
+
+func (c *Client) search(conn *goldap.Conn, filter string, attributes []string) ([]*goldap.Entry, error) {
+	result, err := conn.SearchWithPaging(goldap.NewSearchRequest(
+		c.config.BaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases,
+		0, 0, false, filter, attributes, nil,
+	), searchPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Entries, nil
+}
+
+func (c *Client) userAttributes() []string {
+	return []string{c.config.UsernameAttribute, "displayName", "cn", "givenName", "sn", "userAccountControl"}
+}
+
+func (c *Client) userFromEntry(entry *goldap.Entry) idp.User {
+	displayName := entry.GetEqualFoldAttributeValue("displayName")
+	if displayName == "" {
+		displayName = strings.TrimSpace(entry.GetEqualFoldAttributeValue("givenName") + " " + entry.GetEqualFoldAttributeValue("sn"))
+	}
+
+	accountDisabled := false
+	if uac, err := strconv.ParseInt(entry.GetEqualFoldAttributeValue("userAccountControl"), 10, 64); err == nil {
+		accountDisabled = uac&adAccountDisabled != 0
+	}
+
+	return idp.User{
+		ID:              normalizeDN(entry.DN),
+		Username:        entry.GetEqualFoldAttributeValue(c.config.UsernameAttribute),
+		DisplayName:     displayName,
+		AccountDisabled: accountDisabled,
+		AccountArchived: false,
+	}
+}
+
+// normalizeDN - returns a canonical form of a dn, so that the dn of a user
+// matches its reference in the member attribute of a group.
+func normalizeDN(dn string) string {
+	parsed, err := goldap.ParseDN(dn)
+	if err != nil {
+		return strings.ToLower(dn)
+	}
+
+	return strings.ToLower(parsed.String())
+}
+
+func wrapFilter(filter string) string {
+	filter = strings.TrimSpace(filter)
+	if !strings.HasPrefix(filter, "(") {
+		filter = "(" + filter + ")"
+	}
+
+	return filter
+}
+
+func andFilter(filters ...string) string {
+	var parts []string
+	for _, filter := range filters {
+		if filter != "" {
+			parts = append(parts, filter)
+		}
+	}
+
+	if len(parts) == 1 {
+		return parts[0]
+	}
+
+	return "(&" + strings.Join(parts, "") + ")"
+}
+
+func buildPrefixFilter(attribute string, prefixes []string) string {
+	if len(prefixes) == 0 {
+		return ""
+	}
+
+	var filter strings.Builder
+	filter.WriteString("(|")
+	for _, prefix := range prefixes {
+		filter.WriteString(fmt.Sprintf("(%s=%s*)", attribute, goldap.EscapeFilter(prefix)))
+	}
+	filter.WriteString(")")
+
+	return filter.String()
+}
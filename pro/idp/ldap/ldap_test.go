@@ -0,0 +1,430 @@
+package ldap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/gravitl/netmaker/pro/idp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBaseDN       = "dc=example,dc=com"
+	testBindDN       = "cn=netmaker,ou=services,dc=example,dc=com"
+	testBindPassword = "service-secret"
+)
+
+type testEntry struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+// testServer - minimal in-process LDAP server, it supports simple binds,
+// searches, StartTLS and unbinds.
+type testServer struct {
+	url       string
+	entries   []testEntry
+	tlsConfig *tls.Config
+	// requireTLS rejects binds on plain connections
+	requireTLS bool
+}
+
+func newTestServer(t *testing.T, requireTLS bool) *testServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	s := &testServer{
+		url:        "ldap://" + listener.Addr().String(),
+		entries:    testDirectory(),
+		tlsConfig:  &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}},
+		requireTLS: requireTLS,
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *testServer) config() Config {
+	return Config{
+		URL:                s.url,
+		InsecureSkipVerify: true,
+		BindDN:             testBindDN,
+		BindPassword:       testBindPassword,
+		BaseDN:             testBaseDN,
+	}
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	isTLS := false
+	bound := false
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		msgID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case goldap.ApplicationBindRequest:
+			dn := op.Children[1].Data.String()
+			password := op.Children[2].Data.String()
+			code := uint16(goldap.LDAPResultInvalidCredentials)
+			if s.requireTLS && !isTLS {
+				code = goldap.LDAPResultConfidentialityRequired
+			} else if entry := s.find(dn); entry != nil && entry.password != "" && entry.password == password {
+				code = goldap.LDAPResultSuccess
+			}
+			bound = code == goldap.LDAPResultSuccess
+			s.writeResult(conn, msgID, goldap.ApplicationBindResponse, code)
+		case goldap.ApplicationSearchRequest:
+			if !bound {
+				s.writeResult(conn, msgID, goldap.ApplicationSearchResultDone, goldap.LDAPResultInsufficientAccessRights)
+				continue
+			}
+			baseDN := op.Children[0].Data.String()
+			scope := op.Children[1].Value.(int64)
+			if s.find(baseDN) == nil {
+				s.writeResult(conn, msgID, goldap.ApplicationSearchResultDone, goldap.LDAPResultNoSuchObject)
+				continue
+			}
+			for _, entry := range s.entries {
+				if inScope(entry.dn, baseDN, scope) && matchFilter(op.Children[6], entry) {
+					s.writeEntry(conn, msgID, entry)
+				}
+			}
+			s.writeResult(conn, msgID, goldap.ApplicationSearchResultDone, goldap.LDAPResultSuccess)
+		case goldap.ApplicationExtendedRequest:
+			s.writeResult(conn, msgID, goldap.ApplicationExtendedResponse, goldap.LDAPResultSuccess)
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			isTLS = true
+		default:
+			return
+		}
+	}
+}
+
+func (s *testServer) find(dn string) *testEntry {
+	for i := range s.entries {
+		if strings.EqualFold(s.entries[i].dn, dn) {
+			return &s.entries[i]
+		}
+	}
+
+	return nil
+}
+
+func (s *testServer) writeResult(conn net.Conn, msgID int64, tag ber.Tag, code uint16) {
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	s.write(conn, msgID, response)
+}
+
+func (s *testServer) writeEntry(conn net.Conn, msgID int64, entry testEntry) {
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, goldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "DN"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, values := range entry.attrs {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	response.AppendChild(attributes)
+	s.write(conn, msgID, response)
+}
+
+func (s *testServer) write(conn net.Conn, msgID int64, response *ber.Packet) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgID, "MessageID"))
+	packet.AppendChild(response)
+	_, _ = conn.Write(packet.Bytes())
+}
+
+func inScope(dn, baseDN string, scope int64) bool {
+	dn, baseDN = strings.ToLower(dn), strings.ToLower(baseDN)
+	if scope == goldap.ScopeBaseObject {
+		return dn == baseDN
+	}
+
+	return dn == baseDN || strings.HasSuffix(dn, ","+baseDN)
+}
+
+func attrValues(entry testEntry, name string) []string {
+	for attr, values := range entry.attrs {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+
+	return nil
+}
+
+func matchFilter(filter *ber.Packet, entry testEntry) bool {
+	switch filter.Tag {
+	case goldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matchFilter(child, entry) {
+				return false
+			}
+		}
+		return true
+	case goldap.FilterOr:
+		for _, child := range filter.Children {
+			if matchFilter(child, entry) {
+				return true
+			}
+		}
+		return false
+	case goldap.FilterNot:
+		return !matchFilter(filter.Children[0], entry)
+	case goldap.FilterPresent:
+		return strings.EqualFold(filter.Data.String(), "objectClass") || len(attrValues(entry, filter.Data.String())) > 0
+	case goldap.FilterEqualityMatch:
+		for _, value := range attrValues(entry, filter.Children[0].Data.String()) {
+			if strings.EqualFold(value, filter.Children[1].Data.String()) {
+				return true
+			}
+		}
+		return false
+	case goldap.FilterSubstrings:
+		for _, value := range attrValues(entry, filter.Children[0].Data.String()) {
+			if matchSubstrings(strings.ToLower(value), filter.Children[1].Children) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return false
+}
+
+func matchSubstrings(value string, parts []*ber.Packet) bool {
+	for _, part := range parts {
+		sub := strings.ToLower(part.Data.String())
+		switch part.Tag {
+		case goldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(value, sub) {
+				return false
+			}
+			value = value[len(sub):]
+		case goldap.FilterSubstringsAny:
+			i := strings.Index(value, sub)
+			if i < 0 {
+				return false
+			}
+			value = value[i+len(sub):]
+		case goldap.FilterSubstringsFinal:
+			if !strings.HasSuffix(value, sub) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ldap.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func testDirectory() []testEntry {
+	return []testEntry{
+		{dn: testBaseDN, attrs: map[string][]string{"objectClass": {"domain"}}},
+		{dn: testBindDN, password: testBindPassword, attrs: map[string][]string{"objectClass": {"applicationProcess"}, "cn": {"netmaker"}}},
+		{
+			dn:       "uid=jane,ou=people,dc=example,dc=com",
+			password: "jane-secret",
+			attrs: map[string][]string{
+				"objectClass": {"top", "person", "inetOrgPerson"},
+				"uid":         {"jane"},
+				"cn":          {"Jane Doe"},
+				"displayName": {"Jane Doe"},
+			},
+		},
+		{
+			dn:       "uid=john,ou=people,dc=example,dc=com",
+			password: "john-secret",
+			attrs: map[string][]string{
+				"objectClass": {"top", "person", "inetOrgPerson"},
+				"uid":         {"john"},
+				"givenName":   {"John"},
+				"sn":          {"Smith"},
+			},
+		},
+		{
+			// Active Directory account with ACCOUNTDISABLE set
+			dn:       "uid=bob,ou=people,dc=example,dc=com",
+			password: "bob-secret",
+			attrs: map[string][]string{
+				"objectClass":        {"top", "person"},
+				"uid":                {"bob"},
+				"userAccountControl": {"514"},
+			},
+		},
+		{
+			dn: "cn=devops,ou=groups,dc=example,dc=com",
+			attrs: map[string][]string{
+				"objectClass": {"groupOfNames"},
+				"cn":          {"devops"},
+				"member":      {"UID=Jane,OU=People,DC=example,DC=com", "uid=bob, ou=people, dc=example, dc=com"},
+			},
+		},
+		{
+			dn: "cn=sales,ou=groups,dc=example,dc=com",
+			attrs: map[string][]string{
+				"objectClass": {"groupOfNames"},
+				"cn":          {"sales"},
+				"member":      {"uid=john,ou=people,dc=example,dc=com"},
+			},
+		},
+	}
+}
+
+func TestClient(t *testing.T) {
+	server := newTestServer(t, false)
+	client := NewLDAPClient(server.config())
+
+	require.NoError(t, client.Verify())
+
+	users, err := client.GetUsers(nil)
+	require.NoError(t, err)
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	assert.Equal(t, []idp.User{
+		{ID: "uid=bob,ou=people,dc=example,dc=com", Username: "bob", AccountDisabled: true},
+		{ID: "uid=jane,ou=people,dc=example,dc=com", Username: "jane", DisplayName: "Jane Doe"},
+		{ID: "uid=john,ou=people,dc=example,dc=com", Username: "john", DisplayName: "John Smith"},
+	}, users)
+
+	users, err = client.GetUsers([]string{"ja", "bo"})
+	require.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	groups, err := client.GetGroups([]string{"dev"})
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, "cn=devops,ou=groups,dc=example,dc=com", groups[0].ID)
+	assert.Equal(t, "devops", groups[0].Name)
+	// member dns are normalized to the ids of the users
+	assert.Equal(t, []string{"uid=jane,ou=people,dc=example,dc=com", "uid=bob,ou=people,dc=example,dc=com"}, groups[0].Members)
+
+	groups, err = client.GetGroups(nil)
+	require.NoError(t, err)
+	assert.Len(t, groups, 2)
+}
+
+func TestClientVerify(t *testing.T) {
+	server := newTestServer(t, false)
+
+	config := server.config()
+	config.BindPassword = "wrong"
+	err := NewLDAPClient(config).Verify()
+	assert.True(t, goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials))
+
+	config = server.config()
+	config.BaseDN = "dc=missing,dc=com"
+	err = NewLDAPClient(config).Verify()
+	assert.True(t, goldap.IsErrorWithCode(err, goldap.LDAPResultNoSuchObject))
+}
+
+func TestAuthenticate(t *testing.T) {
+	server := newTestServer(t, false)
+	client := NewLDAPClient(server.config())
+
+	user, err := client.Authenticate("JANE", "jane-secret")
+	require.NoError(t, err)
+	assert.Equal(t, "jane", user.Username)
+	assert.Equal(t, "uid=jane,ou=people,dc=example,dc=com", user.ID)
+
+	_, err = client.Authenticate("jane", "john-secret")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// unauthenticated binds are never attempted
+	_, err = client.Authenticate("jane", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = client.Authenticate("nobody", "secret")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// filter characters in the username are escaped
+	_, err = client.Authenticate("*", "jane-secret")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// the service account is not matched by the user filter
+	_, err = client.Authenticate("netmaker", testBindPassword)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	user, err = client.Authenticate("bob", "bob-secret")
+	require.NoError(t, err)
+	assert.True(t, user.AccountDisabled)
+}
+
+func TestStartTLS(t *testing.T) {
+	server := newTestServer(t, true)
+
+	err := NewLDAPClient(server.config()).Verify()
+	assert.True(t, goldap.IsErrorWithCode(err, goldap.LDAPResultConfidentialityRequired))
+
+	config := server.config()
+	config.StartTLS = true
+	client := NewLDAPClient(config)
+	require.NoError(t, client.Verify())
+
+	user, err := client.Authenticate("john", "john-secret")
+	require.NoError(t, err)
+	assert.Equal(t, "John Smith", user.DisplayName)
+}
+
+func TestFilters(t *testing.T) {
+	assert.Equal(t, "(objectClass=user)", wrapFilter(" objectClass=user "))
+	assert.Equal(t, "(&(objectClass=user)(|(uid=a\\2a*)(uid=b*)))", andFilter(wrapFilter("(objectClass=user)"), buildPrefixFilter("uid", []string{"a*", "b"})))
+	assert.Equal(t, "(objectClass=user)", andFilter("(objectClass=user)", buildPrefixFilter("uid", nil)))
+	assert.Equal(t, "cn=a\\,b,dc=example,dc=com", normalizeDN("CN=a\\,b, DC=Example,DC=com"))
+}
@@ -11,6 +11,7 @@ import (
 	ch "github.com/gravitl/netmaker/clickhouse"
 	controller "github.com/gravitl/netmaker/controllers"
 	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/leader"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
@@ -50,6 +51,9 @@ func InitPro() {
 		proControllers.PostureCheckHandlers,
 		proControllers.JITHandlers,
 		proControllers.ServerHandlers,
+		proControllers.WebhookHandlers,
+		proControllers.ScimHandlers,
+		proControllers.AlertHandlers,
 	)
 	controller.ListRoles = proControllers.ListRoles
 	logic.EnterpriseCheckFuncs = append(logic.EnterpriseCheckFuncs, func(ctx context.Context, wg *sync.WaitGroup) {
@@ -110,31 +114,42 @@ func InitPro() {
 		if servercfg.CacheEnabled() {
 			proLogic.InitAutoRelayCache()
 		}
+		proLogic.RegisterCaches()
 
-		// Only run singleton operations on master pod in HA setup
+		// Singleton operations only run on the leader in HA setup
 		// These include IDP sync, posture checks, JIT expiry, and flow cleanup
-		if servercfg.IsMasterPod() {
+		if leader.IsLeader() {
 			auth.ResetIDPSyncHook()
-			proLogic.AddPostureCheckHook()
-			// Register JIT expiry hook with email notifications
-			addJitExpiryHookWithEmail()
+		}
+		leader.OnChange(auth.OnLeaderChange)
+		proLogic.AddPostureCheckHook()
+		proLogic.AddWebhookHooks()
+		proLogic.AddAuditExportHook()
+		proLogic.AddEventCheckpointHook()
+		proLogic.AddMetricsHistoryHook()
+		proLogic.AddAlertRulesHook()
+		// Register JIT expiry hook with email notifications
+		addJitExpiryHookWithEmail()
 
-			if proLogic.GetFeatureFlags().EnableFlowLogs && logic.GetServerSettings().EnableFlowLogs {
-				err := ch.Initialize()
-				if err != nil {
-					logger.Log(0, "error connecting to clickhouse:", err.Error())
-				}
+		if proLogic.GetFeatureFlags().EnableFlowLogs && logic.GetServerSettings().EnableFlowLogs {
+			err := ch.Initialize()
+			if err != nil {
+				logger.Log(0, "error connecting to clickhouse:", err.Error())
+			}
 
-				proLogic.StartFlowCleanupLoop()
+			// the cleanup hook only runs on the leader, flows are ingested by
+			// every replica since netclients reach them through the load balancer.
+			proLogic.StartFlowCleanupLoop()
+			proLogic.StartFlowIngestServer()
 
-				wg.Add(1)
-				go func(ctx context.Context, wg *sync.WaitGroup) {
-					<-ctx.Done()
-					proLogic.StopFlowCleanupLoop()
-					ch.Close()
-					wg.Done()
-				}(ctx, wg)
-			}
+			wg.Add(1)
+			go func(ctx context.Context, wg *sync.WaitGroup) {
+				<-ctx.Done()
+				proLogic.StopFlowCleanupLoop()
+				proLogic.StopFlowIngestServer()
+				ch.Close()
+				wg.Done()
+			}(ctx, wg)
 		}
 
 		// These can run on all pods
@@ -181,7 +196,13 @@ func InitPro() {
 	logic.ResetAuthProvider = auth.ResetAuthProvider
 	logic.ResetIDPSyncHook = auth.ResetIDPSyncHook
 	logic.SyncFromIDP = auth.SyncFromIDP
+	logic.RegisterJobHandler(logic.IDPSyncJob, func(ctx context.Context, run *logic.JobRun) error {
+		return auth.SyncFromIDP()
+	})
+	logic.IsLDAPLoginEnabled = auth.IsLDAPLoginEnabled
+	logic.VerifyLDAPCredentials = auth.VerifyLDAPCredentials
 	logic.EmailInit = email.Init
+	proLogic.SendAlertEmail = email.SendAlertEmail
 	logic.LogEvent = proLogic.LogEvent
 	logic.RemoveUserFromAclPolicy = proLogic.RemoveUserFromAclPolicy
 	logic.EnsureDefaultUserGroupNetworkPolicies = proLogic.EnsureDefaultUserGroupNetworkPolicies
@@ -189,8 +210,14 @@ func InitPro() {
 	logic.IsUserAllowedToCommunicate = proLogic.IsUserAllowedToCommunicate
 	logic.DeleteAllNetworkTags = proLogic.DeleteAllNetworkTags
 	logic.CreateDefaultTags = proLogic.CreateDefaultTags
+	logic.ListNetworkTags = proLogic.ListNetworkTags
+	logic.UpsertTag = proLogic.UpsertTag
+	logic.DeleteTag = proLogic.DeleteTag
+	logic.CheckIDSyntax = proLogic.CheckIDSyntax
+	logic.ValidatePostureCheck = proLogic.ValidatePostureCheck
 	logic.IsPeerAllowed = proLogic.IsPeerAllowed
 	logic.IsAclPolicyValid = proLogic.IsAclPolicyValid
+	logic.GetUserAclSrcTags = proLogic.GetUserAclSrcTags
 	logic.GetEgressUserRulesForNode = proLogic.GetEgressUserRulesForNode
 	logic.GetTagMapWithNodesByNetwork = proLogic.GetTagMapWithNodesByNetwork
 	logic.GetUserAclRulesForNode = proLogic.GetUserAclRulesForNode
@@ -208,6 +235,8 @@ func InitPro() {
 	logic.GetPostureCheckDeviceInfoByNode = proLogic.GetPostureCheckDeviceInfoByNode
 	logic.StartFlowCleanupLoop = proLogic.StartFlowCleanupLoop
 	logic.StopFlowCleanupLoop = proLogic.StopFlowCleanupLoop
+	logic.StartFlowIngestServer = proLogic.StartFlowIngestServer
+	logic.StopFlowIngestServer = proLogic.StopFlowIngestServer
 	// Expose JIT functions
 	logic.CheckJITAccess = proLogic.CheckJITAccess
 	logic.AssignVirtualRangeToEgress = proLogic.AssignVirtualRangeToEgress
@@ -220,9 +249,10 @@ func addJitExpiryHookWithEmail() {
 	}
 	// Register JIT grant expiry hook with email notifications - runs every 5 minutes
 	logic.HookManagerCh <- models.HookDetails{
-		ID:       "jit-expiry-hook",
-		Hook:     logic.WrapHook(expireJITGrantsWithEmail),
-		Interval: 5 * time.Minute,
+		ID:         "jit-expiry-hook",
+		Hook:       logic.WrapHook(expireJITGrantsWithEmail),
+		Interval:   5 * time.Minute,
+		LeaderOnly: true,
 	}
 }
 
@@ -553,9 +553,31 @@ func listPoliciesOfUser(user *schema.User, netID schema.NetworkID) []models.Acl
 
 		}
 	}
+	logic.SortAclEntrys(userAcls)
 	return userAcls
 }
 
+// GetUserAclSrcTags - returns the username and the expanded user groups
+// that user policies of the network are matched against
+func GetUserAclSrcTags(user *schema.User, netID schema.NetworkID) map[string]struct{} {
+	tags := map[string]struct{}{
+		user.Username: {},
+	}
+	for userG := range user.UserGroups.Data() {
+		tags[userG.String()] = struct{}{}
+	}
+	if _, ok := user.UserGroups.Data()[globalNetworksAdminGroupID]; ok {
+		tags[GetDefaultNetworkAdminGroupID(netID).String()] = struct{}{}
+	}
+	if _, ok := user.UserGroups.Data()[globalNetworksUserGroupID]; ok {
+		tags[GetDefaultNetworkUserGroupID(netID).String()] = struct{}{}
+	}
+	if user.PlatformRoleID == schema.AdminRole || user.PlatformRoleID == schema.SuperAdminRole {
+		tags[GetDefaultNetworkAdminGroupID(netID).String()] = struct{}{}
+	}
+	return tags
+}
+
 // listUserPolicies - lists all user policies in a network
 func listUserPolicies(netID schema.NetworkID) []models.Acl {
 	allAcls := logic.ListAcls()
@@ -565,6 +587,7 @@ func listUserPolicies(netID schema.NetworkID) []models.Acl {
 			deviceAcls = append(deviceAcls, acl)
 		}
 	}
+	logic.SortAclEntrys(deviceAcls)
 	return deviceAcls
 }
 
@@ -603,7 +626,7 @@ func IsUserAllowedToCommunicate(userName string, peer models.Node) (bool, []mode
 	allowedPolicies := []models.Acl{}
 	policies := listPoliciesOfUser(user, schema.NetworkID(peer.Network))
 	for _, policy := range policies {
-		if !policy.Enabled {
+		if !logic.IsAclActive(policy) {
 			continue
 		}
 		dstMap := logic.ConvAclTagToValueMap(policy.Dst)
@@ -618,21 +641,27 @@ func IsUserAllowedToCommunicate(userName string, peer models.Node) (bool, []mode
 				}
 			}
 		}
-		if _, ok := dstMap["*"]; ok {
-			allowedPolicies = append(allowedPolicies, policy)
-			continue
-		}
+		matched := false
 		if _, ok := dstMap[peer.ID.String()]; ok {
-			allowedPolicies = append(allowedPolicies, policy)
-			continue
+			matched = true
 		}
 		for tagID := range peerTags {
 			if _, ok := dstMap[tagID.String()]; ok {
-				allowedPolicies = append(allowedPolicies, policy)
+				matched = true
 				break
 			}
 		}
-
+		if !matched {
+			continue
+		}
+		if logic.IsDenyPolicy(policy) {
+			if logic.IsFullDenyPolicy(policy) {
+				// first match, policies evaluated later do not apply
+				break
+			}
+			continue
+		}
+		allowedPolicies = append(allowedPolicies, policy)
 	}
 	if len(allowedPolicies) > 0 {
 		return true, allowedPolicies
@@ -674,14 +703,14 @@ func IsPeerAllowed(node, peer models.Node, checkDefaultPolicy bool) bool {
 		nodeTags = maps.Clone(node.Tags)
 		node.Mutex.Unlock()
 	} else {
-		nodeTags = node.Tags
+		nodeTags = maps.Clone(node.Tags)
 	}
 	if peer.Mutex != nil {
 		peer.Mutex.Lock()
 		peerTags = maps.Clone(peer.Tags)
 		peer.Mutex.Unlock()
 	} else {
-		peerTags = peer.Tags
+		peerTags = maps.Clone(peer.Tags)
 	}
 	if nodeTags == nil {
 		nodeTags = make(map[models.TagID]struct{})
@@ -691,6 +720,8 @@ func IsPeerAllowed(node, peer models.Node, checkDefaultPolicy bool) bool {
 	}
 	nodeTags[models.TagID(nodeId)] = struct{}{}
 	peerTags[models.TagID(peerId)] = struct{}{}
+	nodeTags["*"] = struct{}{}
+	peerTags["*"] = struct{}{}
 	if checkDefaultPolicy {
 		// check default policy if all allowed return true
 		defaultPolicy, err := logic.GetDefaultPolicy(schema.NetworkID(node.Network), models.DevicePolicy)
@@ -710,7 +741,7 @@ func IsPeerAllowed(node, peer models.Node, checkDefaultPolicy bool) bool {
 		dstMap = nil
 	}()
 	for _, policy := range policies {
-		if !policy.Enabled {
+		if !logic.IsAclActive(policy) {
 			continue
 		}
 
@@ -728,6 +759,13 @@ func IsPeerAllowed(node, peer models.Node, checkDefaultPolicy bool) bool {
 			}
 		}
 		if logic.CheckTagGroupPolicy(srcMap, dstMap, node, peer, nodeTags, peerTags) {
+			// first matching policy decides, unless it only denies part of the traffic
+			if logic.IsDenyPolicy(policy) {
+				if logic.IsFullDenyBetween(policy, nodeId, peerId, nodeTags, peerTags) {
+					return false
+				}
+				continue
+			}
 			return true
 		}
 
@@ -872,7 +910,7 @@ func GetEgressUserRulesForNode(targetnode *models.Node,
 	}
 	if !defaultPolicy.Enabled {
 		for _, acl := range acls {
-			if !acl.Enabled {
+			if !logic.IsAclActive(acl) {
 				continue
 			}
 			dstTags := logic.ConvAclTagToValueMap(acl.Dst)
@@ -934,6 +972,7 @@ func GetEgressUserRulesForNode(targetnode *models.Node,
 			AllowedPorts:    defaultPolicy.Port,
 			Direction:       defaultPolicy.AllowedDirection,
 			Allowed:         true,
+			Priority:        defaultPolicy.Priority,
 		}
 		for _, userNode := range userNodes {
 			if !userNode.StaticNode.Enabled {
@@ -961,7 +1000,7 @@ func GetEgressUserRulesForNode(targetnode *models.Node,
 			}
 			for _, acl := range acls {
 
-				if !acl.Enabled {
+				if !logic.IsAclActive(acl) {
 					continue
 				}
 				r := models.AclRule{
@@ -969,7 +1008,8 @@ func GetEgressUserRulesForNode(targetnode *models.Node,
 					AllowedProtocol: acl.Proto,
 					AllowedPorts:    acl.Port,
 					Direction:       acl.AllowedDirection,
-					Allowed:         true,
+					Allowed:         !logic.IsDenyPolicy(acl),
+					Priority:        acl.Priority,
 				}
 				// Get peers in the tags and add allowed rules
 				if userNode.StaticNode.Address != "" {
@@ -1076,7 +1116,7 @@ func GetUserAclRulesForNode(targetnode *models.Node,
 	targetNodeTags[models.TagID(targetnode.ID.String())] = struct{}{}
 	if !defaultPolicy.Enabled {
 		for _, acl := range acls {
-			if !acl.Enabled {
+			if !logic.IsAclActive(acl) {
 				continue
 			}
 			dstTags := logic.ConvAclTagToValueMap(acl.Dst)
@@ -1129,6 +1169,7 @@ func GetUserAclRulesForNode(targetnode *models.Node,
 			AllowedPorts:    defaultPolicy.Port,
 			Direction:       defaultPolicy.AllowedDirection,
 			Allowed:         true,
+			Priority:        defaultPolicy.Priority,
 		}
 		for _, userNode := range userNodes {
 			if !userNode.StaticNode.Enabled {
@@ -1155,7 +1196,7 @@ func GetUserAclRulesForNode(targetnode *models.Node,
 			}
 			for _, acl := range acls {
 
-				if !acl.Enabled {
+				if !logic.IsAclActive(acl) {
 					continue
 				}
 				egressRanges4 := []net.IPNet{}
@@ -1262,7 +1303,8 @@ func GetUserAclRulesForNode(targetnode *models.Node,
 					Direction:       acl.AllowedDirection,
 					Dst:             []net.IPNet{targetnode.AddressIPNet4()},
 					Dst6:            []net.IPNet{targetnode.AddressIPNet6()},
-					Allowed:         true,
+					Allowed:         !logic.IsDenyPolicy(acl),
+					Priority:        acl.Priority,
 				}
 				if len(egressRanges4) > 0 {
 					r.Dst = append(r.Dst, egressRanges4...)
@@ -1321,7 +1363,7 @@ func CheckIfAnyPolicyisUniDirectional(targetNode models.Node, acls []models.Acl)
 	targetNodeTags[models.TagID(targetNode.ID.String())] = struct{}{}
 	targetNodeTags["*"] = struct{}{}
 	for _, acl := range acls {
-		if !acl.Enabled {
+		if !logic.IsAclActive(acl) {
 			continue
 		}
 		if acl.AllowedDirection == models.TrafficDirectionBi && acl.Proto == models.ALL && acl.ServiceType == models.Any {
@@ -0,0 +1,438 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+)
+
+const alertRulesHookInterval = time.Minute
+
+// SendAlertEmail - notifies the recipients of the rule that an alert fired
+// or resolved, set by the email package to avoid an import cycle
+var SendAlertEmail = func(rule *schema.AlertRule, alert *schema.Alert) error {
+	return nil
+}
+
+// alertCandidate - subject that currently meets the condition of a rule
+type alertCandidate struct {
+	subjectID   string
+	subjectName string
+	network     schema.NetworkID
+	value       float64
+	message     string
+}
+
+// ValidateAlertRule - validates the condition and recipients of a rule
+func ValidateAlertRule(rule *schema.AlertRule) error {
+	if rule.Name == "" {
+		return errors.New("name is required")
+	}
+	switch rule.Type {
+	case schema.NodeOfflineAlert, schema.PeerLatencyAlert:
+		if rule.Threshold <= 0 {
+			return errors.New("threshold must be greater than 0")
+		}
+	case schema.EnrollmentKeyExhaustedAlert:
+		if rule.Threshold < 0 {
+			return errors.New("threshold must not be negative")
+		}
+	case schema.PostureViolationAlert:
+		if rule.Severity <= schema.SeverityUnknown || rule.Severity > schema.SeverityCritical {
+			return errors.New("invalid severity")
+		}
+	case schema.EgressGatewayDownAlert:
+	default:
+		return fmt.Errorf("unknown alert rule type %q", rule.Type)
+	}
+	if rule.Network != "" {
+		if err := (&schema.Network{Name: rule.Network.String()}).Get(db.WithContext(context.TODO())); err != nil {
+			return fmt.Errorf("network %s not found", rule.Network)
+		}
+	}
+	for _, email := range rule.Emails {
+		if _, err := mail.ParseAddress(email); err != nil {
+			return fmt.Errorf("invalid email %s", email)
+		}
+	}
+	return nil
+}
+
+// AddAlertRulesHook - periodically evaluates the alert rules
+func AddAlertRulesHook() {
+	logic.HookManagerCh <- models.HookDetails{
+		ID:         "alert-rules-hook",
+		Hook:       logic.WrapHook(AlertRulesHook),
+		Interval:   alertRulesHookInterval,
+		LeaderOnly: true,
+	}
+}
+
+// AlertRulesHook - evaluates every rule and notifies the alerts that fired
+// or resolved since the last run. Alerts of disabled rules are resolved.
+func AlertRulesHook() error {
+	ctx := db.WithContext(context.TODO())
+	rules, err := (&schema.AlertRule{}).ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	e := newAlertEvaluator()
+	var evalErr error
+	for i := range rules {
+		rule := rules[i]
+		var candidates []alertCandidate
+		if rule.Enabled {
+			candidates, err = e.evaluate(&rule)
+			if err != nil {
+				evalErr = errors.Join(evalErr, fmt.Errorf("rule %s: %w", rule.Name, err))
+				continue
+			}
+		}
+		if err := reconcileAlerts(ctx, &rule, candidates, now); err != nil {
+			evalErr = errors.Join(evalErr, fmt.Errorf("rule %s: %w", rule.Name, err))
+		}
+	}
+
+	retentionPeriod := logic.GetServerSettings().AuditLogsRetentionPeriodInDays
+	if retentionPeriod <= 0 {
+		retentionPeriod = 30
+	}
+	err = (&schema.Alert{}).DeleteResolvedBefore(ctx, now.AddDate(0, 0, -retentionPeriod))
+	return errors.Join(evalErr, err)
+}
+
+// reconcileAlerts - opens an alert for every new candidate and resolves the
+// firing alerts whose subject no longer meets the rule. Subjects that are
+// still firing are not notified again.
+func reconcileAlerts(ctx context.Context, rule *schema.AlertRule, candidates []alertCandidate, now time.Time) error {
+	firing, err := (&schema.Alert{RuleID: rule.ID}).ListFiringByRule(ctx)
+	if err != nil {
+		return err
+	}
+	open := make(map[string]*schema.Alert, len(firing))
+	for i := range firing {
+		open[firing[i].SubjectID] = &firing[i]
+	}
+	for _, c := range candidates {
+		if alert, ok := open[c.subjectID]; ok {
+			delete(open, c.subjectID)
+			alert.SubjectName = c.subjectName
+			alert.Message = c.message
+			alert.Value = c.value
+			alert.LastSeenAt = now
+			if err := alert.Update(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+		alert := &schema.Alert{
+			ID:          uuid.New().String(),
+			RuleID:      rule.ID,
+			RuleName:    rule.Name,
+			Type:        rule.Type,
+			SubjectID:   c.subjectID,
+			SubjectName: c.subjectName,
+			Network:     c.network,
+			Message:     c.message,
+			Value:       c.value,
+			State:       schema.AlertFiring,
+			FiredAt:     now,
+			LastSeenAt:  now,
+		}
+		if err := alert.Create(ctx); err != nil {
+			return err
+		}
+		notifyAlert(rule, alert)
+	}
+	for _, alert := range open {
+		alert.State = schema.AlertResolved
+		alert.ResolvedAt = &now
+		if err := alert.Update(ctx); err != nil {
+			return err
+		}
+		notifyAlert(rule, alert)
+	}
+	return nil
+}
+
+// notifyAlert - emails the recipients of the rule and records an event,
+// which is delivered to the webhooks subscribed to alert actions
+func notifyAlert(rule *schema.AlertRule, alert *schema.Alert) {
+	action := schema.AlertFire
+	if alert.State == schema.AlertResolved {
+		action = schema.AlertResolve
+	}
+	logic.LogEvent(&models.Event{
+		Action: action,
+		Source: models.Subject{
+			ID:   rule.ID,
+			Name: rule.Name,
+			Type: schema.AlertRuleSub,
+		},
+		TriggeredBy: "alerting",
+		Target: models.Subject{
+			ID:   alert.ID,
+			Name: alert.SubjectName,
+			Type: schema.AlertSub,
+			Info: alert,
+		},
+		NetworkID: alert.Network,
+		Origin:    schema.Alerting,
+	})
+	if len(rule.Emails) == 0 {
+		return
+	}
+	if err := SendAlertEmail(rule, alert); err != nil {
+		slog.Error("failed to send alert email", "rule", rule.ID, "alert", alert.ID, "error", err)
+	}
+}
+
+// alertEvaluator - loads the state shared by the rules once per run
+type alertEvaluator struct {
+	nodes           []models.Node
+	nodesLoaded     bool
+	hostNames       map[string]string
+	defaultPolicies map[string]bool
+}
+
+func newAlertEvaluator() *alertEvaluator {
+	return &alertEvaluator{defaultPolicies: make(map[string]bool)}
+}
+
+func (e *alertEvaluator) evaluate(rule *schema.AlertRule) ([]alertCandidate, error) {
+	switch rule.Type {
+	case schema.NodeOfflineAlert:
+		return e.offlineNodes(rule)
+	case schema.PeerLatencyAlert:
+		return e.slowPeers(rule)
+	case schema.PostureViolationAlert:
+		return e.postureViolations(rule)
+	case schema.EgressGatewayDownAlert:
+		return e.downEgressGateways(rule)
+	case schema.EnrollmentKeyExhaustedAlert:
+		return exhaustedEnrollmentKeys(rule)
+	}
+	return nil, fmt.Errorf("unknown alert rule type %q", rule.Type)
+}
+
+// ruleNodes - nodes of the network of the rule, excluding static and
+// deleted nodes
+func (e *alertEvaluator) ruleNodes(rule *schema.AlertRule) ([]models.Node, error) {
+	if !e.nodesLoaded {
+		nodes, err := logic.GetAllNodes()
+		if err != nil {
+			return nil, err
+		}
+		hosts, err := (&schema.Host{}).ListAll(db.WithContext(context.TODO()))
+		if err != nil {
+			return nil, err
+		}
+		e.hostNames = make(map[string]string, len(hosts))
+		for _, host := range hosts {
+			e.hostNames[host.ID.String()] = host.Name
+		}
+		e.nodes = nodes
+		e.nodesLoaded = true
+	}
+	var nodes []models.Node
+	for _, node := range e.nodes {
+		if node.IsStatic || node.PendingDelete {
+			continue
+		}
+		if rule.Network != "" && node.Network != rule.Network.String() {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func (e *alertEvaluator) nodeName(node *models.Node) string {
+	if name, ok := e.hostNames[node.HostID.String()]; ok && name != "" {
+		return name
+	}
+	return node.ID.String()
+}
+
+func (e *alertEvaluator) offlineNodes(rule *schema.AlertRule) ([]alertCandidate, error) {
+	nodes, err := e.ruleNodes(rule)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []alertCandidate
+	for _, node := range nodes {
+		// nodes disconnected by their user are not expected to check in
+		if !node.Connected || node.LastCheckIn.IsZero() {
+			continue
+		}
+		offline := time.Since(node.LastCheckIn).Minutes()
+		if offline <= rule.Threshold {
+			continue
+		}
+		candidates = append(candidates, alertCandidate{
+			subjectID:   node.ID.String(),
+			subjectName: e.nodeName(&node),
+			network:     schema.NetworkID(node.Network),
+			value:       offline,
+			message: fmt.Sprintf("node %s in network %s has not checked in for %d minutes",
+				e.nodeName(&node), node.Network, int(offline)),
+		})
+	}
+	return candidates, nil
+}
+
+func (e *alertEvaluator) slowPeers(rule *schema.AlertRule) ([]alertCandidate, error) {
+	nodes, err := e.ruleNodes(rule)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []alertCandidate
+	for _, node := range nodes {
+		metrics, err := logic.GetMetrics(node.ID.String())
+		if err != nil || metrics == nil {
+			continue
+		}
+		for peerID, metric := range metrics.Connectivity {
+			if !metric.Connected || float64(metric.Latency) <= rule.Threshold {
+				continue
+			}
+			peerName := metric.NodeName
+			if peerName == "" {
+				peerName = peerID
+			}
+			candidates = append(candidates, alertCandidate{
+				subjectID:   node.ID.String() + "/" + peerID,
+				subjectName: e.nodeName(&node) + " -> " + peerName,
+				network:     schema.NetworkID(node.Network),
+				value:       float64(metric.Latency),
+				message: fmt.Sprintf("latency from %s to %s in network %s is %d ms",
+					e.nodeName(&node), peerName, node.Network, metric.Latency),
+			})
+		}
+	}
+	return candidates, nil
+}
+
+func (e *alertEvaluator) postureViolations(rule *schema.AlertRule) ([]alertCandidate, error) {
+	nodes, err := e.ruleNodes(rule)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []alertCandidate
+	for _, node := range nodes {
+		if len(node.PostureChecksViolations) == 0 || node.PostureCheckVolationSeverityLevel < rule.Severity {
+			continue
+		}
+		candidates = append(candidates, alertCandidate{
+			subjectID:   node.ID.String(),
+			subjectName: e.nodeName(&node),
+			network:     schema.NetworkID(node.Network),
+			value:       float64(node.PostureCheckVolationSeverityLevel),
+			message: fmt.Sprintf("node %s in network %s violates %d posture checks",
+				e.nodeName(&node), node.Network, len(node.PostureChecksViolations)),
+		})
+	}
+	return candidates, nil
+}
+
+// downEgressGateways - enabled egresses none of whose routing nodes are online
+func (e *alertEvaluator) downEgressGateways(rule *schema.AlertRule) ([]alertCandidate, error) {
+	nodes, err := e.ruleNodes(rule)
+	if err != nil {
+		return nil, err
+	}
+	nodesByID := make(map[string]models.Node, len(nodes))
+	for _, node := range nodes {
+		nodesByID[node.ID.String()] = node
+	}
+	var egresses []schema.Egress
+	if rule.Network != "" {
+		egresses, err = (&schema.Egress{Network: rule.Network.String()}).ListByNetwork(db.WithContext(context.TODO()))
+	} else {
+		egresses, err = (&schema.Egress{}).List(db.WithContext(context.TODO()))
+	}
+	if err != nil {
+		return nil, err
+	}
+	var candidates []alertCandidate
+	for _, egress := range egresses {
+		if !egress.Status || len(egress.Nodes) == 0 {
+			continue
+		}
+		up := false
+		for nodeID := range egress.Nodes {
+			node, ok := nodesByID[nodeID]
+			if !ok {
+				continue
+			}
+			GetNodeStatus(&node, e.defaultPolicy(node.Network))
+			if node.Status == models.OnlineSt || node.Status == models.WarningSt {
+				up = true
+				break
+			}
+		}
+		if up {
+			continue
+		}
+		candidates = append(candidates, alertCandidate{
+			subjectID:   egress.ID,
+			subjectName: egress.Name,
+			network:     schema.NetworkID(egress.Network),
+			value:       float64(len(egress.Nodes)),
+			message: fmt.Sprintf("none of the %d gateways of egress %s in network %s are online",
+				len(egress.Nodes), egress.Name, egress.Network),
+		})
+	}
+	return candidates, nil
+}
+
+func (e *alertEvaluator) defaultPolicy(network string) bool {
+	enabled, ok := e.defaultPolicies[network]
+	if !ok {
+		policy, _ := logic.GetDefaultPolicy(schema.NetworkID(network), models.DevicePolicy)
+		enabled = policy.Enabled
+		e.defaultPolicies[network] = enabled
+	}
+	return enabled
+}
+
+// exhaustedEnrollmentKeys - limited use keys with at most Threshold uses left
+func exhaustedEnrollmentKeys(rule *schema.AlertRule) ([]alertCandidate, error) {
+	keys, err := logic.GetAllEnrollmentKeys()
+	if err != nil {
+		return nil, err
+	}
+	var candidates []alertCandidate
+	for _, key := range keys {
+		if key.Type != models.Uses || key.Unlimited {
+			continue
+		}
+		if rule.Network != "" && !slices.Contains(key.Networks, rule.Network.String()) {
+			continue
+		}
+		if float64(key.UsesRemaining) > rule.Threshold {
+			continue
+		}
+		name := strings.Join(key.Tags, ",")
+		candidates = append(candidates, alertCandidate{
+			subjectID:   key.Value,
+			subjectName: name,
+			network:     rule.Network,
+			value:       float64(key.UsesRemaining),
+			message:     fmt.Sprintf("enrollment key %s has %d uses left", name, key.UsesRemaining),
+		})
+	}
+	return candidates, nil
+}
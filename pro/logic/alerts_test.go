@@ -0,0 +1,78 @@
+package logic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileAlerts(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	database.InitializeDatabase()
+	t.Cleanup(func() {
+		database.CloseDB()
+		db.CloseDB()
+	})
+	ctx := db.WithContext(context.TODO())
+	require.NoError(t, db.FromContext(ctx).Where("1 = 1").Delete(&schema.Alert{}).Error)
+
+	var notified []schema.AlertState
+	sendAlertEmail := SendAlertEmail
+	SendAlertEmail = func(rule *schema.AlertRule, alert *schema.Alert) error {
+		notified = append(notified, alert.State)
+		return nil
+	}
+	t.Cleanup(func() { SendAlertEmail = sendAlertEmail })
+
+	rule := &schema.AlertRule{ID: "rule-1", Name: "offline", Type: schema.NodeOfflineAlert, Emails: []string{"ops@example.com"}}
+	now := time.Now().UTC()
+	node1 := alertCandidate{subjectID: "node-1", subjectName: "node 1", network: "netx", value: 15}
+	node2 := alertCandidate{subjectID: "node-2", subjectName: "node 2", network: "netx", value: 20}
+
+	require.NoError(t, reconcileAlerts(ctx, rule, []alertCandidate{node1, node2}, now))
+	assert.Equal(t, []schema.AlertState{schema.AlertFiring, schema.AlertFiring}, notified)
+
+	// still firing, not notified again
+	node1.value = 16
+	require.NoError(t, reconcileAlerts(ctx, rule, []alertCandidate{node1, node2}, now.Add(time.Minute)))
+	assert.Len(t, notified, 2)
+
+	// node-2 recovered
+	require.NoError(t, reconcileAlerts(ctx, rule, []alertCandidate{node1}, now.Add(2*time.Minute)))
+	assert.Equal(t, []schema.AlertState{schema.AlertFiring, schema.AlertFiring, schema.AlertResolved}, notified)
+
+	firing, err := (&schema.Alert{State: schema.AlertFiring}).List(ctx)
+	require.NoError(t, err)
+	require.Len(t, firing, 1)
+	assert.Equal(t, "node-1", firing[0].SubjectID)
+	assert.Equal(t, float64(16), firing[0].Value)
+
+	resolved, err := (&schema.Alert{State: schema.AlertResolved}).List(ctx)
+	require.NoError(t, err)
+	require.Len(t, resolved, 1)
+	assert.Equal(t, "node-2", resolved[0].SubjectID)
+	require.NotNil(t, resolved[0].ResolvedAt)
+
+	// node-2 going down again opens a new alert
+	require.NoError(t, reconcileAlerts(ctx, rule, []alertCandidate{node1, node2}, now.Add(3*time.Minute)))
+	assert.Len(t, notified, 4)
+	alerts, err := (&schema.Alert{}).List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, alerts, 3)
+}
+
+func TestValidateAlertRule(t *testing.T) {
+	assert.Error(t, ValidateAlertRule(&schema.AlertRule{Type: schema.NodeOfflineAlert}))
+	assert.Error(t, ValidateAlertRule(&schema.AlertRule{Name: "r", Type: "unknown"}))
+	assert.Error(t, ValidateAlertRule(&schema.AlertRule{Name: "r", Type: schema.PeerLatencyAlert}))
+	assert.Error(t, ValidateAlertRule(&schema.AlertRule{Name: "r", Type: schema.PostureViolationAlert}))
+	assert.Error(t, ValidateAlertRule(&schema.AlertRule{Name: "r", Type: schema.EgressGatewayDownAlert, Emails: []string{"not an email"}}))
+	assert.NoError(t, ValidateAlertRule(&schema.AlertRule{Name: "r", Type: schema.PostureViolationAlert, Severity: schema.SeverityHigh}))
+	assert.NoError(t, ValidateAlertRule(&schema.AlertRule{Name: "r", Type: schema.EnrollmentKeyExhaustedAlert, Emails: []string{"ops@example.com"}}))
+}
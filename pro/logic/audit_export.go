@@ -0,0 +1,317 @@
+package logic
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+const (
+	auditExportCursorKey = "audit_export_cursor"
+	auditExportInterval  = 5 * time.Second
+	auditExportBatchSize = 500
+	// auditExportSettleDelay - events younger than this are left for the next
+	// run, so events recorded by other replicas with a slightly older
+	// timestamp are not skipped
+	auditExportSettleDelay = 2 * time.Second
+	auditExportDialTimeout = 10 * time.Second
+	// syslog facility 13 (log audit), severity 6 (informational)
+	auditExportSyslogPri = 13*8 + 6
+	// private enterprise number used for the structured data of syslog messages
+	auditExportSyslogSDID = "netmaker@32473"
+)
+
+// AuditExportCursor - position of the last exported event
+type AuditExportCursor struct {
+	TimeStamp time.Time `json:"time_stamp"`
+	ID        string    `json:"id"`
+}
+
+// auditExportSink - destination of exported events
+type auditExportSink interface {
+	Write(msg []byte) error
+	Close() error
+}
+
+var (
+	auditExportMutex  sync.Mutex
+	auditExportOut    auditExportSink
+	auditExportOutCfg string
+)
+
+// AddAuditExportHook - starts streaming audit events to the configured destination
+func AddAuditExportHook() {
+	logic.HookManagerCh <- models.HookDetails{
+		ID:         "audit-export-hook",
+		Hook:       logic.WrapHook(AuditExportHook),
+		Interval:   auditExportInterval,
+		LeaderOnly: true,
+	}
+}
+
+// AuditExportHook - exports the events recorded since the persisted cursor.
+// The cursor is only advanced once an event was written, so delivery is at
+// least once across restarts.
+func AuditExportHook() error {
+	auditExportMutex.Lock()
+	defer auditExportMutex.Unlock()
+	settings := logic.GetServerSettings()
+	if !settings.AuditExportEnabled {
+		closeAuditExportSink()
+		return nil
+	}
+	return exportAuditEvents(settings)
+}
+
+func exportAuditEvents(settings models.ServerSettings) error {
+	cfg := fmt.Sprintf("%s|%s|%s", settings.AuditExportFormat, settings.AuditExportTransport, settings.AuditExportTarget)
+	if auditExportOut != nil && auditExportOutCfg != cfg {
+		closeAuditExportSink()
+	}
+	cursor, err := getAuditExportCursor()
+	if err != nil {
+		return err
+	}
+	ctx := db.WithContext(context.TODO())
+	for {
+		events, err := (&schema.Event{}).ListAfter(ctx, cursor.TimeStamp, cursor.ID,
+			time.Now().UTC().Add(-auditExportSettleDelay), auditExportBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		if auditExportOut == nil {
+			out, err := openAuditExportSink(settings.AuditExportTransport, settings.AuditExportTarget)
+			if err != nil {
+				return fmt.Errorf("failed to open audit export destination: %w", err)
+			}
+			auditExportOut, auditExportOutCfg = out, cfg
+		}
+		for _, e := range events {
+			msg, err := formatAuditEvent(&e, settings.AuditExportFormat, settings.AuditExportTransport)
+			if err != nil {
+				slog.Error("failed to format audit event, skipping", "event", e.ID, "error", err)
+			} else if err := auditExportOut.Write(msg); err != nil {
+				closeAuditExportSink()
+				return fmt.Errorf("failed to export audit event: %w", err)
+			}
+			cursor = AuditExportCursor{TimeStamp: e.TimeStamp, ID: e.ID}
+			if err := setAuditExportCursor(cursor); err != nil {
+				return err
+			}
+		}
+		if len(events) < auditExportBatchSize {
+			return nil
+		}
+	}
+}
+
+func getAuditExportCursor() (AuditExportCursor, error) {
+	var cursor AuditExportCursor
+	data, err := database.FetchRecord(database.SERVERCONF_TABLE_NAME, auditExportCursorKey)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return cursor, nil
+		}
+		return cursor, err
+	}
+	err = json.Unmarshal([]byte(data), &cursor)
+	return cursor, err
+}
+
+func setAuditExportCursor(cursor AuditExportCursor) error {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+	return database.Insert(auditExportCursorKey, string(data), database.SERVERCONF_TABLE_NAME)
+}
+
+func closeAuditExportSink() {
+	if auditExportOut != nil {
+		_ = auditExportOut.Close()
+		auditExportOut, auditExportOutCfg = nil, ""
+	}
+}
+
+func openAuditExportSink(transport models.AuditExportTransport, target string) (auditExportSink, error) {
+	switch transport {
+	case models.AuditExportFile:
+		f, err := os.OpenFile(target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		return &auditExportFile{f: f}, nil
+	case models.AuditExportUDP, models.AuditExportTCP:
+		conn, err := net.DialTimeout(string(transport), target, auditExportDialTimeout)
+		if err != nil {
+			return nil, err
+		}
+		return &auditExportConn{conn: conn, stream: transport == models.AuditExportTCP}, nil
+	case models.AuditExportTLS:
+		host, _, _ := net.SplitHostPort(target)
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: auditExportDialTimeout}, "tcp", target,
+			&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+		if err != nil {
+			return nil, err
+		}
+		return &auditExportConn{conn: conn, stream: true}, nil
+	}
+	return nil, fmt.Errorf("unknown audit export transport %q", transport)
+}
+
+// auditExportFile - appends one message per line
+type auditExportFile struct {
+	f *os.File
+}
+
+func (a *auditExportFile) Write(msg []byte) error {
+	if _, err := a.f.Write(append(msg, '\n')); err != nil {
+		return err
+	}
+	return a.f.Sync()
+}
+
+func (a *auditExportFile) Close() error {
+	return a.f.Close()
+}
+
+// auditExportConn - sends one datagram per message, stream transports use
+// octet counting framing (RFC 6587)
+type auditExportConn struct {
+	conn   net.Conn
+	stream bool
+}
+
+func (a *auditExportConn) Write(msg []byte) error {
+	if a.stream {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	_ = a.conn.SetWriteDeadline(time.Now().Add(auditExportDialTimeout))
+	_, err := a.conn.Write(msg)
+	return err
+}
+
+func (a *auditExportConn) Close() error {
+	return a.conn.Close()
+}
+
+// formatAuditEvent - encodes an event, CEF records are wrapped in a syslog
+// header when sent over the network
+func formatAuditEvent(e *schema.Event, format models.AuditExportFormat, transport models.AuditExportTransport) ([]byte, error) {
+	switch format {
+	case models.AuditExportJSON:
+		return json.Marshal(e)
+	case models.AuditExportSyslog:
+		return formatAuditSyslog(e)
+	case models.AuditExportCEF:
+		cef := formatAuditCEF(e)
+		if transport == models.AuditExportFile {
+			return []byte(cef), nil
+		}
+		return []byte(auditSyslogHeader(e) + " - " + cef), nil
+	}
+	return nil, fmt.Errorf("unknown audit export format %q", format)
+}
+
+// auditSyslogHeader - RFC 5424 header with the action as MSGID
+func auditSyslogHeader(e *schema.Event) string {
+	hostname := servercfg.GetServer()
+	if hostname == "" {
+		hostname = "-"
+	}
+	return fmt.Sprintf("<%d>1 %s %s netmaker - %s", auditExportSyslogPri,
+		e.TimeStamp.UTC().Format(time.RFC3339Nano), syslogHeaderValue(hostname, 255), syslogHeaderValue(string(e.Action), 32))
+}
+
+func formatAuditSyslog(e *schema.Event) ([]byte, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	var target models.Subject
+	_ = json.Unmarshal(e.Target, &target)
+	sd := fmt.Sprintf(`[%s id="%s" network="%s" triggered_by="%s" origin="%s" target_type="%s" target="%s"]`,
+		auditExportSyslogSDID, syslogSDValue(e.ID), syslogSDValue(string(e.NetworkID)), syslogSDValue(e.TriggeredBy),
+		syslogSDValue(string(e.Origin)), syslogSDValue(string(target.Type)), syslogSDValue(target.Name))
+	// the BOM marks the message as UTF-8
+	return []byte(auditSyslogHeader(e) + " " + sd + " \xef\xbb\xbf" + string(body)), nil
+}
+
+// syslogHeaderValue - header fields are printable US-ASCII without spaces
+func syslogHeaderValue(v string, max int) string {
+	v = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, v)
+	if len(v) > max {
+		v = v[:max]
+	}
+	return v
+}
+
+var syslogSDEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+func syslogSDValue(v string) string {
+	return syslogSDEscaper.Replace(v)
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+)
+
+// formatAuditCEF - encodes an event as an ArcSight Common Event Format record
+func formatAuditCEF(e *schema.Event) string {
+	var source, target models.Subject
+	_ = json.Unmarshal(e.Source, &source)
+	_ = json.Unmarshal(e.Target, &target)
+	severity := 3
+	switch e.Action {
+	case schema.Delete, schema.DeleteAll, schema.Restore:
+		severity = 7
+	case schema.Create, schema.Update, schema.Login, schema.LogOut:
+		severity = 5
+	}
+	ext := []string{
+		"rt=" + strconv.FormatInt(e.TimeStamp.UnixMilli(), 10),
+		"externalId=" + cefExtensionEscaper.Replace(e.ID),
+		"act=" + cefExtensionEscaper.Replace(string(e.Action)),
+		"suser=" + cefExtensionEscaper.Replace(e.TriggeredBy),
+		"cs1Label=network",
+		"cs1=" + cefExtensionEscaper.Replace(string(e.NetworkID)),
+		"cs2Label=origin",
+		"cs2=" + cefExtensionEscaper.Replace(string(e.Origin)),
+		"cs3Label=sourceType",
+		"cs3=" + cefExtensionEscaper.Replace(string(source.Type)),
+		"cs4Label=targetType",
+		"cs4=" + cefExtensionEscaper.Replace(string(target.Type)),
+		"duid=" + cefExtensionEscaper.Replace(target.ID),
+		"duser=" + cefExtensionEscaper.Replace(target.Name),
+		"msg=" + cefExtensionEscaper.Replace(string(e.Diff)),
+	}
+	return fmt.Sprintf("CEF:0|Netmaker|Netmaker|%s|%s|%s|%d|%s",
+		cefHeaderEscaper.Replace(servercfg.GetVersion()),
+		cefHeaderEscaper.Replace(string(e.Action)+":"+string(target.Type)),
+		cefHeaderEscaper.Replace(strings.ToLower(string(e.Action)+" "+string(target.Type))),
+		severity, strings.Join(ext, " "))
+}
@@ -0,0 +1,209 @@
+package logic
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var syslogRFC5424 = regexp.MustCompile(`^<110>1 \S+ \S+ netmaker - [A-Z_]+ \[netmaker@32473 id="[^"]+" network="[^"]*" .*\] \x{FEFF}\{.*\}$`)
+
+func newAuditTestEvent(t *testing.T, action schema.Action, ts time.Time) schema.Event {
+	source, _ := json.Marshal(models.Subject{ID: "admin", Name: "admin", Type: schema.UserSub})
+	target, _ := json.Marshal(models.Subject{ID: "node-1", Name: "node|1=x", Type: schema.NodeSub})
+	e := schema.Event{
+		ID:          uuid.New().String(),
+		Action:      action,
+		Source:      source,
+		Target:      target,
+		Origin:      schema.Dashboard,
+		NetworkID:   "net1",
+		TriggeredBy: "admin",
+		Diff:        []byte(`{"Old":null,"New":null}`),
+		TimeStamp:   ts.UTC(),
+	}
+	require.NoError(t, e.Create(db.WithContext(context.TODO())))
+	return e
+}
+
+func setupAuditExportTest(t *testing.T) {
+	setupEventsTestDB(t)
+	t.Cleanup(closeAuditExportSink)
+	require.NoError(t, setAuditExportCursor(AuditExportCursor{}))
+}
+
+func TestFormatAuditEvent(t *testing.T) {
+	setupAuditExportTest(t)
+	e := newAuditTestEvent(t, schema.Delete, time.Now().Add(-time.Minute))
+
+	t.Run("syslog", func(t *testing.T) {
+		msg, err := formatAuditEvent(&e, models.AuditExportSyslog, models.AuditExportUDP)
+		require.NoError(t, err)
+		assert.Regexp(t, syslogRFC5424, string(msg))
+	})
+	t.Run("cef", func(t *testing.T) {
+		msg, err := formatAuditEvent(&e, models.AuditExportCEF, models.AuditExportFile)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(msg), "CEF:0|Netmaker|Netmaker|"))
+		assert.Contains(t, string(msg), "|DELETE:NODE|delete node|7|")
+		assert.Contains(t, string(msg), `duser=node|1\=x`)
+		assert.Contains(t, string(msg), "externalId="+e.ID)
+	})
+	t.Run("cef over syslog", func(t *testing.T) {
+		msg, err := formatAuditEvent(&e, models.AuditExportCEF, models.AuditExportTCP)
+		require.NoError(t, err)
+		assert.Regexp(t, `^<110>1 \S+ \S+ netmaker - DELETE - CEF:0\|`, string(msg))
+	})
+	t.Run("json", func(t *testing.T) {
+		msg, err := formatAuditEvent(&e, models.AuditExportJSON, models.AuditExportFile)
+		require.NoError(t, err)
+		var got schema.Event
+		require.NoError(t, json.Unmarshal(msg, &got))
+		assert.Equal(t, e.ID, got.ID)
+	})
+}
+
+func TestAuditExportUDPSyslog(t *testing.T) {
+	setupAuditExportTest(t)
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	settings := models.ServerSettings{
+		AuditExportEnabled:   true,
+		AuditExportFormat:    models.AuditExportSyslog,
+		AuditExportTransport: models.AuditExportUDP,
+		AuditExportTarget:    listener.LocalAddr().String(),
+	}
+	read := func() []string {
+		var msgs []string
+		buf := make([]byte, 65535)
+		for {
+			_ = listener.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+			n, _, err := listener.ReadFrom(buf)
+			if err != nil {
+				return msgs
+			}
+			msgs = append(msgs, string(buf[:n]))
+		}
+	}
+
+	base := time.Now().Add(-time.Minute)
+	var events []schema.Event
+	for i := 0; i < 3; i++ {
+		events = append(events, newAuditTestEvent(t, schema.Create, base.Add(time.Duration(i)*time.Second)))
+	}
+	// not settled yet, exported by a later run
+	recent := newAuditTestEvent(t, schema.Update, time.Now())
+
+	require.NoError(t, exportAuditEvents(settings))
+	msgs := read()
+	require.Len(t, msgs, 3)
+	for i, msg := range msgs {
+		assert.Regexp(t, syslogRFC5424, msg)
+		assert.Contains(t, msg, `id="`+events[i].ID+`"`)
+	}
+	cursor, err := getAuditExportCursor()
+	require.NoError(t, err)
+	assert.Equal(t, events[2].ID, cursor.ID)
+
+	// a restart resumes from the persisted cursor without duplicates
+	closeAuditExportSink()
+	require.NoError(t, exportAuditEvents(settings))
+	assert.Empty(t, read())
+
+	time.Sleep(auditExportSettleDelay)
+	require.NoError(t, exportAuditEvents(settings))
+	msgs = read()
+	require.Len(t, msgs, 1)
+	assert.Contains(t, msgs[0], `id="`+recent.ID+`"`)
+}
+
+func TestAuditExportTCPFraming(t *testing.T) {
+	setupAuditExportTest(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	received := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			// octet counting: MSG-LEN SP SYSLOG-MSG
+			size, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(size))
+			if err != nil {
+				return
+			}
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			received <- string(buf)
+		}
+	}()
+	e1 := newAuditTestEvent(t, schema.Delete, time.Now().Add(-time.Minute))
+	e2 := newAuditTestEvent(t, schema.Create, time.Now().Add(-time.Minute+time.Second))
+	require.NoError(t, exportAuditEvents(models.ServerSettings{
+		AuditExportEnabled:   true,
+		AuditExportFormat:    models.AuditExportCEF,
+		AuditExportTransport: models.AuditExportTCP,
+		AuditExportTarget:    listener.Addr().String(),
+	}))
+	for _, e := range []schema.Event{e1, e2} {
+		select {
+		case msg := <-received:
+			assert.Contains(t, msg, "externalId="+e.ID)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for syslog message")
+		}
+	}
+}
+
+func TestAuditExportFile(t *testing.T) {
+	setupAuditExportTest(t)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	settings := models.ServerSettings{
+		AuditExportEnabled:   true,
+		AuditExportFormat:    models.AuditExportJSON,
+		AuditExportTransport: models.AuditExportFile,
+		AuditExportTarget:    path,
+	}
+	e1 := newAuditTestEvent(t, schema.Delete, time.Now().Add(-time.Minute))
+	require.NoError(t, exportAuditEvents(settings))
+	e2 := newAuditTestEvent(t, schema.Create, time.Now().Add(-30*time.Second))
+	require.NoError(t, exportAuditEvents(settings))
+	closeAuditExportSink()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	for i, e := range []schema.Event{e1, e2} {
+		var got schema.Event
+		require.NoError(t, json.Unmarshal([]byte(lines[i]), &got))
+		assert.Equal(t, e.ID, got.ID)
+	}
+}
@@ -33,6 +33,34 @@ func InitAutoRelayCache() {
 	}
 
 }
+
+// invalidateAutoRelayCache - recomputes the auto relay nodes of a network
+// changed by another replica
+func invalidateAutoRelayCache(network string) {
+	autoRelayCacheMutex.Lock()
+	defer autoRelayCacheMutex.Unlock()
+	delete(autoRelayCache, schema.NetworkID(network))
+	allNodes, err := logic.GetAllNodes()
+	if err != nil {
+		return
+	}
+	for _, node := range logic.GetNetworkNodesMemory(allNodes, network) {
+		if node.IsAutoRelay {
+			autoRelayCache[schema.NetworkID(network)] = append(autoRelayCache[schema.NetworkID(network)], node.ID.String())
+		}
+	}
+}
+
+func reloadAutoRelayCache() {
+	if !servercfg.CacheEnabled() {
+		return
+	}
+	autoRelayCacheMutex.Lock()
+	autoRelayCache = make(map[schema.NetworkID][]string)
+	autoRelayCacheMutex.Unlock()
+	InitAutoRelayCache()
+}
+
 func SetAutoRelay(node *models.Node) {
 	node.IsAutoRelay = true
 }
@@ -129,12 +157,14 @@ func RemoveAutoRelayFromCache(network string) {
 	autoRelayCacheMutex.Lock()
 	defer autoRelayCacheMutex.Unlock()
 	delete(autoRelayCache, schema.NetworkID(network))
+	logic.InvalidateCache(logic.CacheKindAutoRelay, network)
 }
 
 func SetAutoRelayInCache(node models.Node) {
 	autoRelayCacheMutex.Lock()
 	defer autoRelayCacheMutex.Unlock()
 	autoRelayCache[schema.NetworkID(node.Network)] = append(autoRelayCache[schema.NetworkID(node.Network)], node.ID.String())
+	logic.InvalidateCache(logic.CacheKindAutoRelay, node.Network)
 }
 
 // DoesAutoRelayExist - checks if autorelay exists already in the network
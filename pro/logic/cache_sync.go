@@ -0,0 +1,11 @@
+package logic
+
+import "github.com/gravitl/netmaker/logic"
+
+// RegisterCaches - registers the caches of the pro logic package, so they are
+// kept in sync with changes made by other replicas
+func RegisterCaches() {
+	logic.RegisterCache(logic.CacheKindMetrics, logic.CacheHandler{Invalidate: invalidateMetricsCache, Reload: reloadMetricsCache})
+	logic.RegisterCache(logic.CacheKindFailOver, logic.CacheHandler{Invalidate: invalidateFailOverCache, Reload: reloadFailOverCache})
+	logic.RegisterCache(logic.CacheKindAutoRelay, logic.CacheHandler{Invalidate: invalidateAutoRelayCache, Reload: reloadAutoRelayCache})
+}
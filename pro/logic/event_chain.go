@@ -0,0 +1,324 @@
+package logic
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/netclient/ncutils"
+	"github.com/gravitl/netmaker/schema"
+	"golang.org/x/crypto/hkdf"
+	"gorm.io/gorm"
+)
+
+const (
+	eventChainAppendRetries  = 5
+	eventChainVerifyBatch    = 1000
+	eventCheckpointInterval  = time.Hour
+	eventCheckpointKeyInfo   = "netmaker audit checkpoint"
+	eventCheckpointSignedFmt = "netmaker-audit-checkpoint:%d:%s:%s:%d"
+)
+
+// appendEventToChain - links the event to the head of the chain and stores it.
+// The unique sequence number makes concurrent writers retry on a new head.
+func appendEventToChain(e *schema.Event) error {
+	ctx := db.WithContext(context.TODO())
+	// the hash covers the timestamp at the precision every database keeps
+	e.TimeStamp = e.TimeStamp.UTC().Truncate(time.Microsecond)
+	var err error
+	for i := 0; i < eventChainAppendRetries; i++ {
+		var seq int64
+		var prevHash string
+		seq, prevHash, err = getEventChainHead(ctx)
+		if err != nil {
+			return err
+		}
+		seq++
+		e.Seq = &seq
+		e.PrevHash = prevHash
+		if e.Hash, err = eventChainHash(e); err != nil {
+			return err
+		}
+		if err = e.Create(ctx); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// getEventChainHead - sequence number and hash the next event links to. Once
+// every event was pruned the chain continues from the latest checkpoint.
+func getEventChainHead(ctx context.Context) (int64, string, error) {
+	head := &schema.Event{}
+	err := head.GetChainHead(ctx)
+	if err == nil {
+		return *head.Seq, head.Hash, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, "", err
+	}
+	cp := &schema.EventCheckpoint{}
+	err = cp.GetLatest(ctx)
+	if err == nil {
+		return cp.Seq, cp.Hash, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, "", nil
+	}
+	return 0, "", err
+}
+
+// canonicalEventJSON - normalises stored JSON, databases may reorder keys
+// and whitespace of JSON columns
+func canonicalEventJSON(data []byte) (any, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// eventChainHash - hash of the event content and the hash of the previous event
+func eventChainHash(e *schema.Event) (string, error) {
+	var seq int64
+	if e.Seq != nil {
+		seq = *e.Seq
+	}
+	source, err := canonicalEventJSON(e.Source)
+	if err != nil {
+		return "", err
+	}
+	target, err := canonicalEventJSON(e.Target)
+	if err != nil {
+		return "", err
+	}
+	diff, err := canonicalEventJSON(e.Diff)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(struct {
+		Seq         int64  `json:"seq"`
+		ID          string `json:"id"`
+		Action      string `json:"action"`
+		Source      any    `json:"source"`
+		Target      any    `json:"target"`
+		Origin      string `json:"origin"`
+		NetworkID   string `json:"network_id"`
+		TriggeredBy string `json:"triggered_by"`
+		Diff        any    `json:"diff"`
+		TimeStamp   int64  `json:"time_stamp"`
+		PrevHash    string `json:"prev_hash"`
+	}{
+		Seq:         seq,
+		ID:          e.ID,
+		Action:      string(e.Action),
+		Source:      source,
+		Target:      target,
+		Origin:      string(e.Origin),
+		NetworkID:   string(e.NetworkID),
+		TriggeredBy: e.TriggeredBy,
+		Diff:        diff,
+		TimeStamp:   e.TimeStamp.UnixMicro(),
+		PrevHash:    e.PrevHash,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// eventCheckpointKey - ed25519 key derived from the server traffic key
+func eventCheckpointKey() (ed25519.PrivateKey, error) {
+	trafficKey, err := logic.RetrievePrivateTrafficKey()
+	if err != nil {
+		return nil, err
+	}
+	secret := trafficKey
+	if key, err := ncutils.ConvertBytesToKey(trafficKey); err == nil {
+		secret = key[:]
+	}
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte(eventCheckpointKeyInfo)), seed); err != nil {
+		return nil, err
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func eventCheckpointMessage(cp *schema.EventCheckpoint) []byte {
+	return []byte(fmt.Sprintf(eventCheckpointSignedFmt, cp.Seq, cp.EventID, cp.Hash, cp.EventTime.UnixMicro()))
+}
+
+// createEventCheckpoint - signs the chain up to the given event, an existing
+// checkpoint of the event is kept
+func createEventCheckpoint(ctx context.Context, e *schema.Event) (*schema.EventCheckpoint, error) {
+	cp := &schema.EventCheckpoint{Seq: *e.Seq}
+	if err := cp.GetBySeq(ctx); err == nil {
+		return cp, nil
+	}
+	key, err := eventCheckpointKey()
+	if err != nil {
+		return nil, err
+	}
+	cp = &schema.EventCheckpoint{
+		ID:        uuid.New().String(),
+		Seq:       *e.Seq,
+		EventID:   e.ID,
+		Hash:      e.Hash,
+		EventTime: e.TimeStamp.UTC(),
+		CreatedAt: time.Now().UTC(),
+	}
+	cp.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, eventCheckpointMessage(cp)))
+	return cp, cp.Create(ctx)
+}
+
+// EventCheckpointHook - checkpoints the head of the chain
+func EventCheckpointHook() error {
+	ctx := db.WithContext(context.TODO())
+	head := &schema.Event{}
+	if err := head.GetChainHead(ctx); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	_, err := createEventCheckpoint(ctx, head)
+	return err
+}
+
+// AddEventCheckpointHook - periodically signs the head of the event chain
+func AddEventCheckpointHook() {
+	logic.HookManagerCh <- models.HookDetails{
+		ID:         "event-checkpoint-hook",
+		Hook:       logic.WrapHook(EventCheckpointHook),
+		Interval:   eventCheckpointInterval,
+		LeaderOnly: true,
+	}
+}
+
+// pruneEventChain - deletes the chained events recorded before the cutoff.
+// The last pruned event is checkpointed first so the remaining events still
+// link to a signed hash.
+func pruneEventChain(ctx context.Context, cutoff time.Time) error {
+	last := &schema.Event{}
+	if err := last.GetLastChainedBefore(ctx, cutoff); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if _, err := createEventCheckpoint(ctx, last); err != nil {
+		return fmt.Errorf("failed to checkpoint event chain before pruning: %w", err)
+	}
+	return last.DeleteChainedUpTo(ctx, *last.Seq)
+}
+
+// VerifyEventChain - walks the chain and reports the first link that does
+// not match its content, its predecessor or a signed checkpoint
+func VerifyEventChain(ctx context.Context) (models.EventChainVerification, error) {
+	result := models.EventChainVerification{}
+	key, err := eventCheckpointKey()
+	if err != nil {
+		return result, err
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	result.PublicKey = base64.StdEncoding.EncodeToString(pub)
+
+	cps, err := (&schema.EventCheckpoint{}).ListAll(ctx)
+	if err != nil {
+		return result, err
+	}
+	result.Checkpoints = len(cps)
+	checkpoints := make(map[int64]*schema.EventCheckpoint, len(cps))
+	for i := range cps {
+		cp := &cps[i]
+		sig, err := base64.StdEncoding.DecodeString(cp.Signature)
+		if err != nil || !ed25519.Verify(pub, eventCheckpointMessage(cp), sig) {
+			result.Broken = &models.EventChainBreak{Seq: cp.Seq, EventID: cp.EventID, Reason: "checkpoint signature is invalid"}
+			return result, nil
+		}
+		checkpoints[cp.Seq] = cp
+	}
+
+	var prev *schema.Event
+	var lastSeq int64
+	for {
+		events, err := (&schema.Event{}).ListChained(ctx, lastSeq, eventChainVerifyBatch)
+		if err != nil {
+			return result, err
+		}
+		for i := range events {
+			e := &events[i]
+			seq := *e.Seq
+			if prev == nil {
+				result.FirstSeq = seq
+				// the oldest remaining event links to the genesis or to the
+				// checkpoint of the last pruned event
+				if seq == 1 {
+					if e.PrevHash != "" {
+						result.Broken = &models.EventChainBreak{Seq: seq, EventID: e.ID, Reason: "first event does not start the chain"}
+						return result, nil
+					}
+				} else if cp, ok := checkpoints[seq-1]; !ok {
+					result.Broken = &models.EventChainBreak{Seq: seq - 1, Reason: "events were removed without a checkpoint"}
+					return result, nil
+				} else if cp.Hash != e.PrevHash {
+					result.Broken = &models.EventChainBreak{Seq: seq, EventID: e.ID, Reason: "event does not link to the checkpoint"}
+					return result, nil
+				}
+			} else {
+				if seq != *prev.Seq+1 {
+					result.Broken = &models.EventChainBreak{Seq: *prev.Seq + 1, Reason: "event is missing"}
+					return result, nil
+				}
+				if e.PrevHash != prev.Hash {
+					result.Broken = &models.EventChainBreak{Seq: seq, EventID: e.ID, Reason: "event does not link to the previous event"}
+					return result, nil
+				}
+			}
+			hash, err := eventChainHash(e)
+			if err != nil || hash != e.Hash {
+				result.Broken = &models.EventChainBreak{Seq: seq, EventID: e.ID, Reason: "event content does not match its hash"}
+				return result, nil
+			}
+			if cp, ok := checkpoints[seq]; ok && (cp.Hash != e.Hash || cp.EventID != e.ID) {
+				result.Broken = &models.EventChainBreak{Seq: seq, EventID: e.ID, Reason: "event does not match its checkpoint"}
+				return result, nil
+			}
+			prev = e
+			result.Checked++
+			result.LastSeq = seq
+		}
+		if len(events) < eventChainVerifyBatch {
+			break
+		}
+		lastSeq = *events[len(events)-1].Seq
+	}
+	// the tail of the chain up to the latest checkpoint must still exist
+	if len(cps) > 0 {
+		latest := cps[len(cps)-1]
+		if prev == nil {
+			// every event up to the latest checkpoint was pruned
+			result.FirstSeq, result.LastSeq = latest.Seq, latest.Seq
+		} else if latest.Seq > *prev.Seq {
+			result.Broken = &models.EventChainBreak{Seq: latest.Seq, EventID: latest.EventID, Reason: "checkpointed event is missing"}
+			return result, nil
+		}
+	}
+	result.Valid = true
+	return result, nil
+}
@@ -0,0 +1,152 @@
+package logic
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/netclient/ncutils"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// setupEventsTestDB - opens the test database with empty event tables and a server traffic key
+func setupEventsTestDB(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	database.InitializeDatabase()
+	t.Cleanup(func() {
+		database.CloseDB()
+		db.CloseDB()
+	})
+	ctx := db.WithContext(context.TODO())
+	require.NoError(t, db.FromContext(ctx).Where("1 = 1").Delete(&schema.Event{}).Error)
+	require.NoError(t, db.FromContext(ctx).Where("1 = 1").Delete(&schema.EventCheckpoint{}).Error)
+	if _, err := database.FetchRecord(database.SERVER_UUID_TABLE_NAME, database.SERVER_UUID_RECORD_KEY); err != nil {
+		pub, priv, err := box.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		tPriv, _ := ncutils.ConvertKeyToBytes(priv)
+		tPub, _ := ncutils.ConvertKeyToBytes(pub)
+		data, _ := json.Marshal(models.Telemetry{UUID: uuid.NewString(), TrafficKeyPriv: tPriv, TrafficKeyPub: tPub})
+		require.NoError(t, database.Insert(database.SERVER_UUID_RECORD_KEY, string(data), database.SERVER_UUID_TABLE_NAME))
+	}
+}
+
+func appendTestEvents(t *testing.T, n int, start time.Time) []schema.Event {
+	var events []schema.Event
+	for i := 0; i < n; i++ {
+		source, _ := json.Marshal(models.Subject{ID: "admin", Name: "admin", Type: schema.UserSub})
+		target, _ := json.Marshal(models.Subject{ID: "acl", Name: "acl", Type: schema.AclSub})
+		e := schema.Event{
+			ID:          uuid.New().String(),
+			Action:      schema.Update,
+			Source:      source,
+			Target:      target,
+			Origin:      schema.Dashboard,
+			NetworkID:   "net1",
+			TriggeredBy: "admin",
+			Diff:        []byte(`{"Old":{"b":1,"a":"x"},"New":null}`),
+			TimeStamp:   start.Add(time.Duration(i) * time.Second),
+		}
+		require.NoError(t, appendEventToChain(&e))
+		events = append(events, e)
+	}
+	return events
+}
+
+func verifyTestChain(t *testing.T) models.EventChainVerification {
+	result, err := VerifyEventChain(db.WithContext(context.TODO()))
+	require.NoError(t, err)
+	return result
+}
+
+func TestEventChain(t *testing.T) {
+	setupEventsTestDB(t)
+	ctx := db.WithContext(context.TODO())
+	events := appendTestEvents(t, 5, time.Now().Add(-time.Hour))
+	for i, e := range events {
+		assert.Equal(t, int64(i+1), *e.Seq)
+		if i > 0 {
+			assert.Equal(t, events[i-1].Hash, e.PrevHash)
+		}
+	}
+
+	result := verifyTestChain(t)
+	assert.True(t, result.Valid)
+	assert.Equal(t, 5, result.Checked)
+	assert.NotEmpty(t, result.PublicKey)
+
+	t.Run("edited event", func(t *testing.T) {
+		orig := events[2].TriggeredBy
+		require.NoError(t, db.FromContext(ctx).Model(&schema.Event{}).Where("id = ?", events[2].ID).
+			Update("triggered_by", "someone").Error)
+		result := verifyTestChain(t)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.Broken)
+		assert.Equal(t, int64(3), result.Broken.Seq)
+		require.NoError(t, db.FromContext(ctx).Model(&schema.Event{}).Where("id = ?", events[2].ID).
+			Update("triggered_by", orig).Error)
+		assert.True(t, verifyTestChain(t).Valid)
+	})
+	t.Run("deleted event", func(t *testing.T) {
+		require.NoError(t, db.FromContext(ctx).Where("id = ?", events[3].ID).Delete(&schema.Event{}).Error)
+		result := verifyTestChain(t)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.Broken)
+		assert.Equal(t, int64(4), result.Broken.Seq)
+		assert.Equal(t, "event is missing", result.Broken.Reason)
+		require.NoError(t, events[3].Create(ctx))
+	})
+}
+
+func TestEventChainPruning(t *testing.T) {
+	setupEventsTestDB(t)
+	ctx := db.WithContext(context.TODO())
+	old := appendTestEvents(t, 3, time.Now().AddDate(0, 0, -40))
+	recent := appendTestEvents(t, 2, time.Now().Add(-time.Minute))
+
+	require.NoError(t, pruneEventChain(ctx, time.Now().AddDate(0, 0, -30)))
+	remaining, err := (&schema.Event{}).ListChained(ctx, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, remaining, 2)
+	cp := &schema.EventCheckpoint{Seq: *old[2].Seq}
+	require.NoError(t, cp.GetBySeq(ctx))
+	assert.Equal(t, old[2].Hash, cp.Hash)
+
+	result := verifyTestChain(t)
+	assert.True(t, result.Valid)
+	assert.Equal(t, *recent[0].Seq, result.FirstSeq)
+	assert.Equal(t, 2, result.Checked)
+
+	// new events keep extending the chain
+	more := appendTestEvents(t, 1, time.Now())
+	assert.Equal(t, *recent[1].Seq+1, *more[0].Seq)
+	assert.True(t, verifyTestChain(t).Valid)
+
+	t.Run("forged checkpoint", func(t *testing.T) {
+		require.NoError(t, db.FromContext(ctx).Model(&schema.EventCheckpoint{}).Where("seq = ?", cp.Seq).
+			Update("hash", recent[0].PrevHash+"0").Error)
+		result := verifyTestChain(t)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.Broken)
+		assert.Equal(t, "checkpoint signature is invalid", result.Broken.Reason)
+	})
+}
+
+func TestEventChainPrunedWithoutCheckpoint(t *testing.T) {
+	setupEventsTestDB(t)
+	ctx := db.WithContext(context.TODO())
+	events := appendTestEvents(t, 3, time.Now().Add(-time.Hour))
+	require.NoError(t, db.FromContext(ctx).Where("id = ?", events[0].ID).Delete(&schema.Event{}).Error)
+	result := verifyTestChain(t)
+	assert.False(t, result.Valid)
+	require.NotNil(t, result.Broken)
+	assert.Equal(t, int64(1), result.Broken.Seq)
+}
@@ -33,7 +33,11 @@ func EventRententionHook() error {
 	if retentionPeriod <= 0 {
 		retentionPeriod = 30
 	}
-	err := (&schema.Event{}).DeleteOldEvents(db.WithContext(context.TODO()), retentionPeriod)
+	ctx := db.WithContext(context.TODO())
+	if err := pruneEventChain(ctx, time.Now().AddDate(0, 0, -retentionPeriod)); err != nil {
+		slog.Warn("failed to delete old events pas retention period", "error", err)
+	}
+	err := (&schema.Event{}).DeleteOldEvents(ctx, retentionPeriod)
 	if err != nil {
 		slog.Warn("failed to delete old events pas retention period", "error", err)
 	}
@@ -43,9 +47,10 @@ func EventRententionHook() error {
 
 func EventWatcher() {
 	logic.HookManagerCh <- models.HookDetails{
-		ID:       "events-retention-hook",
-		Hook:     logic.WrapHook(EventRententionHook),
-		Interval: time.Hour * 24,
+		ID:         "events-retention-hook",
+		Hook:       logic.WrapHook(EventRententionHook),
+		Interval:   time.Hour * 24,
+		LeaderOnly: true,
 	}
 	for e := range EventActivityCh {
 		if e.Action == schema.Update {
@@ -68,7 +73,11 @@ func EventWatcher() {
 			Diff:        diff,
 			TimeStamp:   time.Now().UTC(),
 		}
-		a.Create(db.WithContext(context.TODO()))
+		if err := appendEventToChain(&a); err != nil {
+			slog.Error("failed to record event", "action", a.Action, "error", err)
+			continue
+		}
+		DispatchWebhooks(&a)
 	}
 
 }
@@ -41,6 +41,31 @@ func InitFailOverCache() {
 	}
 }
 
+// invalidateFailOverCache - recomputes the failover node of a network
+// changed by another replica
+func invalidateFailOverCache(network string) {
+	failOverCacheMutex.Lock()
+	defer failOverCacheMutex.Unlock()
+	delete(failOverCache, schema.NetworkID(network))
+	allNodes, err := logic.GetAllNodes()
+	if err != nil {
+		return
+	}
+	for _, node := range logic.GetNetworkNodesMemory(allNodes, network) {
+		if node.IsFailOver {
+			failOverCache[schema.NetworkID(network)] = node.ID.String()
+			break
+		}
+	}
+}
+
+func reloadFailOverCache() {
+	failOverCacheMutex.Lock()
+	failOverCache = make(map[schema.NetworkID]string)
+	failOverCacheMutex.Unlock()
+	InitFailOverCache()
+}
+
 func CheckFailOverCtx(failOverNode, victimNode, peerNode models.Node) error {
 	failOverCtxMutex.RLock()
 	defer failOverCtxMutex.RUnlock()
@@ -137,12 +162,14 @@ func RemoveFailOverFromCache(network string) {
 	failOverCacheMutex.Lock()
 	defer failOverCacheMutex.Unlock()
 	delete(failOverCache, schema.NetworkID(network))
+	logic.InvalidateCache(logic.CacheKindFailOver, network)
 }
 
 func SetFailOverInCache(node models.Node) {
 	failOverCacheMutex.Lock()
 	defer failOverCacheMutex.Unlock()
 	failOverCache[schema.NetworkID(node.Network)] = node.ID.String()
+	logic.InvalidateCache(logic.CacheKindFailOver, node.Network)
 }
 
 // FailOverExists - checks if failOver exists already in the network
@@ -0,0 +1,253 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	ch "github.com/gravitl/netmaker/clickhouse"
+	"github.com/gravitl/netmaker/grpc/flow"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/servercfg"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxFlowEventsPerEnvelope caps the size of a single batch
+	// so one netclient cannot monopolise a write slot.
+	maxFlowEventsPerEnvelope = 5000
+	// maxConcurrentFlowWrites is the number of batches that may be
+	// written to clickhouse at the same time across all streams.
+	maxConcurrentFlowWrites = 8
+	flowWriteTimeout        = 10 * time.Second
+)
+
+// ErrFlowBackpressure is reported to netclients when all write slots
+// are busy. The batch was not stored and should be retried.
+var ErrFlowBackpressure = errors.New("flow ingestion is saturated, retry later")
+
+var flowIngest struct {
+	mu     sync.Mutex
+	server *grpc.Server
+}
+
+// FlowWriter persists batches of validated flow events.
+type FlowWriter interface {
+	WriteFlows(ctx context.Context, events []*flow.FlowEvent) error
+}
+
+// FlowIngestServer implements flow.FlowServiceServer. Each FlowEnvelope
+// received on a stream is validated against the authenticated host and
+// written as one batch, then acknowledged with a FlowResponse.
+type FlowIngestServer struct {
+	flow.UnimplementedFlowServiceServer
+	writer       FlowWriter
+	verifyToken  func(token string) (hostID string, err error)
+	hostNetworks func(hostID string) []string
+	writeSlots   chan struct{}
+}
+
+// NewFlowIngestServer - creates a flow ingestion server writing to the
+// given writer and authenticating netclients with their host token.
+func NewFlowIngestServer(writer FlowWriter) *FlowIngestServer {
+	return &FlowIngestServer{
+		writer:       writer,
+		verifyToken:  verifyFlowHostToken,
+		hostNetworks: logic.GetHostNetworks,
+		writeSlots:   make(chan struct{}, maxConcurrentFlowWrites),
+	}
+}
+
+// StartFlowIngestServer - starts serving FlowService on the configured
+// flow gRPC port, if it is not already running.
+func StartFlowIngestServer() {
+	flowIngest.mu.Lock()
+	defer flowIngest.mu.Unlock()
+	if flowIngest.server != nil {
+		return
+	}
+
+	port := servercfg.GetFlowGRPCPort()
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		slog.Error("failed to start flow ingestion server", "port", port, "error", err)
+		return
+	}
+
+	srv := grpc.NewServer()
+	flow.RegisterFlowServiceServer(srv, NewFlowIngestServer(ch.FlowWriter{}))
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			slog.Error("flow ingestion server stopped", "error", err)
+		}
+	}()
+	flowIngest.server = srv
+	slog.Info("flow ingestion server started", "port", port)
+}
+
+// StopFlowIngestServer - stops the flow ingestion server and closes
+// all open netclient streams.
+func StopFlowIngestServer() {
+	flowIngest.mu.Lock()
+	defer flowIngest.mu.Unlock()
+	if flowIngest.server == nil {
+		return
+	}
+	// streams are long-lived, so a graceful stop would block until
+	// every netclient disconnects.
+	flowIngest.server.Stop()
+	flowIngest.server = nil
+}
+
+// StreamFlows - receives flow batches from a netclient and acknowledges
+// each of them in order.
+func (s *FlowIngestServer) StreamFlows(stream flow.FlowService_StreamFlowsServer) error {
+	hostID, err := s.authenticate(stream.Context())
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	networks := make(map[string]struct{})
+	for _, network := range s.hostNetworks(hostID) {
+		networks[network] = struct{}{}
+	}
+
+	for {
+		envelope, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		resp := &flow.FlowResponse{Success: true}
+		if err := s.ingest(stream.Context(), hostID, networks, envelope); err != nil {
+			resp = &flow.FlowResponse{Success: false, Error: err.Error()}
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *FlowIngestServer) authenticate(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", errors.New("missing metadata")
+	}
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", errors.New("missing authorization token")
+	}
+	tokenSplit := strings.Split(values[0], " ")
+	if len(tokenSplit) < 2 || tokenSplit[1] == "" {
+		return "", errors.New("malformed authorization token")
+	}
+	return s.verifyToken(tokenSplit[1])
+}
+
+func (s *FlowIngestServer) ingest(ctx context.Context, hostID string, networks map[string]struct{}, envelope *flow.FlowEnvelope) error {
+	events := envelope.GetEvents()
+	if len(events) == 0 {
+		return nil
+	}
+	if len(events) > maxFlowEventsPerEnvelope {
+		return fmt.Errorf("batch of %d events exceeds the limit of %d", len(events), maxFlowEventsPerEnvelope)
+	}
+
+	refreshed := false
+	for i, event := range events {
+		if event.GetNetworkId() != "" {
+			if _, ok := networks[event.GetNetworkId()]; !ok && !refreshed {
+				// the host may have joined a network after the
+				// stream was opened.
+				for _, network := range s.hostNetworks(hostID) {
+					networks[network] = struct{}{}
+				}
+				refreshed = true
+			}
+		}
+		if err := validateFlowEvent(hostID, networks, event); err != nil {
+			return fmt.Errorf("invalid event at index %d: %w", i, err)
+		}
+	}
+
+	select {
+	case s.writeSlots <- struct{}{}:
+		defer func() { <-s.writeSlots }()
+	default:
+		return ErrFlowBackpressure
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, flowWriteTimeout)
+	defer cancel()
+	if err := s.writer.WriteFlows(ctx, events); err != nil {
+		slog.Error("failed to write flows", "host", hostID, "count", len(events), "error", err)
+		return fmt.Errorf("failed to write flows: %w", err)
+	}
+	return nil
+}
+
+// validateFlowEvent checks that the event is well-formed and that it
+// was reported by the authenticated host for one of its networks. An
+// empty host id is filled in with the authenticated host.
+func validateFlowEvent(hostID string, networks map[string]struct{}, event *flow.FlowEvent) error {
+	if event == nil {
+		return errors.New("empty event")
+	}
+	if event.GetFlowId() == "" {
+		return errors.New("flow_id is required")
+	}
+	if event.GetHostId() == "" {
+		event.HostId = hostID
+	} else if event.GetHostId() != hostID {
+		return fmt.Errorf("host_id %s does not match the authenticated host", event.GetHostId())
+	}
+	if _, ok := networks[event.GetNetworkId()]; !ok {
+		return fmt.Errorf("host is not part of network %q", event.GetNetworkId())
+	}
+	switch event.GetType() {
+	case flow.EventType_EVENT_START:
+	case flow.EventType_EVENT_DESTROY:
+		if event.GetEndTsMs() == 0 {
+			return errors.New("end_ts_ms is required for destroy events")
+		}
+	default:
+		return fmt.Errorf("unsupported event type %s", event.GetType())
+	}
+	if event.GetDirection() != flow.Direction_DIR_INGRESS && event.GetDirection() != flow.Direction_DIR_EGRESS {
+		return fmt.Errorf("unsupported direction %s", event.GetDirection())
+	}
+	if event.GetVersion() <= 0 {
+		return errors.New("version must be positive")
+	}
+	if event.GetProtocol() > 0xffff || event.GetSrcPort() > 0xffff || event.GetDstPort() > 0xffff {
+		return errors.New("protocol and ports must fit in 16 bits")
+	}
+	return nil
+}
+
+func verifyFlowHostToken(token string) (string, error) {
+	hostID, _, _, err := logic.VerifyHostToken(token)
+	if err != nil {
+		return "", err
+	}
+	if hostID == logic.MasterUser {
+		return "", errors.New("flows must be reported with a host token")
+	}
+	if _, err := uuid.Parse(hostID); err != nil {
+		return "", fmt.Errorf("invalid host id: %w", err)
+	}
+	return hostID, nil
+}
@@ -0,0 +1,202 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/gravitl/netmaker/grpc/flow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	testFlowHostID = "7b3b4a3e-6c55-4f3d-8f43-3d7c2b0f4a10"
+	testFlowToken  = "host-token"
+)
+
+// memFlowWriter is an in-memory stand-in for the clickhouse writer.
+type memFlowWriter struct {
+	mu      sync.Mutex
+	batches [][]*flow.FlowEvent
+	err     error
+}
+
+func (w *memFlowWriter) WriteFlows(_ context.Context, events []*flow.FlowEvent) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.batches = append(w.batches, events)
+	return nil
+}
+
+func (w *memFlowWriter) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for _, batch := range w.batches {
+		n += len(batch)
+	}
+	return n
+}
+
+func newTestFlowIngestServer(writer FlowWriter) *FlowIngestServer {
+	s := NewFlowIngestServer(writer)
+	s.verifyToken = func(token string) (string, error) {
+		if token != testFlowToken {
+			return "", errors.New("invalid token")
+		}
+		return testFlowHostID, nil
+	}
+	s.hostNetworks = func(string) []string {
+		return []string{"netmaker"}
+	}
+	return s
+}
+
+func dialFlowIngestServer(t *testing.T, s *FlowIngestServer, token string) flow.FlowService_StreamFlowsClient {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	flow.RegisterFlowServiceServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	ctx := context.Background()
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+	stream, err := flow.NewFlowServiceClient(conn).StreamFlows(ctx)
+	require.NoError(t, err)
+	return stream
+}
+
+func testFlowEvent(flowID string) *flow.FlowEvent {
+	return &flow.FlowEvent{
+		Type:      flow.EventType_EVENT_DESTROY,
+		FlowId:    flowID,
+		NetworkId: "netmaker",
+		Protocol:  6,
+		SrcPort:   43512,
+		DstPort:   443,
+		Direction: flow.Direction_DIR_EGRESS,
+		Src:       &flow.FlowParticipant{Ip: "100.64.0.1", Type: flow.ParticipantType_PARTICIPANT_NODE},
+		Dst:       &flow.FlowParticipant{Ip: "100.64.0.2", Type: flow.ParticipantType_PARTICIPANT_NODE},
+		StartTsMs: 1700000000000,
+		EndTsMs:   1700000005000,
+		BytesSent: 1024,
+		Version:   1700000005000,
+	}
+}
+
+func TestStreamFlows(t *testing.T) {
+	t.Run("Unauthenticated", func(t *testing.T) {
+		writer := &memFlowWriter{}
+		stream := dialFlowIngestServer(t, newTestFlowIngestServer(writer), "wrong-token")
+		_, err := stream.Recv()
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		assert.Equal(t, 0, writer.count())
+	})
+	t.Run("MissingToken", func(t *testing.T) {
+		stream := dialFlowIngestServer(t, newTestFlowIngestServer(&memFlowWriter{}), "")
+		_, err := stream.Recv()
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+	t.Run("Accepted", func(t *testing.T) {
+		writer := &memFlowWriter{}
+		stream := dialFlowIngestServer(t, newTestFlowIngestServer(writer), testFlowToken)
+		for i := 0; i < 2; i++ {
+			err := stream.Send(&flow.FlowEnvelope{Events: []*flow.FlowEvent{testFlowEvent("a"), testFlowEvent("b")}})
+			require.NoError(t, err)
+			resp, err := stream.Recv()
+			require.NoError(t, err)
+			assert.True(t, resp.Success)
+			assert.Empty(t, resp.Error)
+		}
+		assert.Equal(t, 4, writer.count())
+		assert.Equal(t, testFlowHostID, writer.batches[0][0].HostId)
+		require.NoError(t, stream.CloseSend())
+	})
+	t.Run("RejectsForeignHost", func(t *testing.T) {
+		writer := &memFlowWriter{}
+		stream := dialFlowIngestServer(t, newTestFlowIngestServer(writer), testFlowToken)
+		event := testFlowEvent("a")
+		event.HostId = "another-host"
+		require.NoError(t, stream.Send(&flow.FlowEnvelope{Events: []*flow.FlowEvent{testFlowEvent("b"), event}}))
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		assert.False(t, resp.Success)
+		assert.Contains(t, resp.Error, "index 1")
+		assert.Equal(t, 0, writer.count())
+	})
+	t.Run("RejectsForeignNetwork", func(t *testing.T) {
+		writer := &memFlowWriter{}
+		stream := dialFlowIngestServer(t, newTestFlowIngestServer(writer), testFlowToken)
+		event := testFlowEvent("a")
+		event.NetworkId = "other"
+		require.NoError(t, stream.Send(&flow.FlowEnvelope{Events: []*flow.FlowEvent{event}}))
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		assert.False(t, resp.Success)
+		assert.Equal(t, 0, writer.count())
+	})
+	t.Run("RejectsMalformedEvent", func(t *testing.T) {
+		writer := &memFlowWriter{}
+		stream := dialFlowIngestServer(t, newTestFlowIngestServer(writer), testFlowToken)
+		event := testFlowEvent("a")
+		event.Direction = flow.Direction_DIR_UNSPECIFIED
+		require.NoError(t, stream.Send(&flow.FlowEnvelope{Events: []*flow.FlowEvent{event}}))
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		assert.False(t, resp.Success)
+		assert.Contains(t, resp.Error, "direction")
+	})
+	t.Run("Backpressure", func(t *testing.T) {
+		writer := &memFlowWriter{}
+		s := newTestFlowIngestServer(writer)
+		s.writeSlots = make(chan struct{}, 1)
+		s.writeSlots <- struct{}{}
+		stream := dialFlowIngestServer(t, s, testFlowToken)
+		require.NoError(t, stream.Send(&flow.FlowEnvelope{Events: []*flow.FlowEvent{testFlowEvent("a")}}))
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		assert.False(t, resp.Success)
+		assert.Equal(t, ErrFlowBackpressure.Error(), resp.Error)
+		assert.Equal(t, 0, writer.count())
+
+		// once the slot frees up the same batch is accepted.
+		<-s.writeSlots
+		require.NoError(t, stream.Send(&flow.FlowEnvelope{Events: []*flow.FlowEvent{testFlowEvent("a")}}))
+		resp, err = stream.Recv()
+		require.NoError(t, err)
+		assert.True(t, resp.Success)
+		assert.Equal(t, 1, writer.count())
+	})
+	t.Run("WriterError", func(t *testing.T) {
+		writer := &memFlowWriter{err: errors.New("clickhouse unavailable")}
+		stream := dialFlowIngestServer(t, newTestFlowIngestServer(writer), testFlowToken)
+		require.NoError(t, stream.Send(&flow.FlowEnvelope{Events: []*flow.FlowEvent{testFlowEvent("a")}}))
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		assert.False(t, resp.Success)
+		assert.Contains(t, resp.Error, "clickhouse unavailable")
+	})
+}
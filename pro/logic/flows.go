@@ -20,9 +20,10 @@ const (
 
 func StartFlowCleanupLoop() {
 	logic.HookManagerCh <- models.HookDetails{
-		ID:       flowsCleanupHookID,
-		Hook:     logic.WrapHook(CleanupFlows),
-		Interval: flowsCleanupHookInterval,
+		ID:         flowsCleanupHookID,
+		Hook:       logic.WrapHook(CleanupFlows),
+		Interval:   flowsCleanupHookInterval,
+		LeaderOnly: true,
 	}
 }
 
@@ -36,19 +37,24 @@ func CleanupFlows() error {
 	if err != nil {
 		return fmt.Errorf("clickhouse connection not available: %w", err)
 	}
+	cutoff := time.Now().AddDate(0, 0, -1*logic.GetServerSettings().AuditLogsRetentionPeriodInDays)
+	return dropPartitionsBefore(ctx, conn, "flows", cutoff)
+}
+
+// dropPartitionsBefore - drops the daily partitions of the table that are
+// older than the cutoff.
+func dropPartitionsBefore(ctx context.Context, conn clickhouse.Conn, table string, cutoff time.Time) error {
 	rows, err := conn.Query(ctx, `
 SELECT DISTINCT parts.partition
 FROM system.parts
-WHERE parts.database = {database:String} AND parts.table = 'flows'
+WHERE parts.database = {database:String} AND parts.table = {table:String}
 ORDER BY parts.partition ASC
-`, clickhouse.Named("database", servercfg.GetClickHouseDB()))
+`, clickhouse.Named("database", servercfg.GetClickHouseDB()), clickhouse.Named("table", table))
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
-	cutoff := time.Now().AddDate(0, 0, -1*logic.GetServerSettings().AuditLogsRetentionPeriodInDays)
-
 	var cleanErr error
 	for rows.Next() {
 		var partitionID string
@@ -67,8 +73,9 @@ ORDER BY parts.partition ASC
 		if partition.Before(cutoff) {
 			err = conn.Exec(
 				ctx,
-				"ALTER TABLE {database:Identifier}.flows DROP partition {partitionID:String}",
+				"ALTER TABLE {database:Identifier}.{table:Identifier} DROP partition {partitionID:String}",
 				clickhouse.Named("database", servercfg.GetClickHouseDB()),
+				clickhouse.Named("table", table),
 				clickhouse.Named("partitionID", partitionID),
 			)
 			if err != nil {
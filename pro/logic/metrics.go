@@ -53,6 +53,29 @@ func deleteNetworkFromCache(key string) {
 	metricsCacheMutex.Unlock()
 }
 
+// invalidateMetricsCache - refetches the metrics of a node changed by another replica
+func invalidateMetricsCache(nodeid string) {
+	if !servercfg.CacheEnabled() {
+		return
+	}
+	deleteNetworkFromCache(nodeid)
+	if _, err := GetMetrics(nodeid); err != nil {
+		slog.Error("failed to refresh metrics cache", "node", nodeid, "error", err)
+	}
+}
+
+func reloadMetricsCache() {
+	if !servercfg.CacheEnabled() {
+		return
+	}
+	metricsCacheMutex.Lock()
+	metricsCacheMap = make(map[string]models.Metrics)
+	metricsCacheMutex.Unlock()
+	if err := LoadNodeMetricsToCache(); err != nil {
+		slog.Error("failed to reload metrics cache", "error", err)
+	}
+}
+
 func LoadNodeMetricsToCache() error {
 	slog.Info("loading metrics to cache")
 	if metricsCacheMap == nil {
@@ -119,6 +142,7 @@ func UpdateMetrics(nodeid string, metrics *models.Metrics) error {
 	if servercfg.CacheEnabled() {
 		storeMetricsInCache(nodeid, *metrics)
 	}
+	logic.InvalidateCache(logic.CacheKindMetrics, nodeid)
 	return nil
 }
 
@@ -131,6 +155,7 @@ func DeleteMetrics(nodeid string) error {
 	if servercfg.CacheEnabled() {
 		deleteNetworkFromCache(nodeid)
 	}
+	logic.InvalidateCache(logic.CacheKindMetrics, nodeid)
 	return nil
 }
 
@@ -148,6 +173,7 @@ func MQUpdateMetricsFallBack(nodeid string, newMetrics models.Metrics) {
 		slog.Error("failed to update node metrics", "id", nodeid, "error", err)
 		return
 	}
+	recordMetricsHistory(&currentNode, &newMetrics)
 	slog.Debug("updated node metrics", "id", nodeid)
 }
 
@@ -178,6 +204,7 @@ func MQUpdateMetrics(client mqtt.Client, msg mqtt.Message) {
 		slog.Error("failed to update node metrics", "id", id, "error", err)
 		return
 	}
+	recordMetricsHistory(&currentNode, &newMetrics)
 	slog.Debug("updated node metrics", "id", id)
 }
 
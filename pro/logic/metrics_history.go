@@ -0,0 +1,322 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	ch "github.com/gravitl/netmaker/clickhouse"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+const (
+	metricsHistoryHookInterval = time.Hour
+	// raw points are rolled up into hourly points after metricsRawRetention,
+	// hourly points into daily points after metricsHourlyRetention
+	metricsRawRetention    = 48 * time.Hour
+	metricsHourlyRetention = 30 * 24 * time.Hour
+	metricsDefaultRange    = 24 * time.Hour
+)
+
+// metricsHistoryStore - persists the metric points reported by the nodes
+type metricsHistoryStore interface {
+	write(ctx context.Context, points []schema.MetricPoint) error
+	// query returns the points of the node in [From, To), downsampled into
+	// buckets of Step
+	query(ctx context.Context, q models.MetricsHistoryQuery) ([]schema.MetricPoint, error)
+	// compact downsamples and drops the points past their retention
+	compact(ctx context.Context, now time.Time) error
+}
+
+// getMetricsHistoryStore - metrics are stored in clickhouse when it is
+// configured, in the server database otherwise
+func getMetricsHistoryStore() metricsHistoryStore {
+	if _, err := ch.FromContext(ch.WithContext(context.TODO())); err == nil {
+		return clickhouseMetricsStore{}
+	}
+	return sqlMetricsStore{}
+}
+
+// AddMetricsHistoryHook - periodically downsamples the metrics history and
+// drops the points past the retention period
+func AddMetricsHistoryHook() {
+	logic.HookManagerCh <- models.HookDetails{
+		ID:         "metrics-history-hook",
+		Hook:       logic.WrapHook(MetricsHistoryHook),
+		Interval:   metricsHistoryHookInterval,
+		LeaderOnly: true,
+	}
+}
+
+// MetricsHistoryHook - compacts the metrics history
+func MetricsHistoryHook() error {
+	return getMetricsHistoryStore().compact(db.WithContext(context.TODO()), time.Now().UTC())
+}
+
+// GetMetricsHistory - gets the metric points of a node over a time range.
+// The last day is returned when no range is given and the step is widened
+// to the resolution the oldest requested points are still kept at.
+func GetMetricsHistory(q models.MetricsHistoryQuery) (models.MetricsHistory, error) {
+	now := time.Now().UTC()
+	if q.To.IsZero() {
+		q.To = now
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-metricsDefaultRange)
+	}
+	if !q.From.Before(q.To) {
+		return models.MetricsHistory{}, errors.New("invalid time range")
+	}
+	if q.Step < 0 {
+		return models.MetricsHistory{}, errors.New("invalid step")
+	}
+	q.From, q.To = q.From.UTC(), q.To.UTC()
+	if minStep := minMetricsStep(now, q.From); q.Step < minStep {
+		q.Step = minStep
+	}
+
+	points, err := getMetricsHistoryStore().query(db.WithContext(context.TODO()), q)
+	if err != nil {
+		return models.MetricsHistory{}, err
+	}
+	if points == nil {
+		points = []schema.MetricPoint{}
+	}
+	return models.MetricsHistory{
+		NodeID: q.NodeID,
+		PeerID: q.PeerID,
+		From:   q.From,
+		To:     q.To,
+		Step:   int64(q.Step / time.Second),
+		Points: points,
+	}, nil
+}
+
+// minMetricsStep - resolution of the points recorded at from
+func minMetricsStep(now, from time.Time) time.Duration {
+	switch {
+	case from.Before(now.Add(-metricsHourlyRetention)):
+		return 24 * time.Hour
+	case from.Before(now.Add(-metricsRawRetention)):
+		return time.Hour
+	default:
+		return 0
+	}
+}
+
+// recordMetricsHistory - stores a point per peer of the reported metrics
+func recordMetricsHistory(node *models.Node, metrics *models.Metrics) {
+	if len(metrics.Connectivity) == 0 {
+		return
+	}
+	ts := metrics.UpdatedAt.UTC()
+	if metrics.UpdatedAt.IsZero() {
+		ts = time.Now().UTC()
+	}
+	points := make([]schema.MetricPoint, 0, len(metrics.Connectivity))
+	for peerID, metric := range metrics.Connectivity {
+		point := schema.MetricPoint{
+			Network:       node.Network,
+			NodeID:        node.ID.String(),
+			PeerID:        peerID,
+			Resolution:    schema.MetricsRawResolution,
+			TimeStamp:     ts,
+			Latency:       metric.Latency,
+			MaxLatency:    metric.Latency,
+			TotalSent:     metric.TotalSent,
+			TotalReceived: metric.TotalReceived,
+			Samples:       1,
+		}
+		if metric.Connected {
+			point.Connected = 1
+		}
+		points = append(points, point)
+	}
+	err := getMetricsHistoryStore().write(db.WithContext(context.TODO()), points)
+	if err != nil {
+		slog.Error("failed to record metrics history", "id", node.ID, "error", err)
+	}
+}
+
+// mergeMetricPoint - aggregates src into dst, weighted by their samples
+func mergeMetricPoint(dst *schema.MetricPoint, src schema.MetricPoint) {
+	samples := dst.Samples + src.Samples
+	if samples > 0 {
+		dst.Latency = (dst.Latency*dst.Samples + src.Latency*src.Samples) / samples
+		dst.Connected = (dst.Connected*float64(dst.Samples) + src.Connected*float64(src.Samples)) / float64(samples)
+	}
+	dst.Samples = samples
+	dst.MaxLatency = max(dst.MaxLatency, src.MaxLatency)
+	dst.TotalSent = max(dst.TotalSent, src.TotalSent)
+	dst.TotalReceived = max(dst.TotalReceived, src.TotalReceived)
+}
+
+// downsampleMetricPoints - aggregates the points of each peer into buckets
+// of step, points must be sorted by time
+func downsampleMetricPoints(points []schema.MetricPoint, step time.Duration) []schema.MetricPoint {
+	if step <= 0 {
+		return points
+	}
+	type bucketKey struct {
+		peerID string
+		ts     time.Time
+	}
+	buckets := make(map[bucketKey]int)
+	var downsampled []schema.MetricPoint
+	for _, point := range points {
+		key := bucketKey{peerID: point.PeerID, ts: point.TimeStamp.UTC().Truncate(step)}
+		if i, ok := buckets[key]; ok {
+			mergeMetricPoint(&downsampled[i], point)
+			continue
+		}
+		point.ID = 0
+		point.TimeStamp = key.ts
+		buckets[key] = len(downsampled)
+		downsampled = append(downsampled, point)
+	}
+	sort.SliceStable(downsampled, func(i, j int) bool {
+		return downsampled[i].TimeStamp.Before(downsampled[j].TimeStamp)
+	})
+	return downsampled
+}
+
+// getMetricsRetention - number of days metrics are kept for
+func getMetricsRetention() int {
+	days := logic.GetServerSettings().MetricsRetentionPeriodInDays
+	if days <= 0 {
+		days = 90
+	}
+	return days
+}
+
+type sqlMetricsStore struct{}
+
+func (sqlMetricsStore) write(ctx context.Context, points []schema.MetricPoint) error {
+	return (&schema.MetricPoint{}).CreateBatch(ctx, points)
+}
+
+func (sqlMetricsStore) query(ctx context.Context, q models.MetricsHistoryQuery) ([]schema.MetricPoint, error) {
+	points, err := (&schema.MetricPoint{NodeID: q.NodeID, PeerID: q.PeerID}).ListByNode(ctx, q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+	return downsampleMetricPoints(points, q.Step), nil
+}
+
+func (sqlMetricsStore) compact(ctx context.Context, now time.Time) error {
+	err := rollupMetricPoints(ctx, schema.MetricsRawResolution, schema.MetricsHourlyResolution, time.Hour, now.Add(-metricsRawRetention))
+	if err != nil {
+		return err
+	}
+	err = rollupMetricPoints(ctx, schema.MetricsHourlyResolution, schema.MetricsDailyResolution, 24*time.Hour, now.Add(-metricsHourlyRetention))
+	if err != nil {
+		return err
+	}
+	return (&schema.MetricPoint{}).DeleteBefore(ctx, now.AddDate(0, 0, -getMetricsRetention()))
+}
+
+// rollupMetricPoints - aggregates the points of the resolution recorded
+// before the cutoff into buckets of the next resolution, oldest bucket first
+func rollupMetricPoints(ctx context.Context, from, to schema.MetricsResolution, bucket time.Duration, cutoff time.Time) error {
+	cutoff = cutoff.UTC().Truncate(bucket)
+	for {
+		oldest := &schema.MetricPoint{Resolution: from}
+		err := oldest.GetOldest(ctx)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		start := oldest.TimeStamp.UTC().Truncate(bucket)
+		if !start.Before(cutoff) {
+			return nil
+		}
+		err = rollupMetricsBucket(ctx, from, to, start, start.Add(bucket))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// rollupMetricsBucket - replaces the points of the resolution in
+// [start, end) with a single point per node pair
+func rollupMetricsBucket(ctx context.Context, from, to schema.MetricsResolution, start, end time.Time) error {
+	dbctx := db.BeginTx(ctx)
+	commit := false
+	defer func() {
+		if commit {
+			db.FromContext(dbctx).Commit()
+		} else {
+			db.FromContext(dbctx).Rollback()
+		}
+	}()
+
+	source := &schema.MetricPoint{Resolution: from}
+	points, err := source.Aggregate(dbctx, start, end)
+	if err != nil {
+		return err
+	}
+	for i := range points {
+		points[i].ID = 0
+		points[i].Resolution = to
+		points[i].TimeStamp = start
+	}
+	err = source.CreateBatch(dbctx, points)
+	if err != nil {
+		return err
+	}
+	err = source.DeleteRange(dbctx, start, end)
+	if err != nil {
+		return err
+	}
+	commit = true
+	return nil
+}
+
+type clickhouseMetricsStore struct{}
+
+func (clickhouseMetricsStore) write(ctx context.Context, points []schema.MetricPoint) error {
+	return ch.MetricsWriter{}.WriteMetrics(ctx, points)
+}
+
+// query - clickhouse keeps the raw points, they are downsampled on read
+func (clickhouseMetricsStore) query(ctx context.Context, q models.MetricsHistoryQuery) ([]schema.MetricPoint, error) {
+	points, err := ch.MetricsWriter{}.QueryMetrics(ctx, q.NodeID, q.PeerID, q.From, q.To, q.Step)
+	if err != nil {
+		return nil, err
+	}
+	if q.Step <= 0 {
+		return points, nil
+	}
+	for i := range points {
+		points[i].Resolution = resolutionForStep(q.Step)
+	}
+	return points, nil
+}
+
+func (clickhouseMetricsStore) compact(ctx context.Context, now time.Time) error {
+	conn, err := ch.FromContext(ch.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	return dropPartitionsBefore(ctx, conn, "metrics", now.AddDate(0, 0, -getMetricsRetention()))
+}
+
+// resolutionForStep - resolution reported for points downsampled on read
+func resolutionForStep(step time.Duration) schema.MetricsResolution {
+	switch {
+	case step >= 24*time.Hour:
+		return schema.MetricsDailyResolution
+	case step >= time.Hour:
+		return schema.MetricsHourlyResolution
+	default:
+		return schema.MetricsRawResolution
+	}
+}
@@ -0,0 +1,97 @@
+package logic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLMetricsStoreCompact(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	database.InitializeDatabase()
+	t.Cleanup(func() {
+		database.CloseDB()
+		db.CloseDB()
+	})
+	ctx := db.WithContext(context.TODO())
+	require.NoError(t, db.FromContext(ctx).Where("1 = 1").Delete(&schema.MetricPoint{}).Error)
+
+	now := time.Date(2026, 6, 1, 12, 30, 0, 0, time.UTC)
+	raw := func(ts time.Time, latency int64, connected bool) schema.MetricPoint {
+		p := schema.MetricPoint{
+			Network:       "netx",
+			NodeID:        "node-1",
+			PeerID:        "peer-1",
+			Resolution:    schema.MetricsRawResolution,
+			TimeStamp:     ts,
+			Latency:       latency,
+			MaxLatency:    latency,
+			TotalSent:     latency * 100,
+			TotalReceived: latency * 10,
+			Samples:       1,
+		}
+		if connected {
+			p.Connected = 1
+		}
+		return p
+	}
+	old := now.Add(-72 * time.Hour).Truncate(time.Hour)
+	store := sqlMetricsStore{}
+	require.NoError(t, store.write(ctx, []schema.MetricPoint{
+		raw(old.Add(5*time.Minute), 10, true),
+		raw(old.Add(10*time.Minute), 20, true),
+		raw(old.Add(15*time.Minute), 30, false),
+		raw(old.Add(65*time.Minute), 40, true),
+		raw(now.Add(-time.Hour), 50, true),
+		raw(now.AddDate(0, 0, -200), 60, true),
+	}))
+
+	require.NoError(t, store.compact(ctx, now))
+
+	points, err := (&schema.MetricPoint{NodeID: "node-1"}).ListByNode(ctx, now.AddDate(-1, 0, 0), now)
+	require.NoError(t, err)
+	require.Len(t, points, 3)
+
+	assert.Equal(t, schema.MetricsHourlyResolution, points[0].Resolution)
+	assert.True(t, points[0].TimeStamp.Equal(old))
+	assert.Equal(t, int64(20), points[0].Latency)
+	assert.Equal(t, int64(30), points[0].MaxLatency)
+	assert.Equal(t, int64(3000), points[0].TotalSent)
+	assert.InDelta(t, 2.0/3.0, points[0].Connected, 0.001)
+	assert.Equal(t, int64(3), points[0].Samples)
+
+	assert.Equal(t, schema.MetricsHourlyResolution, points[1].Resolution)
+	assert.True(t, points[1].TimeStamp.Equal(old.Add(time.Hour)))
+	assert.Equal(t, int64(1), points[1].Samples)
+
+	assert.Equal(t, schema.MetricsRawResolution, points[2].Resolution)
+
+	// downsampling the hourly points on read
+	downsampled, err := store.query(ctx, models.MetricsHistoryQuery{
+		NodeID: "node-1",
+		PeerID: "peer-1",
+		From:   old,
+		To:     old.Add(24 * time.Hour),
+		Step:   24 * time.Hour,
+	})
+	require.NoError(t, err)
+	require.Len(t, downsampled, 1)
+	assert.Equal(t, int64(25), downsampled[0].Latency)
+	assert.Equal(t, int64(40), downsampled[0].MaxLatency)
+	assert.Equal(t, int64(4), downsampled[0].Samples)
+	assert.InDelta(t, 0.75, downsampled[0].Connected, 0.001)
+}
+
+func TestMinMetricsStep(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, time.Duration(0), minMetricsStep(now, now.Add(-time.Hour)))
+	assert.Equal(t, time.Hour, minMetricsStep(now, now.Add(-72*time.Hour)))
+	assert.Equal(t, 24*time.Hour, minMetricsStep(now, now.AddDate(0, 0, -45)))
+}
@@ -12,10 +12,10 @@ import (
 	"github.com/gravitl/netmaker/schema"
 )
 
-func MigrateToGws() {
+func MigrateToGws() error {
 	nodes, err := logic.GetAllNodes()
 	if err != nil {
-		return
+		return err
 	}
 	for _, node := range nodes {
 		if node.IsIngressGateway || node.IsRelay || node.IsInternetGateway || node.IsFailOver {
@@ -28,7 +28,9 @@ func MigrateToGws() {
 			}
 			node.Tags[models.TagID(fmt.Sprintf("%s.%s", node.Network, models.GwTagName))] = struct{}{}
 			delete(node.Tags, models.TagID(fmt.Sprintf("%s.%s", node.Network, models.OldRemoteAccessTagName)))
-			logic.UpsertNode(&node)
+			if err = logic.UpsertNode(&node); err != nil {
+				return err
+			}
 		}
 		// deprecate failover  and initialise auto relay fields
 		if node.IsFailOver {
@@ -36,13 +38,17 @@ func MigrateToGws() {
 			node.FailOverPeers = make(map[string]struct{})
 			node.FailedOverBy = uuid.Nil
 			node.AutoRelayedPeers = make(map[string]string)
-			logic.UpsertNode(&node)
+			if err = logic.UpsertNode(&node); err != nil {
+				return err
+			}
 		}
 		if node.FailedOverBy != uuid.Nil || len(node.FailOverPeers) > 0 {
 			node.FailOverPeers = make(map[string]struct{})
 			node.FailedOverBy = uuid.Nil
 			node.AutoRelayedPeers = make(map[string]string)
-			logic.UpsertNode(&node)
+			if err = logic.UpsertNode(&node); err != nil {
+				return err
+			}
 		}
 		if node.IsInternetGateway && len(node.InetNodeReq.InetNodeClientIDs) > 0 {
 			node.RelayedNodes = append(node.RelayedNodes, node.InetNodeReq.InetNodeClientIDs...)
@@ -52,10 +58,14 @@ func MigrateToGws() {
 				if err == nil {
 					relayedNode.IsRelayed = true
 					relayedNode.RelayedBy = node.ID.String()
-					logic.UpsertNode(&relayedNode)
+					if err = logic.UpsertNode(&relayedNode); err != nil {
+						return err
+					}
 				}
 			}
-			logic.UpsertNode(&node)
+			if err = logic.UpsertNode(&node); err != nil {
+				return err
+			}
 		}
 	}
 	acls := logic.ListAcls()
@@ -76,11 +86,18 @@ func MigrateToGws() {
 			}
 		}
 		if upsert {
-			logic.UpsertAcl(acl)
+			if err = logic.UpsertAcl(acl); err != nil {
+				return err
+			}
 		}
 	}
-	nets, _ := (&schema.Network{}).ListAll(db.WithContext(context.TODO()))
+	nets, err := (&schema.Network{}).ListAll(db.WithContext(context.TODO()))
+	if err != nil {
+		return err
+	}
 	for _, netI := range nets {
-		DeleteTag(models.TagID(fmt.Sprintf("%s.%s", netI.Name, models.OldRemoteAccessTagName)), true)
+		// the tag is gone once it was deleted before
+		_ = DeleteTag(models.TagID(fmt.Sprintf("%s.%s", netI.Name, models.OldRemoteAccessTagName)), true)
 	}
+	return nil
 }
@@ -28,8 +28,9 @@ func AddPostureCheckHook() {
 		interval = time.Minute * time.Duration(i)
 	}
 	logic.HookManagerCh <- models.HookDetails{
-		Hook:     logic.WrapHook(RunPostureChecks),
-		Interval: interval,
+		Hook:       logic.WrapHook(RunPostureChecks),
+		Interval:   interval,
+		LeaderOnly: true,
 	}
 }
 func RemoveTagFromPostureChecks(tagID models.TagID, netID schema.NetworkID) {
@@ -0,0 +1,109 @@
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// samlSPKeyDBKey - key of the saml service provider key pair record in the
+// server settings table
+const samlSPKeyDBKey = "saml_sp_key"
+
+// samlSPKeyPair - pem encoded key pair the service provider signs its
+// requests with, the certificate is published in the sp metadata
+type samlSPKeyPair struct {
+	Key         string `json:"key"`
+	Certificate string `json:"certificate"`
+}
+
+// GetSAMLServiceProviderKeyPair - returns the key pair of the saml service
+// provider, it is generated on first use and kept so that the metadata
+// registered with the IDP stays valid across restarts and replicas.
+func GetSAMLServiceProviderKeyPair() (*rsa.PrivateKey, *x509.Certificate, error) {
+	data, err := database.FetchRecord(database.SERVER_SETTINGS, samlSPKeyDBKey)
+	if err != nil {
+		if !database.IsEmptyRecord(err) {
+			return nil, nil, err
+		}
+
+		data, err = createSAMLServiceProviderKeyPair()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var pair samlSPKeyPair
+	err = json.Unmarshal([]byte(data), &pair)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyBlock, _ := pem.Decode([]byte(pair.Key))
+	certBlock, _ := pem.Decode([]byte(pair.Certificate))
+	if keyBlock == nil || certBlock == nil {
+		return nil, nil, errors.New("invalid saml service provider key pair")
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, cert, nil
+}
+
+func createSAMLServiceProviderKeyPair() (string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: servercfg.GetAPIHost()},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(samlSPKeyPair{
+		Key:         string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	err = database.Insert(samlSPKeyDBKey, string(data), database.SERVER_SETTINGS)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,874 @@
+package logic
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"github.com/gravitl/netmaker/schema"
+	"golang.org/x/exp/slog"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// scimTokenDBKey - key of the SCIM token record in the server settings table
+const scimTokenDBKey = "scim_token"
+
+// scimTokenPrefix - makes SCIM tokens recognisable, e.g. by secret scanners
+const scimTokenPrefix = "nmscim_"
+
+// scimToken - stored SCIM bearer token, only its hash is kept
+type scimToken struct {
+	Hash      string    `json:"hash"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ScimRequestError - error of a SCIM request, returned to the IDP in the
+// SCIM error format
+type ScimRequestError struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *ScimRequestError) Error() string {
+	return e.Detail
+}
+
+func scimError(status int, scimType, format string, args ...any) error {
+	return &ScimRequestError{Status: status, ScimType: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+func scimNotFound(resource, id string) error {
+	return scimError(http.StatusNotFound, "", "%s %s not found", resource, id)
+}
+
+func hashScimToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func getScimToken() (scimToken, error) {
+	var t scimToken
+	data, err := database.FetchRecord(database.SERVER_SETTINGS, scimTokenDBKey)
+	if err != nil {
+		return t, err
+	}
+	err = json.Unmarshal([]byte(data), &t)
+	return t, err
+}
+
+// CreateScimToken - generates the bearer token used by the IDP to provision
+// users and groups, replacing the previous token
+func CreateScimToken(createdBy string) (models.ScimTokenInfo, error) {
+	token := scimTokenPrefix + logic.RandomString(48)
+	t := scimToken{
+		Hash:      hashScimToken(token),
+		CreatedBy: createdBy,
+		CreatedAt: time.Now().UTC(),
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return models.ScimTokenInfo{}, err
+	}
+	if err := database.Insert(scimTokenDBKey, string(data), database.SERVER_SETTINGS); err != nil {
+		return models.ScimTokenInfo{}, err
+	}
+	return models.ScimTokenInfo{
+		Enabled:   true,
+		Token:     token,
+		CreatedBy: t.CreatedBy,
+		CreatedAt: t.CreatedAt,
+	}, nil
+}
+
+// GetScimTokenInfo - returns whether SCIM provisioning is enabled
+func GetScimTokenInfo() models.ScimTokenInfo {
+	t, err := getScimToken()
+	if err != nil {
+		return models.ScimTokenInfo{}
+	}
+	return models.ScimTokenInfo{
+		Enabled:   true,
+		CreatedBy: t.CreatedBy,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+// DeleteScimToken - revokes the SCIM token, disabling SCIM provisioning
+func DeleteScimToken() error {
+	err := database.DeleteRecord(database.SERVER_SETTINGS, scimTokenDBKey)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	return nil
+}
+
+// IsScimEnabled - checks if a SCIM token has been created
+func IsScimEnabled() bool {
+	_, err := getScimToken()
+	return err == nil
+}
+
+// ValidateScimToken - checks a bearer token presented to the SCIM api
+func ValidateScimToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	t, err := getScimToken()
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashScimToken(token)), []byte(t.Hash)) == 1
+}
+
+// PaginateScim - returns the page of resources requested with the 1-based
+// startIndex and count query parameters
+func PaginateScim[T any](resources []T, startIndex, count int) models.ScimListResponse {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	total := len(resources)
+	page := []T{}
+	if startIndex <= total && count != 0 {
+		end := total
+		if count > 0 && startIndex-1+count < total {
+			end = startIndex - 1 + count
+		}
+		page = resources[startIndex-1 : end]
+	}
+	return models.ScimListResponse{
+		Schemas:      []string{models.ScimListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(page),
+		Resources:    page,
+	}
+}
+
+// ScimUserFromSchema - converts a user to its SCIM representation
+func ScimUserFromSchema(u *schema.User, groups map[schema.UserGroupID]schema.UserGroup) models.ScimUser {
+	active := !u.AccountDisabled
+	su := models.ScimUser{
+		Schemas:     []string{models.ScimUserSchema},
+		ID:          u.ID,
+		ExternalID:  u.ExternalIdentityProviderID,
+		UserName:    u.Username,
+		DisplayName: u.DisplayName,
+		Active:      &active,
+		Meta: &models.ScimMeta{
+			ResourceType: "User",
+			Created:      scimTime(u.CreatedAt),
+			LastModified: scimTime(u.UpdatedAt),
+		},
+	}
+	if u.DisplayName != "" {
+		su.Name = &models.ScimName{Formatted: u.DisplayName}
+	}
+	if strings.Contains(u.Username, "@") {
+		su.Emails = []models.ScimMultiValue{{Value: u.Username, Primary: true}}
+	}
+	for gID := range u.UserGroups.Data() {
+		g, ok := groups[gID]
+		if !ok || g.Default {
+			continue
+		}
+		su.Groups = append(su.Groups, models.ScimMultiValue{Value: gID.String(), Display: g.Name})
+	}
+	sort.Slice(su.Groups, func(i, j int) bool { return su.Groups[i].Value < su.Groups[j].Value })
+	return su
+}
+
+// ScimGroupFromSchema - converts a user group to its SCIM representation
+func ScimGroupFromSchema(g *schema.UserGroup, users []schema.User) models.ScimGroup {
+	sg := models.ScimGroup{
+		Schemas:     []string{models.ScimGroupSchema},
+		ID:          g.ID.String(),
+		ExternalID:  g.ExternalIdentityProviderID,
+		DisplayName: g.Name,
+		Meta: &models.ScimMeta{
+			ResourceType: "Group",
+			Created:      scimTime(g.CreatedAt),
+			LastModified: scimTime(g.UpdatedAt),
+		},
+	}
+	for _, u := range users {
+		if _, ok := u.UserGroups.Data()[g.ID]; ok {
+			sg.Members = append(sg.Members, models.ScimMultiValue{Value: u.ID, Display: u.Username})
+		}
+	}
+	return sg
+}
+
+func scimTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func scimUserAttrs(u models.ScimUser) map[string][]string {
+	attrs := map[string][]string{
+		"id":          {u.ID},
+		"username":    {u.UserName},
+		"externalid":  {u.ExternalID},
+		"displayname": {u.DisplayName},
+		"active":      {fmt.Sprint(u.Active != nil && *u.Active)},
+	}
+	if u.Name != nil {
+		attrs["name.formatted"] = []string{u.Name.Formatted}
+	}
+	for _, e := range u.Emails {
+		attrs["emails"] = append(attrs["emails"], e.Value)
+		attrs["emails.value"] = append(attrs["emails.value"], e.Value)
+	}
+	for _, g := range u.Groups {
+		attrs["groups"] = append(attrs["groups"], g.Value)
+		attrs["groups.value"] = append(attrs["groups.value"], g.Value)
+	}
+	if u.Meta != nil && u.Meta.LastModified != nil {
+		attrs["meta.lastmodified"] = []string{u.Meta.LastModified.Format(time.RFC3339)}
+	}
+	return attrs
+}
+
+func scimGroupAttrs(g models.ScimGroup) map[string][]string {
+	attrs := map[string][]string{
+		"id":          {g.ID},
+		"externalid":  {g.ExternalID},
+		"displayname": {g.DisplayName},
+	}
+	for _, m := range g.Members {
+		attrs["members"] = append(attrs["members"], m.Value)
+		attrs["members.value"] = append(attrs["members.value"], m.Value)
+	}
+	if g.Meta != nil && g.Meta.LastModified != nil {
+		attrs["meta.lastmodified"] = []string{g.Meta.LastModified.Format(time.RFC3339)}
+	}
+	return attrs
+}
+
+// isScimUser - checks if the user came from the IDP. Local users are not
+// exposed over SCIM so that the IDP cannot disable or delete them.
+func isScimUser(user *schema.User) bool {
+	return user.AuthType == schema.OAuth || user.ExternalIdentityProviderID != ""
+}
+
+// isScimGroup - checks if the group was created by SCIM or linked to the
+// IDP. Local groups are not exposed so that the IDP cannot change who gets
+// their network roles.
+func isScimGroup(group *schema.UserGroup) bool {
+	return !group.Default && group.ExternalIdentityProviderID != ""
+}
+
+// listScimUsers - users managed through SCIM
+func listScimUsers() ([]schema.User, error) {
+	users, err := (&schema.User{}).ListAll(db.WithContext(context.TODO()))
+	if err != nil {
+		return nil, err
+	}
+	scimUsers := make([]schema.User, 0, len(users))
+	for i := range users {
+		if isScimUser(&users[i]) {
+			scimUsers = append(scimUsers, users[i])
+		}
+	}
+	return scimUsers, nil
+}
+
+// listScimGroups - groups managed through SCIM, default and local groups are
+// internal to netmaker and not exposed
+func listScimGroups() (map[schema.UserGroupID]schema.UserGroup, error) {
+	groups, err := (&schema.UserGroup{}).ListAll(db.WithContext(context.TODO()))
+	if err != nil {
+		return nil, err
+	}
+	groupMap := make(map[schema.UserGroupID]schema.UserGroup, len(groups))
+	for _, g := range groups {
+		if isScimGroup(&g) {
+			groupMap[g.ID] = g
+		}
+	}
+	return groupMap, nil
+}
+
+// ListScimUsers - returns the users matching the filter ordered by username
+func ListScimUsers(filter string) ([]models.ScimUser, error) {
+	match, err := ParseScimFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	users, err := listScimUsers()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := listScimGroups()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	resources := []models.ScimUser{}
+	for i := range users {
+		su := ScimUserFromSchema(&users[i], groups)
+		if match(scimUserAttrs(su)) {
+			resources = append(resources, su)
+		}
+	}
+	return resources, nil
+}
+
+func getScimUser(id string) (*schema.User, error) {
+	user := &schema.User{ID: id}
+	err := db.FromContext(db.WithContext(context.TODO())).Model(&schema.User{}).Where("id = ?", id).First(user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, scimNotFound("user", id)
+		}
+		return nil, err
+	}
+	if !isScimUser(user) {
+		return nil, scimNotFound("user", id)
+	}
+	return user, nil
+}
+
+func scimUserResponse(user *schema.User) (models.ScimUser, error) {
+	groups, err := listScimGroups()
+	if err != nil {
+		return models.ScimUser{}, err
+	}
+	return ScimUserFromSchema(user, groups), nil
+}
+
+// GetScimUser - returns a user by its SCIM id
+func GetScimUser(id string) (models.ScimUser, error) {
+	user, err := getScimUser(id)
+	if err != nil {
+		return models.ScimUser{}, err
+	}
+	return scimUserResponse(user)
+}
+
+// GetScimUserForDeletion - returns a user to be deprovisioned by the IDP
+func GetScimUserForDeletion(id string) (*schema.User, error) {
+	user, err := getScimUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if user.PlatformRoleID == schema.SuperAdminRole {
+		return nil, scimError(http.StatusForbidden, "", "the super admin cannot be deprovisioned")
+	}
+	return user, nil
+}
+
+// applyScimUser - sets the attributes of a SCIM user that are managed by the IDP
+func applyScimUser(user *schema.User, su models.ScimUser) {
+	if su.ExternalID != "" {
+		user.ExternalIdentityProviderID = su.ExternalID
+	}
+	switch {
+	case su.DisplayName != "":
+		user.DisplayName = su.DisplayName
+	case su.Name != nil && su.Name.Formatted != "":
+		user.DisplayName = su.Name.Formatted
+	case su.Name != nil && (su.Name.GivenName != "" || su.Name.FamilyName != ""):
+		user.DisplayName = strings.TrimSpace(su.Name.GivenName + " " + su.Name.FamilyName)
+	}
+	if su.Active != nil {
+		user.AccountDisabled = !*su.Active
+	}
+}
+
+// saveScimUser - stores the changes of a provisioned user
+func saveScimUser(user *schema.User, old schema.User) error {
+	if user.PlatformRoleID == schema.SuperAdminRole && user.AccountDisabled {
+		return scimError(http.StatusBadRequest, "mutability", "the super admin cannot be disabled")
+	}
+	if err := logic.UpsertUser(*user); err != nil {
+		return err
+	}
+	if user.AccountDisabled != old.AccountDisabled {
+		// zero values are skipped by UpsertUser
+		if err := user.UpdateAccountStatus(db.WithContext(context.TODO())); err != nil {
+			return err
+		}
+	}
+	if user.AccountDisabled && !old.AccountDisabled {
+		// revoke api access of the disabled user
+		_ = (&schema.UserAccessToken{UserName: user.Username}).DeleteAllUserTokens(db.WithContext(context.TODO()))
+	}
+	return nil
+}
+
+// CreateScimUser - provisions a user pushed by the IDP. A user that signed in
+// with the IDP before it was provisioned is linked instead of created.
+func CreateScimUser(su models.ScimUser) (models.ScimUser, error) {
+	username := strings.TrimSpace(su.UserName)
+	if username == "" {
+		return models.ScimUser{}, scimError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+	user := &schema.User{Username: username}
+	if err := user.Get(db.WithContext(context.TODO())); err == nil {
+		if user.AuthType != schema.OAuth || user.PlatformRoleID == schema.SuperAdminRole ||
+			(user.ExternalIdentityProviderID != "" && user.ExternalIdentityProviderID != su.ExternalID) {
+			return models.ScimUser{}, scimError(http.StatusConflict, "uniqueness", "user %s already exists", username)
+		}
+		old := *user
+		applyScimUser(user, su)
+		if user.ExternalIdentityProviderID == "" {
+			user.ExternalIdentityProviderID = user.ID
+		}
+		if err := saveScimUser(user, old); err != nil {
+			return models.ScimUser{}, err
+		}
+		return scimUserResponse(user)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.ScimUser{}, err
+	}
+
+	password, err := logic.FetchPassValue("")
+	if err != nil {
+		return models.ScimUser{}, err
+	}
+	user = &schema.User{
+		ID:             uuid.NewString(),
+		Username:       username,
+		Password:       password,
+		AuthType:       schema.OAuth,
+		PlatformRoleID: schema.ServiceUser,
+	}
+	applyScimUser(user, su)
+	if user.ExternalIdentityProviderID == "" {
+		user.ExternalIdentityProviderID = user.ID
+	}
+	if err := logic.CreateUser(user); err != nil {
+		return models.ScimUser{}, scimError(http.StatusBadRequest, "invalidValue", "%v", err)
+	}
+	// a pending user was created if the user tried to sign in before it was
+	// provisioned
+	_ = logic.DeletePendingUser(username)
+	return scimUserResponse(user)
+}
+
+// ReplaceScimUser - replaces the IDP managed attributes of a user
+func ReplaceScimUser(id string, su models.ScimUser) (models.ScimUser, error) {
+	user, err := getScimUser(id)
+	if err != nil {
+		return models.ScimUser{}, err
+	}
+	if su.UserName != "" && !strings.EqualFold(strings.TrimSpace(su.UserName), user.Username) {
+		return models.ScimUser{}, scimError(http.StatusBadRequest, "mutability", "userName cannot be changed")
+	}
+	old := *user
+	applyScimUser(user, su)
+	if err := saveScimUser(user, old); err != nil {
+		return models.ScimUser{}, err
+	}
+	return scimUserResponse(user)
+}
+
+// PatchScimUser - applies a PATCH request to a user. Attributes netmaker does
+// not store, e.g. emails or phone numbers, are ignored.
+func PatchScimUser(id string, req models.ScimPatchRequest) (models.ScimUser, error) {
+	user, err := getScimUser(id)
+	if err != nil {
+		return models.ScimUser{}, err
+	}
+	old := *user
+	for _, op := range req.Operations {
+		opName := strings.ToLower(op.Op)
+		if opName != "add" && opName != "replace" && opName != "remove" {
+			return models.ScimUser{}, scimError(http.StatusBadRequest, "invalidSyntax", "unsupported operation %s", op.Op)
+		}
+		if op.Path == "" {
+			values, ok := op.Value.(map[string]any)
+			if !ok || opName == "remove" {
+				return models.ScimUser{}, scimError(http.StatusBadRequest, "noTarget", "operation %s needs a path", op.Op)
+			}
+			for path, value := range values {
+				if err := patchScimUserAttr(user, path, value, false); err != nil {
+					return models.ScimUser{}, err
+				}
+			}
+			continue
+		}
+		if err := patchScimUserAttr(user, op.Path, op.Value, opName == "remove"); err != nil {
+			return models.ScimUser{}, err
+		}
+	}
+	if err := saveScimUser(user, old); err != nil {
+		return models.ScimUser{}, err
+	}
+	return scimUserResponse(user)
+}
+
+func patchScimUserAttr(user *schema.User, path string, value any, remove bool) error {
+	switch normalizeScimPath(path) {
+	case "active":
+		if remove {
+			return scimError(http.StatusBadRequest, "mutability", "active cannot be removed")
+		}
+		active, err := scimBool(value)
+		if err != nil {
+			return err
+		}
+		user.AccountDisabled = !active
+	case "displayname", "name.formatted":
+		if remove {
+			user.DisplayName = ""
+			return nil
+		}
+		name, err := scimString(value)
+		if err != nil {
+			return err
+		}
+		user.DisplayName = name
+	case "name":
+		if remove {
+			return nil
+		}
+		name, ok := value.(map[string]any)
+		if !ok {
+			return scimError(http.StatusBadRequest, "invalidValue", "invalid name")
+		}
+		if formatted, err := scimString(name["formatted"]); err == nil && formatted != "" {
+			user.DisplayName = formatted
+		}
+	case "externalid":
+		if remove {
+			return scimError(http.StatusBadRequest, "mutability", "externalId cannot be removed")
+		}
+		externalID, err := scimString(value)
+		if err != nil {
+			return err
+		}
+		if externalID != "" {
+			user.ExternalIdentityProviderID = externalID
+		}
+	case "username":
+		userName, err := scimString(value)
+		if err != nil {
+			return err
+		}
+		if remove || !strings.EqualFold(strings.TrimSpace(userName), user.Username) {
+			return scimError(http.StatusBadRequest, "mutability", "userName cannot be changed")
+		}
+	case "id", "groups":
+		return scimError(http.StatusBadRequest, "mutability", "%s is read only", path)
+	}
+	return nil
+}
+
+// ListScimGroups - returns the groups matching the filter ordered by name
+func ListScimGroups(filter string) ([]models.ScimGroup, error) {
+	match, err := ParseScimFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := listScimGroups()
+	if err != nil {
+		return nil, err
+	}
+	users, err := listScimUsers()
+	if err != nil {
+		return nil, err
+	}
+	sorted := make([]schema.UserGroup, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	resources := []models.ScimGroup{}
+	for i := range sorted {
+		sg := ScimGroupFromSchema(&sorted[i], users)
+		if match(scimGroupAttrs(sg)) {
+			resources = append(resources, sg)
+		}
+	}
+	return resources, nil
+}
+
+func getScimGroup(id string) (*schema.UserGroup, error) {
+	group := &schema.UserGroup{ID: schema.UserGroupID(id)}
+	if err := group.Get(db.WithContext(context.TODO())); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, scimNotFound("group", id)
+		}
+		return nil, err
+	}
+	if !isScimGroup(group) {
+		return nil, scimNotFound("group", id)
+	}
+	return group, nil
+}
+
+func scimGroupResponse(group *schema.UserGroup) (models.ScimGroup, error) {
+	users, err := listScimUsers()
+	if err != nil {
+		return models.ScimGroup{}, err
+	}
+	return ScimGroupFromSchema(group, users), nil
+}
+
+// GetScimGroup - returns a group by its SCIM id
+func GetScimGroup(id string) (models.ScimGroup, error) {
+	group, err := getScimGroup(id)
+	if err != nil {
+		return models.ScimGroup{}, err
+	}
+	return scimGroupResponse(group)
+}
+
+// DeleteScimGroup - removes a group deleted on the IDP
+func DeleteScimGroup(id string) (*schema.UserGroup, error) {
+	group, err := getScimGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	return group, DeleteAndCleanUpGroup(group)
+}
+
+func scimGroupMemberIDs(members []models.ScimMultiValue) []string {
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.Value)
+	}
+	return ids
+}
+
+// CreateScimGroup - provisions a group pushed by the IDP. The group gets no
+// network roles, they are assigned in netmaker.
+func CreateScimGroup(sg models.ScimGroup) (models.ScimGroup, error) {
+	name := strings.TrimSpace(sg.DisplayName)
+	if name == "" {
+		return models.ScimGroup{}, scimError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+	existing := &schema.UserGroup{Name: name}
+	if err := existing.GetByName(db.WithContext(context.TODO())); err == nil {
+		return models.ScimGroup{}, scimError(http.StatusConflict, "uniqueness", "group %s already exists", name)
+	}
+	group := &schema.UserGroup{
+		Name:                       name,
+		ExternalIdentityProviderID: sg.ExternalID,
+		NetworkRoles:               datatypes.NewJSONType(schema.NetworkRoles{}),
+	}
+	if err := CreateUserGroup(group); err != nil {
+		return models.ScimGroup{}, err
+	}
+	if group.ExternalIdentityProviderID == "" {
+		group.ExternalIdentityProviderID = group.ID.String()
+		if err := UpdateUserGroup(*group); err != nil {
+			return models.ScimGroup{}, err
+		}
+	}
+	if len(sg.Members) > 0 {
+		if err := updateScimGroupMembers(group.ID, scimGroupMemberIDs(sg.Members), nil, false); err != nil {
+			return models.ScimGroup{}, err
+		}
+	}
+	return scimGroupResponse(group)
+}
+
+// ReplaceScimGroup - replaces the name and members of a group
+func ReplaceScimGroup(id string, sg models.ScimGroup) (models.ScimGroup, error) {
+	group, err := getScimGroup(id)
+	if err != nil {
+		return models.ScimGroup{}, err
+	}
+	if err := renameScimGroup(group, sg.DisplayName); err != nil {
+		return models.ScimGroup{}, err
+	}
+	if sg.ExternalID != "" && sg.ExternalID != group.ExternalIdentityProviderID {
+		group.ExternalIdentityProviderID = sg.ExternalID
+		if err := UpdateUserGroup(*group); err != nil {
+			return models.ScimGroup{}, err
+		}
+	}
+	if err := updateScimGroupMembers(group.ID, scimGroupMemberIDs(sg.Members), nil, true); err != nil {
+		return models.ScimGroup{}, err
+	}
+	return scimGroupResponse(group)
+}
+
+// PatchScimGroup - applies a PATCH request to a group
+func PatchScimGroup(id string, req models.ScimPatchRequest) (models.ScimGroup, error) {
+	group, err := getScimGroup(id)
+	if err != nil {
+		return models.ScimGroup{}, err
+	}
+	for _, op := range req.Operations {
+		opName := strings.ToLower(op.Op)
+		if opName != "add" && opName != "replace" && opName != "remove" {
+			return models.ScimGroup{}, scimError(http.StatusBadRequest, "invalidSyntax", "unsupported operation %s", op.Op)
+		}
+		if op.Path == "" {
+			values, ok := op.Value.(map[string]any)
+			if !ok || opName == "remove" {
+				return models.ScimGroup{}, scimError(http.StatusBadRequest, "noTarget", "operation %s needs a path", op.Op)
+			}
+			for path, value := range values {
+				if err := patchScimGroupAttr(group, opName, path, value); err != nil {
+					return models.ScimGroup{}, err
+				}
+			}
+			continue
+		}
+		if err := patchScimGroupAttr(group, opName, op.Path, op.Value); err != nil {
+			return models.ScimGroup{}, err
+		}
+	}
+	return scimGroupResponse(group)
+}
+
+func patchScimGroupAttr(group *schema.UserGroup, op, path string, value any) error {
+	memberID, isMemberPath, err := scimMemberPathFilter(path)
+	if err != nil {
+		return err
+	}
+	if isMemberPath {
+		if op != "remove" {
+			return scimError(http.StatusBadRequest, "invalidPath", "unsupported path %s", path)
+		}
+		return updateScimGroupMembers(group.ID, nil, []string{memberID}, false)
+	}
+	switch normalizeScimPath(path) {
+	case "displayname":
+		if op == "remove" {
+			return scimError(http.StatusBadRequest, "mutability", "displayName cannot be removed")
+		}
+		name, err := scimString(value)
+		if err != nil {
+			return err
+		}
+		return renameScimGroup(group, name)
+	case "externalid":
+		externalID, err := scimString(value)
+		if err != nil {
+			return err
+		}
+		if op == "remove" || externalID == "" {
+			return scimError(http.StatusBadRequest, "mutability", "externalId cannot be removed")
+		}
+		group.ExternalIdentityProviderID = externalID
+		return UpdateUserGroup(*group)
+	case "members":
+		ids, err := scimMemberIDs(value)
+		if err != nil {
+			return err
+		}
+		switch op {
+		case "add":
+			return updateScimGroupMembers(group.ID, ids, nil, false)
+		case "replace":
+			return updateScimGroupMembers(group.ID, ids, nil, true)
+		default:
+			if ids == nil {
+				// removing the attribute removes all members
+				return updateScimGroupMembers(group.ID, nil, nil, true)
+			}
+			return updateScimGroupMembers(group.ID, nil, ids, false)
+		}
+	case "id":
+		return scimError(http.StatusBadRequest, "mutability", "id is read only")
+	}
+	return nil
+}
+
+func renameScimGroup(group *schema.UserGroup, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || name == group.Name {
+		return nil
+	}
+	existing := &schema.UserGroup{Name: name}
+	if err := existing.GetByName(db.WithContext(context.TODO())); err == nil && existing.ID != group.ID {
+		return scimError(http.StatusConflict, "uniqueness", "group %s already exists", name)
+	}
+	group.Name = name
+	return UpdateUserGroup(*group)
+}
+
+// updateScimGroupMembers - adds and removes users (by SCIM id) to a group, or
+// sets the members to add if replace is set. Unknown users are skipped, the
+// IDP may reference users that are not provisioned.
+func updateScimGroupMembers(groupID schema.UserGroupID, add, remove []string, replace bool) error {
+	users, err := listScimUsers()
+	if err != nil {
+		return err
+	}
+	addSet := make(map[string]struct{}, len(add))
+	for _, id := range add {
+		addSet[id] = struct{}{}
+	}
+	removeSet := make(map[string]struct{}, len(remove))
+	for _, id := range remove {
+		removeSet[id] = struct{}{}
+	}
+	known := make(map[string]struct{}, len(users))
+	var changed bool
+	for _, user := range users {
+		known[user.ID] = struct{}{}
+		_, isMember := user.UserGroups.Data()[groupID]
+		_, toAdd := addSet[user.ID]
+		_, toRemove := removeSet[user.ID]
+		shouldBeMember := isMember
+		switch {
+		case toAdd:
+			shouldBeMember = true
+		case toRemove || replace:
+			shouldBeMember = false
+		}
+		if shouldBeMember == isMember {
+			continue
+		}
+		if user.PlatformRoleID == schema.SuperAdminRole {
+			// the super admin has access to everything
+			continue
+		}
+		old := user
+		groups := make(map[schema.UserGroupID]struct{}, len(user.UserGroups.Data())+1)
+		for gID := range user.UserGroups.Data() {
+			groups[gID] = struct{}{}
+		}
+		if shouldBeMember {
+			groups[groupID] = struct{}{}
+		} else {
+			delete(groups, groupID)
+		}
+		user.UserGroups = datatypes.NewJSONType(groups)
+		if err := logic.UpsertUser(user); err != nil {
+			return err
+		}
+		// sessions carry the groups of the user
+		_ = (&schema.UserAccessToken{UserName: user.Username}).DeleteAllUserTokens(db.WithContext(context.TODO()))
+		if !shouldBeMember {
+			// revoke the access before the IDP is told the member is gone
+			UpdateUserGwAccess(&old, &user)
+		}
+		changed = true
+	}
+	for id := range addSet {
+		if _, ok := known[id]; !ok {
+			slog.Warn("scim: skipping unknown group member", "group", groupID, "user", id)
+		}
+	}
+	if changed {
+		go mq.PublishPeerUpdate(false)
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+package logic
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// ScimFilter - matches the attributes of a resource against a SCIM filter
+// expression (RFC 7644 section 3.4.2.2). Attribute names are lower case,
+// multi-valued attributes hold one value per element.
+type ScimFilter func(attrs map[string][]string) bool
+
+// scimCaseExactAttrs - attributes compared case sensitively, all other
+// attributes are compared case insensitively
+var scimCaseExactAttrs = map[string]bool{
+	"id":            true,
+	"externalid":    true,
+	"members":       true,
+	"members.value": true,
+	"groups":        true,
+	"groups.value":  true,
+}
+
+type scimFilterToken struct {
+	value  string
+	quoted bool
+}
+
+type scimFilterParser struct {
+	tokens []scimFilterToken
+	pos    int
+}
+
+// ParseScimFilter - parses a filter expression, the returned filter matches
+// every resource if the expression is empty. Comparisons (eq, ne, co, sw, ew,
+// gt, ge, lt, le, pr) can be combined with and, or, not and parentheses;
+// complex attribute filters (emails[type eq "work"]) are not supported.
+func ParseScimFilter(expr string) (ScimFilter, error) {
+	if strings.TrimSpace(expr) == "" {
+		return func(map[string][]string) bool { return true }, nil
+	}
+	tokens, err := tokenizeScimFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &scimFilterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, invalidScimFilter("unexpected %q", p.tokens[p.pos].value)
+	}
+	return f, nil
+}
+
+func invalidScimFilter(format string, args ...any) error {
+	return scimError(http.StatusBadRequest, "invalidFilter", "invalid filter: "+format, args...)
+}
+
+func tokenizeScimFilter(expr string) ([]scimFilterToken, error) {
+	var tokens []scimFilterToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, scimFilterToken{value: string(r)})
+			i++
+		case r == '[' || r == ']':
+			return nil, invalidScimFilter("complex attribute filters are not supported")
+		case r == '"':
+			var b strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					b.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				if runes[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, invalidScimFilter("unterminated string")
+			}
+			tokens = append(tokens, scimFilterToken{value: b.String(), quoted: true})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()[]\"", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, scimFilterToken{value: string(runes[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+func (p *scimFilterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].value, keyword)
+}
+
+func (p *scimFilterParser) next() (scimFilterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return scimFilterToken{}, false
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, true
+}
+
+func (p *scimFilterParser) parseOr() (ScimFilter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(attrs map[string][]string) bool { return l(attrs) || right(attrs) }
+	}
+	return left, nil
+}
+
+func (p *scimFilterParser) parseAnd() (ScimFilter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(attrs map[string][]string) bool { return l(attrs) && right(attrs) }
+	}
+	return left, nil
+}
+
+func (p *scimFilterParser) parseUnary() (ScimFilter, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		f, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return func(attrs map[string][]string) bool { return !f(attrs) }, nil
+	}
+	if p.peekKeyword("(") {
+		return p.parseGroup()
+	}
+	return p.parseComparison()
+}
+
+func (p *scimFilterParser) parseGroup() (ScimFilter, error) {
+	if t, ok := p.next(); !ok || t.quoted || t.value != "(" {
+		return nil, invalidScimFilter("expected (")
+	}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.next(); !ok || t.quoted || t.value != ")" {
+		return nil, invalidScimFilter("expected )")
+	}
+	return f, nil
+}
+
+func (p *scimFilterParser) parseComparison() (ScimFilter, error) {
+	attrToken, ok := p.next()
+	if !ok || attrToken.quoted || attrToken.value == ")" {
+		return nil, invalidScimFilter("expected attribute")
+	}
+	attr := normalizeScimPath(attrToken.value)
+	opToken, ok := p.next()
+	if !ok || opToken.quoted {
+		return nil, invalidScimFilter("expected operator after %s", attrToken.value)
+	}
+	op := strings.ToLower(opToken.value)
+	if op == "pr" {
+		return func(attrs map[string][]string) bool {
+			for _, v := range attrs[attr] {
+				if v != "" {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	valueToken, ok := p.next()
+	if !ok {
+		return nil, invalidScimFilter("expected value after %s %s", attrToken.value, opToken.value)
+	}
+	value := valueToken.value
+	if !valueToken.quoted {
+		// true, false, null and numbers are not quoted
+		value = strings.ToLower(value)
+		if value == "null" {
+			value = ""
+		}
+	}
+	caseExact := scimCaseExactAttrs[attr]
+	if !caseExact {
+		value = strings.ToLower(value)
+	}
+	var cmp func(v string) bool
+	switch op {
+	case "eq":
+		cmp = func(v string) bool { return v == value }
+	case "ne":
+		cmp = func(v string) bool { return v != value }
+	case "co":
+		cmp = func(v string) bool { return strings.Contains(v, value) }
+	case "sw":
+		cmp = func(v string) bool { return strings.HasPrefix(v, value) }
+	case "ew":
+		cmp = func(v string) bool { return strings.HasSuffix(v, value) }
+	case "gt":
+		cmp = func(v string) bool { return v > value }
+	case "ge":
+		cmp = func(v string) bool { return v >= value }
+	case "lt":
+		cmp = func(v string) bool { return v < value }
+	case "le":
+		cmp = func(v string) bool { return v <= value }
+	default:
+		return nil, invalidScimFilter("unsupported operator %s", opToken.value)
+	}
+	return func(attrs map[string][]string) bool {
+		values, ok := attrs[attr]
+		if !ok || len(values) == 0 {
+			// an absent attribute is only unequal to a value
+			return op == "ne" || (op == "eq" && value == "")
+		}
+		for _, v := range values {
+			if !caseExact {
+				v = strings.ToLower(v)
+			}
+			if cmp(v) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// normalizeScimPath - lower cases an attribute path and strips the schema
+// URN of core attributes, e.g. urn:...:core:2.0:User:userName -> username
+func normalizeScimPath(path string) string {
+	path = strings.ToLower(strings.TrimSpace(path))
+	for _, urn := range []string{"urn:ietf:params:scim:schemas:core:2.0:user:", "urn:ietf:params:scim:schemas:core:2.0:group:"} {
+		path = strings.TrimPrefix(path, urn)
+	}
+	return path
+}
+
+// scimBool - parses a boolean attribute value, some IDPs send booleans as strings
+func scimBool(value any) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToLower(v) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, scimError(http.StatusBadRequest, "invalidValue", "%v is not a boolean", value)
+}
+
+// scimString - parses a string attribute value
+func scimString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", nil
+	}
+	return "", scimError(http.StatusBadRequest, "invalidValue", "%v is not a string", value)
+}
+
+// scimMemberIDs - parses the value of a members operation, a list of
+// {"value": id} objects or a single object
+func scimMemberIDs(value any) ([]string, error) {
+	var items []any
+	switch v := value.(type) {
+	case []any:
+		items = v
+	case map[string]any:
+		items = []any{v}
+	case nil:
+		return nil, nil
+	default:
+		return nil, scimError(http.StatusBadRequest, "invalidValue", "invalid members value")
+	}
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, scimError(http.StatusBadRequest, "invalidValue", "invalid member %v", item)
+		}
+		id, err := scimString(m["value"])
+		if err != nil || id == "" {
+			return nil, scimError(http.StatusBadRequest, "invalidValue", "member without value")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// scimMemberPathFilter - parses paths like members[value eq "id"] used by
+// IDPs to remove a single member
+func scimMemberPathFilter(path string) (string, bool, error) {
+	open := strings.Index(path, "[")
+	if open < 0 {
+		return "", false, nil
+	}
+	if !strings.HasSuffix(path, "]") || normalizeScimPath(path[:open]) != "members" {
+		return "", false, scimError(http.StatusBadRequest, "invalidPath", "unsupported path %s", path)
+	}
+	tokens, err := tokenizeScimFilter(path[open+1 : len(path)-1])
+	if err != nil {
+		return "", false, err
+	}
+	if len(tokens) != 3 || normalizeScimPath(tokens[0].value) != "value" || !strings.EqualFold(tokens[1].value, "eq") {
+		return "", false, scimError(http.StatusBadRequest, "invalidPath", "unsupported path %s", path)
+	}
+	return tokens[2].value, true, nil
+}
@@ -0,0 +1,347 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func setupScimTestDB(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	database.InitializeDatabase()
+	t.Cleanup(func() {
+		database.CloseDB()
+		db.CloseDB()
+	})
+	ctx := db.WithContext(context.TODO())
+	require.NoError(t, db.FromContext(ctx).Where("1 = 1").Delete(&schema.User{}).Error)
+	require.NoError(t, db.FromContext(ctx).Where("1 = 1").Delete(&schema.UserGroup{}).Error)
+	UserRolesInit()
+	require.NoError(t, logic.SetAuthSecret(logic.RandomString(64)))
+	require.NoError(t, DeleteScimToken())
+}
+
+func assertScimError(t *testing.T, err error, status int, scimType string) {
+	t.Helper()
+	var reqErr *ScimRequestError
+	require.True(t, errors.As(err, &reqErr), "expected a scim error, got %v", err)
+	assert.Equal(t, status, reqErr.Status)
+	assert.Equal(t, scimType, reqErr.ScimType)
+}
+
+func TestParseScimFilter(t *testing.T) {
+	attrs := map[string][]string{
+		"id":           {"2819c223"},
+		"username":     {"Jane@Example.com"},
+		"displayname":  {"Jane Doe"},
+		"active":       {"true"},
+		"emails.value": {"jane@example.com", "jd@work.example.com"},
+	}
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{``, true},
+		{`userName eq "jane@example.com"`, true},
+		{`USERNAME Eq "JANE@EXAMPLE.COM"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "jane@example.com"`, true},
+		{`userName eq "john@example.com"`, false},
+		{`userName ne "john@example.com"`, true},
+		{`id eq "2819C223"`, false},
+		{`displayName sw "jane"`, true},
+		{`displayName ew "doe"`, true},
+		{`displayName co "e d"`, true},
+		{`emails.value eq "jd@work.example.com"`, true},
+		{`externalId pr`, false},
+		{`displayName pr`, true},
+		{`active eq true`, true},
+		{`active eq false`, false},
+		{`userName eq "john@example.com" or active eq true`, true},
+		{`userName eq "john@example.com" or active eq true and displayName eq "x"`, false},
+		{`(userName eq "john@example.com" or active eq true) and displayName sw "jane"`, true},
+		{`not (active eq true)`, false},
+		{`displayName eq "Jane \"JD\" Doe"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := ParseScimFilter(tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, f(attrs))
+		})
+	}
+
+	for _, filter := range []string{
+		`userName`,
+		`userName eq`,
+		`userName foo "x"`,
+		`userName eq "x`,
+		`(userName eq "x"`,
+		`emails[type eq "work"]`,
+		`userName eq "x" extra`,
+	} {
+		t.Run("invalid "+filter, func(t *testing.T) {
+			_, err := ParseScimFilter(filter)
+			assertScimError(t, err, http.StatusBadRequest, "invalidFilter")
+		})
+	}
+}
+
+func TestScimMemberPathFilter(t *testing.T) {
+	id, ok, err := scimMemberPathFilter(`members[value eq "u1"]`)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "u1", id)
+
+	_, ok, err = scimMemberPathFilter("members")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = scimMemberPathFilter(`emails[type eq "work"]`)
+	assertScimError(t, err, http.StatusBadRequest, "invalidPath")
+}
+
+func TestPaginateScim(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	page := PaginateScim(items, 2, 2)
+	assert.Equal(t, 5, page.TotalResults)
+	assert.Equal(t, 2, page.ItemsPerPage)
+	assert.Equal(t, []int{2, 3}, page.Resources)
+
+	page = PaginateScim(items, 4, 10)
+	assert.Equal(t, []int{4, 5}, page.Resources)
+
+	page = PaginateScim(items, 6, 10)
+	assert.Equal(t, []int{}, page.Resources)
+
+	// count=0 only returns the total
+	page = PaginateScim(items, 1, 0)
+	assert.Equal(t, 5, page.TotalResults)
+	assert.Equal(t, 0, page.ItemsPerPage)
+}
+
+func TestScimToken(t *testing.T) {
+	setupScimTestDB(t)
+	assert.False(t, IsScimEnabled())
+	assert.False(t, ValidateScimToken(""))
+
+	info, err := CreateScimToken("admin")
+	require.NoError(t, err)
+	assert.True(t, IsScimEnabled())
+	assert.True(t, ValidateScimToken(info.Token))
+	assert.False(t, ValidateScimToken(info.Token+"x"))
+	assert.Empty(t, GetScimTokenInfo().Token)
+	assert.Equal(t, "admin", GetScimTokenInfo().CreatedBy)
+
+	// a new token replaces the old one
+	rotated, err := CreateScimToken("admin")
+	require.NoError(t, err)
+	assert.False(t, ValidateScimToken(info.Token))
+	assert.True(t, ValidateScimToken(rotated.Token))
+
+	require.NoError(t, DeleteScimToken())
+	assert.False(t, IsScimEnabled())
+	assert.False(t, ValidateScimToken(rotated.Token))
+}
+
+func TestScimUsers(t *testing.T) {
+	setupScimTestDB(t)
+	active := true
+
+	created, err := CreateScimUser(models.ScimUser{
+		UserName:   "jane@example.com",
+		ExternalID: "okta-1",
+		Name:       &models.ScimName{GivenName: "Jane", FamilyName: "Doe"},
+		Active:     &active,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "okta-1", created.ExternalID)
+	assert.Equal(t, "Jane Doe", created.DisplayName)
+	assert.True(t, *created.Active)
+
+	user := &schema.User{Username: "jane@example.com"}
+	require.NoError(t, user.Get(db.WithContext(context.TODO())))
+	assert.Equal(t, schema.OAuth, user.AuthType)
+	assert.Equal(t, schema.ServiceUser, user.PlatformRoleID)
+
+	_, err = CreateScimUser(models.ScimUser{UserName: "jane@example.com", ExternalID: "okta-2"})
+	assertScimError(t, err, http.StatusConflict, "uniqueness")
+
+	users, err := ListScimUsers(`userName eq "JANE@example.com"`)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, created.ID, users[0].ID)
+
+	// deactivation as sent by Azure AD
+	patched, err := PatchScimUser(created.ID, models.ScimPatchRequest{Operations: []models.ScimPatchOperation{
+		{Op: "Replace", Path: "active", Value: "False"},
+		{Op: "replace", Value: map[string]any{"displayName": "Jane D."}},
+	}})
+	require.NoError(t, err)
+	assert.False(t, *patched.Active)
+	assert.Equal(t, "Jane D.", patched.DisplayName)
+	require.NoError(t, user.Get(db.WithContext(context.TODO())))
+	assert.True(t, user.AccountDisabled)
+
+	replaced, err := ReplaceScimUser(created.ID, models.ScimUser{UserName: "jane@example.com", DisplayName: "Jane", Active: &active})
+	require.NoError(t, err)
+	assert.True(t, *replaced.Active)
+	assert.Equal(t, "Jane", replaced.DisplayName)
+
+	_, err = PatchScimUser(created.ID, models.ScimPatchRequest{Operations: []models.ScimPatchOperation{
+		{Op: "replace", Path: "userName", Value: "john@example.com"},
+	}})
+	assertScimError(t, err, http.StatusBadRequest, "mutability")
+
+	_, err = GetScimUser("missing")
+	assertScimError(t, err, http.StatusNotFound, "")
+}
+
+func TestScimLinksExistingOAuthUser(t *testing.T) {
+	setupScimTestDB(t)
+	password, err := logic.FetchPassValue("")
+	require.NoError(t, err)
+	// the user signed in with the IDP before it was provisioned
+	require.NoError(t, logic.CreateUser(&schema.User{
+		Username:       "john@example.com",
+		Password:       password,
+		PlatformRoleID: schema.ServiceUser,
+	}))
+	linked, err := CreateScimUser(models.ScimUser{UserName: "john@example.com", ExternalID: "okta-3"})
+	require.NoError(t, err)
+	assert.Equal(t, "okta-3", linked.ExternalID)
+
+	// basic auth users are not taken over
+	require.NoError(t, logic.CreateUser(&schema.User{
+		Username:       "basic@example.com",
+		Password:       "a-secret-password",
+		PlatformRoleID: schema.ServiceUser,
+	}))
+	_, err = CreateScimUser(models.ScimUser{UserName: "basic@example.com"})
+	assertScimError(t, err, http.StatusConflict, "uniqueness")
+}
+
+func TestScimGroups(t *testing.T) {
+	setupScimTestDB(t)
+	alice, err := CreateScimUser(models.ScimUser{UserName: "alice@example.com"})
+	require.NoError(t, err)
+	bob, err := CreateScimUser(models.ScimUser{UserName: "bob@example.com"})
+	require.NoError(t, err)
+
+	group, err := CreateScimGroup(models.ScimGroup{
+		DisplayName: "engineering",
+		ExternalID:  "okta-group-1",
+		Members:     []models.ScimMultiValue{{Value: alice.ID}, {Value: "not-provisioned"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "okta-group-1", group.ExternalID)
+	require.Len(t, group.Members, 1)
+	assert.Equal(t, alice.ID, group.Members[0].Value)
+
+	_, err = CreateScimGroup(models.ScimGroup{DisplayName: "engineering"})
+	assertScimError(t, err, http.StatusConflict, "uniqueness")
+
+	// Okta style membership updates
+	group, err = PatchScimGroup(group.ID, models.ScimPatchRequest{Operations: []models.ScimPatchOperation{
+		{Op: "add", Path: "members", Value: []any{map[string]any{"value": bob.ID}}},
+		{Op: "replace", Value: map[string]any{"displayName": "platform"}},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "platform", group.DisplayName)
+	assert.Len(t, group.Members, 2)
+
+	aliceGroups, err := GetScimUser(alice.ID)
+	require.NoError(t, err)
+	require.Len(t, aliceGroups.Groups, 1)
+	assert.Equal(t, "platform", aliceGroups.Groups[0].Display)
+
+	// Azure AD style member removal
+	group, err = PatchScimGroup(group.ID, models.ScimPatchRequest{Operations: []models.ScimPatchOperation{
+		{Op: "Remove", Path: `members[value eq "` + alice.ID + `"]`},
+	}})
+	require.NoError(t, err)
+	require.Len(t, group.Members, 1)
+	assert.Equal(t, bob.ID, group.Members[0].Value)
+
+	groups, err := ListScimGroups(`displayName eq "platform"`)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+
+	group, err = ReplaceScimGroup(group.ID, models.ScimGroup{DisplayName: "platform"})
+	require.NoError(t, err)
+	assert.Empty(t, group.Members)
+}
+
+func TestScimIgnoresLocalUsersAndGroups(t *testing.T) {
+	setupScimTestDB(t)
+	ctx := db.WithContext(context.TODO())
+	require.NoError(t, logic.CreateUser(&schema.User{
+		Username:       "local-admin",
+		Password:       "a-secret-password",
+		PlatformRoleID: schema.AdminRole,
+	}))
+	local := &schema.User{Username: "local-admin"}
+	require.NoError(t, local.Get(ctx))
+	alice, err := CreateScimUser(models.ScimUser{UserName: "alice@example.com"})
+	require.NoError(t, err)
+
+	users, err := ListScimUsers("")
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, alice.ID, users[0].ID)
+	_, err = GetScimUser(local.ID)
+	assertScimError(t, err, http.StatusNotFound, "")
+	_, err = GetScimUserForDeletion(local.ID)
+	assertScimError(t, err, http.StatusNotFound, "")
+	_, err = PatchScimUser(local.ID, models.ScimPatchRequest{Operations: []models.ScimPatchOperation{
+		{Op: "replace", Path: "active", Value: false},
+	}})
+	assertScimError(t, err, http.StatusNotFound, "")
+	_, err = ReplaceScimUser(local.ID, models.ScimUser{UserName: local.Username})
+	assertScimError(t, err, http.StatusNotFound, "")
+	require.NoError(t, local.Get(ctx))
+	assert.False(t, local.AccountDisabled)
+
+	// a local group granting network access
+	ops := &schema.UserGroup{
+		Name: "ops",
+		NetworkRoles: datatypes.NewJSONType(schema.NetworkRoles{
+			"net1": {"net1-network-admin": {}},
+		}),
+	}
+	require.NoError(t, CreateUserGroup(ops))
+	_, err = GetScimGroup(ops.ID.String())
+	assertScimError(t, err, http.StatusNotFound, "")
+	_, err = PatchScimGroup(ops.ID.String(), models.ScimPatchRequest{Operations: []models.ScimPatchOperation{
+		{Op: "add", Path: "members", Value: []any{map[string]any{"value": alice.ID}}},
+	}})
+	assertScimError(t, err, http.StatusNotFound, "")
+	_, err = ReplaceScimGroup(ops.ID.String(), models.ScimGroup{DisplayName: "ops", Members: []models.ScimMultiValue{{Value: alice.ID}}})
+	assertScimError(t, err, http.StatusNotFound, "")
+	groups, err := ListScimGroups("")
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+	aliceUser := &schema.User{Username: "alice@example.com"}
+	require.NoError(t, aliceUser.Get(ctx))
+	assert.NotContains(t, aliceUser.UserGroups.Data(), ops.ID)
+
+	// local users are not added to groups of the IDP
+	group, err := CreateScimGroup(models.ScimGroup{
+		DisplayName: "engineering",
+		Members:     []models.ScimMultiValue{{Value: alice.ID}, {Value: local.ID}},
+	})
+	require.NoError(t, err)
+	require.Len(t, group.Members, 1)
+	assert.Equal(t, alice.ID, group.Members[0].Value)
+	require.NoError(t, local.Get(ctx))
+	assert.NotContains(t, local.UserGroups.Data(), schema.UserGroupID(group.ID))
+}
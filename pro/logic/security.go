@@ -44,9 +44,14 @@ const (
 )
 
 func NetworkPermissionsCheck(username string, r *http.Request) error {
+	// a scoped access token narrows down the permissions of its user
+	err := logic.CheckAccessTokenScopes(r, false)
+	if err != nil {
+		return err
+	}
 	// at this point global checks should be completed
 	user := &schema.User{Username: username}
-	err := user.Get(r.Context())
+	err = user.Get(r.Context())
 	if err != nil {
 		return err
 	}
@@ -162,6 +167,11 @@ func GlobalPermissionsCheck(username string, r *http.Request) error {
 	if err != nil {
 		return err
 	}
+	// a scoped access token narrows down the permissions of its user
+	err = logic.CheckAccessTokenScopes(r, true)
+	if err != nil {
+		return err
+	}
 	user := &schema.User{Username: username}
 	err = user.Get(r.Context())
 	if err != nil {
@@ -221,7 +231,7 @@ func GlobalPermissionsCheck(username string, r *http.Request) error {
 	if r.Method == http.MethodGet && targetRsrc == schema.UserActivityRsrc.String() && route == "/api/v1/user/activity" {
 		return nil
 	}
-	if r.Method == http.MethodGet && user.PlatformRoleID == schema.PlatformUser && (route == "/api/v1/network/activity" || route == "/api/v1/flows") {
+	if r.Method == http.MethodGet && user.PlatformRoleID == schema.PlatformUser && (route == "/api/v1/network/activity" || strings.HasPrefix(route, "/api/v1/flows")) {
 		return nil
 	}
 	rsrcPermissionScope, ok := userRole.GlobalLevelAccess.Data()[schema.RsrcType(targetRsrc)]
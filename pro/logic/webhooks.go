@@ -0,0 +1,311 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+const (
+	// WebhookSignatureHeader - hex encoded HMAC-SHA256 of "<timestamp>.<body>" keyed by the webhook secret
+	WebhookSignatureHeader = "X-Netmaker-Signature"
+	// WebhookTimestampHeader - unix timestamp the payload was signed at
+	WebhookTimestampHeader = "X-Netmaker-Timestamp"
+	// WebhookEventHeader - action of the delivered event
+	WebhookEventHeader = "X-Netmaker-Event"
+	// WebhookDeliveryHeader - id of the delivery, stable across retries
+	WebhookDeliveryHeader = "X-Netmaker-Delivery"
+
+	webhookMaxAttempts    = 8
+	webhookInitialBackoff = 10 * time.Second
+	webhookMaxBackoff     = time.Hour
+	webhookTimeout        = 10 * time.Second
+	webhookTestAction     = schema.Action("TEST")
+)
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// WebhookPayload - body posted to webhook endpoints
+type WebhookPayload struct {
+	ID          string           `json:"id"`
+	Action      schema.Action    `json:"action"`
+	Source      json.RawMessage  `json:"source"`
+	Target      json.RawMessage  `json:"target"`
+	Origin      schema.Origin    `json:"origin"`
+	NetworkID   schema.NetworkID `json:"network_id"`
+	TriggeredBy string           `json:"triggered_by"`
+	Diff        json.RawMessage  `json:"diff"`
+	TimeStamp   time.Time        `json:"time_stamp"`
+	Server      string           `json:"server"`
+}
+
+// ValidateWebhook - validates the endpoint and filters of a webhook
+func ValidateWebhook(w *schema.Webhook) error {
+	if w.Name == "" {
+		return errors.New("name is required")
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("url must be a valid http or https endpoint")
+	}
+	for _, netID := range w.Networks {
+		if err := (&schema.Network{Name: netID.String()}).Get(db.WithContext(context.TODO())); err != nil {
+			return fmt.Errorf("network %s not found", netID)
+		}
+	}
+	return nil
+}
+
+// GenerateWebhookSecret - random secret used to sign webhook payloads
+func GenerateWebhookSecret() string {
+	return logic.RandomString(32)
+}
+
+// SignWebhookPayload - computes the signature header value of a payload
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookMatchesEvent - checks if the event passes the filters of the webhook
+func webhookMatchesEvent(w *schema.Webhook, e *schema.Event, targetType schema.SubjectType) bool {
+	if len(w.Actions) > 0 && !slices.Contains(w.Actions, e.Action) {
+		return false
+	}
+	if len(w.SubjectTypes) > 0 && !slices.Contains(w.SubjectTypes, targetType) {
+		return false
+	}
+	if len(w.Networks) > 0 && !slices.Contains(w.Networks, e.NetworkID) {
+		return false
+	}
+	return true
+}
+
+func newWebhookPayload(e *schema.Event) ([]byte, error) {
+	return json.Marshal(WebhookPayload{
+		ID:          e.ID,
+		Action:      e.Action,
+		Source:      json.RawMessage(e.Source),
+		Target:      json.RawMessage(e.Target),
+		Origin:      e.Origin,
+		NetworkID:   e.NetworkID,
+		TriggeredBy: e.TriggeredBy,
+		Diff:        json.RawMessage(e.Diff),
+		TimeStamp:   e.TimeStamp,
+		Server:      servercfg.GetServer(),
+	})
+}
+
+// DispatchWebhooks - queues a delivery of the event to every matching webhook
+// and makes the first attempt right away
+func DispatchWebhooks(e *schema.Event) {
+	ctx := db.WithContext(context.TODO())
+	webhooks, err := (&schema.Webhook{}).ListEnabled(ctx)
+	if err != nil || len(webhooks) == 0 {
+		return
+	}
+	var target models.Subject
+	_ = json.Unmarshal(e.Target, &target)
+	payload, err := newWebhookPayload(e)
+	if err != nil {
+		slog.Error("failed to encode webhook payload", "event", e.ID, "error", err)
+		return
+	}
+	for i := range webhooks {
+		w := webhooks[i]
+		if !webhookMatchesEvent(&w, e, target.Type) {
+			continue
+		}
+		d := schema.WebhookDelivery{
+			ID:        uuid.New().String(),
+			WebhookID: w.ID,
+			EventID:   e.ID,
+			Action:    e.Action,
+			Payload:   payload,
+			Status:    schema.WebhookDeliveryPending,
+			// keeps the retry hook away from the delivery while the first attempt is in flight
+			NextAttemptAt: time.Now().UTC().Add(webhookTimeout + webhookInitialBackoff),
+			CreatedAt:     time.Now().UTC(),
+			UpdatedAt:     time.Now().UTC(),
+		}
+		if err := d.Create(ctx); err != nil {
+			slog.Error("failed to queue webhook delivery", "webhook", w.ID, "event", e.ID, "error", err)
+			continue
+		}
+		go deliverWebhook(&w, &d)
+	}
+}
+
+// TestWebhook - sends a synthetic event to the webhook and returns the delivery
+func TestWebhook(w *schema.Webhook, user string) (schema.WebhookDelivery, error) {
+	source, _ := json.Marshal(models.Subject{ID: user, Name: user, Type: schema.UserSub})
+	target, _ := json.Marshal(models.Subject{ID: w.ID, Name: w.Name, Type: schema.WebhookSub})
+	e := schema.Event{
+		ID:          uuid.New().String(),
+		Action:      webhookTestAction,
+		Source:      source,
+		Target:      target,
+		Origin:      schema.Dashboard,
+		TriggeredBy: user,
+		Diff:        []byte("{}"),
+		TimeStamp:   time.Now().UTC(),
+	}
+	payload, err := newWebhookPayload(&e)
+	if err != nil {
+		return schema.WebhookDelivery{}, err
+	}
+	d := schema.WebhookDelivery{
+		ID:        uuid.New().String(),
+		WebhookID: w.ID,
+		EventID:   e.ID,
+		Action:    e.Action,
+		Payload:   payload,
+		Status:    schema.WebhookDeliveryPending,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	// test deliveries are attempted once and never retried
+	sendWebhook(w, &d)
+	if d.Status == schema.WebhookDeliveryPending {
+		d.Status = schema.WebhookDeliveryFailed
+	}
+	return d, d.Create(db.WithContext(context.TODO()))
+}
+
+// deliverWebhook - attempts a delivery and schedules the next retry on failure
+func deliverWebhook(w *schema.Webhook, d *schema.WebhookDelivery) {
+	sendWebhook(w, d)
+	if d.Status == schema.WebhookDeliveryPending {
+		if d.Attempts >= webhookMaxAttempts {
+			d.Status = schema.WebhookDeliveryFailed
+			slog.Warn("webhook delivery failed, giving up", "webhook", w.ID, "delivery", d.ID, "error", d.Error)
+		} else {
+			d.NextAttemptAt = time.Now().UTC().Add(webhookBackoff(d.Attempts))
+		}
+	}
+	if err := d.Update(db.WithContext(context.TODO())); err != nil {
+		slog.Error("failed to update webhook delivery", "delivery", d.ID, "error", err)
+	}
+}
+
+// webhookBackoff - exponential delay before the next attempt
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookInitialBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return backoff
+}
+
+// sendWebhook - posts the signed payload once and records the outcome on the delivery
+func sendWebhook(w *schema.Webhook, d *schema.WebhookDelivery) {
+	d.Attempts++
+	d.UpdatedAt = time.Now().UTC()
+	d.StatusCode = 0
+	d.Error = ""
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		d.Error = err.Error()
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Netmaker-Webhook/"+servercfg.GetVersion())
+	req.Header.Set(WebhookEventHeader, string(d.Action))
+	req.Header.Set(WebhookDeliveryHeader, d.ID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(w.Secret, timestamp, d.Payload))
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		d.Error = err.Error()
+		return
+	}
+	defer res.Body.Close()
+	d.StatusCode = res.StatusCode
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		d.Status = schema.WebhookDeliverySucceeded
+		return
+	}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	d.Error = fmt.Sprintf("unexpected status %d: %s", res.StatusCode, string(body))
+}
+
+// WebhookRetryHook - retries the pending deliveries that are due
+func WebhookRetryHook() error {
+	ctx := db.WithContext(context.TODO())
+	deliveries, err := (&schema.WebhookDelivery{}).ListDue(ctx)
+	if err != nil {
+		return err
+	}
+	webhooks := make(map[string]*schema.Webhook)
+	for i := range deliveries {
+		d := deliveries[i]
+		w, ok := webhooks[d.WebhookID]
+		if !ok {
+			w = &schema.Webhook{ID: d.WebhookID}
+			if err := w.Get(ctx); err != nil {
+				w = nil
+			}
+			webhooks[d.WebhookID] = w
+		}
+		if w == nil || !w.Enabled {
+			d.Status = schema.WebhookDeliveryFailed
+			d.Error = "webhook was deleted or disabled"
+			d.UpdatedAt = time.Now().UTC()
+			_ = d.Update(ctx)
+			continue
+		}
+		deliverWebhook(w, &d)
+	}
+	return nil
+}
+
+// WebhookDeliveryRetentionHook - prunes the delivery log with the audit retention period
+func WebhookDeliveryRetentionHook() error {
+	retentionPeriod := logic.GetServerSettings().AuditLogsRetentionPeriodInDays
+	if retentionPeriod <= 0 {
+		retentionPeriod = 30
+	}
+	return (&schema.WebhookDelivery{}).DeleteOld(db.WithContext(context.TODO()), retentionPeriod)
+}
+
+// AddWebhookHooks - registers the retry and retention hooks of webhook deliveries
+func AddWebhookHooks() {
+	logic.HookManagerCh <- models.HookDetails{
+		ID:         "webhook-retry-hook",
+		Hook:       logic.WrapHook(WebhookRetryHook),
+		Interval:   webhookInitialBackoff,
+		LeaderOnly: true,
+	}
+	logic.HookManagerCh <- models.HookDetails{
+		ID:         "webhook-delivery-retention-hook",
+		Hook:       logic.WrapHook(WebhookDeliveryRetentionHook),
+		Interval:   time.Hour * 24,
+		LeaderOnly: true,
+	}
+}
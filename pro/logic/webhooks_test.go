@@ -0,0 +1,88 @@
+package logic
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookMatchesEvent(t *testing.T) {
+	e := &schema.Event{Action: schema.Delete, NetworkID: "net1"}
+	tests := []struct {
+		name    string
+		webhook schema.Webhook
+		want    bool
+	}{
+		{"no filters", schema.Webhook{}, true},
+		{"action match", schema.Webhook{Actions: []schema.Action{schema.Create, schema.Delete}}, true},
+		{"action mismatch", schema.Webhook{Actions: []schema.Action{schema.Create}}, false},
+		{"subject match", schema.Webhook{SubjectTypes: []schema.SubjectType{schema.NodeSub}}, true},
+		{"subject mismatch", schema.Webhook{SubjectTypes: []schema.SubjectType{schema.AclSub}}, false},
+		{"network match", schema.Webhook{Networks: []schema.NetworkID{"net1"}}, true},
+		{"network mismatch", schema.Webhook{Networks: []schema.NetworkID{"net2"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, webhookMatchesEvent(&tt.webhook, e, schema.NodeSub))
+		})
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	assert.Equal(t, webhookInitialBackoff, webhookBackoff(1))
+	assert.Equal(t, 2*webhookInitialBackoff, webhookBackoff(2))
+	assert.Equal(t, 4*webhookInitialBackoff, webhookBackoff(3))
+	assert.Equal(t, webhookMaxBackoff, webhookBackoff(50))
+}
+
+func TestSendWebhook(t *testing.T) {
+	payload := []byte(`{"id":"event"}`)
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, err := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+		if err != nil || r.Header.Get(WebhookSignatureHeader) != SignWebhookPayload("secret", ts, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	t.Run("delivered", func(t *testing.T) {
+		d := &schema.WebhookDelivery{ID: "d1", Action: schema.Delete, Payload: payload, Status: schema.WebhookDeliveryPending}
+		sendWebhook(&schema.Webhook{URL: srv.URL, Secret: "secret"}, d)
+		assert.Equal(t, schema.WebhookDeliverySucceeded, d.Status)
+		assert.Equal(t, 1, d.Attempts)
+		assert.Equal(t, http.StatusOK, d.StatusCode)
+	})
+	t.Run("wrong secret", func(t *testing.T) {
+		d := &schema.WebhookDelivery{ID: "d2", Action: schema.Delete, Payload: payload, Status: schema.WebhookDeliveryPending}
+		sendWebhook(&schema.Webhook{URL: srv.URL, Secret: "other"}, d)
+		assert.Equal(t, schema.WebhookDeliveryPending, d.Status)
+		assert.Equal(t, http.StatusUnauthorized, d.StatusCode)
+		assert.NotEmpty(t, d.Error)
+	})
+	t.Run("server error", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		d := &schema.WebhookDelivery{ID: "d3", Action: schema.Delete, Payload: payload, Status: schema.WebhookDeliveryPending}
+		sendWebhook(&schema.Webhook{URL: srv.URL, Secret: "secret"}, d)
+		require.Equal(t, schema.WebhookDeliveryPending, d.Status)
+		assert.Equal(t, http.StatusInternalServerError, d.StatusCode)
+	})
+	t.Run("unreachable", func(t *testing.T) {
+		d := &schema.WebhookDelivery{ID: "d4", Action: schema.Delete, Payload: payload, Status: schema.WebhookDeliveryPending}
+		start := time.Now()
+		sendWebhook(&schema.Webhook{URL: "http://127.0.0.1:1", Secret: "secret"}, d)
+		assert.Equal(t, schema.WebhookDeliveryPending, d.Status)
+		assert.NotEmpty(t, d.Error)
+		assert.Less(t, time.Since(start), webhookTimeout+time.Second)
+	})
+}
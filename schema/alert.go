@@ -0,0 +1,145 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+	"gorm.io/datatypes"
+)
+
+// AlertRuleType - condition an alert rule checks for
+type AlertRuleType string
+
+const (
+	// NodeOfflineAlert - node did not check in for Threshold minutes
+	NodeOfflineAlert AlertRuleType = "node_offline"
+	// PeerLatencyAlert - latency towards a connected peer is above Threshold ms
+	PeerLatencyAlert AlertRuleType = "peer_latency"
+	// PostureViolationAlert - node violates posture checks of at least Severity
+	PostureViolationAlert AlertRuleType = "posture_violation"
+	// EgressGatewayDownAlert - none of the routing nodes of an enabled egress are online
+	EgressGatewayDownAlert AlertRuleType = "egress_gateway_down"
+	// EnrollmentKeyExhaustedAlert - limited use enrollment key has at most Threshold uses left
+	EnrollmentKeyExhaustedAlert AlertRuleType = "enrollment_key_exhausted"
+)
+
+type AlertState string
+
+const (
+	AlertFiring   AlertState = "firing"
+	AlertResolved AlertState = "resolved"
+)
+
+// AlertRule - admin defined condition notified by email and webhooks while
+// it holds. Rules without a network apply to all networks.
+type AlertRule struct {
+	ID        string                      `gorm:"primaryKey" json:"id"`
+	Name      string                      `gorm:"name" json:"name"`
+	Type      AlertRuleType               `gorm:"column:type" json:"type"`
+	Network   NetworkID                   `gorm:"network" json:"network"`
+	Threshold float64                     `gorm:"threshold" json:"threshold"`
+	Severity  Severity                    `gorm:"severity" json:"severity"`
+	Emails    datatypes.JSONSlice[string] `gorm:"emails" json:"emails"`
+	Enabled   bool                        `gorm:"enabled" json:"enabled"`
+	CreatedBy string                      `gorm:"created_by" json:"created_by"`
+	CreatedAt time.Time                   `gorm:"created_at" json:"created_at"`
+	UpdatedAt time.Time                   `gorm:"updated_at" json:"updated_at"`
+}
+
+func (a *AlertRule) Get(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&AlertRule{}).Where("id = ?", a.ID).First(&a).Error
+}
+
+func (a *AlertRule) Create(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&AlertRule{}).Create(&a).Error
+}
+
+func (a *AlertRule) Update(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&AlertRule{}).Where("id = ?", a.ID).Updates(map[string]any{
+		"name":       a.Name,
+		"type":       a.Type,
+		"network":    a.Network,
+		"threshold":  a.Threshold,
+		"severity":   a.Severity,
+		"emails":     a.Emails,
+		"enabled":    a.Enabled,
+		"updated_at": a.UpdatedAt,
+	}).Error
+}
+
+func (a *AlertRule) Delete(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&AlertRule{}).Where("id = ?", a.ID).Delete(&a).Error
+}
+
+func (a *AlertRule) ListAll(ctx context.Context) (rules []AlertRule, err error) {
+	err = db.FromContext(ctx).Model(&AlertRule{}).Order("created_at").Find(&rules).Error
+	return
+}
+
+func (a *AlertRule) ListEnabled(ctx context.Context) (rules []AlertRule, err error) {
+	err = db.FromContext(ctx).Model(&AlertRule{}).Where("enabled = ?", true).Find(&rules).Error
+	return
+}
+
+// Alert - occurrence of a rule for a single subject, e.g. a node. A rule
+// has at most one firing alert per subject.
+type Alert struct {
+	ID          string        `gorm:"primaryKey" json:"id"`
+	RuleID      string        `gorm:"rule_id;index" json:"rule_id"`
+	RuleName    string        `gorm:"rule_name" json:"rule_name"`
+	Type        AlertRuleType `gorm:"column:type" json:"type"`
+	SubjectID   string        `gorm:"subject_id" json:"subject_id"`
+	SubjectName string        `gorm:"subject_name" json:"subject_name"`
+	Network     NetworkID     `gorm:"network" json:"network"`
+	Message     string        `gorm:"message" json:"message"`
+	Value       float64       `gorm:"value" json:"value"`
+	State       AlertState    `gorm:"state;index" json:"state"`
+	FiredAt     time.Time     `gorm:"fired_at" json:"fired_at"`
+	LastSeenAt  time.Time     `gorm:"last_seen_at" json:"last_seen_at"`
+	ResolvedAt  *time.Time    `gorm:"resolved_at" json:"resolved_at,omitempty"`
+}
+
+func (a *Alert) Create(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&Alert{}).Create(&a).Error
+}
+
+func (a *Alert) Update(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&Alert{}).Where("id = ?", a.ID).Updates(map[string]any{
+		"subject_name": a.SubjectName,
+		"message":      a.Message,
+		"value":        a.Value,
+		"state":        a.State,
+		"last_seen_at": a.LastSeenAt,
+		"resolved_at":  a.ResolvedAt,
+	}).Error
+}
+
+// ListFiringByRule - lists the firing alerts of the rule
+func (a *Alert) ListFiringByRule(ctx context.Context) (alerts []Alert, err error) {
+	err = db.FromContext(ctx).Model(&Alert{}).
+		Where("rule_id = ? AND state = ?", a.RuleID, AlertFiring).
+		Find(&alerts).Error
+	return
+}
+
+// List - lists the alerts latest first, of a single state if State is set
+func (a *Alert) List(ctx context.Context) (alerts []Alert, err error) {
+	query := db.FromContext(ctx).Model(&Alert{})
+	if a.State != "" {
+		query = query.Where("state = ?", a.State)
+	}
+	err = query.Order("fired_at DESC").Find(&alerts).Error
+	return
+}
+
+func (a *Alert) DeleteByRule(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&Alert{}).Where("rule_id = ?", a.RuleID).Delete(&Alert{}).Error
+}
+
+// DeleteResolvedBefore - deletes the alerts resolved before the cutoff
+func (a *Alert) DeleteResolvedBefore(ctx context.Context, cutoff time.Time) error {
+	return db.FromContext(ctx).Model(&Alert{}).
+		Where("state = ? AND resolved_at < ?", AlertResolved, cutoff).
+		Delete(&Alert{}).Error
+}
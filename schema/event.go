@@ -43,6 +43,12 @@ const (
 	DisableFlowLogs                      Action = "DISABLE_FLOW_LOGS"
 	GatewayAssign                        Action = "GATEWAY_ASSIGN"
 	GatewayUnAssign                      Action = "GATEWAY_UNASSIGN"
+	Backup                               Action = "BACKUP"
+	Restore                              Action = "RESTORE"
+	UseAccessToken                       Action = "USE_ACCESS_TOKEN"
+	DenyAccessToken                      Action = "DENY_ACCESS_TOKEN"
+	AlertFire                            Action = "ALERT_FIRING"
+	AlertResolve                         Action = "ALERT_RESOLVED"
 )
 
 type SubjectType string
@@ -67,6 +73,12 @@ const (
 	ClientAppSub       SubjectType = "CLIENT-APP"
 	NameserverSub      SubjectType = "NAMESERVER"
 	PostureCheckSub    SubjectType = "POSTURE_CHECK"
+	ServerSub          SubjectType = "SERVER"
+	WebhookSub         SubjectType = "WEBHOOK"
+	ScimTokenSub       SubjectType = "SCIM_TOKEN"
+	WebAuthnSub        SubjectType = "WEBAUTHN_CREDENTIAL"
+	AlertRuleSub       SubjectType = "ALERT_RULE"
+	AlertSub           SubjectType = "ALERT"
 )
 
 func (sub SubjectType) String() string {
@@ -80,6 +92,10 @@ const (
 	Api       Origin = "API"
 	NMCTL     Origin = "NMCTL"
 	ClientApp Origin = "CLIENT-APP"
+	// Scim - changes pushed by the IDP through SCIM provisioning
+	Scim Origin = "SCIM"
+	// Alerting - notifications of the alert rules engine
+	Alerting Origin = "ALERTING"
 )
 
 type Event struct {
@@ -92,6 +108,11 @@ type Event struct {
 	TriggeredBy string         `gorm:"triggered_by" json:"triggered_by"`
 	Diff        datatypes.JSON `gorm:"diff" json:"diff"`
 	TimeStamp   time.Time      `gorm:"time_stamp" json:"time_stamp"`
+	// Seq is the position of the event in the hash chain, events recorded
+	// before the chain was introduced have none
+	Seq      *int64 `gorm:"seq;uniqueIndex" json:"seq,omitempty"`
+	PrevHash string `gorm:"prev_hash" json:"prev_hash,omitempty"`
+	Hash     string `gorm:"hash" json:"hash,omitempty"`
 }
 
 func (a *Event) Get(ctx context.Context) error {
@@ -148,7 +169,41 @@ func (a *Event) List(ctx context.Context, from, to time.Time) (ats []Event, err
 	return
 }
 
+// ListAfter - lists events in (time_stamp, id) order that come after the
+// given position and were recorded before until
+func (a *Event) ListAfter(ctx context.Context, ts time.Time, id string, until time.Time, limit int) (ats []Event, err error) {
+	err = db.FromContext(ctx).Model(&Event{}).
+		Where("(time_stamp > ? OR (time_stamp = ? AND id > ?)) AND time_stamp < ?", ts, ts, id, until).
+		Order("time_stamp, id").Limit(limit).Find(&ats).Error
+	return
+}
+
+// DeleteOldEvents - deletes the events recorded before the hash chain that
+// are past the retention period, chained events are pruned with DeleteChainedUpTo
 func (a *Event) DeleteOldEvents(ctx context.Context, retentionDays int) error {
 	cutoff := time.Now().AddDate(0, 0, -retentionDays)
-	return db.FromContext(ctx).Model(&Event{}).Where("created_at < ?", cutoff).Delete(&Event{}).Error
+	return db.FromContext(ctx).Model(&Event{}).Where("seq IS NULL AND time_stamp < ?", cutoff).Delete(&Event{}).Error
+}
+
+// GetChainHead - gets the chained event with the highest sequence number
+func (a *Event) GetChainHead(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&Event{}).Where("seq IS NOT NULL").Order("seq DESC").First(&a).Error
+}
+
+// GetLastChainedBefore - gets the chained event with the highest sequence
+// number recorded before the cutoff
+func (a *Event) GetLastChainedBefore(ctx context.Context, cutoff time.Time) error {
+	return db.FromContext(ctx).Model(&Event{}).Where("seq IS NOT NULL AND time_stamp < ?", cutoff).
+		Order("seq DESC").First(&a).Error
+}
+
+// ListChained - lists chained events after the given sequence number in chain order
+func (a *Event) ListChained(ctx context.Context, afterSeq int64, limit int) (ats []Event, err error) {
+	err = db.FromContext(ctx).Model(&Event{}).Where("seq > ?", afterSeq).Order("seq").Limit(limit).Find(&ats).Error
+	return
+}
+
+// DeleteChainedUpTo - deletes the chained events up to and including seq
+func (a *Event) DeleteChainedUpTo(ctx context.Context, seq int64) error {
+	return db.FromContext(ctx).Model(&Event{}).Where("seq <= ?", seq).Delete(&Event{}).Error
 }
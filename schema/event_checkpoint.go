@@ -0,0 +1,40 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+)
+
+// EventCheckpoint - signed snapshot of the event hash chain. Checkpoints are
+// kept when events are pruned so the remaining chain can still be verified.
+type EventCheckpoint struct {
+	ID        string    `gorm:"primaryKey" json:"id"`
+	Seq       int64     `gorm:"seq;uniqueIndex" json:"seq"`
+	EventID   string    `gorm:"event_id" json:"event_id"`
+	Hash      string    `gorm:"hash" json:"hash"`
+	EventTime time.Time `gorm:"event_time" json:"event_time"`
+	Signature string    `gorm:"signature" json:"signature"`
+	CreatedAt time.Time `gorm:"created_at" json:"created_at"`
+}
+
+func (c *EventCheckpoint) Create(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&EventCheckpoint{}).Create(&c).Error
+}
+
+// GetBySeq - gets the checkpoint of the event at c.Seq
+func (c *EventCheckpoint) GetBySeq(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&EventCheckpoint{}).Where("seq = ?", c.Seq).First(&c).Error
+}
+
+// GetLatest - gets the checkpoint with the highest sequence number
+func (c *EventCheckpoint) GetLatest(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&EventCheckpoint{}).Order("seq DESC").First(&c).Error
+}
+
+// ListAll - lists all checkpoints in chain order
+func (c *EventCheckpoint) ListAll(ctx context.Context) (cps []EventCheckpoint, err error) {
+	err = db.FromContext(ctx).Model(&EventCheckpoint{}).Order("seq").Find(&cps).Error
+	return
+}
@@ -5,20 +5,46 @@ import (
 	"time"
 
 	"github.com/gravitl/netmaker/db"
+	"gorm.io/datatypes"
+)
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
 )
 
 // Job represents a task that netmaker server
 // wants to do.
 //
-// Ideally, a jobs table should have details
-// about its type, status, who initiated it,
-// etc. But, for now, the table only contains
-// records of jobs that have been done, so
-// that it is easier to prevent a task from
-// being executed again.
+// Jobs with a Type are queued background
+// operations run by the leader, e.g. bulk
+// deletes, see logic.EnqueueJob. Jobs without
+// a Type are records of one-shot migrations
+// that have been done, so that it is easier
+// to prevent a task from being executed again.
 type Job struct {
-	ID        string `gorm:"primaryKey"`
-	CreatedAt time.Time
+	ID              string         `gorm:"primaryKey" json:"id"`
+	Type            string         `gorm:"index" json:"type"`
+	Status          JobStatus      `gorm:"index" json:"status"`
+	Initiator       string         `json:"initiator"`
+	Payload         datatypes.JSON `json:"payload" swaggertype:"object"`
+	Progress        int            `json:"progress"`
+	Total           int            `json:"total"`
+	Attempts        int            `json:"attempts"`
+	MaxAttempts     int            `json:"max_attempts"`
+	Error           string         `json:"error,omitempty"`
+	CancelRequested bool           `json:"cancel_requested"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	// NextRunAt - time a queued job is retried at
+	NextRunAt  time.Time  `json:"next_run_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
 }
 
 // Create creates a job record in the jobs table.
@@ -30,3 +56,106 @@ func (j *Job) Create(ctx context.Context) error {
 func (j *Job) Get(ctx context.Context) error {
 	return db.FromContext(ctx).Model(&Job{}).Where("id = ?", j.ID).First(j).Error
 }
+
+// Claim marks the queued job as running, claimed is false if the job is not
+// queued anymore, e.g. it was cancelled in the meantime.
+func (j *Job) Claim(ctx context.Context) (claimed bool, err error) {
+	now := time.Now().UTC()
+	result := db.FromContext(ctx).Model(&Job{}).
+		Where("id = ? AND status = ?", j.ID, JobQueued).
+		Updates(map[string]any{
+			"status":     JobRunning,
+			"attempts":   j.Attempts + 1,
+			"started_at": now,
+			"updated_at": now,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	return true, j.Get(ctx)
+}
+
+// Finish records the outcome of a run of the job.
+func (j *Job) Finish(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&Job{}).Where("id = ?", j.ID).Updates(map[string]any{
+		"status":      j.Status,
+		"error":       j.Error,
+		"progress":    j.Progress,
+		"total":       j.Total,
+		"next_run_at": j.NextRunAt,
+		"finished_at": j.FinishedAt,
+		"updated_at":  time.Now().UTC(),
+	}).Error
+}
+
+// UpdateProgress stores the progress of the running job.
+func (j *Job) UpdateProgress(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&Job{}).Where("id = ?", j.ID).Updates(map[string]any{
+		"progress":   j.Progress,
+		"total":      j.Total,
+		"updated_at": time.Now().UTC(),
+	}).Error
+}
+
+// RequestCancel asks the runner to stop the running job.
+func (j *Job) RequestCancel(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&Job{}).Where("id = ?", j.ID).Updates(map[string]any{
+		"cancel_requested": true,
+		"updated_at":       time.Now().UTC(),
+	}).Error
+}
+
+// CancelQueued cancels the job if it is still queued, cancelled is false if
+// it started running in the meantime.
+func (j *Job) CancelQueued(ctx context.Context) (cancelled bool, err error) {
+	now := time.Now().UTC()
+	result := db.FromContext(ctx).Model(&Job{}).
+		Where("id = ? AND status = ?", j.ID, JobQueued).
+		Updates(map[string]any{
+			"status":           JobCancelled,
+			"cancel_requested": true,
+			"finished_at":      now,
+			"updated_at":       now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListDue lists the queued jobs due to run, oldest first.
+func (j *Job) ListDue(ctx context.Context, now time.Time) (jobs []Job, err error) {
+	err = db.FromContext(ctx).Model(&Job{}).
+		Where("status = ? AND next_run_at <= ?", JobQueued, now).
+		Order("created_at").
+		Find(&jobs).Error
+	return
+}
+
+// List lists the background jobs latest first, filtered by Type and Status
+// if set. Migration records are left out.
+func (j *Job) List(ctx context.Context) (jobs []Job, err error) {
+	query := db.FromContext(ctx).Model(&Job{}).Where("type <> ''")
+	if j.Type != "" {
+		query = query.Where("type = ?", j.Type)
+	}
+	if j.Status != "" {
+		query = query.Where("status = ?", j.Status)
+	}
+	err = query.Order("created_at DESC").Find(&jobs).Error
+	return
+}
+
+// RequeueRunning puts the jobs left running by a previous leader back in the
+// queue.
+func (j *Job) RequeueRunning(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&Job{}).Where("status = ?", JobRunning).Updates(map[string]any{
+		"status":     JobQueued,
+		"updated_at": time.Now().UTC(),
+	}).Error
+}
+
+// DeleteFinishedBefore deletes the background jobs that finished before the
+// cutoff.
+func (j *Job) DeleteFinishedBefore(ctx context.Context, cutoff time.Time) error {
+	return db.FromContext(ctx).Model(&Job{}).
+		Where("type <> '' AND status IN ? AND finished_at < ?", []JobStatus{JobSucceeded, JobFailed, JobCancelled}, cutoff).
+		Delete(&Job{}).Error
+}
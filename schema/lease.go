@@ -0,0 +1,90 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Lease - time bound lock held by one server replica. The holder renews the
+// lease before it expires, any other replica may take over an expired lease.
+//
+// Leases are runtime state of the running replicas, so they are not listed in
+// ListModels and are not part of backups. The leader package migrates the
+// table itself.
+type Lease struct {
+	Name       string    `gorm:"primaryKey" json:"name"`
+	Holder     string    `gorm:"holder" json:"holder"`
+	Term       int64     `gorm:"term" json:"term"`
+	AcquiredAt time.Time `gorm:"acquired_at" json:"acquired_at"`
+	RenewedAt  time.Time `gorm:"renewed_at" json:"renewed_at"`
+	ExpiresAt  time.Time `gorm:"expires_at" json:"expires_at"`
+}
+
+func (l *Lease) Get(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&Lease{}).Where("name = ?", l.Name).First(&l).Error
+}
+
+// Acquire - creates, renews or takes over the lease for l.Holder until
+// now+ttl. Each statement is a single conditional write, so at most one
+// replica holds the lease at a time. On return l reflects the stored lease.
+func (l *Lease) Acquire(ctx context.Context, now time.Time, ttl time.Duration) (bool, error) {
+	now = now.UTC()
+	expiresAt := now.Add(ttl)
+	holder := l.Holder
+	tx := db.FromContext(ctx)
+
+	res := tx.Model(&Lease{}).Clauses(clause.OnConflict{DoNothing: true}).Create(&Lease{
+		Name:       l.Name,
+		Holder:     holder,
+		Term:       1,
+		AcquiredAt: now,
+		RenewedAt:  now,
+		ExpiresAt:  expiresAt,
+	})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	acquired := res.RowsAffected == 1
+	if !acquired {
+		// renew the lease held by this replica
+		res = tx.Model(&Lease{}).
+			Where("name = ? AND holder = ?", l.Name, holder).
+			Updates(map[string]any{"renewed_at": now, "expires_at": expiresAt})
+		if res.Error != nil {
+			return false, res.Error
+		}
+		acquired = res.RowsAffected == 1
+	}
+	if !acquired {
+		// take over an expired lease
+		res = tx.Model(&Lease{}).
+			Where("name = ? AND expires_at < ?", l.Name, now).
+			Updates(map[string]any{
+				"holder":      holder,
+				"term":        gorm.Expr("term + 1"),
+				"acquired_at": now,
+				"renewed_at":  now,
+				"expires_at":  expiresAt,
+			})
+		if res.Error != nil {
+			return false, res.Error
+		}
+		acquired = res.RowsAffected == 1
+	}
+	if err := l.Get(ctx); err != nil {
+		return false, err
+	}
+	return acquired && l.Holder == holder, nil
+}
+
+// Release - expires the lease if it is still held by l.Holder, so another
+// replica can take over without waiting for the lease to run out
+func (l *Lease) Release(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&Lease{}).
+		Where("name = ? AND holder = ?", l.Name, l.Holder).
+		Update("expires_at", time.Unix(0, 0).UTC()).Error
+}
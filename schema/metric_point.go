@@ -0,0 +1,102 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+)
+
+// MetricsResolution - size of the bucket a metric point aggregates
+type MetricsResolution string
+
+const (
+	MetricsRawResolution    MetricsResolution = "raw"
+	MetricsHourlyResolution MetricsResolution = "hour"
+	MetricsDailyResolution  MetricsResolution = "day"
+)
+
+// MetricPoint - connectivity of a node towards one of its peers, either a
+// single sample reported by the node or the aggregate of the samples of a
+// bucket once downsampled
+type MetricPoint struct {
+	ID         uint              `gorm:"primaryKey;autoIncrement" json:"-"`
+	Network    string            `gorm:"network" json:"network"`
+	NodeID     string            `gorm:"node_id;index:idx_metric_points_pair,priority:1" json:"node_id"`
+	PeerID     string            `gorm:"peer_id;index:idx_metric_points_pair,priority:2" json:"peer_id"`
+	Resolution MetricsResolution `gorm:"resolution;index:idx_metric_points_bucket,priority:1" json:"resolution"`
+	TimeStamp  time.Time         `gorm:"time_stamp;index:idx_metric_points_pair,priority:3;index:idx_metric_points_bucket,priority:2" json:"time_stamp"`
+	// Latency - average latency of the samples in ms
+	Latency    int64 `gorm:"latency" json:"latency"`
+	MaxLatency int64 `gorm:"max_latency" json:"max_latency"`
+	// TotalSent, TotalReceived - bytes exchanged with the peer since the
+	// connection was first seen, the last value of the bucket
+	TotalSent     int64 `gorm:"total_sent" json:"total_sent"`
+	TotalReceived int64 `gorm:"total_received" json:"total_received"`
+	// Connected - share of the samples the peer was connected
+	Connected float64 `gorm:"connected" json:"connected"`
+	Samples   int64   `gorm:"samples" json:"samples"`
+}
+
+func (p *MetricPoint) CreateBatch(ctx context.Context, points []MetricPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+	return db.FromContext(ctx).Model(&MetricPoint{}).Create(&points).Error
+}
+
+// ListByNode - lists the points of a node in [from, to), towards a single
+// peer if PeerID is set
+func (p *MetricPoint) ListByNode(ctx context.Context, from, to time.Time) (points []MetricPoint, err error) {
+	query := db.FromContext(ctx).Model(&MetricPoint{}).
+		Where("node_id = ? AND time_stamp >= ? AND time_stamp < ?", p.NodeID, from, to)
+	if p.PeerID != "" {
+		query = query.Where("peer_id = ?", p.PeerID)
+	}
+	err = query.Order("time_stamp").Find(&points).Error
+	return
+}
+
+// GetOldest - gets the oldest point of the resolution
+func (p *MetricPoint) GetOldest(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&MetricPoint{}).
+		Where("resolution = ?", p.Resolution).
+		Order("time_stamp").
+		First(&p).
+		Error
+}
+
+// Aggregate - aggregates the points of the resolution in [from, to) per
+// node pair
+func (p *MetricPoint) Aggregate(ctx context.Context, from, to time.Time) (points []MetricPoint, err error) {
+	err = db.FromContext(ctx).Model(&MetricPoint{}).
+		Select(`network, node_id, peer_id,
+			CAST(SUM(latency * samples) / SUM(samples) AS BIGINT) AS latency,
+			MAX(max_latency) AS max_latency,
+			MAX(total_sent) AS total_sent,
+			MAX(total_received) AS total_received,
+			SUM(connected * samples) / CAST(SUM(samples) AS DOUBLE PRECISION) AS connected,
+			CAST(SUM(samples) AS BIGINT) AS samples`).
+		Where("resolution = ? AND time_stamp >= ? AND time_stamp < ?", p.Resolution, from, to).
+		Group("network, node_id, peer_id").
+		Scan(&points).
+		Error
+	return
+}
+
+// DeleteRange - deletes the points of the resolution in [from, to)
+func (p *MetricPoint) DeleteRange(ctx context.Context, from, to time.Time) error {
+	return db.FromContext(ctx).Model(&MetricPoint{}).
+		Where("resolution = ? AND time_stamp >= ? AND time_stamp < ?", p.Resolution, from, to).
+		Delete(&MetricPoint{}).
+		Error
+}
+
+// DeleteBefore - deletes the points of all resolutions recorded before the
+// cutoff
+func (p *MetricPoint) DeleteBefore(ctx context.Context, cutoff time.Time) error {
+	return db.FromContext(ctx).Model(&MetricPoint{}).
+		Where("time_stamp < ?", cutoff).
+		Delete(&MetricPoint{}).
+		Error
+}
@@ -6,7 +6,9 @@ func ListModels() []interface{} {
 		&Job{},
 		&Egress{},
 		&UserAccessToken{},
+		&UserWebAuthnCredential{},
 		&Event{},
+		&EventCheckpoint{},
 		&PendingHost{},
 		&Nameserver{},
 		&PostureCheck{},
@@ -17,5 +19,11 @@ func ListModels() []interface{} {
 		&JITRequest{},
 		&JITGrant{},
 		&Host{},
+		&Webhook{},
+		&WebhookDelivery{},
+		&MetricPoint{},
+		&AlertRule{},
+		&Alert{},
+		&SchemaMigration{},
 	}
 }
@@ -0,0 +1,36 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+)
+
+// SchemaMigration - record of a versioned migration applied to the
+// database, see migrate.Up.
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+func (m *SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Create - records the migration as applied
+func (m *SchemaMigration) Create(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&SchemaMigration{}).Create(m).Error
+}
+
+// Delete - removes the record of the migration once it is reverted
+func (m *SchemaMigration) Delete(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&SchemaMigration{}).Where("version = ?", m.Version).Delete(&SchemaMigration{}).Error
+}
+
+// ListAll - lists the applied migrations by version
+func (m *SchemaMigration) ListAll(ctx context.Context) (migrations []SchemaMigration, err error) {
+	err = db.FromContext(ctx).Model(&SchemaMigration{}).Order("version").Find(&migrations).Error
+	return
+}
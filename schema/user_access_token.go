@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/gravitl/netmaker/db"
+	"gorm.io/datatypes"
 )
 
 // UserAccessToken - token used to access netmaker
@@ -16,10 +17,19 @@ type UserAccessToken struct {
 	LastUsed  time.Time `json:"last_used"`
 	CreatedBy string    `json:"created_by"`
 	CreatedAt time.Time `json:"created_at"`
+	// Scoped - restricts the token to the resources granted by its
+	// access scopes, on top of the permissions of its user. A token that
+	// is not scoped has all the permissions of its user.
+	Scoped             bool                                             `json:"scoped"`
+	NetworkLevelAccess datatypes.JSONType[map[NetworkID]ResourceAccess] `json:"network_level_access"`
+	GlobalLevelAccess  datatypes.JSONType[ResourceAccess]               `json:"global_level_access"`
+	// AllowedCIDRs - source ranges the token can be used from, any source
+	// is allowed when empty
+	AllowedCIDRs datatypes.JSONSlice[string] `json:"allowed_cidrs"`
 }
 
 func (a *UserAccessToken) Get(ctx context.Context) error {
-	return db.FromContext(ctx).Model(&UserAccessToken{}).First(&a).Where("id = ?", a.ID).Error
+	return db.FromContext(ctx).Model(&UserAccessToken{}).Where("id = ?", a.ID).First(&a).Error
 }
 
 func (a *UserAccessToken) Update(ctx context.Context) error {
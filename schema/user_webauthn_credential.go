@@ -0,0 +1,53 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+	"gorm.io/datatypes"
+)
+
+// UserWebAuthnCredential - webauthn credential (security key or passkey)
+// registered by a user as a second factor or for passwordless sign in
+type UserWebAuthnCredential struct {
+	// ID - base64url encoded credential id
+	ID       string `gorm:"primaryKey" json:"id"`
+	UserName string `gorm:"user_name;index" json:"user_name"`
+	Name     string `gorm:"name" json:"name"`
+	// Credential - public key, sign count and flags of the credential
+	Credential datatypes.JSON `gorm:"credential" json:"-"`
+	CreatedAt  time.Time      `gorm:"created_at" json:"created_at"`
+	LastUsedAt time.Time      `gorm:"last_used_at" json:"last_used_at"`
+}
+
+func (c *UserWebAuthnCredential) Get(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&UserWebAuthnCredential{}).Where("id = ?", c.ID).First(&c).Error
+}
+
+func (c *UserWebAuthnCredential) Create(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&UserWebAuthnCredential{}).Create(&c).Error
+}
+
+func (c *UserWebAuthnCredential) UpdateUsage(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&UserWebAuthnCredential{}).Where("id = ?", c.ID).Updates(map[string]any{
+		"credential":   c.Credential,
+		"last_used_at": c.LastUsedAt,
+	}).Error
+}
+
+func (c *UserWebAuthnCredential) ListByUser(ctx context.Context) (creds []UserWebAuthnCredential, err error) {
+	err = db.FromContext(ctx).Model(&UserWebAuthnCredential{}).
+		Where("user_name = ?", c.UserName).
+		Order("created_at").
+		Find(&creds).Error
+	return
+}
+
+func (c *UserWebAuthnCredential) Delete(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&UserWebAuthnCredential{}).Where("id = ?", c.ID).Delete(&c).Error
+}
+
+func (c *UserWebAuthnCredential) DeleteAllUserCredentials(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&UserWebAuthnCredential{}).Where("user_name = ?", c.UserName).Delete(&c).Error
+}
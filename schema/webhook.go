@@ -0,0 +1,121 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+	"gorm.io/datatypes"
+)
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// Webhook - subscription of an endpoint to audit events. Empty filters match
+// every event.
+type Webhook struct {
+	ID           string                           `gorm:"primaryKey" json:"id"`
+	Name         string                           `gorm:"name" json:"name"`
+	URL          string                           `gorm:"url" json:"url"`
+	Secret       string                           `gorm:"secret" json:"secret"`
+	Actions      datatypes.JSONSlice[Action]      `gorm:"actions" json:"actions"`
+	SubjectTypes datatypes.JSONSlice[SubjectType] `gorm:"subject_types" json:"subject_types"`
+	Networks     datatypes.JSONSlice[NetworkID]   `gorm:"networks" json:"networks"`
+	Enabled      bool                             `gorm:"enabled" json:"enabled"`
+	CreatedBy    string                           `gorm:"created_by" json:"created_by"`
+	CreatedAt    time.Time                        `gorm:"created_at" json:"created_at"`
+	UpdatedAt    time.Time                        `gorm:"updated_at" json:"updated_at"`
+}
+
+func (w *Webhook) Get(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&Webhook{}).Where("id = ?", w.ID).First(&w).Error
+}
+
+func (w *Webhook) Create(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&Webhook{}).Create(&w).Error
+}
+
+func (w *Webhook) Update(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&Webhook{}).Where("id = ?", w.ID).Updates(map[string]any{
+		"name":          w.Name,
+		"url":           w.URL,
+		"secret":        w.Secret,
+		"actions":       w.Actions,
+		"subject_types": w.SubjectTypes,
+		"networks":      w.Networks,
+		"enabled":       w.Enabled,
+		"updated_at":    w.UpdatedAt,
+	}).Error
+}
+
+func (w *Webhook) Delete(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&Webhook{}).Where("id = ?", w.ID).Delete(&w).Error
+}
+
+func (w *Webhook) ListAll(ctx context.Context) (webhooks []Webhook, err error) {
+	err = db.FromContext(ctx).Model(&Webhook{}).Order("created_at").Find(&webhooks).Error
+	return
+}
+
+func (w *Webhook) ListEnabled(ctx context.Context) (webhooks []Webhook, err error) {
+	err = db.FromContext(ctx).Model(&Webhook{}).Where("enabled = ?", true).Find(&webhooks).Error
+	return
+}
+
+// WebhookDelivery - delivery of a single event to a webhook, retried until
+// it succeeds or runs out of attempts
+type WebhookDelivery struct {
+	ID            string                `gorm:"primaryKey" json:"id"`
+	WebhookID     string                `gorm:"webhook_id;index" json:"webhook_id"`
+	EventID       string                `gorm:"event_id" json:"event_id"`
+	Action        Action                `gorm:"action" json:"action"`
+	Payload       datatypes.JSON        `gorm:"payload" json:"payload"`
+	Status        WebhookDeliveryStatus `gorm:"status" json:"status"`
+	Attempts      int                   `gorm:"attempts" json:"attempts"`
+	StatusCode    int                   `gorm:"status_code" json:"status_code"`
+	Error         string                `gorm:"error" json:"error"`
+	NextAttemptAt time.Time             `gorm:"next_attempt_at" json:"next_attempt_at"`
+	CreatedAt     time.Time             `gorm:"created_at" json:"created_at"`
+	UpdatedAt     time.Time             `gorm:"updated_at" json:"updated_at"`
+}
+
+func (d *WebhookDelivery) Create(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&WebhookDelivery{}).Create(&d).Error
+}
+
+func (d *WebhookDelivery) Update(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&WebhookDelivery{}).Where("id = ?", d.ID).Updates(map[string]any{
+		"status":          d.Status,
+		"attempts":        d.Attempts,
+		"status_code":     d.StatusCode,
+		"error":           d.Error,
+		"next_attempt_at": d.NextAttemptAt,
+		"updated_at":      d.UpdatedAt,
+	}).Error
+}
+
+func (d *WebhookDelivery) ListByWebhook(ctx context.Context) (deliveries []WebhookDelivery, err error) {
+	err = db.FromContext(ctx).Model(&WebhookDelivery{}).Where("webhook_id = ?", d.WebhookID).
+		Order("created_at DESC").Find(&deliveries).Error
+	return
+}
+
+func (d *WebhookDelivery) ListDue(ctx context.Context) (deliveries []WebhookDelivery, err error) {
+	err = db.FromContext(ctx).Model(&WebhookDelivery{}).Where("status = ? AND next_attempt_at <= ?",
+		WebhookDeliveryPending, time.Now().UTC()).Order("next_attempt_at").Find(&deliveries).Error
+	return
+}
+
+func (d *WebhookDelivery) DeleteByWebhook(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&WebhookDelivery{}).Where("webhook_id = ?", d.WebhookID).Delete(&WebhookDelivery{}).Error
+}
+
+func (d *WebhookDelivery) DeleteOld(ctx context.Context, retentionDays int) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	return db.FromContext(ctx).Model(&WebhookDelivery{}).Where("created_at < ?", cutoff).Delete(&WebhookDelivery{}).Error
+}
@@ -67,3 +67,16 @@ func GetClickHousePassword() string {
 	}
 	return password
 }
+
+// GetFlowGRPCPort - gets the port the flow ingestion
+// gRPC service listens on.
+func GetFlowGRPCPort() int {
+	port := 50051
+	envport, err := strconv.Atoi(os.Getenv("FLOW_GRPC_PORT"))
+	if err == nil && envport != 0 {
+		port = envport
+	} else if config.Config.Server.FlowGRPCPort != 0 {
+		port = config.Config.Server.FlowGRPCPort
+	}
+	return port
+}
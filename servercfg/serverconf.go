@@ -310,6 +310,31 @@ func GetMetricsExporterURL() string {
 	return "http://netmaker-exporter:8085"
 }
 
+// GetMetricsScrapeToken - gets the bearer token prometheus scrapes the
+// /metrics endpoint with, the endpoint is disabled when it is not set
+func GetMetricsScrapeToken() string {
+	if os.Getenv("METRICS_SCRAPE_TOKEN") != "" {
+		return os.Getenv("METRICS_SCRAPE_TOKEN")
+	}
+	return config.Config.Server.MetricsScrapeToken
+}
+
+// GetTrustedProxies - gets the addresses or cidrs of the reverse proxies
+// whose forwarding headers are trusted to carry the client ip
+func GetTrustedProxies() []string {
+	proxies := config.Config.Server.TrustedProxies
+	if os.Getenv("TRUSTED_PROXIES") != "" {
+		proxies = os.Getenv("TRUSTED_PROXIES")
+	}
+	var trusted []string
+	for _, proxy := range strings.Split(proxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trusted = append(trusted, proxy)
+		}
+	}
+	return trusted
+}
+
 // IsMessageQueueBackend - checks if message queue is on or off
 func IsMessageQueueBackend() bool {
 	ismessagequeue := true
@@ -815,49 +840,3 @@ func GetNmBaseDomain() string {
 func IsHA() bool {
 	return os.Getenv("IS_HA") == "true"
 }
-
-// IsMasterPod - returns true if this pod should run singleton operations
-// In K8s StatefulSet HA mode, the 0th pod (hostname ending with -0) is the master.
-// This ensures migrations, IDP sync, and other singleton operations only run on one pod.
-// Can be overridden with IS_MASTER_POD env var for manual control.
-//
-// TODO: Future Enhancement - Implement Leader Election for True HA Failover
-// Current limitation: If pod-0 goes down, singleton operations and MQ subscriptions
-// are unavailable until pod-0 recovers. For automatic failover, implement:
-//
-// Option 1: K8s Lease-based Leader Election
-//   - Use client-go's leaderelection package
-//   - Any pod can become leader if current leader fails
-//   - Requires K8s ServiceAccount with lease permissions
-//
-// Option 2: Database-based Distributed Lock
-//   - Use database (PostgreSQL/SQLite) advisory locks
-//   - Leader heartbeats to maintain lock, others acquire on timeout
-//   - Works in non-K8s environments too
-//
-// Option 3: etcd/Consul-based Leader Election
-//   - Use distributed consensus systems
-//   - Most robust but adds infrastructure dependency
-//
-// Implementation would replace static pod-0 check with dynamic leader status.
-func IsMasterPod() bool {
-	// Allow manual override via environment variable
-	if override := os.Getenv("IS_MASTER_POD"); override != "" {
-		return override == "true"
-	}
-
-	// If not in HA mode, default to true (single instance deployment)
-	if !IsHA() {
-		return true
-	}
-
-	// StatefulSet pods are named <statefulset-name>-<ordinal>.
-	// Compare the last hyphen-delimited segment to "0" to avoid
-	// false positives (e.g. "netmaker-10" matching a naive HasSuffix "-0").
-	hostname, err := os.Hostname()
-	if err != nil {
-		return true
-	}
-	parts := strings.Split(hostname, "-")
-	return parts[len(parts)-1] == "0"
-}
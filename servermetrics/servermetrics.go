@@ -0,0 +1,241 @@
+// Package servermetrics exposes the internals of the server in the
+// Prometheus text exposition format.
+//
+// Counters and histograms that are updated while the server runs are
+// registered once and written on every scrape. Values that are read from
+// the database at scrape time (e.g. node statuses) are written with
+// WriteFamily by the scrape handler.
+package servermetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metric types of the exposition format
+const (
+	CounterType   = "counter"
+	GaugeType     = "gauge"
+	HistogramType = "histogram"
+)
+
+// DefaultBuckets - upper bounds in seconds for latencies of in-process operations
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+var (
+	// APIRequestDuration - latency of the REST api requests
+	APIRequestDuration = NewHistogramVec(
+		"netmaker_api_request_duration_seconds",
+		"Latency of REST api requests.",
+		DefaultBuckets, "method", "route", "code",
+	)
+	// PeerUpdatePublishDuration - time taken to publish a peer update to all hosts
+	PeerUpdatePublishDuration = NewHistogramVec(
+		"netmaker_peer_update_publish_duration_seconds",
+		"Time taken to compute and publish a peer update to all hosts.",
+		DefaultBuckets, "result",
+	)
+	// HookDuration - run time of the timer hooks
+	HookDuration = NewHistogramVec(
+		"netmaker_hook_duration_seconds",
+		"Run time of the server timer hooks.",
+		DefaultBuckets, "hook", "result",
+	)
+)
+
+var (
+	registryMutex sync.RWMutex
+	registry      []*HistogramVec
+)
+
+// Label - name and value of a label of a sample
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample - a single value of a metric family
+type Sample struct {
+	// Suffix - appended to the family name, e.g. _bucket for histograms
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Result - label value for the outcome of an operation
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// HistogramVec - histograms partitioned by label values
+type HistogramVec struct {
+	name    string
+	help    string
+	buckets []float64
+	labels  []string
+
+	mutex  sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+// NewHistogramVec - creates a histogram and registers it so it is
+// written on every scrape
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		labels:  labels,
+		series:  make(map[string]*histogram),
+	}
+	registryMutex.Lock()
+	registry = append(registry, h)
+	registryMutex.Unlock()
+	return h
+}
+
+// Observe - records a value for the given label values, which must be
+// given in the order the labels were declared
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// ObserveSince - records the time elapsed since start in seconds
+func (h *HistogramVec) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *HistogramVec) samples() []Sample {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var samples []Sample
+	for _, key := range keys {
+		s := h.series[key]
+		labels := make([]Label, len(h.labels))
+		for i := range h.labels {
+			var value string
+			if i < len(s.labelValues) {
+				value = s.labelValues[i]
+			}
+			labels[i] = Label{Name: h.labels[i], Value: value}
+		}
+		for i, bound := range h.buckets {
+			samples = append(samples, Sample{
+				Suffix: "_bucket",
+				Labels: append(labels[:len(labels):len(labels)], Label{Name: "le", Value: formatFloat(bound)}),
+				Value:  float64(s.counts[i]),
+			})
+		}
+		samples = append(samples,
+			Sample{Suffix: "_bucket", Labels: append(labels[:len(labels):len(labels)], Label{Name: "le", Value: "+Inf"}), Value: float64(s.count)},
+			Sample{Suffix: "_sum", Labels: labels, Value: s.sum},
+			Sample{Suffix: "_count", Labels: labels, Value: float64(s.count)},
+		)
+	}
+	return samples
+}
+
+// WriteRegistered - writes all registered histograms
+func WriteRegistered(w io.Writer) error {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	for _, h := range registry {
+		if err := WriteFamily(w, h.name, h.help, HistogramType, h.samples()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFamily - writes a metric family with its help and type lines
+func WriteFamily(w io.Writer, name, help, metricType string, samples []Sample) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(bw, "# TYPE %s %s\n", name, metricType)
+	for _, sample := range samples {
+		bw.WriteString(name)
+		bw.WriteString(sample.Suffix)
+		if len(sample.Labels) > 0 {
+			bw.WriteByte('{')
+			for i, label := range sample.Labels {
+				if i > 0 {
+					bw.WriteByte(',')
+				}
+				bw.WriteString(label.Name)
+				bw.WriteString(`="`)
+				bw.WriteString(escapeLabelValue(label.Value))
+				bw.WriteByte('"')
+			}
+			bw.WriteByte('}')
+		}
+		bw.WriteByte(' ')
+		bw.WriteString(formatFloat(sample.Value))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,57 @@
+package servermetrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteFamily(t *testing.T) {
+	var b strings.Builder
+	err := WriteFamily(&b, "netmaker_nodes", "Nodes by status.", GaugeType, []Sample{
+		{Labels: []Label{{Name: "network", Value: `net"x`}, {Name: "status", Value: "online"}}, Value: 3},
+		{Value: 0.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP netmaker_nodes Nodes by status.
+# TYPE netmaker_nodes gauge
+netmaker_nodes{network="net\"x",status="online"} 3
+netmaker_nodes 0.5
+`
+	if b.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	h := &HistogramVec{
+		name:    "test_duration_seconds",
+		help:    "test",
+		buckets: []float64{0.1, 1},
+		labels:  []string{"result"},
+		series:  make(map[string]*histogram),
+	}
+	h.Observe(0.05, Result(nil))
+	h.Observe(0.5, Result(nil))
+	h.Observe(2, Result(errors.New("failed")))
+
+	var b strings.Builder
+	if err := WriteFamily(&b, h.name, h.help, HistogramType, h.samples()); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`test_duration_seconds_bucket{result="error",le="1"} 0`,
+		`test_duration_seconds_bucket{result="error",le="+Inf"} 1`,
+		`test_duration_seconds_count{result="error"} 1`,
+		`test_duration_seconds_bucket{result="success",le="0.1"} 1`,
+		`test_duration_seconds_bucket{result="success",le="1"} 2`,
+		`test_duration_seconds_sum{result="success"} 0.55`,
+		`test_duration_seconds_count{result="success"} 2`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("missing %q in\n%s", line, b.String())
+		}
+	}
+}